@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func CreateAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+func (h *APIKeyHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	key, secret, err := h.apiKeyService.Issue(r.Context(), tenantID, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrAPIKeyScopesNeeded) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, services.ErrAPIKeyScopeDenied) {
+			writeErrorFrom(w, http.StatusForbidden, err)
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.APIKeyResponse{APIKey: key, Secret: secret})
+}
+
+func (h *APIKeyHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	keys, err := h.apiKeyService.List(r.Context(), tenantID)
+	if err != nil {
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.APIKeyListResponse{APIKeys: keys})
+}
+
+func (h *APIKeyHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(r.Context(), tenantID, mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			writeError(w, http.StatusNotFound, models.ErrCodeAPIKeyNotFound, "API key not found")
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
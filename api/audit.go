@@ -7,6 +7,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/internal/pagination"
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/services"
 )
@@ -21,61 +22,78 @@ func CreateAuditHandler(auditService *services.AuditService) *AuditHandler {
 	}
 }
 
+// HandleList pages through the caller's audit logs newest first. Pass the
+// next_cursor from one response as ?cursor= to fetch the following page.
+// Operator JWTs carry no tenant and may list across tenants, optionally
+// narrowed with ?tenant_id=.
 func (h *AuditHandler) HandleList(w http.ResponseWriter, r *http.Request) {
-	filter := models.AuditLogFilter{
-		Limit:  100,
-		Offset: 0,
-	}
-
-	if tenantID := r.Context().Value(ctxkeys.TenantID); tenantID != nil {
-		filter.TenantID = tenantID.(string)
-	}
+	query := r.URL.Query()
 
-	if userID := r.URL.Query().Get("user_id"); userID != "" {
-		filter.UserID = userID
+	tenantID, _ := r.Context().Value(ctxkeys.TenantID).(string)
+	operator := tenantID == "" && isOperatorRequest(r)
+	if tenantID == "" && !operator {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
 	}
-	if action := r.URL.Query().Get("action"); action != "" {
-		filter.Action = action
+	if operator {
+		tenantID = query.Get("tenant_id")
 	}
-	if resourceType := r.URL.Query().Get("resource_type"); resourceType != "" {
-		filter.ResourceType = resourceType
+
+	filter := models.AuditLogFilter{
+		TenantID:     tenantID,
+		UserID:       query.Get("user_id"),
+		Action:       query.Get("action"),
+		ResourceType: query.Get("resource_type"),
+		ResourceID:   query.Get("resource_id"),
+		Limit:        100,
 	}
-	if resourceID := r.URL.Query().Get("resource_id"); resourceID != "" {
-		filter.ResourceID = resourceID
+
+	if actor := query.Get("actor"); actor != "" {
+		filter.UserID = actor
 	}
-	if limit := r.URL.Query().Get("limit"); limit != "" {
+	if limit := query.Get("limit"); limit != "" {
 		if parsed, err := strconv.Atoi(limit); err == nil {
 			filter.Limit = clampLimit(parsed)
 		}
 	}
-	if offset := r.URL.Query().Get("offset"); offset != "" {
-		if parsed, err := strconv.Atoi(offset); err == nil {
-			filter.Offset = parsed
+	if cursor := query.Get("cursor"); cursor != "" {
+		decoded, err := pagination.Decode(cursor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid cursor")
+			return
 		}
+		filter.Cursor = decoded
 	}
-	if startDate := r.URL.Query().Get("start_date"); startDate != "" {
-		if parsed, err := time.Parse(time.RFC3339, startDate); err == nil {
-			filter.StartDate = &parsed
+	if startDate := query.Get("start_date"); startDate != "" {
+		parsed, err := time.Parse(time.RFC3339, startDate)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "start_date must be RFC3339")
+			return
 		}
+		filter.StartDate = &parsed
 	}
-	if endDate := r.URL.Query().Get("end_date"); endDate != "" {
-		if parsed, err := time.Parse(time.RFC3339, endDate); err == nil {
-			filter.EndDate = &parsed
+	if endDate := query.Get("end_date"); endDate != "" {
+		parsed, err := time.Parse(time.RFC3339, endDate)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "end_date must be RFC3339")
+			return
 		}
+		filter.EndDate = &parsed
 	}
 
-	logs, total, err := h.auditService.GetAuditLogs(r.Context(), filter)
+	var page *pagination.Page[*models.AuditLog]
+	var err error
+	if operator {
+		page, err = h.auditService.GetAuditLogsAcrossTenants(r.Context(), filter)
+	} else {
+		page, err = h.auditService.GetAuditLogs(r.Context(), filter)
+	}
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"audit_logs": logs,
-		"total":      total,
-		"limit":      filter.Limit,
-		"offset":     filter.Offset,
-	})
+	writeJSON(w, http.StatusOK, page)
 }
 
 func (h *AuditHandler) HandleGetResourceHistory(w http.ResponseWriter, r *http.Request) {
@@ -90,9 +108,15 @@ func (h *AuditHandler) HandleGetResourceHistory(w http.ResponseWriter, r *http.R
 		}
 	}
 
-	logs, err := h.auditService.GetResourceHistory(r.Context(), resourceType, resourceID, limit)
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	logs, err := h.auditService.GetResourceHistory(r.Context(), tenantID, resourceType, resourceID, limit)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -102,3 +126,36 @@ func (h *AuditHandler) HandleGetResourceHistory(w http.ResponseWriter, r *http.R
 		"resource_id":   resourceID,
 	})
 }
+
+// isAdminRequest reports whether the caller is an API key holding the admin
+// scope or a JWT user with the admin role.
+func isAdminRequest(r *http.Request) bool {
+	if key, scoped := r.Context().Value(ctxkeys.ScopedAPIKey).(*models.APIKey); scoped {
+		return key.HasScope(models.APIKeyScopeAdminRead)
+	}
+	roles, _ := r.Context().Value(ctxkeys.UserRoles).([]string)
+	for _, role := range roles {
+		if role == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+// isAdminWriteRequest is isAdminRequest for endpoints that change state:
+// API keys need the admin:write scope instead of admin:read.
+func isAdminWriteRequest(r *http.Request) bool {
+	if key, scoped := r.Context().Value(ctxkeys.ScopedAPIKey).(*models.APIKey); scoped {
+		return key.HasScope(models.APIKeyScopeAdminWrite)
+	}
+	return isAdminRequest(r)
+}
+
+// isOperatorRequest reports whether the request was authenticated with a JWT
+// rather than a tenant-scoped API key.
+func isOperatorRequest(r *http.Request) bool {
+	if _, scoped := r.Context().Value(ctxkeys.ScopedAPIKey).(*models.APIKey); scoped {
+		return false
+	}
+	return r.Context().Value(ctxkeys.UserID) != nil
+}
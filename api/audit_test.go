@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/internal/pagination"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
+)
+
+type fakeAuditRepository struct {
+	lastFilter *models.AuditLogFilter
+}
+
+func (f *fakeAuditRepository) Create(context.Context, *models.AuditLog) error {
+	return nil
+}
+
+func (f *fakeAuditRepository) List(_ context.Context, filter models.AuditLogFilter) (pagination.Page[*models.AuditLog], error) {
+	f.lastFilter = &filter
+	return pagination.Page[*models.AuditLog]{Data: []*models.AuditLog{}}, nil
+}
+
+func (f *fakeAuditRepository) ListByResource(context.Context, string, string, string, int) ([]*models.AuditLog, error) {
+	return nil, nil
+}
+
+func (f *fakeAuditRepository) CleanupOld(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func listAuditLogs(ctx context.Context, repo *fakeAuditRepository, target string) int {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+	CreateAuditHandler(services.CreateAuditService(repo)).HandleList(rec, req)
+	return rec.Code
+}
+
+func TestAuditListAllowsOperatorWithoutTenant(t *testing.T) {
+	repo := &fakeAuditRepository{}
+	ctx := context.WithValue(context.Background(), ctxkeys.UserID, "ops_1")
+
+	if code := listAuditLogs(ctx, repo, "/v1/audit-logs"); code != http.StatusOK {
+		t.Fatalf("expected 200 for operator JWT, got %d", code)
+	}
+	if repo.lastFilter == nil || repo.lastFilter.TenantID != "" {
+		t.Fatalf("expected an unscoped listing, got %+v", repo.lastFilter)
+	}
+
+	if code := listAuditLogs(ctx, repo, "/v1/audit-logs?tenant_id=tenant_2"); code != http.StatusOK {
+		t.Fatalf("expected 200 for operator JWT with tenant filter, got %d", code)
+	}
+	if repo.lastFilter.TenantID != "tenant_2" {
+		t.Fatalf("expected tenant_id filter to apply, got %q", repo.lastFilter.TenantID)
+	}
+}
+
+func TestAuditListScopesTenantCallers(t *testing.T) {
+	repo := &fakeAuditRepository{}
+	ctx := context.WithValue(context.Background(), ctxkeys.TenantID, "tenant_1")
+
+	if code := listAuditLogs(ctx, repo, "/v1/audit-logs?tenant_id=tenant_2"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if repo.lastFilter.TenantID != "tenant_1" {
+		t.Fatalf("expected tenant caller to stay scoped to tenant_1, got %q", repo.lastFilter.TenantID)
+	}
+
+	if code := listAuditLogs(context.Background(), &fakeAuditRepository{}, "/v1/audit-logs"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without tenant or operator identity, got %d", code)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
@@ -10,19 +11,24 @@ import (
 )
 
 type AuthHandler struct {
-	jwtManager    *security.JWTManager
-	tenantService *services.TenantService
-	tokenDuration time.Duration
+	jwtManager      *security.JWTManager
+	tenantService   *services.TenantService
+	tokenDuration   time.Duration
+	refreshDuration time.Duration
 }
 
-func CreateAuthHandler(jwtManager *security.JWTManager, tenantService *services.TenantService, tokenDuration time.Duration) *AuthHandler {
+func CreateAuthHandler(jwtManager *security.JWTManager, tenantService *services.TenantService, tokenDuration, refreshDuration time.Duration) *AuthHandler {
 	if tokenDuration <= 0 {
 		tokenDuration = 24 * time.Hour
 	}
+	if refreshDuration <= 0 {
+		refreshDuration = 30 * 24 * time.Hour
+	}
 	return &AuthHandler{
-		jwtManager:    jwtManager,
-		tenantService: tenantService,
-		tokenDuration: tokenDuration,
+		jwtManager:      jwtManager,
+		tenantService:   tenantService,
+		tokenDuration:   tokenDuration,
+		refreshDuration: refreshDuration,
 	}
 }
 
@@ -32,17 +38,27 @@ type tokenRequest struct {
 }
 
 type tokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int64  `json:"expires_in"`
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in,omitempty"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type revokeRequest struct {
+	Token string `json:"token"`
 }
 
 // HandleToken exchanges a tenant API key/secret pair for a short-lived JWT that
 // the rest of the /v1 API expects in the Authorization header.
 func (h *AuthHandler) HandleToken(w http.ResponseWriter, r *http.Request) {
 	var req tokenRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeAuthError(w, http.StatusBadRequest, "invalid request body")
+	if err := readJSON(w, r, &req, false); err != nil {
+		writeAuthError(w, err.status, err.message)
 		return
 	}
 
@@ -57,21 +73,80 @@ func (h *AuthHandler) HandleToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.jwtManager.GenerateToken(tenant.ID, tenant.Name, []string{"standard"}, tenant.APIKey, h.tokenDuration)
+	pair, err := h.jwtManager.IssueTokenPair(tenant.ID, tenant.Name, []string{"standard"}, tenant.APIKey, h.tokenDuration, h.refreshDuration)
 	if err != nil {
 		writeAuthError(w, http.StatusInternalServerError, "failed to issue token")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(tokenResponse{
+	writeTokenResponse(w, tokenResponse{
+		AccessToken:      pair.AccessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(h.tokenDuration.Seconds()),
+		RefreshToken:     pair.RefreshToken,
+		RefreshExpiresIn: int64(h.refreshDuration.Seconds()),
+	})
+}
+
+// HandleRefresh exchanges a refresh token for a new access token.
+func (h *AuthHandler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := readJSON(w, r, &req, false); err != nil {
+		writeAuthError(w, err.status, err.message)
+		return
+	}
+
+	if req.RefreshToken == "" {
+		writeAuthError(w, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	token, err := h.jwtManager.Refresh(r.Context(), req.RefreshToken, h.tokenDuration)
+	if err != nil {
+		writeAuthError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+
+	writeTokenResponse(w, tokenResponse{
 		AccessToken: token,
 		TokenType:   "Bearer",
 		ExpiresIn:   int64(h.tokenDuration.Seconds()),
 	})
 }
 
+// HandleRevoke adds an access or refresh token to the revocation list so it is
+// rejected before it expires.
+func (h *AuthHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	var req revokeRequest
+	if err := readJSON(w, r, &req, false); err != nil {
+		writeAuthError(w, err.status, err.message)
+		return
+	}
+
+	if req.Token == "" {
+		writeAuthError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	err := h.jwtManager.Revoke(r.Context(), req.Token)
+	switch {
+	case errors.Is(err, security.ErrRevocationUnavailable):
+		writeAuthError(w, http.StatusServiceUnavailable, "token revocation is unavailable")
+		return
+	case err != nil:
+		writeAuthError(w, http.StatusBadRequest, "invalid token")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeTokenResponse(w http.ResponseWriter, resp tokenResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
 func writeAuthError(w http.ResponseWriter, statusCode int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -23,7 +23,7 @@ func (h *BalanceHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 	if currency != "" {
 		balance, err := h.balanceService.GetBalance(r.Context(), currency)
 		if err != nil {
-			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+			writeServiceError(w, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, map[string]interface{}{
@@ -34,7 +34,7 @@ func (h *BalanceHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 
 	balances, err := h.balanceService.GetAllBalances(r.Context())
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
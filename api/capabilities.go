@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/malwarebo/conductor/providers"
+)
+
+type CapabilitySource interface {
+	AvailableCapabilities(ctx context.Context) map[string]providers.ProviderCapabilities
+}
+
+type CapabilitiesResponse struct {
+	Currency     string                                    `json:"currency,omitempty"`
+	Capabilities providers.ProviderCapabilities            `json:"capabilities"`
+	Providers    map[string]providers.ProviderCapabilities `json:"providers"`
+}
+
+type CapabilitiesHandler struct {
+	source CapabilitySource
+}
+
+func CreateCapabilitiesHandler(source CapabilitySource) *CapabilitiesHandler {
+	return &CapabilitiesHandler{
+		source: source,
+	}
+}
+
+func (h *CapabilitiesHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	currency := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("currency")))
+
+	available := h.source.AvailableCapabilities(r.Context())
+	names := make([]string, 0, len(available))
+	for name := range available {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	byProvider := make(map[string]providers.ProviderCapabilities)
+	all := make([]providers.ProviderCapabilities, 0, len(names))
+	for _, name := range names {
+		caps := available[name]
+		if currency != "" && !caps.SupportsCurrency(currency) {
+			continue
+		}
+		byProvider[name] = caps
+		all = append(all, caps)
+	}
+
+	writeJSON(w, http.StatusOK, CapabilitiesResponse{
+		Currency:     currency,
+		Capabilities: providers.MergeCapabilities(all...),
+		Providers:    byProvider,
+	})
+}
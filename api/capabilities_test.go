@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type fakeCapabilitySource map[string]providers.ProviderCapabilities
+
+func (f fakeCapabilitySource) AvailableCapabilities(context.Context) map[string]providers.ProviderCapabilities {
+	return f
+}
+
+func capabilitySource() fakeCapabilitySource {
+	return fakeCapabilitySource{
+		"stripe": {
+			SupportsSubscriptions:   true,
+			SupportsMultiCapture:    true,
+			SupportedCurrencies:     []string{"USD", "EUR"},
+			SupportedPaymentMethods: []models.PaymentMethodType{models.PMTypeCard},
+		},
+		"razorpay": {
+			SupportsSubscriptions:   true,
+			SupportedCurrencies:     []string{"INR", "USD"},
+			SupportedPaymentMethods: []models.PaymentMethodType{models.PMTypeCard, models.PMTypeUPI},
+		},
+	}
+}
+
+func getCapabilities(t *testing.T, target string) CapabilitiesResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	CreateCapabilitiesHandler(capabilitySource()).HandleGet(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp CapabilitiesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp
+}
+
+func TestCapabilitiesMergesAvailableProviders(t *testing.T) {
+	resp := getCapabilities(t, "/v1/capabilities")
+
+	if len(resp.Providers) != 2 {
+		t.Fatalf("expected 2 providers, got %d", len(resp.Providers))
+	}
+	if !resp.Capabilities.SupportsSubscriptions || !resp.Capabilities.SupportsMultiCapture {
+		t.Fatalf("expected merged capabilities to union provider flags, got %+v", resp.Capabilities)
+	}
+	if len(resp.Capabilities.SupportedCurrencies) != 3 || len(resp.Capabilities.SupportedPaymentMethods) != 2 {
+		t.Fatalf("expected deduplicated currencies and methods, got %+v", resp.Capabilities)
+	}
+}
+
+func TestCapabilitiesFiltersByCurrency(t *testing.T) {
+	resp := getCapabilities(t, "/v1/capabilities?currency=inr")
+
+	if resp.Currency != "INR" {
+		t.Fatalf("expected normalized currency INR, got %q", resp.Currency)
+	}
+	if _, ok := resp.Providers["razorpay"]; !ok || len(resp.Providers) != 1 {
+		t.Fatalf("expected only razorpay, got %v", resp.Providers)
+	}
+	if resp.Capabilities.SupportsMultiCapture {
+		t.Fatal("expected merged view to exclude providers that do not support the currency")
+	}
+}
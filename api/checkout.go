@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"github.com/malwarebo/conductor/services"
+)
+
+type CheckoutHandler struct {
+	checkoutService *services.CheckoutService
+}
+
+func CreateCheckoutHandler(checkoutService *services.CheckoutService) *CheckoutHandler {
+	return &CheckoutHandler{
+		checkoutService: checkoutService,
+	}
+}
+
+func (h *CheckoutHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCheckoutSessionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if provider := r.Header.Get(providerOverrideHeader); provider != "" {
+		req.Provider = provider
+	}
+
+	session, err := h.checkoutService.CreateCheckoutSession(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCheckoutSession),
+			errors.Is(err, providers.ErrProviderNotConfigured),
+			errors.Is(err, providers.ErrProviderCurrency):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, providers.ErrProviderUnavailable):
+			writeErrorFrom(w, http.StatusServiceUnavailable, err)
+		case errors.Is(err, providers.ErrNotSupported):
+			writeErrorFrom(w, http.StatusUnprocessableEntity, err)
+		default:
+			writeServiceError(w, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.CheckoutSessionResponse{CheckoutSession: session})
+}
+
+func (h *CheckoutHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	session, err := h.checkoutService.GetCheckoutSession(r.Context(), sessionID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, models.ErrCodeCheckoutSessionNotFound, "Checkout session not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.CheckoutSessionResponse{CheckoutSession: session})
+}
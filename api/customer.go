@@ -1,11 +1,14 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/internal/ctxkeys"
 	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/services"
 )
 
@@ -21,27 +24,73 @@ func CreateCustomerHandler(customerService *services.CustomerService) *CustomerH
 
 func (h *CustomerHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateCustomerRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	customer, err := h.customerService.CreateCustomer(r.Context(), &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		switch {
+		case errors.Is(err, providers.ErrProviderNotConfigured):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, providers.ErrProviderUnavailable):
+			writeErrorFrom(w, http.StatusServiceUnavailable, err)
+		default:
+			writeErrorFrom(w, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
 	writeJSON(w, http.StatusCreated, customer)
 }
 
+// HandleList finds the caller's customers, optionally by email or
+// external_id, newest first.
+func (h *CustomerHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.CustomerFilter{
+		TenantID:   tenantID,
+		Email:      query.Get("email"),
+		ExternalID: query.Get("external_id"),
+		Limit:      20,
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if parsed, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = clampLimit(parsed)
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		if o, err := strconv.Atoi(offset); err == nil && o > 0 {
+			filter.Offset = o
+		}
+	}
+
+	customers, err := h.customerService.ListCustomers(r.Context(), filter)
+	if err != nil {
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.CustomerListResponse{
+		Customers: customers,
+		Limit:     filter.Limit,
+		Offset:    filter.Offset,
+	})
+}
+
 func (h *CustomerHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	customerID := vars["id"]
 
 	customer, err := h.customerService.GetCustomer(r.Context(), customerID)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Customer not found"})
+		writeError(w, http.StatusNotFound, models.ErrCodeCustomerNotFound, "Customer not found")
 		return
 	}
 
@@ -53,19 +102,18 @@ func (h *CustomerHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 	customerID := vars["id"]
 
 	var req models.UpdateCustomerRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	if err := h.customerService.UpdateCustomer(r.Context(), customerID, &req); err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	customer, err := h.customerService.GetCustomer(r.Context(), customerID)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Customer updated but failed to retrieve"})
+		writeError(w, http.StatusInternalServerError, models.ErrCodeInternal, "Customer updated but failed to retrieve")
 		return
 	}
 
@@ -77,7 +125,7 @@ func (h *CustomerHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	customerID := vars["id"]
 
 	if err := h.customerService.DeleteCustomer(r.Context(), customerID); err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/malwarebo/conductor/db"
+	"github.com/malwarebo/conductor/models"
+)
+
+// DatabaseFailover moves database writes between the primary and the
+// failover replica.
+type DatabaseFailover interface {
+	Failover(ctx context.Context) error
+	Failback(ctx context.Context) error
+	GetStats() db.PoolStats
+}
+
+type DatabaseHandler struct {
+	pool DatabaseFailover
+}
+
+func CreateDatabaseHandler(pool DatabaseFailover) *DatabaseHandler {
+	return &DatabaseHandler{
+		pool: pool,
+	}
+}
+
+// HandleFailover promotes the failover replica and moves writes to it, the
+// manual override for automatic failover. Scoped API keys need the
+// admin:write scope and JWTs need the admin role.
+func (h *DatabaseHandler) HandleFailover(w http.ResponseWriter, r *http.Request) {
+	h.switchWrites(w, r, h.pool.Failover)
+}
+
+// HandleFailback moves writes back to the restored primary. It is refused
+// while the primary is unreachable or fails its fencing check.
+func (h *DatabaseHandler) HandleFailback(w http.ResponseWriter, r *http.Request) {
+	h.switchWrites(w, r, h.pool.Failback)
+}
+
+func (h *DatabaseHandler) switchWrites(w http.ResponseWriter, r *http.Request, move func(context.Context) error) {
+	if !isAdminWriteRequest(r) {
+		writeError(w, http.StatusForbidden, models.ErrCodeForbidden, "Admin access required")
+		return
+	}
+
+	if err := move(r.Context()); err != nil {
+		switch {
+		case errors.Is(err, db.ErrFailoverNotConfigured), errors.Is(err, db.ErrPrimaryNotFenced):
+			writeErrorFrom(w, http.StatusConflict, err)
+		case errors.Is(err, db.ErrPrimaryUnavailable):
+			writeErrorFrom(w, http.StatusServiceUnavailable, err)
+		default:
+			writeErrorFrom(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"writable_node": h.pool.GetStats().WritableNode,
+	})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/malwarebo/conductor/db"
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+)
+
+type fakeDatabaseFailover struct {
+	writable    string
+	failbackErr error
+}
+
+func (f *fakeDatabaseFailover) Failover(context.Context) error {
+	f.writable = "replica_0"
+	return nil
+}
+
+func (f *fakeDatabaseFailover) Failback(context.Context) error {
+	if f.failbackErr != nil {
+		return f.failbackErr
+	}
+	f.writable = "primary"
+	return nil
+}
+
+func (f *fakeDatabaseFailover) GetStats() db.PoolStats {
+	return db.PoolStats{WritableNode: f.writable}
+}
+
+func TestDatabaseFailoverRequiresAdmin(t *testing.T) {
+	pool := &fakeDatabaseFailover{writable: "primary"}
+	handler := CreateDatabaseHandler(pool)
+
+	rec := httptest.NewRecorder()
+	handler.HandleFailover(rec, httptest.NewRequest(http.MethodPost, "/v1/database/failover", nil))
+	if rec.Code != http.StatusForbidden || pool.writable != "primary" {
+		t.Fatalf("expected a non-admin to be refused, got %d with writes on %s", rec.Code, pool.writable)
+	}
+
+	admin := context.WithValue(context.Background(), ctxkeys.UserRoles, []string{"admin"})
+	rec = httptest.NewRecorder()
+	handler.HandleFailover(rec, httptest.NewRequest(http.MethodPost, "/v1/database/failover", nil).WithContext(admin))
+	if rec.Code != http.StatusOK || pool.writable != "replica_0" {
+		t.Fatalf("expected writes moved to the replica, got %d with writes on %s", rec.Code, pool.writable)
+	}
+}
+
+func TestDatabaseFailbackRefusedUntilPrimaryIsFenced(t *testing.T) {
+	pool := &fakeDatabaseFailover{
+		writable:    "replica_0",
+		failbackErr: fmt.Errorf("%w: it is in recovery", db.ErrPrimaryNotFenced),
+	}
+	admin := context.WithValue(context.Background(), ctxkeys.UserRoles, []string{"admin"})
+
+	rec := httptest.NewRecorder()
+	CreateDatabaseHandler(pool).HandleFailback(rec, httptest.NewRequest(http.MethodPost, "/v1/database/failback", nil).WithContext(admin))
+	if rec.Code != http.StatusConflict || pool.writable != "replica_0" {
+		t.Fatalf("expected 409 with writes left on the replica, got %d with writes on %s", rec.Code, pool.writable)
+	}
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
 	"strings"
 
@@ -63,14 +62,13 @@ func extractDisputeID(path string) string {
 
 func (h *DisputeHandler) handleCreateDispute(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateDisputeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	dispute, err := h.disputeService.CreateDispute(r.Context(), &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -79,18 +77,17 @@ func (h *DisputeHandler) handleCreateDispute(w http.ResponseWriter, r *http.Requ
 
 func (h *DisputeHandler) handleUpdateDispute(w http.ResponseWriter, r *http.Request, disputeID string) {
 	var req models.UpdateDisputeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	dispute, err := h.disputeService.UpdateDispute(r.Context(), disputeID, &req)
 	if err != nil {
 		if err == services.ErrDisputeNotFound {
-			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Dispute not found"})
+			writeError(w, http.StatusNotFound, models.ErrCodeDisputeNotFound, "Dispute not found")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -100,17 +97,17 @@ func (h *DisputeHandler) handleUpdateDispute(w http.ResponseWriter, r *http.Requ
 func (h *DisputeHandler) handleAcceptDispute(w http.ResponseWriter, r *http.Request) {
 	disputeID := extractDisputeID(r.URL.Path)
 	if disputeID == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Dispute ID required"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Dispute ID required")
 		return
 	}
 
 	dispute, err := h.disputeService.AcceptDispute(r.Context(), disputeID)
 	if err != nil {
 		if err == services.ErrDisputeNotFound {
-			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Dispute not found"})
+			writeError(w, http.StatusNotFound, models.ErrCodeDisputeNotFound, "Dispute not found")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -120,23 +117,22 @@ func (h *DisputeHandler) handleAcceptDispute(w http.ResponseWriter, r *http.Requ
 func (h *DisputeHandler) handleContestDispute(w http.ResponseWriter, r *http.Request) {
 	disputeID := extractDisputeID(r.URL.Path)
 	if disputeID == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Dispute ID required"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Dispute ID required")
 		return
 	}
 
 	var evidence map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&evidence); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &evidence) {
 		return
 	}
 
 	dispute, err := h.disputeService.ContestDispute(r.Context(), disputeID, evidence)
 	if err != nil {
 		if err == services.ErrDisputeNotFound {
-			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Dispute not found"})
+			writeError(w, http.StatusNotFound, models.ErrCodeDisputeNotFound, "Dispute not found")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -146,23 +142,22 @@ func (h *DisputeHandler) handleContestDispute(w http.ResponseWriter, r *http.Req
 func (h *DisputeHandler) handleSubmitEvidence(w http.ResponseWriter, r *http.Request) {
 	disputeID := extractDisputeID(r.URL.Path)
 	if disputeID == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Dispute ID required"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Dispute ID required")
 		return
 	}
 
 	var req models.SubmitEvidenceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	evidence, err := h.disputeService.SubmitEvidence(r.Context(), disputeID, &req)
 	if err != nil {
 		if err == services.ErrDisputeNotFound {
-			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Dispute not found"})
+			writeError(w, http.StatusNotFound, models.ErrCodeDisputeNotFound, "Dispute not found")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -173,10 +168,10 @@ func (h *DisputeHandler) handleGetDispute(w http.ResponseWriter, r *http.Request
 	dispute, err := h.disputeService.GetDispute(r.Context(), disputeID)
 	if err != nil {
 		if err == services.ErrDisputeNotFound {
-			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Dispute not found"})
+			writeError(w, http.StatusNotFound, models.ErrCodeDisputeNotFound, "Dispute not found")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -186,13 +181,13 @@ func (h *DisputeHandler) handleGetDispute(w http.ResponseWriter, r *http.Request
 func (h *DisputeHandler) handleListDisputes(w http.ResponseWriter, r *http.Request) {
 	customerID := r.URL.Query().Get("customer_id")
 	if customerID == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "customer_id query parameter is required"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "customer_id query parameter is required")
 		return
 	}
 
 	disputes, err := h.disputeService.ListDisputes(r.Context(), customerID)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -205,7 +200,7 @@ func (h *DisputeHandler) handleListDisputes(w http.ResponseWriter, r *http.Reque
 func (h *DisputeHandler) handleGetStats(w http.ResponseWriter, r *http.Request) {
 	stats, err := h.disputeService.GetStats(r.Context())
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/malwarebo/conductor/internal/routing"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"github.com/malwarebo/conductor/services"
+)
+
+// sentinelCodes maps the errors services and providers return to the code
+// clients see. The first match wins.
+var sentinelCodes = []struct {
+	err  error
+	code models.ErrorCode
+}{
+	{services.ErrPaymentNotFound, models.ErrCodePaymentNotFound},
+	{services.ErrPaymentNotCapturable, models.ErrCodePaymentNotCapturable},
+	{services.ErrPaymentAlreadyCaptured, models.ErrCodePaymentAlreadyCaptured},
+	{services.ErrInvalidCaptureAmount, models.ErrCodeInvalidCaptureAmount},
+	{services.ErrInvalidReversalAmount, models.ErrCodeInvalidRequest},
+	{services.ErrPaymentTenantRequired, models.ErrCodeTenantRequired},
+	{services.ErrAuditTenantRequired, models.ErrCodeTenantRequired},
+	{services.ErrIdempotencyConflict, models.ErrCodeIdempotencyConflict},
+	{services.ErrConcurrentModification, models.ErrCodeConflict},
+	{services.ErrAmountOutOfRange, models.ErrCodeAmountOutOfRange},
+	{services.ErrNoAvailableProvider, models.ErrCodeProviderUnavailable},
+	{services.ErrCapabilityUnsupported, models.ErrCodeCapabilityUnsupported},
+	{services.ErrDisputeNotFound, models.ErrCodeDisputeNotFound},
+	{services.ErrSubscriptionNotFound, models.ErrCodeSubscriptionNotFound},
+	{services.ErrPlanNotFound, models.ErrCodePlanNotFound},
+	{services.ErrPaymentMethodNotFound, models.ErrCodePaymentMethodNotFound},
+	{services.ErrNoDefaultPaymentMethod, models.ErrCodeNoDefaultPaymentMethod},
+	{services.ErrCustomerNotFound, models.ErrCodeCustomerNotFound},
+	{services.ErrInvalidLineItem, models.ErrCodeInvalidRequest},
+	{services.ErrLineItemTotalMismatch, models.ErrCodeInvalidRequest},
+	{services.ErrInvalidFXQuoteRequest, models.ErrCodeInvalidRequest},
+	{services.ErrInsufficientBalance, models.ErrCodeInsufficientBalance},
+	{services.ErrInvalidPayoutChannel, models.ErrCodeInvalidRequest},
+	{services.ErrPayoutAmountOutOfRange, models.ErrCodeAmountOutOfRange},
+	{services.ErrPayoutScheduleNotFound, models.ErrCodePayoutScheduleNotFound},
+	{services.ErrInvalidPayoutSchedule, models.ErrCodeInvalidRequest},
+	{services.ErrPayoutScheduleTenantRequired, models.ErrCodeTenantRequired},
+	{services.ErrCheckoutSessionNotFound, models.ErrCodeCheckoutSessionNotFound},
+	{services.ErrInvalidCheckoutSession, models.ErrCodeInvalidRequest},
+	{services.ErrTenantNotFound, models.ErrCodeTenantNotFound},
+	{services.ErrTenantInactive, models.ErrCodeTenantInactive},
+	{services.ErrInvalidAPIKey, models.ErrCodeUnauthorized},
+	{services.ErrInvalidDefaultCaptureMethod, models.ErrCodeInvalidRequest},
+	{services.ErrTenantKeysNotEnabled, models.ErrCodeConflict},
+	{services.ErrAPIKeyNotFound, models.ErrCodeAPIKeyNotFound},
+	{services.ErrAPIKeyRevoked, models.ErrCodeAPIKeyRevoked},
+	{services.ErrAPIKeyExpired, models.ErrCodeAPIKeyExpired},
+	{services.ErrAPIKeyScopeDenied, models.ErrCodeScopeDenied},
+	{services.ErrWebhookDeliveryNotFound, models.ErrCodeWebhookDeliveryNotFound},
+	{services.ErrWebhookDeliveryTenantRequired, models.ErrCodeTenantRequired},
+	{services.ErrCustomerTenantRequired, models.ErrCodeTenantRequired},
+	{services.ErrWebhookURLNotConfigured, models.ErrCodeWebhookURLNotConfigured},
+	{services.ErrWebhookEndpointNotFound, models.ErrCodeWebhookEndpointNotFound},
+	{services.ErrWebhookEndpointTenantRequired, models.ErrCodeTenantRequired},
+	{services.ErrInvalidWebhookEndpointURL, models.ErrCodeInvalidRequest},
+	{services.ErrWebhookEndpointEventsNeeded, models.ErrCodeInvalidRequest},
+	{services.ErrWebhookEventNotFound, models.ErrCodeWebhookEventNotFound},
+	{services.ErrWebhookEventNotDeadLettered, models.ErrCodeConflict},
+	{services.ErrWebhookEventInProgress, models.ErrCodeConflict},
+	{services.ErrScheduledPaymentNotFound, models.ErrCodeScheduledPaymentNotFound},
+	{services.ErrScheduledPaymentNotCancelable, models.ErrCodeConflict},
+	{services.ErrInvalidScheduledPayment, models.ErrCodeInvalidRequest},
+	{services.ErrThreeDSCallbackMismatch, models.ErrCodeInvalidRequest},
+	{services.ErrFraudBlocked, models.ErrCodeFraudBlocked},
+	{services.ErrFraudCheckUnavailable, models.ErrCodeServiceUnavailable},
+	{providers.ErrProviderUnavailable, models.ErrCodeProviderUnavailable},
+	{providers.ErrProviderNotConfigured, models.ErrCodeProviderNotConfigured},
+	{providers.ErrProviderTimeout, models.ErrCodeProviderTimeout},
+	{providers.ErrProviderCurrency, models.ErrCodeCurrencyNotSupported},
+	{providers.ErrNotSupported, models.ErrCodeCapabilityUnsupported},
+	{providers.ErrPlatformFeesNotSupported, models.ErrCodeCapabilityUnsupported},
+	{providers.ErrSubAccountNotSupported, models.ErrCodeCapabilityUnsupported},
+	{providers.ErrPaymentMethodUnsupported, models.ErrCodeCapabilityUnsupported},
+	{providers.ErrMultiCaptureUnavailable, models.ErrCodeCapabilityUnsupported},
+	{providers.ErrReversalExceedsCapturable, models.ErrCodeInvalidRequest},
+	{providers.ErrPaymentDeclined, models.ErrCodePaymentDeclined},
+	{providers.ErrInvalidStatementDescriptor, models.ErrCodeInvalidRequest},
+	{providers.ErrTaxAddressRequired, models.ErrCodeInvalidRequest},
+	// Last, so a validation error whose only failure has its own code, such
+	// as an amount out of range, keeps that code.
+	{services.ErrValidation, models.ErrCodeInvalidRequest},
+}
+
+var statusCodes = map[int]models.ErrorCode{
+	http.StatusBadRequest:            models.ErrCodeInvalidRequest,
+	http.StatusUnauthorized:          models.ErrCodeUnauthorized,
+	http.StatusForbidden:             models.ErrCodeForbidden,
+	http.StatusNotFound:              models.ErrCodeNotFound,
+	http.StatusConflict:              models.ErrCodeConflict,
+	http.StatusUnprocessableEntity:   models.ErrCodeUnprocessable,
+	http.StatusRequestEntityTooLarge: models.ErrCodeRequestTooLarge,
+	http.StatusTooManyRequests:       models.ErrCodeRateLimited,
+	http.StatusNotImplemented:        models.ErrCodeNotImplemented,
+	http.StatusServiceUnavailable:    models.ErrCodeServiceUnavailable,
+	http.StatusGatewayTimeout:        models.ErrCodeProviderTimeout,
+}
+
+var declineClassifier = routing.NewErrorClassifier()
+
+// errorCodeFor names err by its sentinel, then by the decline reason a
+// provider gave, and finally by the HTTP status it is answered with.
+func errorCodeFor(err error, status int) models.ErrorCode {
+	for _, entry := range sentinelCodes {
+		if errors.Is(err, entry.err) {
+			return entry.code
+		}
+	}
+	if err != nil {
+		for _, provider := range []string{"stripe", "xendit", "razorpay", "airwallex"} {
+			if declineClassifier.ClassifyMessage(provider, err.Error()) == "insufficient_funds" {
+				return models.ErrCodeInsufficientFunds
+			}
+		}
+	}
+	return codeForStatus(status)
+}
+
+func codeForStatus(status int) models.ErrorCode {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	return models.ErrCodeInternal
+}
+
+// writeError answers with the error envelope. An empty message falls back to
+// the code's default text.
+func writeError(w http.ResponseWriter, status int, code models.ErrorCode, message string) {
+	if message == "" {
+		message = code.Message()
+	}
+	writeJSON(w, status, ErrorResponse{Code: code, Message: message})
+}
+
+// WriteError is writeError for callers outside this package, such as
+// middleware that answers before a handler runs.
+func WriteError(w http.ResponseWriter, status int, code models.ErrorCode, message string) {
+	writeError(w, status, code, message)
+}
+
+// writeErrorFrom answers with err's message and the code registered for it,
+// listing each failing field when err is a *services.ValidationError.
+// A provider timeout is always answered with 504, whatever status the
+// handler would use for other failures.
+func writeErrorFrom(w http.ResponseWriter, status int, err error) {
+	if errors.Is(err, providers.ErrProviderTimeout) {
+		status = http.StatusGatewayTimeout
+	}
+	resp := ErrorResponse{Code: errorCodeFor(err, status), Message: err.Error()}
+	var verr *services.ValidationError
+	if errors.As(err, &verr) {
+		resp.Errors = verr.Fields
+	}
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"github.com/malwarebo/conductor/services"
+)
+
+type fakeMissingPaymentStore struct {
+	services.PaymentStore
+}
+
+func (fakeMissingPaymentStore) GetByID(context.Context, string) (*models.Payment, error) {
+	return nil, errors.New("record not found")
+}
+
+func (fakeMissingPaymentStore) Create(context.Context, *models.Payment) error { return nil }
+
+func (fakeMissingPaymentStore) Update(context.Context, *models.Payment) error { return nil }
+
+type fakeChargeProvider struct {
+	providers.PaymentProvider
+	available bool
+	chargeErr error
+}
+
+func (f fakeChargeProvider) Name() string                     { return "fake" }
+func (f fakeChargeProvider) IsAvailable(context.Context) bool { return f.available }
+func (f fakeChargeProvider) Capabilities() providers.ProviderCapabilities {
+	return providers.ProviderCapabilities{}
+}
+func (f fakeChargeProvider) Charge(context.Context, *models.ChargeRequest) (*models.ChargeResponse, error) {
+	return nil, f.chargeErr
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) ErrorResponse {
+	t.Helper()
+	var body ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	return body
+}
+
+func TestErrorResponsesCarryStableCodes(t *testing.T) {
+	const chargeBody = `{"customer_id":"cus_1","amount":1000,"currency":"USD","payment_method":"pm_1"}`
+
+	tests := []struct {
+		name     string
+		provider fakeChargeProvider
+		method   string
+		path     string
+		body     string
+		handle   func(*PaymentHandler) http.HandlerFunc
+		status   int
+		code     models.ErrorCode
+	}{
+		{
+			name:   "malformed charge body",
+			method: http.MethodPost, path: "/v1/charges", body: `{`,
+			handle: func(h *PaymentHandler) http.HandlerFunc { return h.HandleCharge },
+			status: http.StatusBadRequest, code: models.ErrCodeInvalidRequest,
+		},
+		{
+			name:   "no provider available",
+			method: http.MethodPost, path: "/v1/charges", body: chargeBody,
+			handle: func(h *PaymentHandler) http.HandlerFunc { return h.HandleCharge },
+			status: http.StatusServiceUnavailable, code: models.ErrCodeProviderUnavailable,
+		},
+		{
+			name:     "insufficient funds decline",
+			provider: fakeChargeProvider{available: true, chargeErr: errors.New("card declined: insufficient_funds")},
+			method:   http.MethodPost, path: "/v1/charges", body: chargeBody,
+			handle: func(h *PaymentHandler) http.HandlerFunc { return h.HandleCharge },
+			status: http.StatusPaymentRequired, code: models.ErrCodeInsufficientFunds,
+		},
+		{
+			name:   "unknown payment",
+			method: http.MethodGet, path: "/v1/payments/pay_missing",
+			handle: func(h *PaymentHandler) http.HandlerFunc { return h.HandleGetPayment },
+			status: http.StatusNotFound, code: models.ErrCodePaymentNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CreatePaymentHandler(services.CreatePaymentService(fakeMissingPaymentStore{}, tt.provider))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "pay_missing"})
+			tt.handle(handler)(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			body := decodeError(t, rec)
+			if body.Code != tt.code {
+				t.Fatalf("expected code %q, got %q", tt.code, body.Code)
+			}
+			if body.Message == "" {
+				t.Fatal("expected a message alongside the code")
+			}
+		})
+	}
+}
+
+func TestErrorCodeForUnknownErrorFallsBackToStatus(t *testing.T) {
+	if code := errorCodeFor(errors.New("boom"), http.StatusConflict); code != models.ErrCodeConflict {
+		t.Fatalf("expected %q, got %q", models.ErrCodeConflict, code)
+	}
+	if code := errorCodeFor(errors.New("boom"), http.StatusTeapot); code != models.ErrCodeInternal {
+		t.Fatalf("expected %q, got %q", models.ErrCodeInternal, code)
+	}
+}
+
+func TestValidationErrorListsEveryField(t *testing.T) {
+	handler := CreatePaymentHandler(services.CreatePaymentService(fakeMissingPaymentStore{}, fakeChargeProvider{available: true}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/charges", strings.NewReader(`{"amount":0,"application_fee_amount":-1}`))
+	handler.HandleCharge(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := decodeError(t, rec)
+	if body.Code != models.ErrCodeInvalidRequest {
+		t.Fatalf("expected code %q, got %q", models.ErrCodeInvalidRequest, body.Code)
+	}
+	want := []models.FieldError{
+		{Field: "amount", Code: services.FieldCodeMustBePositive},
+		{Field: "currency", Code: services.FieldCodeRequired},
+		{Field: "payment_method", Code: services.FieldCodeRequired},
+		{Field: "application_fee_amount", Code: services.FieldCodeMustNotBeNegative},
+	}
+	if len(body.Errors) != len(want) {
+		t.Fatalf("expected %d field errors, got %+v", len(want), body.Errors)
+	}
+	for i, w := range want {
+		if got := body.Errors[i]; got.Field != w.Field || got.Code != w.Code || got.Message == "" {
+			t.Fatalf("field error %d: expected %s/%s with a message, got %+v", i, w.Field, w.Code, got)
+		}
+	}
+}
@@ -22,8 +22,7 @@ func CreateFraudHandler(service services.FraudService) *FraudHandler {
 
 func (h *FraudHandler) AnalyzeTransaction(w http.ResponseWriter, r *http.Request) {
 	var request models.FraudAnalysisRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSON(w, r, &request) {
 		return
 	}
 
@@ -1,8 +1,11 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,6 +17,115 @@ type HealthResponse struct {
 
 var startTime = time.Now()
 
+type ComponentHealth struct {
+	Status   string        `json:"status"`
+	Critical bool          `json:"critical"`
+	Latency  string        `json:"latency"`
+	Mode     string        `json:"mode,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Startup  *StartupCheck `json:"startup,omitempty"`
+}
+
+// StartupCheck is the outcome of a component's check when the server
+// started, such as a provider's credential validation.
+type StartupCheck struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type DeepHealthResponse struct {
+	Status     string                     `json:"status"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	Uptime     string                     `json:"uptime"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+type DependencyCheck struct {
+	Name     string
+	Critical bool
+	Mode     string
+	Check    func(ctx context.Context) error
+	// Startup, when set, is reported alongside the live check's result.
+	Startup *StartupCheck
+}
+
+type HealthHandler struct {
+	checks  []DependencyCheck
+	timeout time.Duration
+}
+
+func CreateHealthHandler(timeout time.Duration, checks ...DependencyCheck) *HealthHandler {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &HealthHandler{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+func (h *HealthHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("deep") != "true" {
+		CreateHealthCheckHandler(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	components := make(map[string]ComponentHealth, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check DependencyCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := check.Check(ctx)
+
+			component := ComponentHealth{
+				Status:   "healthy",
+				Critical: check.Critical,
+				Latency:  time.Since(start).String(),
+				Mode:     check.Mode,
+				Startup:  check.Startup,
+			}
+			if err != nil {
+				component.Status = "unhealthy"
+				component.Error = err.Error()
+			}
+
+			mu.Lock()
+			components[check.Name] = component
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	status := "healthy"
+	httpStatus := http.StatusOK
+	for _, component := range components {
+		if component.Status == "healthy" {
+			continue
+		}
+		if component.Critical {
+			status = "unhealthy"
+			httpStatus = http.StatusServiceUnavailable
+			break
+		}
+		status = "degraded"
+	}
+
+	writeJSON(w, httpStatus, DeepHealthResponse{
+		Status:     status,
+		Timestamp:  time.Now(),
+		Uptime:     time.Since(startTime).String(),
+		Components: components,
+	})
+}
+
 func CreateHealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	uptime := time.Since(startTime)
 
@@ -30,3 +142,38 @@ func CreateHealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type ReadinessHandler struct {
+	ready atomic.Bool
+}
+
+func CreateReadinessHandler() *ReadinessHandler {
+	h := &ReadinessHandler{}
+	h.ready.Store(true)
+	return h
+}
+
+func (h *ReadinessHandler) SetNotReady() {
+	h.ready.Store(false)
+}
+
+func (h *ReadinessHandler) IsReady() bool {
+	return h.ready.Load()
+}
+
+func (h *ReadinessHandler) HandleReady(w http.ResponseWriter, r *http.Request) {
+	if !h.IsReady() {
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{
+			Status:    "shutting_down",
+			Timestamp: time.Now(),
+			Uptime:    time.Since(startTime).String(),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, HealthResponse{
+		Status:    "ready",
+		Timestamp: time.Now(),
+		Uptime:    time.Since(startTime).String(),
+	})
+}
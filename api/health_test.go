@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadinessReturns503AfterShutdownInitiated(t *testing.T) {
+	h := CreateReadinessHandler()
+
+	rec := httptest.NewRecorder()
+	h.HandleReady(rec, httptest.NewRequest(http.MethodGet, "/v1/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 before shutdown, got %d", rec.Code)
+	}
+
+	h.SetNotReady()
+
+	rec = httptest.NewRecorder()
+	h.HandleReady(rec, httptest.NewRequest(http.MethodGet, "/v1/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 after shutdown initiated, got %d", rec.Code)
+	}
+}
+
+func deepHealthChecks(dbErr error) []DependencyCheck {
+	return []DependencyCheck{
+		{Name: "database", Critical: true, Check: func(ctx context.Context) error { return dbErr }},
+		{Name: "redis", Check: func(ctx context.Context) error { return nil }},
+		{Name: "provider:stripe", Check: func(ctx context.Context) error { return nil }},
+	}
+}
+
+func TestDeepHealthCheckAllHealthy(t *testing.T) {
+	h := CreateHealthHandler(time.Second, deepHealthChecks(nil)...)
+
+	rec := httptest.NewRecorder()
+	h.HandleHealth(rec, httptest.NewRequest(http.MethodGet, "/v1/health?deep=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp DeepHealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Status != "healthy" {
+		t.Fatalf("expected healthy, got %s", resp.Status)
+	}
+	if len(resp.Components) != 3 {
+		t.Fatalf("expected 3 components, got %d", len(resp.Components))
+	}
+	for name, component := range resp.Components {
+		if component.Status != "healthy" {
+			t.Fatalf("expected %s to be healthy, got %s", name, component.Status)
+		}
+	}
+}
+
+func TestDeepHealthCheckDatabaseDown(t *testing.T) {
+	h := CreateHealthHandler(time.Second, deepHealthChecks(errors.New("connection refused"))...)
+
+	rec := httptest.NewRecorder()
+	h.HandleHealth(rec, httptest.NewRequest(http.MethodGet, "/v1/health?deep=true", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+
+	var resp DeepHealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Status != "unhealthy" {
+		t.Fatalf("expected unhealthy, got %s", resp.Status)
+	}
+	if db := resp.Components["database"]; db.Status != "unhealthy" || db.Error != "connection refused" {
+		t.Fatalf("unexpected database component: %+v", db)
+	}
+	if resp.Components["redis"].Status != "healthy" {
+		t.Fatalf("expected redis to be healthy, got %s", resp.Components["redis"].Status)
+	}
+}
+
+func TestShallowHealthCheckSkipsDependencies(t *testing.T) {
+	called := false
+	h := CreateHealthHandler(time.Second, DependencyCheck{
+		Name:     "database",
+		Critical: true,
+		Check: func(ctx context.Context) error {
+			called = true
+			return errors.New("down")
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	h.HandleHealth(rec, httptest.NewRequest(http.MethodGet, "/v1/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if called {
+		t.Fatal("shallow health check should not run dependency checks")
+	}
+}
+
+func TestDeepHealthCheckReportsStartupCredentialFailure(t *testing.T) {
+	h := CreateHealthHandler(time.Second, DependencyCheck{
+		Name:    "provider:xendit",
+		Check:   func(ctx context.Context) error { return nil },
+		Startup: &StartupCheck{Status: "unhealthy", Error: "provider rejected the configured credentials", CheckedAt: time.Now()},
+	})
+
+	rec := httptest.NewRecorder()
+	h.HandleHealth(rec, httptest.NewRequest(http.MethodGet, "/v1/health?deep=true", nil))
+
+	var resp DeepHealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	xendit := resp.Components["provider:xendit"]
+	if xendit.Startup == nil || xendit.Startup.Status != "unhealthy" || xendit.Startup.Error == "" {
+		t.Fatalf("expected the failed startup check to be reported, got %+v", xendit.Startup)
+	}
+}
@@ -1,12 +1,13 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/services"
 )
 
@@ -22,14 +23,21 @@ func CreateInvoiceHandler(invoiceService *services.InvoiceService) *InvoiceHandl
 
 func (h *InvoiceHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateInvoiceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	invoice, err := h.invoiceService.CreateInvoice(r.Context(), &req)
+	if errors.Is(err, services.ErrInvalidLineItem) || errors.Is(err, services.ErrLineItemTotalMismatch) || errors.Is(err, providers.ErrTaxAddressRequired) {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, providers.ErrNotSupported) {
+		writeErrorFrom(w, http.StatusUnprocessableEntity, err)
+		return
+	}
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -41,8 +49,12 @@ func (h *InvoiceHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 	invoiceID := vars["id"]
 
 	invoice, err := h.invoiceService.GetInvoice(r.Context(), invoiceID)
+	if errors.Is(err, services.ErrCapabilityUnsupported) {
+		writeServiceError(w, err)
+		return
+	}
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Invoice not found"})
+		writeError(w, http.StatusNotFound, models.ErrCodeInvoiceNotFound, "Invoice not found")
 		return
 	}
 
@@ -70,7 +82,7 @@ func (h *InvoiceHandler) HandleList(w http.ResponseWriter, r *http.Request) {
 
 	invoices, err := h.invoiceService.ListInvoices(r.Context(), req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -86,7 +98,7 @@ func (h *InvoiceHandler) HandleCancel(w http.ResponseWriter, r *http.Request) {
 
 	invoice, err := h.invoiceService.CancelInvoice(r.Context(), invoiceID)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -1,13 +1,20 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/internal/metrics"
+	"github.com/malwarebo/conductor/internal/pagination"
 	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/services"
 )
 
@@ -15,6 +22,7 @@ type PaymentHandler struct {
 	paymentService    *services.PaymentService
 	webhookService    *services.WebhookService
 	webhookValidators map[string]WebhookValidator
+	webhookSecrets    WebhookSecretResolver
 }
 
 func CreatePaymentHandler(paymentService *services.PaymentService) *PaymentHandler {
@@ -31,6 +39,14 @@ func CreatePaymentHandlerWithWebhook(paymentService *services.PaymentService, we
 	}
 }
 
+func (h *PaymentHandler) SetWebhookSecretResolver(resolver WebhookSecretResolver) {
+	h.webhookSecrets = resolver
+}
+
+// providerOverrideHeader pins a charge to a named provider, bypassing
+// routing. It is meant for QA and support reproducing provider behaviour.
+const providerOverrideHeader = "X-Provider-Override"
+
 func (h *PaymentHandler) HandleCharge(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -38,22 +54,68 @@ func (h *PaymentHandler) HandleCharge(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req models.ChargeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	if idempotencyKey := r.Header.Get("Idempotency-Key"); idempotencyKey != "" {
 		req.IdempotencyKey = idempotencyKey
 	}
+	if provider := r.Header.Get(providerOverrideHeader); provider != "" {
+		req.Provider = provider
+	}
 
 	resp, err := h.paymentService.CreateCharge(r.Context(), &req)
 	if err != nil {
 		if err == services.ErrNoAvailableProvider {
-			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "No payment provider available"})
+			writeError(w, http.StatusServiceUnavailable, models.ErrCodeProviderUnavailable, "No payment provider available")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		if errors.Is(err, providers.ErrProviderUnavailable) || errors.Is(err, services.ErrFraudCheckUnavailable) {
+			writeErrorFrom(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		if errors.Is(err, providers.ErrProviderNotConfigured) || errors.Is(err, providers.ErrProviderCurrency) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, providers.ErrPlatformFeesNotSupported) || errors.Is(err, providers.ErrSubAccountNotSupported) || errors.Is(err, providers.ErrPaymentMethodUnsupported) || errors.Is(err, providers.ErrInvalidStatementDescriptor) || errors.Is(err, services.ErrAmountOutOfRange) || errors.Is(err, services.ErrNoDefaultPaymentMethod) || errors.Is(err, providers.ErrTaxAddressRequired) || errors.Is(err, services.ErrValidation) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, providers.ErrNotSupported) || errors.Is(err, services.ErrCapabilityUnsupported) {
+			writeErrorFrom(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			writeErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		switch errorCodeFor(err, http.StatusInternalServerError) {
+		case models.ErrCodePaymentDeclined, models.ErrCodeInsufficientFunds, models.ErrCodeFraudBlocked:
+			writeErrorFrom(w, http.StatusPaymentRequired, err)
+		default:
+			writeErrorFrom(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *PaymentHandler) HandleChargeBatch(w http.ResponseWriter, r *http.Request) {
+	var req models.BatchChargeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	resp, err := h.paymentService.CreateChargeBatch(r.Context(), req.Charges)
+	if err != nil {
+		if errors.Is(err, services.ErrBatchEmpty) || errors.Is(err, services.ErrBatchTooLarge) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -67,8 +129,7 @@ func (h *PaymentHandler) HandleAuthorize(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req models.AuthorizeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -79,10 +140,18 @@ func (h *PaymentHandler) HandleAuthorize(w http.ResponseWriter, r *http.Request)
 	resp, err := h.paymentService.Authorize(r.Context(), &req)
 	if err != nil {
 		if err == services.ErrNoAvailableProvider {
-			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "No payment provider available"})
+			writeError(w, http.StatusServiceUnavailable, models.ErrCodeProviderUnavailable, "No payment provider available")
+			return
+		}
+		if errors.Is(err, services.ErrAmountOutOfRange) || errors.Is(err, services.ErrValidation) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, services.ErrCapabilityUnsupported) {
+			writeErrorFrom(w, http.StatusUnprocessableEntity, err)
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -99,8 +168,7 @@ func (h *PaymentHandler) HandleCapture(w http.ResponseWriter, r *http.Request) {
 	paymentID := vars["id"]
 
 	var req models.CaptureRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeOptionalJSON(w, r, &req) {
 		return
 	}
 	req.PaymentID = paymentID
@@ -109,15 +177,23 @@ func (h *PaymentHandler) HandleCapture(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch err {
 		case services.ErrPaymentNotFound:
-			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Payment not found"})
+			writeError(w, http.StatusNotFound, models.ErrCodePaymentNotFound, "Payment not found")
 		case services.ErrPaymentNotCapturable:
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payment is not in capturable state"})
+			writeError(w, http.StatusBadRequest, models.ErrCodePaymentNotCapturable, "Payment is not in capturable state")
 		case services.ErrPaymentAlreadyCaptured:
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Payment already captured"})
+			writeError(w, http.StatusBadRequest, models.ErrCodePaymentAlreadyCaptured, "Payment already captured")
 		case services.ErrInvalidCaptureAmount:
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid capture amount"})
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidCaptureAmount, "Invalid capture amount")
 		default:
-			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+			if errors.Is(err, providers.ErrMultiCaptureUnavailable) {
+				writeErrorFrom(w, http.StatusUnprocessableEntity, err)
+				return
+			}
+			if errors.Is(err, services.ErrConcurrentModification) {
+				writeErrorFrom(w, http.StatusConflict, err)
+				return
+			}
+			writeErrorFrom(w, http.StatusInternalServerError, err)
 		}
 		return
 	}
@@ -135,8 +211,7 @@ func (h *PaymentHandler) HandleVoid(w http.ResponseWriter, r *http.Request) {
 	paymentID := vars["id"]
 
 	var req models.VoidRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeOptionalJSON(w, r, &req) {
 		return
 	}
 	req.PaymentID = paymentID
@@ -144,10 +219,43 @@ func (h *PaymentHandler) HandleVoid(w http.ResponseWriter, r *http.Request) {
 	resp, err := h.paymentService.Void(r.Context(), &req)
 	if err != nil {
 		if err == services.ErrPaymentNotFound {
-			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Payment not found"})
+			writeError(w, http.StatusNotFound, models.ErrCodePaymentNotFound, "Payment not found")
+			return
+		}
+		if errors.Is(err, services.ErrConcurrentModification) {
+			writeErrorFrom(w, http.StatusConflict, err)
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleReverse releases part of an authorized payment's hold; the rest
+// stays capturable.
+func (h *PaymentHandler) HandleReverse(w http.ResponseWriter, r *http.Request) {
+	var req models.ReverseAuthorizationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.PaymentID = mux.Vars(r)["id"]
+
+	resp, err := h.paymentService.ReverseAuthorization(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPaymentNotFound):
+			writeError(w, http.StatusNotFound, models.ErrCodePaymentNotFound, "Payment not found")
+		case errors.Is(err, services.ErrPaymentNotCapturable):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrInvalidReversalAmount), errors.Is(err, providers.ErrReversalExceedsCapturable):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrConcurrentModification):
+			writeErrorFrom(w, http.StatusConflict, err)
+		default:
+			writeServiceError(w, err)
+		}
 		return
 	}
 
@@ -168,10 +276,48 @@ func (h *PaymentHandler) HandleConfirm3DS(w http.ResponseWriter, r *http.Request
 	resp, err := h.paymentService.Confirm3DS(r.Context(), req)
 	if err != nil {
 		if err == services.ErrPaymentNotFound {
-			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Payment not found"})
+			writeError(w, http.StatusNotFound, models.ErrCodePaymentNotFound, "Payment not found")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Handle3DSCallback finalizes a payment once the customer is back from a
+// 3DS challenge. The merchant forwards the provider's return parameters,
+// either as the query string or as params in the body.
+func (h *PaymentHandler) Handle3DSCallback(w http.ResponseWriter, r *http.Request) {
+	var req models.ThreeDSCallbackRequest
+	if !decodeOptionalJSON(w, r, &req) {
+		return
+	}
+	req.PaymentID = mux.Vars(r)["id"]
+	for key, values := range r.URL.Query() {
+		if req.Params == nil {
+			req.Params = map[string]string{}
+		}
+		if _, ok := req.Params[key]; !ok && len(values) > 0 {
+			req.Params[key] = values[0]
+		}
+	}
+
+	resp, err := h.paymentService.Complete3DSCallback(r.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPaymentNotFound):
+			writeErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, services.ErrThreeDSCallbackMismatch):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrConcurrentModification):
+			writeErrorFrom(w, http.StatusConflict, err)
+		case errors.Is(err, providers.ErrProviderUnavailable):
+			writeErrorFrom(w, http.StatusServiceUnavailable, err)
+		default:
+			writeErrorFrom(w, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
@@ -184,23 +330,106 @@ func (h *PaymentHandler) HandleGetPayment(w http.ResponseWriter, r *http.Request
 
 	payment, err := h.paymentService.GetPayment(r.Context(), paymentID)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Payment not found"})
+		writeError(w, http.StatusNotFound, models.ErrCodePaymentNotFound, "Payment not found")
 		return
 	}
 
 	writeJSON(w, http.StatusOK, payment)
 }
 
+// HandleGetProviderResponses returns the raw provider responses captured for
+// a payment. It is an internal debugging endpoint: scoped API keys need the
+// admin:read scope and JWTs need the admin role.
+func (h *PaymentHandler) HandleGetProviderResponses(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		writeError(w, http.StatusForbidden, models.ErrCodeForbidden, "Admin access required")
+		return
+	}
+
+	resp, err := h.paymentService.ListProviderResponses(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, services.ErrPaymentNotFound) {
+			writeError(w, http.StatusNotFound, models.ErrCodePaymentNotFound, "Payment not found")
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// HandleListPayments pages through the tenant's payments newest first,
+// optionally narrowed to a single metadata pair given as metadata[key]=value.
+// Pass the next_cursor from one response as ?cursor= to fetch the following
+// page.
+func (h *PaymentHandler) HandleListPayments(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.PaymentFilter{TenantID: tenantID, Limit: 20}
+	if limit := query.Get("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = clampLimit(l)
+		}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		decoded, err := pagination.Decode(cursor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid cursor")
+			return
+		}
+		filter.Cursor = decoded
+	}
+
+	var metadataKey, metadataValue string
+	metadataFilters := 0
+	for param, values := range query {
+		key, found := strings.CutPrefix(param, "metadata[")
+		if !found || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		metadataFilters++
+		metadataKey = strings.TrimSuffix(key, "]")
+		metadataValue = values[0]
+	}
+	if metadataFilters > 1 {
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Only one metadata filter is supported")
+		return
+	}
+
+	var page *pagination.Page[*models.Payment]
+	var err error
+	if metadataFilters == 1 {
+		page, err = h.paymentService.ListPaymentsByMetadata(r.Context(), metadataKey, metadataValue, filter)
+	} else {
+		page, err = h.paymentService.ListTenantPayments(r.Context(), filter)
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrMetadataKeyRequired) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
 func (h *PaymentHandler) HandleCreatePaymentSession(w http.ResponseWriter, r *http.Request) {
 	var req models.CreatePaymentSessionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	session, err := h.paymentService.CreatePaymentSession(r.Context(), &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -213,7 +442,7 @@ func (h *PaymentHandler) HandleGetPaymentSession(w http.ResponseWriter, r *http.
 
 	session, err := h.paymentService.GetPaymentSession(r.Context(), sessionID)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Payment session not found"})
+		writeError(w, http.StatusNotFound, models.ErrCodePaymentSessionNotFound, "Payment session not found")
 		return
 	}
 
@@ -225,14 +454,13 @@ func (h *PaymentHandler) HandleUpdatePaymentSession(w http.ResponseWriter, r *ht
 	sessionID := vars["id"]
 
 	var req models.UpdatePaymentSessionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	session, err := h.paymentService.UpdatePaymentSession(r.Context(), sessionID, &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -244,14 +472,13 @@ func (h *PaymentHandler) HandleConfirmPaymentSession(w http.ResponseWriter, r *h
 	sessionID := vars["id"]
 
 	var req models.ConfirmPaymentSessionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeOptionalJSON(w, r, &req) {
 		return
 	}
 
 	session, err := h.paymentService.ConfirmPaymentSession(r.Context(), sessionID, &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -265,14 +492,13 @@ func (h *PaymentHandler) HandleCapturePaymentSession(w http.ResponseWriter, r *h
 	var req struct {
 		Amount *int64 `json:"amount,omitempty"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeOptionalJSON(w, r, &req) {
 		return
 	}
 
 	session, err := h.paymentService.CapturePaymentSession(r.Context(), sessionID, req.Amount)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -285,7 +511,7 @@ func (h *PaymentHandler) HandleCancelPaymentSession(w http.ResponseWriter, r *ht
 
 	session, err := h.paymentService.CancelPaymentSession(r.Context(), sessionID)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -306,7 +532,7 @@ func (h *PaymentHandler) HandleListPaymentSessions(w http.ResponseWriter, r *htt
 
 	sessions, err := h.paymentService.ListPaymentSessions(r.Context(), req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -322,18 +548,25 @@ func (h *PaymentHandler) HandleRefund(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req models.RefundRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
+	if idempotencyKey := r.Header.Get("Idempotency-Key"); idempotencyKey != "" {
+		req.IdempotencyKey = idempotencyKey
+	}
+
 	resp, err := h.paymentService.CreateRefund(r.Context(), &req)
 	if err != nil {
 		if err == services.ErrNoAvailableProvider {
-			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "No payment provider available"})
+			writeError(w, http.StatusServiceUnavailable, models.ErrCodeProviderUnavailable, "No payment provider available")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		if errors.Is(err, services.ErrValidation) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -348,21 +581,28 @@ func (h *PaymentHandler) HandleStripeWebhook(w http.ResponseWriter, r *http.Requ
 
 	payload, err := io.ReadAll(r.Body)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Failed to read request body"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Failed to read request body")
 		return
 	}
 
-	if validator, ok := h.webhookValidators["stripe"]; ok {
+	ctx, validator, err := h.resolveWebhook(r, "stripe")
+	if err != nil {
+		metrics.WebhookEventsTotal.Inc("stripe", metrics.WebhookOutcomeRejected)
+		writeWebhookTenantError(w, err)
+		return
+	}
+	if validator != nil {
 		signature := r.Header.Get("Stripe-Signature")
 		if err := validator.ValidateWebhookSignature(payload, signature); err != nil {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Invalid webhook signature"})
+			metrics.WebhookEventsTotal.Inc("stripe", metrics.WebhookOutcomeRejected)
+			writeError(w, http.StatusUnauthorized, models.ErrCodeInvalidWebhookSignature, "Invalid webhook signature")
 			return
 		}
 	}
 
 	var event map[string]interface{}
 	if err := json.Unmarshal(payload, &event); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid JSON payload"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid JSON payload")
 		return
 	}
 
@@ -370,8 +610,8 @@ func (h *PaymentHandler) HandleStripeWebhook(w http.ResponseWriter, r *http.Requ
 	eventType, _ := event["type"].(string)
 
 	if h.webhookService != nil {
-		if err := h.webhookService.ProcessInboundWebhook(r.Context(), "stripe", eventID, eventType, payload); err != nil {
-			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to process webhook"})
+		if err := h.webhookService.ProcessInboundWebhook(ctx, "stripe", eventID, eventType, payload); err != nil {
+			writeError(w, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to process webhook")
 			return
 		}
 	}
@@ -391,21 +631,28 @@ func (h *PaymentHandler) HandleXenditWebhook(w http.ResponseWriter, r *http.Requ
 
 	payload, err := io.ReadAll(r.Body)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Failed to read request body"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Failed to read request body")
 		return
 	}
 
-	if validator, ok := h.webhookValidators["xendit"]; ok {
+	ctx, validator, err := h.resolveWebhook(r, "xendit")
+	if err != nil {
+		metrics.WebhookEventsTotal.Inc("xendit", metrics.WebhookOutcomeRejected)
+		writeWebhookTenantError(w, err)
+		return
+	}
+	if validator != nil {
 		signature := r.Header.Get("x-callback-token")
 		if err := validator.ValidateWebhookSignature(payload, signature); err != nil {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Invalid webhook signature"})
+			metrics.WebhookEventsTotal.Inc("xendit", metrics.WebhookOutcomeRejected)
+			writeError(w, http.StatusUnauthorized, models.ErrCodeInvalidWebhookSignature, "Invalid webhook signature")
 			return
 		}
 	}
 
 	var event map[string]interface{}
 	if err := json.Unmarshal(payload, &event); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid JSON payload"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid JSON payload")
 		return
 	}
 
@@ -413,8 +660,8 @@ func (h *PaymentHandler) HandleXenditWebhook(w http.ResponseWriter, r *http.Requ
 	eventType, _ := event["event"].(string)
 
 	if h.webhookService != nil {
-		if err := h.webhookService.ProcessInboundWebhook(r.Context(), "xendit", eventID, eventType, payload); err != nil {
-			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to process webhook"})
+		if err := h.webhookService.ProcessInboundWebhook(ctx, "xendit", eventID, eventType, payload); err != nil {
+			writeError(w, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to process webhook")
 			return
 		}
 	}
@@ -434,27 +681,34 @@ func (h *PaymentHandler) HandleRazorpayWebhook(w http.ResponseWriter, r *http.Re
 
 	payload, err := io.ReadAll(r.Body)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Failed to read request body"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Failed to read request body")
 		return
 	}
 
-	if validator, ok := h.webhookValidators["razorpay"]; ok {
+	ctx, validator, err := h.resolveWebhook(r, "razorpay")
+	if err != nil {
+		metrics.WebhookEventsTotal.Inc("razorpay", metrics.WebhookOutcomeRejected)
+		writeWebhookTenantError(w, err)
+		return
+	}
+	if validator != nil {
 		signature := r.Header.Get("X-Razorpay-Signature")
 		if err := validator.ValidateWebhookSignature(payload, signature); err != nil {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Invalid webhook signature"})
+			metrics.WebhookEventsTotal.Inc("razorpay", metrics.WebhookOutcomeRejected)
+			writeError(w, http.StatusUnauthorized, models.ErrCodeInvalidWebhookSignature, "Invalid webhook signature")
 			return
 		}
 	}
 
 	var event map[string]interface{}
 	if err := json.Unmarshal(payload, &event); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid JSON payload"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid JSON payload")
 		return
 	}
 
 	eventType, _ := event["event"].(string)
-	eventID := ""
-	if payloadData, ok := event["payload"].(map[string]interface{}); ok {
+	eventID := r.Header.Get("X-Razorpay-Event-Id")
+	if payloadData, ok := event["payload"].(map[string]interface{}); ok && eventID == "" {
 		if payment, ok := payloadData["payment"].(map[string]interface{}); ok {
 			if entity, ok := payment["entity"].(map[string]interface{}); ok {
 				eventID, _ = entity["id"].(string)
@@ -471,8 +725,8 @@ func (h *PaymentHandler) HandleRazorpayWebhook(w http.ResponseWriter, r *http.Re
 	}
 
 	if h.webhookService != nil {
-		if err := h.webhookService.ProcessInboundWebhook(r.Context(), "razorpay", eventID, eventType, payload); err != nil {
-			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to process webhook"})
+		if err := h.webhookService.ProcessInboundWebhook(ctx, "razorpay", eventID, eventType, payload); err != nil {
+			writeError(w, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to process webhook")
 			return
 		}
 	}
@@ -492,21 +746,33 @@ func (h *PaymentHandler) HandleAirwallexWebhook(w http.ResponseWriter, r *http.R
 
 	payload, err := io.ReadAll(r.Body)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Failed to read request body"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Failed to read request body")
 		return
 	}
 
-	if validator, ok := h.webhookValidators["airwallex"]; ok {
+	ctx, validator, err := h.resolveWebhook(r, "airwallex")
+	if err != nil {
+		metrics.WebhookEventsTotal.Inc("airwallex", metrics.WebhookOutcomeRejected)
+		writeWebhookTenantError(w, err)
+		return
+	}
+	if validator != nil {
 		signature := r.Header.Get("x-signature")
-		if err := validator.ValidateWebhookSignature(payload, signature); err != nil {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "Invalid webhook signature"})
+		if timestamped, ok := validator.(TimestampedWebhookValidator); ok {
+			err = timestamped.ValidateTimestampedWebhookSignature(payload, signature, r.Header.Get("x-timestamp"))
+		} else {
+			err = validator.ValidateWebhookSignature(payload, signature)
+		}
+		if err != nil {
+			metrics.WebhookEventsTotal.Inc("airwallex", metrics.WebhookOutcomeRejected)
+			writeError(w, http.StatusUnauthorized, models.ErrCodeInvalidWebhookSignature, "Invalid webhook signature")
 			return
 		}
 	}
 
 	var event map[string]interface{}
 	if err := json.Unmarshal(payload, &event); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid JSON payload"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid JSON payload")
 		return
 	}
 
@@ -514,8 +780,8 @@ func (h *PaymentHandler) HandleAirwallexWebhook(w http.ResponseWriter, r *http.R
 	eventID, _ := event["id"].(string)
 
 	if h.webhookService != nil {
-		if err := h.webhookService.ProcessInboundWebhook(r.Context(), "airwallex", eventID, eventType, payload); err != nil {
-			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to process webhook"})
+		if err := h.webhookService.ProcessInboundWebhook(ctx, "airwallex", eventID, eventType, payload); err != nil {
+			writeError(w, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to process webhook")
 			return
 		}
 	}
@@ -526,3 +792,41 @@ func (h *PaymentHandler) HandleAirwallexWebhook(w http.ResponseWriter, r *http.R
 		"event_type": eventType,
 	})
 }
+
+// resolveWebhook picks the validator for an inbound webhook. On the
+// /v1/webhooks/{provider}/{tenantId} routes the tenant's own signing secret is
+// used when it has one, and the returned context carries the tenant ID;
+// otherwise the globally configured validator applies.
+func (h *PaymentHandler) resolveWebhook(r *http.Request, provider string) (context.Context, WebhookValidator, error) {
+	ctx := r.Context()
+	tenantID := mux.Vars(r)["tenantId"]
+	if tenantID == "" || h.webhookSecrets == nil {
+		return ctx, h.webhookValidators[provider], nil
+	}
+
+	secret, err := h.webhookSecrets.ProviderWebhookSecret(ctx, tenantID, provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx = context.WithValue(ctx, ctxkeys.TenantID, tenantID)
+	if secret == "" {
+		return ctx, h.webhookValidators[provider], nil
+	}
+
+	validator, err := providers.CreateWebhookValidator(provider, secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctx, validator, nil
+}
+
+func writeWebhookTenantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrTenantNotFound):
+		writeError(w, http.StatusNotFound, models.ErrCodeTenantNotFound, "Tenant not found")
+	case errors.Is(err, services.ErrTenantInactive):
+		writeError(w, http.StatusForbidden, models.ErrCodeTenantInactive, "Tenant is inactive")
+	default:
+		writeError(w, http.StatusInternalServerError, models.ErrCodeInternal, "Failed to resolve webhook secret")
+	}
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -44,8 +43,7 @@ func (h *PaymentWithFraudHandler) HandleChargeWithFraudCheck(w http.ResponseWrit
 	}
 
 	var req EnhancedChargeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -59,7 +57,7 @@ func (h *PaymentWithFraudHandler) HandleChargeWithFraudCheck(w http.ResponseWrit
 	fraudRequest := &models.FraudAnalysisRequest{
 		TransactionID:       transactionID,
 		UserID:              req.CustomerID,
-		TransactionAmount:   float64(req.Amount) / 100, // Convert from cents
+		TransactionAmount:   req.Money().Major(),
 		BillingCountry:      req.BillingCountry,
 		ShippingCountry:     req.ShippingCountry,
 		IPAddress:           req.IPAddress,
@@ -71,7 +69,7 @@ func (h *PaymentWithFraudHandler) HandleChargeWithFraudCheck(w http.ResponseWrit
 		log.Printf("Fraud analysis failed: %v", err)
 		// In case of fraud service failure, you might want to allow the transaction
 		// or implement a more sophisticated fallback strategy
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Fraud analysis failed"})
+		writeError(w, http.StatusInternalServerError, models.ErrCodeInternal, "Fraud analysis failed")
 		return
 	}
 
@@ -92,10 +90,10 @@ func (h *PaymentWithFraudHandler) HandleChargeWithFraudCheck(w http.ResponseWrit
 	resp, err := h.paymentService.CreateCharge(r.Context(), &req.ChargeRequest)
 	if err != nil {
 		if err == services.ErrNoAvailableProvider {
-			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "No payment provider available"})
+			writeError(w, http.StatusServiceUnavailable, models.ErrCodeProviderUnavailable, "No payment provider available")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1,12 +1,14 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/services"
 )
 
@@ -22,14 +24,22 @@ func CreatePaymentMethodHandler(paymentMethodService *services.PaymentMethodServ
 
 func (h *PaymentMethodHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 	var req models.CreatePaymentMethodRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.MandateIPAddress != "" && net.ParseIP(req.MandateIPAddress) == nil {
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "mandate_ip_address must be an IP address")
 		return
 	}
 
 	pm, err := h.paymentMethodService.CreatePaymentMethod(r.Context(), &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		if errors.Is(err, providers.ErrBankAccountRequired) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -42,7 +52,7 @@ func (h *PaymentMethodHandler) HandleGet(w http.ResponseWriter, r *http.Request)
 
 	pm, err := h.paymentMethodService.GetPaymentMethod(r.Context(), paymentMethodID)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Payment method not found"})
+		writeError(w, http.StatusNotFound, models.ErrCodePaymentMethodNotFound, "Payment method not found")
 		return
 	}
 
@@ -60,7 +70,7 @@ func (h *PaymentMethodHandler) HandleList(w http.ResponseWriter, r *http.Request
 
 	methods, err := h.paymentMethodService.ListPaymentMethods(r.Context(), customerID, pmType)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -77,19 +87,18 @@ func (h *PaymentMethodHandler) HandleAttach(w http.ResponseWriter, r *http.Reque
 	var req struct {
 		CustomerID string `json:"customer_id"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	if err := h.paymentMethodService.AttachPaymentMethod(r.Context(), paymentMethodID, req.CustomerID); err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	pm, err := h.paymentMethodService.GetPaymentMethod(r.Context(), paymentMethodID)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Payment method attached but failed to retrieve"})
+		writeError(w, http.StatusInternalServerError, models.ErrCodeInternal, "Payment method attached but failed to retrieve")
 		return
 	}
 	writeJSON(w, http.StatusOK, models.PaymentMethodResponse{PaymentMethod: pm})
@@ -100,7 +109,7 @@ func (h *PaymentMethodHandler) HandleDetach(w http.ResponseWriter, r *http.Reque
 	paymentMethodID := vars["id"]
 
 	if err := h.paymentMethodService.DetachPaymentMethod(r.Context(), paymentMethodID); err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -118,7 +127,51 @@ func (h *PaymentMethodHandler) HandleExpire(w http.ResponseWriter, r *http.Reque
 
 	pm, err := h.paymentMethodService.ExpirePaymentMethod(r.Context(), paymentMethodID)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.PaymentMethodResponse{PaymentMethod: pm})
+}
+
+func (h *PaymentMethodHandler) HandleSetDefault(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	paymentMethodID := vars["id"]
+
+	pm, err := h.paymentMethodService.SetDefault(r.Context(), paymentMethodID)
+	if err != nil {
+		if errors.Is(err, services.ErrPaymentMethodNotFound) {
+			writeError(w, http.StatusNotFound, models.ErrCodePaymentMethodNotFound, "Payment method not found")
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.PaymentMethodResponse{PaymentMethod: pm})
+}
+
+func (h *PaymentMethodHandler) HandleVerify(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	paymentMethodID := vars["id"]
+
+	var req models.VerifyPaymentMethodRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	pm, err := h.paymentMethodService.VerifyPaymentMethod(r.Context(), paymentMethodID, req.Amounts)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMicrodepositAmounts):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, providers.ErrVerificationNotPending):
+			writeErrorFrom(w, http.StatusConflict, err)
+		case errors.Is(err, providers.ErrNotSupported):
+			writeErrorFrom(w, http.StatusNotImplemented, err)
+		default:
+			writeErrorFrom(w, http.StatusUnprocessableEntity, err)
+		}
 		return
 	}
 
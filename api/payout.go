@@ -1,12 +1,13 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/services"
 )
 
@@ -22,14 +23,21 @@ func CreatePayoutHandler(payoutService *services.PayoutService) *PayoutHandler {
 
 func (h *PayoutHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 	var req models.CreatePayoutRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	payout, err := h.payoutService.CreatePayout(r.Context(), &req)
+	if errors.Is(err, services.ErrInvalidPayoutChannel) || errors.Is(err, services.ErrPayoutAmountOutOfRange) {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, services.ErrInsufficientBalance) {
+		writeErrorFrom(w, http.StatusUnprocessableEntity, err)
+		return
+	}
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -41,8 +49,12 @@ func (h *PayoutHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 	payoutID := vars["id"]
 
 	payout, err := h.payoutService.GetPayout(r.Context(), payoutID)
+	if errors.Is(err, services.ErrCapabilityUnsupported) {
+		writeServiceError(w, err)
+		return
+	}
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Payout not found"})
+		writeError(w, http.StatusNotFound, models.ErrCodePayoutNotFound, "Payout not found")
 		return
 	}
 
@@ -70,7 +82,7 @@ func (h *PayoutHandler) HandleList(w http.ResponseWriter, r *http.Request) {
 
 	payouts, err := h.payoutService.ListPayouts(r.Context(), req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -86,7 +98,7 @@ func (h *PayoutHandler) HandleCancel(w http.ResponseWriter, r *http.Request) {
 
 	payout, err := h.payoutService.CancelPayout(r.Context(), payoutID)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -98,7 +110,7 @@ func (h *PayoutHandler) HandleGetChannels(w http.ResponseWriter, r *http.Request
 
 	channels, err := h.payoutService.GetPayoutChannels(r.Context(), currency)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -106,3 +118,31 @@ func (h *PayoutHandler) HandleGetChannels(w http.ResponseWriter, r *http.Request
 		"channels": channels,
 	})
 }
+
+// HandleFXQuote quotes a conversion for a cross-currency payout:
+// GET /v1/fx/quote?from=USD&to=EUR&amount=10000, where amount is what the
+// recipient receives in the minor unit of to.
+func (h *PayoutHandler) HandleFXQuote(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	amount, err := strconv.ParseInt(query.Get("amount"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "amount must be an integer in the currency's minor unit")
+		return
+	}
+
+	quote, err := h.payoutService.GetFXQuote(r.Context(), query.Get("from"), query.Get("to"), amount)
+	if errors.Is(err, services.ErrInvalidFXQuoteRequest) {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, providers.ErrNotSupported) {
+		writeErrorFrom(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.FXQuoteResponse{Quote: quote})
+}
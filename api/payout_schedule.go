@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"github.com/malwarebo/conductor/services"
+)
+
+func (h *PayoutHandler) HandleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req models.CreatePayoutScheduleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	schedule, err := h.payoutService.CreatePayoutSchedule(r.Context(), &req)
+	if err != nil {
+		writePayoutScheduleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.PayoutScheduleResponse{PayoutSchedule: schedule})
+}
+
+func (h *PayoutHandler) HandleListSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.payoutService.ListPayoutSchedules(r.Context())
+	if err != nil {
+		writePayoutScheduleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.PayoutScheduleListResponse{PayoutSchedules: schedules})
+}
+
+func (h *PayoutHandler) HandleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := h.payoutService.GetPayoutSchedule(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writePayoutScheduleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.PayoutScheduleResponse{PayoutSchedule: schedule})
+}
+
+func (h *PayoutHandler) HandleUpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdatePayoutScheduleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	schedule, err := h.payoutService.UpdatePayoutSchedule(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writePayoutScheduleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.PayoutScheduleResponse{PayoutSchedule: schedule})
+}
+
+func (h *PayoutHandler) HandleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	if err := h.payoutService.DeletePayoutSchedule(r.Context(), mux.Vars(r)["id"]); err != nil {
+		writePayoutScheduleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *PayoutHandler) HandleListScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.payoutService.ListPayoutScheduleRuns(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writePayoutScheduleError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.PayoutScheduleRunListResponse{Runs: runs})
+}
+
+func writePayoutScheduleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrPayoutScheduleNotFound):
+		writeErrorFrom(w, http.StatusNotFound, err)
+	case errors.Is(err, services.ErrPayoutScheduleTenantRequired):
+		writeErrorFrom(w, http.StatusUnauthorized, err)
+	case errors.Is(err, services.ErrInvalidPayoutSchedule),
+		errors.Is(err, providers.ErrProviderCurrency):
+		writeErrorFrom(w, http.StatusBadRequest, err)
+	default:
+		writeServiceError(w, err)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/providers"
+	"github.com/malwarebo/conductor/services"
+)
+
+type fakeNoPayoutProvider struct {
+	providers.PaymentProvider
+}
+
+func (fakeNoPayoutProvider) Name() string                     { return "fake" }
+func (fakeNoPayoutProvider) IsAvailable(context.Context) bool { return true }
+func (fakeNoPayoutProvider) Capabilities() providers.ProviderCapabilities {
+	return providers.ProviderCapabilities{}
+}
+
+func TestPayoutCreateUnsupportedCapabilityReturns422(t *testing.T) {
+	handler := CreatePayoutHandler(services.CreatePayoutService(fakeNoPayoutProvider{}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/payouts", strings.NewReader(`{"amount":100,"currency":"USD"}`))
+	handler.HandleCreate(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+// DefaultMaxBodyBytes caps JSON request bodies when the server config does
+// not set its own limit.
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+var maxBodyBytes = DefaultMaxBodyBytes
+
+// SetMaxBodyBytes sets the largest JSON body handlers will read. It is meant
+// to be called once at startup; non-positive values keep the default.
+func SetMaxBodyBytes(n int64) {
+	if n > 0 {
+		maxBodyBytes = n
+	}
+}
+
+// bodyError describes why a request body was rejected and how to answer.
+type bodyError struct {
+	status  int
+	code    models.ErrorCode
+	message string
+}
+
+func (e *bodyError) Error() string { return e.message }
+
+// readJSON decodes a single JSON value from the request body into v. Bodies
+// over the configured limit, fields v does not declare and trailing data are
+// all rejected so typos in client payloads surface instead of being ignored.
+// An empty body is accepted only when allowEmpty is set, leaving v untouched.
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}, allowEmpty bool) *bodyError {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		if allowEmpty && err == io.EOF {
+			return nil
+		}
+		return bodyErrorFrom(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return bodyErrorFrom(err)
+		}
+		return &bodyError{http.StatusBadRequest, models.ErrCodeInvalidRequest, "Request body must contain a single JSON object"}
+	}
+	return nil
+}
+
+func bodyErrorFrom(err error) *bodyError {
+	var tooLarge *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		return &bodyError{http.StatusRequestEntityTooLarge, models.ErrCodeRequestTooLarge,
+			fmt.Sprintf("Request body must not exceed %d bytes", tooLarge.Limit)}
+	case errors.Is(err, io.EOF):
+		return &bodyError{http.StatusBadRequest, models.ErrCodeInvalidRequest, "Request body must not be empty"}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return &bodyError{http.StatusBadRequest, models.ErrCodeInvalidRequest, "Request body contains malformed JSON"}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return &bodyError{http.StatusBadRequest, models.ErrCodeInvalidRequest,
+			fmt.Sprintf("Request body has an invalid value for field %q", typeErr.Field)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return &bodyError{http.StatusBadRequest, models.ErrCodeInvalidRequest,
+			fmt.Sprintf("Request body contains unknown field %s", field)}
+	default:
+		return &bodyError{http.StatusBadRequest, models.ErrCodeInvalidRequest, "Invalid request body"}
+	}
+}
+
+// decodeJSON is readJSON for handlers that answer with the standard error
+// envelope. It writes the error response itself and reports whether v was
+// filled in.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return writeBodyError(w, readJSON(w, r, v, false))
+}
+
+// decodeOptionalJSON is decodeJSON for endpoints whose body may be omitted
+// entirely, such as capture and cancel.
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return writeBodyError(w, readJSON(w, r, v, true))
+}
+
+func writeBodyError(w http.ResponseWriter, err *bodyError) bool {
+	if err != nil {
+		writeError(w, err.status, err.code, err.message)
+		return false
+	}
+	return true
+}
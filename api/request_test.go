@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
+)
+
+func TestChargeRejectsOversizedBody(t *testing.T) {
+	SetMaxBodyBytes(64)
+	defer SetMaxBodyBytes(DefaultMaxBodyBytes)
+
+	handler := CreatePaymentHandler(services.CreatePaymentService(fakeMissingPaymentStore{}, fakeChargeProvider{}))
+	body := `{"customer_id":"cus_1","amount":1000,"currency":"USD","description":"` + strings.Repeat("x", 128) + `"}`
+
+	rec := httptest.NewRecorder()
+	handler.HandleCharge(rec, httptest.NewRequest(http.MethodPost, "/v1/charges", strings.NewReader(body)))
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+	}
+	if got := decodeError(t, rec).Code; got != models.ErrCodeRequestTooLarge {
+		t.Fatalf("expected code %q, got %q", models.ErrCodeRequestTooLarge, got)
+	}
+}
+
+func TestChargeRejectsUnknownField(t *testing.T) {
+	handler := CreatePaymentHandler(services.CreatePaymentService(fakeMissingPaymentStore{}, fakeChargeProvider{}))
+	body := `{"customer_id":"cus_1","amount":1000,"currency":"USD","ammount":5}`
+
+	rec := httptest.NewRecorder()
+	handler.HandleCharge(rec, httptest.NewRequest(http.MethodPost, "/v1/charges", strings.NewReader(body)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+	resp := decodeError(t, rec)
+	if resp.Code != models.ErrCodeInvalidRequest {
+		t.Fatalf("expected code %q, got %q", models.ErrCodeInvalidRequest, resp.Code)
+	}
+	if !strings.Contains(resp.Message, `"ammount"`) {
+		t.Fatalf("expected message to name the unknown field, got %q", resp.Message)
+	}
+}
+
+func TestCaptureAcceptsEmptyBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/payments/pay_1/capture", http.NoBody)
+
+	var body struct {
+		Amount int64 `json:"amount"`
+	}
+	if !decodeOptionalJSON(rec, req, &body) {
+		t.Fatalf("expected empty body to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -1,20 +1,37 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
 )
 
 const maxPageLimit = 100
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
+// ErrorResponse is the body of every error answer: a stable code, a
+// human-readable message and optional details.
+type ErrorResponse = models.APIError
 
 type WebhookValidator interface {
 	ValidateWebhookSignature(payload []byte, signature string) error
 }
 
+// WebhookSecretResolver looks up the signing secret a tenant configured for
+// its own provider account; an empty secret means use the global one.
+type WebhookSecretResolver interface {
+	ProviderWebhookSecret(ctx context.Context, tenantID, provider string) (string, error)
+}
+
+// TimestampedWebhookValidator is implemented by providers that sign a
+// timestamp header together with the payload.
+type TimestampedWebhookValidator interface {
+	ValidateTimestampedWebhookSignature(payload []byte, signature, timestamp string) error
+}
+
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -24,6 +41,16 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	}
 }
 
+// writeServiceError answers with 422 when the handling provider lacks the
+// capability and 500 otherwise.
+func writeServiceError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, services.ErrCapabilityUnsupported) {
+		status = http.StatusUnprocessableEntity
+	}
+	writeErrorFrom(w, status, err)
+}
+
 func clampLimit(limit int) int {
 	if limit <= 0 {
 		return 20
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"github.com/malwarebo/conductor/services"
+)
+
+// HandleSchedulePayment stores a charge to run at run_at. The charge is sent
+// with the request's Idempotency-Key when it runs, or one derived from the
+// scheduled payment's ID otherwise.
+func (h *PaymentHandler) HandleSchedulePayment(w http.ResponseWriter, r *http.Request) {
+	var req models.SchedulePaymentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if idempotencyKey := r.Header.Get("Idempotency-Key"); idempotencyKey != "" {
+		req.IdempotencyKey = idempotencyKey
+	}
+	if provider := r.Header.Get(providerOverrideHeader); provider != "" {
+		req.Provider = provider
+	}
+
+	scheduled, err := h.paymentService.SchedulePayment(r.Context(), &req.ChargeRequest, req.RunAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidScheduledPayment),
+			errors.Is(err, services.ErrNoDefaultPaymentMethod),
+			errors.Is(err, providers.ErrProviderNotConfigured),
+			errors.Is(err, providers.ErrProviderCurrency):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrCapabilityUnsupported):
+			writeErrorFrom(w, http.StatusUnprocessableEntity, err)
+		case errors.Is(err, services.ErrCustomerNotFound):
+			writeErrorFrom(w, http.StatusNotFound, err)
+		default:
+			writeErrorFrom(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.ScheduledPaymentResponse{ScheduledPayment: scheduled})
+}
+
+func (h *PaymentHandler) HandleListScheduledPayments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.ScheduledPaymentFilter{
+		Status: models.ScheduledPaymentStatus(query.Get("status")),
+		Limit:  20,
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if parsed, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = clampLimit(parsed)
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		if o, err := strconv.Atoi(offset); err == nil && o > 0 {
+			filter.Offset = o
+		}
+	}
+
+	scheduled, err := h.paymentService.ListScheduledPayments(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, services.ErrPaymentTenantRequired) {
+			writeErrorFrom(w, http.StatusUnauthorized, err)
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.ScheduledPaymentListResponse{
+		ScheduledPayments: scheduled,
+		Limit:             filter.Limit,
+		Offset:            filter.Offset,
+	})
+}
+
+func (h *PaymentHandler) HandleGetScheduledPayment(w http.ResponseWriter, r *http.Request) {
+	scheduled, err := h.paymentService.GetScheduledPayment(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, services.ErrScheduledPaymentNotFound) {
+			writeErrorFrom(w, http.StatusNotFound, err)
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.ScheduledPaymentResponse{ScheduledPayment: scheduled})
+}
+
+func (h *PaymentHandler) HandleCancelScheduledPayment(w http.ResponseWriter, r *http.Request) {
+	scheduled, err := h.paymentService.CancelScheduledPayment(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrScheduledPaymentNotFound):
+			writeErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, services.ErrScheduledPaymentNotCancelable):
+			writeErrorFrom(w, http.StatusConflict, err)
+		default:
+			writeErrorFrom(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.ScheduledPaymentResponse{ScheduledPayment: scheduled})
+}
@@ -1,11 +1,12 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/services"
 )
 
@@ -36,13 +37,13 @@ func (h *SubscriptionHandler) HandlePlans(w http.ResponseWriter, r *http.Request
 		if id != "" {
 			h.handleUpdatePlan(w, r, id)
 		} else {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Plan ID required"})
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Plan ID required")
 		}
 	case http.MethodDelete:
 		if id != "" {
 			h.handleDeletePlan(w, r, id)
 		} else {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Plan ID required"})
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Plan ID required")
 		}
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -66,13 +67,13 @@ func (h *SubscriptionHandler) HandleSubscriptions(w http.ResponseWriter, r *http
 		if id != "" {
 			h.handleUpdateSubscription(w, r, id)
 		} else {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Subscription ID required"})
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Subscription ID required")
 		}
 	case http.MethodDelete:
 		if id != "" {
 			h.handleCancelSubscription(w, r, id)
 		} else {
-			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Subscription ID required"})
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "Subscription ID required")
 		}
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -81,14 +82,13 @@ func (h *SubscriptionHandler) HandleSubscriptions(w http.ResponseWriter, r *http
 
 func (h *SubscriptionHandler) handleCreatePlan(w http.ResponseWriter, r *http.Request) {
 	var plan models.Plan
-	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &plan) {
 		return
 	}
 
 	createdPlan, err := h.subscriptionService.CreatePlan(r.Context(), &plan)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -97,14 +97,13 @@ func (h *SubscriptionHandler) handleCreatePlan(w http.ResponseWriter, r *http.Re
 
 func (h *SubscriptionHandler) handleUpdatePlan(w http.ResponseWriter, r *http.Request, planID string) {
 	var plan models.Plan
-	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &plan) {
 		return
 	}
 
 	updatedPlan, err := h.subscriptionService.UpdatePlan(r.Context(), planID, &plan)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -113,7 +112,7 @@ func (h *SubscriptionHandler) handleUpdatePlan(w http.ResponseWriter, r *http.Re
 
 func (h *SubscriptionHandler) handleDeletePlan(w http.ResponseWriter, r *http.Request, planID string) {
 	if err := h.subscriptionService.DeletePlan(r.Context(), planID); err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -123,7 +122,7 @@ func (h *SubscriptionHandler) handleDeletePlan(w http.ResponseWriter, r *http.Re
 func (h *SubscriptionHandler) handleGetPlan(w http.ResponseWriter, r *http.Request, planID string) {
 	plan, err := h.subscriptionService.GetPlan(r.Context(), planID)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -133,7 +132,7 @@ func (h *SubscriptionHandler) handleGetPlan(w http.ResponseWriter, r *http.Reque
 func (h *SubscriptionHandler) handleListPlans(w http.ResponseWriter, r *http.Request) {
 	plans, err := h.subscriptionService.ListPlans(r.Context())
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -145,14 +144,13 @@ func (h *SubscriptionHandler) handleListPlans(w http.ResponseWriter, r *http.Req
 
 func (h *SubscriptionHandler) handleCreateSubscription(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateSubscriptionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	subscription, err := h.subscriptionService.CreateSubscription(r.Context(), &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -161,14 +159,20 @@ func (h *SubscriptionHandler) handleCreateSubscription(w http.ResponseWriter, r
 
 func (h *SubscriptionHandler) handleUpdateSubscription(w http.ResponseWriter, r *http.Request, subscriptionID string) {
 	var req models.UpdateSubscriptionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
+	if idempotencyKey := r.Header.Get("Idempotency-Key"); idempotencyKey != "" {
+		req.IdempotencyKey = idempotencyKey
+	}
 
 	subscription, err := h.subscriptionService.UpdateSubscription(r.Context(), subscriptionID, &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		if errors.Is(err, services.ErrIdempotencyConflict) {
+			writeErrorFrom(w, http.StatusConflict, err)
+			return
+		}
+		writeServiceError(w, err)
 		return
 	}
 
@@ -177,14 +181,20 @@ func (h *SubscriptionHandler) handleUpdateSubscription(w http.ResponseWriter, r
 
 func (h *SubscriptionHandler) handleCancelSubscription(w http.ResponseWriter, r *http.Request, subscriptionID string) {
 	var req models.CancelSubscriptionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
+	if idempotencyKey := r.Header.Get("Idempotency-Key"); idempotencyKey != "" {
+		req.IdempotencyKey = idempotencyKey
+	}
 
 	subscription, err := h.subscriptionService.CancelSubscription(r.Context(), subscriptionID, &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		if errors.Is(err, services.ErrIdempotencyConflict) {
+			writeErrorFrom(w, http.StatusConflict, err)
+			return
+		}
+		writeServiceError(w, err)
 		return
 	}
 
@@ -194,7 +204,7 @@ func (h *SubscriptionHandler) handleCancelSubscription(w http.ResponseWriter, r
 func (h *SubscriptionHandler) handleGetSubscription(w http.ResponseWriter, r *http.Request, subscriptionID string) {
 	subscription, err := h.subscriptionService.GetSubscription(r.Context(), subscriptionID)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -204,13 +214,13 @@ func (h *SubscriptionHandler) handleGetSubscription(w http.ResponseWriter, r *ht
 func (h *SubscriptionHandler) handleListSubscriptions(w http.ResponseWriter, r *http.Request) {
 	customerID := r.URL.Query().Get("customer_id")
 	if customerID == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "customer_id query parameter is required"})
+		writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "customer_id query parameter is required")
 		return
 	}
 
 	subscriptions, err := h.subscriptionService.ListSubscriptions(r.Context(), customerID)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
@@ -219,3 +229,29 @@ func (h *SubscriptionHandler) handleListSubscriptions(w http.ResponseWriter, r *
 		"total": len(subscriptions),
 	})
 }
+
+func (h *SubscriptionHandler) HandleRecordUsage(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := mux.Vars(r)["id"]
+
+	var req models.RecordUsageRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	record, err := h.subscriptionService.RecordUsage(r.Context(), subscriptionID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSubscriptionNotFound):
+			writeErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, services.ErrPlanNotMetered), errors.Is(err, services.ErrInvalidUsageQuantity):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, providers.ErrNotSupported):
+			writeErrorFrom(w, http.StatusNotImplemented, err)
+		default:
+			writeServiceError(w, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, record)
+}
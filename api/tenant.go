@@ -1,11 +1,12 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/internal/ctxkeys"
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/services"
 )
@@ -22,14 +23,17 @@ func CreateTenantHandler(tenantService *services.TenantService) *TenantHandler {
 
 func (h *TenantHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateTenantRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	tenant, err := h.tenantService.Create(r.Context(), &req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		if errors.Is(err, services.ErrInvalidDefaultCaptureMethod) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -43,10 +47,14 @@ func (h *TenantHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 	tenant, err := h.tenantService.GetByID(r.Context(), id)
 	if err != nil {
 		if err == services.ErrTenantNotFound {
-			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Tenant not found"})
+			writeError(w, http.StatusNotFound, models.ErrCodeTenantNotFound, "Tenant not found")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		if errors.Is(err, services.ErrInvalidDefaultCaptureMethod) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -58,18 +66,17 @@ func (h *TenantHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	var req models.UpdateTenantRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	tenant, err := h.tenantService.Update(r.Context(), id, &req)
 	if err != nil {
 		if err == services.ErrTenantNotFound {
-			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "Tenant not found"})
+			writeError(w, http.StatusNotFound, models.ErrCodeTenantNotFound, "Tenant not found")
 			return
 		}
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -97,7 +104,7 @@ func (h *TenantHandler) HandleList(w http.ResponseWriter, r *http.Request) {
 
 	tenants, total, err := h.tenantService.List(r.Context(), activeOnly, limit, offset)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -114,19 +121,45 @@ func (h *TenantHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	if err := h.tenantService.Delete(r.Context(), id); err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		if err == services.ErrTenantNotFound {
+			writeError(w, http.StatusNotFound, models.ErrCodeTenantNotFound, "Tenant not found")
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
+func (h *TenantHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	if !isAdminWriteRequest(r) {
+		writeError(w, http.StatusForbidden, models.ErrCodeForbidden, "Admin access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	tenant, err := h.tenantService.Restore(r.Context(), id)
+	if err != nil {
+		if err == services.ErrTenantNotFound {
+			writeError(w, http.StatusNotFound, models.ErrCodeTenantNotFound, "Tenant not found")
+			return
+		}
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.TenantResponse{Tenant: tenant})
+}
+
 func (h *TenantHandler) HandleDeactivate(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
 	if err := h.tenantService.Deactivate(r.Context(), id); err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -139,9 +172,44 @@ func (h *TenantHandler) HandleRegenerateSecret(w http.ResponseWriter, r *http.Re
 
 	newSecret, err := h.tenantService.RegenerateAPISecret(r.Context(), id)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		writeErrorFrom(w, http.StatusInternalServerError, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"api_secret": newSecret})
 }
+
+func (h *TenantHandler) HandleRotateEncryptionKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if !isOwnTenantOrAdmin(r, id) {
+		writeError(w, http.StatusForbidden, models.ErrCodeForbidden, "Cannot manage another tenant")
+		return
+	}
+
+	keyID, err := h.tenantService.RotateEncryptionKey(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTenantNotFound):
+			writeError(w, http.StatusNotFound, models.ErrCodeTenantNotFound, "Tenant not found")
+		case errors.Is(err, services.ErrTenantKeysNotEnabled):
+			writeErrorFrom(w, http.StatusConflict, err)
+		default:
+			writeErrorFrom(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"key_id": keyID})
+}
+
+// isOwnTenantOrAdmin reports whether the caller may change tenant id: an
+// admin may change any tenant, anyone else only the tenant they act for.
+func isOwnTenantOrAdmin(r *http.Request, id string) bool {
+	if isAdminWriteRequest(r) {
+		return true
+	}
+	tenantID, _ := r.Context().Value(ctxkeys.TenantID).(string)
+	return tenantID != "" && tenantID == id
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+)
+
+func TestTenantRestoreRequiresAdmin(t *testing.T) {
+	// No tenant service: reaching it would panic.
+	handler := CreateTenantHandler(nil)
+	key := &models.APIKey{TenantID: "ten_a", Scopes: []string{"tenants:write"}}
+	ctx := context.WithValue(context.Background(), ctxkeys.ScopedAPIKey, key)
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/v1/tenants/ten_b/restore", nil).WithContext(ctx), map[string]string{"id": "ten_b"})
+
+	rec := httptest.NewRecorder()
+	handler.HandleRestore(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected a tenants:write key to be refused, got %d", rec.Code)
+	}
+}
+
+func TestTenantKeyRotationRefusedForAnotherTenant(t *testing.T) {
+	// No tenant service: reaching it would panic.
+	handler := CreateTenantHandler(nil)
+	key := &models.APIKey{TenantID: "ten_a", Scopes: []string{"tenants:write"}}
+	ctx := context.WithValue(context.Background(), ctxkeys.ScopedAPIKey, key)
+	ctx = context.WithValue(ctx, ctxkeys.TenantID, "ten_a")
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/v1/tenants/ten_b/rotate-encryption-key", nil).WithContext(ctx), map[string]string{"id": "ten_b"})
+
+	rec := httptest.NewRecorder()
+	handler.HandleRotateEncryptionKey(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected rotating another tenant's key to be refused, got %d", rec.Code)
+	}
+}
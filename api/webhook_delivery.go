@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
+)
+
+type WebhookDeliveryHandler struct {
+	webhookService *services.WebhookService
+}
+
+func CreateWebhookDeliveryHandler(webhookService *services.WebhookService) *WebhookDeliveryHandler {
+	return &WebhookDeliveryHandler{
+		webhookService: webhookService,
+	}
+}
+
+// HandleList returns the caller's outbound webhook delivery attempts newest
+// first, optionally narrowed by event_type, status and a start_date/end_date
+// window.
+func (h *WebhookDeliveryHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.WebhookDeliveryFilter{
+		TenantID:  tenantID,
+		EventType: query.Get("event_type"),
+		Limit:     50,
+	}
+
+	if status := query.Get("status"); status != "" {
+		filter.Status = models.WebhookDeliveryStatus(status)
+		if filter.Status != models.WebhookDeliveryStatusSucceeded && filter.Status != models.WebhookDeliveryStatusFailed {
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "status must be succeeded or failed")
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if parsed, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = clampLimit(parsed)
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		if o, err := strconv.Atoi(offset); err == nil && o > 0 {
+			filter.Offset = o
+		}
+	}
+	if startDate := query.Get("start_date"); startDate != "" {
+		parsed, err := time.Parse(time.RFC3339, startDate)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "start_date must be RFC3339")
+			return
+		}
+		filter.StartDate = &parsed
+	}
+	if endDate := query.Get("end_date"); endDate != "" {
+		parsed, err := time.Parse(time.RFC3339, endDate)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "end_date must be RFC3339")
+			return
+		}
+		filter.EndDate = &parsed
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(r.Context(), filter)
+	if err != nil {
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.WebhookDeliveryListResponse{
+		Deliveries: deliveries,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+	})
+}
+
+// HandleRedeliver sends a previously logged event to the tenant's webhook
+// URL or endpoint again and returns the new attempt. A receiver rejecting
+// the event is reported in the attempt's status, not as an error response.
+func (h *WebhookDeliveryHandler) HandleRedeliver(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(r.Context(), tenantID, mux.Vars(r)["id"])
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebhookDeliveryNotFound), errors.Is(err, services.ErrWebhookEndpointNotFound):
+			writeErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, services.ErrWebhookURLNotConfigured):
+			writeErrorFrom(w, http.StatusUnprocessableEntity, err)
+		default:
+			writeErrorFrom(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, delivery)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
+)
+
+type WebhookEndpointHandler struct {
+	webhookService *services.WebhookService
+}
+
+func CreateWebhookEndpointHandler(webhookService *services.WebhookService) *WebhookEndpointHandler {
+	return &WebhookEndpointHandler{
+		webhookService: webhookService,
+	}
+}
+
+// HandleCreate registers an endpoint. The response carries the endpoint's
+// signing secret, which is not returned again.
+func (h *WebhookEndpointHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	var req models.CreateWebhookEndpointRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	endpoint, secret, err := h.webhookService.CreateEndpoint(r.Context(), tenantID, &req)
+	if err != nil {
+		writeEndpointError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.WebhookEndpointResponse{Endpoint: endpoint, Secret: secret})
+}
+
+func (h *WebhookEndpointHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	endpoints, err := h.webhookService.ListEndpoints(r.Context(), tenantID)
+	if err != nil {
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.WebhookEndpointListResponse{Endpoints: endpoints})
+}
+
+func (h *WebhookEndpointHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	endpoint, err := h.webhookService.GetEndpoint(r.Context(), tenantID, mux.Vars(r)["id"])
+	if err != nil {
+		writeEndpointError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.WebhookEndpointResponse{Endpoint: endpoint})
+}
+
+func (h *WebhookEndpointHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	var req models.UpdateWebhookEndpointRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	endpoint, err := h.webhookService.UpdateEndpoint(r.Context(), tenantID, mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeEndpointError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.WebhookEndpointResponse{Endpoint: endpoint})
+}
+
+func (h *WebhookEndpointHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string)
+	if !ok || tenantID == "" {
+		writeError(w, http.StatusUnauthorized, models.ErrCodeTenantRequired, "Tenant context required")
+		return
+	}
+
+	if err := h.webhookService.DeleteEndpoint(r.Context(), tenantID, mux.Vars(r)["id"]); err != nil {
+		writeEndpointError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeEndpointError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrWebhookEndpointNotFound):
+		writeErrorFrom(w, http.StatusNotFound, err)
+	case errors.Is(err, services.ErrInvalidWebhookEndpointURL), errors.Is(err, services.ErrWebhookEndpointEventsNeeded):
+		writeErrorFrom(w, http.StatusBadRequest, err)
+	default:
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
+)
+
+type WebhookEventHandler struct {
+	webhookService *services.WebhookService
+}
+
+func CreateWebhookEventHandler(webhookService *services.WebhookService) *WebhookEventHandler {
+	return &WebhookEventHandler{
+		webhookService: webhookService,
+	}
+}
+
+// HandleListDeadLetter returns inbound provider events that exhausted their
+// retries, most recently dead-lettered first, optionally narrowed by
+// provider. It is an operator endpoint: scoped API keys need the admin:read
+// scope and JWTs need the admin role.
+func (h *WebhookEventHandler) HandleListDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if !isAdminRequest(r) {
+		writeError(w, http.StatusForbidden, models.ErrCodeForbidden, "Admin access required")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.DeadLetterFilter{
+		Provider: query.Get("provider"),
+		Limit:    50,
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if parsed, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = clampLimit(parsed)
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		if o, err := strconv.Atoi(offset); err == nil && o > 0 {
+			filter.Offset = o
+		}
+	}
+
+	events, err := h.webhookService.ListDeadLetterEvents(r.Context(), filter)
+	if err != nil {
+		writeErrorFrom(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.DeadLetterListResponse{
+		Events: events,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	})
+}
+
+// HandleReprocess gives a dead-lettered event one more attempt and returns
+// the event afterwards. An attempt that fails again leaves the event in the
+// dead-letter state with the new reason, not an error response. Scoped API
+// keys need the admin:write scope.
+func (h *WebhookEventHandler) HandleReprocess(w http.ResponseWriter, r *http.Request) {
+	if !isAdminWriteRequest(r) {
+		writeError(w, http.StatusForbidden, models.ErrCodeForbidden, "Admin access required")
+		return
+	}
+
+	event, err := h.webhookService.ReprocessEvent(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebhookEventNotFound):
+			writeErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, services.ErrWebhookEventNotDeadLettered):
+			writeErrorFrom(w, http.StatusConflict, err)
+		default:
+			writeErrorFrom(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, event)
+}
+
+// HandleReplay re-runs a stored inbound event through the business handlers,
+// whatever state it finished in, and returns the event afterwards. It is
+// meant for replaying events after a handler bug is fixed, without asking
+// the provider to resend them. Scoped API keys need the admin:write scope.
+func (h *WebhookEventHandler) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	if !isAdminWriteRequest(r) {
+		writeError(w, http.StatusForbidden, models.ErrCodeForbidden, "Admin access required")
+		return
+	}
+
+	vars := mux.Vars(r)
+	event, err := h.webhookService.Reprocess(r.Context(), vars["provider"], vars["eventID"])
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebhookEventNotFound):
+			writeErrorFrom(w, http.StatusNotFound, err)
+		case errors.Is(err, services.ErrWebhookEventInProgress):
+			writeErrorFrom(w, http.StatusConflict, err)
+		default:
+			writeErrorFrom(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, event)
+}
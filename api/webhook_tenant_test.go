@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/internal/crypto"
+	"github.com/malwarebo/conductor/providers"
+	"github.com/malwarebo/conductor/services"
+)
+
+type fakeWebhookSecrets map[string]string
+
+func (f fakeWebhookSecrets) ProviderWebhookSecret(_ context.Context, tenantID, provider string) (string, error) {
+	secret, ok := f[tenantID]
+	if !ok {
+		return "", services.ErrTenantNotFound
+	}
+	return secret, nil
+}
+
+func TestTenantWebhookVerifiesWithTenantSecret(t *testing.T) {
+	global, err := providers.CreateWebhookValidator("xendit", "global-secret")
+	if err != nil {
+		t.Fatalf("create validator: %v", err)
+	}
+	h := CreatePaymentHandlerWithWebhook(nil, nil, map[string]WebhookValidator{"xendit": global})
+	h.SetWebhookSecretResolver(fakeWebhookSecrets{"tenant_a": "tenant-a-secret", "tenant_b": ""})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/webhooks/xendit", h.HandleXenditWebhook).Methods("POST")
+	router.HandleFunc("/v1/webhooks/xendit/{tenantId}", h.HandleXenditWebhook).Methods("POST")
+
+	payload := []byte(`{"id":"evt_1","event":"payment.succeeded"}`)
+	tests := []struct {
+		name   string
+		path   string
+		secret string
+		want   int
+	}{
+		{"tenant secret", "/v1/webhooks/xendit/tenant_a", "tenant-a-secret", http.StatusOK},
+		{"global secret rejected for tenant with override", "/v1/webhooks/xendit/tenant_a", "global-secret", http.StatusUnauthorized},
+		{"tenant without override falls back to global", "/v1/webhooks/xendit/tenant_b", "global-secret", http.StatusOK},
+		{"global route", "/v1/webhooks/xendit", "global-secret", http.StatusOK},
+		{"unknown tenant", "/v1/webhooks/xendit/tenant_x", "global-secret", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewReader(payload))
+			req.Header.Set("x-callback-token", crypto.GenerateHMACSHA256(payload, tt.secret))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
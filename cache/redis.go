@@ -82,3 +82,9 @@ func (c *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
 	result, err := c.client.Exists(ctx, key).Result()
 	return result > 0, err
 }
+
+// AddToStream appends an entry to a Redis stream, creating the stream if it
+// does not exist.
+func (c *RedisCache) AddToStream(ctx context.Context, stream string, values map[string]interface{}) error {
+	return c.client.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: values}).Err()
+}
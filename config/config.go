@@ -5,7 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/malwarebo/conductor/internal/httputil"
+	"github.com/malwarebo/conductor/models"
 )
 
 type Config struct {
@@ -18,70 +23,293 @@ type Config struct {
 	Server      ServerConfig     `json:"server"`
 	Redis       RedisConfig      `json:"redis"`
 	OpenAI      OpenAIConfig     `json:"openai"`
+	HTTPClient  HTTPClientConfig `json:"http_client"`
+	Fraud       FraudConfig      `json:"fraud"`
+	Payment     PaymentConfig    `json:"payment"`
 	Security    SecurityConfig   `json:"security"`
 	Monitoring  MonitoringConfig `json:"monitoring"`
 	Worker      WorkerConfig     `json:"worker"`
+	Events      EventsConfig     `json:"events"`
 }
 
 type WorkerConfig struct {
-	WebhookWorkers      int `json:"webhook_workers"`
-	WebhookBatchSize    int `json:"webhook_batch_size"`
-	WebhookPollMs       int `json:"webhook_poll_ms"`
-	WebhookStaleSeconds int `json:"webhook_stale_seconds"`
+	WebhookWorkers                 int   `json:"webhook_workers"`
+	WebhookBatchSize               int   `json:"webhook_batch_size"`
+	WebhookPollMs                  int   `json:"webhook_poll_ms"`
+	WebhookStaleSeconds            int   `json:"webhook_stale_seconds"`
+	ReconcileIntervalSeconds       int   `json:"reconcile_interval_seconds"`
+	ReconcileOlderThanSeconds      int   `json:"reconcile_older_than_seconds"`
+	ReconcileBatchSize             int   `json:"reconcile_batch_size"`
+	DunningIntervalSeconds         int   `json:"dunning_interval_seconds"`
+	DunningBatchSize               int   `json:"dunning_batch_size"`
+	DunningRetryHours              []int `json:"dunning_retry_hours"`
+	DisputeReminderIntervalSeconds int   `json:"dispute_reminder_interval_seconds"`
+	DisputeReminderBatchSize       int   `json:"dispute_reminder_batch_size"`
+	DisputeReminderLeadHours       int   `json:"dispute_reminder_lead_hours"`
+	ReEncryptIntervalSeconds       int   `json:"reencrypt_interval_seconds"`
+	AuthExpiryIntervalSeconds      int   `json:"auth_expiry_interval_seconds"`
+	AuthExpiryBatchSize            int   `json:"auth_expiry_batch_size"`
+	AuthExpiryDefaultHours         int   `json:"auth_expiry_default_hours"`
+	// AuthExpiryHours overrides AuthExpiryDefaultHours per provider name,
+	// since providers hold authorizations for different periods.
+	AuthExpiryHours                   map[string]int `json:"auth_expiry_hours"`
+	IdempotencyCleanupIntervalSeconds int            `json:"idempotency_cleanup_interval_seconds"`
+	IdempotencyCleanupBatchSize       int            `json:"idempotency_cleanup_batch_size"`
+	ScheduledPaymentIntervalSeconds   int            `json:"scheduled_payment_interval_seconds"`
+	ScheduledPaymentBatchSize         int            `json:"scheduled_payment_batch_size"`
+	PayoutScheduleIntervalSeconds     int            `json:"payout_schedule_interval_seconds"`
+	PayoutScheduleBatchSize           int            `json:"payout_schedule_batch_size"`
+	EventPublishRetryIntervalSeconds  int            `json:"event_publish_retry_interval_seconds"`
+	EventPublishRetryBatchSize        int            `json:"event_publish_retry_batch_size"`
 }
 
 type DatabaseConfig struct {
-	Host         string        `json:"host"`
-	Port         int           `json:"port"`
-	User         string        `json:"user"`
-	Password     string        `json:"password"`
-	DBName       string        `json:"dbname"`
-	SSLMode      string        `json:"sslmode"`
-	MaxOpenConns int           `json:"max_open_conns"`
-	MaxIdleConns int           `json:"max_idle_conns"`
-	MaxLifetime  time.Duration `json:"max_lifetime"`
-	MaxIdleTime  time.Duration `json:"max_idle_time"`
-	ReplicaDSNs  []string      `json:"replica_dsns"`
+	Host         string                 `json:"host"`
+	Port         int                    `json:"port"`
+	User         string                 `json:"user"`
+	Password     string                 `json:"password"`
+	DBName       string                 `json:"dbname"`
+	SSLMode      string                 `json:"sslmode"`
+	MaxOpenConns int                    `json:"max_open_conns"`
+	MaxIdleConns int                    `json:"max_idle_conns"`
+	MaxLifetime  time.Duration          `json:"max_lifetime"`
+	MaxIdleTime  time.Duration          `json:"max_idle_time"`
+	ReplicaDSNs  []string               `json:"replica_dsns"`
+	Failover     DatabaseFailoverConfig `json:"failover"`
+}
+
+// DatabaseFailoverConfig promotes one of ReplicaDSNs, by index, when the primary
+// fails FailureThreshold health checks in a row. Automatic failovers are at
+// least CooldownSeconds apart.
+type DatabaseFailoverConfig struct {
+	Enabled                    bool `json:"enabled"`
+	Replica                    int  `json:"replica"`
+	FailureThreshold           int  `json:"failure_threshold"`
+	CooldownSeconds            int  `json:"cooldown_seconds"`
+	HealthCheckIntervalSeconds int  `json:"health_check_interval_seconds"`
 }
 
+const (
+	ProviderModeTest = "test"
+	ProviderModeLive = "live"
+)
+
+var (
+	stripeTestKeyPrefixes   = []string{"sk_test_", "rk_test_"}
+	xenditTestKeyPrefixes   = []string{"xnd_development_"}
+	razorpayTestKeyPrefixes = []string{"rzp_test_"}
+)
+
 type StripeConfig struct {
 	Secret        string `json:"secret"`
 	Public        string `json:"public"`
 	WebhookSecret string `json:"webhook_secret"`
+	Mode          string `json:"mode"`
+}
+
+func (c StripeConfig) ResolvedMode() string {
+	return resolveProviderMode(c.Mode, c.Secret, stripeTestKeyPrefixes...)
 }
 
 type XenditConfig struct {
 	Secret        string `json:"secret"`
 	Public        string `json:"public"`
 	WebhookSecret string `json:"webhook_secret"`
+	Mode          string `json:"mode"`
+}
+
+func (c XenditConfig) ResolvedMode() string {
+	return resolveProviderMode(c.Mode, c.Secret, xenditTestKeyPrefixes...)
 }
 
 type RazorpayConfig struct {
 	KeyID         string `json:"key_id"`
 	KeySecret     string `json:"key_secret"`
 	WebhookSecret string `json:"webhook_secret"`
+	Mode          string `json:"mode"`
+}
+
+func (c RazorpayConfig) ResolvedMode() string {
+	return resolveProviderMode(c.Mode, c.KeyID, razorpayTestKeyPrefixes...)
 }
 
 type AirwallexConfig struct {
 	ClientID      string `json:"client_id"`
 	APIKey        string `json:"api_key"`
 	WebhookSecret string `json:"webhook_secret"`
-	UseSandbox    bool   `json:"use_sandbox"`
+	Mode          string `json:"mode"`
+	// Deprecated: set Mode to "test" instead.
+	UseSandbox bool `json:"use_sandbox"`
+}
+
+// ResolvedMode prefers the explicit mode; Airwallex keys carry no
+// environment marker, so without one the legacy use_sandbox flag decides.
+func (c AirwallexConfig) ResolvedMode() string {
+	if c.Mode != "" {
+		return c.Mode
+	}
+	if c.UseSandbox {
+		return ProviderModeTest
+	}
+	return ProviderModeLive
+}
+
+func (c AirwallexConfig) Sandbox() bool {
+	return c.ResolvedMode() == ProviderModeTest
+}
+
+// resolveProviderMode falls back to the mode implied by the key prefix when
+// none is configured.
+func resolveProviderMode(mode, key string, testPrefixes ...string) string {
+	if mode != "" {
+		return mode
+	}
+	return keyMode(key, testPrefixes...)
+}
+
+func keyMode(key string, testPrefixes ...string) string {
+	for _, prefix := range testPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return ProviderModeTest
+		}
+	}
+	return ProviderModeLive
+}
+
+// validateProviderMode rejects unknown modes and keys that belong to the other
+// environment, so a live deployment cannot silently run against test keys.
+func validateProviderMode(provider, mode, key string, testPrefixes ...string) error {
+	switch mode {
+	case "":
+		return nil
+	case ProviderModeTest, ProviderModeLive:
+	default:
+		return fmt.Errorf("%s mode must be %q or %q", provider, ProviderModeTest, ProviderModeLive)
+	}
+	if key != "" && len(testPrefixes) > 0 && keyMode(key, testPrefixes...) != mode {
+		return fmt.Errorf("%s mode is %s but the configured key is not a %s key", provider, mode, mode)
+	}
+	return nil
 }
 
 type OpenAIConfig struct {
-	APIKey string `json:"api_key"`
+	APIKey         string   `json:"api_key"`
+	APIType        string   `json:"api_type"`
+	APIVersion     string   `json:"api_version"`
+	Model          string   `json:"model"`
+	BaseURL        string   `json:"base_url"`
+	RequestTimeout Duration `json:"request_timeout"`
+}
+
+// Duration reads a duration string such as "30s" from JSON. Bare numbers are
+// still read as nanoseconds, which is how time.Duration fields were decoded.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch value := raw.(type) {
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		*d = Duration(parsed)
+	case float64:
+		*d = Duration(time.Duration(value))
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// HTTPClientConfig tunes the pooled client shared by the providers and the
+// OpenAI fraud service. Zero values keep the httputil defaults.
+type HTTPClientConfig struct {
+	TimeoutSeconds               int `json:"timeout_seconds"`
+	DialTimeoutSeconds           int `json:"dial_timeout_seconds"`
+	TLSHandshakeTimeoutSeconds   int `json:"tls_handshake_timeout_seconds"`
+	ResponseHeaderTimeoutSeconds int `json:"response_header_timeout_seconds"`
+	MaxIdleConns                 int `json:"max_idle_conns"`
+	MaxIdleConnsPerHost          int `json:"max_idle_conns_per_host"`
+	MaxConnsPerHost              int `json:"max_conns_per_host"`
+	IdleConnTimeoutSeconds       int `json:"idle_conn_timeout_seconds"`
+}
+
+func (c HTTPClientConfig) Transport() httputil.TransportConfig {
+	return httputil.TransportConfig{
+		Timeout:               time.Duration(c.TimeoutSeconds) * time.Second,
+		DialTimeout:           time.Duration(c.DialTimeoutSeconds) * time.Second,
+		TLSHandshakeTimeout:   time.Duration(c.TLSHandshakeTimeoutSeconds) * time.Second,
+		ResponseHeaderTimeout: time.Duration(c.ResponseHeaderTimeoutSeconds) * time.Second,
+		MaxIdleConns:          c.MaxIdleConns,
+		MaxIdleConnsPerHost:   c.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       c.MaxConnsPerHost,
+		IdleConnTimeout:       time.Duration(c.IdleConnTimeoutSeconds) * time.Second,
+	}
+}
+
+// FraudConfig holds hard block rules evaluated before the AI assessment. No
+// rules apply unless Rules is set or UseDefaultRules opts in to the built-in
+// set. GeoIPDatabasePaths lists optional MaxMind .mmdb files (e.g.
+// GeoLite2-Country and GeoLite2-ASN) used to enrich client IPs. FailOpen lets
+// charges through unscreened when the inline fraud check errors; by default
+// they fail.
+type FraudConfig struct {
+	Rules              []models.FraudRule `json:"rules"`
+	UseDefaultRules    bool               `json:"use_default_rules"`
+	GeoIPDatabasePaths []string           `json:"geoip_database_paths"`
+	FailOpen           bool               `json:"fail_open"`
+}
+
+// PaymentConfig overrides the charge amount limits, keyed by provider and
+// then currency. Amounts are in minor units and pairs not listed keep their
+// defaults. CurrencyExponents overrides the decimals used to format amounts.
+// CaptureProviderResponses stores raw provider responses for every tenant;
+// without it only tenants with the capture_provider_responses setting are
+// captured.
+type PaymentConfig struct {
+	AmountLimits             map[string]map[string]models.AmountLimit `json:"amount_limits"`
+	CurrencyExponents        map[string]int                           `json:"currency_exponents"`
+	CaptureProviderResponses bool                                     `json:"capture_provider_responses"`
+	IdempotencyTTL           time.Duration                            `json:"idempotency_ttl"`
+	// ChargeFailover retries a charge on another provider that supports the
+	// currency when the routed one is unavailable. Declines are not retried.
+	ChargeFailover bool `json:"charge_failover"`
+	// ProviderOrder ranks providers by name for failover and tie-breaking,
+	// e.g. ["airwallex", "stripe", "xendit"]. Unlisted providers follow.
+	ProviderOrder []string `json:"provider_order"`
+	// PayoutBalanceReserves is how much available balance, per currency in
+	// minor units, a payout must leave untouched.
+	PayoutBalanceReserves map[string]int64 `json:"payout_balance_reserves"`
+	// ProviderTimeout bounds each provider call; ProviderOperationTimeouts
+	// overrides it per operation, e.g. {"charge": "15s", "list_invoices": "5s"}.
+	// Zero keeps the 20s default.
+	ProviderTimeout           Duration            `json:"provider_timeout"`
+	ProviderOperationTimeouts map[string]Duration `json:"provider_operation_timeouts"`
 }
 
 type ServerConfig struct {
-	Port           string        `json:"port"`
-	ReadTimeout    time.Duration `json:"read_timeout"`
-	WriteTimeout   time.Duration `json:"write_timeout"`
-	IdleTimeout    time.Duration `json:"idle_timeout"`
-	MaxHeaderBytes int           `json:"max_header_bytes"`
-	EnableTLS      bool          `json:"enable_tls"`
-	TLSCertFile    string        `json:"tls_cert_file"`
-	TLSKeyFile     string        `json:"tls_key_file"`
+	Port            string        `json:"port"`
+	ReadTimeout     time.Duration `json:"read_timeout"`
+	WriteTimeout    time.Duration `json:"write_timeout"`
+	IdleTimeout     time.Duration `json:"idle_timeout"`
+	RequestTimeout  time.Duration `json:"request_timeout"`
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+	DrainDelay      time.Duration `json:"drain_delay"`
+	MaxHeaderBytes  int           `json:"max_header_bytes"`
+	MaxBodyBytes    int64         `json:"max_body_bytes"`
+	EnableTLS       bool          `json:"enable_tls"`
+	TLSCertFile     string        `json:"tls_cert_file"`
+	TLSKeyFile      string        `json:"tls_key_file"`
 }
 
 type RedisConfig struct {
@@ -95,13 +323,33 @@ type RedisConfig struct {
 }
 
 type SecurityConfig struct {
-	JWTSecret        string        `json:"jwt_secret"`
-	JWTExpiration    time.Duration `json:"jwt_expiration"`
-	EncryptionKey    string        `json:"encryption_key"`
-	WebhookSecret    string        `json:"webhook_secret"`
-	RateLimitEnabled bool          `json:"rate_limit_enabled"`
-	RateLimitRPS     float64       `json:"rate_limit_rps"`
-	RateLimitBurst   int           `json:"rate_limit_burst"`
+	JWTSecret              string                    `json:"jwt_secret"`
+	JWTExpiration          time.Duration             `json:"jwt_expiration"`
+	RefreshTokenExpiration time.Duration             `json:"refresh_token_expiration"`
+	EncryptionKey          string                    `json:"encryption_key"`
+	RetiredKeys            []string                  `json:"retired_encryption_keys"`
+	BlindIndexKey          string                    `json:"blind_index_key"`
+	WebhookSecret          string                    `json:"webhook_secret"`
+	RateLimitEnabled       bool                      `json:"rate_limit_enabled"`
+	RateLimitRPS           float64                   `json:"rate_limit_rps"`
+	RateLimitBurst         int                       `json:"rate_limit_burst"`
+	RouteRateLimits        map[string]RouteRateLimit `json:"route_rate_limits"`
+	AuditRedactPaths       []string                  `json:"audit_redact_paths"`
+	AuditMaxBodyBytes      int                       `json:"audit_max_body_bytes"`
+	// LogRedactKeys are masked in provider request and response bodies on
+	// top of the built-in list before they are logged.
+	LogRedactKeys []string `json:"log_redact_keys"`
+	// TenantKeys encrypts each tenant's PII under its own data key, wrapped
+	// by EncryptionKey. Once on, it must stay on for that data to be read.
+	TenantKeys bool `json:"tenant_encryption_keys"`
+}
+
+// RouteRateLimit caps one mux route template, e.g. "/v1/fraud/analyze", per
+// tenant on top of the tier limit. Entries override the built-in route
+// defaults; a zero burst removes the route's limit.
+type RouteRateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
 }
 
 type MonitoringConfig struct {
@@ -111,6 +359,19 @@ type MonitoringConfig struct {
 	AlertingEnabled bool   `json:"alerting_enabled"`
 	LogLevel        string `json:"log_level"`
 	LogFormat       string `json:"log_format"`
+	EnableTracing   bool   `json:"enable_tracing"`
+	TracingEndpoint string `json:"tracing_endpoint"`
+}
+
+// EventsConfig selects where inbound provider webhook events are forwarded.
+// Publisher is "none" (the default) or "redis", which appends them to Redis
+// streams named TopicPrefix + "." + provider. HandledTypes adds event types,
+// by provider, to those stored and forwarded; other types without a handler
+// are acknowledged and dropped.
+type EventsConfig struct {
+	Publisher    string              `json:"publisher"`
+	TopicPrefix  string              `json:"topic_prefix"`
+	HandledTypes map[string][]string `json:"handled_types"`
 }
 
 func CreateLoadConfig() (*Config, error) {
@@ -168,6 +429,10 @@ func (c *Config) loadFromEnv() {
 	if sslmode := os.Getenv("DB_SSLMODE"); sslmode != "" {
 		c.Database.SSLMode = sslmode
 	}
+	if os.Getenv("DB_FAILOVER_ENABLED") == "true" {
+		c.Database.Failover.Enabled = true
+	}
+	envInt("DB_FAILOVER_REPLICA", &c.Database.Failover.Replica)
 
 	if stripeSecret := os.Getenv("STRIPE_SECRET"); stripeSecret != "" {
 		c.Stripe.Secret = stripeSecret
@@ -178,6 +443,9 @@ func (c *Config) loadFromEnv() {
 	if stripeWebhook := os.Getenv("STRIPE_WEBHOOK_SECRET"); stripeWebhook != "" {
 		c.Stripe.WebhookSecret = stripeWebhook
 	}
+	if stripeMode := os.Getenv("STRIPE_MODE"); stripeMode != "" {
+		c.Stripe.Mode = stripeMode
+	}
 
 	if xenditSecret := os.Getenv("XENDIT_SECRET"); xenditSecret != "" {
 		c.Xendit.Secret = xenditSecret
@@ -188,6 +456,9 @@ func (c *Config) loadFromEnv() {
 	if xenditWebhook := os.Getenv("XENDIT_WEBHOOK_SECRET"); xenditWebhook != "" {
 		c.Xendit.WebhookSecret = xenditWebhook
 	}
+	if xenditMode := os.Getenv("XENDIT_MODE"); xenditMode != "" {
+		c.Xendit.Mode = xenditMode
+	}
 
 	if razorpayKeyID := os.Getenv("RAZORPAY_KEY_ID"); razorpayKeyID != "" {
 		c.Razorpay.KeyID = razorpayKeyID
@@ -198,6 +469,9 @@ func (c *Config) loadFromEnv() {
 	if razorpayWebhook := os.Getenv("RAZORPAY_WEBHOOK_SECRET"); razorpayWebhook != "" {
 		c.Razorpay.WebhookSecret = razorpayWebhook
 	}
+	if razorpayMode := os.Getenv("RAZORPAY_MODE"); razorpayMode != "" {
+		c.Razorpay.Mode = razorpayMode
+	}
 
 	if airwallexClientID := os.Getenv("AIRWALLEX_CLIENT_ID"); airwallexClientID != "" {
 		c.Airwallex.ClientID = airwallexClientID
@@ -211,14 +485,116 @@ func (c *Config) loadFromEnv() {
 	if airwallexSandbox := os.Getenv("AIRWALLEX_USE_SANDBOX"); airwallexSandbox == "true" {
 		c.Airwallex.UseSandbox = true
 	}
+	if airwallexMode := os.Getenv("AIRWALLEX_MODE"); airwallexMode != "" {
+		c.Airwallex.Mode = airwallexMode
+	}
 
 	if openaiKey := os.Getenv("OPENAI_API_KEY"); openaiKey != "" {
 		c.OpenAI.APIKey = openaiKey
 	}
+	if openaiModel := os.Getenv("OPENAI_MODEL"); openaiModel != "" {
+		c.OpenAI.Model = openaiModel
+	}
+	if openaiAPIType := os.Getenv("OPENAI_API_TYPE"); openaiAPIType != "" {
+		c.OpenAI.APIType = openaiAPIType
+	}
+	if openaiAPIVersion := os.Getenv("OPENAI_API_VERSION"); openaiAPIVersion != "" {
+		c.OpenAI.APIVersion = openaiAPIVersion
+	}
+	if openaiBaseURL := os.Getenv("OPENAI_BASE_URL"); openaiBaseURL != "" {
+		c.OpenAI.BaseURL = openaiBaseURL
+	}
+	if openaiTimeout := os.Getenv("OPENAI_REQUEST_TIMEOUT"); openaiTimeout != "" {
+		if d, err := time.ParseDuration(openaiTimeout); err == nil {
+			c.OpenAI.RequestTimeout = Duration(d)
+		}
+	}
+	envInt("HTTP_CLIENT_TIMEOUT_SECONDS", &c.HTTPClient.TimeoutSeconds)
+	envInt("HTTP_CLIENT_DIAL_TIMEOUT_SECONDS", &c.HTTPClient.DialTimeoutSeconds)
+	envInt("HTTP_CLIENT_TLS_HANDSHAKE_TIMEOUT_SECONDS", &c.HTTPClient.TLSHandshakeTimeoutSeconds)
+	envInt("HTTP_CLIENT_RESPONSE_HEADER_TIMEOUT_SECONDS", &c.HTTPClient.ResponseHeaderTimeoutSeconds)
+	envInt("HTTP_CLIENT_MAX_IDLE_CONNS", &c.HTTPClient.MaxIdleConns)
+	envInt("HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST", &c.HTTPClient.MaxIdleConnsPerHost)
+	envInt("HTTP_CLIENT_MAX_CONNS_PER_HOST", &c.HTTPClient.MaxConnsPerHost)
+	envInt("HTTP_CLIENT_IDLE_CONN_TIMEOUT_SECONDS", &c.HTTPClient.IdleConnTimeoutSeconds)
+
+	if os.Getenv("FRAUD_USE_DEFAULT_RULES") == "true" {
+		c.Fraud.UseDefaultRules = true
+	}
+	if geoIPPaths := os.Getenv("FRAUD_GEOIP_DATABASE_PATHS"); geoIPPaths != "" {
+		c.Fraud.GeoIPDatabasePaths = strings.Split(geoIPPaths, ",")
+	}
+	if os.Getenv("FRAUD_FAIL_OPEN") == "true" {
+		c.Fraud.FailOpen = true
+	}
+
+	if os.Getenv("PAYMENT_CAPTURE_PROVIDER_RESPONSES") == "true" {
+		c.Payment.CaptureProviderResponses = true
+	}
+	if os.Getenv("PAYMENT_CHARGE_FAILOVER") == "true" {
+		c.Payment.ChargeFailover = true
+	}
+	if providerOrder := os.Getenv("PAYMENT_PROVIDER_ORDER"); providerOrder != "" {
+		c.Payment.ProviderOrder = strings.Split(providerOrder, ",")
+	}
+	if providerTimeout := os.Getenv("PAYMENT_PROVIDER_TIMEOUT"); providerTimeout != "" {
+		if d, err := time.ParseDuration(providerTimeout); err == nil {
+			c.Payment.ProviderTimeout = Duration(d)
+		}
+	}
+
+	if monitoring := os.Getenv("MONITORING_ENABLED"); monitoring == "true" {
+		c.Monitoring.Enabled = true
+	}
+	if metricsPort := os.Getenv("METRICS_PORT"); metricsPort != "" {
+		c.Monitoring.MetricsPort = metricsPort
+	}
+	if tracing := os.Getenv("TRACING_ENABLED"); tracing == "true" {
+		c.Monitoring.EnableTracing = true
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		c.Monitoring.TracingEndpoint = endpoint
+	}
 
 	if serverPort := os.Getenv("SERVER_PORT"); serverPort != "" {
 		c.Server.Port = serverPort
 	}
+	if shutdownTimeout := os.Getenv("SERVER_SHUTDOWN_TIMEOUT"); shutdownTimeout != "" {
+		if d, err := time.ParseDuration(shutdownTimeout); err == nil {
+			c.Server.ShutdownTimeout = d
+		}
+	}
+	if requestTimeout := os.Getenv("SERVER_REQUEST_TIMEOUT"); requestTimeout != "" {
+		if d, err := time.ParseDuration(requestTimeout); err == nil {
+			c.Server.RequestTimeout = d
+		}
+	}
+	if drainDelay := os.Getenv("SERVER_DRAIN_DELAY"); drainDelay != "" {
+		if d, err := time.ParseDuration(drainDelay); err == nil {
+			c.Server.DrainDelay = d
+		}
+	}
+	if maxBody := os.Getenv("SERVER_MAX_BODY_BYTES"); maxBody != "" {
+		if n, err := strconv.ParseInt(maxBody, 10, 64); err == nil {
+			c.Server.MaxBodyBytes = n
+		}
+	}
+
+	if publisher := os.Getenv("EVENT_PUBLISHER"); publisher != "" {
+		c.Events.Publisher = publisher
+	}
+	if topicPrefix := os.Getenv("EVENT_TOPIC_PREFIX"); topicPrefix != "" {
+		c.Events.TopicPrefix = topicPrefix
+	}
+	if handledTypes := os.Getenv("EVENT_HANDLED_TYPES"); handledTypes != "" {
+		c.Events.HandledTypes = parseProviderEventTypes(handledTypes)
+	}
+
+	if idempotencyTTL := os.Getenv("IDEMPOTENCY_TTL"); idempotencyTTL != "" {
+		if d, err := time.ParseDuration(idempotencyTTL); err == nil {
+			c.Payment.IdempotencyTTL = d
+		}
+	}
 
 	if jwtSecret := os.Getenv("JWT_SECRET"); jwtSecret != "" {
 		c.Security.JWTSecret = jwtSecret
@@ -226,11 +602,45 @@ func (c *Config) loadFromEnv() {
 	if encryptionKey := os.Getenv("ENCRYPTION_KEY"); encryptionKey != "" {
 		c.Security.EncryptionKey = encryptionKey
 	}
+	if retiredKeys := os.Getenv("ENCRYPTION_RETIRED_KEYS"); retiredKeys != "" {
+		c.Security.RetiredKeys = strings.Split(retiredKeys, ",")
+	}
+	if os.Getenv("ENCRYPTION_TENANT_KEYS") == "true" {
+		c.Security.TenantKeys = true
+	}
+	if redactKeys := os.Getenv("LOG_REDACT_KEYS"); redactKeys != "" {
+		c.Security.LogRedactKeys = strings.Split(redactKeys, ",")
+	}
+	if blindIndexKey := os.Getenv("BLIND_INDEX_KEY"); blindIndexKey != "" {
+		c.Security.BlindIndexKey = blindIndexKey
+	}
 	if webhookSecret := os.Getenv("WEBHOOK_SECRET"); webhookSecret != "" {
 		c.Security.WebhookSecret = webhookSecret
 	}
 }
 
+// parseProviderEventTypes reads a comma-separated list of provider:event_type
+// pairs, skipping malformed entries.
+func parseProviderEventTypes(value string) map[string][]string {
+	types := make(map[string][]string)
+	for _, entry := range strings.Split(value, ",") {
+		provider, eventType, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || provider == "" || eventType == "" {
+			continue
+		}
+		types[provider] = append(types[provider], eventType)
+	}
+	return types
+}
+
+func envInt(key string, dst *int) {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			*dst = n
+		}
+	}
+}
+
 func (c *Config) setEnvironmentDefaults() {
 	switch c.Environment {
 	case "production":
@@ -252,6 +662,9 @@ func (c *Config) setDevelopmentDefaults() {
 	if c.Redis.TTL == 0 {
 		c.Redis.TTL = time.Hour
 	}
+	if c.Server.ShutdownTimeout == 0 {
+		c.Server.ShutdownTimeout = 5 * time.Second
+	}
 	if c.Security.RateLimitRPS == 0 {
 		c.Security.RateLimitRPS = 1000.0
 	}
@@ -270,6 +683,12 @@ func (c *Config) setStagingDefaults() {
 	if c.Redis.TTL == 0 {
 		c.Redis.TTL = 12 * time.Hour
 	}
+	if c.Server.ShutdownTimeout == 0 {
+		c.Server.ShutdownTimeout = 15 * time.Second
+	}
+	if c.Server.DrainDelay == 0 {
+		c.Server.DrainDelay = 2 * time.Second
+	}
 	if c.Security.RateLimitRPS == 0 {
 		c.Security.RateLimitRPS = 500.0
 	}
@@ -300,6 +719,12 @@ func (c *Config) setProductionDefaults() {
 	if c.Server.IdleTimeout == 0 {
 		c.Server.IdleTimeout = 60 * time.Second
 	}
+	if c.Server.ShutdownTimeout == 0 {
+		c.Server.ShutdownTimeout = 30 * time.Second
+	}
+	if c.Server.DrainDelay == 0 {
+		c.Server.DrainDelay = 5 * time.Second
+	}
 	if c.Redis.TTL == 0 {
 		c.Redis.TTL = 24 * time.Hour
 	}
@@ -333,6 +758,9 @@ func (c *Config) Validate() error {
 	if c.Database.DBName == "" {
 		return fmt.Errorf("database name is required")
 	}
+	if c.Database.Failover.Enabled && (c.Database.Failover.Replica < 0 || c.Database.Failover.Replica >= len(c.Database.ReplicaDSNs)) {
+		return fmt.Errorf("database failover replica %d is not one of the %d replica_dsns", c.Database.Failover.Replica, len(c.Database.ReplicaDSNs))
+	}
 	if c.Stripe.Secret == "" {
 		return fmt.Errorf("stripe secret key is required")
 	}
@@ -342,6 +770,24 @@ func (c *Config) Validate() error {
 	if c.Server.Port == "" {
 		return fmt.Errorf("server port is required")
 	}
+	if err := validateProviderMode("stripe", c.Stripe.Mode, c.Stripe.Secret, stripeTestKeyPrefixes...); err != nil {
+		return err
+	}
+	if err := validateProviderMode("xendit", c.Xendit.Mode, c.Xendit.Secret, xenditTestKeyPrefixes...); err != nil {
+		return err
+	}
+	if err := validateProviderMode("razorpay", c.Razorpay.Mode, c.Razorpay.KeyID, razorpayTestKeyPrefixes...); err != nil {
+		return err
+	}
+	if err := validateProviderMode("airwallex", c.Airwallex.Mode, c.Airwallex.APIKey); err != nil {
+		return err
+	}
+	if c.OpenAI.APIType != "" && c.OpenAI.APIType != "openai" && c.OpenAI.APIType != "azure" {
+		return fmt.Errorf("openai api_type must be \"openai\" or \"azure\"")
+	}
+	if c.Events.Publisher != "" && c.Events.Publisher != "none" && c.Events.Publisher != "redis" {
+		return fmt.Errorf("events publisher must be \"none\" or \"redis\"")
+	}
 	return nil
 }
 
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestOpenAIRequestTimeoutParsesDurationStrings(t *testing.T) {
+	var cfg OpenAIConfig
+	if err := json.Unmarshal([]byte(`{"request_timeout":"45s"}`), &cfg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if time.Duration(cfg.RequestTimeout) != 45*time.Second {
+		t.Fatalf("expected 45s, got %s", time.Duration(cfg.RequestTimeout))
+	}
+
+	if err := json.Unmarshal([]byte(`{"request_timeout":1000000000}`), &cfg); err != nil {
+		t.Fatalf("unmarshal numeric: %v", err)
+	}
+	if time.Duration(cfg.RequestTimeout) != time.Second {
+		t.Fatalf("expected numeric value to be read as nanoseconds, got %s", time.Duration(cfg.RequestTimeout))
+	}
+
+	if err := json.Unmarshal([]byte(`{"request_timeout":"soon"}`), &cfg); err == nil {
+		t.Fatal("expected an invalid duration to be rejected")
+	}
+}
@@ -0,0 +1,260 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/metrics"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	primaryNode = "primary"
+
+	defaultFailureThreshold = 3
+	defaultFailoverCooldown = 5 * time.Minute
+)
+
+var (
+	ErrFailoverNotConfigured = errors.New("no replica is designated for failover")
+	ErrPrimaryUnavailable    = errors.New("primary database is unreachable")
+	ErrPrimaryNotFenced      = errors.New("primary database cannot take writes back")
+)
+
+// FailoverConfig controls moving writes to a replica when the primary stops
+// answering health checks.
+type FailoverConfig struct {
+	// Enabled turns on automatic failover. Failover and Failback work
+	// without it, as a manual override.
+	Enabled bool
+	// Replica is the index into the replica DSNs of the replica promoted
+	// when the primary fails.
+	Replica int
+	// FailureThreshold is how many health checks in a row the primary must
+	// fail before writes move. Defaults to 3.
+	FailureThreshold int
+	// Cooldown is the minimum time between a failover or failback and the
+	// next automatic failover, so a flapping primary does not bounce writes.
+	// Defaults to 5 minutes.
+	Cooldown time.Duration
+	// Promote makes the replica writable. Defaults to pg_promote().
+	Promote func(ctx context.Context, replica *gorm.DB) error
+	// Fence checks that the restored primary can take writes back before a
+	// failback. Defaults to requiring that it is not in recovery, so writes
+	// never return to a node still running as a standby.
+	Fence func(ctx context.Context, primary *gorm.DB) error
+}
+
+func (c FailoverConfig) failureThreshold() int {
+	if c.FailureThreshold <= 0 {
+		return defaultFailureThreshold
+	}
+	return c.FailureThreshold
+}
+
+func (c FailoverConfig) cooldown() time.Duration {
+	if c.Cooldown <= 0 {
+		return defaultFailoverCooldown
+	}
+	return c.Cooldown
+}
+
+func promoteReplica(ctx context.Context, replica *gorm.DB) error {
+	return replica.WithContext(ctx).Exec("SELECT pg_promote()").Error
+}
+
+func fencePrimary(ctx context.Context, primary *gorm.DB) error {
+	var inRecovery bool
+	if err := primary.WithContext(ctx).Raw("SELECT pg_is_in_recovery()").Scan(&inRecovery).Error; err != nil {
+		return fmt.Errorf("%w: %v", ErrPrimaryNotFenced, err)
+	}
+	if inRecovery {
+		return fmt.Errorf("%w: it is in recovery", ErrPrimaryNotFenced)
+	}
+	return nil
+}
+
+func replicaNode(i int) string {
+	return fmt.Sprintf("replica_%d", i)
+}
+
+// writeRouter is the connection the writer handle runs on. Every statement
+// goes to the node that currently takes writes, so stores built on
+// GetPrimary follow a failover without being rebuilt.
+type writeRouter struct {
+	current atomic.Pointer[sql.DB]
+}
+
+func (r *writeRouter) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return r.current.Load().PrepareContext(ctx, query)
+}
+
+func (r *writeRouter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.current.Load().ExecContext(ctx, query, args...)
+}
+
+func (r *writeRouter) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.current.Load().QueryContext(ctx, query, args...)
+}
+
+func (r *writeRouter) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.current.Load().QueryRowContext(ctx, query, args...)
+}
+
+func (r *writeRouter) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return r.current.Load().BeginTx(ctx, opts)
+}
+
+// GetDBConn lets gorm's DB() return the node that currently takes writes.
+func (r *writeRouter) GetDBConn() (*sql.DB, error) {
+	return r.current.Load(), nil
+}
+
+func (r *writeRouter) Ping() error {
+	return r.current.Load().Ping()
+}
+
+// initWriter points the writer handle at the primary and checks that the
+// designated failover replica exists.
+func (p *ConnectionPool) initWriter() error {
+	if p.config.Failover.Enabled {
+		if i := p.config.Failover.Replica; i < 0 || i >= len(p.replicas) {
+			return fmt.Errorf("failover replica %d is not configured: %d replicas", i, len(p.replicas))
+		}
+	}
+
+	sqlDB, err := p.primary.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get primary database instance: %v", err)
+	}
+	p.router = &writeRouter{}
+	p.router.current.Store(sqlDB)
+
+	writer, err := gorm.Open(postgres.New(postgres.Config{Conn: p.router}), &gorm.Config{
+		Logger: p.primary.Logger,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open write connection: %v", err)
+	}
+	p.writer = writer
+	p.writable = primaryNode
+	return nil
+}
+
+// recordPrimaryHealth counts consecutive failed primary checks and reports
+// whether writes should fail over now. Callers hold p.mu.
+func (p *ConnectionPool) recordPrimaryHealth() bool {
+	if p.health[primaryNode] {
+		p.primaryFailures = 0
+		return false
+	}
+	p.primaryFailures++
+
+	failover := p.config.Failover
+	if !failover.Enabled || p.writable != primaryNode {
+		return false
+	}
+	if p.primaryFailures < failover.failureThreshold() {
+		return false
+	}
+	if !p.lastFailover.IsZero() && time.Since(p.lastFailover) < failover.cooldown() {
+		return false
+	}
+	return p.health[replicaNode(failover.Replica)]
+}
+
+// Failover promotes the designated replica and moves writes to it now,
+// whatever the primary's health and the cool-down. It is the manual
+// override for automatic failover.
+func (p *ConnectionPool) Failover(ctx context.Context) error {
+	return p.failover(ctx, "manual failover", true)
+}
+
+func (p *ConnectionPool) failover(ctx context.Context, reason string, manual bool) error {
+	p.failoverMu.Lock()
+	defer p.failoverMu.Unlock()
+
+	index := p.config.Failover.Replica
+	if index < 0 || index >= len(p.replicas) {
+		return ErrFailoverNotConfigured
+	}
+	to := replicaNode(index)
+
+	p.mu.RLock()
+	from := p.writable
+	p.mu.RUnlock()
+	if from == to {
+		return nil
+	}
+
+	replica := p.replicas[index]
+	promote := p.config.Failover.Promote
+	if promote == nil {
+		promote = promoteReplica
+	}
+	if err := promote(ctx, replica); err != nil {
+		return fmt.Errorf("failed to promote %s: %w", to, err)
+	}
+
+	sqlDB, err := replica.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get %s database instance: %w", to, err)
+	}
+	p.switchWrites(sqlDB, from, to, reason, manual)
+	return nil
+}
+
+// Failback moves writes back to the original primary, for when it has been
+// restored. The primary must answer a ping and pass the Fence check first.
+func (p *ConnectionPool) Failback(ctx context.Context) error {
+	p.failoverMu.Lock()
+	defer p.failoverMu.Unlock()
+
+	p.mu.RLock()
+	from := p.writable
+	p.mu.RUnlock()
+	if from == primaryNode {
+		return nil
+	}
+
+	if !pingNode(p.primary) {
+		return ErrPrimaryUnavailable
+	}
+	fence := p.config.Failover.Fence
+	if fence == nil {
+		fence = fencePrimary
+	}
+	if err := fence(ctx, p.primary); err != nil {
+		return err
+	}
+	sqlDB, err := p.primary.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get primary database instance: %w", err)
+	}
+	p.switchWrites(sqlDB, from, primaryNode, "manual failback", true)
+	return nil
+}
+
+// switchWrites points the writer at sqlDB and raises the failover alert.
+func (p *ConnectionPool) switchWrites(sqlDB *sql.DB, from, to, reason string, manual bool) {
+	p.router.current.Store(sqlDB)
+
+	p.mu.Lock()
+	p.writable = to
+	p.lastFailover = time.Now()
+	p.primaryFailures = 0
+	p.mu.Unlock()
+
+	trigger := "automatic"
+	if manual {
+		trigger = "manual"
+	}
+	metrics.DBFailoversTotal.Inc(from, to, trigger)
+	log.Printf("ALERT: database writes moved from %s to %s (%s): %s", from, to, trigger, reason)
+}
@@ -0,0 +1,281 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/metrics"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeNode is a database server the fake driver connects to by DSN. A down
+// node refuses new connections and fails pings on open ones.
+type fakeNode struct {
+	mu    sync.Mutex
+	down  bool
+	execs []string
+}
+
+func (n *fakeNode) setDown(down bool) {
+	n.mu.Lock()
+	n.down = down
+	n.mu.Unlock()
+}
+
+func (n *fakeNode) isDown() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.down
+}
+
+func (n *fakeNode) executed() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.execs...)
+}
+
+var (
+	fakeNodesMu sync.Mutex
+	fakeNodes   = map[string]*fakeNode{}
+)
+
+func init() {
+	sql.Register("failover_fake", fakeDriver{})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fakeNodesMu.Lock()
+	node := fakeNodes[dsn]
+	fakeNodesMu.Unlock()
+	if node.isDown() {
+		return nil, errors.New("connection refused")
+	}
+	return &fakeConn{node: node}, nil
+}
+
+type fakeConn struct {
+	node *fakeNode
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) Ping(context.Context) error {
+	if c.node.isDown() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if c.node.isDown() {
+		return nil, driver.ErrBadConn
+	}
+	c.node.mu.Lock()
+	c.node.execs = append(c.node.execs, query)
+	c.node.mu.Unlock()
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func openFakeNode(t *testing.T, dsn string) (*gorm.DB, *fakeNode) {
+	t.Helper()
+	node := &fakeNode{}
+	fakeNodesMu.Lock()
+	fakeNodes[dsn] = node
+	fakeNodesMu.Unlock()
+
+	sqlDB, err := sql.Open("failover_fake", dsn)
+	if err != nil {
+		t.Fatalf("open %s: %v", dsn, err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	database, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open gorm on %s: %v", dsn, err)
+	}
+	return database, node
+}
+
+func TestPrimaryFailurePromotesReplicaAndRoutesWrites(t *testing.T) {
+	primary, primaryNode := openFakeNode(t, t.Name()+"/primary")
+	replica, replicaNode := openFakeNode(t, t.Name()+"/replica")
+	promotions := 0
+	pool := &ConnectionPool{
+		primary:  primary,
+		replicas: []*gorm.DB{replica},
+		config: PoolConfig{Failover: FailoverConfig{
+			Enabled:          true,
+			FailureThreshold: 2,
+			Fence:            func(context.Context, *gorm.DB) error { return nil },
+			Promote: func(context.Context, *gorm.DB) error {
+				promotions++
+				return nil
+			},
+		}},
+		health: map[string]bool{"primary": true, "replica_0": true},
+	}
+	if err := pool.initWriter(); err != nil {
+		t.Fatalf("init writer: %v", err)
+	}
+
+	writer := pool.GetPrimary()
+	alerts := metrics.DBFailoversTotal.Value("primary", "replica_0", "automatic")
+
+	if err := writer.Exec("UPDATE payments SET status = 'succeeded'").Error; err != nil {
+		t.Fatalf("write before failover: %v", err)
+	}
+	if len(primaryNode.executed()) != 1 {
+		t.Fatalf("expected the write on the primary, got %v", primaryNode.executed())
+	}
+
+	primaryNode.setDown(true)
+	pool.checkHealth()
+	if got := pool.GetStats().WritableNode; got != "primary" || promotions != 0 {
+		t.Fatalf("expected no failover below the threshold, writable=%s promotions=%d", got, promotions)
+	}
+
+	pool.checkHealth()
+	if got := pool.GetStats().WritableNode; got != "replica_0" || promotions != 1 {
+		t.Fatalf("expected the replica promoted, writable=%s promotions=%d", got, promotions)
+	}
+	if got := metrics.DBFailoversTotal.Value("primary", "replica_0", "automatic"); got != alerts+1 {
+		t.Fatalf("expected one failover alert, counter went from %v to %v", alerts, got)
+	}
+
+	if pool.GetPrimary() != writer {
+		t.Fatal("expected GetPrimary to keep returning the same handle")
+	}
+	replicaDB, _ := pool.replicas[0].DB()
+	if sqlDB, err := writer.DB(); err != nil || sqlDB != replicaDB {
+		t.Fatalf("expected the writer's connection pool to be the promoted replica's, got %p, %v", sqlDB, err)
+	}
+	if err := writer.Exec("UPDATE payments SET status = 'refunded'").Error; err != nil {
+		t.Fatalf("write after failover: %v", err)
+	}
+	err := pool.WithTransaction(context.Background(), func(_ context.Context, tx *gorm.DB) error {
+		return tx.Exec("INSERT INTO audit_logs (action) VALUES ('refund')").Error
+	})
+	if err != nil {
+		t.Fatalf("transaction after failover: %v", err)
+	}
+	if got := replicaNode.executed(); len(got) != 2 {
+		t.Fatalf("expected both writes on the promoted replica, got %v", got)
+	}
+	if len(primaryNode.executed()) != 1 {
+		t.Fatalf("expected nothing more on the failed primary, got %v", primaryNode.executed())
+	}
+}
+
+func TestFailoverCooldownAndManualOverride(t *testing.T) {
+	primary, primaryNode := openFakeNode(t, t.Name()+"/primary")
+	replica, replicaNode := openFakeNode(t, t.Name()+"/replica")
+	promotions := 0
+	pool := &ConnectionPool{
+		primary:  primary,
+		replicas: []*gorm.DB{replica},
+		config: PoolConfig{Failover: FailoverConfig{
+			Enabled:          true,
+			FailureThreshold: 1,
+			Cooldown:         time.Hour,
+			Fence:            func(context.Context, *gorm.DB) error { return nil },
+			Promote: func(context.Context, *gorm.DB) error {
+				promotions++
+				return nil
+			},
+		}},
+		health: map[string]bool{"primary": true, "replica_0": true},
+	}
+	if err := pool.initWriter(); err != nil {
+		t.Fatalf("init writer: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := pool.Failover(ctx); err != nil {
+		t.Fatalf("manual failover: %v", err)
+	}
+	if got := pool.GetStats().WritableNode; got != "replica_0" {
+		t.Fatalf("expected writes on the replica after a manual failover, got %s", got)
+	}
+
+	if err := pool.Failback(ctx); err != nil {
+		t.Fatalf("failback: %v", err)
+	}
+	if err := pool.GetPrimary().Exec("UPDATE payments SET status = 'succeeded'").Error; err != nil {
+		t.Fatalf("write after failback: %v", err)
+	}
+	if len(primaryNode.executed()) != 1 || len(replicaNode.executed()) != 0 {
+		t.Fatalf("expected the write back on the primary, primary=%v replica=%v", primaryNode.executed(), replicaNode.executed())
+	}
+
+	primaryNode.setDown(true)
+	pool.checkHealth()
+	pool.checkHealth()
+	if got := pool.GetStats().WritableNode; got != "primary" || promotions != 1 {
+		t.Fatalf("expected the cool-down to hold off another failover, writable=%s promotions=%d", got, promotions)
+	}
+	if err := pool.Failback(ctx); err != nil {
+		t.Fatalf("failback while already on the primary: %v", err)
+	}
+
+	if err := pool.Failover(ctx); err != nil {
+		t.Fatalf("manual failover during the cool-down: %v", err)
+	}
+	if got := pool.GetStats().WritableNode; got != "replica_0" || promotions != 2 {
+		t.Fatalf("expected a manual failover to skip the cool-down, writable=%s promotions=%d", got, promotions)
+	}
+	if err := pool.Failback(ctx); !errors.Is(err, ErrPrimaryUnavailable) {
+		t.Fatalf("expected failback to an unreachable primary to fail, got %v", err)
+	}
+}
+
+func TestFailbackRequiresFencedPrimary(t *testing.T) {
+	fenced := errors.New("primary is in recovery")
+	primary, _ := openFakeNode(t, t.Name()+"/primary")
+	replica, _ := openFakeNode(t, t.Name()+"/replica")
+	pool := &ConnectionPool{
+		primary:  primary,
+		replicas: []*gorm.DB{replica},
+		config: PoolConfig{Failover: FailoverConfig{
+			Promote: func(context.Context, *gorm.DB) error { return nil },
+			Fence:   func(context.Context, *gorm.DB) error { return fenced },
+		}},
+		health: map[string]bool{"primary": true, "replica_0": true},
+	}
+	if err := pool.initWriter(); err != nil {
+		t.Fatalf("init writer: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := pool.Failover(ctx); err != nil {
+		t.Fatalf("manual failover: %v", err)
+	}
+	if err := pool.Failback(ctx); !errors.Is(err, fenced) {
+		t.Fatalf("expected failback to be refused by the fence, got %v", err)
+	}
+	if got := pool.GetStats().WritableNode; got != "replica_0" {
+		t.Fatalf("expected writes to stay on the replica, got %s", got)
+	}
+}
@@ -0,0 +1,5 @@
+-- Platform fee columns for marketplace charges
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS application_fee_amount BIGINT DEFAULT 0;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS on_behalf_of VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_payments_on_behalf_of ON payments(on_behalf_of);
@@ -0,0 +1,2 @@
+-- Provider subscription item used for metered usage reporting
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS subscription_item_id VARCHAR(255);
@@ -0,0 +1,2 @@
+-- Rate limit tier assigned per tenant
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS rate_limit_tier VARCHAR(50) DEFAULT 'default';
@@ -0,0 +1,10 @@
+-- Widen PII columns to hold versioned ciphertext and add a blind index for email lookups
+ALTER TABLE customers ALTER COLUMN email TYPE TEXT;
+ALTER TABLE customers ALTER COLUMN phone TYPE TEXT;
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS email_hash VARCHAR(64);
+
+DROP INDEX IF EXISTS idx_customers_email;
+CREATE INDEX IF NOT EXISTS idx_customers_email_hash ON customers(email_hash);
+
+ALTER TABLE payment_methods ALTER COLUMN last4 TYPE TEXT;
+ALTER TABLE payment_methods ALTER COLUMN brand TYPE TEXT;
@@ -0,0 +1,2 @@
+-- Redacted response payloads for dispute investigations
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS response_body JSONB;
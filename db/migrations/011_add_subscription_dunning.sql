@@ -0,0 +1,6 @@
+-- Dunning state for past-due subscriptions
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS latest_invoice_id VARCHAR(255);
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS dunning_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS next_dunning_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_next_dunning_at ON subscriptions(next_dunning_at) WHERE status = 'past_due';
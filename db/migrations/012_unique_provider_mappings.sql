@@ -0,0 +1,11 @@
+-- Keep one mapping per entity so retried charges cannot create duplicates
+DELETE FROM provider_mappings a
+    USING provider_mappings b
+    WHERE a.entity_id = b.entity_id
+      AND a.entity_type = b.entity_type
+      AND (a.created_at, a.id) > (b.created_at, b.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS provider_mappings_entity_id_entity_type_key
+    ON provider_mappings(entity_id, entity_type);
+
+DROP INDEX IF EXISTS idx_provider_mappings_entity;
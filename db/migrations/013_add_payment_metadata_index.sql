@@ -0,0 +1,2 @@
+-- Metadata search uses jsonb containment (metadata @> '{"key":"value"}')
+CREATE INDEX IF NOT EXISTS idx_payments_metadata ON payments USING GIN (metadata jsonb_path_ops);
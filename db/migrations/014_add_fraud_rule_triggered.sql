@@ -0,0 +1,2 @@
+-- Name of the hard fraud rule that blocked the transaction, if any
+ALTER TABLE fraud_analysis_results ADD COLUMN IF NOT EXISTS rule_triggered VARCHAR(100);
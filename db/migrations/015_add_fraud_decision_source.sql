@@ -0,0 +1,4 @@
+-- Where the fraud decision came from (ai, fallback or rule) and the IP risk category
+ALTER TABLE fraud_analysis_results ADD COLUMN IF NOT EXISTS decision_source VARCHAR(20);
+ALTER TABLE fraud_analysis_results ADD COLUMN IF NOT EXISTS ip_risk VARCHAR(50);
+CREATE INDEX IF NOT EXISTS idx_fraud_analysis_results_decision_source ON fraud_analysis_results(decision_source);
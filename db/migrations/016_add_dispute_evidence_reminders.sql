@@ -0,0 +1,6 @@
+-- Evidence deadline reminders and optional auto-submission of staged evidence
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS auto_submit_evidence BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS evidence_submitted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_disputes_due_by_pending_reminder ON disputes(due_by) WHERE status = 'open' AND reminder_sent_at IS NULL;
@@ -0,0 +1,5 @@
+-- Link stored disputes to provider disputes so webhook status changes can be applied
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS provider_name VARCHAR(50);
+ALTER TABLE disputes ADD COLUMN IF NOT EXISTS provider_dispute_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_disputes_provider_dispute_id ON disputes(provider_dispute_id);
@@ -0,0 +1,3 @@
+-- Statement descriptor shown on the cardholder's bill, separate from the internal description
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS statement_descriptor VARCHAR(32);
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS statement_descriptor_suffix VARCHAR(32);
@@ -0,0 +1,3 @@
+-- Webhooks identify entities by the provider's ID, so support the reverse lookup
+CREATE INDEX IF NOT EXISTS idx_provider_mappings_provider_entity
+    ON provider_mappings(provider_name, provider_entity_id, entity_type);
@@ -0,0 +1,2 @@
+-- Scheduled end for subscriptions canceled at period end; canceled_at stays unset until then
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS cancel_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,2 @@
+-- Signing secrets of each tenant's own provider accounts, keyed by provider name
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS provider_webhook_secrets JSONB DEFAULT '{}';
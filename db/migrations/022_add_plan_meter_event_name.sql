@@ -0,0 +1,2 @@
+-- Billing meter event name that usage for a metered plan is reported under
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS meter_event_name VARCHAR(100);
@@ -0,0 +1,13 @@
+-- Raw provider responses captured for support debugging when capture is enabled
+CREATE TABLE IF NOT EXISTS provider_responses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id VARCHAR(255) NOT NULL,
+    tenant_id UUID REFERENCES tenants(id),
+    provider VARCHAR(50) NOT NULL,
+    operation VARCHAR(50) NOT NULL,
+    raw_body TEXT NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_provider_responses_payment ON provider_responses(payment_id, received_at);
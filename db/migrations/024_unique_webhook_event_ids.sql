@@ -0,0 +1,13 @@
+-- Keep one row per provider event so concurrent deliveries cannot both be processed
+DELETE FROM webhook_events a
+    USING webhook_events b
+    WHERE a.provider = b.provider
+      AND a.event_id = b.event_id
+      AND a.event_id <> ''
+      AND (a.created_at, a.id) > (b.created_at, b.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_events_provider_event_id
+    ON webhook_events(provider, event_id)
+    WHERE event_id <> '';
+
+DROP INDEX IF EXISTS idx_webhook_events_event_id;
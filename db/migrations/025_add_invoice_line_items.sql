@@ -0,0 +1,30 @@
+-- Invoices keep the line items they were billed with; the amount is their sum
+CREATE TABLE IF NOT EXISTS invoices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id VARCHAR(255),
+    external_id VARCHAR(255),
+    provider_id VARCHAR(255),
+    provider_name VARCHAR(50) NOT NULL,
+    customer_id VARCHAR(255),
+    customer_email VARCHAR(255),
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending',
+    description TEXT,
+    invoice_url TEXT,
+    due_date TIMESTAMP WITH TIME ZONE,
+    paid_at TIMESTAMP WITH TIME ZONE,
+    success_redirect_url TEXT,
+    failure_redirect_url TEXT,
+    payment_methods TEXT[],
+    metadata JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS line_items JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_invoices_tenant_id ON invoices(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_external_id ON invoices(external_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_provider_id ON invoices(provider_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_customer_id ON invoices(customer_id);
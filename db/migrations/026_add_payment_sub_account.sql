@@ -0,0 +1,4 @@
+-- Xendit sub-account (for-user-id) a platform charge was made on behalf of
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS sub_account_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_payments_sub_account_id ON payments(sub_account_id);
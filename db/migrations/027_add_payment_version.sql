@@ -0,0 +1,2 @@
+-- Optimistic locking counter; each payment update must match the version it read
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,26 @@
+-- Provider-hosted checkout pages (Stripe Checkout, Xendit invoice URLs)
+CREATE TABLE IF NOT EXISTS checkout_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id VARCHAR(255),
+    customer_id VARCHAR(255),
+    provider_name VARCHAR(50) NOT NULL,
+    provider_session_id VARCHAR(255),
+    amount BIGINT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    url TEXT,
+    success_url TEXT,
+    cancel_url TEXT,
+    provider_payment_id VARCHAR(255),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    metadata JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_checkout_sessions_tenant_id ON checkout_sessions(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_checkout_sessions_customer_id ON checkout_sessions(customer_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_checkout_sessions_provider_session
+    ON checkout_sessions(provider_name, provider_session_id);
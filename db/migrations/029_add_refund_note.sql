@@ -0,0 +1,2 @@
+-- Free-text note kept alongside the canonical refund reason
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS note TEXT;
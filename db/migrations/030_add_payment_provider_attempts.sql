@@ -0,0 +1,2 @@
+-- Providers a charge was tried on, in order, when charge failover is enabled
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS provider_attempts JSONB;
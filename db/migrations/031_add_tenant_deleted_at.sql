@@ -0,0 +1,4 @@
+-- Tenants are soft-deleted so their payments and audit logs stay intact
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_tenants_deleted_at ON tenants(deleted_at);
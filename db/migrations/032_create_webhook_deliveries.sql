@@ -0,0 +1,18 @@
+-- Every attempt to deliver an outbound webhook to a tenant
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id VARCHAR(255) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    payload JSONB,
+    status VARCHAR(50) NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    response_body TEXT,
+    error TEXT,
+    attempt INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_tenant_created ON webhook_deliveries(tenant_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event_id ON webhook_deliveries(event_id);
@@ -0,0 +1,5 @@
+-- Scope customers to the tenant that created them so they can be listed per tenant
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_customers_tenant_id ON customers(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_customers_tenant_created ON customers(tenant_id, created_at DESC);
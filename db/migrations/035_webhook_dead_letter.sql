@@ -0,0 +1,11 @@
+-- Webhook events that exhaust their retries move to a dead-letter state for
+-- manual inspection and reprocessing
+ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE webhook_events
+    SET status = 'dead_letter', dead_lettered_at = COALESCE(last_attempt_at, updated_at)
+    WHERE status = 'failed';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_dead_letter
+    ON webhook_events(dead_lettered_at DESC)
+    WHERE status = 'dead_letter';
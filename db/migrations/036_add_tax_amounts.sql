@@ -0,0 +1,7 @@
+-- Tax calculated for charges and invoices requested with automatic_tax
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS tax_amount BIGINT DEFAULT 0;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS tax_breakdown JSONB;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS tax_calculation_id VARCHAR(255);
+
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS tax_amount BIGINT DEFAULT 0;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS tax_breakdown JSONB;
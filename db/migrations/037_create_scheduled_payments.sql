@@ -0,0 +1,18 @@
+-- Charges held until a future time and then run through the normal charge path
+CREATE TABLE IF NOT EXISTS scheduled_payments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id VARCHAR(255),
+    request JSONB NOT NULL,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled',
+    payment_id VARCHAR(255),
+    error_message TEXT,
+    executed_at TIMESTAMP WITH TIME ZONE,
+    canceled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_payments_tenant_id ON scheduled_payments(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_payments_due
+    ON scheduled_payments(run_at) WHERE status IN ('scheduled', 'processing');
@@ -0,0 +1,5 @@
+-- Provider fingerprint identifying the underlying card or account, shared by
+-- every payment method created from it, used to spot reuse across customers
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS fingerprint VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_payment_methods_fingerprint ON payment_methods(fingerprint);
@@ -0,0 +1,8 @@
+-- Inbound webhook events whose forwarding to the event publisher failed are
+-- flagged for the publish retry job
+ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS publish_pending BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS publish_error TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_publish_pending
+    ON webhook_events(created_at)
+    WHERE publish_pending;
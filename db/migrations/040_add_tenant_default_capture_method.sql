@@ -0,0 +1,3 @@
+-- Capture method applied to a tenant's charges that do not set one; empty
+-- means automatic
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS default_capture_method VARCHAR(20) NOT NULL DEFAULT '';
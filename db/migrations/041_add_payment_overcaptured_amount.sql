@@ -0,0 +1,3 @@
+-- How much was captured above the authorized amount on providers that allow
+-- overcapture
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS overcaptured_amount BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Which provider took the charge and why it was selected
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS routing_explanation JSONB;
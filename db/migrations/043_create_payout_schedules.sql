@@ -0,0 +1,39 @@
+-- Recurring sweeps of a tenant's available balance to one destination
+CREATE TABLE IF NOT EXISTS payout_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id VARCHAR(255),
+    currency VARCHAR(3) NOT NULL,
+    min_amount BIGINT NOT NULL DEFAULT 0,
+    frequency VARCHAR(20) NOT NULL,
+    destination_type VARCHAR(20) NOT NULL,
+    destination_account VARCHAR(255) NOT NULL,
+    destination_name VARCHAR(255),
+    destination_bank VARCHAR(255),
+    destination_channel VARCHAR(100),
+    description TEXT,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payout_schedules_tenant_id ON payout_schedules(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_payout_schedules_due
+    ON payout_schedules(next_run_at) WHERE active;
+
+CREATE TABLE IF NOT EXISTS payout_schedule_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    schedule_id UUID NOT NULL REFERENCES payout_schedules(id) ON DELETE CASCADE,
+    tenant_id VARCHAR(255),
+    status VARCHAR(20) NOT NULL,
+    available_balance BIGINT NOT NULL DEFAULT 0,
+    amount BIGINT NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL,
+    payout_id VARCHAR(255),
+    reason TEXT,
+    ran_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_payout_schedule_runs_schedule_id
+    ON payout_schedule_runs(schedule_id, ran_at DESC);
@@ -0,0 +1,5 @@
+-- Payment session that saved the payment method for future use, linking a
+-- method saved through setup_future_usage back to the session that created it
+ALTER TABLE payment_methods ADD COLUMN IF NOT EXISTS payment_session_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_payment_methods_payment_session_id ON payment_methods(payment_session_id);
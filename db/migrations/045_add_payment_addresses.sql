@@ -0,0 +1,4 @@
+-- Billing and shipping addresses given with a charge, kept for fraud review
+-- and disputes
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS billing_address JSONB;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS shipping_address JSONB;
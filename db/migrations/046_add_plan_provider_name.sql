@@ -0,0 +1,3 @@
+-- Provider a plan was created on; updates and deletes are sent there while
+-- reads come from this table
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS provider_name VARCHAR(50);
@@ -0,0 +1,16 @@
+-- Per-tenant data encryption keys, wrapped by the master encryption key.
+-- Each tenant has at most one primary key; the rest only decrypt data not yet
+-- re-encrypted under it.
+CREATE TABLE IF NOT EXISTS tenant_data_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id VARCHAR(255) NOT NULL,
+    key_id VARCHAR(16) NOT NULL,
+    wrapped_key TEXT NOT NULL,
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_data_keys_tenant_key
+    ON tenant_data_keys(tenant_id, key_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_data_keys_primary
+    ON tenant_data_keys(tenant_id) WHERE is_primary;
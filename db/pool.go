@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
@@ -15,6 +16,8 @@ type contextKey string
 
 const txContextKey contextKey = "tx"
 
+const defaultHealthCheckInterval = 30 * time.Second
+
 type PoolConfig struct {
 	MaxOpenConns    int
 	MaxIdleConns    int
@@ -22,6 +25,10 @@ type PoolConfig struct {
 	ConnMaxIdleTime time.Duration
 	MaxRetries      int
 	RetryDelay      time.Duration
+	// HealthCheckInterval is how often every node is pinged. Defaults to 30
+	// seconds.
+	HealthCheckInterval time.Duration
+	Failover            FailoverConfig
 }
 
 type ConnectionPool struct {
@@ -30,6 +37,15 @@ type ConnectionPool struct {
 	config   PoolConfig
 	mu       sync.RWMutex
 	health   map[string]bool
+
+	// writer is the handle GetPrimary returns. Its statements go through
+	// router to whichever node currently takes writes.
+	writer          *gorm.DB
+	router          *writeRouter
+	writable        string
+	primaryFailures int
+	lastFailover    time.Time
+	failoverMu      sync.Mutex
 }
 
 func CreateNewConnectionPool(primaryDSN string, replicaDSNs []string, config PoolConfig) (*ConnectionPool, error) {
@@ -58,7 +74,7 @@ func CreateNewConnectionPool(primaryDSN string, replicaDSNs []string, config Poo
 	sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
 
 	pool.primary = primary
-	pool.health["primary"] = true
+	pool.health[primaryNode] = true
 
 	for i, replicaDSN := range replicaDSNs {
 		replica, err := gorm.Open(postgres.Open(replicaDSN), gormConfig)
@@ -77,7 +93,11 @@ func CreateNewConnectionPool(primaryDSN string, replicaDSNs []string, config Poo
 		replicaSQLDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
 
 		pool.replicas = append(pool.replicas, replica)
-		pool.health[fmt.Sprintf("replica_%d", i)] = true
+		pool.health[replicaNode(i)] = true
+	}
+
+	if err := pool.initWriter(); err != nil {
+		return nil, err
 	}
 
 	go pool.startHealthChecks()
@@ -85,8 +105,11 @@ func CreateNewConnectionPool(primaryDSN string, replicaDSNs []string, config Poo
 	return pool, nil
 }
 
+// GetPrimary returns the handle for writes. It stays valid across a
+// failover: statements issued through it go to the promoted replica once
+// writes have moved.
 func (p *ConnectionPool) GetPrimary() *gorm.DB {
-	return p.primary
+	return p.writer
 }
 
 func (p *ConnectionPool) GetReplica() (*gorm.DB, error) {
@@ -94,16 +117,16 @@ func (p *ConnectionPool) GetReplica() (*gorm.DB, error) {
 	defer p.mu.RUnlock()
 
 	for i, replica := range p.replicas {
-		if p.health[fmt.Sprintf("replica_%d", i)] {
+		if p.health[replicaNode(i)] {
 			return replica, nil
 		}
 	}
 
-	return p.primary, nil
+	return p.writer, nil
 }
 
 func (p *ConnectionPool) WithTransaction(ctx context.Context, fn func(context.Context, *gorm.DB) error) error {
-	return p.primary.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	return p.writer.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		txCtx := context.WithValue(ctx, txContextKey, tx)
 		return fn(txCtx, tx)
 	})
@@ -133,7 +156,11 @@ func (p *ConnectionPool) WithRetry(ctx context.Context, fn func() error) error {
 }
 
 func (p *ConnectionPool) startHealthChecks() {
-	ticker := time.NewTicker(30 * time.Second)
+	interval := p.config.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for range ticker.C {
@@ -141,34 +168,39 @@ func (p *ConnectionPool) startHealthChecks() {
 	}
 }
 
+// checkHealth pings every node and, when failover is enabled, moves writes
+// to the designated replica once the primary has failed enough checks in a
+// row.
 func (p *ConnectionPool) checkHealth() {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	sqlDB, err := p.primary.DB()
-	if err != nil {
-		p.health["primary"] = false
-	} else {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		err = sqlDB.PingContext(ctx)
-		cancel()
-		p.health["primary"] = err == nil
+	health := map[string]bool{primaryNode: pingNode(p.primary)}
+	for i, replica := range p.replicas {
+		health[replicaNode(i)] = pingNode(replica)
 	}
 
-	for i, replica := range p.replicas {
-		key := fmt.Sprintf("replica_%d", i)
-		sqlDB, err := replica.DB()
-		if err != nil {
-			p.health[key] = false
-		} else {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			err = sqlDB.PingContext(ctx)
-			cancel()
-			p.health[key] = err == nil
+	p.mu.Lock()
+	p.health = health
+	failover := p.recordPrimaryHealth()
+	failures := p.primaryFailures
+	p.mu.Unlock()
+
+	if failover {
+		reason := fmt.Sprintf("primary failed %d consecutive health checks", failures)
+		if err := p.failover(context.Background(), reason, false); err != nil {
+			log.Printf("ALERT: database failover failed: %v", err)
 		}
 	}
 }
 
+func pingNode(node *gorm.DB) bool {
+	sqlDB, err := node.DB()
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(ctx) == nil
+}
+
 func (p *ConnectionPool) GetHealth() map[string]bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
@@ -185,13 +217,14 @@ func (p *ConnectionPool) GetStats() PoolStats {
 	defer p.mu.RUnlock()
 
 	stats := PoolStats{
-		PrimaryHealthy:  p.health["primary"],
+		PrimaryHealthy:  p.health[primaryNode],
 		ReplicaCount:    len(p.replicas),
 		HealthyReplicas: 0,
+		WritableNode:    p.writable,
 	}
 
 	for i := range p.replicas {
-		if p.health[fmt.Sprintf("replica_%d", i)] {
+		if p.health[replicaNode(i)] {
 			stats.HealthyReplicas++
 		}
 	}
@@ -227,4 +260,6 @@ type PoolStats struct {
 	PrimaryHealthy  bool
 	ReplicaCount    int
 	HealthyReplicas int
+	// WritableNode is "primary", or "replica_<n>" after a failover.
+	WritableNode string
 }
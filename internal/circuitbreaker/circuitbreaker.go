@@ -254,6 +254,17 @@ func (m *Manager) AllStats() map[string]map[string]interface{} {
 	return stats
 }
 
+func (m *Manager) States() map[string]State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	states := make(map[string]State, len(m.breakers))
+	for name, cb := range m.breakers {
+		states[name] = cb.State()
+	}
+	return states
+}
+
 func (m *Manager) HealthyProviders() []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
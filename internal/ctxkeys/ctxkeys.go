@@ -3,12 +3,15 @@ package ctxkeys
 type Key string
 
 const (
-	UserID         Key = "user_id"
-	UserEmail      Key = "user_email"
-	UserRoles      Key = "user_roles"
-	APIKey         Key = "api_key"
-	EncryptedData  Key = "encrypted_data"
-	TenantID       Key = "tenant_id"
-	Tenant         Key = "tenant"
-	IdempotencyKey Key = "idempotency_key"
+	UserID              Key = "user_id"
+	UserEmail           Key = "user_email"
+	UserRoles           Key = "user_roles"
+	APIKey              Key = "api_key"
+	EncryptedData       Key = "encrypted_data"
+	TenantID            Key = "tenant_id"
+	Tenant              Key = "tenant"
+	IdempotencyKey      Key = "idempotency_key"
+	ScopedAPIKey        Key = "scoped_api_key"
+	CorrelationID       Key = "correlation_id"
+	RawResponseRecorder Key = "raw_response_recorder"
 )
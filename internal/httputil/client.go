@@ -24,6 +24,12 @@ func WithTimeout(timeout time.Duration) ClientOption {
 	}
 }
 
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
 func WithBaseURL(url string) ClientOption {
 	return func(c *Client) {
 		c.baseURL = url
@@ -46,7 +52,7 @@ func WithHeaders(headers map[string]string) ClientOption {
 
 func NewClient(opts ...ClientOption) *Client {
 	c := &Client{
-		client:  &http.Client{Timeout: 30 * time.Second},
+		client:  NewHTTPClient(DefaultTransportConfig()),
 		headers: make(map[string]string),
 	}
 	for _, opt := range opts {
@@ -0,0 +1,67 @@
+package httputil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+)
+
+func TestNewHTTPClientReusesIdleConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(DefaultTransportConfig())
+
+	var reused []bool
+	for i := 0; i < 3; i++ {
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				reused = append(reused, info.Reused)
+			},
+		}
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	if len(reused) != 3 {
+		t.Fatalf("expected 3 connections, got %d", len(reused))
+	}
+	if reused[0] {
+		t.Error("expected the first request to dial a new connection")
+	}
+	if !reused[1] || !reused[2] {
+		t.Errorf("expected later requests to reuse the idle connection, got %v", reused)
+	}
+}
+
+func TestTransportConfigDefaults(t *testing.T) {
+	client := NewHTTPClient(TransportConfig{MaxIdleConnsPerHost: 7})
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected *http.Transport, got %T", client.Transport)
+	}
+	if transport.MaxIdleConnsPerHost != 7 {
+		t.Errorf("expected MaxIdleConnsPerHost 7, got %d", transport.MaxIdleConnsPerHost)
+	}
+	defaults := DefaultTransportConfig()
+	if transport.IdleConnTimeout != defaults.IdleConnTimeout {
+		t.Errorf("expected default IdleConnTimeout %v, got %v", defaults.IdleConnTimeout, transport.IdleConnTimeout)
+	}
+	if client.Timeout != defaults.Timeout {
+		t.Errorf("expected default Timeout %v, got %v", defaults.Timeout, client.Timeout)
+	}
+}
@@ -0,0 +1,5 @@
+package metrics
+
+var DBFailoversTotal = Default.Counter("conductor_db_failovers_total",
+	"Times database writes moved between nodes, by source node, target node and trigger (automatic or manual).",
+	"from", "to", "trigger")
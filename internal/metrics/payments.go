@@ -0,0 +1,45 @@
+package metrics
+
+// Default is the registry served on /metrics.
+var Default = NewRegistry()
+
+var (
+	ChargesTotal = Default.Counter("conductor_charges_total",
+		"Charge attempts by provider, currency and resulting status.",
+		"provider", "currency", "status")
+	RefundsTotal = Default.Counter("conductor_refunds_total",
+		"Refund attempts by provider and resulting status.",
+		"provider", "status")
+	ProviderLatency = Default.Histogram("conductor_provider_request_duration_seconds",
+		"Latency of calls to payment providers.",
+		DefaultLatencyBuckets, "provider", "operation")
+	WebhookEventsTotal = Default.Counter("conductor_webhook_events_total",
+		"Inbound webhook events by provider and processing outcome.",
+		"provider", "outcome")
+	FraudChecksTotal = Default.Counter("conductor_fraud_checks_total",
+		"Inline fraud checks on charges by decision.",
+		"outcome")
+)
+
+const (
+	WebhookOutcomeAccepted  = "accepted"
+	WebhookOutcomeDuplicate = "duplicate"
+	WebhookOutcomeRejected  = "rejected"
+	WebhookOutcomeProcessed = "processed"
+	WebhookOutcomeFailed    = "failed"
+	// WebhookOutcomeDeadLettered counts events that failed their last
+	// allowed attempt; they are also counted as failed.
+	WebhookOutcomeDeadLettered = "dead_lettered"
+	// WebhookOutcomeIgnored counts events of a type nothing handles; they
+	// are acknowledged but not stored.
+	WebhookOutcomeIgnored = "ignored"
+)
+
+const (
+	FraudOutcomeAllowed = "allowed"
+	FraudOutcomeBlocked = "blocked"
+	// FraudOutcomeFailOpen and FraudOutcomeFailClosed count checks the
+	// fraud service could not answer, by what the charge path did next.
+	FraudOutcomeFailOpen   = "fail_open"
+	FraudOutcomeFailClosed = "fail_closed"
+)
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, registry *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	return rec.Body.String()
+}
+
+func TestCounterExposition(t *testing.T) {
+	registry := NewRegistry()
+	charges := registry.Counter("charges_total", "Charges.", "provider", "status")
+	charges.Inc("stripe", "succeeded")
+	charges.Add(2, "stripe", "succeeded")
+	charges.Inc("xendit", `fa"il`)
+
+	body := scrape(t, registry)
+	for _, line := range []string{
+		"# HELP charges_total Charges.",
+		"# TYPE charges_total counter",
+		`charges_total{provider="stripe",status="succeeded"} 3`,
+		`charges_total{provider="xendit",status="fa\"il"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expected %q in scrape output:\n%s", line, body)
+		}
+	}
+}
+
+func TestHistogramExposition(t *testing.T) {
+	registry := NewRegistry()
+	latency := registry.Histogram("latency_seconds", "Latency.", []float64{0.5, 0.1}, "provider")
+	latency.Observe(0.05, "stripe")
+	latency.Observe(0.3, "stripe")
+	latency.Observe(2, "stripe")
+
+	body := scrape(t, registry)
+	for _, line := range []string{
+		"# TYPE latency_seconds histogram",
+		`latency_seconds_bucket{provider="stripe",le="0.1"} 1`,
+		`latency_seconds_bucket{provider="stripe",le="0.5"} 2`,
+		`latency_seconds_bucket{provider="stripe",le="+Inf"} 3`,
+		`latency_seconds_sum{provider="stripe"} 2.35`,
+		`latency_seconds_count{provider="stripe"} 3`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expected %q in scrape output:\n%s", line, body)
+		}
+	}
+}
+
+func TestGaugeFuncCollectsOnScrape(t *testing.T) {
+	registry := NewRegistry()
+	state := 0.0
+	registry.GaugeFunc("breaker_state", "State.", []string{"provider"}, func() []GaugeSample {
+		return []GaugeSample{{LabelValues: []string{"stripe"}, Value: state}}
+	})
+
+	if body := scrape(t, registry); !strings.Contains(body, `breaker_state{provider="stripe"} 0`+"\n") {
+		t.Fatalf("unexpected initial gauge:\n%s", body)
+	}
+	state = 1
+	if body := scrape(t, registry); !strings.Contains(body, `breaker_state{provider="stripe"} 1`+"\n") {
+		t.Fatalf("gauge was not re-collected:\n%s", body)
+	}
+}
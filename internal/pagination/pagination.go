@@ -0,0 +1,94 @@
+// Package pagination pages list endpoints by keyset on (created_at, id),
+// newest first, behind an opaque cursor.
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// Cursor marks the last row of a page. Rows are ordered by created_at then
+// id, both descending, so the pair is a stable position even when many rows
+// share a timestamp.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// Encode returns the cursor as clients see it: base64 of "created_at,id".
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode parses a cursor produced by Encode.
+func Decode(encoded string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), ",")
+	if !found || id == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: t, ID: id}, nil
+}
+
+// Precedes reports whether a row at key falls after the cursor in page
+// order, matching the condition Keyset adds to a query.
+func (c *Cursor) Precedes(key Cursor) bool {
+	if c == nil {
+		return true
+	}
+	if !key.CreatedAt.Equal(c.CreatedAt) {
+		return key.CreatedAt.Before(c.CreatedAt)
+	}
+	return key.ID < c.ID
+}
+
+// Page is the envelope list endpoints return.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// Keyset orders query newest first, skips rows up to and including cursor,
+// and fetches one row past limit so NewPage can tell whether another page
+// follows. The table must have created_at and id columns.
+func Keyset(query *gorm.DB, cursor *Cursor, limit int) *gorm.DB {
+	if cursor != nil {
+		query = query.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	return query.Order("created_at DESC, id DESC").Limit(limit + 1)
+}
+
+// NewPage trims rows fetched with Keyset to limit and, when more follow,
+// sets the next cursor from the last row kept.
+func NewPage[T any](rows []T, limit int, key func(T) Cursor) Page[T] {
+	if rows == nil {
+		rows = []T{}
+	}
+	if limit <= 0 || len(rows) <= limit {
+		return Page[T]{Data: rows}
+	}
+
+	rows = rows[:limit]
+	return Page[T]{
+		Data:       rows,
+		NextCursor: key(rows[limit-1]).Encode(),
+		HasMore:    true,
+	}
+}
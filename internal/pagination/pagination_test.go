@@ -0,0 +1,104 @@
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{
+		CreatedAt: time.Date(2026, 3, 1, 12, 30, 0, 123456000, time.UTC),
+		ID:        "6f1c0d2e-8a43-4d8e-9d6a-1f2b3c4d5e6f",
+	}
+
+	decoded, err := Decode(cursor.Encode())
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Fatalf("expected %+v, got %+v", cursor, decoded)
+	}
+}
+
+func TestDecodeRejectsGarbage(t *testing.T) {
+	for _, encoded := range []string{"not base64!", "bm8tc2VwYXJhdG9y", "LGlk", "MjAyNi0wMy0wMSw"} {
+		if _, err := Decode(encoded); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("%q: expected ErrInvalidCursor, got %v", encoded, err)
+		}
+	}
+}
+
+type row struct {
+	id        string
+	createdAt time.Time
+}
+
+func rowKey(r row) Cursor {
+	return Cursor{CreatedAt: r.createdAt, ID: r.id}
+}
+
+// fetch mimics a query built with Keyset over rows already sorted newest
+// first: it skips rows up to the cursor and returns one past limit.
+func fetch(rows []row, cursor *Cursor, limit int) []row {
+	var out []row
+	for _, r := range rows {
+		if len(out) == limit+1 {
+			break
+		}
+		if cursor.Precedes(rowKey(r)) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func TestKeysetPagingIsStableAcrossTiedTimestamps(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var rows []row
+	// Rows are listed newest first; groups of four share a timestamp so
+	// page boundaries fall inside a tie.
+	for i := 13; i >= 0; i-- {
+		rows = append(rows, row{id: fmt.Sprintf("id_%02d", i), createdAt: base.Add(time.Duration(i/4) * time.Second)})
+	}
+
+	var seen []string
+	var cursor *Cursor
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("pagination did not terminate")
+		}
+		page := NewPage(fetch(rows, cursor, 3), 3, rowKey)
+		for _, r := range page.Data {
+			seen = append(seen, r.id)
+		}
+		if !page.HasMore {
+			if page.NextCursor != "" {
+				t.Fatal("expected no next cursor on the last page")
+			}
+			break
+		}
+
+		var err error
+		if cursor, err = Decode(page.NextCursor); err != nil {
+			t.Fatalf("decode next cursor: %v", err)
+		}
+	}
+
+	if len(seen) != len(rows) {
+		t.Fatalf("expected all %d rows exactly once, got %v", len(rows), seen)
+	}
+	for i, r := range rows {
+		if seen[i] != r.id {
+			t.Fatalf("row %d: expected %s, got %s", i, r.id, seen[i])
+		}
+	}
+}
+
+func TestNewPageWithoutMoreRows(t *testing.T) {
+	page := NewPage[row](nil, 3, rowKey)
+	if page.Data == nil || len(page.Data) != 0 || page.HasMore || page.NextCursor != "" {
+		t.Fatalf("expected an empty last page, got %+v", page)
+	}
+}
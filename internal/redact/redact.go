@@ -0,0 +1,131 @@
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const Placeholder = "[REDACTED]"
+
+// DefaultPaths covers card data and credentials that must never be persisted
+// in audit records.
+var DefaultPaths = []string{
+	"card_number",
+	"card.number",
+	"cvv",
+	"cvc",
+	"card.cvc",
+	"account_number",
+	"exp_month",
+	"exp_year",
+	"password",
+	"secret",
+	"api_key",
+	"api_secret",
+	"webhook_secret",
+	"provider_webhook_secrets",
+	"client_secret",
+	"token",
+	"refresh_token",
+	"access_token",
+}
+
+// LogPaths extends DefaultPaths for provider request and response bodies
+// that reach logs and error messages, where whole card objects are masked.
+var LogPaths = append(append([]string{}, DefaultPaths...), "card")
+
+// Redactor replaces values at configured paths in decoded JSON. A path with a
+// single segment (e.g. "cvv") matches that key at any depth; a dotted path
+// (e.g. "card.number") matches from the document root, where "*" matches any
+// key. Arrays are transparent, so "items.card.number" applies to every element
+// of items. Keys are compared case-insensitively.
+type Redactor struct {
+	anywhere map[string]bool
+	rooted   [][]string
+}
+
+func CreateRedactor(paths []string) *Redactor {
+	r := &Redactor{anywhere: make(map[string]bool)}
+	for _, path := range paths {
+		path = strings.ToLower(strings.TrimSpace(path))
+		if path == "" {
+			continue
+		}
+		segments := strings.Split(path, ".")
+		if len(segments) == 1 {
+			r.anywhere[segments[0]] = true
+			continue
+		}
+		r.rooted = append(r.rooted, segments)
+	}
+	return r
+}
+
+// Redact returns a copy of v with matching values replaced. v is expected to
+// be the result of json.Unmarshal into interface{} or map[string]interface{}.
+func (r *Redactor) Redact(v interface{}) interface{} {
+	return r.redact(v, r.rooted)
+}
+
+func (r *Redactor) RedactMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	return r.redact(m, r.rooted).(map[string]interface{})
+}
+
+// RedactBody redacts a raw JSON body and re-encodes it. Bodies that are not
+// JSON are returned unchanged.
+func (r *Redactor) RedactBody(body []byte) string {
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return string(body)
+	}
+	redacted, err := json.Marshal(r.Redact(decoded))
+	if err != nil {
+		return string(body)
+	}
+	return string(redacted)
+}
+
+func (r *Redactor) redact(v interface{}, paths [][]string) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for key, child := range value {
+			lower := strings.ToLower(key)
+			if r.anywhere[lower] {
+				out[key] = Placeholder
+				continue
+			}
+
+			var remaining [][]string
+			matched := false
+			for _, path := range paths {
+				if path[0] != "*" && path[0] != lower {
+					continue
+				}
+				if len(path) == 1 {
+					matched = true
+					break
+				}
+				remaining = append(remaining, path[1:])
+			}
+
+			if matched {
+				out[key] = Placeholder
+				continue
+			}
+			out[key] = r.redact(child, remaining)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(value))
+		for i, child := range value {
+			out[i] = r.redact(child, paths)
+		}
+		return out
+	default:
+		return v
+	}
+}
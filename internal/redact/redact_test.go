@@ -0,0 +1,62 @@
+package redact
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, body string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return v
+}
+
+func TestRedactPaths(t *testing.T) {
+	r := CreateRedactor([]string{"cvv", "card.number", "items.*.secret"})
+
+	got := r.Redact(decode(t, `{
+		"amount": 1000,
+		"card": {"number": "4242424242424242", "brand": "visa", "CVV": "123"},
+		"number": "not-a-card",
+		"items": [{"meta": {"secret": "s1", "name": "a"}}, {"other": {"secret": "s2"}}]
+	}`))
+
+	want := decode(t, `{
+		"amount": 1000,
+		"card": {"number": "[REDACTED]", "brand": "visa", "CVV": "[REDACTED]"},
+		"number": "not-a-card",
+		"items": [{"meta": {"secret": "[REDACTED]", "name": "a"}}, {"other": {"secret": "[REDACTED]"}}]
+	}`)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected redaction:\n got  %v\n want %v", got, want)
+	}
+}
+
+func TestRedactDoesNotMutateInput(t *testing.T) {
+	input := map[string]interface{}{"card_number": "4242424242424242"}
+	out := CreateRedactor(DefaultPaths).RedactMap(input)
+
+	if out["card_number"] != Placeholder {
+		t.Fatalf("expected card_number to be redacted, got %v", out["card_number"])
+	}
+	if input["card_number"] != "4242424242424242" {
+		t.Fatal("expected input map to be left untouched")
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	r := CreateRedactor(LogPaths)
+
+	got := r.RedactBody([]byte(`{"id":"pi_1","client_secret":"pi_1_secret_abc","payment_method":{"card":{"last4":"4242"}}}`))
+	if got != `{"client_secret":"[REDACTED]","id":"pi_1","payment_method":{"card":"[REDACTED]"}}` {
+		t.Fatalf("unexpected redacted body %s", got)
+	}
+	if got := r.RedactBody([]byte("<html>Bad Gateway</html>")); got != "<html>Bad Gateway</html>" {
+		t.Fatalf("expected a non-JSON body unchanged, got %s", got)
+	}
+}
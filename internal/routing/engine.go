@@ -95,7 +95,8 @@ func (e *Engine) Route(ctx context.Context, rc *models.RoutingContext) (*models.
 	}
 
 	scores := e.scoreProviders(ctx, rc, eligibleProviders, merchantConfig)
-	sort.Slice(scores, func(i, j int) bool {
+	// Stable, so equally scored providers keep the configured order.
+	sort.SliceStable(scores, func(i, j int) bool {
 		return scores[i].Score > scores[j].Score
 	})
 
@@ -515,6 +516,10 @@ func (e *Engine) GetCircuitBreakerStats() map[string]map[string]interface{} {
 	return e.circuitBreakers.AllStats()
 }
 
+func (e *Engine) GetCircuitBreakerStates() map[string]circuitbreaker.State {
+	return e.circuitBreakers.States()
+}
+
 func (e *Engine) GetMetricsSnapshot() map[string]interface{} {
 	return e.metricsCollector.Snapshot()
 }
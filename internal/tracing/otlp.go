@@ -0,0 +1,181 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// OTLPExporter sends spans to an OTLP/HTTP collector using the JSON encoding,
+// which every collector accepts on /v1/traces.
+type OTLPExporter struct {
+	url    string
+	client *http.Client
+}
+
+func CreateOTLPExporter(endpoint string) *OTLPExporter {
+	url := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return &OTLPExporter{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(buildOTLPRequest(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("span export rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func buildOTLPRequest(spans []sdktrace.ReadOnlySpan) otlpRequest {
+	var resource otlpResource
+	if res := spans[0].Resource(); res != nil {
+		resource.Attributes = otlpAttributes(res.Attributes())
+	}
+
+	scopes := make(map[string]*otlpScopeSpans)
+	order := make([]string, 0)
+	for _, span := range spans {
+		scope := span.InstrumentationScope()
+		group, ok := scopes[scope.Name]
+		if !ok {
+			group = &otlpScopeSpans{Scope: otlpScope{Name: scope.Name, Version: scope.Version}}
+			scopes[scope.Name] = group
+			order = append(order, scope.Name)
+		}
+		group.Spans = append(group.Spans, otlpSpanFrom(span))
+	}
+
+	resourceSpans := otlpResourceSpans{Resource: resource}
+	for _, name := range order {
+		resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, *scopes[name])
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{resourceSpans}}
+}
+
+func otlpSpanFrom(span sdktrace.ReadOnlySpan) otlpSpan {
+	out := otlpSpan{
+		TraceID:           span.SpanContext().TraceID().String(),
+		SpanID:            span.SpanContext().SpanID().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()),
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime().UnixNano(), 10),
+		Attributes:        otlpAttributes(span.Attributes()),
+	}
+	if parent := span.Parent(); parent.HasSpanID() {
+		out.ParentSpanID = parent.SpanID().String()
+	}
+
+	// OTLP numbers status codes differently from the Go API: 1 is OK, 2 is ERROR.
+	switch span.Status().Code {
+	case codes.Ok:
+		out.Status = otlpStatus{Code: 1}
+	case codes.Error:
+		out.Status = otlpStatus{Code: 2, Message: span.Status().Description}
+	}
+	return out
+}
+
+func otlpAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value map[string]interface{}
+		switch attr.Value.Type() {
+		case attribute.BOOL:
+			value = map[string]interface{}{"boolValue": attr.Value.AsBool()}
+		case attribute.INT64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(attr.Value.AsInt64(), 10)}
+		case attribute.FLOAT64:
+			value = map[string]interface{}{"doubleValue": attr.Value.AsFloat64()}
+		default:
+			value = map[string]interface{}{"stringValue": attr.Value.Emit()}
+		}
+		out = append(out, otlpKeyValue{Key: string(attr.Key), Value: value})
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestOTLPExporterPostsJSONSpans(t *testing.T) {
+	var received otlpRequest
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("collector received invalid JSON: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(CreateOTLPExporter(server.URL)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "conductor"))),
+	)
+	_, span := provider.Tracer("test").Start(context.Background(), "provider.charge")
+	span.SetAttributes(attribute.String("provider.name", "stripe"), attribute.Int64("amount", 1000))
+	span.SetStatus(codes.Error, "card declined")
+	span.RecordError(errors.New("card declined"))
+	span.End()
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	if path != "/v1/traces" {
+		t.Fatalf("expected spans to be posted to /v1/traces, got %q", path)
+	}
+	if len(received.ResourceSpans) != 1 || len(received.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload shape: %+v", received)
+	}
+	resourceAttrs := received.ResourceSpans[0].Resource.Attributes
+	if len(resourceAttrs) != 1 || resourceAttrs[0].Value["stringValue"] != "conductor" {
+		t.Fatalf("expected service.name resource attribute, got %+v", resourceAttrs)
+	}
+
+	exported := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(exported) != 1 {
+		t.Fatalf("expected one span, got %d", len(exported))
+	}
+	got := exported[0]
+	if got.Name != "provider.charge" || len(got.TraceID) != 32 || len(got.SpanID) != 16 {
+		t.Fatalf("unexpected span identity: %+v", got)
+	}
+	if got.Status.Code != 2 || got.Status.Message != "card declined" {
+		t.Fatalf("expected OTLP error status, got %+v", got.Status)
+	}
+	attrs := map[string]map[string]interface{}{}
+	for _, attr := range got.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	if attrs["provider.name"]["stringValue"] != "stripe" || attrs["amount"]["intValue"] != "1000" {
+		t.Fatalf("unexpected span attributes: %+v", got.Attributes)
+	}
+}
+
+func TestInitIsNoopWhenDisabled(t *testing.T) {
+	shutdown, err := Init(Config{})
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if _, err := Init(Config{Enabled: true}); !errors.Is(err, ErrEndpointRequired) {
+		t.Fatalf("expected ErrEndpointRequired, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var ErrEndpointRequired = errors.New("tracing endpoint is required when tracing is enabled")
+
+type Config struct {
+	Enabled     bool
+	Endpoint    string
+	ServiceName string
+	Environment string
+}
+
+// Init installs the global tracer provider. When tracing is disabled the
+// default no-op provider stays in place and the returned shutdown does nothing.
+func Init(cfg Config) (func(context.Context) error, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.Endpoint == "" {
+		return nil, ErrEndpointRequired
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "conductor"
+	}
+	attrs := []attribute.KeyValue{attribute.String("service.name", serviceName)}
+	if cfg.Environment != "" {
+		attrs = append(attrs, attribute.String("deployment.environment", cfg.Environment))
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(CreateOTLPExporter(cfg.Endpoint)),
+		sdktrace.WithResource(resource.NewSchemaless(attrs...)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+)
+
+// Locker grants exclusive, named leases so that a scheduled job runs on only
+// one instance at a time.
+type Locker interface {
+	TryLock(ctx context.Context, name string) (Lease, bool, error)
+}
+
+type Lease interface {
+	Check(ctx context.Context) error
+	Release(ctx context.Context) error
+}
+
+// PostgresLocker uses session-level advisory locks. Each lease pins a
+// dedicated connection, so the lock is dropped automatically if the instance
+// holding it dies.
+type PostgresLocker struct {
+	db func() (*sql.DB, error)
+}
+
+func NewPostgresLocker(db *sql.DB) *PostgresLocker {
+	return &PostgresLocker{db: func() (*sql.DB, error) { return db, nil }}
+}
+
+// NewRoutedPostgresLocker looks the database up on every TryLock, so leases
+// are taken on whichever node takes writes at the time, such as a replica
+// promoted by a failover.
+func NewRoutedPostgresLocker(db func() (*sql.DB, error)) *PostgresLocker {
+	return &PostgresLocker{db: db}
+}
+
+func (l *PostgresLocker) TryLock(ctx context.Context, name string) (Lease, bool, error) {
+	db, err := l.db()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := advisoryLockKey(name)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+	return &postgresLease{conn: conn, key: key}, true, nil
+}
+
+type postgresLease struct {
+	conn *sql.Conn
+	key  int64
+}
+
+func (l *postgresLease) Check(ctx context.Context) error {
+	return l.conn.PingContext(ctx)
+}
+
+func (l *postgresLease) Release(ctx context.Context) error {
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	if closeErr := l.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("conductor:worker:" + name))
+	return int64(h.Sum64())
+}
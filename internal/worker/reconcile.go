@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+type PaymentReconciler interface {
+	ReconcilePending(ctx context.Context, olderThan time.Duration, batchSize int) (*models.ReconciliationResult, error)
+}
+
+type ReconcileConfig struct {
+	Interval  time.Duration
+	OlderThan time.Duration
+	BatchSize int
+}
+
+func DefaultReconcileConfig() ReconcileConfig {
+	return ReconcileConfig{
+		Interval:  5 * time.Minute,
+		OlderThan: 15 * time.Minute,
+		BatchSize: 50,
+	}
+}
+
+func (c ReconcileConfig) withDefaults() ReconcileConfig {
+	d := DefaultReconcileConfig()
+	if c.Interval <= 0 {
+		c.Interval = d.Interval
+	}
+	if c.OlderThan <= 0 {
+		c.OlderThan = d.OlderThan
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = d.BatchSize
+	}
+	return c
+}
+
+type ReconcileJob struct {
+	reconciler PaymentReconciler
+	cfg        ReconcileConfig
+
+	OnError  func(error)
+	OnResult func(*models.ReconciliationResult)
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewReconcileJob(reconciler PaymentReconciler, cfg ReconcileConfig) *ReconcileJob {
+	return &ReconcileJob{
+		reconciler: reconciler,
+		cfg:        cfg.withDefaults(),
+	}
+}
+
+func (j *ReconcileJob) Start(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	j.cancel = cancel
+
+	j.wg.Add(1)
+	go j.run(ctx)
+}
+
+func (j *ReconcileJob) Stop() {
+	if j.cancel != nil {
+		j.cancel()
+	}
+	j.wg.Wait()
+}
+
+func (j *ReconcileJob) run(ctx context.Context) {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := j.Run(ctx); err != nil && j.OnError != nil {
+			j.OnError(err)
+		}
+	}
+}
+
+func (j *ReconcileJob) Interval() time.Duration {
+	return j.cfg.Interval
+}
+
+// Run performs a single reconciliation pass. It matches JobFunc so the job
+// can be registered with a Scheduler instead of started on its own.
+func (j *ReconcileJob) Run(ctx context.Context) error {
+	result, err := j.reconciler.ReconcilePending(ctx, j.cfg.OlderThan, j.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	if j.OnResult != nil && result != nil {
+		j.OnResult(result)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type JobFunc func(ctx context.Context) error
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	run      JobFunc
+}
+
+// Scheduler runs registered jobs on fixed intervals. When a Locker is set,
+// each job only runs on the instance currently holding its lease; the lease
+// is kept between runs and released on Stop so another instance can take over.
+type Scheduler struct {
+	locker Locker
+	jobs   []scheduledJob
+
+	OnError func(job string, err error)
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(locker Locker) *Scheduler {
+	return &Scheduler{locker: locker}
+}
+
+// Register must be called before Start.
+func (s *Scheduler) Register(name string, interval time.Duration, run JobFunc) {
+	s.jobs = append(s.jobs, scheduledJob{name: name, interval: interval, run: run})
+}
+
+func (s *Scheduler) Start(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	s.cancel = cancel
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job scheduledJob) {
+	defer s.wg.Done()
+
+	var lease Lease
+	defer func() {
+		if lease != nil {
+			if err := lease.Release(context.Background()); err != nil {
+				s.reportError(job.name, fmt.Errorf("release lock: %w", err))
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if s.locker != nil {
+			if lease != nil {
+				if err := lease.Check(ctx); err != nil {
+					s.reportError(job.name, fmt.Errorf("lost lock: %w", err))
+					_ = lease.Release(context.Background())
+					lease = nil
+				}
+			}
+			if lease == nil {
+				acquired, ok, err := s.locker.TryLock(ctx, job.name)
+				if err != nil {
+					s.reportError(job.name, fmt.Errorf("acquire lock: %w", err))
+					continue
+				}
+				if !ok {
+					continue
+				}
+				lease = acquired
+			}
+		}
+
+		if err := job.run(ctx); err != nil {
+			s.reportError(job.name, err)
+		}
+	}
+}
+
+func (s *Scheduler) reportError(job string, err error) {
+	if s.OnError != nil && !errors.Is(err, context.Canceled) {
+		s.OnError(job, err)
+	}
+}
@@ -0,0 +1,168 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+type memoryLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func newMemoryLocker() *memoryLocker {
+	return &memoryLocker{held: make(map[string]bool)}
+}
+
+func (l *memoryLocker) TryLock(_ context.Context, name string) (Lease, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[name] {
+		return nil, false, nil
+	}
+	l.held[name] = true
+	return &memoryLease{locker: l, name: name}, true, nil
+}
+
+type memoryLease struct {
+	locker *memoryLocker
+	name   string
+}
+
+func (l *memoryLease) Check(context.Context) error { return nil }
+
+func (l *memoryLease) Release(context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+	delete(l.locker.held, l.name)
+	return nil
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for !cond() {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for condition")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestSchedulerRunsJobOnOneInstance(t *testing.T) {
+	locker := newMemoryLocker()
+
+	var runsA, runsB atomic.Int32
+	a := NewScheduler(locker)
+	a.Register("reconcile", 5*time.Millisecond, func(context.Context) error {
+		runsA.Add(1)
+		return nil
+	})
+	b := NewScheduler(locker)
+	b.Register("reconcile", 5*time.Millisecond, func(context.Context) error {
+		runsB.Add(1)
+		return nil
+	})
+
+	a.Start(context.Background())
+	b.Start(context.Background())
+
+	waitFor(t, func() bool { return runsA.Load()+runsB.Load() >= 10 })
+	a.Stop()
+	b.Stop()
+
+	if runsA.Load() > 0 && runsB.Load() > 0 {
+		t.Fatalf("expected a single leader, but both ran: a=%d b=%d", runsA.Load(), runsB.Load())
+	}
+}
+
+func TestSchedulerHandsOverLeadershipOnStop(t *testing.T) {
+	locker := newMemoryLocker()
+
+	var runsA, runsB atomic.Int32
+	a := NewScheduler(locker)
+	a.Register("renewals", 5*time.Millisecond, func(context.Context) error {
+		runsA.Add(1)
+		return nil
+	})
+	a.Start(context.Background())
+	waitFor(t, func() bool { return runsA.Load() > 0 })
+
+	b := NewScheduler(locker)
+	b.Register("renewals", 5*time.Millisecond, func(context.Context) error {
+		runsB.Add(1)
+		return nil
+	})
+	b.Start(context.Background())
+	defer b.Stop()
+
+	time.Sleep(30 * time.Millisecond)
+	if runsB.Load() != 0 {
+		t.Fatalf("follower ran while leader held the lock: %d runs", runsB.Load())
+	}
+
+	a.Stop()
+	waitFor(t, func() bool { return runsB.Load() > 0 })
+}
+
+func TestWebhookRetryJobDrainsDueEvents(t *testing.T) {
+	const total = 25
+	events := make([]*models.WebhookEvent, total)
+	for i := range events {
+		events[i] = &models.WebhookEvent{ID: string(rune('a' + i))}
+	}
+
+	claimer := &fakeClaimer{events: events}
+	proc := &fakeProcessor{seen: make(map[string]int)}
+
+	job := NewWebhookRetryJob(claimer, proc, Config{Workers: 3, BatchSize: 10})
+	if err := job(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := proc.count(); got != total {
+		t.Fatalf("expected %d events processed, got %d", total, got)
+	}
+	if m := proc.maxSeen(); m != 1 {
+		t.Fatalf("expected each event processed once, got %d", m)
+	}
+}
+
+type countingReconciler struct {
+	calls atomic.Int32
+}
+
+func (r *countingReconciler) ReconcilePending(context.Context, time.Duration, int) (*models.ReconciliationResult, error) {
+	r.calls.Add(1)
+	return &models.ReconciliationResult{Checked: 1}, nil
+}
+
+func TestSchedulerRunsReconcileJobOnLeaderOnly(t *testing.T) {
+	locker := newMemoryLocker()
+	cfg := ReconcileConfig{Interval: 5 * time.Millisecond}
+
+	reconcilerA, reconcilerB := &countingReconciler{}, &countingReconciler{}
+	jobA, jobB := NewReconcileJob(reconcilerA, cfg), NewReconcileJob(reconcilerB, cfg)
+
+	a := NewScheduler(locker)
+	a.Register("payment-reconciliation", jobA.Interval(), jobA.Run)
+	b := NewScheduler(locker)
+	b.Register("payment-reconciliation", jobB.Interval(), jobB.Run)
+
+	a.Start(context.Background())
+	b.Start(context.Background())
+
+	waitFor(t, func() bool { return reconcilerA.calls.Load()+reconcilerB.calls.Load() >= 5 })
+	a.Stop()
+	b.Stop()
+
+	if reconcilerA.calls.Load() > 0 && reconcilerB.calls.Load() > 0 {
+		t.Fatalf("expected reconciliation on a single leader, got a=%d b=%d", reconcilerA.calls.Load(), reconcilerB.calls.Load())
+	}
+}
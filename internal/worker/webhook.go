@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -148,3 +149,48 @@ func (p *WebhookPool) reportError(err error) {
 		p.OnError(err)
 	}
 }
+
+// NewWebhookRetryJob drains due webhook events, including retries and events
+// abandoned mid-processing, for use as a scheduled job.
+func NewWebhookRetryJob(claimer EventClaimer, processor EventProcessor, cfg Config) JobFunc {
+	cfg = cfg.withDefaults()
+
+	return func(ctx context.Context) error {
+		for ctx.Err() == nil {
+			claimed, err := claimer.ClaimPendingEvents(ctx, cfg.BatchSize, cfg.StaleAfter)
+			if err != nil {
+				return err
+			}
+
+			sem := make(chan struct{}, cfg.Workers)
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			var errs []error
+			for _, ev := range claimed {
+				sem <- struct{}{}
+				wg.Add(1)
+				go func(ev *models.WebhookEvent) {
+					defer wg.Done()
+					defer func() { <-sem }()
+
+					procCtx, cancel := context.WithTimeout(context.Background(), cfg.ProcessTimeout)
+					defer cancel()
+					if err := processor.ProcessClaimedEvent(procCtx, ev); err != nil {
+						mu.Lock()
+						errs = append(errs, err)
+						mu.Unlock()
+					}
+				}(ev)
+			}
+			wg.Wait()
+
+			if len(errs) > 0 {
+				return errors.Join(errs...)
+			}
+			if len(claimed) < cfg.BatchSize {
+				return nil
+			}
+		}
+		return ctx.Err()
+	}
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,12 +17,17 @@ import (
 	"github.com/malwarebo/conductor/cache"
 	"github.com/malwarebo/conductor/config"
 	"github.com/malwarebo/conductor/db"
+	"github.com/malwarebo/conductor/internal/httputil"
+	"github.com/malwarebo/conductor/internal/metrics"
+	"github.com/malwarebo/conductor/internal/redact"
+	"github.com/malwarebo/conductor/internal/tracing"
 	"github.com/malwarebo/conductor/internal/worker"
 	"github.com/malwarebo/conductor/middleware"
 	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/security"
 	"github.com/malwarebo/conductor/services"
 	"github.com/malwarebo/conductor/stores"
+	"github.com/malwarebo/conductor/utils"
 )
 
 const (
@@ -85,14 +92,35 @@ func main() {
 	}
 	printSuccess("Configuration validation passed")
 
+	shutdownTracing, err := tracing.Init(tracing.Config{
+		Enabled:     cfg.Monitoring.EnableTracing,
+		Endpoint:    cfg.Monitoring.TracingEndpoint,
+		ServiceName: "conductor",
+		Environment: cfg.Environment,
+	})
+	if err != nil {
+		printError(fmt.Sprintf("Failed to initialize tracing: %v", err))
+		os.Exit(1)
+	}
+	if cfg.Monitoring.EnableTracing {
+		printSuccess(fmt.Sprintf("Tracing enabled, exporting to %s", cfg.Monitoring.TracingEndpoint))
+	}
+
 	printStep("3/10", "Connecting to database...")
 	poolConfig := db.PoolConfig{
-		MaxOpenConns:    cfg.Database.MaxOpenConns,
-		MaxIdleConns:    cfg.Database.MaxIdleConns,
-		ConnMaxLifetime: cfg.Database.MaxLifetime,
-		ConnMaxIdleTime: cfg.Database.MaxIdleTime,
-		MaxRetries:      3,
-		RetryDelay:      time.Second,
+		MaxOpenConns:        cfg.Database.MaxOpenConns,
+		MaxIdleConns:        cfg.Database.MaxIdleConns,
+		ConnMaxLifetime:     cfg.Database.MaxLifetime,
+		ConnMaxIdleTime:     cfg.Database.MaxIdleTime,
+		MaxRetries:          3,
+		RetryDelay:          time.Second,
+		HealthCheckInterval: time.Duration(cfg.Database.Failover.HealthCheckIntervalSeconds) * time.Second,
+		Failover: db.FailoverConfig{
+			Enabled:          cfg.Database.Failover.Enabled,
+			Replica:          cfg.Database.Failover.Replica,
+			FailureThreshold: cfg.Database.Failover.FailureThreshold,
+			Cooldown:         time.Duration(cfg.Database.Failover.CooldownSeconds) * time.Second,
+		},
 	}
 
 	connectionPool, err := db.CreateNewConnectionPool(cfg.GetDatabaseURL(), cfg.Database.ReplicaDSNs, poolConfig)
@@ -102,8 +130,17 @@ func main() {
 	}
 	defer func() { _ = connectionPool.Close() }()
 
+	if cfg.Monitoring.EnableTracing {
+		if err := connectionPool.EnableTracing(); err != nil {
+			printWarning(fmt.Sprintf("Failed to enable database tracing: %v", err))
+		}
+	}
+
 	database := connectionPool.GetPrimary()
 	printSuccess(fmt.Sprintf("Connected to PostgreSQL at %s:%d", cfg.Database.Host, cfg.Database.Port))
+	if cfg.Database.Failover.Enabled {
+		printInfo(fmt.Sprintf("  • Failing writes over to replica %d if the primary goes down", cfg.Database.Failover.Replica))
+	}
 
 	printStep("3.1/10", "Running database migrations...")
 	migrator := db.CreateNewMigrator(database)
@@ -150,19 +187,44 @@ func main() {
 		printWarning("No encryption key configured; generated an ephemeral key (encrypted data will not survive restarts)")
 	}
 
-	encryption, err := security.CreateEncryptionManager(encryptionKey)
+	var retiredKeys [][]byte
+	for _, retired := range cfg.Security.RetiredKeys {
+		digest := sha256.Sum256([]byte(strings.TrimSpace(retired)))
+		retiredKeys = append(retiredKeys, digest[:])
+	}
+
+	encryption, err := security.CreateEncryptionManager(encryptionKey, retiredKeys...)
 	if err != nil {
 		printError(fmt.Sprintf("Failed to initialize encryption: %v", err))
 		os.Exit(1)
 	}
 
 	jwtManager := security.CreateJWTManager(cfg.Security.JWTSecret, "conductor", "conductor-api")
+	if redisCache != nil {
+		jwtManager.SetRevocationList(security.CreateTokenRevocationList(redisCache))
+	} else {
+		printWarning("Redis unavailable; JWT revocation is disabled")
+	}
 
 	rateLimiter := security.CreateTieredRateLimiter(map[string]security.RateLimitConfig{
 		"default":  {RequestsPerSecond: 10, Burst: 20, Window: time.Minute},
 		"premium":  {RequestsPerSecond: 100, Burst: 200, Window: time.Minute},
 		"standard": {RequestsPerSecond: 50, Burst: 100, Window: time.Minute},
 	})
+	routeLimits := security.DefaultRouteLimits()
+	for route, limit := range cfg.Security.RouteRateLimits {
+		if limit.Burst <= 0 {
+			delete(routeLimits, route)
+			continue
+		}
+		routeLimits[route] = security.RateLimitConfig{RequestsPerSecond: limit.RequestsPerSecond, Burst: limit.Burst, Window: time.Minute}
+	}
+	rateLimiter.SetRouteLimits(routeLimits)
+	rateLimiter.SetIPLimit(security.RateLimitConfig{
+		RequestsPerSecond: cfg.Security.RateLimitRPS,
+		Burst:             cfg.Security.RateLimitBurst,
+		Window:            time.Minute,
+	})
 	printSuccess("Security components initialized")
 
 	printStep("6/8", "Initializing stores...")
@@ -175,9 +237,33 @@ func main() {
 	idempotencyStore := stores.CreateIdempotencyStore(database)
 	auditStore := stores.CreateAuditStore(database)
 	tenantStore := stores.CreateTenantStore(database)
+	apiKeyStore := stores.CreateAPIKeyStore(database)
 	webhookStore := stores.CreateWebhookStore(database)
-	customerStore := stores.CreateCustomerStore(database)
-	paymentMethodStore := stores.CreatePaymentMethodStore(database)
+	providerResponseStore := stores.CreateProviderResponseStore(database)
+	checkoutSessionRepo := stores.CreateCheckoutSessionRepository(database)
+	webhookDeliveryRepo := stores.CreateWebhookDeliveryRepository(database)
+	webhookEndpointStore := stores.CreateWebhookEndpointStore(database)
+	scheduledPaymentStore := stores.CreateScheduledPaymentStore(database)
+	payoutScheduleStore := stores.CreatePayoutScheduleStore(database)
+	var blindIndexSecret []byte
+	if cfg.Security.BlindIndexKey != "" {
+		blindIndexSecret = []byte(cfg.Security.BlindIndexKey)
+	} else {
+		if cfg.IsProduction() {
+			printError("Security.BlindIndexKey is required in production")
+			os.Exit(1)
+		}
+		blindIndexSecret = encryptionKey
+		printWarning("No blind index key configured; deriving it from the encryption key (email lookups break if that key rotates)")
+	}
+	fieldIndexKey := sha256.Sum256(append([]byte("conductor-blind-index:"), blindIndexSecret...))
+	if cfg.Security.TenantKeys {
+		encryption.SetTenantKeyStore(stores.CreateTenantKeyStore(database))
+		printInfo("  • Encrypting PII under per-tenant data keys")
+	}
+	fieldCipher := stores.CreateFieldCipher(encryption, fieldIndexKey[:])
+	customerStore := stores.CreateCustomerStoreWithEncryption(database, fieldCipher)
+	paymentMethodStore := stores.CreatePaymentMethodStoreWithEncryption(database, fieldCipher)
 
 	binStore := stores.NewBINStore(database)
 	merchantConfigStore := stores.NewMerchantConfigStore(database)
@@ -194,65 +280,309 @@ func main() {
 	printSuccess("Stores initialized")
 
 	printStep("7/8", "Initializing payment providers...")
+	outboundClient := httputil.NewHTTPClient(cfg.HTTPClient.Transport())
+	providers.SetLogRedactionKeys(cfg.Security.LogRedactKeys)
 	stripeProvider := providers.CreateStripeProviderWithWebhook(cfg.Stripe.Secret, cfg.Stripe.WebhookSecret)
 	xenditProvider := providers.CreateXenditProviderWithWebhook(cfg.Xendit.Secret, cfg.Xendit.WebhookSecret)
+	xenditProvider.SetHTTPClient(outboundClient)
 
 	availableProviders := []providers.PaymentProvider{stripeProvider, xenditProvider}
+	namedProviders := map[string]providers.PaymentProvider{"stripe": stripeProvider, "xendit": xenditProvider}
 
 	var razorpayProvider *providers.RazorpayProvider
 	if cfg.Razorpay.KeyID != "" && cfg.Razorpay.KeySecret != "" {
 		razorpayProvider = providers.CreateRazorpayProviderWithWebhook(cfg.Razorpay.KeyID, cfg.Razorpay.KeySecret, cfg.Razorpay.WebhookSecret)
+		razorpayProvider.SetHTTPClient(outboundClient)
 		availableProviders = append(availableProviders, razorpayProvider)
+		namedProviders["razorpay"] = razorpayProvider
 	}
 
 	var airwallexProvider *providers.AirwallexProvider
 	if cfg.Airwallex.ClientID != "" && cfg.Airwallex.APIKey != "" {
-		airwallexProvider = providers.CreateAirwallexProviderWithWebhook(cfg.Airwallex.ClientID, cfg.Airwallex.APIKey, cfg.Airwallex.WebhookSecret, cfg.Airwallex.UseSandbox)
+		airwallexProvider = providers.CreateAirwallexProviderWithWebhook(cfg.Airwallex.ClientID, cfg.Airwallex.APIKey, cfg.Airwallex.WebhookSecret, cfg.Airwallex.Sandbox())
+		airwallexProvider.SetHTTPClient(outboundClient)
 		availableProviders = append(availableProviders, airwallexProvider)
+		namedProviders["airwallex"] = airwallexProvider
 	}
 
 	routingConfig := providers.DefaultMultiProviderConfig()
 	routingConfig.BINStore = binStore
 	routingConfig.MerchantStore = merchantConfigStore
 	routingConfig.RuleStore = routingRuleStore
+	routingConfig.EnableChargeFailover = cfg.Payment.ChargeFailover
+	routingConfig.ProviderOrder = cfg.Payment.ProviderOrder
 	providerSelector := providers.CreateMultiProviderSelectorWithConfig(availableProviders, providerMappingStore, routingConfig)
 	printSuccess("Payment providers initialized")
-	printInfo("  • Stripe: Ready for USD, EUR, GBP")
-	printInfo("  • Xendit: Ready for IDR, SGD, MYR, PHP, THB, VND")
+	printInfo(fmt.Sprintf("  • Stripe (%s): Ready for USD, EUR, GBP", stripeProvider.Mode()))
+	printInfo(fmt.Sprintf("  • Xendit (%s): Ready for IDR, SGD, MYR, PHP, THB, VND", xenditProvider.Mode()))
 	if razorpayProvider != nil {
-		printInfo("  • Razorpay: Ready for INR")
+		printInfo(fmt.Sprintf("  • Razorpay (%s): Ready for INR", razorpayProvider.Mode()))
 	}
 	if airwallexProvider != nil {
-		printInfo("  • Airwallex: Ready for HKD, CNY, AUD, NZD, JPY, KRW")
+		printInfo(fmt.Sprintf("  • Airwallex (%s): Ready for HKD, CNY, AUD, NZD, JPY, KRW", airwallexProvider.Mode()))
+	}
+
+	credentialChecks := providers.ValidateCredentials(context.Background(), namedProviders, providers.DefaultCredentialCheckTimeout)
+	credentialsCheckedAt := time.Now()
+	for name, check := range credentialChecks {
+		if !check.Available {
+			printWarning(fmt.Sprintf("Provider %s failed its credential check: %v (requests routed to it will fail until this is fixed)", name, check.Err))
+		}
 	}
 
 	printStep("8/8", "Initializing services...")
-	fraudService := services.CreateFraudServiceWithCache(fraudRepo, cfg.OpenAI.APIKey, redisCache)
+	fraudRules := cfg.Fraud.Rules
+	if len(fraudRules) == 0 && cfg.Fraud.UseDefaultRules {
+		fraudRules = services.DefaultFraudRules()
+	}
+	ipAnalyzer := utils.CreateIPAnalyzer()
+	if len(cfg.Fraud.GeoIPDatabasePaths) > 0 {
+		loaded, err := utils.LoadIPAnalyzer(cfg.Fraud.GeoIPDatabasePaths...)
+		if err != nil {
+			printWarning(fmt.Sprintf("GeoIP database unavailable, using IP heuristics: %v", err))
+		} else {
+			ipAnalyzer = loaded
+			defer ipAnalyzer.Close()
+			printInfo("  • GeoIP enrichment enabled")
+		}
+	}
+	fraudService := services.CreateFraudServiceWithRules(fraudRepo, services.OpenAIConfig{
+		APIKey:         cfg.OpenAI.APIKey,
+		APIType:        cfg.OpenAI.APIType,
+		APIVersion:     cfg.OpenAI.APIVersion,
+		Model:          cfg.OpenAI.Model,
+		BaseURL:        cfg.OpenAI.BaseURL,
+		RequestTimeout: time.Duration(cfg.OpenAI.RequestTimeout),
+		HTTPClient:     outboundClient,
+	}, redisCache, services.CreateFraudRulesEngine(fraudRules), ipAnalyzer)
+	fraudService.SetFingerprintCounter(paymentMethodStore)
+	providerTimeouts := providers.OperationTimeouts{
+		Default:    time.Duration(cfg.Payment.ProviderTimeout),
+		Operations: make(map[string]time.Duration, len(cfg.Payment.ProviderOperationTimeouts)),
+	}
+	for operation, timeout := range cfg.Payment.ProviderOperationTimeouts {
+		providerTimeouts.Operations[operation] = time.Duration(timeout)
+	}
+	services.SetProviderTimeouts(providerTimeouts)
 	paymentService := services.CreatePaymentServiceFull(paymentRepo, idempotencyStore, auditStore, providerSelector, fraudService)
+	if len(cfg.Payment.AmountLimits) > 0 {
+		paymentService.SetAmountLimits(cfg.Payment.AmountLimits)
+	}
+	if len(cfg.Payment.CurrencyExponents) > 0 {
+		paymentService.SetCurrencyExponents(cfg.Payment.CurrencyExponents)
+	}
+	paymentService.SetProviderResponseCapture(providerResponseStore, cfg.Payment.CaptureProviderResponses)
+	paymentService.SetIdempotencyTTL(cfg.Payment.IdempotencyTTL)
+	paymentService.SetScheduledPayments(scheduledPaymentStore)
+	paymentService.SetFraudFailOpen(cfg.Fraud.FailOpen)
 	subscriptionService := services.CreateSubscriptionService(planRepo, subscriptionRepo, providerSelector)
-	disputeService := services.CreateDisputeService(disputeRepo, providerSelector)
+	disputeService := services.CreateDisputeService(disputeRepo, paymentRepo, providerSelector)
 	auditService := services.CreateAuditService(auditStore)
+	subscriptionService.SetIdempotency(idempotencyStore, cfg.Payment.IdempotencyTTL)
+	subscriptionService.SetAuditService(auditService)
 	tenantService := services.CreateTenantService(tenantStore)
+	if cfg.Security.TenantKeys {
+		tenantService.SetKeyRotator(encryption)
+	}
+	apiKeyService := services.CreateAPIKeyService(apiKeyStore)
 	webhookService := services.CreateWebhookService(webhookStore, paymentRepo, tenantStore, auditStore)
+	paymentService.SetNotifier(webhookService)
+	webhookService.SetDeliveryLog(webhookDeliveryRepo)
+	webhookService.SetEndpointStore(webhookEndpointStore)
+	for provider, eventTypes := range cfg.Events.HandledTypes {
+		webhookService.RegisterHandledEventTypes(provider, eventTypes...)
+	}
+	eventPublishing := false
+	if cfg.Events.Publisher == "redis" {
+		if redisCache == nil {
+			printWarning("EVENT_PUBLISHER=redis needs Redis; provider webhook events will not be forwarded")
+		} else {
+			webhookService.SetEventPublisher(services.CreateRedisStreamPublisher(redisCache), cfg.Events.TopicPrefix)
+			eventPublishing = true
+			printInfo("  • Forwarding provider webhook events to Redis streams")
+		}
+	}
 	invoiceService := services.CreateInvoiceService(providerSelector)
 	payoutService := services.CreatePayoutService(providerSelector)
+	payoutService.SetBalanceReserves(cfg.Payment.PayoutBalanceReserves)
+	payoutService.SetPayoutSchedules(payoutScheduleStore)
 	customerService := services.CreateCustomerService(customerStore, providerSelector)
 	paymentMethodService := services.CreatePaymentMethodService(paymentMethodStore, providerSelector)
+	paymentService.SetDefaultPaymentMethods(paymentMethodService, customerStore)
+	paymentService.SetSessionPaymentMethods(paymentMethodStore)
 	balanceService := services.CreateBalanceService(providerSelector)
+	checkoutService := services.CreateCheckoutService(checkoutSessionRepo, providerSelector)
 
 	printSuccess("Services initialized")
 
-	webhookPool := worker.NewWebhookPool(webhookStore, webhookService, worker.Config{
-		Workers:      cfg.Worker.WebhookWorkers,
-		BatchSize:    cfg.Worker.WebhookBatchSize,
-		PollInterval: time.Duration(cfg.Worker.WebhookPollMs) * time.Millisecond,
-		StaleAfter:   time.Duration(cfg.Worker.WebhookStaleSeconds) * time.Second,
+	// Leases follow the writer so scheduled jobs keep running after a
+	// database failover.
+	scheduler := worker.NewScheduler(worker.NewRoutedPostgresLocker(database.DB))
+	scheduler.OnError = func(job string, err error) {
+		printWarning(fmt.Sprintf("%s job: %v", job, err))
+	}
+	webhookInterval := time.Duration(cfg.Worker.WebhookPollMs) * time.Millisecond
+	if webhookInterval <= 0 {
+		webhookInterval = worker.DefaultConfig().PollInterval
+	}
+	scheduler.Register("webhook-retries", webhookInterval,
+		worker.NewWebhookRetryJob(webhookStore, webhookService, worker.Config{
+			Workers:    cfg.Worker.WebhookWorkers,
+			BatchSize:  cfg.Worker.WebhookBatchSize,
+			StaleAfter: time.Duration(cfg.Worker.WebhookStaleSeconds) * time.Second,
+		}))
+	dunning := services.DefaultDunningConfig()
+	if len(cfg.Worker.DunningRetryHours) > 0 {
+		dunning.RetryIntervals = make([]time.Duration, len(cfg.Worker.DunningRetryHours))
+		for i, hours := range cfg.Worker.DunningRetryHours {
+			dunning.RetryIntervals[i] = time.Duration(hours) * time.Hour
+		}
+	}
+	subscriptionService.SetDunning(dunning, webhookService)
+	webhookService.SetInvoiceEventHandler(subscriptionService)
+	webhookService.SetCheckoutEventHandler(checkoutService)
+
+	dunningInterval := time.Duration(cfg.Worker.DunningIntervalSeconds) * time.Second
+	if dunningInterval <= 0 {
+		dunningInterval = 15 * time.Minute
+	}
+	dunningBatchSize := cfg.Worker.DunningBatchSize
+	if dunningBatchSize <= 0 {
+		dunningBatchSize = 50
+	}
+	scheduler.Register("subscription-dunning", dunningInterval, func(ctx context.Context) error {
+		_, err := subscriptionService.ProcessDunning(ctx, dunningBatchSize)
+		return err
 	})
-	webhookPool.OnError = func(err error) {
-		printWarning(fmt.Sprintf("webhook worker: %v", err))
+
+	disputeReminders := services.DefaultDisputeReminderConfig()
+	if cfg.Worker.DisputeReminderLeadHours > 0 {
+		disputeReminders.LeadTime = time.Duration(cfg.Worker.DisputeReminderLeadHours) * time.Hour
 	}
-	webhookPool.Start(context.Background())
-	printSuccess("Webhook worker pool started")
+	disputeService.SetReminders(disputeReminders, webhookService)
+	webhookService.SetDisputeEventHandler(disputeService)
+
+	disputeReminderInterval := time.Duration(cfg.Worker.DisputeReminderIntervalSeconds) * time.Second
+	if disputeReminderInterval <= 0 {
+		disputeReminderInterval = time.Hour
+	}
+	disputeReminderBatchSize := cfg.Worker.DisputeReminderBatchSize
+	if disputeReminderBatchSize <= 0 {
+		disputeReminderBatchSize = 50
+	}
+	scheduler.Register("dispute-evidence-reminders", disputeReminderInterval, func(ctx context.Context) error {
+		_, err := disputeService.ProcessEvidenceReminders(ctx, disputeReminderBatchSize)
+		return err
+	})
+
+	reEncryptInterval := time.Duration(cfg.Worker.ReEncryptIntervalSeconds) * time.Second
+	if reEncryptInterval <= 0 {
+		reEncryptInterval = 6 * time.Hour
+	}
+	scheduler.Register("pii-reencrypt", reEncryptInterval, func(ctx context.Context) error {
+		if _, err := customerStore.ReEncrypt(ctx, 100); err != nil {
+			return fmt.Errorf("customers: %w", err)
+		}
+		if _, err := paymentMethodStore.ReEncrypt(ctx, 100); err != nil {
+			return fmt.Errorf("payment methods: %w", err)
+		}
+		return nil
+	})
+
+	reconciliationService := services.CreateReconciliationService(paymentRepo, auditStore, providerSelector)
+	reconcileJob := worker.NewReconcileJob(reconciliationService, worker.ReconcileConfig{
+		Interval:  time.Duration(cfg.Worker.ReconcileIntervalSeconds) * time.Second,
+		OlderThan: time.Duration(cfg.Worker.ReconcileOlderThanSeconds) * time.Second,
+		BatchSize: cfg.Worker.ReconcileBatchSize,
+	})
+	scheduler.Register("payment-reconciliation", reconcileJob.Interval(), reconcileJob.Run)
+
+	authExpiry := services.DefaultAuthorizationExpiryConfig()
+	if cfg.Worker.AuthExpiryDefaultHours > 0 {
+		authExpiry.Default = time.Duration(cfg.Worker.AuthExpiryDefaultHours) * time.Hour
+	}
+	if len(cfg.Worker.AuthExpiryHours) > 0 {
+		authExpiry.Windows = make(map[string]time.Duration, len(cfg.Worker.AuthExpiryHours))
+		for name, hours := range cfg.Worker.AuthExpiryHours {
+			authExpiry.Windows[name] = time.Duration(hours) * time.Hour
+		}
+	}
+	reconciliationService.SetAuthorizationExpiry(authExpiry, webhookService)
+
+	authExpiryInterval := time.Duration(cfg.Worker.AuthExpiryIntervalSeconds) * time.Second
+	if authExpiryInterval <= 0 {
+		authExpiryInterval = time.Hour
+	}
+	authExpiryBatchSize := cfg.Worker.AuthExpiryBatchSize
+	if authExpiryBatchSize <= 0 {
+		authExpiryBatchSize = 50
+	}
+	scheduler.Register("authorization-expiry", authExpiryInterval, func(ctx context.Context) error {
+		_, err := reconciliationService.ExpireStaleAuthorizations(ctx, authExpiryBatchSize)
+		return err
+	})
+
+	scheduledPaymentInterval := time.Duration(cfg.Worker.ScheduledPaymentIntervalSeconds) * time.Second
+	if scheduledPaymentInterval <= 0 {
+		scheduledPaymentInterval = time.Minute
+	}
+	scheduledPaymentBatchSize := cfg.Worker.ScheduledPaymentBatchSize
+	if scheduledPaymentBatchSize <= 0 {
+		scheduledPaymentBatchSize = 50
+	}
+	scheduler.Register("scheduled-payments", scheduledPaymentInterval, func(ctx context.Context) error {
+		_, err := paymentService.ExecuteDueScheduledPayments(ctx, scheduledPaymentBatchSize)
+		return err
+	})
+
+	payoutScheduleInterval := time.Duration(cfg.Worker.PayoutScheduleIntervalSeconds) * time.Second
+	if payoutScheduleInterval <= 0 {
+		payoutScheduleInterval = 5 * time.Minute
+	}
+	payoutScheduleBatchSize := cfg.Worker.PayoutScheduleBatchSize
+	if payoutScheduleBatchSize <= 0 {
+		payoutScheduleBatchSize = 50
+	}
+	scheduler.Register("payout-schedules", payoutScheduleInterval, func(ctx context.Context) error {
+		_, err := payoutService.ExecuteDuePayoutSchedules(ctx, payoutScheduleBatchSize)
+		return err
+	})
+
+	if eventPublishing {
+		eventPublishInterval := time.Duration(cfg.Worker.EventPublishRetryIntervalSeconds) * time.Second
+		if eventPublishInterval <= 0 {
+			eventPublishInterval = time.Minute
+		}
+		eventPublishBatchSize := cfg.Worker.EventPublishRetryBatchSize
+		if eventPublishBatchSize <= 0 {
+			eventPublishBatchSize = 100
+		}
+		scheduler.Register("event-publish-retries", eventPublishInterval, func(ctx context.Context) error {
+			_, err := webhookService.RetryEventPublishes(ctx, eventPublishBatchSize)
+			return err
+		})
+	}
+
+	idempotencyCleanupInterval := time.Duration(cfg.Worker.IdempotencyCleanupIntervalSeconds) * time.Second
+	if idempotencyCleanupInterval <= 0 {
+		idempotencyCleanupInterval = time.Hour
+	}
+	idempotencyCleanupBatchSize := cfg.Worker.IdempotencyCleanupBatchSize
+	if idempotencyCleanupBatchSize <= 0 {
+		idempotencyCleanupBatchSize = 1000
+	}
+	scheduler.Register("idempotency-cleanup", idempotencyCleanupInterval, func(ctx context.Context) error {
+		for ctx.Err() == nil {
+			deleted, err := idempotencyStore.DeleteExpired(ctx, idempotencyCleanupBatchSize)
+			if err != nil || deleted < int64(idempotencyCleanupBatchSize) {
+				return err
+			}
+		}
+		return ctx.Err()
+	})
+
+	scheduler.Start(context.Background())
+	printSuccess("Job scheduler started")
 
 	printStep("8/8", "Setting up HTTP server...")
 	webhookValidators := map[string]api.WebhookValidator{
@@ -266,50 +596,119 @@ func main() {
 		webhookValidators["airwallex"] = airwallexProvider
 	}
 	paymentHandler := api.CreatePaymentHandlerWithWebhook(paymentService, webhookService, webhookValidators)
+	paymentHandler.SetWebhookSecretResolver(tenantService)
 	subscriptionHandler := api.CreateSubscriptionHandler(subscriptionService)
 	disputeHandler := api.CreateDisputeHandler(disputeService)
 	fraudHandler := api.CreateFraudHandler(fraudService)
 	tenantHandler := api.CreateTenantHandler(tenantService)
+	apiKeyHandler := api.CreateAPIKeyHandler(apiKeyService)
 	auditHandler := api.CreateAuditHandler(auditService)
+	webhookDeliveryHandler := api.CreateWebhookDeliveryHandler(webhookService)
+	webhookEndpointHandler := api.CreateWebhookEndpointHandler(webhookService)
+	webhookEventHandler := api.CreateWebhookEventHandler(webhookService)
+	databaseHandler := api.CreateDatabaseHandler(connectionPool)
 	invoiceHandler := api.CreateInvoiceHandler(invoiceService)
+	checkoutHandler := api.CreateCheckoutHandler(checkoutService)
 	payoutHandler := api.CreatePayoutHandler(payoutService)
 	customerHandler := api.CreateCustomerHandler(customerService)
 	paymentMethodHandler := api.CreatePaymentMethodHandler(paymentMethodService)
 	balanceHandler := api.CreateBalanceHandler(balanceService)
-	authHandler := api.CreateAuthHandler(jwtManager, tenantService, cfg.Security.JWTExpiration)
+	capabilitiesHandler := api.CreateCapabilitiesHandler(providerSelector)
+	authHandler := api.CreateAuthHandler(jwtManager, tenantService, cfg.Security.JWTExpiration, cfg.Security.RefreshTokenExpiration)
+	readinessHandler := api.CreateReadinessHandler()
+
+	healthChecks := []api.DependencyCheck{
+		{
+			Name:     "database",
+			Critical: true,
+			Check: func(ctx context.Context) error {
+				return database.WithContext(ctx).Exec("SELECT 1").Error
+			},
+		},
+		{
+			Name: "redis",
+			Check: func(ctx context.Context) error {
+				if redisCache == nil {
+					return errors.New("redis not connected")
+				}
+				return redisCache.Client().Ping(ctx).Err()
+			},
+		},
+	}
+	for name, provider := range namedProviders {
+		startup := &api.StartupCheck{Status: "healthy", CheckedAt: credentialsCheckedAt}
+		if check := credentialChecks[name]; !check.Available {
+			startup.Status = "unhealthy"
+			startup.Error = check.Err.Error()
+		}
+		healthChecks = append(healthChecks, api.DependencyCheck{
+			Name: "provider:" + name,
+			Mode: string(provider.Capabilities().Mode),
+			Check: func(ctx context.Context) error {
+				if !provider.IsAvailable(ctx) {
+					return errors.New("provider unavailable")
+				}
+				return nil
+			},
+			Startup: startup,
+		})
+	}
+	healthHandler := api.CreateHealthHandler(5*time.Second, healthChecks...)
 
 	router := mux.NewRouter()
 
 	authMiddleware := middleware.CreateAuthMiddleware(jwtManager, rateLimiter, encryption)
+	authMiddleware.SetAPIKeyService(apiKeyService)
 	tenantMiddleware := middleware.CreateTenantMiddleware(tenantService, auditService)
+	tenantMiddleware.SetAuditBodyCapture(
+		redact.CreateRedactor(append(redact.DefaultPaths, cfg.Security.AuditRedactPaths...)),
+		cfg.Security.AuditMaxBodyBytes,
+	)
 
 	router.Use(middleware.CreateLoggingMiddleware)
 	router.Use(authMiddleware.HeadersMiddleware)
 	allowedOrigins := []string{"http://localhost:3000", "http://localhost:8080"}
 	router.Use(middleware.CreateCORSMiddleware(allowedOrigins))
 	router.Use(middleware.CreateRecoveryMiddleware)
+	router.Use(middleware.CreateTimeoutMiddleware(cfg.Server.RequestTimeout))
+
+	router.HandleFunc("/v1/ready", readinessHandler.HandleReady).Methods("GET")
 
 	authRouter := router.PathPrefix("/v1/auth").Subrouter()
 	authRouter.Use(authMiddleware.RateLimitMiddleware)
 	authRouter.HandleFunc("/token", authHandler.HandleToken).Methods("POST")
+	authRouter.HandleFunc("/refresh", authHandler.HandleRefresh).Methods("POST")
+	authRouter.HandleFunc("/revoke", authHandler.HandleRevoke).Methods("POST")
 
 	apiRouter := router.PathPrefix("/v1").Subrouter()
-	apiRouter.Use(authMiddleware.RateLimitMiddleware)
+	apiRouter.Use(authMiddleware.IPRateLimitMiddleware)
 	apiRouter.Use(authMiddleware.JWTMiddleware)
+	apiRouter.Use(authMiddleware.ScopeMiddleware)
 	apiRouter.Use(tenantMiddleware.TenantContextMiddleware)
+	apiRouter.Use(authMiddleware.RateLimitMiddleware)
 	apiRouter.Use(tenantMiddleware.IdempotencyMiddleware)
 	apiRouter.Use(tenantMiddleware.AuditMiddleware)
 	apiRouter.Use(authMiddleware.EncryptionMiddleware)
 
-	apiRouter.HandleFunc("/health", api.CreateHealthCheckHandler).Methods("GET")
+	apiRouter.HandleFunc("/health", healthHandler.HandleHealth).Methods("GET")
+	apiRouter.HandleFunc("/capabilities", capabilitiesHandler.HandleGet).Methods("GET")
 
 	apiRouter.HandleFunc("/charges", paymentHandler.HandleCharge).Methods("POST")
+	apiRouter.HandleFunc("/charges/batch", paymentHandler.HandleChargeBatch).Methods("POST")
 	apiRouter.HandleFunc("/authorize", paymentHandler.HandleAuthorize).Methods("POST")
+	apiRouter.HandleFunc("/payments", paymentHandler.HandleListPayments).Methods("GET")
 	apiRouter.HandleFunc("/payments/{id}", paymentHandler.HandleGetPayment).Methods("GET")
+	apiRouter.HandleFunc("/payments/{id}/provider-response", paymentHandler.HandleGetProviderResponses).Methods("GET")
 	apiRouter.HandleFunc("/payments/{id}/capture", paymentHandler.HandleCapture).Methods("POST")
 	apiRouter.HandleFunc("/payments/{id}/void", paymentHandler.HandleVoid).Methods("POST")
+	apiRouter.HandleFunc("/payments/{id}/reverse", paymentHandler.HandleReverse).Methods("POST")
 	apiRouter.HandleFunc("/payments/{id}/confirm", paymentHandler.HandleConfirm3DS).Methods("POST")
+	apiRouter.HandleFunc("/payments/{id}/3ds-callback", paymentHandler.Handle3DSCallback).Methods("POST")
 	apiRouter.HandleFunc("/refunds", paymentHandler.HandleRefund).Methods("POST")
+	apiRouter.HandleFunc("/scheduled-payments", paymentHandler.HandleSchedulePayment).Methods("POST")
+	apiRouter.HandleFunc("/scheduled-payments", paymentHandler.HandleListScheduledPayments).Methods("GET")
+	apiRouter.HandleFunc("/scheduled-payments/{id}", paymentHandler.HandleGetScheduledPayment).Methods("GET")
+	apiRouter.HandleFunc("/scheduled-payments/{id}/cancel", paymentHandler.HandleCancelScheduledPayment).Methods("POST")
 
 	apiRouter.HandleFunc("/payment-sessions", paymentHandler.HandleCreatePaymentSession).Methods("POST")
 	apiRouter.HandleFunc("/payment-sessions", paymentHandler.HandleListPaymentSessions).Methods("GET")
@@ -319,11 +718,15 @@ func main() {
 	apiRouter.HandleFunc("/payment-sessions/{id}/capture", paymentHandler.HandleCapturePaymentSession).Methods("POST")
 	apiRouter.HandleFunc("/payment-sessions/{id}/cancel", paymentHandler.HandleCancelPaymentSession).Methods("POST")
 
+	apiRouter.HandleFunc("/checkout-sessions", checkoutHandler.HandleCreate).Methods("POST")
+	apiRouter.HandleFunc("/checkout-sessions/{id}", checkoutHandler.HandleGet).Methods("GET")
+
 	apiRouter.HandleFunc("/plans", subscriptionHandler.HandlePlans).Methods("POST", "GET")
 	apiRouter.HandleFunc("/plans/{id}", subscriptionHandler.HandlePlans).Methods("GET", "PUT", "DELETE")
 
 	apiRouter.HandleFunc("/subscriptions", subscriptionHandler.HandleSubscriptions).Methods("POST", "GET")
 	apiRouter.HandleFunc("/subscriptions/{id}", subscriptionHandler.HandleSubscriptions).Methods("GET", "PUT", "DELETE")
+	apiRouter.HandleFunc("/subscriptions/{id}/usage", subscriptionHandler.HandleRecordUsage).Methods("POST")
 
 	apiRouter.HandleFunc("/disputes", disputeHandler.HandleDisputes).Methods("POST", "GET")
 	apiRouter.HandleFunc("/disputes/stats", disputeHandler.HandleDisputes).Methods("GET")
@@ -341,11 +744,30 @@ func main() {
 	apiRouter.HandleFunc("/tenants/{id}", tenantHandler.HandleUpdate).Methods("PUT")
 	apiRouter.HandleFunc("/tenants/{id}", tenantHandler.HandleDelete).Methods("DELETE")
 	apiRouter.HandleFunc("/tenants/{id}/deactivate", tenantHandler.HandleDeactivate).Methods("POST")
+	apiRouter.HandleFunc("/tenants/{id}/restore", tenantHandler.HandleRestore).Methods("POST")
 	apiRouter.HandleFunc("/tenants/{id}/regenerate-secret", tenantHandler.HandleRegenerateSecret).Methods("POST")
+	apiRouter.HandleFunc("/tenants/{id}/rotate-encryption-key", tenantHandler.HandleRotateEncryptionKey).Methods("POST")
+
+	apiRouter.HandleFunc("/api-keys", apiKeyHandler.HandleCreate).Methods("POST")
+	apiRouter.HandleFunc("/api-keys", apiKeyHandler.HandleList).Methods("GET")
+	apiRouter.HandleFunc("/api-keys/{id}", apiKeyHandler.HandleRevoke).Methods("DELETE")
 
 	apiRouter.HandleFunc("/audit-logs", auditHandler.HandleList).Methods("GET")
 	apiRouter.HandleFunc("/audit-logs/{resource_type}/{resource_id}", auditHandler.HandleGetResourceHistory).Methods("GET")
 
+	apiRouter.HandleFunc("/webhook-deliveries", webhookDeliveryHandler.HandleList).Methods("GET")
+	apiRouter.HandleFunc("/webhook-deliveries/{id}/redeliver", webhookDeliveryHandler.HandleRedeliver).Methods("POST")
+	apiRouter.HandleFunc("/webhook-endpoints", webhookEndpointHandler.HandleCreate).Methods("POST")
+	apiRouter.HandleFunc("/webhook-endpoints", webhookEndpointHandler.HandleList).Methods("GET")
+	apiRouter.HandleFunc("/webhook-endpoints/{id}", webhookEndpointHandler.HandleGet).Methods("GET")
+	apiRouter.HandleFunc("/webhook-endpoints/{id}", webhookEndpointHandler.HandleUpdate).Methods("PUT")
+	apiRouter.HandleFunc("/webhook-endpoints/{id}", webhookEndpointHandler.HandleDelete).Methods("DELETE")
+	apiRouter.HandleFunc("/webhook-events/dead-letter", webhookEventHandler.HandleListDeadLetter).Methods("GET")
+	apiRouter.HandleFunc("/webhook-events/{id}/reprocess", webhookEventHandler.HandleReprocess).Methods("POST")
+	apiRouter.HandleFunc("/webhooks/{provider}/{eventID}/reprocess", webhookEventHandler.HandleReplay).Methods("POST")
+	apiRouter.HandleFunc("/database/failover", databaseHandler.HandleFailover).Methods("POST")
+	apiRouter.HandleFunc("/database/failback", databaseHandler.HandleFailback).Methods("POST")
+
 	apiRouter.HandleFunc("/invoices", invoiceHandler.HandleCreate).Methods("POST")
 	apiRouter.HandleFunc("/invoices", invoiceHandler.HandleList).Methods("GET")
 	apiRouter.HandleFunc("/invoices/{id}", invoiceHandler.HandleGet).Methods("GET")
@@ -355,9 +777,17 @@ func main() {
 	apiRouter.HandleFunc("/payouts", payoutHandler.HandleList).Methods("GET")
 	apiRouter.HandleFunc("/payouts/{id}", payoutHandler.HandleGet).Methods("GET")
 	apiRouter.HandleFunc("/payouts/{id}/cancel", payoutHandler.HandleCancel).Methods("POST")
+	apiRouter.HandleFunc("/payout-schedules", payoutHandler.HandleCreateSchedule).Methods("POST")
+	apiRouter.HandleFunc("/payout-schedules", payoutHandler.HandleListSchedules).Methods("GET")
+	apiRouter.HandleFunc("/payout-schedules/{id}", payoutHandler.HandleGetSchedule).Methods("GET")
+	apiRouter.HandleFunc("/payout-schedules/{id}", payoutHandler.HandleUpdateSchedule).Methods("PATCH")
+	apiRouter.HandleFunc("/payout-schedules/{id}", payoutHandler.HandleDeleteSchedule).Methods("DELETE")
+	apiRouter.HandleFunc("/payout-schedules/{id}/runs", payoutHandler.HandleListScheduleRuns).Methods("GET")
 	apiRouter.HandleFunc("/payout-channels", payoutHandler.HandleGetChannels).Methods("GET")
+	apiRouter.HandleFunc("/fx/quote", payoutHandler.HandleFXQuote).Methods("GET")
 
 	apiRouter.HandleFunc("/customers", customerHandler.HandleCreate).Methods("POST")
+	apiRouter.HandleFunc("/customers", customerHandler.HandleList).Methods("GET")
 	apiRouter.HandleFunc("/customers/{id}", customerHandler.HandleGet).Methods("GET")
 	apiRouter.HandleFunc("/customers/{id}", customerHandler.HandleUpdate).Methods("PUT")
 	apiRouter.HandleFunc("/customers/{id}", customerHandler.HandleDelete).Methods("DELETE")
@@ -368,6 +798,8 @@ func main() {
 	apiRouter.HandleFunc("/payment-methods/{id}/attach", paymentMethodHandler.HandleAttach).Methods("POST")
 	apiRouter.HandleFunc("/payment-methods/{id}/detach", paymentMethodHandler.HandleDetach).Methods("POST")
 	apiRouter.HandleFunc("/payment-methods/{id}/expire", paymentMethodHandler.HandleExpire).Methods("POST")
+	apiRouter.HandleFunc("/payment-methods/{id}/verify", paymentMethodHandler.HandleVerify).Methods("POST")
+	apiRouter.HandleFunc("/payment-methods/{id}/set-default", paymentMethodHandler.HandleSetDefault).Methods("POST")
 
 	apiRouter.HandleFunc("/balance", balanceHandler.HandleGet).Methods("GET")
 
@@ -377,6 +809,28 @@ func main() {
 	webhookRouter.HandleFunc("/xendit", paymentHandler.HandleXenditWebhook).Methods("POST")
 	webhookRouter.HandleFunc("/razorpay", paymentHandler.HandleRazorpayWebhook).Methods("POST")
 	webhookRouter.HandleFunc("/airwallex", paymentHandler.HandleAirwallexWebhook).Methods("POST")
+	webhookRouter.HandleFunc("/stripe/{tenantId}", paymentHandler.HandleStripeWebhook).Methods("POST")
+	webhookRouter.HandleFunc("/xendit/{tenantId}", paymentHandler.HandleXenditWebhook).Methods("POST")
+	webhookRouter.HandleFunc("/razorpay/{tenantId}", paymentHandler.HandleRazorpayWebhook).Methods("POST")
+	webhookRouter.HandleFunc("/airwallex/{tenantId}", paymentHandler.HandleAirwallexWebhook).Methods("POST")
+
+	if cfg.Monitoring.Enabled {
+		metrics.Default.GaugeFunc("conductor_circuit_breaker_state",
+			"Circuit breaker state per provider: 0 closed, 1 open, 2 half-open.",
+			[]string{"provider"}, func() []metrics.GaugeSample {
+				states := providerSelector.CircuitBreakerStates()
+				samples := make([]metrics.GaugeSample, 0, len(states))
+				for name, state := range states {
+					samples = append(samples, metrics.GaugeSample{LabelValues: []string{name}, Value: float64(state)})
+				}
+				return samples
+			})
+		if cfg.Monitoring.MetricsPort == "" {
+			router.Handle("/metrics", metrics.Default.Handler()).Methods("GET")
+		}
+	}
+
+	api.SetMaxBodyBytes(cfg.Server.MaxBodyBytes)
 
 	server := &http.Server{
 		Addr:           ":" + cfg.Server.Port,
@@ -393,7 +847,8 @@ func main() {
 	if cfg.Monitoring.Enabled && cfg.Monitoring.MetricsPort != "" {
 		metricsMux := http.NewServeMux()
 		metricsHandler := api.CreateMetricsHandler(providerSelector.GetProviderStats)
-		metricsMux.HandleFunc("/metrics", metricsHandler.HandleMetrics)
+		metricsMux.Handle("/metrics", metrics.Default.Handler())
+		metricsMux.HandleFunc("/stats", metricsHandler.HandleMetrics)
 		metricsServer = &http.Server{
 			Addr:    ":" + cfg.Monitoring.MetricsPort,
 			Handler: metricsMux,
@@ -411,6 +866,7 @@ func main() {
 	fmt.Println()
 	fmt.Printf("%s%sAPI Endpoints:%s\n", colorPurple, colorBold, colorReset)
 	fmt.Printf("  %s-%s Health Check: %shttp://localhost:%s/v1/health%s\n", colorCyan, colorReset, colorYellow, cfg.Server.Port, colorReset)
+	fmt.Printf("  %s-%s Readiness:    %shttp://localhost:%s/v1/ready%s\n", colorCyan, colorReset, colorYellow, cfg.Server.Port, colorReset)
 	fmt.Printf("  %s-%s Payments:     %shttp://localhost:%s/v1/charges%s\n", colorCyan, colorReset, colorYellow, cfg.Server.Port, colorReset)
 	fmt.Printf("  %s-%s Subscriptions: %shttp://localhost:%s/v1/subscriptions%s\n", colorCyan, colorReset, colorYellow, cfg.Server.Port, colorReset)
 	fmt.Printf("  %s-%s Disputes:     %shttp://localhost:%s/v1/disputes%s\n", colorCyan, colorReset, colorYellow, cfg.Server.Port, colorReset)
@@ -442,7 +898,13 @@ func main() {
 	fmt.Println()
 	printWarning("Shutting down Conductor server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	readinessHandler.SetNotReady()
+	if cfg.Server.DrainDelay > 0 {
+		printInfo(fmt.Sprintf("Readiness set to not-ready, waiting %s before draining...", cfg.Server.DrainDelay))
+		time.Sleep(cfg.Server.DrainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
@@ -456,10 +918,14 @@ func main() {
 		}
 	}
 
-	webhookPool.Stop()
+	scheduler.Stop()
 
 	rateLimiter.Close()
 
+	if err := shutdownTracing(ctx); err != nil {
+		printWarning(fmt.Sprintf("Failed to flush traces: %v", err))
+	}
+
 	printSuccess("Conductor server stopped gracefully")
 	fmt.Println()
 	fmt.Printf("%s%sThanks for using Conductor!%s\n", colorCyan, colorBold, colorReset)
@@ -0,0 +1,278 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
+	"gorm.io/gorm"
+)
+
+type fakeAPIKeyRepository struct {
+	keys    map[string]*models.APIKey
+	touches int
+}
+
+func (f *fakeAPIKeyRepository) Create(_ context.Context, key *models.APIKey) error {
+	key.ID = "key_" + key.Prefix
+	f.keys[key.SecretHash] = key
+	return nil
+}
+
+func (f *fakeAPIKeyRepository) GetBySecretHash(_ context.Context, secretHash string) (*models.APIKey, error) {
+	key, ok := f.keys[secretHash]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return key, nil
+}
+
+func (f *fakeAPIKeyRepository) ListByTenant(_ context.Context, tenantID string) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	for _, key := range f.keys {
+		if key.TenantID == tenantID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeAPIKeyRepository) Revoke(_ context.Context, tenantID, id string, revokedAt time.Time) error {
+	for _, key := range f.keys {
+		if key.ID == id && key.TenantID == tenantID {
+			key.RevokedAt = &revokedAt
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (f *fakeAPIKeyRepository) TouchLastUsed(context.Context, string, time.Time) error {
+	f.touches++
+	return nil
+}
+
+func serveWithKey(router http.Handler, method, path, secret string) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestScopedAPIKeyAuthenticates(t *testing.T) {
+	svc := services.CreateAPIKeyService(&fakeAPIKeyRepository{keys: make(map[string]*models.APIKey)})
+	_, secret, err := svc.Issue(context.Background(), "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"charges:write"}})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+	am := CreateAuthMiddleware(nil, nil, nil)
+	am.SetAPIKeyService(svc)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/v1").Subrouter()
+	api.Use(am.JWTMiddleware, am.ScopeMiddleware)
+	api.HandleFunc("/charges", func(w http.ResponseWriter, r *http.Request) {
+		if tenantID, _ := r.Context().Value(ctxkeys.TenantID).(string); tenantID != "tenant_1" {
+			t.Errorf("expected tenant_1 in context, got %q", tenantID)
+		}
+		w.WriteHeader(http.StatusOK)
+	}).Methods("POST")
+
+	if code := serveWithKey(router, http.MethodPost, "/v1/charges", secret); code != http.StatusOK {
+		t.Fatalf("expected 200 for key with charges:write, got %d", code)
+	}
+}
+
+func TestScopedAPIKeyInsufficientScope(t *testing.T) {
+	svc := services.CreateAPIKeyService(&fakeAPIKeyRepository{keys: make(map[string]*models.APIKey)})
+	_, secret, err := svc.Issue(context.Background(), "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"charges:write", "refunds:read"}})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+	am := CreateAuthMiddleware(nil, nil, nil)
+	am.SetAPIKeyService(svc)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/v1").Subrouter()
+	api.Use(am.JWTMiddleware, am.ScopeMiddleware)
+	api.HandleFunc("/refunds", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+
+	if code := serveWithKey(router, http.MethodPost, "/v1/refunds", secret); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for key without refunds:write, got %d", code)
+	}
+}
+
+func TestRevokedAPIKeyRejected(t *testing.T) {
+	svc := services.CreateAPIKeyService(&fakeAPIKeyRepository{keys: make(map[string]*models.APIKey)})
+	key, secret, err := svc.Issue(context.Background(), "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"*"}})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+	am := CreateAuthMiddleware(nil, nil, nil)
+	am.SetAPIKeyService(svc)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/v1").Subrouter()
+	api.Use(am.JWTMiddleware, am.ScopeMiddleware)
+	api.HandleFunc("/charges", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+
+	if err := svc.Revoke(context.Background(), "tenant_1", key.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if code := serveWithKey(router, http.MethodPost, "/v1/charges", secret); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for revoked key, got %d", code)
+	}
+}
+
+func TestUnknownAPIKeyRejected(t *testing.T) {
+	am := CreateAuthMiddleware(nil, nil, nil)
+	am.SetAPIKeyService(services.CreateAPIKeyService(&fakeAPIKeyRepository{keys: make(map[string]*models.APIKey)}))
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/v1").Subrouter()
+	api.Use(am.JWTMiddleware, am.ScopeMiddleware)
+	api.HandleFunc("/charges", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+
+	if code := serveWithKey(router, http.MethodPost, "/v1/charges", "sk_unknown"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unknown key, got %d", code)
+	}
+}
+
+func TestScopedAPIKeyUsesExplicitRouteScopes(t *testing.T) {
+	svc := services.CreateAPIKeyService(&fakeAPIKeyRepository{keys: make(map[string]*models.APIKey)})
+	_, authorizeOnly, err := svc.Issue(context.Background(), "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"authorize:write"}})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+	_, chargesWrite, err := svc.Issue(context.Background(), "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"charges:write"}})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+	am := CreateAuthMiddleware(nil, nil, nil)
+	am.SetAPIKeyService(svc)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/v1").Subrouter()
+	api.Use(am.JWTMiddleware, am.ScopeMiddleware)
+	api.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+
+	if code := serveWithKey(router, http.MethodPost, "/v1/authorize", authorizeOnly); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for authorize without charges:write, got %d", code)
+	}
+	if code := serveWithKey(router, http.MethodPost, "/v1/authorize", chargesWrite); code != http.StatusOK {
+		t.Fatalf("expected 200 for authorize with charges:write, got %d", code)
+	}
+}
+
+func TestPayoutScheduleRoutesUsePayoutScopes(t *testing.T) {
+	svc := services.CreateAPIKeyService(&fakeAPIKeyRepository{keys: make(map[string]*models.APIKey)})
+	_, secret, err := svc.Issue(context.Background(), "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"payouts:read"}})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+	am := CreateAuthMiddleware(nil, nil, nil)
+	am.SetAPIKeyService(svc)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/v1").Subrouter()
+	api.Use(am.JWTMiddleware, am.ScopeMiddleware)
+	api.HandleFunc("/payout-schedules/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods("PATCH")
+	api.HandleFunc("/payout-schedules/{id}/runs", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	if code := serveWithKey(router, http.MethodGet, "/v1/payout-schedules/ps_1/runs", secret); code != http.StatusOK {
+		t.Fatalf("expected 200 listing runs with payouts:read, got %d", code)
+	}
+	if code := serveWithKey(router, http.MethodPatch, "/v1/payout-schedules/ps_1", secret); code != http.StatusForbidden {
+		t.Fatalf("expected 403 updating a schedule without payouts:write, got %d", code)
+	}
+}
+
+func TestScopedAPIKeyDeniedOnUnlistedRoute(t *testing.T) {
+	svc := services.CreateAPIKeyService(&fakeAPIKeyRepository{keys: make(map[string]*models.APIKey)})
+	_, secret, err := svc.Issue(context.Background(), "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"*"}})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+	am := CreateAuthMiddleware(nil, nil, nil)
+	am.SetAPIKeyService(svc)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/v1").Subrouter()
+	api.Use(am.JWTMiddleware, am.ScopeMiddleware)
+	api.HandleFunc("/unlisted", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	if code := serveWithKey(router, http.MethodGet, "/v1/unlisted", secret); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for route without a scope mapping, got %d", code)
+	}
+}
+
+func TestTenantScopedKeyCannotActOnAnotherTenant(t *testing.T) {
+	svc := services.CreateAPIKeyService(&fakeAPIKeyRepository{keys: make(map[string]*models.APIKey)})
+	_, tenantWrite, err := svc.Issue(context.Background(), "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"tenants:write"}})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+	_, admin, err := svc.Issue(context.Background(), "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"tenants:write", "admin:write"}})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+	am := CreateAuthMiddleware(nil, nil, nil)
+	am.SetAPIKeyService(svc)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/v1").Subrouter()
+	api.Use(am.JWTMiddleware, am.ScopeMiddleware)
+	api.HandleFunc("/tenants/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods("PUT")
+
+	if code := serveWithKey(router, http.MethodPut, "/v1/tenants/tenant_1", tenantWrite); code != http.StatusOK {
+		t.Fatalf("expected 200 updating the key's own tenant, got %d", code)
+	}
+	if code := serveWithKey(router, http.MethodPut, "/v1/tenants/tenant_2", tenantWrite); code != http.StatusForbidden {
+		t.Fatalf("expected 403 updating another tenant with tenants:write, got %d", code)
+	}
+	if code := serveWithKey(router, http.MethodPut, "/v1/tenants/tenant_2", admin); code != http.StatusOK {
+		t.Fatalf("expected 200 updating another tenant with admin:write, got %d", code)
+	}
+}
+
+func TestIssueRejectsScopeEscalation(t *testing.T) {
+	svc := services.CreateAPIKeyService(&fakeAPIKeyRepository{keys: make(map[string]*models.APIKey)})
+	caller := &models.APIKey{TenantID: "tenant_1", Scopes: []string{"charges:write", "api-keys:write"}}
+	ctx := context.WithValue(context.Background(), ctxkeys.ScopedAPIKey, caller)
+
+	for _, scopes := range [][]string{{"*"}, {"charges:*"}, {"refunds:write"}, {"charges:write", "payouts:write"}} {
+		if _, _, err := svc.Issue(ctx, "tenant_1", &models.CreateAPIKeyRequest{Scopes: scopes}); !errors.Is(err, services.ErrAPIKeyScopeDenied) {
+			t.Fatalf("scopes %v: expected ErrAPIKeyScopeDenied, got %v", scopes, err)
+		}
+	}
+
+	if _, _, err := svc.Issue(ctx, "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"charges:write"}}); err != nil {
+		t.Fatalf("expected subset of caller scopes to be allowed, got %v", err)
+	}
+}
+
+func TestAuthenticateThrottlesLastUsedWrites(t *testing.T) {
+	repo := &fakeAPIKeyRepository{keys: make(map[string]*models.APIKey)}
+	svc := services.CreateAPIKeyService(repo)
+	_, secret, err := svc.Issue(context.Background(), "tenant_1", &models.CreateAPIKeyRequest{Scopes: []string{"*"}})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := svc.Authenticate(context.Background(), secret); err != nil {
+			t.Fatalf("authenticate: %v", err)
+		}
+	}
+	if repo.touches != 1 {
+		t.Fatalf("expected a single last_used_at write, got %d", repo.touches)
+	}
+}
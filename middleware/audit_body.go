@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/malwarebo/conductor/internal/redact"
+	"github.com/malwarebo/conductor/models"
+)
+
+const defaultAuditMaxBodyBytes = 16 * 1024
+
+// captureRequestBody buffers at most limit+1 bytes of the request body for
+// auditing and stitches them back in front of the unread remainder, so the
+// handler still sees the full stream.
+func captureRequestBody(r *http.Request, limit int) ([]byte, int) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, 0
+	}
+
+	prefix, _ := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), r.Body), r.Body}
+
+	size := len(prefix)
+	if size > limit {
+		return nil, size
+	}
+	return prefix, size
+}
+
+// auditBody turns a captured body into a redacted JSON document. Bodies that
+// were truncated or are not JSON are recorded only by size, since redaction
+// cannot be guaranteed for them.
+func auditBody(redactor *redact.Redactor, body []byte, size, limit int) models.JSON {
+	if size == 0 {
+		return nil
+	}
+	if size > limit {
+		return models.JSON{"_truncated": true, "_size_at_least": size}
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return models.JSON{"_unparsed": true, "_size": size}
+	}
+
+	redacted := redactor.Redact(decoded)
+	if m, ok := redacted.(map[string]interface{}); ok {
+		return models.JSON(m)
+	}
+	return models.JSON{"_value": redacted}
+}
+
+type auditResponseWriter struct {
+	*responseWriter
+	body  bytes.Buffer
+	size  int
+	limit int
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if remaining := w.limit + 1 - w.body.Len(); remaining > 0 {
+		if remaining > len(b) {
+			remaining = len(b)
+		}
+		w.body.Write(b[:remaining])
+	}
+	w.size += len(b)
+	return w.responseWriter.Write(b)
+}
+
+func (w *auditResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/pagination"
+	"github.com/malwarebo/conductor/internal/redact"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
+)
+
+type fakeAuditRepository struct {
+	logs chan *models.AuditLog
+}
+
+func (f *fakeAuditRepository) Create(_ context.Context, log *models.AuditLog) error {
+	f.logs <- log
+	return nil
+}
+
+func (f *fakeAuditRepository) List(context.Context, models.AuditLogFilter) (pagination.Page[*models.AuditLog], error) {
+	return pagination.Page[*models.AuditLog]{}, nil
+}
+
+func (f *fakeAuditRepository) ListByResource(context.Context, string, string, string, int) ([]*models.AuditLog, error) {
+	return nil, nil
+}
+
+func (f *fakeAuditRepository) CleanupOld(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeAuditRepository) next(t *testing.T) *models.AuditLog {
+	t.Helper()
+	select {
+	case log := <-f.logs:
+		return log
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for audit log")
+		return nil
+	}
+}
+
+func TestAuditMiddlewareRedactsCardFields(t *testing.T) {
+	repo := &fakeAuditRepository{logs: make(chan *models.AuditLog, 1)}
+	tm := CreateTenantMiddleware(nil, services.CreateAuditService(repo))
+
+	requestBody := `{"amount":1000,"card":{"number":"4242424242424242","cvc":"123","brand":"visa"}}`
+	var handlerSaw string
+	handler := tm.AuditMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		handlerSaw = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"pay_1","client_secret":"pi_1_secret_abc"}`))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/charges", strings.NewReader(requestBody)))
+
+	if handlerSaw != requestBody {
+		t.Fatalf("expected handler to receive the original body, got %q", handlerSaw)
+	}
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), "pi_1_secret_abc") {
+		t.Fatalf("expected response to reach the client unchanged, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	log := repo.next(t)
+	card, ok := log.RequestBody["card"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected card object in audited request body, got %v", log.RequestBody)
+	}
+	if card["number"] != redact.Placeholder || card["cvc"] != redact.Placeholder {
+		t.Fatalf("expected card number and cvc to be redacted, got %v", card)
+	}
+	if card["brand"] != "visa" || log.RequestBody["amount"] != float64(1000) {
+		t.Fatalf("expected non-sensitive fields to be kept, got %v", log.RequestBody)
+	}
+	if log.ResponseBody["client_secret"] != redact.Placeholder || log.ResponseBody["id"] != "pay_1" {
+		t.Fatalf("expected client_secret to be redacted in response body, got %v", log.ResponseBody)
+	}
+	if log.ResponseCode != http.StatusCreated {
+		t.Fatalf("expected response code 201, got %d", log.ResponseCode)
+	}
+}
+
+func TestAuditMiddlewareTruncatesLargeBodies(t *testing.T) {
+	repo := &fakeAuditRepository{logs: make(chan *models.AuditLog, 1)}
+	tm := CreateTenantMiddleware(nil, services.CreateAuditService(repo))
+	tm.SetAuditBodyCapture(nil, 32)
+
+	requestBody := `{"description":"` + strings.Repeat("x", 100) + `"}`
+	var handlerSaw string
+	handler := tm.AuditMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		handlerSaw = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/charges", strings.NewReader(requestBody)))
+
+	if handlerSaw != requestBody {
+		t.Fatalf("expected handler to receive the full body, got %d bytes", len(handlerSaw))
+	}
+
+	log := repo.next(t)
+	if log.RequestBody["_truncated"] != true {
+		t.Fatalf("expected truncated marker, got %v", log.RequestBody)
+	}
+	if _, ok := log.RequestBody["description"]; ok {
+		t.Fatal("expected truncated body content not to be stored")
+	}
+}
+
+func TestAuditMiddlewareRedactsTenantCredentials(t *testing.T) {
+	repo := &fakeAuditRepository{logs: make(chan *models.AuditLog, 1)}
+	tm := CreateTenantMiddleware(nil, services.CreateAuditService(repo))
+
+	requestBody := `{"name":"acme","webhook_secret":"whsec_1","provider_webhook_secrets":{"stripe":"whsec_2"}}`
+	handler := tm.AuditMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"tenant":{"id":"ten_1","name":"acme","api_key":"ak_live_123"},"secret":"s_123"}`))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/tenants", strings.NewReader(requestBody)))
+
+	log := repo.next(t)
+	if log.RequestBody["webhook_secret"] != redact.Placeholder || log.RequestBody["provider_webhook_secrets"] != redact.Placeholder {
+		t.Fatalf("expected webhook secrets to be redacted in request body, got %v", log.RequestBody)
+	}
+	tenant, ok := log.ResponseBody["tenant"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected tenant object in audited response body, got %v", log.ResponseBody)
+	}
+	if tenant["api_key"] != redact.Placeholder || log.ResponseBody["secret"] != redact.Placeholder {
+		t.Fatalf("expected api_key and secret to be redacted in response body, got %v", log.ResponseBody)
+	}
+	if tenant["name"] != "acme" {
+		t.Fatalf("expected tenant name to be kept, got %v", tenant)
+	}
+}
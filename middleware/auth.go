@@ -4,20 +4,28 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/security"
+	"github.com/malwarebo/conductor/services"
 )
 
 type AuthMiddleware struct {
 	jwtManager  *security.JWTManager
 	rateLimiter *security.TieredRateLimiter
 	encryption  *security.EncryptionManager
+	apiKeys     *services.APIKeyService
 }
 
 func CreateAuthMiddleware(jwtManager *security.JWTManager, rateLimiter *security.TieredRateLimiter, encryption *security.EncryptionManager) *AuthMiddleware {
@@ -28,6 +36,10 @@ func CreateAuthMiddleware(jwtManager *security.JWTManager, rateLimiter *security
 	}
 }
 
+func (am *AuthMiddleware) SetAPIKeyService(apiKeys *services.APIKeyService) {
+	am.apiKeys = apiKeys
+}
+
 func (am *AuthMiddleware) JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/v1/health" || r.URL.Path == "/v1/metrics" {
@@ -47,7 +59,16 @@ func (am *AuthMiddleware) JWTMiddleware(next http.Handler) http.Handler {
 		}
 
 		token := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := am.jwtManager.ValidateToken(token)
+		if services.IsAPIKeySecret(token) {
+			am.serveWithAPIKey(w, r, next, token)
+			return
+		}
+
+		claims, err := am.jwtManager.ValidateAccessToken(r.Context(), token)
+		if errors.Is(err, security.ErrTokenRevoked) {
+			am.writeErrorResponse(w, http.StatusUnauthorized, "Token has been revoked")
+			return
+		}
 		if err != nil {
 			am.writeErrorResponse(w, http.StatusUnauthorized, "Invalid token")
 			return
@@ -62,25 +83,138 @@ func (am *AuthMiddleware) JWTMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func (am *AuthMiddleware) RateLimitMiddleware(next http.Handler) http.Handler {
+func (am *AuthMiddleware) serveWithAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, secret string) {
+	if am.apiKeys == nil {
+		am.writeErrorResponse(w, http.StatusUnauthorized, "API key authentication is not enabled")
+		return
+	}
+
+	key, err := am.apiKeys.Authenticate(r.Context(), secret)
+	switch {
+	case errors.Is(err, services.ErrAPIKeyRevoked):
+		am.writeErrorResponse(w, http.StatusUnauthorized, "API key has been revoked")
+		return
+	case errors.Is(err, services.ErrAPIKeyExpired):
+		am.writeErrorResponse(w, http.StatusUnauthorized, "API key has expired")
+		return
+	case err != nil:
+		am.writeErrorResponse(w, http.StatusUnauthorized, "Invalid API key")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), ctxkeys.ScopedAPIKey, key)
+	ctx = context.WithValue(ctx, ctxkeys.TenantID, key.TenantID)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// ScopeMiddleware enforces API key scopes using the explicit route table in
+// scopes.go. Routes not listed there are closed to API keys. JWT-authenticated
+// requests carry no scoped key and pass through.
+func (am *AuthMiddleware) ScopeMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID := r.Context().Value(ctxkeys.UserID)
-		if userID == nil {
-			userID = r.RemoteAddr
+		key, ok := r.Context().Value(ctxkeys.ScopedAPIKey).(*models.APIKey)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		tier := am.getUserTier(r.Context())
-		key := fmt.Sprintf("%s_%s", userID, r.URL.Path)
+		scope, listed := requiredScope(r)
+		if !listed {
+			am.writeErrorResponse(w, http.StatusForbidden, "API keys cannot access this endpoint")
+			return
+		}
+		if scope != scopeAny && !key.HasScope(scope) {
+			am.writeErrorResponse(w, http.StatusForbidden, fmt.Sprintf("API key is missing required scope %s", scope))
+			return
+		}
+		if scope := crossTenantScope(r, key); scope != "" && !key.HasScope(scope) {
+			am.writeErrorResponse(w, http.StatusForbidden, fmt.Sprintf("API key needs scope %s to act on another tenant", scope))
+			return
+		}
 
-		if !am.rateLimiter.Allow(key, tier) {
-			am.writeErrorResponse(w, http.StatusTooManyRequests, "Rate limit exceeded")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IPRateLimitMiddleware limits by client address and runs ahead of
+// authentication, so unauthenticated floods are rejected before any token or
+// API key lookup. RateLimitMiddleware applies the tenant limit after auth.
+func (am *AuthMiddleware) IPRateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, limited := am.rateLimiter.TakeIP(remoteIP(r))
+		if limited {
+			writeRateLimitHeaders(w, result)
+		}
+		if !result.Allowed {
+			am.writeRateLimitExceeded(w, result, "Rate limit exceeded")
 			return
 		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (am *AuthMiddleware) RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := am.getRateLimitKey(r)
+		tier := am.getRateLimitTier(r.Context())
+
+		result := am.rateLimiter.Take(key, tier)
+		writeRateLimitHeaders(w, result)
+		if !result.Allowed {
+			am.writeRateLimitExceeded(w, result, "Rate limit exceeded")
+			return
+		}
+
+		if route := getRouteTemplate(r); route != "" {
+			routeResult, limited := am.rateLimiter.TakeRoute(key, route)
+			// The route bucket is usually the tighter one, so its state
+			// replaces the tier headers whenever it leaves less headroom.
+			if limited && (!routeResult.Allowed || routeResult.Remaining < result.Remaining) {
+				writeRateLimitHeaders(w, routeResult)
+			}
+			if !routeResult.Allowed {
+				am.writeRateLimitExceeded(w, routeResult, "Rate limit exceeded for endpoint")
+				return
+			}
+		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// writeRateLimitHeaders reports the bucket state on every response. Reset is
+// the number of seconds until the bucket is full again.
+func writeRateLimitHeaders(w http.ResponseWriter, result security.RateLimitResult) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+func (am *AuthMiddleware) writeRateLimitExceeded(w http.ResponseWriter, result security.RateLimitResult, message string) {
+	retryAfter := ceilSeconds(result.RetryAfter)
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	am.writeErrorResponse(w, http.StatusTooManyRequests, message)
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
 func (am *AuthMiddleware) WebhookMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		next.ServeHTTP(w, r)
@@ -128,6 +262,35 @@ func (am *AuthMiddleware) HeadersMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+func (am *AuthMiddleware) getRateLimitKey(r *http.Request) string {
+	if tenantID, ok := r.Context().Value(ctxkeys.TenantID).(string); ok && tenantID != "" {
+		return "tenant_" + tenantID
+	}
+	if userID := r.Context().Value(ctxkeys.UserID); userID != nil {
+		return fmt.Sprintf("user_%v", userID)
+	}
+	return "ip_" + r.RemoteAddr
+}
+
+func getRouteTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return template
+}
+
+func (am *AuthMiddleware) getRateLimitTier(ctx context.Context) string {
+	if tenant, ok := ctx.Value(ctxkeys.Tenant).(*models.Tenant); ok && tenant != nil && tenant.RateLimitTier != "" {
+		return tenant.RateLimitTier
+	}
+	return am.getUserTier(ctx)
+}
+
 func (am *AuthMiddleware) getUserTier(ctx context.Context) string {
 	roles := ctx.Value(ctxkeys.UserRoles)
 	if roles == nil {
@@ -0,0 +1,272 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/security"
+)
+
+func testRateLimitTiers() map[string]security.RateLimitConfig {
+	return map[string]security.RateLimitConfig{
+		"default":  {RequestsPerSecond: 0.001, Burst: 1, Window: time.Minute},
+		"standard": {RequestsPerSecond: 0.001, Burst: 2, Window: time.Minute},
+		"premium":  {RequestsPerSecond: 0.001, Burst: 5, Window: time.Minute},
+	}
+}
+
+func allowedRequests(handler http.Handler, tenant *models.Tenant, attempts int) int {
+	allowed := 0
+	for i := 0; i < attempts; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/v1/payments/pay_1", nil)
+		ctx := context.WithValue(req.Context(), ctxkeys.TenantID, tenant.ID)
+		ctx = context.WithValue(ctx, ctxkeys.Tenant, tenant)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(ctx))
+		if rec.Code == http.StatusOK {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestRateLimitUsesTenantTier(t *testing.T) {
+	limiter := security.CreateTieredRateLimiter(testRateLimitTiers())
+	defer limiter.Close()
+
+	am := CreateAuthMiddleware(nil, limiter, nil)
+	handler := am.RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	premium := allowedRequests(handler, &models.Tenant{ID: "tenant_premium", RateLimitTier: "premium"}, 10)
+	standard := allowedRequests(handler, &models.Tenant{ID: "tenant_standard", RateLimitTier: "standard"}, 10)
+	unknown := allowedRequests(handler, &models.Tenant{ID: "tenant_unknown", RateLimitTier: "gold"}, 10)
+
+	if premium != 5 {
+		t.Fatalf("expected premium tenant to get a burst of 5, got %d", premium)
+	}
+	if standard != 2 {
+		t.Fatalf("expected standard tenant to get a burst of 2, got %d", standard)
+	}
+	if unknown != 1 {
+		t.Fatalf("expected unknown tier to fall back to default burst of 1, got %d", unknown)
+	}
+}
+
+func TestRateLimitTiersHotReload(t *testing.T) {
+	limiter := security.CreateTieredRateLimiter(testRateLimitTiers())
+	defer limiter.Close()
+
+	am := CreateAuthMiddleware(nil, limiter, nil)
+	handler := am.RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tenant := &models.Tenant{ID: "tenant_reload", RateLimitTier: "standard"}
+	if got := allowedRequests(handler, tenant, 5); got != 2 {
+		t.Fatalf("expected 2 requests before reload, got %d", got)
+	}
+
+	tiers := testRateLimitTiers()
+	tiers["standard"] = security.RateLimitConfig{RequestsPerSecond: 1000, Burst: 1000, Window: time.Minute}
+	limiter.SetTiers(tiers)
+
+	if got := allowedRequests(handler, tenant, 5); got != 5 {
+		t.Fatalf("expected reloaded tier to allow all 5 requests, got %d", got)
+	}
+}
+
+func TestRateLimitThrottlesRouteIndependently(t *testing.T) {
+	tiers := testRateLimitTiers()
+	tiers["premium"] = security.RateLimitConfig{RequestsPerSecond: 1000, Burst: 1000, Window: time.Minute}
+	limiter := security.CreateTieredRateLimiter(tiers)
+	defer limiter.Close()
+	limiter.SetRouteLimits(map[string]security.RateLimitConfig{
+		"/v1/fraud/analyze": {RequestsPerSecond: 0.001, Burst: 2, Window: time.Minute},
+	})
+
+	tenant := &models.Tenant{ID: "tenant_1", RateLimitTier: "premium"}
+	am := CreateAuthMiddleware(nil, limiter, nil)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/v1").Subrouter()
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), ctxkeys.TenantID, tenant.ID)
+			ctx = context.WithValue(ctx, ctxkeys.Tenant, tenant)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	api.Use(am.RateLimitMiddleware)
+	api.HandleFunc("/fraud/analyze", ok).Methods("POST")
+	api.HandleFunc("/payments/{id}", ok).Methods("GET")
+
+	serve := func(method, path string) int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := serve(http.MethodPost, "/v1/fraud/analyze"); code != http.StatusOK {
+			t.Fatalf("request %d to /fraud/analyze: expected 200, got %d", i, code)
+		}
+	}
+	if code := serve(http.MethodPost, "/v1/fraud/analyze"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected /fraud/analyze to be throttled, got %d", code)
+	}
+
+	for _, id := range []string{"pay_1", "pay_2", "pay_3"} {
+		if code := serve(http.MethodGet, "/v1/payments/"+id); code != http.StatusOK {
+			t.Fatalf("expected /payments/%s to be allowed, got %d", id, code)
+		}
+	}
+}
+
+func TestIPRateLimitRunsBeforeAuth(t *testing.T) {
+	limiter := security.CreateTieredRateLimiter(testRateLimitTiers())
+	defer limiter.Close()
+	limiter.SetIPLimit(security.RateLimitConfig{RequestsPerSecond: 0.001, Burst: 3, Window: time.Minute})
+
+	am := CreateAuthMiddleware(nil, limiter, nil)
+	authCalls := 0
+	rejectAuth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authCalls++
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	}
+	handler := am.IPRateLimitMiddleware(rejectAuth(nil))
+
+	serve := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/payments", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := serve("203.0.113.7:4000"); code != http.StatusUnauthorized {
+			t.Fatalf("request %d: expected auth to run, got %d", i, code)
+		}
+	}
+	if code := serve("203.0.113.7:4001"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the IP to be throttled before auth, got %d", code)
+	}
+	if authCalls != 3 {
+		t.Fatalf("expected throttled request to skip auth, got %d auth calls", authCalls)
+	}
+	if code := serve("198.51.100.9:4000"); code != http.StatusUnauthorized {
+		t.Fatalf("expected a different IP to keep its own budget, got %d", code)
+	}
+}
+
+func TestDefaultRouteLimitsCoverBatchCharges(t *testing.T) {
+	tiers := testRateLimitTiers()
+	tiers["premium"] = security.RateLimitConfig{RequestsPerSecond: 1000, Burst: 1000, Window: time.Minute}
+	limiter := security.CreateTieredRateLimiter(tiers)
+	defer limiter.Close()
+	limiter.SetRouteLimits(security.DefaultRouteLimits())
+
+	tenant := &models.Tenant{ID: "tenant_batch", RateLimitTier: "premium"}
+	am := CreateAuthMiddleware(nil, limiter, nil)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/v1").Subrouter()
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), ctxkeys.TenantID, tenant.ID)
+			ctx = context.WithValue(ctx, ctxkeys.Tenant, tenant)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	api.Use(am.RateLimitMiddleware)
+	api.HandleFunc("/charges/batch", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }).Methods("POST")
+
+	burst := security.DefaultRouteLimits()["/v1/charges/batch"].Burst
+	throttled := false
+	for i := 0; i <= burst; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/charges/batch", nil))
+		if rec.Code == http.StatusTooManyRequests {
+			throttled = i == burst
+			break
+		}
+	}
+	if !throttled {
+		t.Fatalf("expected /v1/charges/batch to be throttled after %d requests", burst)
+	}
+}
+
+func TestRateLimitReportsHeadersUntilExhausted(t *testing.T) {
+	limiter := security.CreateTieredRateLimiter(testRateLimitTiers())
+	defer limiter.Close()
+
+	am := CreateAuthMiddleware(nil, limiter, nil)
+	handler := am.RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tenant := &models.Tenant{ID: "tenant_headers", RateLimitTier: "premium"}
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/payments/pay_1", nil)
+		ctx := context.WithValue(req.Context(), ctxkeys.TenantID, tenant.ID)
+		ctx = context.WithValue(ctx, ctxkeys.Tenant, tenant)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(ctx))
+		return rec
+	}
+	header := func(rec *httptest.ResponseRecorder, name string) int {
+		t.Helper()
+		value, err := strconv.Atoi(rec.Header().Get(name))
+		if err != nil {
+			t.Fatalf("expected numeric %s header, got %q", name, rec.Header().Get(name))
+		}
+		return value
+	}
+
+	previous := 5
+	for i := 0; i < 5; i++ {
+		rec := serve()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, rec.Code)
+		}
+		if limit := header(rec, "X-RateLimit-Limit"); limit != 5 {
+			t.Fatalf("expected X-RateLimit-Limit 5, got %d", limit)
+		}
+		remaining := header(rec, "X-RateLimit-Remaining")
+		if remaining >= previous {
+			t.Fatalf("request %d: expected remaining to drop below %d, got %d", i, previous, remaining)
+		}
+		previous = remaining
+		if header(rec, "X-RateLimit-Reset") <= 0 {
+			t.Fatalf("request %d: expected a positive X-RateLimit-Reset", i)
+		}
+		if rec.Header().Get("Retry-After") != "" {
+			t.Fatalf("request %d: did not expect Retry-After on an allowed request", i)
+		}
+	}
+
+	rec := serve()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the burst is spent, got %d", rec.Code)
+	}
+	if remaining := header(rec, "X-RateLimit-Remaining"); remaining != 0 {
+		t.Fatalf("expected X-RateLimit-Remaining 0 when throttled, got %d", remaining)
+	}
+	// At 0.001 rps the next token is roughly 1000 seconds away.
+	if retryAfter := header(rec, "Retry-After"); retryAfter < 900 || retryAfter > 1000 {
+		t.Fatalf("expected Retry-After near 1000 seconds, got %d", retryAfter)
+	}
+}
@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,6 +14,12 @@ import (
 	"golang.org/x/time/rate"
 )
 
+const (
+	CorrelationIDHeader    = "X-Correlation-ID"
+	RequestIDHeader        = "X-Request-ID"
+	maxCorrelationIDLength = 128
+)
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -27,10 +35,17 @@ func CreateLoggingMiddleware(next http.Handler) http.Handler {
 		start := time.Now()
 		rw := &responseWriter{w, http.StatusOK}
 
-		correlationID := r.Header.Get("X-Correlation-ID")
+		// Gateways commonly send X-Request-ID, so it is accepted as an alias
+		// and both headers echo the one ID the logs are tagged with.
+		correlationID := r.Header.Get(CorrelationIDHeader)
 		if correlationID == "" {
+			correlationID = r.Header.Get(RequestIDHeader)
+		}
+		if !isValidCorrelationID(correlationID) {
 			correlationID = generateCorrelationID()
 		}
+		w.Header().Set(CorrelationIDHeader, correlationID)
+		w.Header().Set(RequestIDHeader, correlationID)
 
 		ctx := utils.CreateWithCorrelationID(r.Context(), correlationID)
 		r = r.WithContext(ctx)
@@ -59,7 +74,8 @@ func CreateCORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handl
 			}
 
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Correlation-ID")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Correlation-ID, X-Request-ID")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Correlation-ID, X-Request-ID, Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.Header().Set("Access-Control-Max-Age", "86400")
 
@@ -165,5 +181,23 @@ func CreateBasicAuthMiddleware(next http.Handler) http.Handler {
 }
 
 func generateCorrelationID() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// isValidCorrelationID accepts caller-supplied IDs only if they are short and
+// printable so they are safe to echo back and write to logs.
+func isValidCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/utils"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	flags := log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func logEntries(t *testing.T, buf *bytes.Buffer) []utils.LogEntry {
+	t.Helper()
+	var entries []utils.LogEntry
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry utils.LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func serveLogged(r *http.Request) *httptest.ResponseRecorder {
+	handler := CreateLoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.CreateLogger("payments").Info(r.Context(), "charging customer")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestLoggingMiddlewareTagsLogsWithGeneratedRequestID(t *testing.T) {
+	buf := captureLogs(t)
+
+	rec := serveLogged(httptest.NewRequest(http.MethodPost, "/v1/charges", nil))
+
+	requestID := rec.Header().Get(RequestIDHeader)
+	if requestID == "" {
+		t.Fatal("expected a generated X-Request-ID response header")
+	}
+	entries := logEntries(t, buf)
+	if len(entries) != 2 {
+		t.Fatalf("expected handler and access log entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.CorrelationID != requestID {
+			t.Fatalf("expected log entry %q to carry request ID %s, got %q", entry.Message, requestID, entry.CorrelationID)
+		}
+	}
+}
+
+func TestLoggingMiddlewareHonorsInboundRequestID(t *testing.T) {
+	buf := captureLogs(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/charges", nil)
+	req.Header.Set(RequestIDHeader, "req-from-gateway-42")
+	rec := serveLogged(req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "req-from-gateway-42" {
+		t.Fatalf("expected inbound request ID to be echoed, got %q", got)
+	}
+	for _, entry := range logEntries(t, buf) {
+		if entry.CorrelationID != "req-from-gateway-42" {
+			t.Fatalf("expected inbound request ID in logs, got %q", entry.CorrelationID)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/charges", nil)
+	req.Header.Set(CorrelationIDHeader, "corr-7")
+	req.Header.Set(RequestIDHeader, "req-8")
+	rec = serveLogged(req)
+	if rec.Header().Get(CorrelationIDHeader) != "corr-7" || rec.Header().Get(RequestIDHeader) != "corr-7" {
+		t.Fatalf("expected X-Correlation-ID to win and be echoed in both headers, got %q and %q",
+			rec.Header().Get(CorrelationIDHeader), rec.Header().Get(RequestIDHeader))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/charges", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	if got := serveLogged(req).Header().Get(RequestIDHeader); got == "" || strings.ContainsAny(got, " \n") {
+		t.Fatalf("expected unsafe inbound request ID to be replaced, got %q", got)
+	}
+}
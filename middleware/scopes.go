@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/malwarebo/conductor/models"
+)
+
+// scopeAny marks routes any valid API key may call regardless of its scopes.
+const scopeAny = ""
+
+// routeScopes maps "METHOD /template" to the scope an API key needs. Routes
+// missing from the map are closed to API keys, so a new route has to be
+// listed here before scoped keys can reach it.
+var routeScopes = map[string]string{
+	"GET /v1/health":       scopeAny,
+	"GET /v1/capabilities": scopeAny,
+
+	"POST /v1/charges":                        "charges:write",
+	"POST /v1/charges/batch":                  "charges:write",
+	"POST /v1/authorize":                      "charges:write",
+	"GET /v1/payments":                        "payments:read",
+	"GET /v1/payments/{id}":                   "payments:read",
+	"GET /v1/payments/{id}/provider-response": "admin:read",
+	"POST /v1/payments/{id}/capture":          "payments:write",
+	"POST /v1/payments/{id}/void":             "payments:write",
+	"POST /v1/payments/{id}/reverse":          "payments:write",
+	"POST /v1/payments/{id}/confirm":          "payments:write",
+	"POST /v1/payments/{id}/3ds-callback":     "payments:write",
+	"POST /v1/refunds":                        "refunds:write",
+
+	"POST /v1/scheduled-payments":             "charges:write",
+	"GET /v1/scheduled-payments":              "payments:read",
+	"GET /v1/scheduled-payments/{id}":         "payments:read",
+	"POST /v1/scheduled-payments/{id}/cancel": "charges:write",
+
+	"POST /v1/payment-sessions":              "payment-sessions:write",
+	"GET /v1/payment-sessions":               "payment-sessions:read",
+	"GET /v1/payment-sessions/{id}":          "payment-sessions:read",
+	"PATCH /v1/payment-sessions/{id}":        "payment-sessions:write",
+	"POST /v1/payment-sessions/{id}/confirm": "payment-sessions:write",
+	"POST /v1/payment-sessions/{id}/capture": "payment-sessions:write",
+	"POST /v1/payment-sessions/{id}/cancel":  "payment-sessions:write",
+	"POST /v1/checkout-sessions":             "checkout-sessions:write",
+	"GET /v1/checkout-sessions/{id}":         "checkout-sessions:read",
+
+	"POST /v1/plans":        "plans:write",
+	"GET /v1/plans":         "plans:read",
+	"GET /v1/plans/{id}":    "plans:read",
+	"PUT /v1/plans/{id}":    "plans:write",
+	"DELETE /v1/plans/{id}": "plans:write",
+
+	"POST /v1/subscriptions":            "subscriptions:write",
+	"GET /v1/subscriptions":             "subscriptions:read",
+	"GET /v1/subscriptions/{id}":        "subscriptions:read",
+	"PUT /v1/subscriptions/{id}":        "subscriptions:write",
+	"DELETE /v1/subscriptions/{id}":     "subscriptions:write",
+	"POST /v1/subscriptions/{id}/usage": "subscriptions:write",
+
+	"POST /v1/disputes":               "disputes:write",
+	"GET /v1/disputes":                "disputes:read",
+	"GET /v1/disputes/stats":          "disputes:read",
+	"GET /v1/disputes/{id}":           "disputes:read",
+	"PUT /v1/disputes/{id}":           "disputes:write",
+	"POST /v1/disputes/{id}/accept":   "disputes:write",
+	"POST /v1/disputes/{id}/contest":  "disputes:write",
+	"POST /v1/disputes/{id}/evidence": "disputes:write",
+
+	"POST /v1/fraud/analyze": "fraud:write",
+	"GET /v1/fraud/stats":    "fraud:read",
+
+	"POST /v1/tenants":                            "tenants:write",
+	"GET /v1/tenants":                             "tenants:read",
+	"GET /v1/tenants/{id}":                        "tenants:read",
+	"PUT /v1/tenants/{id}":                        "tenants:write",
+	"DELETE /v1/tenants/{id}":                     "tenants:write",
+	"POST /v1/tenants/{id}/deactivate":            "tenants:write",
+	"POST /v1/tenants/{id}/restore":               "admin:write",
+	"POST /v1/tenants/{id}/regenerate-secret":     "tenants:write",
+	"POST /v1/tenants/{id}/rotate-encryption-key": "tenants:write",
+
+	"POST /v1/api-keys":        "api-keys:write",
+	"GET /v1/api-keys":         "api-keys:read",
+	"DELETE /v1/api-keys/{id}": "api-keys:write",
+
+	"GET /v1/audit-logs": "audit-logs:read",
+	"GET /v1/audit-logs/{resource_type}/{resource_id}": "audit-logs:read",
+
+	"GET /v1/webhook-deliveries":                 "webhook-deliveries:read",
+	"POST /v1/webhook-deliveries/{id}/redeliver": "webhook-deliveries:write",
+
+	"POST /v1/webhook-endpoints":        "webhook-endpoints:write",
+	"GET /v1/webhook-endpoints":         "webhook-endpoints:read",
+	"GET /v1/webhook-endpoints/{id}":    "webhook-endpoints:read",
+	"PUT /v1/webhook-endpoints/{id}":    "webhook-endpoints:write",
+	"DELETE /v1/webhook-endpoints/{id}": "webhook-endpoints:write",
+
+	"GET /v1/webhook-events/dead-letter":     "admin:read",
+	"POST /v1/webhook-events/{id}/reprocess": "admin:write",
+
+	"POST /v1/webhooks/{provider}/{eventID}/reprocess": "admin:write",
+
+	"POST /v1/database/failover": "admin:write",
+	"POST /v1/database/failback": "admin:write",
+
+	"POST /v1/invoices":             "invoices:write",
+	"GET /v1/invoices":              "invoices:read",
+	"GET /v1/invoices/{id}":         "invoices:read",
+	"POST /v1/invoices/{id}/cancel": "invoices:write",
+
+	"POST /v1/payouts":             "payouts:write",
+	"GET /v1/payouts":              "payouts:read",
+	"GET /v1/payouts/{id}":         "payouts:read",
+	"POST /v1/payouts/{id}/cancel": "payouts:write",
+	"GET /v1/payout-channels":      "payouts:read",
+	"GET /v1/fx/quote":             "payouts:read",
+
+	"POST /v1/payout-schedules":          "payouts:write",
+	"GET /v1/payout-schedules":           "payouts:read",
+	"GET /v1/payout-schedules/{id}":      "payouts:read",
+	"PATCH /v1/payout-schedules/{id}":    "payouts:write",
+	"DELETE /v1/payout-schedules/{id}":   "payouts:write",
+	"GET /v1/payout-schedules/{id}/runs": "payouts:read",
+
+	"POST /v1/customers":        "customers:write",
+	"GET /v1/customers":         "customers:read",
+	"GET /v1/customers/{id}":    "customers:read",
+	"PUT /v1/customers/{id}":    "customers:write",
+	"DELETE /v1/customers/{id}": "customers:write",
+
+	"POST /v1/payment-methods":                  "payment-methods:write",
+	"GET /v1/payment-methods":                   "payment-methods:read",
+	"GET /v1/payment-methods/{id}":              "payment-methods:read",
+	"POST /v1/payment-methods/{id}/attach":      "payment-methods:write",
+	"POST /v1/payment-methods/{id}/detach":      "payment-methods:write",
+	"POST /v1/payment-methods/{id}/expire":      "payment-methods:write",
+	"POST /v1/payment-methods/{id}/verify":      "payment-methods:write",
+	"POST /v1/payment-methods/{id}/set-default": "payment-methods:write",
+
+	"GET /v1/balance": "balance:read",
+}
+
+// requiredScope returns the scope the route needs and whether the route is
+// open to API keys at all. HEAD is checked as GET.
+func requiredScope(r *http.Request) (string, bool) {
+	path := getRouteTemplate(r)
+	if path == "" {
+		path = r.URL.Path
+	}
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	scope, ok := routeScopes[method+" "+path]
+	return scope, ok
+}
+
+// tenantRoutePrefix marks the routes acting on the tenant named by {id}.
+const tenantRoutePrefix = "/v1/tenants/{id}"
+
+// crossTenantScope returns the admin scope a key needs on top of the route's
+// own scope when the route acts on a tenant other than the key's, or "" when
+// it does not.
+func crossTenantScope(r *http.Request, key *models.APIKey) string {
+	if !strings.HasPrefix(getRouteTemplate(r), tenantRoutePrefix) || mux.Vars(r)["id"] == key.TenantID {
+		return ""
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return models.APIKeyScopeAdminRead
+	}
+	return models.APIKeyScopeAdminWrite
+}
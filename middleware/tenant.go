@@ -8,18 +8,35 @@ import (
 	"time"
 
 	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/internal/redact"
+	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/services"
 )
 
 type TenantMiddleware struct {
-	tenantService *services.TenantService
-	auditService  *services.AuditService
+	tenantService     *services.TenantService
+	auditService      *services.AuditService
+	auditRedactor     *redact.Redactor
+	auditMaxBodyBytes int
 }
 
 func CreateTenantMiddleware(tenantService *services.TenantService, auditService *services.AuditService) *TenantMiddleware {
 	return &TenantMiddleware{
-		tenantService: tenantService,
-		auditService:  auditService,
+		tenantService:     tenantService,
+		auditService:      auditService,
+		auditRedactor:     redact.CreateRedactor(redact.DefaultPaths),
+		auditMaxBodyBytes: defaultAuditMaxBodyBytes,
+	}
+}
+
+// SetAuditBodyCapture configures how request and response bodies are redacted
+// and how much of each is kept in audit records.
+func (tm *TenantMiddleware) SetAuditBodyCapture(redactor *redact.Redactor, maxBodyBytes int) {
+	if redactor != nil {
+		tm.auditRedactor = redactor
+	}
+	if maxBodyBytes > 0 {
+		tm.auditMaxBodyBytes = maxBodyBytes
 	}
 }
 
@@ -30,6 +47,19 @@ func (tm *TenantMiddleware) TenantContextMiddleware(next http.Handler) http.Hand
 			return
 		}
 
+		if key, ok := r.Context().Value(ctxkeys.ScopedAPIKey).(*models.APIKey); ok {
+			tenant, err := tm.tenantService.GetByID(r.Context(), key.TenantID)
+			if err != nil || !tenant.IsActive {
+				tm.writeErrorResponse(w, http.StatusUnauthorized, "Invalid API key")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), ctxkeys.TenantID, tenant.ID)
+			ctx = context.WithValue(ctx, ctxkeys.Tenant, tenant)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		apiKey := tm.extractAPIKey(r)
 		if apiKey == "" {
 			tm.writeErrorResponse(w, http.StatusUnauthorized, "API key required")
@@ -57,8 +87,12 @@ func (tm *TenantMiddleware) AuditMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		start := time.Now()
-		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		limit := tm.auditMaxBodyBytes
+		reqBody, reqSize := captureRequestBody(r, limit)
+		rw := &auditResponseWriter{
+			responseWriter: &responseWriter{ResponseWriter: w, statusCode: http.StatusOK},
+			limit:          limit,
+		}
 
 		next.ServeHTTP(rw, r)
 
@@ -72,6 +106,10 @@ func (tm *TenantMiddleware) AuditMiddleware(next http.Handler) http.Handler {
 			userID = uid.(string)
 		}
 
+		requestBody := auditBody(tm.auditRedactor, reqBody, reqSize, limit)
+		responseBody := auditBody(tm.auditRedactor, rw.body.Bytes(), rw.size, limit)
+		statusCode := rw.statusCode
+
 		go func() {
 			_ = tm.auditService.LogAPIRequest(
 				context.Background(),
@@ -81,14 +119,13 @@ func (tm *TenantMiddleware) AuditMiddleware(next http.Handler) http.Handler {
 				r.URL.Path,
 				getClientIP(r),
 				r.UserAgent(),
-				nil,
-				rw.statusCode,
-				rw.statusCode < 400,
+				requestBody,
+				responseBody,
+				statusCode,
+				statusCode < 400,
 				"",
 			)
 		}()
-
-		_ = start
 	})
 }
 
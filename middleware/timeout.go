@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/malwarebo/conductor/api"
+	"github.com/malwarebo/conductor/models"
+)
+
+// DefaultRequestTimeout bounds a request when the server config does not
+// set one.
+const DefaultRequestTimeout = 30 * time.Second
+
+// CreateTimeoutMiddleware cancels each request's context once timeout has
+// passed and answers 503 if the handler has not finished by then, so a stuck
+// provider or database call cannot hold the connection. Handlers must pass
+// r.Context() down for their calls to be canceled. Their response is
+// buffered until they return so a late write cannot follow the 503; requests
+// under exemptPrefixes, such as streaming routes, are passed through as is.
+// A non-positive timeout uses DefaultRequestTimeout.
+func CreateTimeoutMiddleware(timeout time.Duration, exemptPrefixes ...string) func(http.Handler) http.Handler {
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range exemptPrefixes {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				// Re-raised on the request's goroutine so the recovery
+				// middleware sees it.
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for k, v := range tw.header {
+					dst[k] = v
+				}
+				if tw.code == 0 {
+					tw.code = http.StatusOK
+				}
+				w.WriteHeader(tw.code)
+				_, _ = w.Write(tw.body.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				api.WriteError(w, http.StatusServiceUnavailable, models.ErrCodeServiceUnavailable, "Request timed out")
+			}
+		})
+	}
+}
+
+// timeoutWriter collects a handler's response so it can be discarded if the
+// request times out first.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.body.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
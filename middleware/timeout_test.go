@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+func TestTimeoutMiddlewareReturns503AndCancelsContext(t *testing.T) {
+	canceled := make(chan error, 1)
+	handler := CreateTimeoutMiddleware(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			canceled <- r.Context().Err()
+		case <-time.After(5 * time.Second):
+			canceled <- nil
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/charges", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var body models.APIError
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("expected a JSON body: %v", err)
+	}
+	if body.Code != models.ErrCodeServiceUnavailable || body.Message != "Request timed out" {
+		t.Fatalf("unexpected body: %v", body)
+	}
+
+	select {
+	case err := <-canceled:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the handler's context to be canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler did not observe cancellation")
+	}
+}
+
+func TestTimeoutMiddlewarePassesFastResponses(t *testing.T) {
+	handler := CreateTimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"pay_1"}`))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/charges", nil))
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"id":"pay_1"}` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected the handler's response, got %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+}
+
+func TestTimeoutMiddlewareSkipsExemptPaths(t *testing.T) {
+	handler := CreateTimeoutMiddleware(10*time.Millisecond, "/v1/stream")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		if r.Context().Err() != nil {
+			t.Error("expected an exempt request's context not to be canceled")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/stream/events", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for an exempt path, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+// Address is a postal address on a charge. Country is the two-letter ISO
+// 3166-1 code.
+type Address struct {
+	Line1      string `json:"line1,omitempty"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city,omitempty"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+	Country    string `json:"country"`
+}
+
+// ShippingAddress is where a charge's order is sent and who receives it.
+type ShippingAddress struct {
+	Name    string  `json:"name"`
+	Phone   string  `json:"phone,omitempty"`
+	Address Address `json:"address"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	APIKeySecretPrefix    = "sk_"
+	APIKeyScopeAll        = "*"
+	APIKeyScopeAdminRead  = "admin:read"
+	APIKeyScopeAdminWrite = "admin:write"
+)
+
+type APIKey struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID   string     `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"not null"`
+	SecretHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// HasScope reports whether the key grants scope. A key matches on an exact
+// scope, "*", or a resource wildcard such as "charges:*".
+func (k *APIKey) HasScope(scope string) bool {
+	resource, _, _ := strings.Cut(scope, ":")
+	for _, granted := range k.Scopes {
+		if granted == APIKeyScopeAll || granted == scope || granted == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && now.After(*k.ExpiresAt)
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type APIKeyResponse struct {
+	APIKey *APIKey `json:"api_key"`
+	Secret string  `json:"secret,omitempty"`
+}
+
+type APIKeyListResponse struct {
+	APIKeys []*APIKey `json:"api_keys"`
+}
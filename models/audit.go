@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"github.com/malwarebo/conductor/internal/pagination"
 )
 
 type AuditLog struct {
@@ -16,6 +18,7 @@ type AuditLog struct {
 	RequestMethod string                 `json:"request_method"`
 	RequestPath   string                 `json:"request_path"`
 	RequestBody   JSON                   `json:"request_body" gorm:"type:jsonb"`
+	ResponseBody  JSON                   `json:"response_body" gorm:"type:jsonb"`
 	ResponseCode  int                    `json:"response_code"`
 	Success       bool                   `json:"success" gorm:"not null"`
 	ErrorMessage  string                 `json:"error_message"`
@@ -35,8 +38,10 @@ const (
 	AuditActionCapture      AuditAction = "capture"
 	AuditActionRefund       AuditAction = "refund"
 	AuditActionVoid         AuditAction = "void"
+	AuditActionCancel       AuditAction = "cancel"
 	AuditAction3DSChallenge AuditAction = "3ds_challenge"
 	AuditActionWebhook      AuditAction = "webhook"
+	AuditActionReconcile    AuditAction = "reconcile"
 	AuditActionLogin        AuditAction = "login"
 	AuditActionLogout       AuditAction = "logout"
 )
@@ -62,5 +67,10 @@ type AuditLogFilter struct {
 	StartDate    *time.Time
 	EndDate      *time.Time
 	Limit        int
-	Offset       int
+	Cursor       *pagination.Cursor
+}
+
+// AuditLogKey is an audit log's position in a page.
+func AuditLogKey(log *AuditLog) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: log.CreatedAt, ID: log.ID}
 }
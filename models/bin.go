@@ -103,6 +103,27 @@ type RoutingDecision struct {
 	CreatedAt         time.Time       `json:"created_at"`
 }
 
+// RoutingReason is why a charge was sent to the provider that took it.
+type RoutingReason string
+
+const (
+	RoutingReasonCurrency RoutingReason = "currency_match"
+	RoutingReasonExplicit RoutingReason = "explicit"
+	RoutingReasonAIRouted RoutingReason = "ai_routed"
+	RoutingReasonFailover RoutingReason = "failover"
+	RoutingReasonDefault  RoutingReason = "default"
+)
+
+// RoutingExplanation tells merchants which provider took a charge and why.
+// Confidence is the routing engine's score for the provider and is only set
+// when the charge was AI-routed.
+type RoutingExplanation struct {
+	SelectedProvider string        `json:"selected_provider"`
+	Reason           RoutingReason `json:"reason"`
+	Confidence       float64       `json:"confidence,omitempty"`
+	Detail           string        `json:"detail,omitempty"`
+}
+
 type AttemptResult struct {
 	Provider       string    `json:"provider"`
 	Success        bool      `json:"success"`
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+type CheckoutSessionStatus string
+
+const (
+	CheckoutSessionStatusOpen     CheckoutSessionStatus = "open"
+	CheckoutSessionStatusComplete CheckoutSessionStatus = "complete"
+	CheckoutSessionStatusExpired  CheckoutSessionStatus = "expired"
+)
+
+// CheckoutSession is a provider-hosted payment page. Customers are sent to
+// URL and the session completes when the provider reports the payment.
+type CheckoutSession struct {
+	ID                string                `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID          *string               `json:"tenant_id" gorm:"index"`
+	CustomerID        string                `json:"customer_id,omitempty" gorm:"index"`
+	ProviderName      string                `json:"provider_name" gorm:"not null"`
+	ProviderSessionID string                `json:"provider_session_id" gorm:"index"`
+	Amount            int64                 `json:"amount" gorm:"not null"`
+	Currency          string                `json:"currency" gorm:"not null"`
+	Description       string                `json:"description,omitempty"`
+	Status            CheckoutSessionStatus `json:"status" gorm:"not null;default:'open'"`
+	URL               string                `json:"url"`
+	SuccessURL        string                `json:"success_url"`
+	CancelURL         string                `json:"cancel_url,omitempty"`
+	ProviderPaymentID string                `json:"provider_payment_id,omitempty"`
+	ExpiresAt         *time.Time            `json:"expires_at,omitempty"`
+	CompletedAt       *time.Time            `json:"completed_at,omitempty"`
+	Metadata          JSON                  `json:"metadata,omitempty" gorm:"type:jsonb"`
+	CreatedAt         time.Time             `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time             `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type CreateCheckoutSessionRequest struct {
+	CustomerID  string `json:"customer_id,omitempty"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	Description string `json:"description,omitempty"`
+	SuccessURL  string `json:"success_url"`
+	CancelURL   string `json:"cancel_url,omitempty"`
+	Provider    string `json:"provider,omitempty"`
+	Metadata    JSON   `json:"metadata,omitempty"`
+}
+
+type CheckoutSessionResponse struct {
+	CheckoutSession *CheckoutSession `json:"checkout_session"`
+}
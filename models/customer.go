@@ -6,8 +6,10 @@ import (
 
 type Customer struct {
 	ID         string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID   *string   `json:"tenant_id,omitempty" gorm:"index"`
 	ExternalID string    `json:"external_id" gorm:"uniqueIndex;not null"`
-	Email      string    `json:"email" gorm:"not null;index"`
+	Email      string    `json:"email" gorm:"not null"`
+	EmailHash  string    `json:"-" gorm:"index"`
 	Name       string    `json:"name"`
 	Phone      string    `json:"phone"`
 	Metadata   JSON      `json:"metadata" gorm:"type:jsonb"`
@@ -15,12 +17,33 @@ type Customer struct {
 	UpdatedAt  time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
+// CustomerFilter narrows a customer listing. Email and ExternalID are exact
+// matches; Email ignores case.
+type CustomerFilter struct {
+	TenantID   string
+	Email      string
+	ExternalID string
+	Limit      int
+	Offset     int
+}
+
+type CustomerListResponse struct {
+	Customers []*Customer `json:"customers"`
+	Limit     int         `json:"limit"`
+	Offset    int         `json:"offset"`
+}
+
+// CreateCustomerRequest creates a customer on Provider when set, otherwise
+// on the usual provider for Currency, otherwise on Stripe. Later reads and
+// updates go to the provider the customer was created on.
 type CreateCustomerRequest struct {
 	ExternalID string                 `json:"external_id" binding:"required"`
 	Email      string                 `json:"email" binding:"required,email"`
 	Name       string                 `json:"name"`
 	Phone      string                 `json:"phone"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Provider   string                 `json:"provider,omitempty"`
+	Currency   string                 `json:"currency,omitempty"`
 }
 
 type UpdateCustomerRequest struct {
@@ -47,6 +70,14 @@ const (
 	PMTypeCardlessEMI    PaymentMethodType = "cardless_emi"
 )
 
+const (
+	PaymentMethodStatusActive               = "active"
+	PaymentMethodStatusPending              = "pending"
+	PaymentMethodStatusRequiresVerification = "requires_verification"
+	PaymentMethodStatusVerificationFailed   = "verification_failed"
+	PaymentMethodStatusExpired              = "expired"
+)
+
 type PaymentMethod struct {
 	ID                      string            `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
 	CustomerID              string            `json:"customer_id" gorm:"not null;index"`
@@ -62,7 +93,10 @@ type PaymentMethod struct {
 	BankCode                string            `json:"bank_code,omitempty"`
 	AccountName             string            `json:"account_name,omitempty"`
 	ChannelCode             string            `json:"channel_code,omitempty"`
+	Fingerprint             string            `json:"fingerprint,omitempty" gorm:"index"`
 	IsDefault               bool              `json:"is_default" gorm:"default:false"`
+	PaymentSessionID        string            `json:"payment_session_id,omitempty" gorm:"index"`
+	VerificationURL         string            `json:"verification_url,omitempty" gorm:"-"`
 	Metadata                JSON              `json:"metadata" gorm:"type:jsonb"`
 	CreatedAt               time.Time         `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt               time.Time         `json:"updated_at" gorm:"autoUpdateTime"`
@@ -74,10 +108,28 @@ type CreatePaymentMethodRequest struct {
 	Reusable    bool                   `json:"reusable"`
 	ChannelCode string                 `json:"channel_code,omitempty"`
 	CardToken   string                 `json:"card_token,omitempty"`
+	BankAccount *BankAccountDetails    `json:"bank_account,omitempty"`
 	ReturnURL   string                 `json:"return_url,omitempty"`
 	Provider    string                 `json:"provider,omitempty"`
 	IsDefault   bool                   `json:"is_default"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+
+	// Mandate acceptance details for bank debits: the end customer's IP and
+	// user agent as seen by the merchant. Without both an offline mandate is used.
+	MandateIPAddress string `json:"mandate_ip_address,omitempty"`
+	MandateUserAgent string `json:"mandate_user_agent,omitempty"`
+}
+
+type BankAccountDetails struct {
+	AccountHolderName string `json:"account_holder_name"`
+	AccountHolderType string `json:"account_holder_type,omitempty"`
+	AccountType       string `json:"account_type,omitempty"`
+	RoutingNumber     string `json:"routing_number"`
+	AccountNumber     string `json:"account_number"`
+}
+
+type VerifyPaymentMethodRequest struct {
+	Amounts []int64 `json:"amounts"`
 }
 
 type PaymentMethodResponse struct {
@@ -91,10 +143,10 @@ type PaymentMethodListResponse struct {
 
 type ProviderMapping struct {
 	ID               string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	EntityID         string    `json:"entity_id" gorm:"not null;index:idx_entity"`
-	EntityType       string    `json:"entity_type" gorm:"not null;index:idx_entity"`
-	ProviderName     string    `json:"provider_name" gorm:"not null;index"`
-	ProviderEntityID string    `json:"provider_entity_id" gorm:"not null"`
+	EntityID         string    `json:"entity_id" gorm:"not null;uniqueIndex:provider_mappings_entity_id_entity_type_key"`
+	EntityType       string    `json:"entity_type" gorm:"not null;uniqueIndex:provider_mappings_entity_id_entity_type_key;index:idx_provider_mappings_provider_entity,priority:3"`
+	ProviderName     string    `json:"provider_name" gorm:"not null;index;index:idx_provider_mappings_provider_entity,priority:1"`
+	ProviderEntityID string    `json:"provider_entity_id" gorm:"not null;index:idx_provider_mappings_provider_entity,priority:2"`
 	CreatedAt        time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt        time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
@@ -14,19 +14,25 @@ const (
 )
 
 type Dispute struct {
-	ID            string                 `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	CustomerID    string                 `json:"customer_id" gorm:"not null;index"`
-	TransactionID string                 `json:"transaction_id" gorm:"not null;index"`
-	Amount        int64                  `json:"amount" gorm:"not null"`
-	Currency      string                 `json:"currency" gorm:"not null"`
-	Reason        string                 `json:"reason" gorm:"not null"`
-	Status        DisputeStatus          `json:"status" gorm:"not null;default:'open'"`
-	Evidence      map[string]interface{} `json:"evidence" gorm:"type:jsonb"`
-	DueBy         time.Time              `json:"due_by" gorm:"not null"`
-	ClosedAt      *time.Time             `json:"closed_at,omitempty"`
-	Metadata      map[string]interface{} `json:"metadata" gorm:"type:jsonb"`
-	CreatedAt     time.Time              `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt     time.Time              `json:"updated_at" gorm:"autoUpdateTime"`
+	ID                  string                 `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID            *string                `json:"tenant_id" gorm:"index"`
+	CustomerID          string                 `json:"customer_id" gorm:"not null;index"`
+	TransactionID       string                 `json:"transaction_id" gorm:"not null;index"`
+	ProviderName        string                 `json:"provider_name,omitempty"`
+	ProviderDisputeID   string                 `json:"provider_dispute_id,omitempty" gorm:"index"`
+	Amount              int64                  `json:"amount" gorm:"not null"`
+	Currency            string                 `json:"currency" gorm:"not null"`
+	Reason              string                 `json:"reason" gorm:"not null"`
+	Status              DisputeStatus          `json:"status" gorm:"not null;default:'open'"`
+	Evidence            map[string]interface{} `json:"evidence" gorm:"type:jsonb"`
+	DueBy               time.Time              `json:"due_by" gorm:"not null"`
+	ClosedAt            *time.Time             `json:"closed_at,omitempty"`
+	AutoSubmitEvidence  bool                   `json:"auto_submit_evidence" gorm:"not null;default:false"`
+	ReminderSentAt      *time.Time             `json:"reminder_sent_at,omitempty"`
+	EvidenceSubmittedAt *time.Time             `json:"evidence_submitted_at,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata" gorm:"type:jsonb"`
+	CreatedAt           time.Time              `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt           time.Time              `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 type Evidence struct {
@@ -41,14 +47,15 @@ type Evidence struct {
 }
 
 type CreateDisputeRequest struct {
-	CustomerID    string                 `json:"customer_id" binding:"required"`
-	TransactionID string                 `json:"transaction_id" binding:"required"`
-	Amount        int64                  `json:"amount" binding:"required"`
-	Currency      string                 `json:"currency" binding:"required"`
-	Reason        string                 `json:"reason" binding:"required"`
-	DueBy         time.Time              `json:"due_by" binding:"required"`
-	Evidence      map[string]interface{} `json:"evidence,omitempty"`
-	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	CustomerID         string                 `json:"customer_id" binding:"required"`
+	TransactionID      string                 `json:"transaction_id" binding:"required"`
+	Amount             int64                  `json:"amount" binding:"required"`
+	Currency           string                 `json:"currency" binding:"required"`
+	Reason             string                 `json:"reason" binding:"required"`
+	DueBy              time.Time              `json:"due_by" binding:"required"`
+	Evidence           map[string]interface{} `json:"evidence,omitempty"`
+	AutoSubmitEvidence bool                   `json:"auto_submit_evidence,omitempty"`
+	Metadata           map[string]interface{} `json:"metadata,omitempty"`
 }
 
 type UpdateDisputeRequest struct {
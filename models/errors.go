@@ -0,0 +1,139 @@
+package models
+
+// ErrorCode is the stable, machine-readable identifier returned with every
+// API error. Codes never change meaning once released; messages may be
+// reworded or localized, so clients should branch on the code.
+type ErrorCode string
+
+const (
+	ErrCodeInvalidRequest           ErrorCode = "invalid_request"
+	ErrCodeRequestTooLarge          ErrorCode = "request_too_large"
+	ErrCodeUnauthorized             ErrorCode = "unauthorized"
+	ErrCodeForbidden                ErrorCode = "forbidden"
+	ErrCodeNotFound                 ErrorCode = "not_found"
+	ErrCodeConflict                 ErrorCode = "conflict"
+	ErrCodeUnprocessable            ErrorCode = "unprocessable_request"
+	ErrCodeRateLimited              ErrorCode = "rate_limited"
+	ErrCodeInternal                 ErrorCode = "internal_error"
+	ErrCodeNotImplemented           ErrorCode = "not_implemented"
+	ErrCodeServiceUnavailable       ErrorCode = "service_unavailable"
+	ErrCodePaymentNotFound          ErrorCode = "payment_not_found"
+	ErrCodePaymentNotCapturable     ErrorCode = "payment_not_capturable"
+	ErrCodePaymentAlreadyCaptured   ErrorCode = "payment_already_captured"
+	ErrCodeInvalidCaptureAmount     ErrorCode = "invalid_capture_amount"
+	ErrCodePaymentDeclined          ErrorCode = "payment_declined"
+	ErrCodeInsufficientFunds        ErrorCode = "insufficient_funds"
+	ErrCodeFraudBlocked             ErrorCode = "fraud_blocked"
+	ErrCodeAmountOutOfRange         ErrorCode = "amount_out_of_range"
+	ErrCodeIdempotencyConflict      ErrorCode = "idempotency_conflict"
+	ErrCodeProviderUnavailable      ErrorCode = "provider_unavailable"
+	ErrCodeProviderNotConfigured    ErrorCode = "provider_not_configured"
+	ErrCodeProviderTimeout          ErrorCode = "provider_timeout"
+	ErrCodeCurrencyNotSupported     ErrorCode = "currency_not_supported"
+	ErrCodeCapabilityUnsupported    ErrorCode = "capability_unsupported"
+	ErrCodeDisputeNotFound          ErrorCode = "dispute_not_found"
+	ErrCodeSubscriptionNotFound     ErrorCode = "subscription_not_found"
+	ErrCodePlanNotFound             ErrorCode = "plan_not_found"
+	ErrCodeCustomerNotFound         ErrorCode = "customer_not_found"
+	ErrCodeInvoiceNotFound          ErrorCode = "invoice_not_found"
+	ErrCodePayoutNotFound           ErrorCode = "payout_not_found"
+	ErrCodePaymentMethodNotFound    ErrorCode = "payment_method_not_found"
+	ErrCodePaymentSessionNotFound   ErrorCode = "payment_session_not_found"
+	ErrCodeCheckoutSessionNotFound  ErrorCode = "checkout_session_not_found"
+	ErrCodeNoDefaultPaymentMethod   ErrorCode = "no_default_payment_method"
+	ErrCodeTenantNotFound           ErrorCode = "tenant_not_found"
+	ErrCodeTenantInactive           ErrorCode = "tenant_inactive"
+	ErrCodeTenantRequired           ErrorCode = "tenant_required"
+	ErrCodeAPIKeyNotFound           ErrorCode = "api_key_not_found"
+	ErrCodeAPIKeyRevoked            ErrorCode = "api_key_revoked"
+	ErrCodeAPIKeyExpired            ErrorCode = "api_key_expired"
+	ErrCodeScopeDenied              ErrorCode = "scope_denied"
+	ErrCodeInvalidWebhookSignature  ErrorCode = "invalid_webhook_signature"
+	ErrCodeWebhookDeliveryNotFound  ErrorCode = "webhook_delivery_not_found"
+	ErrCodeWebhookURLNotConfigured  ErrorCode = "webhook_url_not_configured"
+	ErrCodeWebhookEndpointNotFound  ErrorCode = "webhook_endpoint_not_found"
+	ErrCodeWebhookEventNotFound     ErrorCode = "webhook_event_not_found"
+	ErrCodeScheduledPaymentNotFound ErrorCode = "scheduled_payment_not_found"
+	ErrCodeInsufficientBalance      ErrorCode = "insufficient_balance"
+	ErrCodePayoutScheduleNotFound   ErrorCode = "payout_schedule_not_found"
+)
+
+// errorMessages is the default English text for each code, used when a
+// handler has nothing more specific to say. Clients that localize should key
+// their own translations on the code.
+var errorMessages = map[ErrorCode]string{
+	ErrCodeInvalidRequest:           "The request is invalid.",
+	ErrCodeRequestTooLarge:          "The request body is too large.",
+	ErrCodeUnauthorized:             "Authentication is required.",
+	ErrCodeForbidden:                "You do not have access to this resource.",
+	ErrCodeNotFound:                 "The resource was not found.",
+	ErrCodeConflict:                 "The request conflicts with the current state of the resource.",
+	ErrCodeUnprocessable:            "The request cannot be processed.",
+	ErrCodeRateLimited:              "Too many requests.",
+	ErrCodeInternal:                 "An internal error occurred.",
+	ErrCodeNotImplemented:           "This operation is not implemented.",
+	ErrCodeServiceUnavailable:       "The service is temporarily unavailable.",
+	ErrCodePaymentNotFound:          "Payment not found.",
+	ErrCodePaymentNotCapturable:     "Payment is not in a capturable state.",
+	ErrCodePaymentAlreadyCaptured:   "Payment has already been captured.",
+	ErrCodeInvalidCaptureAmount:     "Capture amount exceeds the authorized amount.",
+	ErrCodePaymentDeclined:          "Payment was declined.",
+	ErrCodeInsufficientFunds:        "Payment was declined for insufficient funds.",
+	ErrCodeFraudBlocked:             "Payment was blocked by fraud screening.",
+	ErrCodeAmountOutOfRange:         "Amount is outside the allowed range.",
+	ErrCodeIdempotencyConflict:      "Idempotency key was reused with a different request.",
+	ErrCodeProviderUnavailable:      "No payment provider is available.",
+	ErrCodeProviderNotConfigured:    "The requested provider is not configured.",
+	ErrCodeProviderTimeout:          "The payment provider did not respond in time.",
+	ErrCodeCurrencyNotSupported:     "The currency is not supported by the provider.",
+	ErrCodeCapabilityUnsupported:    "The provider does not support this operation.",
+	ErrCodeDisputeNotFound:          "Dispute not found.",
+	ErrCodeSubscriptionNotFound:     "Subscription not found.",
+	ErrCodePlanNotFound:             "Plan not found.",
+	ErrCodeCustomerNotFound:         "Customer not found.",
+	ErrCodeInvoiceNotFound:          "Invoice not found.",
+	ErrCodePayoutNotFound:           "Payout not found.",
+	ErrCodePaymentMethodNotFound:    "Payment method not found.",
+	ErrCodePaymentSessionNotFound:   "Payment session not found.",
+	ErrCodeCheckoutSessionNotFound:  "Checkout session not found.",
+	ErrCodeNoDefaultPaymentMethod:   "Customer has no default payment method.",
+	ErrCodeTenantNotFound:           "Tenant not found.",
+	ErrCodeTenantInactive:           "Tenant is inactive.",
+	ErrCodeTenantRequired:           "Tenant context is required.",
+	ErrCodeAPIKeyNotFound:           "API key not found.",
+	ErrCodeAPIKeyRevoked:            "API key has been revoked.",
+	ErrCodeAPIKeyExpired:            "API key has expired.",
+	ErrCodeScopeDenied:              "The API key does not have the required scope.",
+	ErrCodeInvalidWebhookSignature:  "Webhook signature is invalid.",
+	ErrCodeWebhookDeliveryNotFound:  "The webhook delivery was not found.",
+	ErrCodeWebhookURLNotConfigured:  "No webhook URL is configured for this tenant.",
+	ErrCodeWebhookEndpointNotFound:  "The webhook endpoint was not found.",
+	ErrCodeWebhookEventNotFound:     "The webhook event was not found.",
+	ErrCodeScheduledPaymentNotFound: "Scheduled payment not found.",
+	ErrCodeInsufficientBalance:      "The available balance does not cover the payout.",
+	ErrCodePayoutScheduleNotFound:   "Payout schedule not found.",
+}
+
+// Message returns the default message for the code.
+func (c ErrorCode) Message() string {
+	if msg, ok := errorMessages[c]; ok {
+		return msg
+	}
+	return errorMessages[ErrCodeInternal]
+}
+
+// FieldError names one request field that failed validation. Field is the
+// JSON path of the field and Code says what was wrong with it.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// APIError is the envelope every API error is written in.
+type APIError struct {
+	Code    ErrorCode              `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	Errors  []FieldError           `json:"errors,omitempty"`
+}
@@ -4,6 +4,14 @@ import (
 	"time"
 )
 
+type FraudDecisionSource string
+
+const (
+	FraudDecisionSourceAI       FraudDecisionSource = "ai"
+	FraudDecisionSourceFallback FraudDecisionSource = "fallback"
+	FraudDecisionSourceRule     FraudDecisionSource = "rule"
+)
+
 type FraudAnalysisRequest struct {
 	TransactionID       string  `json:"transaction_id"`
 	UserID              string  `json:"user_id"`
@@ -12,11 +20,23 @@ type FraudAnalysisRequest struct {
 	ShippingCountry     string  `json:"shipping_country"`
 	IPAddress           string  `json:"ip_address"`
 	TransactionVelocity int     `json:"transaction_velocity"`
+	CardFingerprint     string  `json:"card_fingerprint,omitempty"`
 }
 
 type FraudAnalysisResponse struct {
-	Allow  bool   `json:"allow"`
-	Reason string `json:"reason,omitempty"`
+	Allow         bool   `json:"allow"`
+	Reason        string `json:"reason,omitempty"`
+	RuleTriggered string `json:"rule_triggered,omitempty"`
+}
+
+// FraudRule is a hard block rule. Every condition that is set must match;
+// a rule with no conditions never matches.
+type FraudRule struct {
+	Name             string   `json:"name"`
+	MinAmount        float64  `json:"min_amount,omitempty"`
+	MinVelocity      int      `json:"min_velocity,omitempty"`
+	CountryMismatch  bool     `json:"country_mismatch,omitempty"`
+	BlockedCountries []string `json:"blocked_countries,omitempty"`
 }
 
 type OpenAIFraudAssessment struct {
@@ -26,20 +46,23 @@ type OpenAIFraudAssessment struct {
 }
 
 type FraudAnalysisResult struct {
-	ID                  string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	TransactionID       string    `json:"transaction_id" gorm:"not null;index"`
-	UserID              string    `json:"user_id" gorm:"not null;index"`
-	TransactionAmount   float64   `json:"transaction_amount" gorm:"not null"`
-	BillingCountry      string    `json:"billing_country" gorm:"not null"`
-	ShippingCountry     string    `json:"shipping_country" gorm:"not null"`
-	IPAddress           string    `json:"ip_address" gorm:"not null"`
-	TransactionVelocity int       `json:"transaction_velocity" gorm:"not null"`
-	IsFraudulent        bool      `json:"is_fraudulent" gorm:"not null"`
-	FraudScore          int       `json:"fraud_score" gorm:"not null"`
-	Reason              string    `json:"reason" gorm:"not null"`
-	Allow               bool      `json:"allow" gorm:"not null"`
-	CreatedAt           time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt           time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID                  string              `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TransactionID       string              `json:"transaction_id" gorm:"not null;index"`
+	UserID              string              `json:"user_id" gorm:"not null;index"`
+	TransactionAmount   float64             `json:"transaction_amount" gorm:"not null"`
+	BillingCountry      string              `json:"billing_country" gorm:"not null"`
+	ShippingCountry     string              `json:"shipping_country" gorm:"not null"`
+	IPAddress           string              `json:"ip_address" gorm:"not null"`
+	TransactionVelocity int                 `json:"transaction_velocity" gorm:"not null"`
+	IsFraudulent        bool                `json:"is_fraudulent" gorm:"not null"`
+	FraudScore          int                 `json:"fraud_score" gorm:"not null"`
+	Reason              string              `json:"reason" gorm:"not null"`
+	Allow               bool                `json:"allow" gorm:"not null"`
+	RuleTriggered       string              `json:"rule_triggered,omitempty"`
+	DecisionSource      FraudDecisionSource `json:"decision_source" gorm:"index"`
+	IPRisk              string              `json:"ip_risk"`
+	CreatedAt           time.Time           `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt           time.Time           `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 type FraudStatsRequest struct {
@@ -48,8 +71,10 @@ type FraudStatsRequest struct {
 }
 
 type FraudStatsResponse struct {
-	TotalTransactions               int     `json:"total_transactions"`
-	TotalFraudulentTransactions     int     `json:"total_fraudulent_transactions"`
-	AverageFraudScore               float64 `json:"average_fraud_score"`
-	FraudulentTransactionPercentage float64 `json:"fraudulent_transaction_percentage"`
+	TotalTransactions               int                         `json:"total_transactions"`
+	TotalFraudulentTransactions     int                         `json:"total_fraudulent_transactions"`
+	AverageFraudScore               float64                     `json:"average_fraud_score"`
+	FraudulentTransactionPercentage float64                     `json:"fraudulent_transaction_percentage"`
+	TotalBlockedTransactions        int                         `json:"total_blocked_transactions"`
+	DecisionSources                 map[FraudDecisionSource]int `json:"decision_sources"`
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"time"
 )
 
@@ -16,26 +18,29 @@ const (
 )
 
 type Invoice struct {
-	ID                 string        `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	TenantID           *string       `json:"tenant_id" gorm:"index"`
-	ExternalID         string        `json:"external_id" gorm:"index"`
-	ProviderID         string        `json:"provider_id" gorm:"index"`
-	ProviderName       string        `json:"provider_name" gorm:"not null"`
-	CustomerID         string        `json:"customer_id" gorm:"index"`
-	CustomerEmail      string        `json:"customer_email"`
-	Amount             int64         `json:"amount" gorm:"not null"`
-	Currency           string        `json:"currency" gorm:"not null"`
-	Status             InvoiceStatus `json:"status" gorm:"not null;default:'pending'"`
-	Description        string        `json:"description"`
-	InvoiceURL         string        `json:"invoice_url"`
-	DueDate            *time.Time    `json:"due_date"`
-	PaidAt             *time.Time    `json:"paid_at"`
-	SuccessRedirectURL string        `json:"success_redirect_url"`
-	FailureRedirectURL string        `json:"failure_redirect_url"`
-	PaymentMethods     []string      `json:"payment_methods" gorm:"type:text[]"`
-	Metadata           JSON          `json:"metadata" gorm:"type:jsonb"`
-	CreatedAt          time.Time     `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt          time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
+	ID                 string           `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID           *string          `json:"tenant_id" gorm:"index"`
+	ExternalID         string           `json:"external_id" gorm:"index"`
+	ProviderID         string           `json:"provider_id" gorm:"index"`
+	ProviderName       string           `json:"provider_name" gorm:"not null"`
+	CustomerID         string           `json:"customer_id" gorm:"index"`
+	CustomerEmail      string           `json:"customer_email"`
+	Amount             int64            `json:"amount" gorm:"not null"`
+	Currency           string           `json:"currency" gorm:"not null"`
+	Status             InvoiceStatus    `json:"status" gorm:"not null;default:'pending'"`
+	Description        string           `json:"description"`
+	InvoiceURL         string           `json:"invoice_url"`
+	DueDate            *time.Time       `json:"due_date"`
+	PaidAt             *time.Time       `json:"paid_at"`
+	SuccessRedirectURL string           `json:"success_redirect_url"`
+	FailureRedirectURL string           `json:"failure_redirect_url"`
+	PaymentMethods     []string         `json:"payment_methods" gorm:"type:text[]"`
+	LineItems          InvoiceLineItems `json:"line_items,omitempty" gorm:"type:jsonb"`
+	TaxAmount          int64            `json:"tax_amount,omitempty" gorm:"default:0"`
+	TaxBreakdown       TaxBreakdown     `json:"tax_breakdown,omitempty" gorm:"type:jsonb"`
+	Metadata           JSON             `json:"metadata" gorm:"type:jsonb"`
+	CreatedAt          time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt          time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 type CreateInvoiceRequest struct {
@@ -50,11 +55,66 @@ type CreateInvoiceRequest struct {
 	SuccessRedirectURL string                 `json:"success_redirect_url,omitempty"`
 	FailureRedirectURL string                 `json:"failure_redirect_url,omitempty"`
 	PaymentMethods     []string               `json:"payment_methods,omitempty"`
+	LineItems          []InvoiceLineItem      `json:"line_items,omitempty"`
 	SendEmail          bool                   `json:"send_email,omitempty"`
 	Provider           string                 `json:"provider,omitempty"`
+	AutomaticTax       bool                   `json:"automatic_tax,omitempty"`
+	TaxAddress         *TaxAddress            `json:"tax_address,omitempty"`
 	Metadata           map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// InvoiceLineItem is one billed line on an invoice. Amounts are in the
+// currency's minor unit, like the invoice amount itself.
+type InvoiceLineItem struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Quantity    int64  `json:"quantity"`
+	UnitAmount  int64  `json:"unit_amount"`
+}
+
+// Total is the line's amount: quantity times unit amount.
+func (i InvoiceLineItem) Total() int64 {
+	return i.Quantity * i.UnitAmount
+}
+
+// InvoiceLineItems is stored as a JSONB array on the invoice.
+type InvoiceLineItems []InvoiceLineItem
+
+// Total sums every line on the invoice.
+func (items InvoiceLineItems) Total() int64 {
+	var total int64
+	for _, item := range items {
+		total += item.Total()
+	}
+	return total
+}
+
+// Value implements the driver.Valuer interface
+func (items InvoiceLineItems) Value() (driver.Value, error) {
+	if items == nil {
+		return nil, nil
+	}
+	return json.Marshal(items)
+}
+
+// Scan implements the sql.Scanner interface
+func (items *InvoiceLineItems) Scan(value interface{}) error {
+	if value == nil {
+		*items = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, items)
+}
+
 type ListInvoicesRequest struct {
 	CustomerID string `json:"customer_id,omitempty"`
 	Status     string `json:"status,omitempty"`
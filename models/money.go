@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// currencyExponents lists the ISO 4217 currencies whose minor unit is not
+// 1/100 of the major unit.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "JPY": 0, "KMF": 0, "KRW": 0, "MGA": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// CurrencyExponent returns the number of decimals in currency's minor unit,
+// defaulting to 2 for currencies not in the table.
+func CurrencyExponent(currency string) int {
+	if exponent, ok := currencyExponents[strings.ToUpper(currency)]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// Money is an amount in the currency's minor unit (cents for USD, yen for
+// JPY). It marshals to the same amount and currency fields the API has
+// always used.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MoneyFromMajor converts a major-unit amount such as 12.34 USD, as some
+// providers report it, to Money, rounding to the nearest minor unit.
+func MoneyFromMajor(amount float64, currency string) Money {
+	return Money{
+		Amount:   int64(math.Round(amount * math.Pow10(CurrencyExponent(currency)))),
+		Currency: currency,
+	}
+}
+
+// Major returns the amount in the currency's major unit.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / math.Pow10(CurrencyExponent(m.Currency))
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if !m.sameCurrency(other) {
+		return Money{}, fmt.Errorf("%w: cannot add %s to %s", ErrCurrencyMismatch, other.Currency, m.Currency)
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if !m.sameCurrency(other) {
+		return Money{}, fmt.Errorf("%w: cannot subtract %s from %s", ErrCurrencyMismatch, other.Currency, m.Currency)
+	}
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// String formats the amount in major units followed by the currency code,
+// e.g. "12.50 USD" or "500 JPY".
+func (m Money) String() string {
+	currency := strings.ToUpper(m.Currency)
+	exponent := CurrencyExponent(currency)
+	if exponent <= 0 {
+		return fmt.Sprintf("%d %s", m.Amount, currency)
+	}
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	scale := int64(math.Pow10(exponent))
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/scale, exponent, amount%scale, currency)
+}
+
+func (m Money) sameCurrency(other Money) bool {
+	return strings.EqualFold(m.Currency, other.Currency)
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMoneyArithmetic(t *testing.T) {
+	sum, err := Money{Amount: 1250, Currency: "USD"}.Add(Money{Amount: 75, Currency: "usd"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sum != (Money{Amount: 1325, Currency: "USD"}) {
+		t.Fatalf("expected 1325 USD, got %+v", sum)
+	}
+
+	diff, err := sum.Sub(Money{Amount: 2000, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff.Amount != -675 || diff.String() != "-6.75 USD" {
+		t.Fatalf("expected -6.75 USD, got %s", diff)
+	}
+}
+
+func TestMoneyRejectsCurrencyMismatch(t *testing.T) {
+	usd := Money{Amount: 100, Currency: "USD"}
+	eur := Money{Amount: 100, Currency: "EUR"}
+
+	if _, err := usd.Add(eur); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("expected ErrCurrencyMismatch from Add, got %v", err)
+	}
+	if _, err := usd.Sub(eur); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("expected ErrCurrencyMismatch from Sub, got %v", err)
+	}
+}
+
+func TestMoneyMajorUnitsFollowCurrencyExponent(t *testing.T) {
+	tests := []struct {
+		money  Money
+		major  float64
+		format string
+	}{
+		{Money{Amount: 1999, Currency: "USD"}, 19.99, "19.99 USD"},
+		{Money{Amount: 500, Currency: "JPY"}, 500, "500 JPY"},
+		{Money{Amount: 1234, Currency: "KWD"}, 1.234, "1.234 KWD"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.Major(); got != tt.major {
+			t.Fatalf("%s: expected major %v, got %v", tt.format, tt.major, got)
+		}
+		if got := tt.money.String(); got != tt.format {
+			t.Fatalf("expected %q, got %q", tt.format, got)
+		}
+		if back := MoneyFromMajor(tt.major, tt.money.Currency); back != tt.money {
+			t.Fatalf("%s: expected round trip to %+v, got %+v", tt.format, tt.money, back)
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	req := ChargeRequest{CustomerID: "cus_1", Amount: 1999, Currency: "USD"}
+	body, err := json.Marshal(req.Money())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != `{"amount":1999,"currency":"USD"}` {
+		t.Fatalf("unexpected JSON: %s", body)
+	}
+
+	var decoded ChargeRequest
+	if err := json.Unmarshal([]byte(`{"customer_id":"cus_1","amount":1999,"currency":"USD"}`), &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded.Money() != req.Money() {
+		t.Fatalf("expected %+v, got %+v", req.Money(), decoded.Money())
+	}
+
+	var money Money
+	if err := json.Unmarshal(body, &money); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if money != req.Money() {
+		t.Fatalf("expected %+v, got %+v", req.Money(), money)
+	}
+}
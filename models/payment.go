@@ -1,7 +1,11 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"time"
+
+	"github.com/malwarebo/conductor/internal/pagination"
 )
 
 type PaymentStatus string
@@ -23,27 +27,66 @@ const (
 	CaptureMethodManual    CaptureMethod = "manual"
 )
 
+func (m CaptureMethod) Valid() bool {
+	return m == CaptureMethodAutomatic || m == CaptureMethodManual
+}
+
 type Payment struct {
-	ID               string        `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	TenantID         *string       `json:"tenant_id" gorm:"index"`
-	CustomerID       string        `json:"customer_id" gorm:"not null;index"`
-	Amount           int64         `json:"amount" gorm:"not null"`
-	Currency         string        `json:"currency" gorm:"not null"`
-	Status           PaymentStatus `json:"status" gorm:"not null;default:'pending'"`
-	PaymentMethod    string        `json:"payment_method" gorm:"not null"`
-	Description      string        `json:"description"`
-	ProviderName     string        `json:"provider_name" gorm:"not null"`
-	ProviderChargeID string        `json:"provider_charge_id" gorm:"index"`
-	CaptureMethod    CaptureMethod `json:"capture_method" gorm:"default:'automatic'"`
-	CapturedAmount   int64         `json:"captured_amount" gorm:"default:0"`
-	RequiresAction   bool          `json:"requires_action" gorm:"default:false"`
-	NextActionType   string        `json:"next_action_type"`
-	NextActionURL    string        `json:"next_action_url"`
-	IdempotencyKey   string        `json:"idempotency_key" gorm:"index"`
-	ClientSecret     string        `json:"client_secret,omitempty"`
-	Metadata         JSON          `json:"metadata" gorm:"type:jsonb"`
-	CreatedAt        time.Time     `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt        time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
+	ID                        string              `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID                  *string             `json:"tenant_id" gorm:"index"`
+	CustomerID                string              `json:"customer_id" gorm:"not null;index"`
+	Amount                    int64               `json:"amount" gorm:"not null"`
+	Currency                  string              `json:"currency" gorm:"not null"`
+	Status                    PaymentStatus       `json:"status" gorm:"not null;default:'pending'"`
+	PaymentMethod             string              `json:"payment_method" gorm:"not null"`
+	Description               string              `json:"description"`
+	StatementDescriptor       string              `json:"statement_descriptor,omitempty"`
+	StatementDescriptorSuffix string              `json:"statement_descriptor_suffix,omitempty"`
+	ProviderName              string              `json:"provider_name" gorm:"not null"`
+	ProviderChargeID          string              `json:"provider_charge_id" gorm:"index"`
+	CaptureMethod             CaptureMethod       `json:"capture_method" gorm:"default:'automatic'"`
+	CapturedAmount            int64               `json:"captured_amount" gorm:"default:0"`
+	OvercapturedAmount        int64               `json:"overcaptured_amount,omitempty" gorm:"default:0"`
+	ApplicationFeeAmount      int64               `json:"application_fee_amount" gorm:"default:0"`
+	OnBehalfOf                string              `json:"on_behalf_of"`
+	SubAccountID              string              `json:"sub_account_id,omitempty"`
+	TaxAmount                 int64               `json:"tax_amount,omitempty" gorm:"default:0"`
+	TaxBreakdown              TaxBreakdown        `json:"tax_breakdown,omitempty" gorm:"type:jsonb"`
+	TaxCalculationID          string              `json:"tax_calculation_id,omitempty"`
+	BillingAddress            *Address            `json:"billing_address,omitempty" gorm:"serializer:json;type:jsonb"`
+	ShippingAddress           *ShippingAddress    `json:"shipping_address,omitempty" gorm:"serializer:json;type:jsonb"`
+	RequiresAction            bool                `json:"requires_action" gorm:"default:false"`
+	NextActionType            string              `json:"next_action_type"`
+	NextActionURL             string              `json:"next_action_url"`
+	IdempotencyKey            string              `json:"idempotency_key" gorm:"index"`
+	ClientSecret              string              `json:"client_secret,omitempty"`
+	Metadata                  JSON                `json:"metadata" gorm:"type:jsonb"`
+	ProviderAttempts          ChargeAttempts      `json:"provider_attempts,omitempty" gorm:"type:jsonb"`
+	RoutingExplanation        *RoutingExplanation `json:"routing_explanation,omitempty" gorm:"serializer:json"`
+	Version                   int64               `json:"-" gorm:"not null;default:0"`
+	CreatedAt                 time.Time           `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt                 time.Time           `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Money returns the payment's authorized amount with its currency.
+func (p *Payment) Money() Money {
+	return Money{Amount: p.Amount, Currency: p.Currency}
+}
+
+// CapturedMoney returns how much of the payment has been captured.
+func (p *Payment) CapturedMoney() Money {
+	return Money{Amount: p.CapturedAmount, Currency: p.Currency}
+}
+
+type PaymentFilter struct {
+	TenantID string
+	Limit    int
+	Cursor   *pagination.Cursor
+}
+
+// PaymentKey is a payment's position in a page.
+func PaymentKey(p *Payment) pagination.Cursor {
+	return pagination.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
 }
 
 type Refund struct {
@@ -51,6 +94,7 @@ type Refund struct {
 	PaymentID        string    `json:"payment_id" gorm:"not null;index"`
 	Amount           int64     `json:"amount" gorm:"not null"`
 	Reason           string    `json:"reason"`
+	Note             string    `json:"note,omitempty"`
 	Status           string    `json:"status" gorm:"not null;default:'pending'"`
 	ProviderName     string    `json:"provider_name" gorm:"not null"`
 	ProviderRefundID string    `json:"provider_refund_id" gorm:"index"`
@@ -59,37 +103,72 @@ type Refund struct {
 	UpdatedAt        time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
+// AllowRedirects controls whether automatically enabled payment methods may
+// send the customer to a third-party page to complete payment.
+type AllowRedirects string
+
+const (
+	AllowRedirectsAlways AllowRedirects = "always"
+	AllowRedirectsNever  AllowRedirects = "never"
+)
+
+func (a AllowRedirects) Valid() bool {
+	return a == AllowRedirectsAlways || a == AllowRedirectsNever
+}
+
 type ChargeRequest struct {
-	CustomerID     string        `json:"customer_id"`
-	Amount         int64         `json:"amount"`
-	Currency       string        `json:"currency"`
-	PaymentMethod  string        `json:"payment_method"`
-	Description    string        `json:"description"`
-	CaptureMethod  CaptureMethod `json:"capture_method,omitempty"`
-	Capture        *bool         `json:"capture,omitempty"`
-	ReturnURL      string        `json:"return_url,omitempty"`
-	IdempotencyKey string        `json:"idempotency_key,omitempty"`
-	Provider       string        `json:"provider,omitempty"`
-	FraudCheck     *bool         `json:"fraud_check,omitempty"`
-	IPAddress      string        `json:"ip_address,omitempty"`
-	Metadata       JSON          `json:"metadata,omitempty"`
+	CustomerID                string            `json:"customer_id"`
+	Amount                    int64             `json:"amount"`
+	Currency                  string            `json:"currency"`
+	PaymentMethod             string            `json:"payment_method"`
+	PaymentMethodType         PaymentMethodType `json:"payment_method_type,omitempty"`
+	VPA                       string            `json:"vpa,omitempty"`
+	Description               string            `json:"description"`
+	StatementDescriptor       string            `json:"statement_descriptor,omitempty"`
+	StatementDescriptorSuffix string            `json:"statement_descriptor_suffix,omitempty"`
+	CaptureMethod             CaptureMethod     `json:"capture_method,omitempty"`
+	Capture                   *bool             `json:"capture,omitempty"`
+	RequestMultiCapture       bool              `json:"request_multicapture,omitempty"`
+	RequestOvercapture        bool              `json:"request_overcapture,omitempty"`
+	ReturnURL                 string            `json:"return_url,omitempty"`
+	AllowRedirects            AllowRedirects    `json:"allow_redirects,omitempty"`
+	PaymentMethodTypes        []string          `json:"payment_method_types,omitempty"`
+	IdempotencyKey            string            `json:"idempotency_key,omitempty"`
+	Provider                  string            `json:"provider,omitempty"`
+	FraudCheck                *bool             `json:"fraud_check,omitempty"`
+	IPAddress                 string            `json:"ip_address,omitempty"`
+	ApplicationFeeAmount      int64             `json:"application_fee_amount,omitempty"`
+	OnBehalfOf                string            `json:"on_behalf_of,omitempty"`
+	SubAccountID              string            `json:"sub_account_id,omitempty"`
+	AutomaticTax              bool              `json:"automatic_tax,omitempty"`
+	TaxAddress                *TaxAddress       `json:"tax_address,omitempty"`
+	BillingAddress            *Address          `json:"billing_address,omitempty"`
+	ShippingAddress           *ShippingAddress  `json:"shipping_address,omitempty"`
+	Metadata                  JSON              `json:"metadata,omitempty"`
+}
+
+func (r *ChargeRequest) Money() Money {
+	return Money{Amount: r.Amount, Currency: r.Currency}
 }
 
 type AuthorizeRequest struct {
-	CustomerID     string `json:"customer_id"`
-	Amount         int64  `json:"amount"`
-	Currency       string `json:"currency"`
-	PaymentMethod  string `json:"payment_method"`
-	Description    string `json:"description"`
-	ReturnURL      string `json:"return_url,omitempty"`
-	IdempotencyKey string `json:"idempotency_key,omitempty"`
-	Provider       string `json:"provider,omitempty"`
-	Metadata       JSON   `json:"metadata,omitempty"`
+	CustomerID          string `json:"customer_id"`
+	Amount              int64  `json:"amount"`
+	Currency            string `json:"currency"`
+	PaymentMethod       string `json:"payment_method"`
+	Description         string `json:"description"`
+	ReturnURL           string `json:"return_url,omitempty"`
+	IdempotencyKey      string `json:"idempotency_key,omitempty"`
+	Provider            string `json:"provider,omitempty"`
+	RequestMultiCapture bool   `json:"request_multicapture,omitempty"`
+	RequestOvercapture  bool   `json:"request_overcapture,omitempty"`
+	Metadata            JSON   `json:"metadata,omitempty"`
 }
 
 type CaptureRequest struct {
 	PaymentID      string `json:"payment_id"`
 	Amount         int64  `json:"amount,omitempty"`
+	Final          bool   `json:"final,omitempty"`
 	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
@@ -99,37 +178,117 @@ type VoidRequest struct {
 	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
+// ReverseAuthorizationRequest releases Amount of an authorization's hold
+// while the rest stays capturable.
+type ReverseAuthorizationRequest struct {
+	PaymentID string `json:"payment_id"`
+	Amount    int64  `json:"amount"`
+}
+
 type Confirm3DSRequest struct {
 	PaymentID string `json:"payment_id"`
 }
 
+// ThreeDSCallbackRequest carries the query parameters the provider appended
+// to the return URL after a 3DS challenge, such as Stripe's payment_intent
+// and redirect_status. They identify the payment but do not decide its
+// outcome; the provider is asked for that.
+type ThreeDSCallbackRequest struct {
+	PaymentID string            `json:"-"`
+	Params    map[string]string `json:"params"`
+}
+
 type ChargeResponse struct {
-	ID               string        `json:"id"`
-	CustomerID       string        `json:"customer_id"`
-	Amount           int64         `json:"amount"`
-	Currency         string        `json:"currency"`
-	Status           PaymentStatus `json:"status"`
-	PaymentMethod    string        `json:"payment_method"`
-	Description      string        `json:"description"`
-	ProviderName     string        `json:"provider_name"`
-	ProviderChargeID string        `json:"provider_charge_id"`
-	CaptureMethod    CaptureMethod `json:"capture_method,omitempty"`
-	CapturedAmount   int64         `json:"captured_amount,omitempty"`
-	RequiresAction   bool          `json:"requires_action,omitempty"`
-	NextActionType   string        `json:"next_action_type,omitempty"`
-	NextActionURL    string        `json:"next_action_url,omitempty"`
-	ClientSecret     string        `json:"client_secret,omitempty"`
-	Metadata         JSON          `json:"metadata,omitempty"`
-	CreatedAt        time.Time     `json:"created_at"`
+	ID                        string              `json:"id"`
+	CustomerID                string              `json:"customer_id"`
+	Amount                    int64               `json:"amount"`
+	Currency                  string              `json:"currency"`
+	Status                    PaymentStatus       `json:"status"`
+	PaymentMethod             string              `json:"payment_method"`
+	Description               string              `json:"description"`
+	StatementDescriptor       string              `json:"statement_descriptor,omitempty"`
+	StatementDescriptorSuffix string              `json:"statement_descriptor_suffix,omitempty"`
+	ProviderName              string              `json:"provider_name"`
+	ProviderChargeID          string              `json:"provider_charge_id"`
+	CaptureMethod             CaptureMethod       `json:"capture_method,omitempty"`
+	CapturedAmount            int64               `json:"captured_amount,omitempty"`
+	ApplicationFeeAmount      int64               `json:"application_fee_amount,omitempty"`
+	OnBehalfOf                string              `json:"on_behalf_of,omitempty"`
+	SubAccountID              string              `json:"sub_account_id,omitempty"`
+	TaxAmount                 int64               `json:"tax_amount,omitempty"`
+	TaxBreakdown              TaxBreakdown        `json:"tax_breakdown,omitempty"`
+	BillingAddress            *Address            `json:"billing_address,omitempty"`
+	ShippingAddress           *ShippingAddress    `json:"shipping_address,omitempty"`
+	RequiresAction            bool                `json:"requires_action,omitempty"`
+	NextActionType            string              `json:"next_action_type,omitempty"`
+	NextActionURL             string              `json:"next_action_url,omitempty"`
+	ClientSecret              string              `json:"client_secret,omitempty"`
+	Metadata                  JSON                `json:"metadata,omitempty"`
+	ProviderAttempts          ChargeAttempts      `json:"provider_attempts,omitempty"`
+	RoutingExplanation        *RoutingExplanation `json:"routing_explanation,omitempty"`
+	CreatedAt                 time.Time           `json:"created_at"`
 }
 
+// ChargeAttempts is the chain of providers a charge was tried on when
+// failover is enabled, in the order they were tried.
+type ChargeAttempts []AttemptResult
+
+// Value implements the driver.Valuer interface
+func (a ChargeAttempts) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan implements the sql.Scanner interface
+func (a *ChargeAttempts) Scan(value interface{}) error {
+	if value == nil {
+		*a = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, a)
+}
+
+type BatchChargeRequest struct {
+	Charges []ChargeRequest `json:"charges"`
+}
+
+type BatchChargeResult struct {
+	Index          int             `json:"index"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty"`
+	Success        bool            `json:"success"`
+	Charge         *ChargeResponse `json:"charge,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	Errors         []FieldError    `json:"errors,omitempty"`
+}
+
+type BatchChargeResponse struct {
+	Results   []BatchChargeResult `json:"results"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+}
+
+// CaptureResponse reports a capture. OvercapturedAmount is how much of
+// CapturedAmount is beyond the authorized amount.
 type CaptureResponse struct {
-	ID           string        `json:"id"`
-	PaymentID    string        `json:"payment_id"`
-	Amount       int64         `json:"amount"`
-	Status       PaymentStatus `json:"status"`
-	ProviderName string        `json:"provider_name"`
-	CapturedAt   time.Time     `json:"captured_at"`
+	ID                 string        `json:"id"`
+	PaymentID          string        `json:"payment_id"`
+	Amount             int64         `json:"amount"`
+	CapturedAmount     int64         `json:"captured_amount"`
+	OvercapturedAmount int64         `json:"overcaptured_amount,omitempty"`
+	Status             PaymentStatus `json:"status"`
+	ProviderName       string        `json:"provider_name"`
+	CapturedAt         time.Time     `json:"captured_at"`
 }
 
 type VoidResponse struct {
@@ -140,6 +299,19 @@ type VoidResponse struct {
 	VoidedAt     time.Time     `json:"voided_at"`
 }
 
+// ReversalResponse reports a partial reversal. AuthorizedAmount is what the
+// payment is now authorized for, of which CapturedAmount has been captured.
+type ReversalResponse struct {
+	ID               string        `json:"id"`
+	PaymentID        string        `json:"payment_id"`
+	Amount           int64         `json:"amount"`
+	AuthorizedAmount int64         `json:"authorized_amount"`
+	CapturedAmount   int64         `json:"captured_amount"`
+	Status           PaymentStatus `json:"status"`
+	ProviderName     string        `json:"provider_name"`
+	ReversedAt       time.Time     `json:"reversed_at"`
+}
+
 type NextAction struct {
 	Type        string `json:"type"`
 	RedirectURL string `json:"redirect_url,omitempty"`
@@ -166,9 +338,14 @@ type PaymentSession struct {
 	NextActionType  string        `json:"next_action_type"`
 	NextActionURL   string        `json:"next_action_url"`
 	CapturedAmount  int64         `json:"captured_amount" gorm:"default:0"`
-	Metadata        JSON          `json:"metadata" gorm:"type:jsonb"`
-	CreatedAt       time.Time     `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt       time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
+	// SetupFutureUsage is the usage the session saves its payment method
+	// for. Once the session completes, SavedPaymentMethodID is the stored
+	// payment method attached to the customer.
+	SetupFutureUsage     string    `json:"setup_future_usage,omitempty"`
+	SavedPaymentMethodID string    `json:"saved_payment_method_id,omitempty" gorm:"-"`
+	Metadata             JSON      `json:"metadata" gorm:"type:jsonb"`
+	CreatedAt            time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt            time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 type CreatePaymentSessionRequest struct {
@@ -214,12 +391,21 @@ type PaymentSessionListResponse struct {
 	Total           int               `json:"total"`
 }
 
+// RefundRequest asks for a refund. IdempotencyKey is forwarded to providers
+// that dedupe on it, so a retried refund is not issued twice even when our
+// own records missed the first attempt.
 type RefundRequest struct {
-	PaymentID string `json:"payment_id"`
-	Amount    int64  `json:"amount"`
-	Currency  string `json:"currency"`
-	Reason    string `json:"reason,omitempty"`
-	Metadata  JSON   `json:"metadata,omitempty"`
+	PaymentID      string `json:"payment_id"`
+	Amount         int64  `json:"amount"`
+	Currency       string `json:"currency"`
+	Reason         string `json:"reason,omitempty"`
+	Note           string `json:"note,omitempty"`
+	Metadata       JSON   `json:"metadata,omitempty"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func (r *RefundRequest) Money() Money {
+	return Money{Amount: r.Amount, Currency: r.Currency}
 }
 
 type RefundResponse struct {
@@ -229,8 +415,16 @@ type RefundResponse struct {
 	Currency         string    `json:"currency"`
 	Status           string    `json:"status"`
 	Reason           string    `json:"reason"`
+	Note             string    `json:"note,omitempty"`
 	ProviderName     string    `json:"provider_name"`
 	ProviderRefundID string    `json:"provider_refund_id"`
 	Metadata         JSON      `json:"metadata,omitempty"`
 	CreatedAt        time.Time `json:"created_at"`
 }
+
+// AmountLimit bounds a charge amount in the currency's minor units. A zero
+// Max means no upper bound.
+type AmountLimit struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+}
@@ -40,6 +40,10 @@ type Payout struct {
 	DestinationChannel string          `json:"destination_channel"`
 	FailureReason      string          `json:"failure_reason"`
 	EstimatedArrival   *time.Time      `json:"estimated_arrival"`
+	SourceCurrency     string          `json:"source_currency,omitempty"`
+	SourceAmount       int64           `json:"source_amount,omitempty"`
+	FXRate             float64         `json:"fx_rate,omitempty"`
+	FXQuoteID          string          `json:"fx_quote_id,omitempty"`
 	Metadata           JSON            `json:"metadata" gorm:"type:jsonb"`
 	CreatedAt          time.Time       `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt          time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
@@ -56,6 +60,8 @@ type CreatePayoutRequest struct {
 	DestinationBank    string                 `json:"destination_bank,omitempty"`
 	DestinationChannel string                 `json:"destination_channel,omitempty"`
 	SourceAccount      string                 `json:"source_account,omitempty"`
+	SourceCurrency     string                 `json:"source_currency,omitempty"`
+	FXQuoteID          string                 `json:"-"`
 	Provider           string                 `json:"provider,omitempty"`
 	Metadata           map[string]interface{} `json:"metadata,omitempty"`
 }
@@ -76,6 +82,9 @@ type PayoutListResponse struct {
 	Total   int       `json:"total"`
 }
 
+// PayoutChannel is a way a provider can deliver payouts in Currency.
+// MinAmount and MaxAmount are in the unit payout amounts are given in; zero
+// means the provider reports no limit.
 type PayoutChannel struct {
 	Code      string  `json:"code"`
 	Name      string  `json:"name"`
@@ -84,3 +93,21 @@ type PayoutChannel struct {
 	MinAmount float64 `json:"min_amount"`
 	MaxAmount float64 `json:"max_amount"`
 }
+
+// FXQuote prices converting SourceCurrency into TargetCurrency. Rate is the
+// number of target units one source unit buys; amounts are in each
+// currency's minor unit.
+type FXQuote struct {
+	ID             string     `json:"id"`
+	Provider       string     `json:"provider"`
+	SourceCurrency string     `json:"source_currency"`
+	TargetCurrency string     `json:"target_currency"`
+	SourceAmount   int64      `json:"source_amount"`
+	TargetAmount   int64      `json:"target_amount"`
+	Rate           float64    `json:"rate"`
+	ValidUntil     *time.Time `json:"valid_until,omitempty"`
+}
+
+type FXQuoteResponse struct {
+	Quote *FXQuote `json:"quote"`
+}
@@ -0,0 +1,117 @@
+package models
+
+import "time"
+
+type PayoutScheduleFrequency string
+
+const (
+	PayoutScheduleDaily   PayoutScheduleFrequency = "daily"
+	PayoutScheduleWeekly  PayoutScheduleFrequency = "weekly"
+	PayoutScheduleMonthly PayoutScheduleFrequency = "monthly"
+)
+
+// Valid reports whether f is a frequency schedules can run at.
+func (f PayoutScheduleFrequency) Valid() bool {
+	switch f {
+	case PayoutScheduleDaily, PayoutScheduleWeekly, PayoutScheduleMonthly:
+		return true
+	}
+	return false
+}
+
+// Next returns the run that follows at.
+func (f PayoutScheduleFrequency) Next(at time.Time) time.Time {
+	switch f {
+	case PayoutScheduleWeekly:
+		return at.AddDate(0, 0, 7)
+	case PayoutScheduleMonthly:
+		return at.AddDate(0, 1, 0)
+	default:
+		return at.AddDate(0, 0, 1)
+	}
+}
+
+// PayoutSchedule sweeps a tenant's available balance in Currency to one
+// destination every Frequency. A run pays out whatever is available above
+// the currency's reserve, and is skipped when that is below MinAmount.
+type PayoutSchedule struct {
+	ID                 string                  `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID           *string                 `json:"tenant_id" gorm:"index"`
+	Currency           string                  `json:"currency" gorm:"not null"`
+	MinAmount          int64                   `json:"min_amount" gorm:"not null;default:0"`
+	Frequency          PayoutScheduleFrequency `json:"frequency" gorm:"not null"`
+	DestinationType    DestinationType         `json:"destination_type" gorm:"not null"`
+	DestinationAccount string                  `json:"destination_account" gorm:"not null"`
+	DestinationName    string                  `json:"destination_name,omitempty"`
+	DestinationBank    string                  `json:"destination_bank,omitempty"`
+	DestinationChannel string                  `json:"destination_channel,omitempty"`
+	Description        string                  `json:"description,omitempty"`
+	Active             bool                    `json:"active" gorm:"not null;default:true"`
+	NextRunAt          time.Time               `json:"next_run_at" gorm:"not null;index"`
+	LastRunAt          *time.Time              `json:"last_run_at,omitempty"`
+	CreatedAt          time.Time               `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt          time.Time               `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type PayoutScheduleRunStatus string
+
+const (
+	PayoutScheduleRunPaidOut PayoutScheduleRunStatus = "paid_out"
+	PayoutScheduleRunSkipped PayoutScheduleRunStatus = "skipped"
+	PayoutScheduleRunFailed  PayoutScheduleRunStatus = "failed"
+)
+
+// PayoutScheduleRun records one run of a schedule: the balance it saw and
+// the payout it issued, or why it issued none.
+type PayoutScheduleRun struct {
+	ID               string                  `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ScheduleID       string                  `json:"schedule_id" gorm:"not null;index"`
+	TenantID         *string                 `json:"tenant_id" gorm:"index"`
+	Status           PayoutScheduleRunStatus `json:"status" gorm:"not null"`
+	AvailableBalance int64                   `json:"available_balance"`
+	Amount           int64                   `json:"amount"`
+	Currency         string                  `json:"currency" gorm:"not null"`
+	PayoutID         string                  `json:"payout_id,omitempty"`
+	Reason           string                  `json:"reason,omitempty"`
+	RanAt            time.Time               `json:"ran_at" gorm:"not null"`
+}
+
+type CreatePayoutScheduleRequest struct {
+	Currency           string                  `json:"currency"`
+	MinAmount          int64                   `json:"min_amount"`
+	Frequency          PayoutScheduleFrequency `json:"frequency"`
+	DestinationType    DestinationType         `json:"destination_type"`
+	DestinationAccount string                  `json:"destination_account"`
+	DestinationName    string                  `json:"destination_name,omitempty"`
+	DestinationBank    string                  `json:"destination_bank,omitempty"`
+	DestinationChannel string                  `json:"destination_channel,omitempty"`
+	Description        string                  `json:"description,omitempty"`
+	// StartAt is the first run; it defaults to one period from now.
+	StartAt *time.Time `json:"start_at,omitempty"`
+}
+
+// UpdatePayoutScheduleRequest changes only the fields that are set.
+type UpdatePayoutScheduleRequest struct {
+	MinAmount          *int64                   `json:"min_amount,omitempty"`
+	Frequency          *PayoutScheduleFrequency `json:"frequency,omitempty"`
+	DestinationType    *DestinationType         `json:"destination_type,omitempty"`
+	DestinationAccount *string                  `json:"destination_account,omitempty"`
+	DestinationName    *string                  `json:"destination_name,omitempty"`
+	DestinationBank    *string                  `json:"destination_bank,omitempty"`
+	DestinationChannel *string                  `json:"destination_channel,omitempty"`
+	Description        *string                  `json:"description,omitempty"`
+	Active             *bool                    `json:"active,omitempty"`
+	NextRunAt          *time.Time               `json:"next_run_at,omitempty"`
+}
+
+type PayoutScheduleResponse struct {
+	PayoutSchedule *PayoutSchedule `json:"payout_schedule"`
+}
+
+type PayoutScheduleListResponse struct {
+	PayoutSchedules []*PayoutSchedule `json:"payout_schedules"`
+}
+
+type PayoutScheduleRunListResponse struct {
+	Runs []*PayoutScheduleRun `json:"runs"`
+}
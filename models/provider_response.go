@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// ProviderResponse is a provider's raw response to one operation on a
+// payment, stored only while response capture is switched on.
+type ProviderResponse struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	PaymentID  string    `json:"payment_id" gorm:"not null;index"`
+	TenantID   *string   `json:"tenant_id" gorm:"index"`
+	Provider   string    `json:"provider" gorm:"not null"`
+	Operation  string    `json:"operation" gorm:"not null"`
+	RawBody    string    `json:"raw_body" gorm:"type:text;not null"`
+	ReceivedAt time.Time `json:"received_at" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type ProviderResponseList struct {
+	PaymentID string              `json:"payment_id"`
+	Responses []*ProviderResponse `json:"responses"`
+}
@@ -0,0 +1,7 @@
+package models
+
+type ReconciliationResult struct {
+	Checked int `json:"checked"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "strings"
+
+// RefundReason is the canonical reason stored for a refund. Providers accept
+// different values, so each provider maps these onto its own set.
+type RefundReason string
+
+const (
+	RefundReasonDuplicate           RefundReason = "duplicate"
+	RefundReasonFraudulent          RefundReason = "fraudulent"
+	RefundReasonRequestedByCustomer RefundReason = "requested_by_customer"
+	RefundReasonOther               RefundReason = "other"
+)
+
+var refundReasonAliases = map[string]RefundReason{
+	"duplicate":             RefundReasonDuplicate,
+	"fraudulent":            RefundReasonFraudulent,
+	"fraud":                 RefundReasonFraudulent,
+	"requested_by_customer": RefundReasonRequestedByCustomer,
+	"customer_request":      RefundReasonRequestedByCustomer,
+	"other":                 RefundReasonOther,
+	"others":                RefundReasonOther,
+}
+
+// NormalizeRefundReason maps a client-supplied reason onto the canonical set,
+// ignoring case and accepting spaces or hyphens in place of underscores. An
+// empty reason stays empty; anything unrecognised becomes RefundReasonOther
+// rather than being passed to a provider that would reject it.
+func NormalizeRefundReason(reason string) RefundReason {
+	key := strings.ToLower(strings.TrimSpace(reason))
+	if key == "" {
+		return ""
+	}
+	key = strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+	if canonical, ok := refundReasonAliases[key]; ok {
+		return canonical
+	}
+	return RefundReasonOther
+}
+
+// NormalizeReason rewrites Reason to its canonical value. When the reason was
+// not recognised and no Note was given, the original text is kept as the
+// Note so it is not lost.
+func (r *RefundRequest) NormalizeReason() {
+	canonical := NormalizeRefundReason(r.Reason)
+	if canonical == RefundReasonOther && r.Note == "" && !strings.EqualFold(strings.TrimSpace(r.Reason), string(RefundReasonOther)) {
+		r.Note = strings.TrimSpace(r.Reason)
+	}
+	r.Reason = string(canonical)
+}
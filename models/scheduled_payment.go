@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type ScheduledPaymentStatus string
+
+const (
+	ScheduledPaymentStatusScheduled  ScheduledPaymentStatus = "scheduled"
+	ScheduledPaymentStatusProcessing ScheduledPaymentStatus = "processing"
+	ScheduledPaymentStatusSucceeded  ScheduledPaymentStatus = "succeeded"
+	ScheduledPaymentStatusFailed     ScheduledPaymentStatus = "failed"
+	ScheduledPaymentStatusCanceled   ScheduledPaymentStatus = "canceled"
+)
+
+// ScheduledPayment is a charge held until RunAt. Request is sent through the
+// normal charge path when it runs, and PaymentID records the resulting
+// payment.
+type ScheduledPayment struct {
+	ID           string                 `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID     *string                `json:"tenant_id" gorm:"index"`
+	Request      ChargeRequest          `json:"request" gorm:"type:jsonb;serializer:json;not null"`
+	RunAt        time.Time              `json:"run_at" gorm:"not null;index"`
+	Status       ScheduledPaymentStatus `json:"status" gorm:"not null;default:'scheduled'"`
+	PaymentID    string                 `json:"payment_id,omitempty"`
+	ErrorMessage string                 `json:"error_message,omitempty"`
+	ExecutedAt   *time.Time             `json:"executed_at,omitempty"`
+	CanceledAt   *time.Time             `json:"canceled_at,omitempty"`
+	CreatedAt    time.Time              `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time              `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+type ScheduledPaymentFilter struct {
+	Status ScheduledPaymentStatus
+	Limit  int
+	Offset int
+}
+
+type SchedulePaymentRequest struct {
+	ChargeRequest
+	RunAt time.Time `json:"run_at"`
+}
+
+type ScheduledPaymentResponse struct {
+	ScheduledPayment *ScheduledPayment `json:"scheduled_payment"`
+}
+
+type ScheduledPaymentListResponse struct {
+	ScheduledPayments []*ScheduledPayment `json:"scheduled_payments"`
+	Limit             int                 `json:"limit"`
+	Offset            int                 `json:"offset"`
+}
@@ -13,6 +13,7 @@ const (
 	PricingTypePerUnit PricingType = "per_unit"
 	PricingTypeTiered  PricingType = "tiered"
 	PricingTypeVolume  PricingType = "volume"
+	PricingTypeMetered PricingType = "metered"
 
 	SubscriptionStatusActive   SubscriptionStatus = "active"
 	SubscriptionStatusCanceled SubscriptionStatus = "canceled"
@@ -27,34 +28,42 @@ const (
 )
 
 type Plan struct {
-	ID            string        `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	Name          string        `json:"name" gorm:"not null"`
-	Description   string        `json:"description"`
-	Amount        float64       `json:"amount" gorm:"not null"`
-	Currency      string        `json:"currency" gorm:"not null"`
-	BillingPeriod BillingPeriod `json:"billing_period" gorm:"not null"`
-	PricingType   PricingType   `json:"pricing_type" gorm:"not null"`
-	TrialDays     int           `json:"trial_days"`
-	Features      []string      `json:"features"`
-	Metadata      interface{}   `json:"metadata" gorm:"type:jsonb"`
-	CreatedAt     time.Time     `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt     time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
+	ID             string        `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Name           string        `json:"name" gorm:"not null"`
+	Description    string        `json:"description"`
+	Amount         float64       `json:"amount" gorm:"not null"`
+	Currency       string        `json:"currency" gorm:"not null"`
+	BillingPeriod  BillingPeriod `json:"billing_period" gorm:"not null"`
+	PricingType    PricingType   `json:"pricing_type" gorm:"not null"`
+	TrialDays      int           `json:"trial_days"`
+	MeterEventName string        `json:"meter_event_name,omitempty"`
+	ProviderName   string        `json:"provider_name,omitempty"`
+	Features       []string      `json:"features"`
+	Metadata       interface{}   `json:"metadata" gorm:"type:jsonb"`
+	CreatedAt      time.Time     `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 type Subscription struct {
 	ID                 string             `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID           *string            `json:"tenant_id" gorm:"index"`
 	CustomerID         string             `json:"customer_id" gorm:"not null;index"`
 	PlanID             string             `json:"plan_id" gorm:"not null"`
 	Plan               *Plan              `json:"plan" gorm:"foreignKey:PlanID"`
 	Status             SubscriptionStatus `json:"status" gorm:"not null;default:'active'"`
 	CurrentPeriodStart time.Time          `json:"current_period_start"`
 	CurrentPeriodEnd   time.Time          `json:"current_period_end"`
+	CancelAt           *time.Time         `json:"cancel_at,omitempty"`
 	CanceledAt         *time.Time         `json:"canceled_at,omitempty"`
 	TrialStart         *time.Time         `json:"trial_start,omitempty"`
 	TrialEnd           *time.Time         `json:"trial_end,omitempty"`
 	Quantity           int                `json:"quantity"`
+	SubscriptionItemID string             `json:"subscription_item_id,omitempty"`
 	PaymentMethodID    string             `json:"payment_method_id"`
 	ProviderName       string             `json:"provider_name"`
+	LatestInvoiceID    string             `json:"latest_invoice_id,omitempty"`
+	DunningAttempts    int                `json:"dunning_attempts"`
+	NextDunningAt      *time.Time         `json:"next_dunning_at,omitempty"`
 	Metadata           interface{}        `json:"metadata" gorm:"type:jsonb"`
 	CreatedAt          time.Time          `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt          time.Time          `json:"updated_at" gorm:"autoUpdateTime"`
@@ -73,11 +82,40 @@ type UpdateSubscriptionRequest struct {
 	PlanID          *string     `json:"plan_id,omitempty"`
 	PaymentMethodID *string     `json:"payment_method_id,omitempty"`
 	Metadata        interface{} `json:"metadata,omitempty"`
+	IdempotencyKey  string      `json:"idempotency_key,omitempty"`
 }
 
 type CancelSubscriptionRequest struct {
 	CancelAtPeriodEnd bool   `json:"cancel_at_period_end"`
 	Reason            string `json:"reason,omitempty"`
+	IdempotencyKey    string `json:"idempotency_key,omitempty"`
+}
+
+type RecordUsageRequest struct {
+	SubscriptionItemID string     `json:"subscription_item_id,omitempty"`
+	Quantity           int64      `json:"quantity"`
+	Timestamp          *time.Time `json:"timestamp,omitempty"`
+}
+
+// UsageEvent is a single usage report against a metered subscription.
+// EventName is the plan's billing meter event name.
+type UsageEvent struct {
+	SubscriptionID     string
+	SubscriptionItemID string
+	CustomerID         string
+	ProviderName       string
+	EventName          string
+	Quantity           int64
+	Timestamp          time.Time
+}
+
+type UsageRecord struct {
+	ID                 string    `json:"id"`
+	SubscriptionID     string    `json:"subscription_id,omitempty"`
+	SubscriptionItemID string    `json:"subscription_item_id"`
+	Quantity           int64     `json:"quantity"`
+	Timestamp          time.Time `json:"timestamp"`
+	ProviderName       string    `json:"provider_name"`
 }
 
 type SubscriptionEvent struct {
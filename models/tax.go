@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// TaxAddress is where the customer is taxed. It is optional when the
+// provider already holds an address for the customer.
+type TaxAddress struct {
+	Line1      string `json:"line1,omitempty"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city,omitempty"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+	Country    string `json:"country"`
+}
+
+// TaxLineItem is one taxable amount, in the currency's minor unit, before
+// tax. Reference identifies the line in the calculation's breakdown.
+type TaxLineItem struct {
+	Reference string `json:"reference"`
+	Amount    int64  `json:"amount"`
+	TaxCode   string `json:"tax_code,omitempty"`
+}
+
+type CalculateTaxRequest struct {
+	Currency   string        `json:"currency"`
+	CustomerID string        `json:"customer_id,omitempty"`
+	Address    *TaxAddress   `json:"address,omitempty"`
+	LineItems  []TaxLineItem `json:"line_items"`
+}
+
+// TaxCalculation is the tax owed on a set of line items. TaxAmount is the
+// tax added on top of Subtotal, so Total is what the customer is charged.
+type TaxCalculation struct {
+	ID        string       `json:"id"`
+	Currency  string       `json:"currency"`
+	Subtotal  int64        `json:"subtotal"`
+	TaxAmount int64        `json:"tax_amount"`
+	Total     int64        `json:"total"`
+	Breakdown TaxBreakdown `json:"breakdown"`
+}
+
+// TaxBreakdownItem is the tax charged for one jurisdiction and tax type.
+// Rate is the percentage as a decimal string, e.g. "8.875".
+type TaxBreakdownItem struct {
+	Amount           int64  `json:"amount"`
+	TaxableAmount    int64  `json:"taxable_amount"`
+	Country          string `json:"country,omitempty"`
+	State            string `json:"state,omitempty"`
+	TaxType          string `json:"tax_type,omitempty"`
+	Rate             string `json:"rate,omitempty"`
+	TaxabilityReason string `json:"taxability_reason,omitempty"`
+}
+
+// TaxBreakdown is stored as a JSONB array on payments and invoices.
+type TaxBreakdown []TaxBreakdownItem
+
+// Value implements the driver.Valuer interface
+func (b TaxBreakdown) Value() (driver.Value, error) {
+	if b == nil {
+		return nil, nil
+	}
+	return json.Marshal(b)
+}
+
+// Scan implements the sql.Scanner interface
+func (b *TaxBreakdown) Scan(value interface{}) error {
+	if value == nil {
+		*b = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil
+	}
+	return json.Unmarshal(data, b)
+}
@@ -5,41 +5,64 @@ import (
 )
 
 type Tenant struct {
-	ID            string                 `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	Name          string                 `json:"name" gorm:"not null"`
-	APIKey        string                 `json:"api_key" gorm:"uniqueIndex;not null"`
-	APISecret     string                 `json:"-" gorm:"not null"`
-	WebhookURL    string                 `json:"webhook_url"`
-	WebhookSecret string                 `json:"-"`
-	IsActive      bool                   `json:"is_active" gorm:"default:true"`
-	Settings      map[string]interface{} `json:"settings" gorm:"type:jsonb;default:'{}'"`
-	Metadata      map[string]interface{} `json:"metadata" gorm:"type:jsonb;default:'{}'"`
-	CreatedAt     time.Time              `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt     time.Time              `json:"updated_at" gorm:"autoUpdateTime"`
+	ID                     string                 `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Name                   string                 `json:"name" gorm:"not null"`
+	APIKey                 string                 `json:"api_key" gorm:"uniqueIndex;not null"`
+	APISecret              string                 `json:"-" gorm:"not null"`
+	WebhookURL             string                 `json:"webhook_url"`
+	WebhookSecret          string                 `json:"-"`
+	ProviderWebhookSecrets map[string]string      `json:"-" gorm:"serializer:json"`
+	IsActive               bool                   `json:"is_active" gorm:"default:true"`
+	RateLimitTier          string                 `json:"rate_limit_tier" gorm:"default:'default'"`
+	Settings               map[string]interface{} `json:"settings" gorm:"type:jsonb;default:'{}'"`
+	Metadata               map[string]interface{} `json:"metadata" gorm:"type:jsonb;default:'{}'"`
+	CreatedAt              time.Time              `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt              time.Time              `json:"updated_at" gorm:"autoUpdateTime"`
+	// DeletedAt marks a soft-deleted tenant. Its payments and audit trail
+	// are kept, but the store no longer returns it until it is restored.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" gorm:"index"`
+	// DefaultCaptureMethod applies to the tenant's charges that do not set
+	// capture_method; empty means automatic.
+	DefaultCaptureMethod CaptureMethod `json:"default_capture_method,omitempty"`
 }
 
 type TenantSettings struct {
-	DefaultProvider      string   `json:"default_provider"`
-	EnabledProviders     []string `json:"enabled_providers"`
-	Enable3DS            bool     `json:"enable_3ds"`
-	DefaultCaptureMethod string   `json:"default_capture_method"`
-	WebhookRetryCount    int      `json:"webhook_retry_count"`
+	DefaultProvider          string   `json:"default_provider"`
+	EnabledProviders         []string `json:"enabled_providers"`
+	Enable3DS                bool     `json:"enable_3ds"`
+	DefaultCaptureMethod     string   `json:"default_capture_method"`
+	WebhookRetryCount        int      `json:"webhook_retry_count"`
+	CaptureProviderResponses bool     `json:"capture_provider_responses"`
+	FraudCheck               bool     `json:"fraud_check"`
 }
 
+// TenantSettingCaptureProviderResponses turns on raw provider response
+// capture for a single tenant.
+const TenantSettingCaptureProviderResponses = "capture_provider_responses"
+
+// TenantSettingFraudCheck runs the inline fraud check on every charge a
+// tenant makes, not only those that ask for one.
+const TenantSettingFraudCheck = "fraud_check"
+
 type CreateTenantRequest struct {
-	Name       string                 `json:"name" binding:"required"`
-	WebhookURL string                 `json:"webhook_url"`
-	Settings   map[string]interface{} `json:"settings"`
-	Metadata   map[string]interface{} `json:"metadata"`
+	Name                 string                 `json:"name" binding:"required"`
+	WebhookURL           string                 `json:"webhook_url"`
+	RateLimitTier        string                 `json:"rate_limit_tier"`
+	DefaultCaptureMethod CaptureMethod          `json:"default_capture_method"`
+	Settings             map[string]interface{} `json:"settings"`
+	Metadata             map[string]interface{} `json:"metadata"`
 }
 
 type UpdateTenantRequest struct {
-	Name          string                 `json:"name"`
-	WebhookURL    string                 `json:"webhook_url"`
-	WebhookSecret string                 `json:"webhook_secret"`
-	IsActive      *bool                  `json:"is_active"`
-	Settings      map[string]interface{} `json:"settings"`
-	Metadata      map[string]interface{} `json:"metadata"`
+	Name                   string                 `json:"name"`
+	WebhookURL             string                 `json:"webhook_url"`
+	WebhookSecret          string                 `json:"webhook_secret"`
+	ProviderWebhookSecrets map[string]string      `json:"provider_webhook_secrets"`
+	IsActive               *bool                  `json:"is_active"`
+	RateLimitTier          string                 `json:"rate_limit_tier"`
+	DefaultCaptureMethod   CaptureMethod          `json:"default_capture_method"`
+	Settings               map[string]interface{} `json:"settings"`
+	Metadata               map[string]interface{} `json:"metadata"`
 }
 
 type TenantResponse struct {
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// TenantDataKey is a per-tenant data encryption key, stored wrapped by the
+// master encryption key. The tenant's primary key encrypts new data; keys it
+// replaced stay readable until their data is re-encrypted.
+type TenantDataKey struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID   string    `json:"tenant_id" gorm:"not null;index"`
+	KeyID      string    `json:"key_id" gorm:"not null"`
+	WrappedKey string    `json:"-" gorm:"not null"`
+	Primary    bool      `json:"primary" gorm:"column:is_primary;not null;default:false"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
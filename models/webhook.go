@@ -4,6 +4,9 @@ import (
 	"time"
 )
 
+// WebhookEventAll subscribes a webhook endpoint to every event type.
+const WebhookEventAll = "*"
+
 type WebhookEventStatus string
 
 const (
@@ -12,14 +15,18 @@ const (
 	WebhookEventStatusCompleted  WebhookEventStatus = "completed"
 	WebhookEventStatusFailed     WebhookEventStatus = "failed"
 	WebhookEventStatusRetrying   WebhookEventStatus = "retrying"
+	// WebhookEventStatusDeadLetter marks an event whose last allowed attempt
+	// failed. It is no longer retried and waits for an operator to inspect
+	// and reprocess it; ErrorMessage holds the final failure reason.
+	WebhookEventStatusDeadLetter WebhookEventStatus = "dead_letter"
 )
 
 type WebhookEvent struct {
 	ID            string             `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
 	TenantID      *string            `json:"tenant_id"`
-	Provider      string             `json:"provider" gorm:"not null"`
+	Provider      string             `json:"provider" gorm:"not null;uniqueIndex:idx_webhook_events_provider_event_id,priority:1"`
 	EventType     string             `json:"event_type" gorm:"not null"`
-	EventID       string             `json:"event_id"`
+	EventID       string             `json:"event_id" gorm:"uniqueIndex:idx_webhook_events_provider_event_id,priority:2,where:event_id <> ''"`
 	Payload       JSON               `json:"payload" gorm:"type:jsonb;not null"`
 	Status        WebhookEventStatus `json:"status" gorm:"not null;default:'pending'"`
 	Attempts      int                `json:"attempts" gorm:"default:0"`
@@ -27,9 +34,41 @@ type WebhookEvent struct {
 	LastAttemptAt *time.Time         `json:"last_attempt_at"`
 	NextAttemptAt *time.Time         `json:"next_attempt_at"`
 	ProcessedAt   *time.Time         `json:"processed_at"`
-	ErrorMessage  string             `json:"error_message"`
-	CreatedAt     time.Time          `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt     time.Time          `json:"updated_at" gorm:"autoUpdateTime"`
+	// DeadLetteredAt is when the event last moved to dead_letter.
+	DeadLetteredAt *time.Time `json:"dead_lettered_at,omitempty"`
+	ErrorMessage   string     `json:"error_message"`
+	// PublishPending is set when forwarding the event to the configured
+	// event publisher failed and is waiting to be retried; PublishError holds
+	// the last failure.
+	PublishPending bool      `json:"publish_pending,omitempty" gorm:"default:false"`
+	PublishError   string    `json:"publish_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// PublishedWebhookEvent is the provider-agnostic form of an inbound webhook
+// event forwarded to an event publisher. Payload is the provider's body as
+// received.
+type PublishedWebhookEvent struct {
+	ID         string    `json:"id"`
+	TenantID   string    `json:"tenant_id,omitempty"`
+	Provider   string    `json:"provider"`
+	EventType  string    `json:"event_type"`
+	EventID    string    `json:"event_id,omitempty"`
+	Payload    JSON      `json:"payload"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+type DeadLetterFilter struct {
+	Provider string
+	Limit    int
+	Offset   int
+}
+
+type DeadLetterListResponse struct {
+	Events []*WebhookEvent `json:"events"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
 }
 
 type WebhookPayload struct {
@@ -39,11 +78,161 @@ type WebhookPayload struct {
 	CreatedAt time.Time              `json:"created_at"`
 }
 
-type OutboundWebhook struct {
-	ID        string                 `json:"id"`
-	TenantID  string                 `json:"tenant_id"`
-	EventType string                 `json:"event_type"`
-	Data      map[string]interface{} `json:"data"`
-	Timestamp time.Time              `json:"timestamp"`
-	Signature string                 `json:"signature"`
+// OutboundEventSchemaVersion is the version of the typed Object carried by
+// outbound events. It is bumped whenever a field is removed or changes
+// meaning, so merchants can branch on it instead of sniffing payloads.
+const OutboundEventSchemaVersion = 1
+
+// OutboundEvent is the envelope sent to a tenant's webhook URL. Object holds
+// the typed summary for the event type (PaymentEventObject for payment.*
+// events, and so on). Data is the older untyped payload and is still sent so
+// existing integrations keep working.
+type OutboundEvent struct {
+	ID            string                 `json:"id"`
+	TenantID      string                 `json:"tenant_id"`
+	EventType     string                 `json:"event_type"`
+	SchemaVersion int                    `json:"schema_version"`
+	Object        interface{}            `json:"object,omitempty"`
+	Data          map[string]interface{} `json:"data"`
+	Timestamp     time.Time              `json:"timestamp"`
+	Signature     string                 `json:"signature"`
+}
+
+// PaymentEventObject is the Object of payment.* outbound events.
+type PaymentEventObject struct {
+	ID               string        `json:"id"`
+	CustomerID       string        `json:"customer_id"`
+	Amount           int64         `json:"amount"`
+	CapturedAmount   int64         `json:"captured_amount"`
+	Currency         string        `json:"currency"`
+	Status           PaymentStatus `json:"status"`
+	CaptureMethod    CaptureMethod `json:"capture_method"`
+	Description      string        `json:"description,omitempty"`
+	Provider         string        `json:"provider"`
+	ProviderChargeID string        `json:"provider_charge_id"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+}
+
+// DisputeEventObject is the Object of dispute.* outbound events.
+type DisputeEventObject struct {
+	ID                  string        `json:"id"`
+	CustomerID          string        `json:"customer_id"`
+	TransactionID       string        `json:"transaction_id"`
+	Amount              int64         `json:"amount"`
+	Currency            string        `json:"currency"`
+	Reason              string        `json:"reason"`
+	Status              DisputeStatus `json:"status"`
+	DueBy               time.Time     `json:"due_by"`
+	EvidenceSubmittedAt *time.Time    `json:"evidence_submitted_at,omitempty"`
+	ClosedAt            *time.Time    `json:"closed_at,omitempty"`
+}
+
+// SubscriptionEventObject is the Object of subscription.* outbound events.
+type SubscriptionEventObject struct {
+	ID                 string             `json:"id"`
+	CustomerID         string             `json:"customer_id"`
+	PlanID             string             `json:"plan_id"`
+	Status             SubscriptionStatus `json:"status"`
+	CurrentPeriodStart time.Time          `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time          `json:"current_period_end"`
+	LatestInvoiceID    string             `json:"latest_invoice_id,omitempty"`
+	DunningAttempts    int                `json:"dunning_attempts"`
+	NextDunningAt      *time.Time         `json:"next_dunning_at,omitempty"`
+	CanceledAt         *time.Time         `json:"canceled_at,omitempty"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery records one attempt to deliver an outbound event to a
+// tenant's webhook URL or to one of its endpoints (EndpointID). Redeliveries
+// of the same event share EventID and count up Attempt.
+type WebhookDelivery struct {
+	ID           string                `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID     string                `json:"tenant_id" gorm:"not null;index"`
+	EndpointID   *string               `json:"endpoint_id,omitempty" gorm:"index"`
+	EventID      string                `json:"event_id" gorm:"not null;index"`
+	EventType    string                `json:"event_type" gorm:"not null"`
+	URL          string                `json:"url" gorm:"not null"`
+	Payload      JSON                  `json:"payload" gorm:"type:jsonb"`
+	Status       WebhookDeliveryStatus `json:"status" gorm:"not null"`
+	StatusCode   int                   `json:"status_code"`
+	ResponseBody string                `json:"response_body"`
+	Error        string                `json:"error,omitempty"`
+	Attempt      int                   `json:"attempt" gorm:"not null;default:1"`
+	CreatedAt    time.Time             `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type WebhookDeliveryFilter struct {
+	TenantID  string
+	EventType string
+	Status    WebhookDeliveryStatus
+	StartDate *time.Time
+	EndDate   *time.Time
+	Limit     int
+	Offset    int
+}
+
+type WebhookDeliveryListResponse struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+	Limit      int                `json:"limit"`
+	Offset     int                `json:"offset"`
+}
+
+// WebhookEndpoint is an additional URL a tenant receives outbound events on,
+// alongside the tenant's own webhook URL. Each endpoint only receives the
+// event types it subscribes to and signs with its own secret.
+type WebhookEndpoint struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID    string    `json:"tenant_id" gorm:"not null;index"`
+	URL         string    `json:"url" gorm:"not null"`
+	Description string    `json:"description,omitempty"`
+	Secret      string    `json:"-" gorm:"not null"`
+	EventTypes  []string  `json:"event_types" gorm:"serializer:json"`
+	Enabled     bool      `json:"enabled" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Subscribes reports whether the endpoint takes eventType, either by name or
+// through WebhookEventAll.
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	for _, subscribed := range e.EventTypes {
+		if subscribed == WebhookEventAll || subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookEndpointRequest registers an endpoint. Secret may be left
+// empty to have one generated; Enabled defaults to true.
+type CreateWebhookEndpointRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Secret      string   `json:"secret"`
+	EventTypes  []string `json:"event_types"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+// UpdateWebhookEndpointRequest changes only the fields that are set.
+type UpdateWebhookEndpointRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	EventTypes  []string `json:"event_types"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+type WebhookEndpointResponse struct {
+	Endpoint *WebhookEndpoint `json:"endpoint"`
+	Secret   string           `json:"secret,omitempty"`
+}
+
+type WebhookEndpointListResponse struct {
+	Endpoints []*WebhookEndpoint `json:"endpoints"`
 }
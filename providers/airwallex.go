@@ -8,11 +8,14 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/malwarebo/conductor/internal/convert"
 	"github.com/malwarebo/conductor/internal/crypto"
+	"github.com/malwarebo/conductor/internal/httputil"
 	"github.com/malwarebo/conductor/models"
 )
 
@@ -20,6 +23,8 @@ const (
 	airwallexProdURL    = "https://api.airwallex.com"
 	airwallexDemoURL    = "https://api-demo.airwallex.com"
 	airwallexAPIVersion = "2026-02-27"
+
+	airwallexWebhookTolerance = 5 * time.Minute
 )
 
 type AirwallexProvider struct {
@@ -27,6 +32,7 @@ type AirwallexProvider struct {
 	apiKey        string
 	webhookSecret string
 	baseURL       string
+	mode          Mode
 	httpClient    *http.Client
 	accessToken   string
 	tokenExpiry   time.Time
@@ -34,15 +40,16 @@ type AirwallexProvider struct {
 }
 
 func CreateAirwallexProvider(clientID, apiKey string, useSandbox bool) *AirwallexProvider {
-	baseURL := airwallexProdURL
+	baseURL, mode := airwallexProdURL, ModeLive
 	if useSandbox {
-		baseURL = airwallexDemoURL
+		baseURL, mode = airwallexDemoURL, ModeTest
 	}
 	return &AirwallexProvider{
 		clientID:   clientID,
 		apiKey:     apiKey,
 		baseURL:    baseURL,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		mode:       mode,
+		httpClient: httputil.NewHTTPClient(httputil.DefaultTransportConfig()),
 	}
 }
 
@@ -143,6 +150,8 @@ type awxCustomerResponse struct {
 type awxTransferRequest struct {
 	RequestID        string                 `json:"request_id"`
 	SourceID         string                 `json:"source_id,omitempty"`
+	SourceCurrency   string                 `json:"source_currency,omitempty"`
+	QuoteID          string                 `json:"quote_id,omitempty"`
 	BeneficiaryID    string                 `json:"beneficiary_id"`
 	TransferAmount   float64                `json:"transfer_amount"`
 	TransferCurrency string                 `json:"transfer_currency"`
@@ -166,6 +175,23 @@ type awxTransferResponse struct {
 	Metadata      map[string]interface{} `json:"metadata,omitempty"`
 }
 
+type awxQuoteRequest struct {
+	BuyCurrency  string  `json:"buy_currency"`
+	SellCurrency string  `json:"sell_currency"`
+	BuyAmount    float64 `json:"buy_amount"`
+	Validity     string  `json:"validity"`
+}
+
+type awxQuoteResponse struct {
+	QuoteID      string  `json:"quote_id"`
+	BuyCurrency  string  `json:"buy_currency"`
+	SellCurrency string  `json:"sell_currency"`
+	BuyAmount    float64 `json:"buy_amount"`
+	SellAmount   float64 `json:"sell_amount"`
+	ClientRate   float64 `json:"client_rate"`
+	ValidToAt    string  `json:"valid_to_at"`
+}
+
 type awxSubscriptionRequest struct {
 	RequestID         string                 `json:"request_id"`
 	BillingCustomerID string                 `json:"billing_customer_id"`
@@ -237,6 +263,7 @@ type awxBalanceResponse struct {
 
 type awxListResponse struct {
 	Items      json.RawMessage `json:"items"`
+	HasMore    bool            `json:"has_more,omitempty"`
 	PageBefore string          `json:"page_before,omitempty"`
 	PageAfter  string          `json:"page_after,omitempty"`
 }
@@ -273,7 +300,7 @@ func (p *AirwallexProvider) authenticate(ctx context.Context) error {
 
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("authentication failed (status %d): %s", resp.StatusCode, string(body))
+		return fmt.Errorf("authentication failed (status %d): %s", resp.StatusCode, redactBody(body))
 	}
 
 	var authResp awxAuthResponse
@@ -298,8 +325,10 @@ func (p *AirwallexProvider) doRequest(ctx context.Context, method, path string,
 	}
 
 	var reqBody io.Reader
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		var err error
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
@@ -328,8 +357,10 @@ func (p *AirwallexProvider) doRequest(ctx context.Context, method, path string,
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
+	logProviderExchange(ctx, "airwallex", method, path, resp.StatusCode, jsonBody, respBody)
+
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("airwallex API error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, &APIError{Provider: "airwallex", StatusCode: resp.StatusCode, Body: redactBody(respBody)}
 	}
 
 	return respBody, nil
@@ -355,31 +386,97 @@ func (p *AirwallexProvider) buildListPath(basePath string, params map[string]str
 	return basePath
 }
 
+// listAirwallex fetches every page of an Airwallex list endpoint. Newer
+// endpoints return a page_after cursor; older ones report has_more and take
+// a zero-based page_num. At most maxListPages pages are followed.
+func listAirwallex[T any](ctx context.Context, p *AirwallexProvider, basePath string, params map[string]string) ([]T, error) {
+	query := make(map[string]string, len(params)+1)
+	for k, v := range params {
+		query[k] = v
+	}
+
+	var all []T
+	for page := 0; page < maxListPages; page++ {
+		respBody, err := p.doRequest(ctx, "GET", p.buildListPath(basePath, query), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var listResp awxListResponse
+		if err := json.Unmarshal(respBody, &listResp); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		var items []T
+		if err := json.Unmarshal(listResp.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to parse items: %w", err)
+		}
+		all = append(all, items...)
+
+		switch {
+		case len(items) == 0:
+			return all, nil
+		case listResp.PageAfter != "":
+			query["page"] = listResp.PageAfter
+		case listResp.HasMore:
+			query["page_num"] = strconv.Itoa(page + 1)
+		default:
+			return all, nil
+		}
+	}
+	return nil, fmt.Errorf("%s returned more than %d pages", basePath, maxListPages)
+}
+
+func (p *AirwallexProvider) SetHTTPClient(client *http.Client) {
+	p.httpClient = client
+}
+
 func (p *AirwallexProvider) Name() string {
 	return "airwallex"
 }
 
+func (p *AirwallexProvider) Mode() Mode {
+	return p.mode
+}
+
 func (p *AirwallexProvider) Capabilities() ProviderCapabilities {
 	return ProviderCapabilities{
+		SupportsSubscriptions:   true,
 		SupportsInvoices:        true,
 		SupportsPayouts:         true,
 		SupportsPaymentSessions: true,
 		Supports3DS:             true,
 		SupportsManualCapture:   true,
+		SupportsMultiCapture:    true,
 		SupportsBalance:         true,
 		SupportedCurrencies:     []string{"USD", "EUR", "GBP", "AUD", "NZD", "HKD", "SGD", "CNY", "JPY", "CAD", "CHF", "ILS", "THB", "MYR", "IDR", "PHP", "VND", "KRW", "INR"},
 		SupportedPaymentMethods: []models.PaymentMethodType{models.PMTypeCard, models.PMTypeBankAccount, models.PMTypeEWallet, models.PMTypeQRCode},
+		Mode:                    p.mode,
 	}
 }
 
 func (p *AirwallexProvider) Charge(ctx context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+	if hasPlatformFees(req) {
+		return nil, ErrPlatformFeesNotSupported
+	}
+	if req.SubAccountID != "" {
+		return nil, ErrSubAccountNotSupported
+	}
+
+	descriptor := airwallexDescriptor(req)
+	if req.StatementDescriptor != "" || req.StatementDescriptorSuffix != "" {
+		if err := validateDescriptor("descriptor", descriptor, airwallexDescriptorRules); err != nil {
+			return nil, err
+		}
+	}
+
 	piReq := awxPaymentIntentRequest{
 		RequestID:       p.requestID("pi"),
-		Amount:          convert.CentsToFloat(req.Amount),
+		Amount:          req.Money().Major(),
 		Currency:        req.Currency,
 		MerchantOrderID: req.CustomerID,
 		CustomerID:      req.CustomerID,
-		Descriptor:      req.Description,
+		Descriptor:      descriptor,
 		ReturnURL:       req.ReturnURL,
 		CaptureMethod:   p.resolveCaptureMethod(req.CaptureMethod, req.Capture),
 	}
@@ -392,6 +489,7 @@ func (p *AirwallexProvider) Charge(ctx context.Context, req *models.ChargeReques
 	if err != nil {
 		return nil, fmt.Errorf("charge failed: %w", err)
 	}
+	RecordRawResponse(ctx, "airwallex", "charge", respBody)
 
 	var piResp awxPaymentIntentResponse
 	if err := json.Unmarshal(respBody, &piResp); err != nil {
@@ -401,6 +499,22 @@ func (p *AirwallexProvider) Charge(ctx context.Context, req *models.ChargeReques
 	return p.mapChargeResponse(&piResp, req), nil
 }
 
+// airwallexDescriptor builds Airwallex's single descriptor field from the
+// statement descriptor and suffix, falling back to the description for
+// callers that have not set a statement descriptor.
+func airwallexDescriptor(req *models.ChargeRequest) string {
+	parts := make([]string, 0, 2)
+	for _, part := range []string{req.StatementDescriptor, req.StatementDescriptorSuffix} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return req.Description
+	}
+	return strings.Join(parts, " ")
+}
+
 func (p *AirwallexProvider) resolveCaptureMethod(method models.CaptureMethod, capture *bool) string {
 	if method == models.CaptureMethodManual || (capture != nil && !*capture) {
 		return "manual"
@@ -417,14 +531,14 @@ func (p *AirwallexProvider) mapChargeResponse(pi *awxPaymentIntentResponse, req
 	resp := &models.ChargeResponse{
 		ID:               pi.ID,
 		CustomerID:       pi.CustomerID,
-		Amount:           convert.FloatToCents(pi.Amount),
+		Amount:           models.MoneyFromMajor(pi.Amount, pi.Currency).Amount,
 		Currency:         pi.Currency,
 		Status:           p.mapPaymentStatus(pi.Status),
 		Description:      pi.Descriptor,
 		ProviderName:     "airwallex",
 		ProviderChargeID: pi.ID,
 		CaptureMethod:    captureMethod,
-		CapturedAmount:   convert.FloatToCents(pi.CapturedAmount),
+		CapturedAmount:   models.MoneyFromMajor(pi.CapturedAmount, pi.Currency).Amount,
 		ClientSecret:     pi.ClientSecret,
 		Metadata:         pi.Metadata,
 		CreatedAt:        convert.ParseTime(pi.CreatedAt),
@@ -465,6 +579,27 @@ func (p *AirwallexProvider) CapturePayment(ctx context.Context, paymentID string
 	return err
 }
 
+// CapturePartial issues another capture against the intent. Airwallex keeps
+// the remaining authorization open, so a final capture cancels the intent
+// afterwards to release whatever is left.
+func (p *AirwallexProvider) CapturePartial(ctx context.Context, paymentID string, amount int64, final bool) error {
+	if err := p.CapturePayment(ctx, paymentID, amount); err != nil {
+		return err
+	}
+	if !final {
+		return nil
+	}
+
+	pi, err := p.getPaymentIntent(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	if pi.Status != "REQUIRES_CAPTURE" {
+		return nil
+	}
+	return p.VoidPayment(ctx, paymentID)
+}
+
 func (p *AirwallexProvider) VoidPayment(ctx context.Context, paymentID string) error {
 	reqBody := map[string]interface{}{
 		"request_id":          p.requestID("void"),
@@ -492,7 +627,11 @@ func (p *AirwallexProvider) Create3DSSession(ctx context.Context, paymentID stri
 }
 
 func (p *AirwallexProvider) Confirm3DSPayment(ctx context.Context, paymentID string) (*models.ChargeResponse, error) {
-	pi, err := p.getPaymentIntent(ctx, paymentID)
+	return p.GetCharge(ctx, paymentID)
+}
+
+func (p *AirwallexProvider) GetCharge(ctx context.Context, providerChargeID string) (*models.ChargeResponse, error) {
+	pi, err := p.getPaymentIntent(ctx, providerChargeID)
 	if err != nil {
 		return nil, err
 	}
@@ -511,12 +650,27 @@ func (p *AirwallexProvider) getPaymentIntent(ctx context.Context, id string) (*a
 	return &pi, nil
 }
 
+// airwallexRefundReason returns the free-text reason Airwallex records. The
+// note is more useful to whoever reads it there, so it wins when set.
+func airwallexRefundReason(req *models.RefundRequest) string {
+	if req.Note != "" {
+		return req.Note
+	}
+	return string(models.NormalizeRefundReason(req.Reason))
+}
+
 func (p *AirwallexProvider) Refund(ctx context.Context, req *models.RefundRequest) (*models.RefundResponse, error) {
+	// Airwallex dedupes on request_id, so a caller's idempotency key stands
+	// in for the generated one.
+	requestID := req.IdempotencyKey
+	if requestID == "" {
+		requestID = p.requestID("ref")
+	}
 	refundReq := awxRefundRequest{
-		RequestID:       p.requestID("ref"),
+		RequestID:       requestID,
 		PaymentIntentID: req.PaymentID,
-		Amount:          convert.CentsToFloat(req.Amount),
-		Reason:          req.Reason,
+		Amount:          req.Money().Major(),
+		Reason:          airwallexRefundReason(req),
 	}
 
 	if req.Metadata != nil {
@@ -527,6 +681,7 @@ func (p *AirwallexProvider) Refund(ctx context.Context, req *models.RefundReques
 	if err != nil {
 		return nil, fmt.Errorf("refund failed: %w", err)
 	}
+	RecordRawResponse(ctx, "airwallex", "refund", respBody)
 
 	var refundResp awxRefundResponse
 	if err := json.Unmarshal(respBody, &refundResp); err != nil {
@@ -536,10 +691,11 @@ func (p *AirwallexProvider) Refund(ctx context.Context, req *models.RefundReques
 	return &models.RefundResponse{
 		ID:               refundResp.ID,
 		PaymentID:        req.PaymentID,
-		Amount:           convert.FloatToCents(refundResp.Amount),
+		Amount:           models.MoneyFromMajor(refundResp.Amount, refundResp.Currency).Amount,
 		Currency:         refundResp.Currency,
 		Status:           p.mapRefundStatus(refundResp.Status),
-		Reason:           refundResp.Reason,
+		Reason:           string(models.NormalizeRefundReason(req.Reason)),
+		Note:             req.Note,
 		ProviderName:     "airwallex",
 		ProviderRefundID: refundResp.ID,
 		Metadata:         refundResp.Metadata,
@@ -897,25 +1053,13 @@ func (p *AirwallexProvider) GetSubscription(ctx context.Context, subscriptionID
 }
 
 func (p *AirwallexProvider) ListSubscriptions(ctx context.Context, customerID string) ([]*models.Subscription, error) {
-	path := p.buildListPath("/api/v1/subscriptions", map[string]string{
+	items, err := listAirwallex[awxSubscriptionResponse](ctx, p, "/api/v1/subscriptions", map[string]string{
 		"billing_customer_id": customerID,
 	})
-
-	respBody, err := p.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("list subscriptions failed: %w", err)
 	}
 
-	var listResp awxListResponse
-	if err := json.Unmarshal(respBody, &listResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	var items []awxSubscriptionResponse
-	if err := json.Unmarshal(listResp.Items, &items); err != nil {
-		return nil, fmt.Errorf("failed to parse items: %w", err)
-	}
-
 	subs := make([]*models.Subscription, 0, len(items))
 	for i := range items {
 		subs = append(subs, p.mapSubscription(&items[i], ""))
@@ -998,6 +1142,8 @@ func (p *AirwallexProvider) DeletePlan(ctx context.Context, planID string) error
 	return nil
 }
 
+// GetPlan and ListPlans are not supported: Airwallex has no plan objects, so
+// plans are read from the local plan store.
 func (p *AirwallexProvider) GetPlan(ctx context.Context, planID string) (*models.Plan, error) {
 	return nil, ErrNotSupported
 }
@@ -1051,25 +1197,13 @@ func (p *AirwallexProvider) GetInvoice(ctx context.Context, invoiceID string) (*
 }
 
 func (p *AirwallexProvider) ListInvoices(ctx context.Context, req *models.ListInvoicesRequest) ([]*models.Invoice, error) {
-	path := p.buildListPath("/api/v1/invoices", map[string]string{
+	items, err := listAirwallex[awxInvoiceResponse](ctx, p, "/api/v1/invoices", map[string]string{
 		"billing_customer_id": req.CustomerID,
 	})
-
-	respBody, err := p.doRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("list invoices failed: %w", err)
 	}
 
-	var listResp awxListResponse
-	if err := json.Unmarshal(respBody, &listResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	var items []awxInvoiceResponse
-	if err := json.Unmarshal(listResp.Items, &items); err != nil {
-		return nil, fmt.Errorf("failed to parse items: %w", err)
-	}
-
 	invoices := make([]*models.Invoice, 0, len(items))
 	for i := range items {
 		invoices = append(invoices, p.mapInvoice(&items[i]))
@@ -1138,6 +1272,8 @@ func (p *AirwallexProvider) CreatePayout(ctx context.Context, req *models.Create
 		Reference:        req.ReferenceID,
 		Reason:           req.Description,
 		SourceID:         req.SourceAccount,
+		SourceCurrency:   req.SourceCurrency,
+		QuoteID:          req.FXQuoteID,
 		Metadata:         req.Metadata,
 	}
 
@@ -1169,21 +1305,11 @@ func (p *AirwallexProvider) GetPayout(ctx context.Context, payoutID string) (*mo
 }
 
 func (p *AirwallexProvider) ListPayouts(ctx context.Context, req *models.ListPayoutsRequest) ([]*models.Payout, error) {
-	respBody, err := p.doRequest(ctx, "GET", "/api/v1/transfers", nil)
+	items, err := listAirwallex[awxTransferResponse](ctx, p, "/api/v1/transfers", nil)
 	if err != nil {
 		return nil, fmt.Errorf("list payouts failed: %w", err)
 	}
 
-	var listResp awxListResponse
-	if err := json.Unmarshal(respBody, &listResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	var items []awxTransferResponse
-	if err := json.Unmarshal(listResp.Items, &items); err != nil {
-		return nil, fmt.Errorf("failed to parse items: %w", err)
-	}
-
 	payouts := make([]*models.Payout, 0, len(items))
 	for i := range items {
 		payouts = append(payouts, p.mapPayout(&items[i], ""))
@@ -1207,6 +1333,50 @@ func (p *AirwallexProvider) CancelPayout(ctx context.Context, payoutID string) (
 	return p.mapPayout(&transferResp, ""), nil
 }
 
+// airwallexQuoteValidity is how long a quote's rate is held for the payout
+// that uses it.
+const airwallexQuoteValidity = "MIN_15"
+
+func (p *AirwallexProvider) GetFXQuote(ctx context.Context, from, to string, amount int64) (*models.FXQuote, error) {
+	respBody, err := p.doRequest(ctx, "POST", "/api/v1/fx/quotes/create", awxQuoteRequest{
+		BuyCurrency:  to,
+		SellCurrency: from,
+		BuyAmount:    convert.CentsToFloat(amount),
+		Validity:     airwallexQuoteValidity,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create fx quote failed: %w", err)
+	}
+
+	var quoteResp awxQuoteResponse
+	if err := json.Unmarshal(respBody, &quoteResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return p.mapFXQuote(&quoteResp), nil
+}
+
+// mapFXQuote derives the rate from the quoted amounts, since Airwallex's
+// client_rate follows the pair's market convention rather than the
+// sell-to-buy direction of this quote.
+func (p *AirwallexProvider) mapFXQuote(q *awxQuoteResponse) *models.FXQuote {
+	rate := q.ClientRate
+	if q.SellAmount > 0 {
+		rate = q.BuyAmount / q.SellAmount
+	}
+
+	return &models.FXQuote{
+		ID:             q.QuoteID,
+		Provider:       "airwallex",
+		SourceCurrency: q.SellCurrency,
+		TargetCurrency: q.BuyCurrency,
+		SourceAmount:   convert.FloatToCents(q.SellAmount),
+		TargetAmount:   convert.FloatToCents(q.BuyAmount),
+		Rate:           rate,
+		ValidUntil:     convert.ParseTimePtr(q.ValidToAt),
+	}
+}
+
 func (p *AirwallexProvider) GetPayoutChannels(ctx context.Context, currency string) ([]*models.PayoutChannel, error) {
 	return []*models.PayoutChannel{
 		{Code: "LOCAL", Name: "Local Bank Transfer", Category: "bank", Currency: currency},
@@ -1303,8 +1473,30 @@ func (p *AirwallexProvider) GetDisputeStats(ctx context.Context) (*models.Disput
 	return &models.DisputeStats{}, nil
 }
 
+// ValidateWebhookSignature always fails: Airwallex signs the x-timestamp
+// header together with the body, so use ValidateTimestampedWebhookSignature.
 func (p *AirwallexProvider) ValidateWebhookSignature(payload []byte, signature string) error {
-	return crypto.ValidateHMACSHA256(payload, signature, p.webhookSecret)
+	return p.ValidateTimestampedWebhookSignature(payload, signature, "")
+}
+
+func (p *AirwallexProvider) ValidateTimestampedWebhookSignature(payload []byte, signature, timestamp string) error {
+	if timestamp == "" {
+		return ErrWebhookTimestampMissing
+	}
+
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp %q: %w", timestamp, err)
+	}
+	age := time.Since(time.UnixMilli(millis))
+	if age > airwallexWebhookTolerance || age < -airwallexWebhookTolerance {
+		return ErrWebhookTimestampExpired
+	}
+
+	signed := make([]byte, 0, len(timestamp)+len(payload))
+	signed = append(signed, timestamp...)
+	signed = append(signed, payload...)
+	return crypto.ValidateHMACSHA256(signed, signature, p.webhookSecret)
 }
 
 func (p *AirwallexProvider) IsAvailable(ctx context.Context) bool {
@@ -0,0 +1,321 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/config"
+	"github.com/malwarebo/conductor/internal/crypto"
+	"github.com/malwarebo/conductor/models"
+)
+
+func TestAirwallexModeSelectsBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.AirwallexConfig
+		baseURL string
+		mode    Mode
+	}{
+		{"sandbox mode", config.AirwallexConfig{Mode: config.ProviderModeTest}, airwallexDemoURL, ModeTest},
+		{"legacy use_sandbox", config.AirwallexConfig{UseSandbox: true}, airwallexDemoURL, ModeTest},
+		{"live mode", config.AirwallexConfig{Mode: config.ProviderModeLive, UseSandbox: true}, airwallexProdURL, ModeLive},
+		{"default", config.AirwallexConfig{}, airwallexProdURL, ModeLive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := CreateAirwallexProvider("client", "key", tt.cfg.Sandbox())
+			if p.baseURL != tt.baseURL {
+				t.Fatalf("expected base URL %s, got %s", tt.baseURL, p.baseURL)
+			}
+			if p.Mode() != tt.mode || p.Capabilities().Mode != tt.mode {
+				t.Fatalf("expected mode %s, got %s", tt.mode, p.Mode())
+			}
+		})
+	}
+}
+
+func TestAirwallexGetChargeFetchesPaymentIntent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/authentication/login":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+		case "/api/v1/pa/payment_intents/int_123":
+			if r.Method != http.MethodGet {
+				t.Errorf("expected GET, got %s", r.Method)
+			}
+			_, _ = w.Write([]byte(`{"id":"int_123","amount":12.5,"currency":"HKD","customer_id":"cus_1","status":"SUCCEEDED","captured_amount":12.5}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := CreateAirwallexProvider("client", "key", true)
+	p.baseURL = server.URL
+
+	charge, err := p.GetCharge(context.Background(), "int_123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if charge.ProviderChargeID != "int_123" || charge.Status != models.PaymentStatusSuccess {
+		t.Fatalf("unexpected charge: %+v", charge)
+	}
+	if charge.Amount != 1250 || charge.CapturedAmount != 1250 || charge.Currency != "HKD" {
+		t.Fatalf("unexpected amounts: amount=%d captured=%d currency=%s", charge.Amount, charge.CapturedAmount, charge.Currency)
+	}
+}
+
+func TestAirwallexGetChargeReturnsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/authentication/login" {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok"}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	p := CreateAirwallexProvider("client", "key", true)
+	p.baseURL = server.URL
+
+	if _, err := p.GetCharge(context.Background(), "int_missing"); err == nil {
+		t.Fatal("expected error for unknown payment intent")
+	}
+}
+
+const airwallexWebhookFixture = `{"id":"evt_hkdmr7v9rg1j58ky8re","name":"payment_intent.succeeded","account_id":"acct_123","data":{"object":{"id":"int_123","amount":12.5,"currency":"HKD","status":"SUCCEEDED"}},"created_at":"2026-03-01T08:00:00+0000"}`
+
+func signAirwallexWebhook(secret, timestamp, payload string) string {
+	return crypto.GenerateHMACSHA256([]byte(timestamp+payload), secret)
+}
+
+func TestAirwallexWebhookSignatureAcceptsSignedPayload(t *testing.T) {
+	p := CreateAirwallexProviderWithWebhook("client", "key", "whsec_test", true)
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	signature := signAirwallexWebhook("whsec_test", timestamp, airwallexWebhookFixture)
+
+	if err := p.ValidateTimestampedWebhookSignature([]byte(airwallexWebhookFixture), signature, timestamp); err != nil {
+		t.Fatalf("expected signed webhook to verify, got %v", err)
+	}
+}
+
+func TestAirwallexWebhookSignatureRejectsTamperedPayload(t *testing.T) {
+	p := CreateAirwallexProviderWithWebhook("client", "key", "whsec_test", true)
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	signature := signAirwallexWebhook("whsec_test", timestamp, airwallexWebhookFixture)
+	tampered := []byte(strings.Replace(airwallexWebhookFixture, `"amount":12.5`, `"amount":1250`, 1))
+
+	if err := p.ValidateTimestampedWebhookSignature(tampered, signature, timestamp); err == nil {
+		t.Fatal("expected tampered payload to fail verification")
+	}
+	if err := p.ValidateWebhookSignature([]byte(airwallexWebhookFixture), signature); !errors.Is(err, ErrWebhookTimestampMissing) {
+		t.Fatalf("expected missing timestamp error, got %v", err)
+	}
+
+	stale := strconv.FormatInt(time.Now().Add(-10*time.Minute).UnixMilli(), 10)
+	staleSignature := signAirwallexWebhook("whsec_test", stale, airwallexWebhookFixture)
+	if err := p.ValidateTimestampedWebhookSignature([]byte(airwallexWebhookFixture), staleSignature, stale); !errors.Is(err, ErrWebhookTimestampExpired) {
+		t.Fatalf("expected replayed webhook to be rejected, got %v", err)
+	}
+}
+
+func TestAirwallexChargeSendsStatementDescriptor(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/authentication/login":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+		case "/api/v1/pa/payment_intents/create":
+			raw, _ := io.ReadAll(r.Body)
+			body = string(raw)
+			_, _ = w.Write([]byte(`{"id":"int_123","amount":10,"currency":"HKD","status":"REQUIRES_PAYMENT_METHOD"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := CreateAirwallexProvider("client", "key", true)
+	p.baseURL = server.URL
+
+	_, err := p.Charge(context.Background(), &models.ChargeRequest{
+		Amount:                    1000,
+		Currency:                  "HKD",
+		Description:               "internal order note",
+		StatementDescriptor:       "ACME STORE",
+		StatementDescriptorSuffix: "ORDER 1042",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(body, `"descriptor":"ACME STORE ORDER 1042"`) {
+		t.Fatalf("expected combined descriptor in request, got %s", body)
+	}
+}
+
+func TestAirwallexChargeRejectsOverLengthDescriptor(t *testing.T) {
+	p := CreateAirwallexProvider("client", "key", true)
+	p.baseURL = "http://127.0.0.1:0"
+
+	_, err := p.Charge(context.Background(), &models.ChargeRequest{
+		Amount:              1000,
+		Currency:            "HKD",
+		StatementDescriptor: strings.Repeat("A", 33),
+	})
+	if !errors.Is(err, ErrInvalidStatementDescriptor) {
+		t.Fatalf("expected ErrInvalidStatementDescriptor, got %v", err)
+	}
+}
+
+func TestAirwallexFinalPartialCaptureReleasesRemainder(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/authentication/login":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+			return
+		case "/api/v1/pa/payment_intents/int_123":
+			_, _ = w.Write([]byte(`{"id":"int_123","amount":12.5,"currency":"HKD","status":"REQUIRES_CAPTURE","captured_amount":5}`))
+		default:
+			_, _ = w.Write([]byte(`{"id":"int_123"}`))
+		}
+		calls = append(calls, r.Method+" "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	p := CreateAirwallexProvider("client", "key", true)
+	p.baseURL = server.URL
+
+	if err := p.CapturePartial(context.Background(), "int_123", 500, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(calls) != 1 || calls[0] != "POST /api/v1/pa/payment_intents/int_123/capture" {
+		t.Fatalf("expected a single capture call, got %v", calls)
+	}
+
+	calls = nil
+	if err := p.CapturePartial(context.Background(), "int_123", 500, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"POST /api/v1/pa/payment_intents/int_123/capture",
+		"GET /api/v1/pa/payment_intents/int_123",
+		"POST /api/v1/pa/payment_intents/int_123/cancel",
+	}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, calls)
+	}
+}
+
+func TestAirwallexChargeRecordsRawResponseWhenRecorderAttached(t *testing.T) {
+	const body = `{"id":"int_raw","amount":10,"currency":"HKD","status":"SUCCEEDED"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/authentication/login":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+		case "/api/v1/pa/payment_intents/create":
+			_, _ = w.Write([]byte(body))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := CreateAirwallexProvider("client", "key", true)
+	p.baseURL = server.URL
+	req := &models.ChargeRequest{Amount: 1000, Currency: "HKD"}
+
+	if _, err := p.Charge(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error without recorder: %v", err)
+	}
+
+	recorder := &RawResponseRecorder{}
+	if _, err := p.Charge(WithRawResponseRecorder(context.Background(), recorder), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	responses := recorder.Responses()
+	if len(responses) != 1 {
+		t.Fatalf("expected one recorded response, got %d", len(responses))
+	}
+	if responses[0].Provider != "airwallex" || responses[0].Operation != "charge" || string(responses[0].Body) != body {
+		t.Fatalf("unexpected recorded response %+v", responses[0])
+	}
+}
+
+func TestAirwallexListPayoutsFollowsPageCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/authentication/login":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok"}`))
+		case "/api/v1/transfers":
+			if r.URL.Query().Get("page") == "cur_2" {
+				_, _ = w.Write([]byte(`{"items":[{"id":"tr_2","amount":20,"currency":"SGD","status":"PAID"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"items":[{"id":"tr_1","amount":10,"currency":"SGD","status":"PAID"}],"page_after":"cur_2"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := CreateAirwallexProvider("client", "key", true)
+	p.baseURL = server.URL
+
+	payouts, err := p.ListPayouts(context.Background(), &models.ListPayoutsRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(payouts) != 2 || payouts[0].ProviderID != "tr_1" || payouts[1].ProviderID != "tr_2" {
+		t.Fatalf("expected payouts from both pages, got %+v", payouts)
+	}
+}
+
+func TestAirwallexListInvoicesFollowsHasMore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/authentication/login":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok"}`))
+		case "/api/v1/invoices":
+			if got := r.URL.Query().Get("billing_customer_id"); got != "cus_1" {
+				t.Errorf("expected customer filter on every page, got %q", got)
+			}
+			if r.URL.Query().Get("page_num") == "1" {
+				_, _ = w.Write([]byte(`{"items":[{"id":"inv_2","currency":"HKD","status":"PAID"}],"has_more":false}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"items":[{"id":"inv_1","currency":"HKD","status":"PAID"}],"has_more":true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := CreateAirwallexProvider("client", "key", true)
+	p.baseURL = server.URL
+
+	invoices, err := p.ListInvoices(context.Background(), &models.ListInvoicesRequest{CustomerID: "cus_1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(invoices) != 2 {
+		t.Fatalf("expected invoices from both pages, got %d", len(invoices))
+	}
+}
@@ -0,0 +1,50 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const DefaultAvailabilityTTL = 30 * time.Second
+
+type availabilityEntry struct {
+	available bool
+	checkedAt time.Time
+}
+
+// AvailabilityCache remembers the result of provider IsAvailable checks for a
+// short TTL so read-heavy callers don't hit the provider APIs on every request.
+type AvailabilityCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]availabilityEntry
+	now     func() time.Time
+}
+
+func CreateAvailabilityCache(ttl time.Duration) *AvailabilityCache {
+	if ttl <= 0 {
+		ttl = DefaultAvailabilityTTL
+	}
+	return &AvailabilityCache{
+		ttl:     ttl,
+		entries: make(map[string]availabilityEntry),
+		now:     time.Now,
+	}
+}
+
+func (c *AvailabilityCache) IsAvailable(ctx context.Context, name string, provider PaymentProvider) bool {
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && c.now().Sub(entry.checkedAt) < c.ttl {
+		return entry.available
+	}
+
+	available := provider.IsAvailable(ctx)
+
+	c.mu.Lock()
+	c.entries[name] = availabilityEntry{available: available, checkedAt: c.now()}
+	c.mu.Unlock()
+	return available
+}
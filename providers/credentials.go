@@ -0,0 +1,61 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultCredentialCheckTimeout bounds each provider's startup credential
+// check, so an unreachable provider delays startup by seconds at most.
+const DefaultCredentialCheckTimeout = 5 * time.Second
+
+var errCredentialsRejected = errors.New("provider rejected the configured credentials or is unreachable")
+
+// CredentialCheck is the outcome of checking one provider's configured
+// credentials.
+type CredentialCheck struct {
+	Provider  string
+	Available bool
+	Err       error
+	Latency   time.Duration
+}
+
+// ValidateCredentials calls IsAvailable on every provider concurrently, each
+// bounded by timeout, and returns the outcome by provider name. A provider
+// that does not answer in time is reported unavailable. It never fails as a
+// whole: callers decide what an unavailable provider means for them.
+func ValidateCredentials(ctx context.Context, named map[string]PaymentProvider, timeout time.Duration) map[string]CredentialCheck {
+	if timeout <= 0 {
+		timeout = DefaultCredentialCheckTimeout
+	}
+
+	results := make(map[string]CredentialCheck, len(named))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, provider := range named {
+		wg.Add(1)
+		go func(name string, provider PaymentProvider) {
+			defer wg.Done()
+			start := time.Now()
+			available, err := CallWithTimeout(ctx, timeout, "credential_check", func(ctx context.Context) (bool, error) {
+				return provider.IsAvailable(ctx), nil
+			})
+			if err == nil && !available {
+				err = errCredentialsRejected
+			}
+
+			mu.Lock()
+			results[name] = CredentialCheck{
+				Provider:  name,
+				Available: err == nil,
+				Err:       err,
+				Latency:   time.Since(start),
+			}
+			mu.Unlock()
+		}(name, provider)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,69 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unauthorizedTransport answers every request the way Xendit answers an
+// invalid API key.
+type unauthorizedTransport struct{}
+
+func (unauthorizedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusUnauthorized,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error_code":"INVALID_API_KEY","message":"API key is invalid"}`)),
+		Request:    r,
+	}, nil
+}
+
+// hangingProvider never answers its availability check, like a provider
+// behind a black-holed network route.
+type hangingProvider struct {
+	PaymentProvider
+	release chan struct{}
+}
+
+func (p *hangingProvider) IsAvailable(context.Context) bool {
+	<-p.release
+	return true
+}
+
+func TestValidateCredentialsReportsRejectedKeyUnavailable(t *testing.T) {
+	xendit := CreateXenditProvider("xnd_development_revoked")
+	xendit.SetHTTPClient(&http.Client{Transport: unauthorizedTransport{}})
+	healthy := newFakeCustomerProvider("stripe")
+
+	results := ValidateCredentials(context.Background(), map[string]PaymentProvider{
+		"xendit": xendit,
+		"stripe": healthy,
+	}, time.Second)
+
+	if check := results["xendit"]; check.Available || !errors.Is(check.Err, errCredentialsRejected) {
+		t.Fatalf("expected xendit with a rejected key to be unavailable, got %+v", check)
+	}
+	if check := results["stripe"]; !check.Available || check.Err != nil {
+		t.Fatalf("expected stripe to be available, got %+v", check)
+	}
+}
+
+func TestValidateCredentialsTimesOutSlowProvider(t *testing.T) {
+	slow := &hangingProvider{release: make(chan struct{})}
+	defer close(slow.release)
+
+	start := time.Now()
+	results := ValidateCredentials(context.Background(), map[string]PaymentProvider{"razorpay": slow}, 20*time.Millisecond)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the check to give up after its timeout, took %s", elapsed)
+	}
+	if check := results["razorpay"]; check.Available || !errors.Is(check.Err, ErrProviderTimeout) {
+		t.Fatalf("expected the unresponsive provider to be reported as timed out, got %+v", check)
+	}
+}
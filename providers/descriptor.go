@@ -0,0 +1,48 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var ErrInvalidStatementDescriptor = errors.New("invalid statement descriptor")
+
+type descriptorRules struct {
+	minLength     int
+	maxLength     int
+	requireLetter bool
+}
+
+var (
+	stripeDescriptorRules       = descriptorRules{minLength: 5, maxLength: 22, requireLetter: true}
+	stripeDescriptorSuffixRules = descriptorRules{minLength: 1, maxLength: 22, requireLetter: true}
+	airwallexDescriptorRules    = descriptorRules{minLength: 1, maxLength: 32}
+)
+
+// descriptorForbiddenChars are rejected by card networks on statements.
+const descriptorForbiddenChars = `<>\'"*`
+
+func validateDescriptor(field, value string, rules descriptorRules) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) < rules.minLength || len(value) > rules.maxLength {
+		return fmt.Errorf("%w: %s must be between %d and %d characters", ErrInvalidStatementDescriptor, field, rules.minLength, rules.maxLength)
+	}
+
+	hasLetter := false
+	for _, r := range value {
+		if r < ' ' || r > '~' || strings.ContainsRune(descriptorForbiddenChars, r) {
+			return fmt.Errorf("%w: %s contains unsupported character %q", ErrInvalidStatementDescriptor, field, r)
+		}
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		}
+	}
+	if rules.requireLetter && !hasLetter {
+		return fmt.Errorf("%w: %s must contain at least one letter", ErrInvalidStatementDescriptor, field)
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
 )
 
 type ProviderExecutor struct {
@@ -39,10 +41,11 @@ func DefaultProviderExecutorConfig() ProviderExecutorConfig {
 func (pe *ProviderExecutor) Execute(ctx context.Context, provider string, fn func() error) error {
 	fuse := pe.getOrCreateFuse(provider)
 
-	return fuse.Execute(ctx, func() error {
+	err := fuse.Execute(ctx, func() error {
 		_, err := Retry(ctx, pe.retryConfig, fn)
 		return err
 	})
+	return wrapProviderError(ctx, provider, err)
 }
 
 func (pe *ProviderExecutor) ExecuteWithResult(ctx context.Context, provider string, fn func() (interface{}, error)) (interface{}, error) {
@@ -58,7 +61,20 @@ func (pe *ProviderExecutor) ExecuteWithResult(ctx context.Context, provider stri
 		return retryErr
 	})
 
-	return result, err
+	return result, wrapProviderError(ctx, provider, err)
+}
+
+// wrapProviderError tags a failed provider call with the correlation ID so
+// the error can be matched against the request's log entries.
+func wrapProviderError(ctx context.Context, provider string, err error) error {
+	if err == nil {
+		return nil
+	}
+	correlationID, _ := ctx.Value(ctxkeys.CorrelationID).(string)
+	if correlationID == "" {
+		return err
+	}
+	return fmt.Errorf("%s call failed (correlation_id=%s): %w", provider, correlationID, err)
 }
 
 func (pe *ProviderExecutor) getOrCreateFuse(provider string) *Fuse {
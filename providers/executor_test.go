@@ -0,0 +1,26 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+)
+
+func TestExecutorTagsProviderErrorsWithCorrelationID(t *testing.T) {
+	cfg := DefaultProviderExecutorConfig()
+	cfg.RetryConfig.RetryableCheck = func(error) bool { return false }
+	executor := CreateProviderExecutor(cfg)
+	declined := errors.New("card declined")
+
+	ctx := context.WithValue(context.Background(), ctxkeys.CorrelationID, "req_123")
+	err := executor.Execute(ctx, "stripe", func() error { return declined })
+	if !errors.Is(err, declined) {
+		t.Fatalf("expected the provider error to be preserved, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "correlation_id=req_123") {
+		t.Fatalf("expected error to carry the correlation ID, got %q", err.Error())
+	}
+}
@@ -0,0 +1,140 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/stripe/stripe-go/v86"
+)
+
+// APIError is an error response from a provider's HTTP API.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// ChargeFailoverError is returned when a charge failed on every provider
+// failover tried. Err is the error from the last attempt.
+type ChargeFailoverError struct {
+	Attempts models.ChargeAttempts
+	Err      error
+}
+
+func (e *ChargeFailoverError) Error() string {
+	providers := make([]string, 0, len(e.Attempts))
+	for _, attempt := range e.Attempts {
+		providers = append(providers, attempt.Provider)
+	}
+	return fmt.Sprintf("charge failed on %s: %v", strings.Join(providers, ", "), e.Err)
+}
+
+func (e *ChargeFailoverError) Unwrap() error { return e.Err }
+
+// IsFailoverable reports whether a charge error shows the provider did not
+// process the charge, so another provider can safely be tried. Declines are
+// never failoverable: the issuer refused the card and would refuse it again
+// through another provider. Timeouts and plain 500s are not either, since the
+// charge may have gone through.
+func IsFailoverable(err error) bool {
+	if err == nil || errors.Is(err, ErrPaymentDeclined) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) {
+		return stripeErr.Type != stripe.ErrorTypeCard && failoverStatus(stripeErr.HTTPStatusCode)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return failoverStatus(apiErr.StatusCode)
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Op == "dial"
+	}
+	return false
+}
+
+func failoverStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// chargeWithFailover charges through first and, while attempts fail in a way
+// IsFailoverable accepts, moves on to the next provider that supports the
+// currency and payment method, in preference order. Every attempt is
+// recorded on the response, or on the ChargeFailoverError if none succeed.
+func (m *MultiProviderSelector) chargeWithFailover(ctx context.Context, first PaymentProvider, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+	var attempts models.ChargeAttempts
+	var lastErr error
+
+	for _, provider := range m.failoverCandidates(first, req) {
+		if provider != first && !provider.IsAvailable(ctx) {
+			continue
+		}
+
+		providerName := provider.Name()
+		start := time.Now()
+		resp, err := m.executeCharge(ctx, provider, req)
+		attempt := models.AttemptResult{
+			Provider:       providerName,
+			Success:        err == nil && resp != nil,
+			ResponseTimeMs: time.Since(start).Milliseconds(),
+			Timestamp:      start,
+		}
+		if err != nil {
+			attempt.ErrorCode = m.errorClassifier.ClassifyMessage(providerName, err.Error())
+			attempt.ErrorMessage = err.Error()
+		}
+		attempts = append(attempts, attempt)
+
+		if attempt.Success {
+			resp.ProviderAttempts = attempts
+			return resp, nil
+		}
+		lastErr = err
+		if lastErr == nil {
+			lastErr = fmt.Errorf("%s returned no charge", providerName)
+		}
+		if !IsFailoverable(lastErr) {
+			break
+		}
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("no available payment provider")
+	}
+	return nil, &ChargeFailoverError{Attempts: attempts, Err: lastErr}
+}
+
+func (m *MultiProviderSelector) failoverCandidates(first PaymentProvider, req *models.ChargeRequest) []PaymentProvider {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	candidates := []PaymentProvider{first}
+	for _, provider := range m.Providers {
+		if provider == first {
+			continue
+		}
+		caps := provider.Capabilities()
+		if caps.SupportsCurrency(req.Currency) && caps.SupportsPaymentMethod(req.PaymentMethodType) {
+			candidates = append(candidates, provider)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,27 @@
+package providers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/metrics"
+	"github.com/malwarebo/conductor/models"
+)
+
+func recordChargeMetrics(providerName, currency string, resp *models.ChargeResponse, err error, elapsed time.Duration) {
+	status := "failed"
+	if err == nil && resp != nil {
+		status = string(resp.Status)
+	}
+	metrics.ChargesTotal.Inc(providerName, strings.ToUpper(currency), status)
+	metrics.ProviderLatency.Observe(elapsed.Seconds(), providerName, "charge")
+}
+
+func recordRefundMetrics(providerName string, resp *models.RefundResponse, err error, elapsed time.Duration) {
+	status := "failed"
+	if err == nil && resp != nil {
+		status = resp.Status
+	}
+	metrics.RefundsTotal.Inc(providerName, status)
+	metrics.ProviderLatency.Observe(elapsed.Seconds(), providerName, "refund")
+}
@@ -0,0 +1,51 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/internal/metrics"
+	"github.com/malwarebo/conductor/models"
+)
+
+func scrapeSample(t *testing.T, series string) float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Default.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), series+" "); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("invalid sample value %q: %v", value, err)
+			}
+			return parsed
+		}
+	}
+	return 0
+}
+
+func TestChargeIncrementsChargesCounter(t *testing.T) {
+	config := DefaultMultiProviderConfig()
+	config.EnableSmartRouting = false
+	selector := CreateMultiProviderSelectorWithConfig([]PaymentProvider{&fakeTracedProvider{}}, nil, config)
+
+	series := `conductor_charges_total{provider="stripe",currency="USD",status="succeeded"}`
+	before := scrapeSample(t, series)
+
+	if _, err := selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "usd"}); err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+
+	if after := scrapeSample(t, series); after != before+1 {
+		t.Fatalf("expected %s to go from %v to %v, got %v", series, before, before+1, after)
+	}
+	if latency := scrapeSample(t, `conductor_provider_request_duration_seconds_count{provider="stripe",operation="charge"}`); latency < 1 {
+		t.Fatalf("expected provider latency to be observed, got count %v", latency)
+	}
+}
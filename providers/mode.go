@@ -0,0 +1,27 @@
+package providers
+
+import "strings"
+
+type Mode string
+
+const (
+	ModeTest Mode = "test"
+	ModeLive Mode = "live"
+)
+
+var (
+	stripeTestKeyPrefixes   = []string{"sk_test_", "rk_test_"}
+	xenditTestKeyPrefixes   = []string{"xnd_development_"}
+	razorpayTestKeyPrefixes = []string{"rzp_test_"}
+)
+
+// modeFromKey infers the account mode from the credential itself, since for
+// Stripe, Xendit and Razorpay the key decides which environment is hit.
+func modeFromKey(key string, testPrefixes []string) Mode {
+	for _, prefix := range testPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return ModeTest
+		}
+	}
+	return ModeLive
+}
@@ -3,9 +3,12 @@ package providers
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/malwarebo/conductor/internal/circuitbreaker"
 	"github.com/malwarebo/conductor/internal/routing"
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/stores"
@@ -16,17 +19,28 @@ type MultiProviderSelector struct {
 	mu        sync.RWMutex
 
 	paymentProviderMap      map[string]PaymentProvider
+	paymentChargeIDs        map[string]string
 	subscriptionProviderMap map[string]PaymentProvider
 	disputeProviderMap      map[string]PaymentProvider
+	customerProviderMap     map[string]PaymentProvider
 
 	providerPreferences map[string]int
 	providerByName      map[string]PaymentProvider
-	mappingStore        *stores.ProviderMappingStore
+	mappingStore        MappingStore
+	availability        *AvailabilityCache
 
 	routingEngine   *routing.Engine
 	retryManager    *routing.RetryManager
 	errorClassifier *routing.ErrorClassifier
 	smartRouting    bool
+	chargeFailover  bool
+}
+
+// MappingStore persists which provider owns each entity, and the provider's
+// own ID for it, so lookups survive a restart.
+type MappingStore interface {
+	GetByEntity(ctx context.Context, entityID, entityType string) (*models.ProviderMapping, error)
+	CreateIfNotExists(ctx context.Context, mapping *models.ProviderMapping) (bool, error)
 }
 
 type MultiProviderConfig struct {
@@ -36,6 +50,17 @@ type MultiProviderConfig struct {
 	BINStore           *stores.BINStore
 	MerchantStore      *stores.MerchantConfigStore
 	RuleStore          *stores.RoutingRuleStore
+
+	// EnableChargeFailover retries a routed charge on the next capable
+	// provider when the first could not process it. Pinned charges never
+	// fail over.
+	EnableChargeFailover bool
+
+	// ProviderOrder ranks providers by name, e.g. ["airwallex", "stripe"].
+	// It decides which provider is tried first when no currency preference
+	// applies, the failover order, and ties between equally scored
+	// providers. Providers it does not name follow in registration order.
+	ProviderOrder []string
 }
 
 func DefaultMultiProviderConfig() MultiProviderConfig {
@@ -46,22 +71,20 @@ func DefaultMultiProviderConfig() MultiProviderConfig {
 	}
 }
 
-func CreateMultiProviderSelector(providers []PaymentProvider, mappingStore *stores.ProviderMappingStore) *MultiProviderSelector {
+func CreateMultiProviderSelector(providers []PaymentProvider, mappingStore MappingStore) *MultiProviderSelector {
 	return CreateMultiProviderSelectorWithConfig(providers, mappingStore, DefaultMultiProviderConfig())
 }
 
-func CreateMultiProviderSelectorWithConfig(providers []PaymentProvider, mappingStore *stores.ProviderMappingStore, config MultiProviderConfig) *MultiProviderSelector {
+func CreateMultiProviderSelectorWithConfig(providers []PaymentProvider, mappingStore MappingStore, config MultiProviderConfig) *MultiProviderSelector {
+	providers = orderProviders(providers, config.ProviderOrder)
 	preferences := make(map[string]int)
 	byName := make(map[string]PaymentProvider)
 
+	config.RoutingConfig.AvailableProviders = make([]string, 0, len(providers))
 	for i, provider := range providers {
-		name := getProviderTypeName(provider)
+		name := strings.ToLower(provider.Name())
 		preferences[name] = i
 		byName[name] = provider
-	}
-
-	config.RoutingConfig.AvailableProviders = make([]string, 0, len(providers))
-	for name := range byName {
 		config.RoutingConfig.AvailableProviders = append(config.RoutingConfig.AvailableProviders, name)
 	}
 
@@ -76,31 +99,47 @@ func CreateMultiProviderSelectorWithConfig(providers []PaymentProvider, mappingS
 	return &MultiProviderSelector{
 		Providers:               providers,
 		paymentProviderMap:      make(map[string]PaymentProvider),
+		paymentChargeIDs:        make(map[string]string),
 		subscriptionProviderMap: make(map[string]PaymentProvider),
 		disputeProviderMap:      make(map[string]PaymentProvider),
+		customerProviderMap:     make(map[string]PaymentProvider),
 		providerPreferences:     preferences,
 		providerByName:          byName,
 		mappingStore:            mappingStore,
+		availability:            CreateAvailabilityCache(DefaultAvailabilityTTL),
 		routingEngine:           engine,
 		retryManager:            retryMgr,
 		errorClassifier:         routing.NewErrorClassifier(),
 		smartRouting:            config.EnableSmartRouting,
+		chargeFailover:          config.EnableChargeFailover,
 	}
 }
 
-func getProviderTypeName(provider PaymentProvider) string {
-	switch provider.(type) {
-	case *StripeProvider:
-		return "stripe"
-	case *XenditProvider:
-		return "xendit"
-	case *RazorpayProvider:
-		return "razorpay"
-	case *AirwallexProvider:
-		return "airwallex"
-	default:
-		return "unknown"
+// orderProviders returns providers sorted by their position in order, with
+// any it does not name kept after them in their original order.
+func orderProviders(providers []PaymentProvider, order []string) []PaymentProvider {
+	if len(order) == 0 {
+		return providers
+	}
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := rank[name]; !ok {
+			rank[name] = i
+		}
 	}
+
+	ordered := make([]PaymentProvider, len(providers))
+	copy(ordered, providers)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, iok := rank[strings.ToLower(ordered[i].Name())]
+		rj, jok := rank[strings.ToLower(ordered[j].Name())]
+		if iok != jok {
+			return iok
+		}
+		return iok && ri < rj
+	})
+	return ordered
 }
 
 func (m *MultiProviderSelector) Name() string {
@@ -108,32 +147,73 @@ func (m *MultiProviderSelector) Name() string {
 }
 
 func (m *MultiProviderSelector) Capabilities() ProviderCapabilities {
-	caps := ProviderCapabilities{
-		SupportedCurrencies:     []string{},
-		SupportedPaymentMethods: []models.PaymentMethodType{},
+	all := make([]ProviderCapabilities, 0, len(m.Providers))
+	for _, provider := range m.Providers {
+		all = append(all, provider.Capabilities())
 	}
+	return MergeCapabilities(all...)
+}
 
-	for _, provider := range m.Providers {
-		providerCaps := provider.Capabilities()
-		caps.SupportsInvoices = caps.SupportsInvoices || providerCaps.SupportsInvoices
-		caps.SupportsPayouts = caps.SupportsPayouts || providerCaps.SupportsPayouts
-		caps.SupportsPaymentSessions = caps.SupportsPaymentSessions || providerCaps.SupportsPaymentSessions
-		caps.Supports3DS = caps.Supports3DS || providerCaps.Supports3DS
-		caps.SupportsManualCapture = caps.SupportsManualCapture || providerCaps.SupportsManualCapture
-		caps.SupportsBalance = caps.SupportsBalance || providerCaps.SupportsBalance
-		caps.SupportedCurrencies = append(caps.SupportedCurrencies, providerCaps.SupportedCurrencies...)
-		caps.SupportedPaymentMethods = append(caps.SupportedPaymentMethods, providerCaps.SupportedPaymentMethods...)
+func (m *MultiProviderSelector) CapabilitiesFor(providerName string) (ProviderCapabilities, bool) {
+	provider, ok := m.providerByName[providerName]
+	if !ok {
+		return ProviderCapabilities{}, false
 	}
+	return provider.Capabilities(), true
+}
 
-	return caps
+// AvailableCapabilities returns the capabilities of each provider that is
+// currently reachable, keyed by provider name.
+func (m *MultiProviderSelector) AvailableCapabilities(ctx context.Context) map[string]ProviderCapabilities {
+	result := make(map[string]ProviderCapabilities, len(m.providerByName))
+	for name, provider := range m.providerByName {
+		if m.availability.IsAvailable(ctx, name, provider) {
+			result[name] = provider.Capabilities()
+		}
+	}
+	return result
+}
+
+func (m *MultiProviderSelector) ProviderForCurrency(ctx context.Context, currency string) (PaymentProvider, error) {
+	return m.selectProviderByCurrency(ctx, currency)
+}
+
+func (m *MultiProviderSelector) ProviderForEntity(ctx context.Context, entityID, entityType string) (PaymentProvider, error) {
+	if entityType == "subscription" {
+		m.mu.RLock()
+		provider, ok := m.subscriptionProviderMap[entityID]
+		m.mu.RUnlock()
+		if ok {
+			return provider, nil
+		}
+	}
+	return m.getProviderFromDB(ctx, entityID, entityType)
+}
+
+func (m *MultiProviderSelector) ProviderForSubscriptions(ctx context.Context) (PaymentProvider, error) {
+	return m.selectAvailableProvider(ctx, "stripe")
 }
 
 func (m *MultiProviderSelector) getProviderFromDB(ctx context.Context, entityID, entityType string) (PaymentProvider, error) {
+	mapping, err := m.getMappingFromDB(ctx, entityID, entityType)
+	if err != nil {
+		return nil, err
+	}
+	return m.providerForMapping(mapping)
+}
+
+func (m *MultiProviderSelector) getMappingFromDB(ctx context.Context, entityID, entityType string) (*models.ProviderMapping, error) {
+	if m.mappingStore == nil {
+		return nil, fmt.Errorf("no provider mapping found for %s: %s", entityType, entityID)
+	}
 	mapping, err := m.mappingStore.GetByEntity(ctx, entityID, entityType)
 	if err != nil {
 		return nil, fmt.Errorf("no provider mapping found for %s: %s", entityType, entityID)
 	}
+	return mapping, nil
+}
 
+func (m *MultiProviderSelector) providerForMapping(mapping *models.ProviderMapping) (PaymentProvider, error) {
 	if idx, ok := m.providerPreferences[mapping.ProviderName]; ok && idx < len(m.Providers) {
 		return m.Providers[idx], nil
 	}
@@ -142,28 +222,17 @@ func (m *MultiProviderSelector) getProviderFromDB(ctx context.Context, entityID,
 }
 
 func (m *MultiProviderSelector) saveProviderMapping(ctx context.Context, entityID, entityType, providerName, providerEntityID string) error {
+	if m.mappingStore == nil {
+		return nil
+	}
 	mapping := &models.ProviderMapping{
 		EntityID:         entityID,
 		EntityType:       entityType,
 		ProviderName:     providerName,
 		ProviderEntityID: providerEntityID,
 	}
-	return m.mappingStore.Create(ctx, mapping)
-}
-
-func (m *MultiProviderSelector) getProviderName(provider PaymentProvider) string {
-	switch provider.(type) {
-	case *StripeProvider:
-		return "stripe"
-	case *XenditProvider:
-		return "xendit"
-	case *RazorpayProvider:
-		return "razorpay"
-	case *AirwallexProvider:
-		return "airwallex"
-	default:
-		return "unknown"
-	}
+	_, err := m.mappingStore.CreateIfNotExists(ctx, mapping)
+	return err
 }
 
 func (m *MultiProviderSelector) selectAvailableProvider(ctx context.Context, preferredProvider string) (PaymentProvider, error) {
@@ -202,6 +271,55 @@ func (m *MultiProviderSelector) selectProviderByCurrency(ctx context.Context, cu
 	return m.selectAvailableProvider(ctx, "")
 }
 
+// selectProviderForMethod picks a provider that supports both the currency
+// and the payment method type, trying the currency's usual provider first.
+func (m *MultiProviderSelector) selectProviderForMethod(ctx context.Context, currency string, method models.PaymentMethodType) (PaymentProvider, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	candidates := make([]PaymentProvider, 0, len(m.Providers)+1)
+	if preferred, ok := currencyProviderMap[currency]; ok {
+		if idx, ok := m.providerPreferences[preferred]; ok && idx < len(m.Providers) {
+			candidates = append(candidates, m.Providers[idx])
+		}
+	}
+	candidates = append(candidates, m.Providers...)
+
+	supported := false
+	for _, provider := range candidates {
+		caps := provider.Capabilities()
+		if !caps.SupportsCurrency(currency) || !caps.SupportsPaymentMethod(method) {
+			continue
+		}
+		supported = true
+		if provider.IsAvailable(ctx) {
+			return provider, nil
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("%w: no configured provider supports %s in %s", ErrPaymentMethodUnsupported, method, currency)
+	}
+	return nil, fmt.Errorf("no available payment provider")
+}
+
+// selectPinnedProvider resolves a provider named explicitly on the request,
+// bypassing routing. It must be configured, support the currency and be
+// reachable; the request is rejected rather than routed elsewhere.
+func (m *MultiProviderSelector) selectPinnedProvider(ctx context.Context, name, currency string) (PaymentProvider, error) {
+	name = strings.ToLower(name)
+	provider, ok := m.providerByName[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProviderNotConfigured, name)
+	}
+	if !provider.Capabilities().SupportsCurrency(currency) {
+		return nil, fmt.Errorf("%w: %s does not support %s", ErrProviderCurrency, name, currency)
+	}
+	if !m.availability.IsAvailable(ctx, name, provider) {
+		return nil, fmt.Errorf("%w: %s", ErrProviderUnavailable, name)
+	}
+	return provider, nil
+}
+
 func (m *MultiProviderSelector) selectProviderWithRouting(ctx context.Context, rc *models.RoutingContext) (PaymentProvider, *models.RoutingDecision, error) {
 	if !m.smartRouting || m.routingEngine == nil {
 		provider, err := m.selectProviderByCurrency(ctx, rc.Currency)
@@ -243,11 +361,38 @@ func (m *MultiProviderSelector) estimateCost(provider string, amount float64) fl
 	return amount * 0.03
 }
 
-func (m *MultiProviderSelector) Charge(ctx context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+func (m *MultiProviderSelector) Charge(ctx context.Context, req *models.ChargeRequest) (_ *models.ChargeResponse, err error) {
+	ctx, span := startProviderSpan(ctx, "charge", req.Currency)
+	defer func() { endProviderSpan(span, err) }()
+
+	pinned := req.Provider
+	if req.SubAccountID != "" {
+		// Sub-accounts only exist on Xendit, so they decide the route.
+		if pinned != "" && !strings.EqualFold(pinned, "xendit") {
+			return nil, ErrSubAccountNotSupported
+		}
+		pinned = "xendit"
+	}
+
+	if pinned != "" {
+		provider, err := m.selectPinnedProvider(ctx, pinned, req.Currency)
+		if err != nil {
+			return nil, err
+		}
+		if err := CheckPaymentMethod(provider, req.PaymentMethodType); err != nil {
+			return nil, err
+		}
+		explanation := &models.RoutingExplanation{
+			SelectedProvider: provider.Name(),
+			Reason:           models.RoutingReasonExplicit,
+		}
+		return explainCharge(explanation)(m.executeCharge(ctx, provider, req))
+	}
+
 	rc := &models.RoutingContext{
 		TransactionID:   req.IdempotencyKey,
 		MerchantID:      m.getMetadataValue(req.Metadata, "merchant_id"),
-		Amount:          float64(req.Amount) / 100,
+		Amount:          req.Money().Major(),
 		Currency:        req.Currency,
 		PaymentMethod:   req.PaymentMethod,
 		CustomerID:      req.CustomerID,
@@ -260,11 +405,69 @@ func (m *MultiProviderSelector) Charge(ctx context.Context, req *models.ChargeRe
 		return nil, err
 	}
 
+	if req.PaymentMethodType != "" && !provider.Capabilities().SupportsPaymentMethod(req.PaymentMethodType) {
+		// The routed provider cannot take this method; retries would only
+		// try the same routing decision, so pick a provider directly.
+		provider, err = m.selectProviderForMethod(ctx, req.Currency, req.PaymentMethodType)
+		if err != nil {
+			return nil, err
+		}
+		decision = nil
+	}
+
+	explain := explainCharge(m.explainSelection(provider, decision, req.Currency))
+	if m.chargeFailover {
+		return explain(m.chargeWithFailover(ctx, provider, req))
+	}
 	if m.retryManager != nil && decision != nil {
-		return m.chargeWithRetry(ctx, req, decision)
+		return explain(m.chargeWithRetry(ctx, req, decision))
 	}
 
-	return m.executeCharge(ctx, provider, req)
+	return explain(m.executeCharge(ctx, provider, req))
+}
+
+// explainSelection says why provider was picked for a routed charge: the
+// routing engine's decision when it was followed, otherwise the currency's
+// usual provider or, failing that, the first one available.
+func (m *MultiProviderSelector) explainSelection(provider PaymentProvider, decision *models.RoutingDecision, currency string) *models.RoutingExplanation {
+	name := provider.Name()
+	explanation := &models.RoutingExplanation{SelectedProvider: name, Reason: models.RoutingReasonDefault}
+
+	switch {
+	case decision != nil && decision.SelectedProvider == name:
+		explanation.Reason = models.RoutingReasonAIRouted
+		explanation.Detail = decision.Reason
+		if len(decision.Scores) > 0 {
+			explanation.Confidence = decision.Scores[0].Score
+		}
+	case currencyProviderMap[currency] == name:
+		explanation.Reason = models.RoutingReasonCurrency
+	}
+	return explanation
+}
+
+// explainCharge attaches explanation to a successful charge. A charge that
+// ended up on a different provider than the one selected was failed over to
+// it, and is explained as such.
+func explainCharge(explanation *models.RoutingExplanation) func(*models.ChargeResponse, error) (*models.ChargeResponse, error) {
+	return func(resp *models.ChargeResponse, err error) (*models.ChargeResponse, error) {
+		if err != nil || resp == nil {
+			return resp, err
+		}
+		served := resp.ProviderName
+		if n := len(resp.ProviderAttempts); n > 0 {
+			served = resp.ProviderAttempts[n-1].Provider
+		}
+		if served != "" && served != explanation.SelectedProvider {
+			explanation = &models.RoutingExplanation{
+				SelectedProvider: served,
+				Reason:           models.RoutingReasonFailover,
+				Detail:           "failed over from " + explanation.SelectedProvider,
+			}
+		}
+		resp.RoutingExplanation = explanation
+		return resp, nil
+	}
 }
 
 func (m *MultiProviderSelector) chargeWithRetry(ctx context.Context, req *models.ChargeRequest, decision *models.RoutingDecision) (*models.ChargeResponse, error) {
@@ -275,8 +478,10 @@ func (m *MultiProviderSelector) chargeWithRetry(ctx context.Context, req *models
 		}
 
 		start := time.Now()
-		resp, err := provider.Charge(ctx, req)
-		latency := time.Since(start).Milliseconds()
+		resp, err := tagProvider(ctx, provider).Charge(ctx, req)
+		elapsed := time.Since(start)
+		latency := elapsed.Milliseconds()
+		recordChargeMetrics(providerName, req.Currency, resp, err, elapsed)
 
 		result := &routing.PaymentResult{
 			ResponseTime: latency,
@@ -286,7 +491,7 @@ func (m *MultiProviderSelector) chargeWithRetry(ctx context.Context, req *models
 			result.Success = false
 			result.ErrorMessage = err.Error()
 			result.ErrorCode = m.errorClassifier.ClassifyMessage(providerName, err.Error())
-			m.recordRoutingResult(providerName, false, latency, float64(req.Amount)/100)
+			m.recordRoutingResult(providerName, false, latency, req.Money().Major())
 			return result, nil
 		}
 
@@ -295,13 +500,11 @@ func (m *MultiProviderSelector) chargeWithRetry(ctx context.Context, req *models
 			result.ProviderID = resp.ProviderChargeID
 
 			if result.Success {
-				m.mu.Lock()
-				m.paymentProviderMap[resp.ID] = provider
-				m.mu.Unlock()
+				m.rememberPayment(resp.ID, provider, resp.ProviderChargeID)
 				_ = m.saveProviderMapping(ctx, resp.ID, "payment", providerName, resp.ProviderChargeID)
 			}
 
-			m.recordRoutingResult(providerName, result.Success, latency, float64(req.Amount)/100)
+			m.recordRoutingResult(providerName, result.Success, latency, req.Money().Major())
 		}
 
 		return result, nil
@@ -339,18 +542,18 @@ func (m *MultiProviderSelector) chargeWithRetry(ctx context.Context, req *models
 
 func (m *MultiProviderSelector) executeCharge(ctx context.Context, provider PaymentProvider, req *models.ChargeRequest) (*models.ChargeResponse, error) {
 	start := time.Now()
-	resp, err := provider.Charge(ctx, req)
-	latency := time.Since(start).Milliseconds()
+	resp, err := tagProvider(ctx, provider).Charge(ctx, req)
+	elapsed := time.Since(start)
+	latency := elapsed.Milliseconds()
 
-	providerName := m.getProviderName(provider)
+	providerName := provider.Name()
 	success := err == nil && resp != nil
+	recordChargeMetrics(providerName, req.Currency, resp, err, elapsed)
 
-	m.recordRoutingResult(providerName, success, latency, float64(req.Amount)/100)
+	m.recordRoutingResult(providerName, success, latency, req.Money().Major())
 
 	if success && resp.ID != "" {
-		m.mu.Lock()
-		m.paymentProviderMap[resp.ID] = provider
-		m.mu.Unlock()
+		m.rememberPayment(resp.ID, provider, resp.ProviderChargeID)
 		_ = m.saveProviderMapping(ctx, resp.ID, "payment", providerName, resp.ProviderChargeID)
 	}
 
@@ -383,41 +586,93 @@ func (m *MultiProviderSelector) getMetadataValue(metadata map[string]interface{}
 	return ""
 }
 
-func (m *MultiProviderSelector) Refund(ctx context.Context, req *models.RefundRequest) (*models.RefundResponse, error) {
+func (m *MultiProviderSelector) Refund(ctx context.Context, req *models.RefundRequest) (_ *models.RefundResponse, err error) {
+	ctx, span := startProviderSpan(ctx, "refund", req.Currency)
+	defer func() { endProviderSpan(span, err) }()
+
+	provider, providerChargeID, err := m.resolvePayment(ctx, req.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	providerReq := *req
+	providerReq.PaymentID = providerChargeID
+
+	start := time.Now()
+	resp, err := tagProvider(ctx, provider).Refund(ctx, &providerReq)
+	recordRefundMetrics(provider.Name(), resp, err, time.Since(start))
+	if resp != nil {
+		resp.PaymentID = req.PaymentID
+	}
+	return resp, err
+}
+
+func (m *MultiProviderSelector) rememberPayment(paymentID string, provider PaymentProvider, providerChargeID string) {
+	m.mu.Lock()
+	m.paymentProviderMap[paymentID] = provider
+	if providerChargeID != "" {
+		m.paymentChargeIDs[paymentID] = providerChargeID
+	}
+	m.mu.Unlock()
+}
+
+// resolvePayment returns the provider that owns a payment and that
+// provider's charge ID for it. The persisted mapping is used whenever this
+// process did not create the charge itself, such as after a restart.
+func (m *MultiProviderSelector) resolvePayment(ctx context.Context, paymentID string) (PaymentProvider, string, error) {
 	m.mu.RLock()
-	provider, ok := m.paymentProviderMap[req.PaymentID]
+	provider, ok := m.paymentProviderMap[paymentID]
+	providerChargeID := m.paymentChargeIDs[paymentID]
 	m.mu.RUnlock()
+	if ok && providerChargeID != "" {
+		return provider, providerChargeID, nil
+	}
 
-	if !ok {
-		var err error
-		provider, err = m.getProviderFromDB(ctx, req.PaymentID, "payment")
-		if err != nil {
-			return nil, err
+	mapping, err := m.getMappingFromDB(ctx, paymentID, "payment")
+	if err != nil {
+		if ok {
+			return provider, paymentID, nil
 		}
+		return nil, "", err
+	}
+	provider, err = m.providerForMapping(mapping)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return provider.Refund(ctx, req)
+	providerChargeID = mapping.ProviderEntityID
+	if providerChargeID == "" {
+		providerChargeID = paymentID
+	}
+	m.rememberPayment(paymentID, provider, providerChargeID)
+	return provider, providerChargeID, nil
 }
 
-func (m *MultiProviderSelector) CreateSubscription(ctx context.Context, req *models.CreateSubscriptionRequest) (*models.Subscription, error) {
+func (m *MultiProviderSelector) CreateSubscription(ctx context.Context, req *models.CreateSubscriptionRequest) (_ *models.Subscription, err error) {
+	ctx, span := startProviderSpan(ctx, "create_subscription", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.selectAvailableProvider(ctx, "stripe")
 	if err != nil {
 		return nil, err
 	}
 
-	sub, err := provider.CreateSubscription(ctx, req)
+	sub, err := tagProvider(ctx, provider).CreateSubscription(ctx, req)
 	if err == nil && sub != nil && sub.ID != "" {
 		m.mu.Lock()
 		m.subscriptionProviderMap[sub.ID] = provider
 		m.mu.Unlock()
 
-		providerName := m.getProviderName(provider)
+		providerName := provider.Name()
 		_ = m.saveProviderMapping(ctx, sub.ID, "subscription", providerName, sub.ID)
 	}
 	return sub, err
 }
 
-func (m *MultiProviderSelector) UpdateSubscription(ctx context.Context, subscriptionID string, req *models.UpdateSubscriptionRequest) (*models.Subscription, error) {
+func (m *MultiProviderSelector) UpdateSubscription(ctx context.Context, subscriptionID string, req *models.UpdateSubscriptionRequest) (_ *models.Subscription, err error) {
+	ctx, span := startProviderSpan(ctx, "update_subscription", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	m.mu.RLock()
 	provider, ok := m.subscriptionProviderMap[subscriptionID]
 	m.mu.RUnlock()
@@ -430,10 +685,13 @@ func (m *MultiProviderSelector) UpdateSubscription(ctx context.Context, subscrip
 		}
 	}
 
-	return provider.UpdateSubscription(ctx, subscriptionID, req)
+	return tagProvider(ctx, provider).UpdateSubscription(ctx, subscriptionID, req)
 }
 
-func (m *MultiProviderSelector) CancelSubscription(ctx context.Context, subscriptionID string, req *models.CancelSubscriptionRequest) (*models.Subscription, error) {
+func (m *MultiProviderSelector) CancelSubscription(ctx context.Context, subscriptionID string, req *models.CancelSubscriptionRequest) (_ *models.Subscription, err error) {
+	ctx, span := startProviderSpan(ctx, "cancel_subscription", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	m.mu.RLock()
 	provider, ok := m.subscriptionProviderMap[subscriptionID]
 	m.mu.RUnlock()
@@ -446,10 +704,13 @@ func (m *MultiProviderSelector) CancelSubscription(ctx context.Context, subscrip
 		}
 	}
 
-	return provider.CancelSubscription(ctx, subscriptionID, req)
+	return tagProvider(ctx, provider).CancelSubscription(ctx, subscriptionID, req)
 }
 
-func (m *MultiProviderSelector) GetSubscription(ctx context.Context, subscriptionID string) (*models.Subscription, error) {
+func (m *MultiProviderSelector) GetSubscription(ctx context.Context, subscriptionID string) (_ *models.Subscription, err error) {
+	ctx, span := startProviderSpan(ctx, "get_subscription", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	m.mu.RLock()
 	provider, ok := m.subscriptionProviderMap[subscriptionID]
 	m.mu.RUnlock()
@@ -462,15 +723,18 @@ func (m *MultiProviderSelector) GetSubscription(ctx context.Context, subscriptio
 		}
 	}
 
-	return provider.GetSubscription(ctx, subscriptionID)
+	return tagProvider(ctx, provider).GetSubscription(ctx, subscriptionID)
 }
 
-func (m *MultiProviderSelector) ListSubscriptions(ctx context.Context, customerID string) ([]*models.Subscription, error) {
+func (m *MultiProviderSelector) ListSubscriptions(ctx context.Context, customerID string) (_ []*models.Subscription, err error) {
+	ctx, span := startProviderSpan(ctx, "list_subscriptions", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	var allSubscriptions []*models.Subscription
 
 	for _, provider := range m.Providers {
 		if provider.IsAvailable(ctx) {
-			subscriptions, err := provider.ListSubscriptions(ctx, customerID)
+			subscriptions, err := tagProvider(ctx, provider).ListSubscriptions(ctx, customerID)
 			if err == nil {
 				allSubscriptions = append(allSubscriptions, subscriptions...)
 			}
@@ -484,65 +748,102 @@ func (m *MultiProviderSelector) ListSubscriptions(ctx context.Context, customerI
 	return allSubscriptions, nil
 }
 
-func (m *MultiProviderSelector) CreatePlan(ctx context.Context, plan *models.Plan) (*models.Plan, error) {
+func (m *MultiProviderSelector) CreatePlan(ctx context.Context, plan *models.Plan) (_ *models.Plan, err error) {
+	ctx, span := startProviderSpan(ctx, "create_plan", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.selectAvailableProvider(ctx, "stripe")
 	if err != nil {
 		return nil, err
 	}
-	return provider.CreatePlan(ctx, plan)
+
+	created, err := tagProvider(ctx, provider).CreatePlan(ctx, plan)
+	if err == nil && created != nil && created.ID != "" {
+		created.ProviderName = provider.Name()
+		_ = m.saveProviderMapping(ctx, created.ID, "plan", provider.Name(), created.ID)
+	}
+	return created, err
 }
 
-func (m *MultiProviderSelector) UpdatePlan(ctx context.Context, planID string, plan *models.Plan) (*models.Plan, error) {
-	provider, err := m.selectAvailableProvider(ctx, "stripe")
+// resolvePlan returns the provider a plan was created on. Plans created
+// before owners were recorded have no mapping and stay on Stripe.
+func (m *MultiProviderSelector) resolvePlan(ctx context.Context, planID string) (PaymentProvider, error) {
+	provider, err := m.getProviderFromDB(ctx, planID, "plan")
+	if err != nil {
+		return m.selectAvailableProvider(ctx, "stripe")
+	}
+	return provider, nil
+}
+
+func (m *MultiProviderSelector) UpdatePlan(ctx context.Context, planID string, plan *models.Plan) (_ *models.Plan, err error) {
+	ctx, span := startProviderSpan(ctx, "update_plan", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	provider, err := m.resolvePlan(ctx, planID)
 	if err != nil {
 		return nil, err
 	}
-	return provider.UpdatePlan(ctx, planID, plan)
+	return tagProvider(ctx, provider).UpdatePlan(ctx, planID, plan)
 }
 
-func (m *MultiProviderSelector) DeletePlan(ctx context.Context, planID string) error {
-	provider, err := m.selectAvailableProvider(ctx, "stripe")
+func (m *MultiProviderSelector) DeletePlan(ctx context.Context, planID string) (err error) {
+	ctx, span := startProviderSpan(ctx, "delete_plan", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	provider, err := m.resolvePlan(ctx, planID)
 	if err != nil {
 		return err
 	}
-	return provider.DeletePlan(ctx, planID)
+	return tagProvider(ctx, provider).DeletePlan(ctx, planID)
 }
 
-func (m *MultiProviderSelector) GetPlan(ctx context.Context, planID string) (*models.Plan, error) {
-	provider, err := m.selectAvailableProvider(ctx, "stripe")
+func (m *MultiProviderSelector) GetPlan(ctx context.Context, planID string) (_ *models.Plan, err error) {
+	ctx, span := startProviderSpan(ctx, "get_plan", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	provider, err := m.resolvePlan(ctx, planID)
 	if err != nil {
 		return nil, err
 	}
-	return provider.GetPlan(ctx, planID)
+	return tagProvider(ctx, provider).GetPlan(ctx, planID)
 }
 
-func (m *MultiProviderSelector) ListPlans(ctx context.Context) ([]*models.Plan, error) {
+func (m *MultiProviderSelector) ListPlans(ctx context.Context) (_ []*models.Plan, err error) {
+	ctx, span := startProviderSpan(ctx, "list_plans", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.selectAvailableProvider(ctx, "stripe")
 	if err != nil {
 		return nil, err
 	}
-	return provider.ListPlans(ctx)
+	return tagProvider(ctx, provider).ListPlans(ctx)
 }
 
-func (m *MultiProviderSelector) CreateDispute(ctx context.Context, req *models.CreateDisputeRequest) (*models.Dispute, error) {
+func (m *MultiProviderSelector) CreateDispute(ctx context.Context, req *models.CreateDisputeRequest) (_ *models.Dispute, err error) {
+	ctx, span := startProviderSpan(ctx, "create_dispute", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.selectAvailableProvider(ctx, "stripe")
 	if err != nil {
 		return nil, err
 	}
 
-	dispute, err := provider.CreateDispute(ctx, req)
+	dispute, err := tagProvider(ctx, provider).CreateDispute(ctx, req)
 	if err == nil && dispute != nil && dispute.ID != "" {
 		m.mu.Lock()
 		m.disputeProviderMap[dispute.ID] = provider
 		m.mu.Unlock()
 
-		providerName := m.getProviderName(provider)
+		providerName := provider.Name()
 		_ = m.saveProviderMapping(ctx, dispute.ID, "dispute", providerName, dispute.ID)
 	}
 	return dispute, err
 }
 
-func (m *MultiProviderSelector) UpdateDispute(ctx context.Context, disputeID string, req *models.UpdateDisputeRequest) (*models.Dispute, error) {
+func (m *MultiProviderSelector) UpdateDispute(ctx context.Context, disputeID string, req *models.UpdateDisputeRequest) (_ *models.Dispute, err error) {
+	ctx, span := startProviderSpan(ctx, "update_dispute", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	m.mu.RLock()
 	provider, ok := m.disputeProviderMap[disputeID]
 	m.mu.RUnlock()
@@ -555,10 +856,13 @@ func (m *MultiProviderSelector) UpdateDispute(ctx context.Context, disputeID str
 		}
 	}
 
-	return provider.UpdateDispute(ctx, disputeID, req)
+	return tagProvider(ctx, provider).UpdateDispute(ctx, disputeID, req)
 }
 
-func (m *MultiProviderSelector) AcceptDispute(ctx context.Context, disputeID string) (*models.Dispute, error) {
+func (m *MultiProviderSelector) AcceptDispute(ctx context.Context, disputeID string) (_ *models.Dispute, err error) {
+	ctx, span := startProviderSpan(ctx, "accept_dispute", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	m.mu.RLock()
 	provider, ok := m.disputeProviderMap[disputeID]
 	m.mu.RUnlock()
@@ -571,10 +875,13 @@ func (m *MultiProviderSelector) AcceptDispute(ctx context.Context, disputeID str
 		}
 	}
 
-	return provider.AcceptDispute(ctx, disputeID)
+	return tagProvider(ctx, provider).AcceptDispute(ctx, disputeID)
 }
 
-func (m *MultiProviderSelector) ContestDispute(ctx context.Context, disputeID string, evidence map[string]interface{}) (*models.Dispute, error) {
+func (m *MultiProviderSelector) ContestDispute(ctx context.Context, disputeID string, evidence map[string]interface{}) (_ *models.Dispute, err error) {
+	ctx, span := startProviderSpan(ctx, "contest_dispute", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	m.mu.RLock()
 	provider, ok := m.disputeProviderMap[disputeID]
 	m.mu.RUnlock()
@@ -587,10 +894,13 @@ func (m *MultiProviderSelector) ContestDispute(ctx context.Context, disputeID st
 		}
 	}
 
-	return provider.ContestDispute(ctx, disputeID, evidence)
+	return tagProvider(ctx, provider).ContestDispute(ctx, disputeID, evidence)
 }
 
-func (m *MultiProviderSelector) SubmitDisputeEvidence(ctx context.Context, disputeID string, req *models.SubmitEvidenceRequest) (*models.Evidence, error) {
+func (m *MultiProviderSelector) SubmitDisputeEvidence(ctx context.Context, disputeID string, req *models.SubmitEvidenceRequest) (_ *models.Evidence, err error) {
+	ctx, span := startProviderSpan(ctx, "submit_dispute_evidence", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	m.mu.RLock()
 	provider, ok := m.disputeProviderMap[disputeID]
 	m.mu.RUnlock()
@@ -603,10 +913,13 @@ func (m *MultiProviderSelector) SubmitDisputeEvidence(ctx context.Context, dispu
 		}
 	}
 
-	return provider.SubmitDisputeEvidence(ctx, disputeID, req)
+	return tagProvider(ctx, provider).SubmitDisputeEvidence(ctx, disputeID, req)
 }
 
-func (m *MultiProviderSelector) GetDispute(ctx context.Context, disputeID string) (*models.Dispute, error) {
+func (m *MultiProviderSelector) GetDispute(ctx context.Context, disputeID string) (_ *models.Dispute, err error) {
+	ctx, span := startProviderSpan(ctx, "get_dispute", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	m.mu.RLock()
 	provider, ok := m.disputeProviderMap[disputeID]
 	m.mu.RUnlock()
@@ -619,15 +932,18 @@ func (m *MultiProviderSelector) GetDispute(ctx context.Context, disputeID string
 		}
 	}
 
-	return provider.GetDispute(ctx, disputeID)
+	return tagProvider(ctx, provider).GetDispute(ctx, disputeID)
 }
 
-func (m *MultiProviderSelector) ListDisputes(ctx context.Context, customerID string) ([]*models.Dispute, error) {
+func (m *MultiProviderSelector) ListDisputes(ctx context.Context, customerID string) (_ []*models.Dispute, err error) {
+	ctx, span := startProviderSpan(ctx, "list_disputes", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	var allDisputes []*models.Dispute
 
 	for _, provider := range m.Providers {
 		if provider.IsAvailable(ctx) {
-			disputes, err := provider.ListDisputes(ctx, customerID)
+			disputes, err := tagProvider(ctx, provider).ListDisputes(ctx, customerID)
 			if err == nil {
 				allDisputes = append(allDisputes, disputes...)
 			}
@@ -641,47 +957,115 @@ func (m *MultiProviderSelector) ListDisputes(ctx context.Context, customerID str
 	return allDisputes, nil
 }
 
-func (m *MultiProviderSelector) GetDisputeStats(ctx context.Context) (*models.DisputeStats, error) {
+func (m *MultiProviderSelector) GetDisputeStats(ctx context.Context) (_ *models.DisputeStats, err error) {
+	ctx, span := startProviderSpan(ctx, "get_dispute_stats", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.selectAvailableProvider(ctx, "stripe")
 	if err != nil {
 		return nil, err
 	}
-	return provider.GetDisputeStats(ctx)
+	return tagProvider(ctx, provider).GetDisputeStats(ctx)
 }
 
-func (m *MultiProviderSelector) CreateCustomer(ctx context.Context, req *models.CreateCustomerRequest) (string, error) {
-	provider, err := m.selectAvailableProvider(ctx, "stripe")
+// selectCustomerProvider picks where a new customer is created: the provider
+// named on the request, else the usual provider for its currency, else
+// Stripe.
+func (m *MultiProviderSelector) selectCustomerProvider(ctx context.Context, req *models.CreateCustomerRequest) (PaymentProvider, error) {
+	if req.Provider != "" {
+		name := strings.ToLower(req.Provider)
+		provider, ok := m.providerByName[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrProviderNotConfigured, name)
+		}
+		if !m.availability.IsAvailable(ctx, name, provider) {
+			return nil, fmt.Errorf("%w: %s", ErrProviderUnavailable, name)
+		}
+		return provider, nil
+	}
+	if req.Currency != "" {
+		return m.selectProviderByCurrency(ctx, strings.ToUpper(req.Currency))
+	}
+	return m.selectAvailableProvider(ctx, "stripe")
+}
+
+// resolveCustomer returns the provider that owns a customer. Customers
+// created before owners were recorded have no mapping and stay on Stripe.
+func (m *MultiProviderSelector) resolveCustomer(ctx context.Context, customerID string) (PaymentProvider, error) {
+	m.mu.RLock()
+	provider, ok := m.customerProviderMap[customerID]
+	m.mu.RUnlock()
+	if ok {
+		return provider, nil
+	}
+
+	provider, err := m.getProviderFromDB(ctx, customerID, "customer")
+	if err != nil {
+		return m.selectAvailableProvider(ctx, "stripe")
+	}
+	m.mu.Lock()
+	m.customerProviderMap[customerID] = provider
+	m.mu.Unlock()
+	return provider, nil
+}
+
+func (m *MultiProviderSelector) CreateCustomer(ctx context.Context, req *models.CreateCustomerRequest) (_ string, err error) {
+	ctx, span := startProviderSpan(ctx, "create_customer", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	provider, err := m.selectCustomerProvider(ctx, req)
 	if err != nil {
 		return "", err
 	}
-	return provider.CreateCustomer(ctx, req)
+
+	customerID, err := tagProvider(ctx, provider).CreateCustomer(ctx, req)
+	if err == nil && customerID != "" {
+		m.mu.Lock()
+		m.customerProviderMap[customerID] = provider
+		m.mu.Unlock()
+
+		_ = m.saveProviderMapping(ctx, customerID, "customer", provider.Name(), customerID)
+	}
+	return customerID, err
 }
 
-func (m *MultiProviderSelector) UpdateCustomer(ctx context.Context, customerID string, req *models.UpdateCustomerRequest) error {
-	provider, err := m.selectAvailableProvider(ctx, "stripe")
+func (m *MultiProviderSelector) UpdateCustomer(ctx context.Context, customerID string, req *models.UpdateCustomerRequest) (err error) {
+	ctx, span := startProviderSpan(ctx, "update_customer", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	provider, err := m.resolveCustomer(ctx, customerID)
 	if err != nil {
 		return err
 	}
-	return provider.UpdateCustomer(ctx, customerID, req)
+	return tagProvider(ctx, provider).UpdateCustomer(ctx, customerID, req)
 }
 
-func (m *MultiProviderSelector) GetCustomer(ctx context.Context, customerID string) (*models.Customer, error) {
-	provider, err := m.selectAvailableProvider(ctx, "stripe")
+func (m *MultiProviderSelector) GetCustomer(ctx context.Context, customerID string) (_ *models.Customer, err error) {
+	ctx, span := startProviderSpan(ctx, "get_customer", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	provider, err := m.resolveCustomer(ctx, customerID)
 	if err != nil {
 		return nil, err
 	}
-	return provider.GetCustomer(ctx, customerID)
+	return tagProvider(ctx, provider).GetCustomer(ctx, customerID)
 }
 
-func (m *MultiProviderSelector) DeleteCustomer(ctx context.Context, customerID string) error {
-	provider, err := m.selectAvailableProvider(ctx, "stripe")
+func (m *MultiProviderSelector) DeleteCustomer(ctx context.Context, customerID string) (err error) {
+	ctx, span := startProviderSpan(ctx, "delete_customer", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	provider, err := m.resolveCustomer(ctx, customerID)
 	if err != nil {
 		return err
 	}
-	return provider.DeleteCustomer(ctx, customerID)
+	return tagProvider(ctx, provider).DeleteCustomer(ctx, customerID)
 }
 
-func (m *MultiProviderSelector) CreatePaymentMethod(ctx context.Context, req *models.CreatePaymentMethodRequest) (*models.PaymentMethod, error) {
+func (m *MultiProviderSelector) CreatePaymentMethod(ctx context.Context, req *models.CreatePaymentMethodRequest) (_ *models.PaymentMethod, err error) {
+	ctx, span := startProviderSpan(ctx, "create_payment_method", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	providerName := req.Provider
 	if providerName == "" {
 		providerName = "stripe"
@@ -693,16 +1077,19 @@ func (m *MultiProviderSelector) CreatePaymentMethod(ctx context.Context, req *mo
 	}
 
 	if pmProvider, ok := provider.(PaymentMethodProvider); ok {
-		return pmProvider.CreatePaymentMethod(ctx, req)
+		return tagProvider(ctx, pmProvider).CreatePaymentMethod(ctx, req)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) GetPaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethod, error) {
+func (m *MultiProviderSelector) GetPaymentMethod(ctx context.Context, paymentMethodID string) (_ *models.PaymentMethod, err error) {
+	ctx, span := startProviderSpan(ctx, "get_payment_method", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	for _, provider := range m.Providers {
 		if provider.IsAvailable(ctx) {
 			if pmProvider, ok := provider.(PaymentMethodProvider); ok {
-				pm, err := pmProvider.GetPaymentMethod(ctx, paymentMethodID)
+				pm, err := tagProvider(ctx, pmProvider).GetPaymentMethod(ctx, paymentMethodID)
 				if err == nil {
 					return pm, nil
 				}
@@ -712,12 +1099,29 @@ func (m *MultiProviderSelector) GetPaymentMethod(ctx context.Context, paymentMet
 	return nil, fmt.Errorf("payment method not found")
 }
 
-func (m *MultiProviderSelector) ListPaymentMethods(ctx context.Context, customerID string, pmType *models.PaymentMethodType) ([]*models.PaymentMethod, error) {
+func (m *MultiProviderSelector) VerifyPaymentMethod(ctx context.Context, paymentMethodID string, amounts []int64) (_ *models.PaymentMethod, err error) {
+	ctx, span := startProviderSpan(ctx, "verify_payment_method", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	for _, provider := range m.Providers {
+		if provider.IsAvailable(ctx) {
+			if verifier, ok := provider.(PaymentMethodVerifier); ok {
+				return tagProvider(ctx, verifier).VerifyPaymentMethod(ctx, paymentMethodID, amounts)
+			}
+		}
+	}
+	return nil, ErrNotSupported
+}
+
+func (m *MultiProviderSelector) ListPaymentMethods(ctx context.Context, customerID string, pmType *models.PaymentMethodType) (_ []*models.PaymentMethod, err error) {
+	ctx, span := startProviderSpan(ctx, "list_payment_methods", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	var allMethods []*models.PaymentMethod
 	for _, provider := range m.Providers {
 		if provider.IsAvailable(ctx) {
 			if pmProvider, ok := provider.(PaymentMethodProvider); ok {
-				methods, err := pmProvider.ListPaymentMethods(ctx, customerID, pmType)
+				methods, err := tagProvider(ctx, pmProvider).ListPaymentMethods(ctx, customerID, pmType)
 				if err == nil {
 					allMethods = append(allMethods, methods...)
 				}
@@ -727,11 +1131,14 @@ func (m *MultiProviderSelector) ListPaymentMethods(ctx context.Context, customer
 	return allMethods, nil
 }
 
-func (m *MultiProviderSelector) AttachPaymentMethod(ctx context.Context, paymentMethodID, customerID string) error {
+func (m *MultiProviderSelector) AttachPaymentMethod(ctx context.Context, paymentMethodID, customerID string) (err error) {
+	ctx, span := startProviderSpan(ctx, "attach_payment_method", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	for _, provider := range m.Providers {
 		if provider.IsAvailable(ctx) {
 			if pmProvider, ok := provider.(PaymentMethodProvider); ok {
-				err := pmProvider.AttachPaymentMethod(ctx, paymentMethodID, customerID)
+				err := tagProvider(ctx, pmProvider).AttachPaymentMethod(ctx, paymentMethodID, customerID)
 				if err == nil {
 					return nil
 				}
@@ -741,11 +1148,14 @@ func (m *MultiProviderSelector) AttachPaymentMethod(ctx context.Context, payment
 	return ErrNotSupported
 }
 
-func (m *MultiProviderSelector) DetachPaymentMethod(ctx context.Context, paymentMethodID string) error {
+func (m *MultiProviderSelector) DetachPaymentMethod(ctx context.Context, paymentMethodID string) (err error) {
+	ctx, span := startProviderSpan(ctx, "detach_payment_method", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	for _, provider := range m.Providers {
 		if provider.IsAvailable(ctx) {
 			if pmProvider, ok := provider.(PaymentMethodProvider); ok {
-				err := pmProvider.DetachPaymentMethod(ctx, paymentMethodID)
+				err := tagProvider(ctx, pmProvider).DetachPaymentMethod(ctx, paymentMethodID)
 				if err == nil {
 					return nil
 				}
@@ -764,7 +1174,10 @@ func (m *MultiProviderSelector) IsAvailable(ctx context.Context) bool {
 	return false
 }
 
-func (m *MultiProviderSelector) CapturePayment(ctx context.Context, paymentID string, amount int64) error {
+func (m *MultiProviderSelector) CapturePayment(ctx context.Context, paymentID string, amount int64) (err error) {
+	ctx, span := startProviderSpan(ctx, "capture_payment", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	m.mu.RLock()
 	provider, ok := m.paymentProviderMap[paymentID]
 	m.mu.RUnlock()
@@ -778,12 +1191,78 @@ func (m *MultiProviderSelector) CapturePayment(ctx context.Context, paymentID st
 	}
 
 	if capturer, ok := provider.(CaptureProvider); ok {
-		return capturer.CapturePayment(ctx, paymentID, amount)
+		return tagProvider(ctx, capturer).CapturePayment(ctx, paymentID, amount)
 	}
 	return ErrNotSupported
 }
 
-func (m *MultiProviderSelector) VoidPayment(ctx context.Context, paymentID string) error {
+func (m *MultiProviderSelector) CapturePartial(ctx context.Context, paymentID string, amount int64, final bool) (err error) {
+	ctx, span := startProviderSpan(ctx, "capture_partial", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	m.mu.RLock()
+	provider, ok := m.paymentProviderMap[paymentID]
+	m.mu.RUnlock()
+
+	if !ok {
+		var err error
+		provider, err = m.getProviderFromDB(ctx, paymentID, "payment")
+		if err != nil {
+			return err
+		}
+	}
+
+	if capturer, ok := provider.(MultiCaptureProvider); ok {
+		return tagProvider(ctx, capturer).CapturePartial(ctx, paymentID, amount, final)
+	}
+	return ErrNotSupported
+}
+
+func (m *MultiProviderSelector) ReverseAuthorization(ctx context.Context, paymentID string, amount int64) (err error) {
+	ctx, span := startProviderSpan(ctx, "reverse_authorization", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	m.mu.RLock()
+	provider, ok := m.paymentProviderMap[paymentID]
+	m.mu.RUnlock()
+
+	if !ok {
+		var err error
+		provider, err = m.getProviderFromDB(ctx, paymentID, "payment")
+		if err != nil {
+			return err
+		}
+	}
+
+	if reverser, ok := provider.(AuthorizationReversalProvider); ok {
+		return tagProvider(ctx, reverser).ReverseAuthorization(ctx, paymentID, amount)
+	}
+	return ErrNotSupported
+}
+
+func (m *MultiProviderSelector) GetCharge(ctx context.Context, providerChargeID string) (_ *models.ChargeResponse, err error) {
+	ctx, span := startProviderSpan(ctx, "get_charge", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	m.mu.RLock()
+	provider, ok := m.paymentProviderMap[providerChargeID]
+	m.mu.RUnlock()
+
+	if !ok {
+		var err error
+		provider, err = m.getProviderFromDB(ctx, providerChargeID, "payment")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tagProvider(ctx, provider).GetCharge(ctx, providerChargeID)
+}
+
+func (m *MultiProviderSelector) VoidPayment(ctx context.Context, paymentID string) (err error) {
+	ctx, span := startProviderSpan(ctx, "void_payment", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	m.mu.RLock()
 	provider, ok := m.paymentProviderMap[paymentID]
 	m.mu.RUnlock()
@@ -797,21 +1276,24 @@ func (m *MultiProviderSelector) VoidPayment(ctx context.Context, paymentID strin
 	}
 
 	if voider, ok := provider.(VoidProvider); ok {
-		return voider.VoidPayment(ctx, paymentID)
+		return tagProvider(ctx, voider).VoidPayment(ctx, paymentID)
 	}
 	return ErrNotSupported
 }
 
-func (m *MultiProviderSelector) CreateInvoice(ctx context.Context, req *models.CreateInvoiceRequest) (*models.Invoice, error) {
+func (m *MultiProviderSelector) CreateInvoice(ctx context.Context, req *models.CreateInvoiceRequest) (_ *models.Invoice, err error) {
+	ctx, span := startProviderSpan(ctx, "create_invoice", req.Currency)
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.selectProviderByCurrency(ctx, req.Currency)
 	if err != nil {
 		return nil, err
 	}
 
 	if invProvider, ok := provider.(InvoiceProvider); ok {
-		inv, err := invProvider.CreateInvoice(ctx, req)
+		inv, err := tagProvider(ctx, invProvider).CreateInvoice(ctx, req)
 		if err == nil && inv != nil {
-			providerName := m.getProviderName(provider)
+			providerName := provider.Name()
 			_ = m.saveProviderMapping(ctx, inv.ProviderID, "invoice", providerName, inv.ProviderID)
 		}
 		return inv, err
@@ -819,13 +1301,16 @@ func (m *MultiProviderSelector) CreateInvoice(ctx context.Context, req *models.C
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) GetInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error) {
+func (m *MultiProviderSelector) GetInvoice(ctx context.Context, invoiceID string) (_ *models.Invoice, err error) {
+	ctx, span := startProviderSpan(ctx, "get_invoice", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.getProviderFromDB(ctx, invoiceID, "invoice")
 	if err != nil {
 		for _, p := range m.Providers {
 			if p.IsAvailable(ctx) {
 				if invProvider, ok := p.(InvoiceProvider); ok {
-					inv, err := invProvider.GetInvoice(ctx, invoiceID)
+					inv, err := tagProvider(ctx, invProvider).GetInvoice(ctx, invoiceID)
 					if err == nil {
 						return inv, nil
 					}
@@ -836,17 +1321,20 @@ func (m *MultiProviderSelector) GetInvoice(ctx context.Context, invoiceID string
 	}
 
 	if invProvider, ok := provider.(InvoiceProvider); ok {
-		return invProvider.GetInvoice(ctx, invoiceID)
+		return tagProvider(ctx, invProvider).GetInvoice(ctx, invoiceID)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) ListInvoices(ctx context.Context, req *models.ListInvoicesRequest) ([]*models.Invoice, error) {
+func (m *MultiProviderSelector) ListInvoices(ctx context.Context, req *models.ListInvoicesRequest) (_ []*models.Invoice, err error) {
+	ctx, span := startProviderSpan(ctx, "list_invoices", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	var allInvoices []*models.Invoice
 	for _, provider := range m.Providers {
 		if provider.IsAvailable(ctx) {
 			if invProvider, ok := provider.(InvoiceProvider); ok {
-				invoices, err := invProvider.ListInvoices(ctx, req)
+				invoices, err := tagProvider(ctx, invProvider).ListInvoices(ctx, req)
 				if err == nil {
 					allInvoices = append(allInvoices, invoices...)
 				}
@@ -856,13 +1344,16 @@ func (m *MultiProviderSelector) ListInvoices(ctx context.Context, req *models.Li
 	return allInvoices, nil
 }
 
-func (m *MultiProviderSelector) CancelInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error) {
+func (m *MultiProviderSelector) CancelInvoice(ctx context.Context, invoiceID string) (_ *models.Invoice, err error) {
+	ctx, span := startProviderSpan(ctx, "cancel_invoice", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.getProviderFromDB(ctx, invoiceID, "invoice")
 	if err != nil {
 		for _, p := range m.Providers {
 			if p.IsAvailable(ctx) {
 				if invProvider, ok := p.(InvoiceProvider); ok {
-					inv, err := invProvider.CancelInvoice(ctx, invoiceID)
+					inv, err := tagProvider(ctx, invProvider).CancelInvoice(ctx, invoiceID)
 					if err == nil {
 						return inv, nil
 					}
@@ -873,21 +1364,24 @@ func (m *MultiProviderSelector) CancelInvoice(ctx context.Context, invoiceID str
 	}
 
 	if invProvider, ok := provider.(InvoiceProvider); ok {
-		return invProvider.CancelInvoice(ctx, invoiceID)
+		return tagProvider(ctx, invProvider).CancelInvoice(ctx, invoiceID)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) CreatePayout(ctx context.Context, req *models.CreatePayoutRequest) (*models.Payout, error) {
+func (m *MultiProviderSelector) CreatePayout(ctx context.Context, req *models.CreatePayoutRequest) (_ *models.Payout, err error) {
+	ctx, span := startProviderSpan(ctx, "create_payout", req.Currency)
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.selectProviderByCurrency(ctx, req.Currency)
 	if err != nil {
 		return nil, err
 	}
 
 	if payoutProvider, ok := provider.(PayoutProvider); ok {
-		payout, err := payoutProvider.CreatePayout(ctx, req)
+		payout, err := tagProvider(ctx, payoutProvider).CreatePayout(ctx, req)
 		if err == nil && payout != nil {
-			providerName := m.getProviderName(provider)
+			providerName := provider.Name()
 			_ = m.saveProviderMapping(ctx, payout.ProviderID, "payout", providerName, payout.ProviderID)
 		}
 		return payout, err
@@ -895,13 +1389,16 @@ func (m *MultiProviderSelector) CreatePayout(ctx context.Context, req *models.Cr
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) GetPayout(ctx context.Context, payoutID string) (*models.Payout, error) {
+func (m *MultiProviderSelector) GetPayout(ctx context.Context, payoutID string) (_ *models.Payout, err error) {
+	ctx, span := startProviderSpan(ctx, "get_payout", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.getProviderFromDB(ctx, payoutID, "payout")
 	if err != nil {
 		for _, p := range m.Providers {
 			if p.IsAvailable(ctx) {
 				if payoutProvider, ok := p.(PayoutProvider); ok {
-					payout, err := payoutProvider.GetPayout(ctx, payoutID)
+					payout, err := tagProvider(ctx, payoutProvider).GetPayout(ctx, payoutID)
 					if err == nil {
 						return payout, nil
 					}
@@ -912,17 +1409,20 @@ func (m *MultiProviderSelector) GetPayout(ctx context.Context, payoutID string)
 	}
 
 	if payoutProvider, ok := provider.(PayoutProvider); ok {
-		return payoutProvider.GetPayout(ctx, payoutID)
+		return tagProvider(ctx, payoutProvider).GetPayout(ctx, payoutID)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) ListPayouts(ctx context.Context, req *models.ListPayoutsRequest) ([]*models.Payout, error) {
+func (m *MultiProviderSelector) ListPayouts(ctx context.Context, req *models.ListPayoutsRequest) (_ []*models.Payout, err error) {
+	ctx, span := startProviderSpan(ctx, "list_payouts", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	var allPayouts []*models.Payout
 	for _, provider := range m.Providers {
 		if provider.IsAvailable(ctx) {
 			if payoutProvider, ok := provider.(PayoutProvider); ok {
-				payouts, err := payoutProvider.ListPayouts(ctx, req)
+				payouts, err := tagProvider(ctx, payoutProvider).ListPayouts(ctx, req)
 				if err == nil {
 					allPayouts = append(allPayouts, payouts...)
 				}
@@ -932,13 +1432,16 @@ func (m *MultiProviderSelector) ListPayouts(ctx context.Context, req *models.Lis
 	return allPayouts, nil
 }
 
-func (m *MultiProviderSelector) CancelPayout(ctx context.Context, payoutID string) (*models.Payout, error) {
+func (m *MultiProviderSelector) CancelPayout(ctx context.Context, payoutID string) (_ *models.Payout, err error) {
+	ctx, span := startProviderSpan(ctx, "cancel_payout", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.getProviderFromDB(ctx, payoutID, "payout")
 	if err != nil {
 		for _, p := range m.Providers {
 			if p.IsAvailable(ctx) {
 				if payoutProvider, ok := p.(PayoutProvider); ok {
-					payout, err := payoutProvider.CancelPayout(ctx, payoutID)
+					payout, err := tagProvider(ctx, payoutProvider).CancelPayout(ctx, payoutID)
 					if err == nil {
 						return payout, nil
 					}
@@ -949,45 +1452,120 @@ func (m *MultiProviderSelector) CancelPayout(ctx context.Context, payoutID strin
 	}
 
 	if payoutProvider, ok := provider.(PayoutProvider); ok {
-		return payoutProvider.CancelPayout(ctx, payoutID)
+		return tagProvider(ctx, payoutProvider).CancelPayout(ctx, payoutID)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) GetPayoutChannels(ctx context.Context, currency string) ([]*models.PayoutChannel, error) {
+func (m *MultiProviderSelector) GetPayoutChannels(ctx context.Context, currency string) (_ []*models.PayoutChannel, err error) {
+	ctx, span := startProviderSpan(ctx, "get_payout_channels", currency)
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.selectProviderByCurrency(ctx, currency)
 	if err != nil {
 		return nil, err
 	}
 
 	if payoutProvider, ok := provider.(PayoutProvider); ok {
-		return payoutProvider.GetPayoutChannels(ctx, currency)
+		return tagProvider(ctx, payoutProvider).GetPayoutChannels(ctx, currency)
+	}
+	return nil, ErrNotSupported
+}
+
+// GetFXQuote asks the provider that would carry a payout in to for the
+// conversion, so the quote can be honored by the payout that follows.
+func (m *MultiProviderSelector) GetFXQuote(ctx context.Context, from, to string, amount int64) (_ *models.FXQuote, err error) {
+	ctx, span := startProviderSpan(ctx, "get_fx_quote", to)
+	defer func() { endProviderSpan(span, err) }()
+
+	provider, err := m.selectProviderByCurrency(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if fxProvider, ok := provider.(FXProvider); ok {
+		return tagProvider(ctx, fxProvider).GetFXQuote(ctx, from, to, amount)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) GetBalance(ctx context.Context, currency string) (*models.Balance, error) {
+func (m *MultiProviderSelector) GetBalance(ctx context.Context, currency string) (_ *models.Balance, err error) {
+	ctx, span := startProviderSpan(ctx, "get_balance", currency)
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.selectProviderByCurrency(ctx, currency)
 	if err != nil {
 		return nil, err
 	}
 
 	if balanceProvider, ok := provider.(BalanceProvider); ok {
-		return balanceProvider.GetBalance(ctx, currency)
+		return tagProvider(ctx, balanceProvider).GetBalance(ctx, currency)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) CreatePaymentSession(ctx context.Context, req *models.CreatePaymentSessionRequest) (*models.PaymentSession, error) {
+// CalculateTax asks the provider that serves the currency for the
+// calculation.
+func (m *MultiProviderSelector) CalculateTax(ctx context.Context, req *models.CalculateTaxRequest) (_ *models.TaxCalculation, err error) {
+	ctx, span := startProviderSpan(ctx, "calculate_tax", req.Currency)
+	defer func() { endProviderSpan(span, err) }()
+
+	provider, err := m.selectProviderByCurrency(ctx, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if taxProvider, ok := provider.(TaxProvider); ok {
+		return tagProvider(ctx, taxProvider).CalculateTax(ctx, req)
+	}
+	return nil, ErrNotSupported
+}
+
+func (m *MultiProviderSelector) RecordUsage(ctx context.Context, usage *models.UsageEvent) (_ *models.UsageRecord, err error) {
+	ctx, span := startProviderSpan(ctx, "record_usage", "")
+	defer func() { endProviderSpan(span, err) }()
+
+	m.mu.RLock()
+	provider, ok := m.subscriptionProviderMap[usage.SubscriptionID]
+	m.mu.RUnlock()
+
+	if !ok {
+		var err error
+		provider, err = m.getProviderFromDB(ctx, usage.SubscriptionID, "subscription")
+		if err != nil {
+			if usage.ProviderName == "" {
+				return nil, err
+			}
+			provider, err = m.selectAvailableProvider(ctx, usage.ProviderName)
+			if err != nil {
+				return nil, err
+			}
+			if provider.Name() != usage.ProviderName {
+				return nil, fmt.Errorf("provider %s not available", usage.ProviderName)
+			}
+		}
+	}
+
+	meteredProvider, ok := provider.(MeteredBillingProvider)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return tagProvider(ctx, meteredProvider).RecordUsage(ctx, usage)
+}
+
+func (m *MultiProviderSelector) CreatePaymentSession(ctx context.Context, req *models.CreatePaymentSessionRequest) (_ *models.PaymentSession, err error) {
+	ctx, span := startProviderSpan(ctx, "create_payment_session", req.Currency)
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.selectProviderByCurrency(ctx, req.Currency)
 	if err != nil {
 		return nil, err
 	}
 
 	if sessionProvider, ok := provider.(PaymentSessionProvider); ok {
-		session, err := sessionProvider.CreatePaymentSession(ctx, req)
+		session, err := tagProvider(ctx, sessionProvider).CreatePaymentSession(ctx, req)
 		if err == nil && session != nil {
-			providerName := m.getProviderName(provider)
+			providerName := provider.Name()
 			_ = m.saveProviderMapping(ctx, session.ProviderID, "payment_session", providerName, session.ProviderID)
 		}
 		return session, err
@@ -995,13 +1573,41 @@ func (m *MultiProviderSelector) CreatePaymentSession(ctx context.Context, req *m
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) GetPaymentSession(ctx context.Context, sessionID string) (*models.PaymentSession, error) {
+func (m *MultiProviderSelector) CreateCheckoutSession(ctx context.Context, req *models.CreateCheckoutSessionRequest) (_ *models.CheckoutSession, err error) {
+	ctx, span := startProviderSpan(ctx, "create_checkout_session", req.Currency)
+	defer func() { endProviderSpan(span, err) }()
+
+	var provider PaymentProvider
+	if req.Provider != "" {
+		provider, err = m.selectPinnedProvider(ctx, req.Provider, req.Currency)
+	} else {
+		provider, err = m.selectProviderByCurrency(ctx, req.Currency)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	checkoutProvider, ok := provider.(HostedCheckoutProvider)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	session, err := tagProvider(ctx, checkoutProvider).CreateCheckoutSession(ctx, req)
+	if err == nil && session != nil {
+		_ = m.saveProviderMapping(ctx, session.ProviderSessionID, "checkout_session", provider.Name(), session.ProviderSessionID)
+	}
+	return session, err
+}
+
+func (m *MultiProviderSelector) GetPaymentSession(ctx context.Context, sessionID string) (_ *models.PaymentSession, err error) {
+	ctx, span := startProviderSpan(ctx, "get_payment_session", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.getProviderFromDB(ctx, sessionID, "payment_session")
 	if err != nil {
 		for _, p := range m.Providers {
 			if p.IsAvailable(ctx) {
 				if sessionProvider, ok := p.(PaymentSessionProvider); ok {
-					session, err := sessionProvider.GetPaymentSession(ctx, sessionID)
+					session, err := tagProvider(ctx, sessionProvider).GetPaymentSession(ctx, sessionID)
 					if err == nil {
 						return session, nil
 					}
@@ -1012,30 +1618,36 @@ func (m *MultiProviderSelector) GetPaymentSession(ctx context.Context, sessionID
 	}
 
 	if sessionProvider, ok := provider.(PaymentSessionProvider); ok {
-		return sessionProvider.GetPaymentSession(ctx, sessionID)
+		return tagProvider(ctx, sessionProvider).GetPaymentSession(ctx, sessionID)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) UpdatePaymentSession(ctx context.Context, sessionID string, req *models.UpdatePaymentSessionRequest) (*models.PaymentSession, error) {
+func (m *MultiProviderSelector) UpdatePaymentSession(ctx context.Context, sessionID string, req *models.UpdatePaymentSessionRequest) (_ *models.PaymentSession, err error) {
+	ctx, span := startProviderSpan(ctx, "update_payment_session", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.getProviderFromDB(ctx, sessionID, "payment_session")
 	if err != nil {
 		return nil, err
 	}
 
 	if sessionProvider, ok := provider.(PaymentSessionProvider); ok {
-		return sessionProvider.UpdatePaymentSession(ctx, sessionID, req)
+		return tagProvider(ctx, sessionProvider).UpdatePaymentSession(ctx, sessionID, req)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) ConfirmPaymentSession(ctx context.Context, sessionID string, req *models.ConfirmPaymentSessionRequest) (*models.PaymentSession, error) {
+func (m *MultiProviderSelector) ConfirmPaymentSession(ctx context.Context, sessionID string, req *models.ConfirmPaymentSessionRequest) (_ *models.PaymentSession, err error) {
+	ctx, span := startProviderSpan(ctx, "confirm_payment_session", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.getProviderFromDB(ctx, sessionID, "payment_session")
 	if err != nil {
 		for _, p := range m.Providers {
 			if p.IsAvailable(ctx) {
 				if sessionProvider, ok := p.(PaymentSessionProvider); ok {
-					session, err := sessionProvider.ConfirmPaymentSession(ctx, sessionID, req)
+					session, err := tagProvider(ctx, sessionProvider).ConfirmPaymentSession(ctx, sessionID, req)
 					if err == nil {
 						return session, nil
 					}
@@ -1046,18 +1658,21 @@ func (m *MultiProviderSelector) ConfirmPaymentSession(ctx context.Context, sessi
 	}
 
 	if sessionProvider, ok := provider.(PaymentSessionProvider); ok {
-		return sessionProvider.ConfirmPaymentSession(ctx, sessionID, req)
+		return tagProvider(ctx, sessionProvider).ConfirmPaymentSession(ctx, sessionID, req)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) CapturePaymentSession(ctx context.Context, sessionID string, amount *int64) (*models.PaymentSession, error) {
+func (m *MultiProviderSelector) CapturePaymentSession(ctx context.Context, sessionID string, amount *int64) (_ *models.PaymentSession, err error) {
+	ctx, span := startProviderSpan(ctx, "capture_payment_session", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.getProviderFromDB(ctx, sessionID, "payment_session")
 	if err != nil {
 		for _, p := range m.Providers {
 			if p.IsAvailable(ctx) {
 				if sessionProvider, ok := p.(PaymentSessionProvider); ok {
-					session, err := sessionProvider.CapturePaymentSession(ctx, sessionID, amount)
+					session, err := tagProvider(ctx, sessionProvider).CapturePaymentSession(ctx, sessionID, amount)
 					if err == nil {
 						return session, nil
 					}
@@ -1068,18 +1683,21 @@ func (m *MultiProviderSelector) CapturePaymentSession(ctx context.Context, sessi
 	}
 
 	if sessionProvider, ok := provider.(PaymentSessionProvider); ok {
-		return sessionProvider.CapturePaymentSession(ctx, sessionID, amount)
+		return tagProvider(ctx, sessionProvider).CapturePaymentSession(ctx, sessionID, amount)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) CancelPaymentSession(ctx context.Context, sessionID string) (*models.PaymentSession, error) {
+func (m *MultiProviderSelector) CancelPaymentSession(ctx context.Context, sessionID string) (_ *models.PaymentSession, err error) {
+	ctx, span := startProviderSpan(ctx, "cancel_payment_session", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	provider, err := m.getProviderFromDB(ctx, sessionID, "payment_session")
 	if err != nil {
 		for _, p := range m.Providers {
 			if p.IsAvailable(ctx) {
 				if sessionProvider, ok := p.(PaymentSessionProvider); ok {
-					session, err := sessionProvider.CancelPaymentSession(ctx, sessionID)
+					session, err := tagProvider(ctx, sessionProvider).CancelPaymentSession(ctx, sessionID)
 					if err == nil {
 						return session, nil
 					}
@@ -1090,17 +1708,20 @@ func (m *MultiProviderSelector) CancelPaymentSession(ctx context.Context, sessio
 	}
 
 	if sessionProvider, ok := provider.(PaymentSessionProvider); ok {
-		return sessionProvider.CancelPaymentSession(ctx, sessionID)
+		return tagProvider(ctx, sessionProvider).CancelPaymentSession(ctx, sessionID)
 	}
 	return nil, ErrNotSupported
 }
 
-func (m *MultiProviderSelector) ListPaymentSessions(ctx context.Context, req *models.ListPaymentSessionsRequest) ([]*models.PaymentSession, error) {
+func (m *MultiProviderSelector) ListPaymentSessions(ctx context.Context, req *models.ListPaymentSessionsRequest) (_ []*models.PaymentSession, err error) {
+	ctx, span := startProviderSpan(ctx, "list_payment_sessions", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	var allSessions []*models.PaymentSession
 	for _, provider := range m.Providers {
 		if provider.IsAvailable(ctx) {
 			if sessionProvider, ok := provider.(PaymentSessionProvider); ok {
-				sessions, err := sessionProvider.ListPaymentSessions(ctx, req)
+				sessions, err := tagProvider(ctx, sessionProvider).ListPaymentSessions(ctx, req)
 				if err == nil {
 					allSessions = append(allSessions, sessions...)
 				}
@@ -1110,11 +1731,14 @@ func (m *MultiProviderSelector) ListPaymentSessions(ctx context.Context, req *mo
 	return allSessions, nil
 }
 
-func (m *MultiProviderSelector) ExpirePaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethod, error) {
+func (m *MultiProviderSelector) ExpirePaymentMethod(ctx context.Context, paymentMethodID string) (_ *models.PaymentMethod, err error) {
+	ctx, span := startProviderSpan(ctx, "expire_payment_method", "")
+	defer func() { endProviderSpan(span, err) }()
+
 	for _, provider := range m.Providers {
 		if provider.IsAvailable(ctx) {
 			if pmProvider, ok := provider.(PaymentMethodProvider); ok {
-				pm, err := pmProvider.ExpirePaymentMethod(ctx, paymentMethodID)
+				pm, err := tagProvider(ctx, pmProvider).ExpirePaymentMethod(ctx, paymentMethodID)
 				if err == nil {
 					return pm, nil
 				}
@@ -1124,6 +1748,13 @@ func (m *MultiProviderSelector) ExpirePaymentMethod(ctx context.Context, payment
 	return nil, ErrNotSupported
 }
 
+func (m *MultiProviderSelector) CircuitBreakerStates() map[string]circuitbreaker.State {
+	if m.routingEngine == nil {
+		return nil
+	}
+	return m.routingEngine.GetCircuitBreakerStates()
+}
+
 func (m *MultiProviderSelector) GetProviderStats() map[string]interface{} {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -1137,8 +1768,7 @@ func (m *MultiProviderSelector) GetProviderStats() map[string]interface{} {
 
 	providerStats := make(map[string]bool)
 	for _, provider := range m.Providers {
-		providerName := getProviderTypeName(provider)
-		providerStats[providerName] = provider.IsAvailable(context.Background())
+		providerStats[provider.Name()] = provider.IsAvailable(context.Background())
 	}
 	stats["provider_availability"] = providerStats
 
@@ -0,0 +1,543 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/stripe/stripe-go/v86"
+)
+
+func TestChargeRejectsPinnedProviderWithoutCurrency(t *testing.T) {
+	selector := CreateMultiProviderSelectorWithConfig(
+		[]PaymentProvider{CreateStripeProvider("sk_test_123"), CreateXenditProvider("xnd_development_123")},
+		nil,
+		MultiProviderConfig{},
+	)
+
+	_, err := selector.Charge(context.Background(), &models.ChargeRequest{
+		Amount:   1000,
+		Currency: "USD",
+		Provider: "xendit",
+	})
+	if !errors.Is(err, ErrProviderCurrency) {
+		t.Fatalf("expected ErrProviderCurrency, got %v", err)
+	}
+}
+
+func TestChargeRejectsUnconfiguredPinnedProvider(t *testing.T) {
+	selector := CreateMultiProviderSelectorWithConfig(
+		[]PaymentProvider{CreateStripeProvider("sk_test_123")},
+		nil,
+		MultiProviderConfig{},
+	)
+
+	_, err := selector.Charge(context.Background(), &models.ChargeRequest{
+		Amount:   1000,
+		Currency: "USD",
+		Provider: "airwallex",
+	})
+	if !errors.Is(err, ErrProviderNotConfigured) {
+		t.Fatalf("expected ErrProviderNotConfigured, got %v", err)
+	}
+}
+
+func TestSubAccountChargeRoutesOnlyToXendit(t *testing.T) {
+	selector := CreateMultiProviderSelectorWithConfig(
+		[]PaymentProvider{CreateStripeProvider("sk_test_123")},
+		nil,
+		MultiProviderConfig{},
+	)
+
+	_, err := selector.Charge(context.Background(), &models.ChargeRequest{
+		Amount:       1000,
+		Currency:     "USD",
+		Provider:     "stripe",
+		SubAccountID: "sub_1",
+	})
+	if !errors.Is(err, ErrSubAccountNotSupported) {
+		t.Fatalf("expected ErrSubAccountNotSupported for a stripe-pinned sub-account charge, got %v", err)
+	}
+
+	_, err = selector.Charge(context.Background(), &models.ChargeRequest{
+		Amount:       1000,
+		Currency:     "IDR",
+		SubAccountID: "sub_1",
+	})
+	if !errors.Is(err, ErrProviderNotConfigured) {
+		t.Fatalf("expected a sub-account charge to require xendit, got %v", err)
+	}
+}
+
+func TestChargeHonorsPinnedProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/authentication/login":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+		case "/api/v1/pa/payment_intents/create":
+			_, _ = w.Write([]byte(`{"id":"int_pinned","amount":10,"currency":"USD","status":"SUCCEEDED"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	airwallex := CreateAirwallexProvider("client", "key", true)
+	airwallex.baseURL = server.URL
+	selector := CreateMultiProviderSelectorWithConfig(
+		[]PaymentProvider{CreateStripeProvider("sk_test_123"), airwallex},
+		nil,
+		MultiProviderConfig{},
+	)
+
+	resp, err := selector.Charge(context.Background(), &models.ChargeRequest{
+		Amount:   1000,
+		Currency: "USD",
+		Provider: "Airwallex",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ProviderChargeID != "int_pinned" {
+		t.Fatalf("expected the charge to go through airwallex, got %+v", resp)
+	}
+}
+
+type fakeMappingStore struct {
+	mappings map[string]*models.ProviderMapping
+}
+
+func (f *fakeMappingStore) GetByEntity(_ context.Context, entityID, entityType string) (*models.ProviderMapping, error) {
+	mapping, ok := f.mappings[entityType+":"+entityID]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return mapping, nil
+}
+
+func (f *fakeMappingStore) CreateIfNotExists(_ context.Context, mapping *models.ProviderMapping) (bool, error) {
+	key := mapping.EntityType + ":" + mapping.EntityID
+	if _, ok := f.mappings[key]; ok {
+		return false, nil
+	}
+	f.mappings[key] = mapping
+	return true, nil
+}
+
+func TestRefundAfterRestartUsesPersistedMapping(t *testing.T) {
+	var refundedIntent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/authentication/login":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+		case "/api/v1/pa/refunds/create":
+			var body struct {
+				PaymentIntentID string `json:"payment_intent_id"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			refundedIntent = body.PaymentIntentID
+			_, _ = w.Write([]byte(`{"id":"rfd_1","amount":5,"currency":"HKD","status":"SUCCEEDED"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	airwallex := CreateAirwallexProvider("client", "key", true)
+	airwallex.baseURL = server.URL
+	store := &fakeMappingStore{mappings: map[string]*models.ProviderMapping{
+		"payment:pay_internal": {EntityID: "pay_internal", EntityType: "payment", ProviderName: "airwallex", ProviderEntityID: "int_provider"},
+	}}
+
+	// A new selector has nothing cached, as after a restart.
+	selector := CreateMultiProviderSelectorWithConfig(
+		[]PaymentProvider{CreateStripeProvider("sk_test_123"), airwallex},
+		store,
+		MultiProviderConfig{},
+	)
+
+	resp, err := selector.Refund(context.Background(), &models.RefundRequest{
+		PaymentID: "pay_internal",
+		Amount:    500,
+		Currency:  "HKD",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if refundedIntent != "int_provider" {
+		t.Fatalf("expected refund against the provider charge ID, got %q", refundedIntent)
+	}
+	if resp.PaymentID != "pay_internal" || resp.ProviderName != "airwallex" {
+		t.Fatalf("unexpected refund response: %+v", resp)
+	}
+}
+
+type fakeMethodProvider struct {
+	PaymentProvider
+	name    string
+	caps    ProviderCapabilities
+	charged int
+	down    bool
+}
+
+func (f *fakeMethodProvider) Name() string                       { return f.name }
+func (f *fakeMethodProvider) IsAvailable(context.Context) bool   { return !f.down }
+func (f *fakeMethodProvider) Capabilities() ProviderCapabilities { return f.caps }
+
+func (f *fakeMethodProvider) Charge(_ context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+	f.charged++
+	return &models.ChargeResponse{ID: "pay_" + f.name, Amount: req.Amount, Currency: req.Currency, Status: models.PaymentStatusSuccess}, nil
+}
+
+func TestUPIChargeRoutesToProviderSupportingMethod(t *testing.T) {
+	stripe := &fakeMethodProvider{name: "stripe", caps: ProviderCapabilities{
+		SupportedCurrencies:     []string{"USD", "INR"},
+		SupportedPaymentMethods: []models.PaymentMethodType{models.PMTypeCard},
+	}}
+	razorpay := &fakeMethodProvider{name: "razorpay", caps: ProviderCapabilities{
+		SupportedCurrencies:     []string{"INR"},
+		SupportedPaymentMethods: []models.PaymentMethodType{models.PMTypeCard, models.PMTypeUPI},
+	}}
+	selector := CreateMultiProviderSelectorWithConfig([]PaymentProvider{stripe, razorpay}, nil, MultiProviderConfig{})
+
+	resp, err := selector.Charge(context.Background(), &models.ChargeRequest{
+		Amount:            1000,
+		Currency:          "INR",
+		PaymentMethodType: models.PMTypeUPI,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ID != "pay_razorpay" || stripe.charged != 0 {
+		t.Fatalf("expected the upi charge to go to razorpay, got %+v (stripe charged %d times)", resp, stripe.charged)
+	}
+
+	_, err = selector.Charge(context.Background(), &models.ChargeRequest{
+		Amount:            1000,
+		Currency:          "USD",
+		PaymentMethodType: models.PMTypeUPI,
+	})
+	if !errors.Is(err, ErrPaymentMethodUnsupported) {
+		t.Fatalf("expected ErrPaymentMethodUnsupported for a usd upi charge, got %v", err)
+	}
+}
+
+func TestCheckPaymentMethodNamesProviderAndType(t *testing.T) {
+	err := CheckPaymentMethod(CreateStripeProvider("sk_test_123"), models.PMTypeUPI)
+	if !errors.Is(err, ErrPaymentMethodUnsupported) {
+		t.Fatalf("expected ErrPaymentMethodUnsupported, got %v", err)
+	}
+	if err.Error() != "payment method type is not supported by provider: stripe does not support upi" {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+	if err := CheckPaymentMethod(CreateRazorpayProvider("key", "secret"), models.PMTypeUPI); err != nil {
+		t.Fatalf("expected razorpay to accept upi, got %v", err)
+	}
+}
+
+// useStripeServer points the Stripe SDK at a test server that reports the
+// account as reachable and answers payment intent creation with status and
+// body.
+func useStripeServer(t *testing.T, status int, body string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/account":
+			_, _ = w.Write([]byte(`{"id":"acct_1","object":"account"}`))
+		case "/v1/payment_intents":
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	original := stripe.GetBackend(stripe.APIBackend)
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(server.URL),
+		MaxNetworkRetries: stripe.Int64(0),
+	}))
+	t.Cleanup(func() {
+		stripe.SetBackend(stripe.APIBackend, original)
+		server.Close()
+	})
+}
+
+func newFailoverAirwallex(t *testing.T, charges *int) *AirwallexProvider {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/authentication/login":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+		case "/api/v1/pa/payment_intents/create":
+			*charges++
+			_, _ = w.Write([]byte(`{"id":"int_failover","amount":10,"currency":"USD","status":"SUCCEEDED"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	airwallex := CreateAirwallexProvider("client", "key", true)
+	airwallex.baseURL = server.URL
+	return airwallex
+}
+
+func TestChargeFailsOverWhenStripeUnavailable(t *testing.T) {
+	useStripeServer(t, http.StatusServiceUnavailable, `{"error":{"type":"api_error","message":"Service unavailable"}}`)
+	var airwallexCharges int
+	selector := CreateMultiProviderSelectorWithConfig(
+		[]PaymentProvider{CreateStripeProvider("sk_test_123"), newFailoverAirwallex(t, &airwallexCharges)},
+		nil,
+		MultiProviderConfig{EnableChargeFailover: true},
+	)
+
+	resp, err := selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ProviderChargeID != "int_failover" || airwallexCharges != 1 {
+		t.Fatalf("expected the charge to succeed on airwallex, got %+v", resp)
+	}
+	if len(resp.ProviderAttempts) != 2 {
+		t.Fatalf("expected two attempts, got %+v", resp.ProviderAttempts)
+	}
+	if first := resp.ProviderAttempts[0]; first.Provider != "stripe" || first.Success || first.ErrorMessage == "" {
+		t.Fatalf("expected a failed stripe attempt first, got %+v", first)
+	}
+	if second := resp.ProviderAttempts[1]; second.Provider != "airwallex" || !second.Success {
+		t.Fatalf("expected a successful airwallex attempt second, got %+v", second)
+	}
+}
+
+func TestChargeDoesNotFailOverOnCardDecline(t *testing.T) {
+	useStripeServer(t, http.StatusPaymentRequired, `{"error":{"type":"card_error","code":"card_declined","decline_code":"generic_decline","message":"Your card was declined."}}`)
+	var airwallexCharges int
+	selector := CreateMultiProviderSelectorWithConfig(
+		[]PaymentProvider{CreateStripeProvider("sk_test_123"), newFailoverAirwallex(t, &airwallexCharges)},
+		nil,
+		MultiProviderConfig{EnableChargeFailover: true},
+	)
+
+	_, err := selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "USD"})
+	var failover *ChargeFailoverError
+	if !errors.As(err, &failover) {
+		t.Fatalf("expected a ChargeFailoverError, got %v", err)
+	}
+	if airwallexCharges != 0 || len(failover.Attempts) != 1 || failover.Attempts[0].Provider != "stripe" {
+		t.Fatalf("expected the decline to stop at stripe, got attempts %+v and %d airwallex charges", failover.Attempts, airwallexCharges)
+	}
+	var stripeErr *stripe.Error
+	if !errors.As(err, &stripeErr) || stripeErr.Type != stripe.ErrorTypeCard {
+		t.Fatalf("expected the stripe card error to be preserved, got %v", err)
+	}
+}
+
+func TestChargeExplainsCurrencySelection(t *testing.T) {
+	stripe := &fakeMethodProvider{name: "stripe", caps: ProviderCapabilities{SupportedCurrencies: []string{"USD"}}}
+	selector := CreateMultiProviderSelectorWithConfig([]PaymentProvider{stripe}, nil, MultiProviderConfig{})
+
+	resp, err := selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	explanation := resp.RoutingExplanation
+	if explanation == nil || explanation.SelectedProvider != "stripe" || explanation.Reason != models.RoutingReasonCurrency {
+		t.Fatalf("expected a currency match on stripe, got %+v", explanation)
+	}
+	if explanation.Confidence != 0 {
+		t.Fatalf("expected no confidence outside AI routing, got %v", explanation.Confidence)
+	}
+}
+
+func TestChargeExplainsAIRoutedSelection(t *testing.T) {
+	useStripeServer(t, http.StatusOK, `{"id":"pi_routed","object":"payment_intent","amount":1000,"currency":"usd","status":"succeeded"}`)
+	selector := CreateMultiProviderSelectorWithConfig(
+		[]PaymentProvider{CreateStripeProvider("sk_test_123")},
+		nil,
+		DefaultMultiProviderConfig(),
+	)
+
+	resp, err := selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	explanation := resp.RoutingExplanation
+	if explanation == nil || explanation.SelectedProvider != "stripe" || explanation.Reason != models.RoutingReasonAIRouted {
+		t.Fatalf("expected an AI-routed selection of stripe, got %+v", explanation)
+	}
+	if explanation.Confidence <= 0 || explanation.Detail == "" {
+		t.Fatalf("expected the routing score and reason, got %+v", explanation)
+	}
+}
+
+func TestSelectionHonorsConfiguredProviderOrder(t *testing.T) {
+	caps := ProviderCapabilities{SupportedCurrencies: []string{"AED", "INR"}}
+	stripe := &fakeMethodProvider{name: "stripe", caps: caps}
+	xendit := &fakeMethodProvider{name: "xendit", caps: caps}
+	razorpay := &fakeMethodProvider{name: "razorpay", caps: caps}
+	airwallex := &fakeMethodProvider{name: "airwallex", caps: caps}
+	selector := CreateMultiProviderSelectorWithConfig(
+		[]PaymentProvider{stripe, xendit, razorpay, airwallex},
+		nil,
+		MultiProviderConfig{ProviderOrder: []string{"airwallex", "stripe", "xendit"}},
+	)
+
+	for _, name := range []string{"stripe", "xendit", "razorpay", "airwallex"} {
+		if _, ok := selector.CapabilitiesFor(name); !ok {
+			t.Fatalf("expected %s to be recognized", name)
+		}
+	}
+
+	// AED has no usual provider, so the configured order decides.
+	resp, err := selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "AED"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ID != "pay_airwallex" {
+		t.Fatalf("expected airwallex first, got %s", resp.ID)
+	}
+
+	airwallex.down = true
+	resp, err = selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "AED"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ID != "pay_stripe" {
+		t.Fatalf("expected stripe next when airwallex is down, got %s", resp.ID)
+	}
+
+	// The currency's usual provider still wins over the configured order.
+	resp, err = selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "INR"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ID != "pay_razorpay" {
+		t.Fatalf("expected razorpay for INR, got %s", resp.ID)
+	}
+
+	names := make([]string, 0, len(selector.Providers))
+	for _, provider := range selector.Providers {
+		names = append(names, provider.Name())
+	}
+	if got := strings.Join(names, ","); got != "airwallex,stripe,xendit,razorpay" {
+		t.Fatalf("expected unlisted providers after the configured ones, got %s", got)
+	}
+}
+
+func TestProviderStatsNameAirwallex(t *testing.T) {
+	var charges int
+	store := &fakeMappingStore{mappings: map[string]*models.ProviderMapping{}}
+	selector := CreateMultiProviderSelectorWithConfig(
+		[]PaymentProvider{newFailoverAirwallex(t, &charges)},
+		store,
+		MultiProviderConfig{},
+	)
+
+	resp, err := selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "HKD"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mapping := store.mappings["payment:"+resp.ID]; mapping == nil || mapping.ProviderName != "airwallex" {
+		t.Fatalf("expected the payment mapped to airwallex, got %+v", mapping)
+	}
+
+	stats := selector.GetProviderStats()
+	availability := stats["provider_availability"].(map[string]bool)
+	if len(availability) != 1 || !availability["airwallex"] {
+		t.Fatalf("expected airwallex counted as available, got %v", availability)
+	}
+	if stats["total_providers"] != 1 || stats["payment_mappings"] != 1 {
+		t.Fatalf("expected one provider and one payment mapping, got %v", stats)
+	}
+}
+
+// fakeCustomerProvider keeps customers in memory, as a provider would.
+type fakeCustomerProvider struct {
+	PaymentProvider
+	name      string
+	customers map[string]*models.Customer
+}
+
+func newFakeCustomerProvider(name string) *fakeCustomerProvider {
+	return &fakeCustomerProvider{name: name, customers: map[string]*models.Customer{}}
+}
+
+func (f *fakeCustomerProvider) Name() string                     { return f.name }
+func (f *fakeCustomerProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeCustomerProvider) CreateCustomer(_ context.Context, req *models.CreateCustomerRequest) (string, error) {
+	id := f.name + "_cust_" + req.ExternalID
+	f.customers[id] = &models.Customer{ExternalID: req.ExternalID, Email: req.Email}
+	return id, nil
+}
+
+func (f *fakeCustomerProvider) GetCustomer(_ context.Context, customerID string) (*models.Customer, error) {
+	customer, ok := f.customers[customerID]
+	if !ok {
+		return nil, errors.New("resource_missing")
+	}
+	return customer, nil
+}
+
+func TestCustomerCreatedOnXenditIsReadFromXendit(t *testing.T) {
+	stripe := newFakeCustomerProvider("stripe")
+	xendit := newFakeCustomerProvider("xendit")
+	store := &fakeMappingStore{mappings: map[string]*models.ProviderMapping{}}
+	selector := CreateMultiProviderSelectorWithConfig([]PaymentProvider{stripe, xendit}, store, MultiProviderConfig{})
+
+	customerID, err := selector.CreateCustomer(context.Background(), &models.CreateCustomerRequest{
+		ExternalID: "merchant-42",
+		Email:      "budi@example.com",
+		Provider:   "Xendit",
+	})
+	if err != nil {
+		t.Fatalf("create customer: %v", err)
+	}
+	if len(xendit.customers) != 1 || len(stripe.customers) != 0 {
+		t.Fatalf("expected the customer created on xendit only, xendit=%d stripe=%d", len(xendit.customers), len(stripe.customers))
+	}
+	if mapping := store.mappings["customer:"+customerID]; mapping == nil || mapping.ProviderName != "xendit" {
+		t.Fatalf("expected the customer mapped to xendit, got %+v", mapping)
+	}
+
+	// A new selector has nothing cached, as after a restart.
+	restarted := CreateMultiProviderSelectorWithConfig([]PaymentProvider{stripe, xendit}, store, MultiProviderConfig{})
+	customer, err := restarted.GetCustomer(context.Background(), customerID)
+	if err != nil {
+		t.Fatalf("get customer: %v", err)
+	}
+	if customer.ExternalID != "merchant-42" {
+		t.Fatalf("expected the xendit customer, got %+v", customer)
+	}
+}
+
+func TestCustomerProviderFollowsCurrencyHint(t *testing.T) {
+	stripe := newFakeCustomerProvider("stripe")
+	xendit := newFakeCustomerProvider("xendit")
+	selector := CreateMultiProviderSelectorWithConfig([]PaymentProvider{stripe, xendit}, nil, MultiProviderConfig{})
+
+	if _, err := selector.CreateCustomer(context.Background(), &models.CreateCustomerRequest{ExternalID: "m-1", Currency: "idr"}); err != nil {
+		t.Fatalf("create customer: %v", err)
+	}
+	if _, err := selector.CreateCustomer(context.Background(), &models.CreateCustomerRequest{ExternalID: "m-2"}); err != nil {
+		t.Fatalf("create customer: %v", err)
+	}
+	if len(xendit.customers) != 1 || len(stripe.customers) != 1 {
+		t.Fatalf("expected the idr customer on xendit and the other on stripe, xendit=%d stripe=%d", len(xendit.customers), len(stripe.customers))
+	}
+
+	_, err := selector.CreateCustomer(context.Background(), &models.CreateCustomerRequest{ExternalID: "m-3", Provider: "adyen"})
+	if !errors.Is(err, ErrProviderNotConfigured) {
+		t.Fatalf("expected ErrProviderNotConfigured for an unknown provider, got %v", err)
+	}
+}
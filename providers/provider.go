@@ -3,13 +3,37 @@ package providers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/malwarebo/conductor/internal/convert"
 	"github.com/malwarebo/conductor/models"
 )
 
 var (
-	ErrNotSupported = errors.New("feature not supported by provider")
+	ErrNotSupported              = errors.New("feature not supported by provider")
+	ErrPlatformFeesNotSupported  = errors.New("application fees and on_behalf_of are not supported by provider")
+	ErrWebhookTimestampMissing   = errors.New("webhook timestamp header missing")
+	ErrWebhookTimestampExpired   = errors.New("webhook timestamp outside tolerance window")
+	ErrBankAccountRequired       = errors.New("bank account details or a payment method token are required")
+	ErrVerificationNotPending    = errors.New("payment method is not awaiting verification")
+	ErrMeterNotConfigured        = errors.New("plan has no billing meter")
+	ErrPaymentDeclined           = errors.New("payment declined")
+	ErrMultiCaptureUnavailable   = errors.New("multicapture is not available for this payment")
+	ErrReversalExceedsCapturable = errors.New("reversal amount must be less than the capturable amount")
+	ErrProviderNotConfigured     = errors.New("requested provider is not configured")
+	ErrProviderCurrency          = errors.New("requested provider does not support currency")
+	ErrProviderUnavailable       = errors.New("requested provider is unavailable")
+	ErrSubAccountNotSupported    = errors.New("sub_account_id is only supported by xendit")
+	ErrPaymentMethodUnsupported  = errors.New("payment method type is not supported by provider")
+	ErrTaxAddressRequired        = errors.New("tax calculation needs a tax address or a customer with an address on file")
+)
+
+// List calls follow provider pagination to the end, but stop after these
+// bounds so a provider that keeps reporting more results cannot loop forever.
+const (
+	maxListPages = 50
+	maxListItems = 5000
 )
 
 var (
@@ -28,14 +52,124 @@ var (
 )
 
 type ProviderCapabilities struct {
-	SupportsInvoices        bool
-	SupportsPayouts         bool
-	SupportsPaymentSessions bool
-	Supports3DS             bool
-	SupportsManualCapture   bool
-	SupportsBalance         bool
-	SupportedCurrencies     []string
-	SupportedPaymentMethods []models.PaymentMethodType
+	SupportsSubscriptions   bool                       `json:"supports_subscriptions"`
+	SupportsInvoices        bool                       `json:"supports_invoices"`
+	SupportsPayouts         bool                       `json:"supports_payouts"`
+	SupportsPaymentSessions bool                       `json:"supports_payment_sessions"`
+	Supports3DS             bool                       `json:"supports_3ds"`
+	SupportsManualCapture   bool                       `json:"supports_manual_capture"`
+	SupportsMultiCapture    bool                       `json:"supports_multi_capture"`
+	SupportsPartialReversal bool                       `json:"supports_partial_reversal"`
+	SupportsOvercapture     bool                       `json:"supports_overcapture"`
+	MaxOvercapturePercent   int                        `json:"max_overcapture_percent,omitempty"`
+	SupportsBalance         bool                       `json:"supports_balance"`
+	SupportsPlatformFees    bool                       `json:"supports_platform_fees"`
+	SupportedCurrencies     []string                   `json:"supported_currencies"`
+	SupportedPaymentMethods []models.PaymentMethodType `json:"supported_payment_methods"`
+	Mode                    Mode                       `json:"mode,omitempty"`
+}
+
+type Capability string
+
+const (
+	CapabilitySubscriptions Capability = "subscriptions"
+	CapabilityInvoices      Capability = "invoices"
+	CapabilityPayouts       Capability = "payouts"
+	CapabilityBalance       Capability = "balance"
+	CapabilityManualCapture Capability = "manual_capture"
+)
+
+func (c ProviderCapabilities) Supports(capability Capability) bool {
+	switch capability {
+	case CapabilitySubscriptions:
+		return c.SupportsSubscriptions
+	case CapabilityInvoices:
+		return c.SupportsInvoices
+	case CapabilityPayouts:
+		return c.SupportsPayouts
+	case CapabilityBalance:
+		return c.SupportsBalance
+	case CapabilityManualCapture:
+		return c.SupportsManualCapture
+	default:
+		return false
+	}
+}
+
+func (c ProviderCapabilities) SupportsCurrency(currency string) bool {
+	for _, supported := range c.SupportedCurrencies {
+		if strings.EqualFold(supported, currency) {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsPaymentMethod reports whether the provider accepts the given payment
+// method type. An empty type is not checked and is always accepted.
+func (c ProviderCapabilities) SupportsPaymentMethod(method models.PaymentMethodType) bool {
+	if method == "" {
+		return true
+	}
+	for _, supported := range c.SupportedPaymentMethods {
+		if strings.EqualFold(string(supported), string(method)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPaymentMethod returns ErrPaymentMethodUnsupported, naming the provider
+// and the type, when provider cannot take payments of the given method type.
+func CheckPaymentMethod(provider PaymentProvider, method models.PaymentMethodType) error {
+	if method == "" || provider.Capabilities().SupportsPaymentMethod(method) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s does not support %s", ErrPaymentMethodUnsupported, provider.Name(), method)
+}
+
+// MergeCapabilities returns the union of the given capabilities, with each
+// currency and payment method listed once.
+func MergeCapabilities(all ...ProviderCapabilities) ProviderCapabilities {
+	merged := ProviderCapabilities{
+		SupportedCurrencies:     []string{},
+		SupportedPaymentMethods: []models.PaymentMethodType{},
+	}
+	currencies := make(map[string]bool)
+	methods := make(map[models.PaymentMethodType]bool)
+
+	for _, caps := range all {
+		merged.SupportsSubscriptions = merged.SupportsSubscriptions || caps.SupportsSubscriptions
+		merged.SupportsInvoices = merged.SupportsInvoices || caps.SupportsInvoices
+		merged.SupportsPayouts = merged.SupportsPayouts || caps.SupportsPayouts
+		merged.SupportsPaymentSessions = merged.SupportsPaymentSessions || caps.SupportsPaymentSessions
+		merged.Supports3DS = merged.Supports3DS || caps.Supports3DS
+		merged.SupportsManualCapture = merged.SupportsManualCapture || caps.SupportsManualCapture
+		merged.SupportsMultiCapture = merged.SupportsMultiCapture || caps.SupportsMultiCapture
+		merged.SupportsPartialReversal = merged.SupportsPartialReversal || caps.SupportsPartialReversal
+		merged.SupportsOvercapture = merged.SupportsOvercapture || caps.SupportsOvercapture
+		merged.MaxOvercapturePercent = max(merged.MaxOvercapturePercent, caps.MaxOvercapturePercent)
+		merged.SupportsBalance = merged.SupportsBalance || caps.SupportsBalance
+		merged.SupportsPlatformFees = merged.SupportsPlatformFees || caps.SupportsPlatformFees
+		for _, currency := range caps.SupportedCurrencies {
+			if !currencies[currency] {
+				currencies[currency] = true
+				merged.SupportedCurrencies = append(merged.SupportedCurrencies, currency)
+			}
+		}
+		for _, method := range caps.SupportedPaymentMethods {
+			if !methods[method] {
+				methods[method] = true
+				merged.SupportedPaymentMethods = append(merged.SupportedPaymentMethods, method)
+			}
+		}
+	}
+
+	return merged
+}
+
+func hasPlatformFees(req *models.ChargeRequest) bool {
+	return req.ApplicationFeeAmount > 0 || req.OnBehalfOf != ""
 }
 
 type PaymentProvider interface {
@@ -43,6 +177,7 @@ type PaymentProvider interface {
 	Capabilities() ProviderCapabilities
 
 	Charge(ctx context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error)
+	GetCharge(ctx context.Context, providerChargeID string) (*models.ChargeResponse, error)
 	Refund(ctx context.Context, req *models.RefundRequest) (*models.RefundResponse, error)
 
 	CreateSubscription(ctx context.Context, req *models.CreateSubscriptionRequest) (*models.Subscription, error)
@@ -81,6 +216,10 @@ type InvoiceProvider interface {
 	CancelInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error)
 }
 
+type InvoicePaymentProvider interface {
+	PayInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error)
+}
+
 type PayoutProvider interface {
 	CreatePayout(ctx context.Context, req *models.CreatePayoutRequest) (*models.Payout, error)
 	GetPayout(ctx context.Context, payoutID string) (*models.Payout, error)
@@ -89,6 +228,10 @@ type PayoutProvider interface {
 	GetPayoutChannels(ctx context.Context, currency string) ([]*models.PayoutChannel, error)
 }
 
+type MeteredBillingProvider interface {
+	RecordUsage(ctx context.Context, usage *models.UsageEvent) (*models.UsageRecord, error)
+}
+
 type PaymentSessionProvider interface {
 	CreatePaymentSession(ctx context.Context, req *models.CreatePaymentSessionRequest) (*models.PaymentSession, error)
 	GetPaymentSession(ctx context.Context, sessionID string) (*models.PaymentSession, error)
@@ -99,6 +242,12 @@ type PaymentSessionProvider interface {
 	ListPaymentSessions(ctx context.Context, req *models.ListPaymentSessionsRequest) ([]*models.PaymentSession, error)
 }
 
+// HostedCheckoutProvider creates provider-hosted payment pages. The returned
+// session carries the URL to redirect the customer to.
+type HostedCheckoutProvider interface {
+	CreateCheckoutSession(ctx context.Context, req *models.CreateCheckoutSessionRequest) (*models.CheckoutSession, error)
+}
+
 type PaymentMethodProvider interface {
 	CreatePaymentMethod(ctx context.Context, req *models.CreatePaymentMethodRequest) (*models.PaymentMethod, error)
 	GetPaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethod, error)
@@ -108,18 +257,63 @@ type PaymentMethodProvider interface {
 	ExpirePaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethod, error)
 }
 
+// PaymentMethodVerifier confirms payment methods that need out-of-band
+// verification, such as bank accounts verified with micro-deposits.
+type PaymentMethodVerifier interface {
+	VerifyPaymentMethod(ctx context.Context, paymentMethodID string, amounts []int64) (*models.PaymentMethod, error)
+}
+
+// ProviderResolver is implemented by selectors that delegate to a concrete
+// provider, so callers can inspect the provider that will handle a call.
+type ProviderResolver interface {
+	ProviderForCurrency(ctx context.Context, currency string) (PaymentProvider, error)
+	ProviderForEntity(ctx context.Context, entityID, entityType string) (PaymentProvider, error)
+	ProviderForSubscriptions(ctx context.Context) (PaymentProvider, error)
+}
+
 type BalanceProvider interface {
 	GetBalance(ctx context.Context, currency string) (*models.Balance, error)
 }
 
+// FXProvider quotes currency conversions for payouts that settle in a
+// currency other than the balance they are funded from. amount is what the
+// recipient should receive, in the minor unit of to.
+type FXProvider interface {
+	GetFXQuote(ctx context.Context, from, to string, amount int64) (*models.FXQuote, error)
+}
+
 type CaptureProvider interface {
 	CapturePayment(ctx context.Context, paymentID string, amount int64) error
 }
 
+// MultiCaptureProvider captures part of an authorization while keeping the
+// remainder capturable until a capture is marked final.
+type MultiCaptureProvider interface {
+	CapturePartial(ctx context.Context, paymentID string, amount int64, final bool) error
+}
+
+// CapabilityLookup resolves the capabilities of a named provider behind a
+// selector that routes to several providers.
+type CapabilityLookup interface {
+	CapabilitiesFor(providerName string) (ProviderCapabilities, bool)
+}
+
 type VoidProvider interface {
 	VoidPayment(ctx context.Context, paymentID string) error
 }
 
+// AuthorizationReversalProvider releases part of an uncaptured authorization,
+// lowering the hold by amount while the rest stays capturable.
+type AuthorizationReversalProvider interface {
+	ReverseAuthorization(ctx context.Context, paymentID string, amount int64) error
+}
+
+// TaxProvider calculates the tax owed on a charge or invoice before it is
+// created. The returned TaxAmount is added on top of the line items.
+type TaxProvider interface {
+	CalculateTax(ctx context.Context, req *models.CalculateTaxRequest) (*models.TaxCalculation, error)
+}
+
 type ThreeDSecureProvider interface {
 	Create3DSSession(ctx context.Context, paymentID string, returnURL string) (*ThreeDSecureSession, error)
 	Confirm3DSPayment(ctx context.Context, paymentID string) (*models.ChargeResponse, error)
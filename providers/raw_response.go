@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+)
+
+// RawResponse is a provider response body exactly as received, kept so
+// support can see what the provider actually returned.
+type RawResponse struct {
+	Provider   string
+	Operation  string
+	Body       []byte
+	ReceivedAt time.Time
+}
+
+// RawResponseRecorder collects the raw responses of the provider calls made
+// with its context. Providers record nothing unless one is attached, so
+// capture costs nothing when it is switched off.
+type RawResponseRecorder struct {
+	mu        sync.Mutex
+	responses []RawResponse
+}
+
+func WithRawResponseRecorder(ctx context.Context, recorder *RawResponseRecorder) context.Context {
+	return context.WithValue(ctx, ctxkeys.RawResponseRecorder, recorder)
+}
+
+func (r *RawResponseRecorder) Responses() []RawResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RawResponse(nil), r.responses...)
+}
+
+// RecordRawResponse adds body to the recorder attached to ctx, if any.
+func RecordRawResponse(ctx context.Context, provider, operation string, body []byte) {
+	recorder, ok := ctx.Value(ctxkeys.RawResponseRecorder).(*RawResponseRecorder)
+	if !ok || recorder == nil || len(body) == 0 {
+		return
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.responses = append(recorder.responses, RawResponse{
+		Provider:   provider,
+		Operation:  operation,
+		Body:       append([]byte(nil), body...),
+		ReceivedAt: time.Now(),
+	})
+}
+
+// recordRawJSON records SDK results that do not expose the response body by
+// re-encoding the decoded value.
+func recordRawJSON(ctx context.Context, provider, operation string, v interface{}) {
+	if _, ok := ctx.Value(ctxkeys.RawResponseRecorder).(*RawResponseRecorder); !ok {
+		return
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	RecordRawResponse(ctx, provider, operation, body)
+}
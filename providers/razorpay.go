@@ -3,10 +3,12 @@ package providers
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/malwarebo/conductor/internal/convert"
 	"github.com/malwarebo/conductor/internal/crypto"
+	"github.com/malwarebo/conductor/internal/httputil"
 	"github.com/malwarebo/conductor/models"
 	razorpay "github.com/razorpay/razorpay-go"
 )
@@ -16,39 +18,54 @@ type RazorpayProvider struct {
 	keySecret     string
 	webhookSecret string
 	client        *razorpay.Client
+	mode          Mode
 }
 
 func CreateRazorpayProvider(keyID, keySecret string) *RazorpayProvider {
 	client := razorpay.NewClient(keyID, keySecret)
+	client.HTTPClient = httputil.NewHTTPClient(httputil.DefaultTransportConfig())
 	return &RazorpayProvider{
 		keyID:     keyID,
 		keySecret: keySecret,
 		client:    client,
+		mode:      modeFromKey(keyID, razorpayTestKeyPrefixes),
 	}
 }
 
 func CreateRazorpayProviderWithWebhook(keyID, keySecret, webhookSecret string) *RazorpayProvider {
 	client := razorpay.NewClient(keyID, keySecret)
+	client.HTTPClient = httputil.NewHTTPClient(httputil.DefaultTransportConfig())
 	return &RazorpayProvider{
 		keyID:         keyID,
 		keySecret:     keySecret,
 		webhookSecret: webhookSecret,
 		client:        client,
+		mode:          modeFromKey(keyID, razorpayTestKeyPrefixes),
 	}
 }
 
+func (p *RazorpayProvider) SetHTTPClient(client *http.Client) {
+	p.client.HTTPClient = client
+}
+
 func (p *RazorpayProvider) Name() string {
 	return "razorpay"
 }
 
+func (p *RazorpayProvider) Mode() Mode {
+	return p.mode
+}
+
 func (p *RazorpayProvider) Capabilities() ProviderCapabilities {
 	return ProviderCapabilities{
+		SupportsSubscriptions:   true,
 		SupportsInvoices:        true,
 		SupportsPayouts:         true,
 		SupportsPaymentSessions: true,
 		Supports3DS:             true,
 		SupportsManualCapture:   true,
 		SupportsBalance:         false,
+		SupportsPlatformFees:    false,
 		SupportedCurrencies:     []string{"INR", "USD", "EUR", "GBP", "SGD", "AED", "AUD", "CAD", "HKD", "JPY", "MYR", "SAR"},
 		SupportedPaymentMethods: []models.PaymentMethodType{
 			models.PMTypeCard,
@@ -58,10 +75,18 @@ func (p *RazorpayProvider) Capabilities() ProviderCapabilities {
 			models.PMTypeEMI,
 			models.PMTypeCardlessEMI,
 		},
+		Mode: p.mode,
 	}
 }
 
 func (p *RazorpayProvider) Charge(ctx context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+	if hasPlatformFees(req) {
+		return nil, ErrPlatformFeesNotSupported
+	}
+	if req.SubAccountID != "" {
+		return nil, ErrSubAccountNotSupported
+	}
+
 	orderData := map[string]interface{}{
 		"amount":   req.Amount,
 		"currency": req.Currency,
@@ -87,6 +112,7 @@ func (p *RazorpayProvider) Charge(ctx context.Context, req *models.ChargeRequest
 	if err != nil {
 		return nil, fmt.Errorf("razorpay order creation failed: %w", err)
 	}
+	recordRawJSON(ctx, "razorpay", "charge", order)
 
 	orderID := convert.StringFromMap(order, "id")
 	status := p.mapOrderStatus(convert.StringFromMap(order, "status"))
@@ -96,6 +122,10 @@ func (p *RazorpayProvider) Charge(ctx context.Context, req *models.ChargeRequest
 		captureMethod = models.CaptureMethodManual
 	}
 
+	if req.VPA != "" {
+		return p.chargeUPICollect(ctx, req, orderID, captureMethod)
+	}
+
 	return &models.ChargeResponse{
 		ID:               orderID,
 		CustomerID:       req.CustomerID,
@@ -115,6 +145,72 @@ func (p *RazorpayProvider) Charge(ctx context.Context, req *models.ChargeRequest
 	}, nil
 }
 
+// chargeUPICollect sends a UPI collect request for the order to the
+// customer's VPA. The payment stays pending until the customer approves it
+// in their UPI app; the payment.* webhooks report the outcome.
+func (p *RazorpayProvider) chargeUPICollect(ctx context.Context, req *models.ChargeRequest, orderID string, captureMethod models.CaptureMethod) (*models.ChargeResponse, error) {
+	payment, err := p.client.Payment.CreateUpi(razorpayUPICollectData(req, orderID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("razorpay upi collect failed: %w", err)
+	}
+	recordRawJSON(ctx, "razorpay", "charge", payment)
+
+	return &models.ChargeResponse{
+		ID:               orderID,
+		CustomerID:       req.CustomerID,
+		Amount:           req.Amount,
+		Currency:         req.Currency,
+		Status:           models.PaymentStatusRequiresAction,
+		PaymentMethod:    req.VPA,
+		Description:      req.Description,
+		ProviderName:     "razorpay",
+		ProviderChargeID: orderID,
+		CaptureMethod:    captureMethod,
+		Metadata:         req.Metadata,
+		CreatedAt:        time.Now(),
+		RequiresAction:   true,
+		NextActionType:   "upi_collect_approval",
+		NextActionURL:    razorpayNextActionURL(payment, "poll"),
+		ClientSecret:     orderID,
+	}, nil
+}
+
+// razorpayUPICollectData builds the S2S UPI payment. Razorpay requires the
+// customer's email, phone and browser details, which are taken from the
+// charge metadata.
+func razorpayUPICollectData(req *models.ChargeRequest, orderID string) map[string]interface{} {
+	data := map[string]interface{}{
+		"amount":   req.Amount,
+		"currency": req.Currency,
+		"order_id": orderID,
+		"method":   "upi",
+		"upi": map[string]interface{}{
+			"flow": "collect",
+			"vpa":  req.VPA,
+		},
+		"email":      convert.StringFromMap(req.Metadata, "email"),
+		"contact":    convert.StringFromMap(req.Metadata, "contact"),
+		"ip":         req.IPAddress,
+		"user_agent": convert.StringFromMap(req.Metadata, "user_agent"),
+	}
+	if req.Description != "" {
+		data["description"] = req.Description
+	}
+	return data
+}
+
+// razorpayNextActionURL returns the URL of the named action in a payment's
+// "next" list.
+func razorpayNextActionURL(payment map[string]interface{}, action string) string {
+	next, _ := payment["next"].([]interface{})
+	for _, item := range next {
+		if entry, ok := item.(map[string]interface{}); ok && convert.StringFromMap(entry, "action") == action {
+			return convert.StringFromMap(entry, "url")
+		}
+	}
+	return ""
+}
+
 var razorpayOrderStatusMap = map[string]models.PaymentStatus{
 	"paid":      models.PaymentStatusSuccess,
 	"attempted": models.PaymentStatusProcessing,
@@ -141,6 +237,10 @@ func (p *RazorpayProvider) CapturePayment(ctx context.Context, paymentID string,
 	return nil
 }
 
+func (p *RazorpayProvider) GetCharge(ctx context.Context, providerChargeID string) (*models.ChargeResponse, error) {
+	return nil, ErrNotSupported
+}
+
 func (p *RazorpayProvider) VoidPayment(ctx context.Context, paymentID string) error {
 	return fmt.Errorf("razorpay does not support voiding payments directly, use refund instead")
 }
@@ -150,25 +250,25 @@ func (p *RazorpayProvider) Refund(ctx context.Context, req *models.RefundRequest
 		"amount": req.Amount,
 	}
 
-	if req.Metadata != nil {
-		notes := make(map[string]interface{})
-		for k, v := range req.Metadata {
-			notes[k] = v
-		}
-		if req.Reason != "" {
-			notes["reason"] = req.Reason
-		}
+	notes := make(map[string]interface{})
+	for k, v := range req.Metadata {
+		notes[k] = v
+	}
+	if reason := models.NormalizeRefundReason(req.Reason); reason != "" {
+		notes["reason"] = string(reason)
+	}
+	if req.Note != "" {
+		notes["note"] = req.Note
+	}
+	if len(notes) > 0 {
 		refundData["notes"] = notes
-	} else if req.Reason != "" {
-		refundData["notes"] = map[string]interface{}{
-			"reason": req.Reason,
-		}
 	}
 
 	ref, err := p.client.Payment.Refund(req.PaymentID, int(req.Amount), refundData, nil)
 	if err != nil {
 		return nil, fmt.Errorf("razorpay refund failed: %w", err)
 	}
+	recordRawJSON(ctx, "razorpay", "refund", ref)
 
 	refundID := convert.StringFromMap(ref, "id")
 	status := convert.StringFromMap(ref, "status")
@@ -182,7 +282,8 @@ func (p *RazorpayProvider) Refund(ctx context.Context, req *models.RefundRequest
 		Amount:           req.Amount,
 		Currency:         req.Currency,
 		Status:           status,
-		Reason:           req.Reason,
+		Reason:           string(models.NormalizeRefundReason(req.Reason)),
+		Note:             req.Note,
 		ProviderName:     "razorpay",
 		ProviderRefundID: refundID,
 		Metadata:         req.Metadata,
@@ -354,13 +455,7 @@ func (p *RazorpayProvider) CreateInvoice(ctx context.Context, req *models.Create
 		invoiceData["expire_by"] = req.DueDate.Unix()
 	}
 
-	lineItems := []map[string]interface{}{
-		{
-			"name":   "Payment",
-			"amount": req.Amount,
-		},
-	}
-	invoiceData["line_items"] = lineItems
+	invoiceData["line_items"] = razorpayLineItems(req)
 
 	if req.CustomerEmail != "" {
 		invoiceData["customer"] = map[string]interface{}{
@@ -376,6 +471,34 @@ func (p *RazorpayProvider) CreateInvoice(ctx context.Context, req *models.Create
 	return p.mapInvoice(inv), nil
 }
 
+// razorpayLineItems sends the request's line items as-is; an invoice without
+// any is billed as a single "Payment" line for the full amount.
+func razorpayLineItems(req *models.CreateInvoiceRequest) []map[string]interface{} {
+	if len(req.LineItems) == 0 {
+		return []map[string]interface{}{
+			{
+				"name":   "Payment",
+				"amount": req.Amount,
+			},
+		}
+	}
+
+	lineItems := make([]map[string]interface{}, 0, len(req.LineItems))
+	for _, item := range req.LineItems {
+		line := map[string]interface{}{
+			"name":     item.Name,
+			"amount":   item.UnitAmount,
+			"currency": req.Currency,
+			"quantity": item.Quantity,
+		}
+		if item.Description != "" {
+			line["description"] = item.Description
+		}
+		lineItems = append(lineItems, line)
+	}
+	return lineItems
+}
+
 func (p *RazorpayProvider) GetInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error) {
 	inv, err := p.client.Invoice.Fetch(invoiceID, nil, nil)
 	if err != nil {
@@ -710,6 +833,17 @@ func (p *RazorpayProvider) mapSubscription(sub map[string]interface{}) *models.S
 		quantity = 1
 	}
 
+	// current_start/current_end bound the billing cycle and stay null until the
+	// subscription is authenticated; start_at/end_at span the whole term.
+	periodStart := convert.Int64FromMap(sub, "current_start")
+	if periodStart == 0 {
+		periodStart = convert.Int64FromMap(sub, "start_at")
+	}
+	periodEnd := convert.Int64FromMap(sub, "current_end")
+	if periodEnd == 0 {
+		periodEnd = convert.Int64FromMap(sub, "end_at")
+	}
+
 	return &models.Subscription{
 		ID:                 convert.StringFromMap(sub, "id"),
 		CustomerID:         convert.StringFromMap(sub, "customer_id"),
@@ -717,8 +851,8 @@ func (p *RazorpayProvider) mapSubscription(sub map[string]interface{}) *models.S
 		Status:             p.mapSubscriptionStatus(convert.StringFromMap(sub, "status")),
 		Quantity:           quantity,
 		ProviderName:       "razorpay",
-		CurrentPeriodStart: convert.UnixToTime(convert.Int64FromMap(sub, "start_at")),
-		CurrentPeriodEnd:   convert.UnixToTime(convert.Int64FromMap(sub, "end_at")),
+		CurrentPeriodStart: convert.UnixToTime(periodStart),
+		CurrentPeriodEnd:   convert.UnixToTime(periodEnd),
 		CanceledAt:         convert.UnixToTimePtr(convert.Int64FromMap(sub, "cancelled_at")),
 		CreatedAt:          time.Now(),
 		UpdatedAt:          time.Now(),
@@ -0,0 +1,110 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+func TestRazorpayGetChargeNotSupported(t *testing.T) {
+	p := CreateRazorpayProvider("key", "secret")
+	if _, err := p.GetCharge(context.Background(), "order_123"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestRazorpayLineItemsFromRequest(t *testing.T) {
+	req := &models.CreateInvoiceRequest{
+		Currency: "INR",
+		Amount:   7000,
+		LineItems: []models.InvoiceLineItem{
+			{Name: "Seat licence", Quantity: 3, UnitAmount: 1500},
+			{Name: "Onboarding", Description: "One-off setup", Quantity: 1, UnitAmount: 2500},
+		},
+	}
+
+	items := razorpayLineItems(req)
+	if len(items) != 2 {
+		t.Fatalf("expected 2 line items, got %d", len(items))
+	}
+	if items[0]["amount"] != int64(1500) || items[0]["quantity"] != int64(3) {
+		t.Fatalf("expected unit amount and quantity on the first line, got %v", items[0])
+	}
+	if items[1]["description"] != "One-off setup" {
+		t.Fatalf("expected description on the second line, got %v", items[1])
+	}
+
+	single := razorpayLineItems(&models.CreateInvoiceRequest{Currency: "INR", Amount: 500})
+	if len(single) != 1 || single[0]["amount"] != int64(500) {
+		t.Fatalf("expected a single line for the full amount, got %v", single)
+	}
+}
+
+type razorpayTransport func(*http.Request) (int, interface{})
+
+func (f razorpayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	status, body := f(r)
+	encoded, _ := json.Marshal(body)
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(encoded))),
+		Request:    r,
+	}, nil
+}
+
+func TestRazorpayVPAChargeSendsUPICollect(t *testing.T) {
+	var upiRequest map[string]interface{}
+	p := CreateRazorpayProvider("rzp_test_key", "secret")
+	p.SetHTTPClient(&http.Client{Transport: razorpayTransport(func(r *http.Request) (int, interface{}) {
+		switch r.URL.Path {
+		case "/v1/orders":
+			return http.StatusOK, map[string]interface{}{"id": "order_upi", "status": "created"}
+		case "/v1/payments/create/upi":
+			if err := json.NewDecoder(r.Body).Decode(&upiRequest); err != nil {
+				t.Errorf("decode upi request: %v", err)
+			}
+			return http.StatusOK, map[string]interface{}{
+				"razorpay_payment_id": "pay_upi",
+				"next": []interface{}{
+					map[string]interface{}{"action": "poll", "url": "https://api.razorpay.com/v1/payments/pay_upi/status"},
+				},
+			}
+		}
+		t.Errorf("unexpected request to %s", r.URL.Path)
+		return http.StatusNotFound, map[string]interface{}{}
+	})})
+
+	resp, err := p.Charge(context.Background(), &models.ChargeRequest{
+		CustomerID:        "cus_1",
+		Amount:            49900,
+		Currency:          "INR",
+		PaymentMethodType: models.PMTypeUPI,
+		VPA:               "jenny@okaxis",
+		IPAddress:         "203.0.113.7",
+		Metadata:          models.JSON{"email": "jenny@example.com", "contact": "+919900000000", "user_agent": "test-agent"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	upi, _ := upiRequest["upi"].(map[string]interface{})
+	if upiRequest["order_id"] != "order_upi" || upiRequest["method"] != "upi" || upi["flow"] != "collect" || upi["vpa"] != "jenny@okaxis" {
+		t.Fatalf("expected a UPI collect request for the order, got %v", upiRequest)
+	}
+	if upiRequest["email"] != "jenny@example.com" || upiRequest["contact"] != "+919900000000" || upiRequest["ip"] != "203.0.113.7" {
+		t.Fatalf("expected customer details from the charge, got %v", upiRequest)
+	}
+	if resp.Status != models.PaymentStatusRequiresAction || !resp.RequiresAction || resp.NextActionType != "upi_collect_approval" {
+		t.Fatalf("expected a pending approval next action, got %+v", resp)
+	}
+	if resp.ProviderChargeID != "order_upi" || resp.NextActionURL != "https://api.razorpay.com/v1/payments/pay_upi/status" {
+		t.Fatalf("expected the order ID and poll URL, got %+v", resp)
+	}
+}
@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/malwarebo/conductor/internal/redact"
+	"github.com/malwarebo/conductor/utils"
+)
+
+var (
+	bodyRedactor   = redact.CreateRedactor(redact.LogPaths)
+	providerLogger = utils.CreateLogger("providers")
+)
+
+// SetLogRedactionKeys masks keys in addition to redact.LogPaths in provider
+// request and response bodies before they are logged or wrapped in errors.
+// It is meant to be called once at startup.
+func SetLogRedactionKeys(keys []string) {
+	bodyRedactor = redact.CreateRedactor(append(append([]string{}, redact.LogPaths...), keys...))
+}
+
+func redactBody(body []byte) string {
+	return bodyRedactor.RedactBody(body)
+}
+
+// logProviderExchange logs one HTTP call to a provider with both bodies
+// redacted. Failed calls are logged as warnings, successful ones at debug.
+func logProviderExchange(ctx context.Context, provider, method, path string, status int, reqBody, respBody []byte) {
+	fields := map[string]interface{}{
+		"provider": provider,
+		"method":   method,
+		"path":     path,
+		"status":   status,
+	}
+	if len(reqBody) > 0 {
+		fields["request_body"] = redactBody(reqBody)
+	}
+	if len(respBody) > 0 {
+		fields["response_body"] = redactBody(respBody)
+	}
+
+	if status >= 400 {
+		providerLogger.Warn(ctx, "provider request failed", fields)
+		return
+	}
+	providerLogger.Debug(ctx, "provider request", fields)
+}
@@ -0,0 +1,72 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/redact"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestProviderErrorLogMasksClientSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/authentication/login" {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"code":"validation_error","client_secret":"int_1_secret_abc"}`))
+	}))
+	defer server.Close()
+
+	p := CreateAirwallexProvider("client", "key", true)
+	p.baseURL = server.URL
+	logged := captureLog(t)
+
+	_, err := p.GetCharge(context.Background(), "int_1")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if strings.Contains(apiErr.Body, "int_1_secret_abc") {
+		t.Fatalf("expected the client secret masked in the error, got %s", apiErr.Body)
+	}
+
+	line := logged.String()
+	if !strings.Contains(line, "provider request failed") || !strings.Contains(line, redact.Placeholder) {
+		t.Fatalf("expected a redacted log line for the failed call, got %q", line)
+	}
+	if strings.Contains(line, "int_1_secret_abc") {
+		t.Fatalf("expected the client secret masked in the log line, got %q", line)
+	}
+}
+
+func TestSetLogRedactionKeysMasksExtraKeys(t *testing.T) {
+	SetLogRedactionKeys([]string{"national_id"})
+	defer SetLogRedactionKeys(nil)
+	logged := captureLog(t)
+
+	logProviderExchange(context.Background(), "xendit", http.MethodPost, "/customers", http.StatusUnprocessableEntity,
+		[]byte(`{"national_id":"S1234567D","api_key":"xnd_live_abc"}`), nil)
+
+	line := logged.String()
+	if strings.Contains(line, "S1234567D") || strings.Contains(line, "xnd_live_abc") {
+		t.Fatalf("expected configured and built-in keys masked, got %q", line)
+	}
+}
@@ -77,7 +77,9 @@ func Retry(ctx context.Context, cfg RetryConfig, fn func() error) (*RetryResult,
 		lastErr = err
 		result.LastErr = err
 
-		if !cfg.RetryableCheck(err) {
+		// A timed-out call may still land at the provider, and retrying it
+		// would stretch the request past the operation's deadline.
+		if errors.Is(err, ErrProviderTimeout) || !cfg.RetryableCheck(err) {
 			return result, err
 		}
 
@@ -2,35 +2,51 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/malwarebo/conductor/internal/convert"
 	"github.com/malwarebo/conductor/models"
 	"github.com/stripe/stripe-go/v86"
 	stripeBalance "github.com/stripe/stripe-go/v86/balance"
+	"github.com/stripe/stripe-go/v86/billing/meter"
+	"github.com/stripe/stripe-go/v86/billing/meterevent"
+	checkoutSession "github.com/stripe/stripe-go/v86/checkout/session"
 	"github.com/stripe/stripe-go/v86/customer"
 	"github.com/stripe/stripe-go/v86/dispute"
 	stripeInvoice "github.com/stripe/stripe-go/v86/invoice"
+	"github.com/stripe/stripe-go/v86/invoiceitem"
 	"github.com/stripe/stripe-go/v86/paymentintent"
 	"github.com/stripe/stripe-go/v86/paymentmethod"
 	"github.com/stripe/stripe-go/v86/payout"
 	"github.com/stripe/stripe-go/v86/plan"
 	"github.com/stripe/stripe-go/v86/refund"
+	"github.com/stripe/stripe-go/v86/setupintent"
 	"github.com/stripe/stripe-go/v86/subscription"
+	taxCalculation "github.com/stripe/stripe-go/v86/tax/calculation"
 	"github.com/stripe/stripe-go/v86/transfer"
 	"github.com/stripe/stripe-go/v86/webhook"
 )
 
+// stripeMaxOvercapturePercent is the most card networks let Stripe capture
+// above the authorized amount, for merchants such as restaurants adding tips.
+// Stripe rejects captures beyond what the network granted on the charge.
+const stripeMaxOvercapturePercent = 20
+
 type StripeProvider struct {
 	apiKey        string
 	webhookSecret string
+	mode          Mode
 }
 
 func CreateStripeProvider(apiKey string) *StripeProvider {
 	stripe.Key = apiKey
 	return &StripeProvider{
 		apiKey: apiKey,
+		mode:   modeFromKey(apiKey, stripeTestKeyPrefixes),
 	}
 }
 
@@ -39,6 +55,7 @@ func CreateStripeProviderWithWebhook(apiKey, webhookSecret string) *StripeProvid
 	return &StripeProvider{
 		apiKey:        apiKey,
 		webhookSecret: webhookSecret,
+		mode:          modeFromKey(apiKey, stripeTestKeyPrefixes),
 	}
 }
 
@@ -46,53 +63,48 @@ func (p *StripeProvider) Name() string {
 	return "stripe"
 }
 
+func (p *StripeProvider) Mode() Mode {
+	return p.mode
+}
+
 func (p *StripeProvider) Capabilities() ProviderCapabilities {
 	return ProviderCapabilities{
+		SupportsSubscriptions:   true,
 		SupportsInvoices:        true,
 		SupportsPayouts:         true,
 		SupportsPaymentSessions: true,
 		Supports3DS:             true,
 		SupportsManualCapture:   true,
+		SupportsMultiCapture:    true,
+		SupportsPartialReversal: true,
+		SupportsOvercapture:     true,
+		MaxOvercapturePercent:   stripeMaxOvercapturePercent,
 		SupportsBalance:         true,
+		SupportsPlatformFees:    true,
 		SupportedCurrencies:     []string{"USD", "EUR", "GBP", "CAD", "AUD", "JPY", "SGD", "HKD"},
 		SupportedPaymentMethods: []models.PaymentMethodType{models.PMTypeCard, models.PMTypeBankAccount},
+		Mode:                    p.mode,
 	}
 }
 
 func (p *StripeProvider) Charge(ctx context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
-	params := &stripe.PaymentIntentParams{
-		Amount:      stripe.Int64(req.Amount),
-		Currency:    stripe.String(req.Currency),
-		Description: stripe.String(req.Description),
-		Customer:    stripe.String(req.CustomerID),
+	if req.SubAccountID != "" {
+		return nil, ErrSubAccountNotSupported
 	}
-
-	if req.PaymentMethod != "" {
-		params.PaymentMethod = stripe.String(req.PaymentMethod)
-		params.Confirm = stripe.Bool(true)
-	}
-
-	if req.CaptureMethod == models.CaptureMethodManual || (req.Capture != nil && !*req.Capture) {
-		params.CaptureMethod = stripe.String("manual")
-	}
-
-	if req.ReturnURL != "" {
-		params.ReturnURL = stripe.String(req.ReturnURL)
-	}
-
-	params.AutomaticPaymentMethods = &stripe.PaymentIntentAutomaticPaymentMethodsParams{
-		Enabled:        stripe.Bool(true),
-		AllowRedirects: stripe.String("always"),
+	if err := validateDescriptor("statement_descriptor", req.StatementDescriptor, stripeDescriptorRules); err != nil {
+		return nil, err
 	}
-
-	if req.Metadata != nil {
-		params.Metadata = ConvertMetadataToStringMap(req.Metadata)
+	if err := validateDescriptor("statement_descriptor_suffix", req.StatementDescriptorSuffix, stripeDescriptorSuffixRules); err != nil {
+		return nil, err
 	}
 
-	pi, err := paymentintent.New(params)
+	pi, err := paymentintent.New(p.buildPaymentIntentParams(req))
 	if err != nil {
 		return nil, fmt.Errorf("stripe payment intent creation failed: %w", err)
 	}
+	if pi.LastResponse != nil {
+		RecordRawResponse(ctx, "stripe", "charge", pi.LastResponse.RawJSON)
+	}
 
 	metadata := ConvertStringMapToMetadata(pi.Metadata)
 
@@ -108,20 +120,22 @@ func (p *StripeProvider) Charge(ctx context.Context, req *models.ChargeRequest)
 	}
 
 	response := &models.ChargeResponse{
-		ID:               pi.ID,
-		CustomerID:       req.CustomerID,
-		Amount:           pi.Amount,
-		Currency:         string(pi.Currency),
-		Status:           status,
-		PaymentMethod:    paymentMethodID,
-		Description:      req.Description,
-		ProviderName:     "stripe",
-		ProviderChargeID: pi.ID,
-		CaptureMethod:    captureMethod,
-		CapturedAmount:   pi.AmountReceived,
-		ClientSecret:     pi.ClientSecret,
-		Metadata:         metadata,
-		CreatedAt:        convert.UnixToTime(pi.Created),
+		ID:                   pi.ID,
+		CustomerID:           req.CustomerID,
+		Amount:               pi.Amount,
+		Currency:             string(pi.Currency),
+		Status:               status,
+		PaymentMethod:        paymentMethodID,
+		Description:          req.Description,
+		ProviderName:         "stripe",
+		ProviderChargeID:     pi.ID,
+		CaptureMethod:        captureMethod,
+		CapturedAmount:       pi.AmountReceived,
+		ApplicationFeeAmount: pi.ApplicationFeeAmount,
+		OnBehalfOf:           req.OnBehalfOf,
+		ClientSecret:         pi.ClientSecret,
+		Metadata:             metadata,
+		CreatedAt:            convert.UnixToTime(pi.Created),
 	}
 
 	if pi.NextAction != nil {
@@ -138,6 +152,120 @@ func (p *StripeProvider) Charge(ctx context.Context, req *models.ChargeRequest)
 	return response, nil
 }
 
+func (p *StripeProvider) buildPaymentIntentParams(req *models.ChargeRequest) *stripe.PaymentIntentParams {
+	params := &stripe.PaymentIntentParams{
+		Amount:      stripe.Int64(req.Amount),
+		Currency:    stripe.String(req.Currency),
+		Description: stripe.String(req.Description),
+		Customer:    stripe.String(req.CustomerID),
+	}
+
+	if req.PaymentMethod != "" {
+		params.PaymentMethod = stripe.String(req.PaymentMethod)
+		params.Confirm = stripe.Bool(true)
+	}
+
+	if req.CaptureMethod == models.CaptureMethodManual || (req.Capture != nil && !*req.Capture) {
+		params.CaptureMethod = stripe.String("manual")
+		if req.RequestMultiCapture || req.RequestOvercapture {
+			card := &stripe.PaymentIntentPaymentMethodOptionsCardParams{}
+			if req.RequestMultiCapture {
+				card.RequestMulticapture = stripe.String(string(stripe.PaymentIntentPaymentMethodOptionsCardRequestMulticaptureIfAvailable))
+			}
+			if req.RequestOvercapture {
+				card.RequestOvercapture = stripe.String(string(stripe.PaymentIntentPaymentMethodOptionsCardRequestOvercaptureIfAvailable))
+			}
+			params.PaymentMethodOptions = &stripe.PaymentIntentPaymentMethodOptionsParams{Card: card}
+		}
+	}
+
+	if req.ReturnURL != "" {
+		params.ReturnURL = stripe.String(req.ReturnURL)
+	}
+
+	if req.StatementDescriptor != "" {
+		params.StatementDescriptor = stripe.String(req.StatementDescriptor)
+	}
+	if req.StatementDescriptorSuffix != "" {
+		params.StatementDescriptorSuffix = stripe.String(req.StatementDescriptorSuffix)
+	}
+
+	// Explicit method types replace automatic payment methods; Stripe rejects
+	// requests that set both.
+	if len(req.PaymentMethodTypes) > 0 {
+		params.PaymentMethodTypes = stripe.StringSlice(req.PaymentMethodTypes)
+	} else {
+		allowRedirects := models.AllowRedirectsAlways
+		if req.AllowRedirects != "" {
+			allowRedirects = req.AllowRedirects
+		}
+		params.AutomaticPaymentMethods = &stripe.PaymentIntentAutomaticPaymentMethodsParams{
+			Enabled:        stripe.Bool(true),
+			AllowRedirects: stripe.String(string(allowRedirects)),
+		}
+	}
+
+	if req.Metadata != nil {
+		params.Metadata = ConvertMetadataToStringMap(req.Metadata)
+	}
+
+	if req.ShippingAddress != nil {
+		params.Shipping = &stripe.ShippingDetailsParams{
+			Name:    stripe.String(req.ShippingAddress.Name),
+			Address: stripeAddressParams(&req.ShippingAddress.Address),
+		}
+		if req.ShippingAddress.Phone != "" {
+			params.Shipping.Phone = stripe.String(req.ShippingAddress.Phone)
+		}
+	}
+
+	// Payment intents have no billing address of their own; it belongs to
+	// the payment method. Sending it as metadata lets Radar rules match on
+	// it without rewriting the customer's saved method.
+	if req.BillingAddress != nil {
+		if params.Metadata == nil {
+			params.Metadata = map[string]string{}
+		}
+		params.Metadata["billing_country"] = req.BillingAddress.Country
+		if req.BillingAddress.PostalCode != "" {
+			params.Metadata["billing_postal_code"] = req.BillingAddress.PostalCode
+		}
+	}
+
+	if req.ApplicationFeeAmount > 0 {
+		params.ApplicationFeeAmount = stripe.Int64(req.ApplicationFeeAmount)
+	}
+
+	if req.OnBehalfOf != "" {
+		params.OnBehalfOf = stripe.String(req.OnBehalfOf)
+		params.TransferData = &stripe.PaymentIntentTransferDataParams{
+			Destination: stripe.String(req.OnBehalfOf),
+		}
+	}
+
+	return params
+}
+
+func stripeAddressParams(address *models.Address) *stripe.AddressParams {
+	params := &stripe.AddressParams{Country: stripe.String(address.Country)}
+	if address.Line1 != "" {
+		params.Line1 = stripe.String(address.Line1)
+	}
+	if address.Line2 != "" {
+		params.Line2 = stripe.String(address.Line2)
+	}
+	if address.City != "" {
+		params.City = stripe.String(address.City)
+	}
+	if address.State != "" {
+		params.State = stripe.String(address.State)
+	}
+	if address.PostalCode != "" {
+		params.PostalCode = stripe.String(address.PostalCode)
+	}
+	return params
+}
+
 func (p *StripeProvider) mapPaymentIntentStatus(status stripe.PaymentIntentStatus) models.PaymentStatus {
 	switch status {
 	case stripe.PaymentIntentStatusSucceeded:
@@ -170,6 +298,32 @@ func (p *StripeProvider) CapturePayment(ctx context.Context, paymentID string, a
 	return nil
 }
 
+// CapturePartial keeps the authorization open only when the card network
+// granted multicapture on the charge; otherwise Stripe would release the
+// remainder, so a non-final capture is rejected before any money moves.
+func (p *StripeProvider) CapturePartial(ctx context.Context, paymentID string, amount int64, final bool) error {
+	params := &stripe.PaymentIntentCaptureParams{
+		AmountToCapture: stripe.Int64(amount),
+	}
+
+	if !final {
+		available, err := stripeMultiCaptureAvailable(paymentID)
+		if err != nil {
+			return fmt.Errorf("stripe get payment intent failed: %w", err)
+		}
+		if !available {
+			return ErrMultiCaptureUnavailable
+		}
+		params.FinalCapture = stripe.Bool(false)
+	}
+
+	_, err := captureStripePaymentIntent(paymentID, params)
+	if err != nil {
+		return fmt.Errorf("stripe partial capture failed: %w", err)
+	}
+	return nil
+}
+
 func (p *StripeProvider) VoidPayment(ctx context.Context, paymentID string) error {
 	params := &stripe.PaymentIntentCancelParams{
 		CancellationReason: stripe.String("requested_by_customer"),
@@ -182,6 +336,27 @@ func (p *StripeProvider) VoidPayment(ctx context.Context, paymentID string) erro
 	return nil
 }
 
+// ReverseAuthorization lowers the amount of an uncaptured PaymentIntent,
+// which releases that part of the hold on the card. Releasing everything
+// that is still capturable is a void, not a reversal.
+func (p *StripeProvider) ReverseAuthorization(ctx context.Context, paymentID string, amount int64) error {
+	pi, err := getStripePaymentIntent(paymentID, nil)
+	if err != nil {
+		return fmt.Errorf("stripe get payment intent failed: %w", err)
+	}
+	if amount <= 0 || amount >= pi.AmountCapturable {
+		return ErrReversalExceedsCapturable
+	}
+
+	_, err = updateStripePaymentIntent(paymentID, &stripe.PaymentIntentParams{
+		Amount: stripe.Int64(pi.Amount - amount),
+	})
+	if err != nil {
+		return fmt.Errorf("stripe authorization reversal failed: %w", err)
+	}
+	return nil
+}
+
 func (p *StripeProvider) Create3DSSession(ctx context.Context, paymentID string, returnURL string) (*ThreeDSecureSession, error) {
 	pi, err := paymentintent.Get(paymentID, nil)
 	if err != nil {
@@ -202,7 +377,30 @@ func (p *StripeProvider) Create3DSSession(ctx context.Context, paymentID string,
 }
 
 func (p *StripeProvider) Confirm3DSPayment(ctx context.Context, paymentID string) (*models.ChargeResponse, error) {
-	pi, err := paymentintent.Get(paymentID, nil)
+	return p.GetCharge(ctx, paymentID)
+}
+
+var (
+	getStripePaymentIntent     = paymentintent.Get
+	captureStripePaymentIntent = paymentintent.Capture
+	updateStripePaymentIntent  = paymentintent.Update
+)
+
+func stripeMultiCaptureAvailable(paymentID string) (bool, error) {
+	params := &stripe.PaymentIntentParams{}
+	params.AddExpand("latest_charge")
+	pi, err := getStripePaymentIntent(paymentID, params)
+	if err != nil {
+		return false, err
+	}
+	if pi.LatestCharge == nil || pi.LatestCharge.PaymentMethodDetails == nil || pi.LatestCharge.PaymentMethodDetails.Card == nil || pi.LatestCharge.PaymentMethodDetails.Card.Multicapture == nil {
+		return false, nil
+	}
+	return pi.LatestCharge.PaymentMethodDetails.Card.Multicapture.Status == stripe.ChargePaymentMethodDetailsCardMulticaptureStatusAvailable, nil
+}
+
+func (p *StripeProvider) GetCharge(ctx context.Context, providerChargeID string) (*models.ChargeResponse, error) {
+	pi, err := getStripePaymentIntent(providerChargeID, nil)
 	if err != nil {
 		return nil, fmt.Errorf("stripe get payment intent failed: %w", err)
 	}
@@ -218,13 +416,19 @@ func (p *StripeProvider) Confirm3DSPayment(ctx context.Context, paymentID string
 		paymentMethodID = pi.PaymentMethod.ID
 	}
 
+	customerID := ""
+	if pi.Customer != nil {
+		customerID = pi.Customer.ID
+	}
+
 	return &models.ChargeResponse{
 		ID:               pi.ID,
-		CustomerID:       pi.Customer.ID,
+		CustomerID:       customerID,
 		Amount:           pi.Amount,
 		Currency:         string(pi.Currency),
 		Status:           status,
 		PaymentMethod:    paymentMethodID,
+		Description:      pi.Description,
 		ProviderName:     "stripe",
 		ProviderChargeID: pi.ID,
 		CaptureMethod:    captureMethod,
@@ -409,6 +613,10 @@ func (p *StripeProvider) mapPaymentSession(pi *stripe.PaymentIntent) *models.Pay
 		session.PaymentMethodID = pi.PaymentMethod.ID
 	}
 
+	if pi.SetupFutureUsage != "" {
+		session.SetupFutureUsage = string(pi.SetupFutureUsage)
+	}
+
 	if pi.Description != "" {
 		session.Description = pi.Description
 	}
@@ -432,6 +640,64 @@ func (p *StripeProvider) mapPaymentSession(pi *stripe.PaymentIntent) *models.Pay
 	return session
 }
 
+// CreateCheckoutSession creates a Stripe Checkout Session in payment mode for
+// a single line covering the whole amount.
+func (p *StripeProvider) CreateCheckoutSession(ctx context.Context, req *models.CreateCheckoutSessionRequest) (*models.CheckoutSession, error) {
+	name := req.Description
+	if name == "" {
+		name = "Payment"
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
+		SuccessURL: stripe.String(req.SuccessURL),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{{
+			Quantity: stripe.Int64(1),
+			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+				Currency:   stripe.String(strings.ToLower(req.Currency)),
+				UnitAmount: stripe.Int64(req.Amount),
+				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+					Name: stripe.String(name),
+				},
+			},
+		}},
+	}
+
+	if req.CancelURL != "" {
+		params.CancelURL = stripe.String(req.CancelURL)
+	}
+
+	if req.CustomerID != "" {
+		params.Customer = stripe.String(req.CustomerID)
+	}
+
+	if req.Metadata != nil {
+		params.Metadata = ConvertMetadataToStringMap(req.Metadata)
+	}
+
+	sess, err := newStripeCheckoutSession(params)
+	if err != nil {
+		return nil, fmt.Errorf("stripe create checkout session failed: %w", err)
+	}
+
+	return &models.CheckoutSession{
+		ProviderName:      "stripe",
+		ProviderSessionID: sess.ID,
+		Amount:            req.Amount,
+		Currency:          req.Currency,
+		CustomerID:        req.CustomerID,
+		Description:       req.Description,
+		Status:            models.CheckoutSessionStatusOpen,
+		URL:               sess.URL,
+		SuccessURL:        req.SuccessURL,
+		CancelURL:         req.CancelURL,
+		ExpiresAt:         UnixToTimePtr(sess.ExpiresAt),
+		Metadata:          req.Metadata,
+	}, nil
+}
+
+var newStripeCheckoutSession = checkoutSession.New
+
 func (p *StripeProvider) CreateInvoice(ctx context.Context, req *models.CreateInvoiceRequest) (*models.Invoice, error) {
 	params := &stripe.InvoiceParams{
 		AutoAdvance: stripe.Bool(true),
@@ -449,20 +715,88 @@ func (p *StripeProvider) CreateInvoice(ctx context.Context, req *models.CreateIn
 		params.DueDate = stripe.Int64(req.DueDate.Unix())
 	}
 
+	if req.Currency != "" {
+		params.Currency = stripe.String(strings.ToLower(req.Currency))
+	}
+
 	if req.Metadata != nil {
 		params.Metadata = ConvertMetadataToStringMap(req.Metadata)
 	}
 
-	inv, err := stripeInvoice.New(params)
+	inv, err := newStripeInvoice(params)
 	if err != nil {
 		return nil, fmt.Errorf("stripe create invoice failed: %w", err)
 	}
 
-	return p.mapInvoice(inv), nil
+	if len(req.LineItems) == 0 {
+		return p.mapInvoice(inv), nil
+	}
+
+	for _, item := range req.LineItems {
+		itemParams := &stripe.InvoiceItemParams{
+			Customer:          stripe.String(req.CustomerID),
+			Invoice:           stripe.String(inv.ID),
+			Currency:          stripe.String(strings.ToLower(req.Currency)),
+			Description:       stripe.String(stripeLineItemDescription(item)),
+			Quantity:          stripe.Int64(item.Quantity),
+			UnitAmountDecimal: stripe.Float64(float64(item.UnitAmount)),
+		}
+		if _, err := newStripeInvoiceItem(itemParams); err != nil {
+			return nil, fmt.Errorf("stripe add invoice item to %s failed: %w", inv.ID, err)
+		}
+	}
+
+	// The draft was created empty; fetch it again so the amount reflects the
+	// items just attached.
+	inv, err = getStripeInvoice(inv.ID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("stripe get invoice failed: %w", err)
+	}
+
+	result := p.mapInvoice(inv)
+	result.LineItems = req.LineItems
+	return result, nil
+}
+
+// stripeListIter is the part of Stripe's list iterators collectStripeList
+// uses. The iterators request the next page from the API as Next is called.
+type stripeListIter interface {
+	Next() bool
+	Err() error
+}
+
+// collectStripeList drains a Stripe list iterator across every page, stopping
+// after limit items, or maxListItems when limit is unset. A page that fails to
+// load is returned as an error rather than a silently truncated list.
+func collectStripeList[T any](iter stripeListIter, current func() T, limit int) ([]T, error) {
+	if limit <= 0 || limit > maxListItems {
+		limit = maxListItems
+	}
+	var items []T
+	for len(items) < limit && iter.Next() {
+		items = append(items, current())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+var (
+	newStripeInvoice     = stripeInvoice.New
+	getStripeInvoice     = stripeInvoice.Get
+	newStripeInvoiceItem = invoiceitem.New
+)
+
+func stripeLineItemDescription(item models.InvoiceLineItem) string {
+	if item.Description == "" {
+		return item.Name
+	}
+	return item.Name + " - " + item.Description
 }
 
 func (p *StripeProvider) GetInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error) {
-	inv, err := stripeInvoice.Get(invoiceID, nil)
+	inv, err := getStripeInvoice(invoiceID, nil)
 	if err != nil {
 		return nil, fmt.Errorf("stripe get invoice failed: %w", err)
 	}
@@ -482,12 +816,15 @@ func (p *StripeProvider) ListInvoices(ctx context.Context, req *models.ListInvoi
 	}
 
 	i := stripeInvoice.List(params)
-	var invoices []*models.Invoice
-
-	for i.Next() {
-		invoices = append(invoices, p.mapInvoice(i.Invoice()))
+	items, err := collectStripeList(i, i.Invoice, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("stripe list invoices failed: %w", err)
 	}
 
+	invoices := make([]*models.Invoice, 0, len(items))
+	for _, inv := range items {
+		invoices = append(invoices, p.mapInvoice(inv))
+	}
 	return invoices, nil
 }
 
@@ -500,6 +837,21 @@ func (p *StripeProvider) CancelInvoice(ctx context.Context, invoiceID string) (*
 	return p.mapInvoice(inv), nil
 }
 
+var payStripeInvoice = stripeInvoice.Pay
+
+func (p *StripeProvider) PayInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error) {
+	inv, err := payStripeInvoice(invoiceID, nil)
+	if err != nil {
+		var stripeErr *stripe.Error
+		if errors.As(err, &stripeErr) && stripeErr.Type == stripe.ErrorTypeCard {
+			return nil, fmt.Errorf("stripe pay invoice failed: %w: %v", ErrPaymentDeclined, err)
+		}
+		return nil, fmt.Errorf("stripe pay invoice failed: %w", err)
+	}
+
+	return p.mapInvoice(inv), nil
+}
+
 func (p *StripeProvider) mapInvoice(inv *stripe.Invoice) *models.Invoice {
 	result := &models.Invoice{
 		ProviderID:   inv.ID,
@@ -552,6 +904,74 @@ func (p *StripeProvider) mapInvoiceStatus(status stripe.InvoiceStatus) models.In
 	}
 }
 
+var newStripeTaxCalculation = taxCalculation.New
+
+// CalculateTax prices tax with Stripe Tax. Line items are treated as
+// tax-exclusive, so the tax is charged on top of their amounts.
+func (p *StripeProvider) CalculateTax(ctx context.Context, req *models.CalculateTaxRequest) (*models.TaxCalculation, error) {
+	if req.Address == nil && req.CustomerID == "" {
+		return nil, ErrTaxAddressRequired
+	}
+
+	params := &stripe.TaxCalculationParams{
+		Currency: stripe.String(strings.ToLower(req.Currency)),
+	}
+	if req.Address != nil {
+		params.CustomerDetails = &stripe.TaxCalculationCustomerDetailsParams{
+			Address: &stripe.AddressParams{
+				Line1:      stripe.String(req.Address.Line1),
+				Line2:      stripe.String(req.Address.Line2),
+				City:       stripe.String(req.Address.City),
+				State:      stripe.String(req.Address.State),
+				PostalCode: stripe.String(req.Address.PostalCode),
+				Country:    stripe.String(req.Address.Country),
+			},
+			AddressSource: stripe.String("billing"),
+		}
+	} else {
+		params.Customer = stripe.String(req.CustomerID)
+	}
+	for _, item := range req.LineItems {
+		line := &stripe.TaxCalculationLineItemParams{
+			Amount:      stripe.Int64(item.Amount),
+			Reference:   stripe.String(item.Reference),
+			TaxBehavior: stripe.String("exclusive"),
+		}
+		if item.TaxCode != "" {
+			line.TaxCode = stripe.String(item.TaxCode)
+		}
+		params.LineItems = append(params.LineItems, line)
+	}
+
+	calc, err := newStripeTaxCalculation(params)
+	if err != nil {
+		return nil, fmt.Errorf("stripe tax calculation failed: %w", err)
+	}
+
+	result := &models.TaxCalculation{
+		ID:        calc.ID,
+		Currency:  strings.ToUpper(string(calc.Currency)),
+		Subtotal:  calc.AmountTotal - calc.TaxAmountExclusive,
+		TaxAmount: calc.TaxAmountExclusive,
+		Total:     calc.AmountTotal,
+	}
+	for _, b := range calc.TaxBreakdown {
+		item := models.TaxBreakdownItem{
+			Amount:           b.Amount,
+			TaxableAmount:    b.TaxableAmount,
+			TaxabilityReason: string(b.TaxabilityReason),
+		}
+		if b.TaxRateDetails != nil {
+			item.Country = b.TaxRateDetails.Country
+			item.State = b.TaxRateDetails.State
+			item.TaxType = string(b.TaxRateDetails.TaxType)
+			item.Rate = b.TaxRateDetails.PercentageDecimal
+		}
+		result.Breakdown = append(result.Breakdown, item)
+	}
+	return result, nil
+}
+
 func (p *StripeProvider) CreatePayout(ctx context.Context, req *models.CreatePayoutRequest) (*models.Payout, error) {
 	params := &stripe.TransferParams{
 		Amount:   stripe.Int64(req.Amount),
@@ -607,12 +1027,15 @@ func (p *StripeProvider) ListPayouts(ctx context.Context, req *models.ListPayout
 	}
 
 	i := payout.List(params)
-	var payouts []*models.Payout
-
-	for i.Next() {
-		payouts = append(payouts, p.mapPayout(i.Payout()))
+	items, err := collectStripeList(i, i.Payout, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("stripe list payouts failed: %w", err)
 	}
 
+	payouts := make([]*models.Payout, 0, len(items))
+	for _, po := range items {
+		payouts = append(payouts, p.mapPayout(po))
+	}
 	return payouts, nil
 }
 
@@ -698,21 +1121,44 @@ func (p *StripeProvider) GetBalance(ctx context.Context, currency string) (*mode
 	return result, nil
 }
 
+var newStripeRefund = refund.New
+
+// stripeRefundReason maps a refund reason onto the values Stripe accepts.
+// Stripe has no "other", so those refunds are sent without a reason.
+func stripeRefundReason(reason string) *string {
+	switch r := models.NormalizeRefundReason(reason); r {
+	case models.RefundReasonDuplicate, models.RefundReasonFraudulent, models.RefundReasonRequestedByCustomer:
+		return stripe.String(string(r))
+	}
+	return nil
+}
+
 func (p *StripeProvider) Refund(ctx context.Context, req *models.RefundRequest) (*models.RefundResponse, error) {
 	params := &stripe.RefundParams{
 		PaymentIntent: stripe.String(req.PaymentID),
 		Amount:        stripe.Int64(req.Amount),
-		Reason:        stripe.String(req.Reason),
+		Reason:        stripeRefundReason(req.Reason),
 	}
 
 	if req.Metadata != nil {
 		params.Metadata = ConvertMetadataToStringMap(req.Metadata)
 	}
+	if req.Note != "" {
+		params.AddMetadata("refund_note", req.Note)
+	}
+	if req.IdempotencyKey != "" {
+		// Sent as the Idempotency-Key header, so Stripe replays the first
+		// refund instead of creating another.
+		params.SetIdempotencyKey(req.IdempotencyKey)
+	}
 
-	ref, err := refund.New(params)
+	ref, err := newStripeRefund(params)
 	if err != nil {
 		return nil, err
 	}
+	if ref.LastResponse != nil {
+		RecordRawResponse(ctx, "stripe", "refund", ref.LastResponse.RawJSON)
+	}
 
 	metadata := ConvertStringMapToMetadata(ref.Metadata)
 
@@ -722,7 +1168,8 @@ func (p *StripeProvider) Refund(ctx context.Context, req *models.RefundRequest)
 		Amount:           ref.Amount,
 		Currency:         string(ref.Currency),
 		Status:           string(ref.Status),
-		Reason:           req.Reason,
+		Reason:           string(models.NormalizeRefundReason(req.Reason)),
+		Note:             req.Note,
 		ProviderName:     "stripe",
 		ProviderRefundID: ref.ID,
 		Metadata:         metadata,
@@ -796,9 +1243,69 @@ func (p *StripeProvider) CreateSubscription(ctx context.Context, req *models.Cre
 		result.TrialEnd = &trialEnd
 	}
 
+	if sub.Items != nil && len(sub.Items.Data) > 0 {
+		result.SubscriptionItemID = sub.Items.Data[0].ID
+	}
+
 	return result, nil
 }
 
+var createStripeMeterEvent = func(params *stripe.BillingMeterEventParams) (*stripe.BillingMeterEvent, error) {
+	return meterevent.New(params)
+}
+
+var createStripeMeter = func(params *stripe.BillingMeterParams) (*stripe.BillingMeter, error) {
+	return meter.New(params)
+}
+
+// RecordUsage reports usage as a billing meter event; the legacy usage
+// records endpoint was removed in API version 2025-03-31.
+func (p *StripeProvider) RecordUsage(ctx context.Context, usage *models.UsageEvent) (*models.UsageRecord, error) {
+	if usage.EventName == "" {
+		return nil, ErrMeterNotConfigured
+	}
+
+	params := &stripe.BillingMeterEventParams{
+		EventName: stripe.String(usage.EventName),
+		Payload: map[string]string{
+			"stripe_customer_id": usage.CustomerID,
+			"value":              strconv.FormatInt(usage.Quantity, 10),
+		},
+		Timestamp: stripe.Int64(usage.Timestamp.Unix()),
+	}
+
+	event, err := createStripeMeterEvent(params)
+	if err != nil {
+		return nil, fmt.Errorf("stripe meter event creation failed: %w", err)
+	}
+
+	return &models.UsageRecord{
+		ID:                 event.Identifier,
+		SubscriptionID:     usage.SubscriptionID,
+		SubscriptionItemID: usage.SubscriptionItemID,
+		Quantity:           usage.Quantity,
+		Timestamp:          time.Unix(event.Timestamp, 0),
+		ProviderName:       "stripe",
+	}, nil
+}
+
+func stripeMeterEventName(planName string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(planName) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	slug := strings.Trim(b.String(), "_")
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+	return fmt.Sprintf("conductor_%s_%s", slug, strconv.FormatInt(time.Now().UnixNano(), 36))
+}
+
 func (p *StripeProvider) UpdateSubscription(ctx context.Context, subscriptionID string, req *models.UpdateSubscriptionRequest) (*models.Subscription, error) {
 	params := &stripe.SubscriptionParams{}
 
@@ -884,18 +1391,14 @@ func (p *StripeProvider) CancelSubscription(ctx context.Context, subscriptionID
 		return nil, err
 	}
 
-	canceledAt := time.Now()
-	if sub.CancelAt > 0 {
-		canceledAt = time.Unix(sub.CancelAt, 0)
-	}
-
 	result := &models.Subscription{
 		ID:                 sub.ID,
 		CustomerID:         sub.Customer.ID,
 		Status:             models.SubscriptionStatus(sub.Status),
 		CurrentPeriodStart: time.Unix(sub.Created, 0),
 		CurrentPeriodEnd:   time.Unix(sub.CanceledAt, 0),
-		CanceledAt:         &canceledAt,
+		CancelAt:           convert.UnixToTimePtr(sub.CancelAt),
+		CanceledAt:         convert.UnixToTimePtr(sub.CanceledAt),
 		ProviderName:       "stripe",
 		UpdatedAt:          time.Now(),
 	}
@@ -934,10 +1437,13 @@ func (p *StripeProvider) ListSubscriptions(ctx context.Context, customerID strin
 	}
 
 	i := subscription.List(params)
-	var subscriptions []*models.Subscription
+	items, err := collectStripeList(i, i.Subscription, 0)
+	if err != nil {
+		return nil, fmt.Errorf("stripe list subscriptions failed: %w", err)
+	}
 
-	for i.Next() {
-		sub := i.Subscription()
+	subscriptions := make([]*models.Subscription, 0, len(items))
+	for _, sub := range items {
 		result := &models.Subscription{
 			ID:                 sub.ID,
 			CustomerID:         sub.Customer.ID,
@@ -973,6 +1479,23 @@ func (p *StripeProvider) CreatePlan(ctx context.Context, planReq *models.Plan) (
 		params.TrialPeriodDays = stripe.Int64(int64(planReq.TrialDays))
 	}
 
+	var meterEventName string
+	if planReq.PricingType == models.PricingTypeMetered {
+		meterEventName = stripeMeterEventName(planReq.Name)
+		m, err := createStripeMeter(&stripe.BillingMeterParams{
+			DisplayName: stripe.String(planReq.Name),
+			EventName:   stripe.String(meterEventName),
+			DefaultAggregation: &stripe.BillingMeterDefaultAggregationParams{
+				Formula: stripe.String(string(stripe.BillingMeterDefaultAggregationFormulaSum)),
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("stripe meter creation failed: %w", err)
+		}
+		params.UsageType = stripe.String(string(stripe.PlanUsageTypeMetered))
+		params.Meter = stripe.String(m.ID)
+	}
+
 	if planReq.Metadata != nil {
 		params.Metadata = ConvertInterfaceMetadataToStringMap(planReq.Metadata)
 	}
@@ -983,18 +1506,19 @@ func (p *StripeProvider) CreatePlan(ctx context.Context, planReq *models.Plan) (
 	}
 
 	result := &models.Plan{
-		ID:            stripePlan.ID,
-		Name:          planReq.Name,
-		Description:   planReq.Description,
-		Amount:        float64(stripePlan.Amount) / 100,
-		Currency:      string(stripePlan.Currency),
-		BillingPeriod: models.BillingPeriod(stripePlan.Interval),
-		PricingType:   models.PricingTypeFixed,
-		TrialDays:     planReq.TrialDays,
-		Features:      planReq.Features,
-		Metadata:      planReq.Metadata,
-		CreatedAt:     time.Unix(stripePlan.Created, 0),
-		UpdatedAt:     time.Unix(stripePlan.Created, 0),
+		ID:             stripePlan.ID,
+		Name:           planReq.Name,
+		Description:    planReq.Description,
+		Amount:         float64(stripePlan.Amount) / 100,
+		Currency:       string(stripePlan.Currency),
+		BillingPeriod:  models.BillingPeriod(stripePlan.Interval),
+		PricingType:    p.mapPlanPricingType(stripePlan.UsageType),
+		TrialDays:      planReq.TrialDays,
+		MeterEventName: meterEventName,
+		Features:       planReq.Features,
+		Metadata:       planReq.Metadata,
+		CreatedAt:      time.Unix(stripePlan.Created, 0),
+		UpdatedAt:      time.Unix(stripePlan.Created, 0),
 	}
 
 	return result, nil
@@ -1025,7 +1549,7 @@ func (p *StripeProvider) UpdatePlan(ctx context.Context, planID string, planReq
 		Amount:        float64(stripePlan.Amount) / 100,
 		Currency:      string(stripePlan.Currency),
 		BillingPeriod: models.BillingPeriod(stripePlan.Interval),
-		PricingType:   models.PricingTypeFixed,
+		PricingType:   p.mapPlanPricingType(stripePlan.UsageType),
 		TrialDays:     planReq.TrialDays,
 		Features:      planReq.Features,
 		Metadata:      planReq.Metadata,
@@ -1035,6 +1559,13 @@ func (p *StripeProvider) UpdatePlan(ctx context.Context, planID string, planReq
 	return result, nil
 }
 
+func (p *StripeProvider) mapPlanPricingType(usageType stripe.PlanUsageType) models.PricingType {
+	if usageType == stripe.PlanUsageTypeMetered {
+		return models.PricingTypeMetered
+	}
+	return models.PricingTypeFixed
+}
+
 func (p *StripeProvider) DeletePlan(ctx context.Context, planID string) error {
 	_, err := plan.Del(planID, nil)
 	return err
@@ -1053,7 +1584,7 @@ func (p *StripeProvider) GetPlan(ctx context.Context, planID string) (*models.Pl
 		Amount:        float64(stripePlan.Amount) / 100,
 		Currency:      string(stripePlan.Currency),
 		BillingPeriod: models.BillingPeriod(stripePlan.Interval),
-		PricingType:   models.PricingTypeFixed,
+		PricingType:   p.mapPlanPricingType(stripePlan.UsageType),
 		TrialDays:     int(stripePlan.TrialPeriodDays),
 		Features:      []string{},
 		Metadata:      nil,
@@ -1082,7 +1613,7 @@ func (p *StripeProvider) ListPlans(ctx context.Context) ([]*models.Plan, error)
 			Amount:        float64(stripePlan.Amount) / 100,
 			Currency:      string(stripePlan.Currency),
 			BillingPeriod: models.BillingPeriod(stripePlan.Interval),
-			PricingType:   models.PricingTypeFixed,
+			PricingType:   p.mapPlanPricingType(stripePlan.UsageType),
 			TrialDays:     int(stripePlan.TrialPeriodDays),
 			Features:      []string{},
 			Metadata:      nil,
@@ -1384,8 +1915,30 @@ func (p *StripeProvider) DeleteCustomer(ctx context.Context, customerID string)
 	return err
 }
 
+var (
+	getStripePaymentMethod    = paymentmethod.Get
+	createStripePaymentMethod = paymentmethod.New
+	createStripeSetupIntent   = setupintent.New
+	verifyStripeMicrodeposits = setupintent.VerifyMicrodeposits
+)
+
+var findStripeSetupIntent = func(paymentMethodID string) (*stripe.SetupIntent, error) {
+	i := setupintent.List(&stripe.SetupIntentListParams{PaymentMethod: stripe.String(paymentMethodID)})
+	if i.Next() {
+		return i.SetupIntent(), nil
+	}
+	if err := i.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrVerificationNotPending
+}
+
 func (p *StripeProvider) CreatePaymentMethod(ctx context.Context, req *models.CreatePaymentMethodRequest) (*models.PaymentMethod, error) {
-	pm, err := paymentmethod.Get(req.CardToken, nil)
+	if req.Type == models.PMTypeBankAccount {
+		return p.createBankAccountPaymentMethod(req)
+	}
+
+	pm, err := getStripePaymentMethod(req.CardToken, nil)
 	if err != nil {
 		return nil, fmt.Errorf("stripe payment method get failed: %w", err)
 	}
@@ -1396,24 +1949,196 @@ func (p *StripeProvider) CreatePaymentMethod(ctx context.Context, req *models.Cr
 		ProviderPaymentMethodID: pm.ID,
 		Type:                    req.Type,
 		Reusable:                req.Reusable,
-		Status:                  "active",
+		Status:                  models.PaymentMethodStatusActive,
+		IsDefault:               req.IsDefault,
+		Metadata:                req.Metadata,
+		CreatedAt:               time.Unix(pm.Created, 0),
+	}
+	applyStripePaymentMethodDetails(result, pm)
+
+	return result, nil
+}
+
+// createBankAccountPaymentMethod saves a US bank account for ACH debits. The
+// account is confirmed through a SetupIntent so Stripe records the mandate;
+// when instant verification is unavailable the method stays in
+// requires_verification until the micro-deposit amounts are confirmed.
+func (p *StripeProvider) createBankAccountPaymentMethod(req *models.CreatePaymentMethodRequest) (*models.PaymentMethod, error) {
+	var pm *stripe.PaymentMethod
+	var err error
+	switch {
+	case req.CardToken != "":
+		pm, err = getStripePaymentMethod(req.CardToken, nil)
+	case req.BankAccount != nil:
+		pm, err = createStripePaymentMethod(buildStripeBankAccountParams(req.BankAccount))
+	default:
+		return nil, ErrBankAccountRequired
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stripe bank account payment method failed: %w", err)
+	}
+
+	si, err := createStripeSetupIntent(buildStripeBankAccountSetupIntentParams(req, pm.ID))
+	if err != nil {
+		return nil, fmt.Errorf("stripe bank account setup failed: %w", err)
+	}
+
+	result := &models.PaymentMethod{
+		CustomerID:              req.CustomerID,
+		ProviderName:            "stripe",
+		ProviderPaymentMethodID: pm.ID,
+		Type:                    models.PMTypeBankAccount,
+		Reusable:                true,
 		IsDefault:               req.IsDefault,
 		Metadata:                req.Metadata,
 		CreatedAt:               time.Unix(pm.Created, 0),
 	}
+	applyStripePaymentMethodDetails(result, pm)
+	applyStripeSetupIntentStatus(result, si)
+
+	return result, nil
+}
+
+func (p *StripeProvider) VerifyPaymentMethod(ctx context.Context, paymentMethodID string, amounts []int64) (*models.PaymentMethod, error) {
+	si, err := findStripeSetupIntent(paymentMethodID)
+	if err != nil {
+		return nil, err
+	}
+	if si.Status != stripe.SetupIntentStatusRequiresAction {
+		return nil, ErrVerificationNotPending
+	}
 
+	si, err = verifyStripeMicrodeposits(si.ID, &stripe.SetupIntentVerifyMicrodepositsParams{
+		Amounts: stripe.Int64Slice(amounts),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stripe micro-deposit verification failed: %w", err)
+	}
+
+	pm, err := getStripePaymentMethod(paymentMethodID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PaymentMethod{
+		ProviderName:            "stripe",
+		ProviderPaymentMethodID: pm.ID,
+		Type:                    models.PMTypeBankAccount,
+		Reusable:                true,
+		CreatedAt:               time.Unix(pm.Created, 0),
+	}
+	if si.Customer != nil {
+		result.CustomerID = si.Customer.ID
+	}
+	applyStripePaymentMethodDetails(result, pm)
+	applyStripeSetupIntentStatus(result, si)
+
+	return result, nil
+}
+
+func buildStripeBankAccountParams(account *models.BankAccountDetails) *stripe.PaymentMethodParams {
+	holderType := account.AccountHolderType
+	if holderType == "" {
+		holderType = "individual"
+	}
+	accountType := account.AccountType
+	if accountType == "" {
+		accountType = "checking"
+	}
+
+	return &stripe.PaymentMethodParams{
+		Type: stripe.String("us_bank_account"),
+		USBankAccount: &stripe.PaymentMethodUSBankAccountParams{
+			AccountHolderType: stripe.String(holderType),
+			AccountType:       stripe.String(accountType),
+			RoutingNumber:     stripe.String(account.RoutingNumber),
+			AccountNumber:     stripe.String(account.AccountNumber),
+		},
+		BillingDetails: &stripe.PaymentMethodBillingDetailsParams{
+			Name: stripe.String(account.AccountHolderName),
+		},
+	}
+}
+
+func buildStripeBankAccountSetupIntentParams(req *models.CreatePaymentMethodRequest, paymentMethodID string) *stripe.SetupIntentParams {
+	params := &stripe.SetupIntentParams{
+		PaymentMethod:      stripe.String(paymentMethodID),
+		PaymentMethodTypes: stripe.StringSlice([]string{"us_bank_account"}),
+		Usage:              stripe.String("off_session"),
+		Confirm:            stripe.Bool(true),
+		PaymentMethodOptions: &stripe.SetupIntentPaymentMethodOptionsParams{
+			USBankAccount: &stripe.SetupIntentPaymentMethodOptionsUSBankAccountParams{
+				VerificationMethod: stripe.String("automatic"),
+			},
+		},
+	}
+	if req.CustomerID != "" {
+		params.Customer = stripe.String(req.CustomerID)
+	}
+
+	acceptance := &stripe.SetupIntentMandateDataCustomerAcceptanceParams{
+		Type:    stripe.MandateCustomerAcceptanceTypeOffline,
+		Offline: &stripe.SetupIntentMandateDataCustomerAcceptanceOfflineParams{},
+	}
+	if req.MandateIPAddress != "" && req.MandateUserAgent != "" {
+		acceptance = &stripe.SetupIntentMandateDataCustomerAcceptanceParams{
+			Type: stripe.MandateCustomerAcceptanceTypeOnline,
+			Online: &stripe.SetupIntentMandateDataCustomerAcceptanceOnlineParams{
+				IPAddress: stripe.String(req.MandateIPAddress),
+				UserAgent: stripe.String(req.MandateUserAgent),
+			},
+		}
+	}
+	params.MandateData = &stripe.SetupIntentMandateDataParams{CustomerAcceptance: acceptance}
+
+	return params
+}
+
+func applyStripeSetupIntentStatus(result *models.PaymentMethod, si *stripe.SetupIntent) {
+	switch si.Status {
+	case stripe.SetupIntentStatusSucceeded:
+		result.Status = models.PaymentMethodStatusActive
+	case stripe.SetupIntentStatusRequiresAction:
+		result.Status = models.PaymentMethodStatusRequiresVerification
+		if si.NextAction != nil && si.NextAction.VerifyWithMicrodeposits != nil {
+			result.VerificationURL = si.NextAction.VerifyWithMicrodeposits.HostedVerificationURL
+		}
+	case stripe.SetupIntentStatusCanceled, stripe.SetupIntentStatusRequiresPaymentMethod:
+		result.Status = models.PaymentMethodStatusVerificationFailed
+	default:
+		result.Status = models.PaymentMethodStatusPending
+	}
+}
+
+func applyStripePaymentMethodDetails(result *models.PaymentMethod, pm *stripe.PaymentMethod) {
 	if pm.Card != nil {
 		result.Last4 = pm.Card.Last4
 		result.Brand = string(pm.Card.Brand)
 		result.ExpMonth = int(pm.Card.ExpMonth)
 		result.ExpYear = int(pm.Card.ExpYear)
+		result.Fingerprint = pm.Card.Fingerprint
+	}
+	if pm.USBankAccount != nil {
+		result.Type = models.PMTypeBankAccount
+		result.Last4 = pm.USBankAccount.Last4
+		result.Fingerprint = pm.USBankAccount.Fingerprint
+		result.Brand = pm.USBankAccount.BankName
+		result.BankCode = pm.USBankAccount.RoutingNumber
+		if pm.BillingDetails != nil {
+			result.AccountName = pm.BillingDetails.Name
+		}
 	}
+}
 
-	return result, nil
+func stripePaymentMethodType(pmType models.PaymentMethodType) string {
+	if pmType == models.PMTypeBankAccount {
+		return "us_bank_account"
+	}
+	return string(pmType)
 }
 
 func (p *StripeProvider) GetPaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethod, error) {
-	pm, err := paymentmethod.Get(paymentMethodID, nil)
+	pm, err := getStripePaymentMethod(paymentMethodID, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1428,16 +2153,10 @@ func (p *StripeProvider) GetPaymentMethod(ctx context.Context, paymentMethodID s
 		ProviderName:            "stripe",
 		ProviderPaymentMethodID: pm.ID,
 		Type:                    models.PaymentMethodType(pm.Type),
-		Status:                  "active",
+		Status:                  models.PaymentMethodStatusActive,
 		CreatedAt:               time.Unix(pm.Created, 0),
 	}
-
-	if pm.Card != nil {
-		result.Last4 = pm.Card.Last4
-		result.Brand = string(pm.Card.Brand)
-		result.ExpMonth = int(pm.Card.ExpMonth)
-		result.ExpYear = int(pm.Card.ExpYear)
-	}
+	applyStripePaymentMethodDetails(result, pm)
 
 	return result, nil
 }
@@ -1449,7 +2168,7 @@ func (p *StripeProvider) ListPaymentMethods(ctx context.Context, customerID stri
 	}
 
 	if pmType != nil {
-		params.Type = stripe.String(string(*pmType))
+		params.Type = stripe.String(stripePaymentMethodType(*pmType))
 	}
 
 	i := paymentmethod.List(params)
@@ -1462,16 +2181,10 @@ func (p *StripeProvider) ListPaymentMethods(ctx context.Context, customerID stri
 			ProviderName:            "stripe",
 			ProviderPaymentMethodID: pm.ID,
 			Type:                    models.PaymentMethodType(pm.Type),
-			Status:                  "active",
+			Status:                  models.PaymentMethodStatusActive,
 			CreatedAt:               time.Unix(pm.Created, 0),
 		}
-
-		if pm.Card != nil {
-			result.Last4 = pm.Card.Last4
-			result.Brand = string(pm.Card.Brand)
-			result.ExpMonth = int(pm.Card.ExpMonth)
-			result.ExpYear = int(pm.Card.ExpYear)
-		}
+		applyStripePaymentMethodDetails(result, pm)
 
 		paymentMethods = append(paymentMethods, result)
 	}
@@ -1501,7 +2214,7 @@ func (p *StripeProvider) ExpirePaymentMethod(ctx context.Context, paymentMethodI
 	return &models.PaymentMethod{
 		ProviderPaymentMethodID: paymentMethodID,
 		ProviderName:            "stripe",
-		Status:                  "expired",
+		Status:                  models.PaymentMethodStatusExpired,
 	}, nil
 }
 
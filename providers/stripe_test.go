@@ -0,0 +1,822 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/stripe/stripe-go/v86"
+)
+
+func TestStripePaymentIntentParamsIncludePlatformFees(t *testing.T) {
+	p := &StripeProvider{}
+	params := p.buildPaymentIntentParams(&models.ChargeRequest{
+		CustomerID:           "cus_123",
+		Amount:               10000,
+		Currency:             "usd",
+		PaymentMethod:        "pm_card_visa",
+		ApplicationFeeAmount: 250,
+		OnBehalfOf:           "acct_connected",
+	})
+
+	if params.ApplicationFeeAmount == nil || *params.ApplicationFeeAmount != 250 {
+		t.Fatalf("expected application fee amount 250, got %v", params.ApplicationFeeAmount)
+	}
+	if params.OnBehalfOf == nil || *params.OnBehalfOf != "acct_connected" {
+		t.Fatalf("expected on_behalf_of acct_connected, got %v", params.OnBehalfOf)
+	}
+	if params.TransferData == nil || params.TransferData.Destination == nil || *params.TransferData.Destination != "acct_connected" {
+		t.Fatal("expected transfer_data.destination to be acct_connected")
+	}
+}
+
+func TestStripePaymentIntentParamsOmitPlatformFeesWhenUnset(t *testing.T) {
+	p := &StripeProvider{}
+	params := p.buildPaymentIntentParams(&models.ChargeRequest{
+		CustomerID: "cus_123",
+		Amount:     10000,
+		Currency:   "usd",
+	})
+
+	if params.ApplicationFeeAmount != nil {
+		t.Fatalf("expected no application fee amount, got %d", *params.ApplicationFeeAmount)
+	}
+	if params.OnBehalfOf != nil || params.TransferData != nil {
+		t.Fatal("expected no connected account params")
+	}
+}
+
+func TestProvidersWithoutPlatformFeesRejectCharge(t *testing.T) {
+	req := &models.ChargeRequest{
+		CustomerID:           "cus_123",
+		Amount:               10000,
+		Currency:             "INR",
+		PaymentMethod:        "card",
+		ApplicationFeeAmount: 250,
+		OnBehalfOf:           "acct_connected",
+	}
+
+	for _, provider := range []PaymentProvider{
+		CreateRazorpayProvider("key", "secret"),
+		CreateAirwallexProvider("client", "key", true),
+	} {
+		if _, err := provider.Charge(context.Background(), req); !errors.Is(err, ErrPlatformFeesNotSupported) {
+			t.Fatalf("%s: expected ErrPlatformFeesNotSupported, got %v", provider.Name(), err)
+		}
+	}
+}
+
+func TestStripeRecordUsageCreatesMeterEvent(t *testing.T) {
+	original := createStripeMeterEvent
+	defer func() { createStripeMeterEvent = original }()
+
+	var gotParams *stripe.BillingMeterEventParams
+	createStripeMeterEvent = func(params *stripe.BillingMeterEventParams) (*stripe.BillingMeterEvent, error) {
+		gotParams = params
+		return &stripe.BillingMeterEvent{
+			Identifier: "evt_ident_123",
+			EventName:  *params.EventName,
+			Payload:    params.Payload,
+			Timestamp:  *params.Timestamp,
+		}, nil
+	}
+
+	timestamp := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	p := &StripeProvider{}
+	record, err := p.RecordUsage(context.Background(), &models.UsageEvent{
+		SubscriptionID:     "sub_123",
+		SubscriptionItemID: "si_123",
+		CustomerID:         "cus_123",
+		EventName:          "conductor_api_calls",
+		Quantity:           42,
+		Timestamp:          timestamp,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *gotParams.EventName != "conductor_api_calls" || *gotParams.Timestamp != timestamp.Unix() {
+		t.Fatalf("unexpected meter event params: event=%s timestamp=%d", *gotParams.EventName, *gotParams.Timestamp)
+	}
+	if gotParams.Payload["stripe_customer_id"] != "cus_123" || gotParams.Payload["value"] != "42" {
+		t.Fatalf("unexpected meter event payload: %v", gotParams.Payload)
+	}
+	if record.ID != "evt_ident_123" || record.Quantity != 42 || record.SubscriptionItemID != "si_123" || !record.Timestamp.Equal(timestamp) || record.ProviderName != "stripe" {
+		t.Fatalf("unexpected usage record: %+v", record)
+	}
+}
+
+func TestStripeRecordUsageRequiresMeter(t *testing.T) {
+	p := &StripeProvider{}
+	_, err := p.RecordUsage(context.Background(), &models.UsageEvent{CustomerID: "cus_123", Quantity: 1, Timestamp: time.Now()})
+	if !errors.Is(err, ErrMeterNotConfigured) {
+		t.Fatalf("expected ErrMeterNotConfigured, got %v", err)
+	}
+}
+
+func TestStripeRecordUsageWrapsProviderError(t *testing.T) {
+	original := createStripeMeterEvent
+	defer func() { createStripeMeterEvent = original }()
+
+	providerErr := errors.New("no such customer")
+	createStripeMeterEvent = func(*stripe.BillingMeterEventParams) (*stripe.BillingMeterEvent, error) {
+		return nil, providerErr
+	}
+
+	p := &StripeProvider{}
+	usage := &models.UsageEvent{CustomerID: "cus_missing", EventName: "conductor_api_calls", Quantity: 1, Timestamp: time.Now()}
+	if _, err := p.RecordUsage(context.Background(), usage); !errors.Is(err, providerErr) {
+		t.Fatalf("expected wrapped provider error, got %v", err)
+	}
+}
+
+func TestStripePayInvoiceClassifiesDeclines(t *testing.T) {
+	original := payStripeInvoice
+	defer func() { payStripeInvoice = original }()
+
+	p := &StripeProvider{}
+	payStripeInvoice = func(string, *stripe.InvoicePayParams) (*stripe.Invoice, error) {
+		return nil, &stripe.Error{Type: stripe.ErrorTypeCard, Code: stripe.ErrorCodeCardDeclined}
+	}
+	if _, err := p.PayInvoice(context.Background(), "in_1"); !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("expected ErrPaymentDeclined for a card error, got %v", err)
+	}
+
+	payStripeInvoice = func(string, *stripe.InvoicePayParams) (*stripe.Invoice, error) {
+		return nil, &stripe.Error{Type: stripe.ErrorTypeAPI}
+	}
+	if _, err := p.PayInvoice(context.Background(), "in_1"); err == nil || errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("expected an API error not to be treated as a decline, got %v", err)
+	}
+}
+
+func TestStripeMeterEventNameIsSlugged(t *testing.T) {
+	name := stripeMeterEventName("Pro API -- Calls!")
+	if !strings.HasPrefix(name, "conductor_pro_api_calls_") {
+		t.Fatalf("unexpected meter event name %q", name)
+	}
+}
+
+func TestStripeGetChargeMapsPaymentIntent(t *testing.T) {
+	original := getStripePaymentIntent
+	defer func() { getStripePaymentIntent = original }()
+
+	getStripePaymentIntent = func(id string, params *stripe.PaymentIntentParams) (*stripe.PaymentIntent, error) {
+		if id != "pi_123" {
+			t.Fatalf("unexpected payment intent id %s", id)
+		}
+		return &stripe.PaymentIntent{
+			ID:             "pi_123",
+			Amount:         5000,
+			AmountReceived: 5000,
+			Currency:       stripe.CurrencyUSD,
+			Status:         stripe.PaymentIntentStatusSucceeded,
+			Customer:       &stripe.Customer{ID: "cus_1"},
+		}, nil
+	}
+
+	p := &StripeProvider{}
+	charge, err := p.GetCharge(context.Background(), "pi_123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if charge.Status != models.PaymentStatusSuccess || charge.CustomerID != "cus_1" || charge.CapturedAmount != 5000 {
+		t.Fatalf("unexpected charge: %+v", charge)
+	}
+}
+
+func stubStripeBankAccount(t *testing.T) {
+	t.Helper()
+	originalGet, originalCreate := getStripePaymentMethod, createStripePaymentMethod
+	originalSetup, originalFind, originalVerify := createStripeSetupIntent, findStripeSetupIntent, verifyStripeMicrodeposits
+	t.Cleanup(func() {
+		getStripePaymentMethod, createStripePaymentMethod = originalGet, originalCreate
+		createStripeSetupIntent, findStripeSetupIntent, verifyStripeMicrodeposits = originalSetup, originalFind, originalVerify
+	})
+
+	bankAccount := &stripe.PaymentMethod{
+		ID:   "pm_bank",
+		Type: stripe.PaymentMethodTypeUSBankAccount,
+		USBankAccount: &stripe.PaymentMethodUSBankAccount{
+			BankName:      "STRIPE TEST BANK",
+			Last4:         "6789",
+			RoutingNumber: "110000000",
+		},
+		BillingDetails: &stripe.PaymentMethodBillingDetails{Name: "Jenny Rosen"},
+	}
+	getStripePaymentMethod = func(string, *stripe.PaymentMethodParams) (*stripe.PaymentMethod, error) {
+		return bankAccount, nil
+	}
+	createStripePaymentMethod = func(*stripe.PaymentMethodParams) (*stripe.PaymentMethod, error) {
+		return bankAccount, nil
+	}
+}
+
+func pendingMicrodepositSetupIntent() *stripe.SetupIntent {
+	return &stripe.SetupIntent{
+		ID:       "seti_123",
+		Status:   stripe.SetupIntentStatusRequiresAction,
+		Customer: &stripe.Customer{ID: "cus_1"},
+		NextAction: &stripe.SetupIntentNextAction{
+			Type: stripe.SetupIntentNextActionTypeVerifyWithMicrodeposits,
+			VerifyWithMicrodeposits: &stripe.SetupIntentNextActionVerifyWithMicrodeposits{
+				HostedVerificationURL: "https://payments.stripe.com/microdeposit/seti_123",
+			},
+		},
+	}
+}
+
+func TestStripeCreateBankAccountRequiresMicrodepositVerification(t *testing.T) {
+	stubStripeBankAccount(t)
+
+	var createParams *stripe.PaymentMethodParams
+	createStripePaymentMethod = func(params *stripe.PaymentMethodParams) (*stripe.PaymentMethod, error) {
+		createParams = params
+		return getStripePaymentMethod("pm_bank", nil)
+	}
+	var setupParams *stripe.SetupIntentParams
+	createStripeSetupIntent = func(params *stripe.SetupIntentParams) (*stripe.SetupIntent, error) {
+		setupParams = params
+		return pendingMicrodepositSetupIntent(), nil
+	}
+
+	p := &StripeProvider{}
+	pm, err := p.CreatePaymentMethod(context.Background(), &models.CreatePaymentMethodRequest{
+		CustomerID: "cus_1",
+		Type:       models.PMTypeBankAccount,
+		BankAccount: &models.BankAccountDetails{
+			AccountHolderName: "Jenny Rosen",
+			RoutingNumber:     "110000000",
+			AccountNumber:     "000123456789",
+		},
+		MandateIPAddress: "203.0.113.7",
+		MandateUserAgent: "test-agent",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if createParams == nil || *createParams.Type != "us_bank_account" || *createParams.USBankAccount.AccountType != "checking" {
+		t.Fatalf("expected a us_bank_account checking payment method, got %+v", createParams)
+	}
+	if setupParams == nil || *setupParams.PaymentMethod != "pm_bank" || *setupParams.Customer != "cus_1" || !*setupParams.Confirm {
+		t.Fatalf("expected a confirmed setup intent for pm_bank, got %+v", setupParams)
+	}
+	if acceptance := setupParams.MandateData.CustomerAcceptance; acceptance.Type != stripe.MandateCustomerAcceptanceTypeOnline || *acceptance.Online.IPAddress != "203.0.113.7" {
+		t.Fatalf("expected online mandate acceptance, got %+v", acceptance)
+	}
+	if pm.Status != models.PaymentMethodStatusRequiresVerification || pm.VerificationURL == "" {
+		t.Fatalf("expected requires_verification with a hosted verification URL, got %+v", pm)
+	}
+	if pm.Type != models.PMTypeBankAccount || pm.Last4 != "6789" || pm.BankCode != "110000000" || pm.AccountName != "Jenny Rosen" {
+		t.Fatalf("unexpected bank account details: %+v", pm)
+	}
+}
+
+func TestStripeGetPaymentMethodCapturesCardFingerprint(t *testing.T) {
+	originalGet := getStripePaymentMethod
+	t.Cleanup(func() { getStripePaymentMethod = originalGet })
+	getStripePaymentMethod = func(string, *stripe.PaymentMethodParams) (*stripe.PaymentMethod, error) {
+		return &stripe.PaymentMethod{
+			ID:       "pm_card",
+			Type:     stripe.PaymentMethodTypeCard,
+			Customer: &stripe.Customer{ID: "cus_1"},
+			Card: &stripe.PaymentMethodCard{
+				Brand:       stripe.PaymentMethodCardBrandVisa,
+				Last4:       "4242",
+				Fingerprint: "fp_visa_4242",
+			},
+		}, nil
+	}
+
+	p := &StripeProvider{}
+	pm, err := p.GetPaymentMethod(context.Background(), "pm_card")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pm.Fingerprint != "fp_visa_4242" || pm.Last4 != "4242" {
+		t.Fatalf("expected the card fingerprint to be captured, got %+v", pm)
+	}
+}
+
+func TestStripeCreateBankAccountRequiresDetails(t *testing.T) {
+	p := &StripeProvider{}
+	_, err := p.CreatePaymentMethod(context.Background(), &models.CreatePaymentMethodRequest{
+		CustomerID: "cus_1",
+		Type:       models.PMTypeBankAccount,
+	})
+	if !errors.Is(err, ErrBankAccountRequired) {
+		t.Fatalf("expected ErrBankAccountRequired, got %v", err)
+	}
+}
+
+func TestStripeVerifyBankAccountActivatesPaymentMethod(t *testing.T) {
+	stubStripeBankAccount(t)
+
+	findStripeSetupIntent = func(paymentMethodID string) (*stripe.SetupIntent, error) {
+		if paymentMethodID != "pm_bank" {
+			t.Fatalf("unexpected payment method %s", paymentMethodID)
+		}
+		return pendingMicrodepositSetupIntent(), nil
+	}
+	var verifiedAmounts []int64
+	verifyStripeMicrodeposits = func(id string, params *stripe.SetupIntentVerifyMicrodepositsParams) (*stripe.SetupIntent, error) {
+		for _, amount := range params.Amounts {
+			verifiedAmounts = append(verifiedAmounts, *amount)
+		}
+		return &stripe.SetupIntent{ID: id, Status: stripe.SetupIntentStatusSucceeded, Customer: &stripe.Customer{ID: "cus_1"}}, nil
+	}
+
+	p := &StripeProvider{}
+	pm, err := p.VerifyPaymentMethod(context.Background(), "pm_bank", []int64{32, 45})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(verifiedAmounts) != 2 || verifiedAmounts[0] != 32 || verifiedAmounts[1] != 45 {
+		t.Fatalf("expected amounts [32 45] to be verified, got %v", verifiedAmounts)
+	}
+	if pm.Status != models.PaymentMethodStatusActive || pm.CustomerID != "cus_1" || pm.VerificationURL != "" {
+		t.Fatalf("expected an active bank account, got %+v", pm)
+	}
+}
+
+func TestStripeVerifyRejectsAlreadyVerifiedBankAccount(t *testing.T) {
+	stubStripeBankAccount(t)
+
+	findStripeSetupIntent = func(string) (*stripe.SetupIntent, error) {
+		return &stripe.SetupIntent{ID: "seti_123", Status: stripe.SetupIntentStatusSucceeded}, nil
+	}
+	verifyStripeMicrodeposits = func(string, *stripe.SetupIntentVerifyMicrodepositsParams) (*stripe.SetupIntent, error) {
+		t.Fatal("verification should not be attempted")
+		return nil, nil
+	}
+
+	p := &StripeProvider{}
+	if _, err := p.VerifyPaymentMethod(context.Background(), "pm_bank", []int64{32, 45}); !errors.Is(err, ErrVerificationNotPending) {
+		t.Fatalf("expected ErrVerificationNotPending, got %v", err)
+	}
+}
+
+func TestStripePaymentIntentParamsIncludeStatementDescriptor(t *testing.T) {
+	p := &StripeProvider{}
+	params := p.buildPaymentIntentParams(&models.ChargeRequest{
+		CustomerID:                "cus_123",
+		Amount:                    10000,
+		Currency:                  "usd",
+		Description:               "order 1042 internal note",
+		StatementDescriptor:       "ACME STORE",
+		StatementDescriptorSuffix: "ORDER 1042",
+	})
+
+	if params.StatementDescriptor == nil || *params.StatementDescriptor != "ACME STORE" {
+		t.Fatalf("expected statement descriptor ACME STORE, got %v", params.StatementDescriptor)
+	}
+	if params.StatementDescriptorSuffix == nil || *params.StatementDescriptorSuffix != "ORDER 1042" {
+		t.Fatalf("expected statement descriptor suffix ORDER 1042, got %v", params.StatementDescriptorSuffix)
+	}
+	if params.Description == nil || *params.Description != "order 1042 internal note" {
+		t.Fatalf("expected description to be sent unchanged, got %v", params.Description)
+	}
+}
+
+func TestStripeChargeRejectsInvalidStatementDescriptor(t *testing.T) {
+	p := &StripeProvider{}
+	for _, req := range []*models.ChargeRequest{
+		{Amount: 1000, Currency: "usd", StatementDescriptor: "ACME STORE INTERNATIONAL"},
+		{Amount: 1000, Currency: "usd", StatementDescriptor: "ACME"},
+		{Amount: 1000, Currency: "usd", StatementDescriptor: `ACME "STORE"`},
+		{Amount: 1000, Currency: "usd", StatementDescriptorSuffix: "12345"},
+	} {
+		if _, err := p.Charge(context.Background(), req); !errors.Is(err, ErrInvalidStatementDescriptor) {
+			t.Fatalf("descriptor %q / suffix %q: expected ErrInvalidStatementDescriptor, got %v", req.StatementDescriptor, req.StatementDescriptorSuffix, err)
+		}
+	}
+}
+
+func TestProviderModeFromKeys(t *testing.T) {
+	if mode := CreateStripeProvider("sk_test_123").Mode(); mode != ModeTest {
+		t.Fatalf("expected stripe test mode, got %s", mode)
+	}
+	if mode := CreateStripeProvider("sk_live_123").Mode(); mode != ModeLive {
+		t.Fatalf("expected stripe live mode, got %s", mode)
+	}
+	if mode := CreateXenditProvider("xnd_development_123").Mode(); mode != ModeTest {
+		t.Fatalf("expected xendit test mode, got %s", mode)
+	}
+	if mode := CreateRazorpayProvider("rzp_live_123", "secret").Mode(); mode != ModeLive {
+		t.Fatalf("expected razorpay live mode, got %s", mode)
+	}
+}
+
+func TestStripePaymentIntentParamsRequestMulticaptureOnlyWhenAsked(t *testing.T) {
+	p := &StripeProvider{}
+	params := p.buildPaymentIntentParams(&models.ChargeRequest{Amount: 1000, Currency: "usd", CaptureMethod: models.CaptureMethodManual})
+	if params.PaymentMethodOptions != nil {
+		t.Fatalf("expected no multicapture request, got %+v", params.PaymentMethodOptions)
+	}
+
+	params = p.buildPaymentIntentParams(&models.ChargeRequest{Amount: 1000, Currency: "usd", CaptureMethod: models.CaptureMethodManual, RequestMultiCapture: true})
+	if params.PaymentMethodOptions == nil || params.PaymentMethodOptions.Card == nil || params.PaymentMethodOptions.Card.RequestMulticapture == nil || *params.PaymentMethodOptions.Card.RequestMulticapture != "if_available" {
+		t.Fatalf("expected request_multicapture=if_available, got %+v", params.PaymentMethodOptions)
+	}
+}
+
+func TestStripePaymentIntentParamsRequestOvercapture(t *testing.T) {
+	p := &StripeProvider{}
+	params := p.buildPaymentIntentParams(&models.ChargeRequest{Amount: 1000, Currency: "usd", CaptureMethod: models.CaptureMethodManual, RequestOvercapture: true})
+	card := params.PaymentMethodOptions.Card
+	if card.RequestOvercapture == nil || *card.RequestOvercapture != "if_available" {
+		t.Fatalf("expected request_overcapture=if_available, got %+v", card)
+	}
+	if card.RequestMulticapture != nil {
+		t.Fatalf("expected no multicapture request, got %q", *card.RequestMulticapture)
+	}
+}
+
+func TestStripeCapturePartialChecksMulticaptureAvailability(t *testing.T) {
+	originalGet, originalCapture := getStripePaymentIntent, captureStripePaymentIntent
+	defer func() { getStripePaymentIntent, captureStripePaymentIntent = originalGet, originalCapture }()
+
+	status := stripe.ChargePaymentMethodDetailsCardMulticaptureStatusUnavailable
+	getStripePaymentIntent = func(string, *stripe.PaymentIntentParams) (*stripe.PaymentIntent, error) {
+		return &stripe.PaymentIntent{LatestCharge: &stripe.Charge{PaymentMethodDetails: &stripe.ChargePaymentMethodDetails{
+			Card: &stripe.ChargePaymentMethodDetailsCard{Multicapture: &stripe.ChargePaymentMethodDetailsCardMulticapture{Status: status}},
+		}}}, nil
+	}
+	var captured []*stripe.PaymentIntentCaptureParams
+	captureStripePaymentIntent = func(_ string, params *stripe.PaymentIntentCaptureParams) (*stripe.PaymentIntent, error) {
+		captured = append(captured, params)
+		return &stripe.PaymentIntent{}, nil
+	}
+
+	p := &StripeProvider{}
+	if err := p.CapturePartial(context.Background(), "pi_1", 500, false); !errors.Is(err, ErrMultiCaptureUnavailable) {
+		t.Fatalf("expected ErrMultiCaptureUnavailable, got %v", err)
+	}
+	if len(captured) != 0 {
+		t.Fatalf("expected no capture call, got %d", len(captured))
+	}
+
+	if err := p.CapturePartial(context.Background(), "pi_1", 500, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(captured) != 1 || captured[0].FinalCapture != nil {
+		t.Fatalf("expected a plain final capture, got %+v", captured)
+	}
+
+	status = stripe.ChargePaymentMethodDetailsCardMulticaptureStatusAvailable
+	if err := p.CapturePartial(context.Background(), "pi_1", 500, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(captured) != 2 || captured[1].FinalCapture == nil || *captured[1].FinalCapture {
+		t.Fatalf("expected final_capture=false, got %+v", captured[1])
+	}
+}
+
+func TestStripeReverseAuthorizationLowersIntentAmount(t *testing.T) {
+	originalGet, originalUpdate := getStripePaymentIntent, updateStripePaymentIntent
+	defer func() { getStripePaymentIntent, updateStripePaymentIntent = originalGet, originalUpdate }()
+
+	getStripePaymentIntent = func(string, *stripe.PaymentIntentParams) (*stripe.PaymentIntent, error) {
+		return &stripe.PaymentIntent{Amount: 1000, AmountCapturable: 800}, nil
+	}
+	var updates []*stripe.PaymentIntentParams
+	updateStripePaymentIntent = func(_ string, params *stripe.PaymentIntentParams) (*stripe.PaymentIntent, error) {
+		updates = append(updates, params)
+		return &stripe.PaymentIntent{}, nil
+	}
+
+	p := &StripeProvider{}
+	if err := p.ReverseAuthorization(context.Background(), "pi_1", 800); !errors.Is(err, ErrReversalExceedsCapturable) {
+		t.Fatalf("expected ErrReversalExceedsCapturable, got %v", err)
+	}
+	if len(updates) != 0 {
+		t.Fatalf("expected no update call, got %d", len(updates))
+	}
+
+	if err := p.ReverseAuthorization(context.Background(), "pi_1", 300); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updates) != 1 || updates[0].Amount == nil || *updates[0].Amount != 700 {
+		t.Fatalf("expected the intent amount lowered to 700, got %+v", updates)
+	}
+}
+
+func TestStripeCreateInvoiceAttachesLineItems(t *testing.T) {
+	originalNew, originalGet, originalItem := newStripeInvoice, getStripeInvoice, newStripeInvoiceItem
+	defer func() {
+		newStripeInvoice, getStripeInvoice, newStripeInvoiceItem = originalNew, originalGet, originalItem
+	}()
+
+	newStripeInvoice = func(*stripe.InvoiceParams) (*stripe.Invoice, error) {
+		return &stripe.Invoice{ID: "in_1", Status: stripe.InvoiceStatusDraft}, nil
+	}
+	var items []*stripe.InvoiceItemParams
+	newStripeInvoiceItem = func(params *stripe.InvoiceItemParams) (*stripe.InvoiceItem, error) {
+		items = append(items, params)
+		return &stripe.InvoiceItem{}, nil
+	}
+	getStripeInvoice = func(id string, _ *stripe.InvoiceParams) (*stripe.Invoice, error) {
+		return &stripe.Invoice{ID: id, AmountDue: 7000, Currency: stripe.CurrencyUSD, Status: stripe.InvoiceStatusDraft}, nil
+	}
+
+	p := &StripeProvider{}
+	inv, err := p.CreateInvoice(context.Background(), &models.CreateInvoiceRequest{
+		CustomerID: "cus_1",
+		Currency:   "USD",
+		Amount:     7000,
+		LineItems: []models.InvoiceLineItem{
+			{Name: "Seat licence", Quantity: 3, UnitAmount: 1500},
+			{Name: "Onboarding", Quantity: 1, UnitAmount: 2500},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 invoice items, got %d", len(items))
+	}
+	if *items[0].Invoice != "in_1" || *items[0].Quantity != 3 || *items[0].UnitAmountDecimal != 1500 {
+		t.Fatalf("unexpected first invoice item %+v", items[0])
+	}
+	if inv.Amount != 7000 || len(inv.LineItems) != 2 {
+		t.Fatalf("expected refetched amount and line items, got amount %d with %d lines", inv.Amount, len(inv.LineItems))
+	}
+}
+
+func TestStripeCalculateTaxMapsCalculation(t *testing.T) {
+	original := newStripeTaxCalculation
+	defer func() { newStripeTaxCalculation = original }()
+
+	var got *stripe.TaxCalculationParams
+	newStripeTaxCalculation = func(params *stripe.TaxCalculationParams) (*stripe.TaxCalculation, error) {
+		got = params
+		return &stripe.TaxCalculation{
+			ID:                 "taxcalc_1",
+			Currency:           stripe.CurrencyUSD,
+			AmountTotal:        1089,
+			TaxAmountExclusive: 89,
+			TaxBreakdown: []*stripe.TaxCalculationTaxBreakdown{{
+				Amount:        89,
+				TaxableAmount: 1000,
+				TaxRateDetails: &stripe.TaxCalculationTaxBreakdownTaxRateDetails{
+					Country:           "US",
+					State:             "NY",
+					PercentageDecimal: "8.875",
+					TaxType:           "sales_tax",
+				},
+			}},
+		}, nil
+	}
+
+	p := &StripeProvider{}
+	calc, err := p.CalculateTax(context.Background(), &models.CalculateTaxRequest{
+		Currency:  "USD",
+		Address:   &models.TaxAddress{PostalCode: "10001", Country: "US"},
+		LineItems: []models.TaxLineItem{{Reference: "charge", Amount: 1000}},
+	})
+	if err != nil {
+		t.Fatalf("calculate tax: %v", err)
+	}
+	if *got.Currency != "usd" || len(got.LineItems) != 1 || *got.LineItems[0].TaxBehavior != "exclusive" {
+		t.Fatalf("unexpected calculation params %+v", got)
+	}
+	if calc.Subtotal != 1000 || calc.TaxAmount != 89 || calc.Total != 1089 {
+		t.Fatalf("expected 89 tax on 1000, got %+v", calc)
+	}
+	if len(calc.Breakdown) != 1 || calc.Breakdown[0].Rate != "8.875" || calc.Breakdown[0].State != "NY" {
+		t.Fatalf("unexpected breakdown %+v", calc.Breakdown)
+	}
+
+	if _, err := p.CalculateTax(context.Background(), &models.CalculateTaxRequest{Currency: "USD"}); !errors.Is(err, ErrTaxAddressRequired) {
+		t.Fatalf("expected ErrTaxAddressRequired, got %v", err)
+	}
+}
+
+func TestStripeCreateCheckoutSessionReturnsURL(t *testing.T) {
+	original := newStripeCheckoutSession
+	defer func() { newStripeCheckoutSession = original }()
+
+	var got *stripe.CheckoutSessionParams
+	newStripeCheckoutSession = func(params *stripe.CheckoutSessionParams) (*stripe.CheckoutSession, error) {
+		got = params
+		return &stripe.CheckoutSession{
+			ID:        "cs_test_1",
+			URL:       "https://checkout.stripe.com/c/pay/cs_test_1",
+			ExpiresAt: 1772323200,
+		}, nil
+	}
+
+	p := &StripeProvider{}
+	session, err := p.CreateCheckoutSession(context.Background(), &models.CreateCheckoutSessionRequest{
+		Amount:      2500,
+		Currency:    "USD",
+		Description: "Order 42",
+		SuccessURL:  "https://shop.example/success",
+		CancelURL:   "https://shop.example/cart",
+	})
+	if err != nil {
+		t.Fatalf("create checkout session: %v", err)
+	}
+	if session.URL != "https://checkout.stripe.com/c/pay/cs_test_1" || session.ProviderSessionID != "cs_test_1" {
+		t.Fatalf("expected the hosted checkout URL to be returned, got %+v", session)
+	}
+	if session.Status != models.CheckoutSessionStatusOpen || session.ExpiresAt == nil || session.ExpiresAt.Unix() != 1772323200 {
+		t.Fatalf("unexpected session state %+v", session)
+	}
+
+	if *got.Mode != string(stripe.CheckoutSessionModePayment) || *got.SuccessURL != "https://shop.example/success" || *got.CancelURL != "https://shop.example/cart" {
+		t.Fatalf("unexpected checkout params %+v", got)
+	}
+	if len(got.LineItems) != 1 {
+		t.Fatalf("expected a single line item, got %d", len(got.LineItems))
+	}
+	price := got.LineItems[0].PriceData
+	if *price.UnitAmount != 2500 || *price.Currency != "usd" || *price.ProductData.Name != "Order 42" {
+		t.Fatalf("unexpected line item price %+v", price)
+	}
+}
+
+type fakeStripeIter struct {
+	pages [][]string
+	page  int
+	pos   int
+	err   error
+	cur   string
+}
+
+func (f *fakeStripeIter) Next() bool {
+	for f.page < len(f.pages) {
+		if f.pos < len(f.pages[f.page]) {
+			f.cur = f.pages[f.page][f.pos]
+			f.pos++
+			return true
+		}
+		f.page, f.pos = f.page+1, 0
+	}
+	return false
+}
+
+func (f *fakeStripeIter) Err() error { return f.err }
+
+func TestCollectStripeListReadsEveryPage(t *testing.T) {
+	iter := &fakeStripeIter{pages: [][]string{{"in_1", "in_2"}, {"in_3"}}}
+
+	items, err := collectStripeList(iter, func() string { return iter.cur }, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(items, ",") != "in_1,in_2,in_3" {
+		t.Fatalf("expected items from both pages, got %v", items)
+	}
+
+	iter = &fakeStripeIter{pages: [][]string{{"in_1", "in_2"}, {"in_3"}}}
+	if items, _ := collectStripeList(iter, func() string { return iter.cur }, 2); len(items) != 2 {
+		t.Fatalf("expected limit to stop after 2 items, got %v", items)
+	}
+}
+
+func TestCollectStripeListReturnsPageError(t *testing.T) {
+	iter := &fakeStripeIter{pages: [][]string{{"in_1"}}, err: errors.New("page 2 failed")}
+
+	if _, err := collectStripeList(iter, func() string { return iter.cur }, 0); err == nil {
+		t.Fatal("expected page error to be returned")
+	}
+}
+
+func TestStripeRefundMapsCanonicalReason(t *testing.T) {
+	original := newStripeRefund
+	defer func() { newStripeRefund = original }()
+
+	var gotParams *stripe.RefundParams
+	newStripeRefund = func(params *stripe.RefundParams) (*stripe.Refund, error) {
+		gotParams = params
+		return &stripe.Refund{ID: "re_1", Amount: *params.Amount, Status: stripe.RefundStatusSucceeded}, nil
+	}
+
+	p := &StripeProvider{}
+	resp, err := p.Refund(context.Background(), &models.RefundRequest{PaymentID: "pi_1", Amount: 500, Reason: "Requested by customer"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotParams.Reason == nil || *gotParams.Reason != "requested_by_customer" {
+		t.Fatalf("expected reason requested_by_customer, got %v", gotParams.Reason)
+	}
+	if resp.Reason != string(models.RefundReasonRequestedByCustomer) {
+		t.Fatalf("expected canonical reason on response, got %q", resp.Reason)
+	}
+}
+
+func TestStripeRefundOmitsUnsupportedReason(t *testing.T) {
+	original := newStripeRefund
+	defer func() { newStripeRefund = original }()
+
+	var gotParams *stripe.RefundParams
+	newStripeRefund = func(params *stripe.RefundParams) (*stripe.Refund, error) {
+		gotParams = params
+		return &stripe.Refund{ID: "re_1", Amount: *params.Amount}, nil
+	}
+
+	req := &models.RefundRequest{PaymentID: "pi_1", Amount: 500, Reason: "item arrived damaged"}
+	req.NormalizeReason()
+	if req.Reason != string(models.RefundReasonOther) || req.Note != "item arrived damaged" {
+		t.Fatalf("expected reason other with the original text as note, got reason=%q note=%q", req.Reason, req.Note)
+	}
+
+	p := &StripeProvider{}
+	if _, err := p.Refund(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotParams.Reason != nil {
+		t.Fatalf("expected no reason to be sent to Stripe, got %q", *gotParams.Reason)
+	}
+	if gotParams.Metadata["refund_note"] != "item arrived damaged" {
+		t.Fatalf("expected note in refund metadata, got %v", gotParams.Metadata)
+	}
+}
+
+func TestStripeRefundSendsIdempotencyKeyHeader(t *testing.T) {
+	var gotKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("Idempotency-Key")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"re_1","object":"refund","amount":500,"currency":"usd","status":"succeeded"}`))
+	}))
+	original := stripe.GetBackend(stripe.APIBackend)
+	stripe.SetBackend(stripe.APIBackend, stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+		URL:               stripe.String(server.URL),
+		MaxNetworkRetries: stripe.Int64(0),
+	}))
+	t.Cleanup(func() {
+		stripe.SetBackend(stripe.APIBackend, original)
+		server.Close()
+	})
+
+	p := &StripeProvider{}
+	_, err := p.Refund(context.Background(), &models.RefundRequest{PaymentID: "pi_1", Amount: 500, IdempotencyKey: "refund-key-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotKey != "refund-key-1" {
+		t.Fatalf("expected Stripe to receive the idempotency key, got %q", gotKey)
+	}
+}
+
+func TestStripePaymentIntentParamsPaymentMethodConfiguration(t *testing.T) {
+	p := &StripeProvider{}
+
+	params := p.buildPaymentIntentParams(&models.ChargeRequest{Amount: 1000, Currency: "usd"})
+	if params.AutomaticPaymentMethods == nil || !*params.AutomaticPaymentMethods.Enabled || *params.AutomaticPaymentMethods.AllowRedirects != "always" {
+		t.Fatalf("expected automatic payment methods with redirects by default, got %+v", params.AutomaticPaymentMethods)
+	}
+	if params.PaymentMethodTypes != nil {
+		t.Fatalf("expected no payment method types by default, got %v", params.PaymentMethodTypes)
+	}
+
+	params = p.buildPaymentIntentParams(&models.ChargeRequest{Amount: 1000, Currency: "usd", AllowRedirects: models.AllowRedirectsNever})
+	if params.AutomaticPaymentMethods == nil || *params.AutomaticPaymentMethods.AllowRedirects != "never" {
+		t.Fatalf("expected allow_redirects=never, got %+v", params.AutomaticPaymentMethods)
+	}
+
+	params = p.buildPaymentIntentParams(&models.ChargeRequest{Amount: 1000, Currency: "usd", PaymentMethodTypes: []string{"card", "link"}})
+	if params.AutomaticPaymentMethods != nil {
+		t.Fatalf("expected automatic payment methods to be omitted, got %+v", params.AutomaticPaymentMethods)
+	}
+	if len(params.PaymentMethodTypes) != 2 || *params.PaymentMethodTypes[0] != "card" || *params.PaymentMethodTypes[1] != "link" {
+		t.Fatalf("expected payment_method_types [card link], got %v", params.PaymentMethodTypes)
+	}
+}
+
+func TestStripePaymentIntentParamsIncludeAddresses(t *testing.T) {
+	p := &StripeProvider{}
+	params := p.buildPaymentIntentParams(&models.ChargeRequest{
+		CustomerID:     "cus_123",
+		Amount:         10000,
+		Currency:       "usd",
+		PaymentMethod:  "pm_card_visa",
+		BillingAddress: &models.Address{Line1: "1 Main St", PostalCode: "94105", Country: "US"},
+		ShippingAddress: &models.ShippingAddress{
+			Name:    "Ada Lovelace",
+			Phone:   "+442071234567",
+			Address: models.Address{Line1: "10 Downing St", City: "London", PostalCode: "SW1A 2AA", Country: "GB"},
+		},
+	})
+
+	shipping := params.Shipping
+	if shipping == nil || shipping.Name == nil || *shipping.Name != "Ada Lovelace" || shipping.Phone == nil {
+		t.Fatalf("expected shipping name and phone, got %+v", shipping)
+	}
+	if a := shipping.Address; a == nil || *a.Country != "GB" || *a.City != "London" || *a.PostalCode != "SW1A 2AA" || a.State != nil {
+		t.Fatalf("unexpected shipping address params: %+v", shipping.Address)
+	}
+	if params.Metadata["billing_country"] != "US" || params.Metadata["billing_postal_code"] != "94105" {
+		t.Fatalf("expected the billing address in metadata, got %v", params.Metadata)
+	}
+}
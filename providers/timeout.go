@@ -0,0 +1,75 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrProviderTimeout reports that a provider did not answer within the
+// operation's deadline. It also wraps context.DeadlineExceeded, so retries
+// and charge failover leave it alone: the provider may still have acted on
+// the request.
+var ErrProviderTimeout = errors.New("provider timeout")
+
+// DefaultOperationTimeout is kept below the shared HTTP client's 30s timeout
+// and the server's write timeout so a slow provider fails the request with
+// provider_timeout instead of holding it open.
+const DefaultOperationTimeout = 20 * time.Second
+
+// OperationTimeouts bounds provider calls. Operations are named like the
+// provider spans, e.g. "charge" or "list_invoices"; any not listed use
+// Default. A non-positive timeout leaves the call unbounded.
+type OperationTimeouts struct {
+	Default    time.Duration
+	Operations map[string]time.Duration
+}
+
+func (t OperationTimeouts) For(operation string) time.Duration {
+	if timeout, ok := t.Operations[operation]; ok {
+		return timeout
+	}
+	return t.Default
+}
+
+// CallWithTimeout runs fn with a context that expires after timeout. Calls
+// that ignore their context, such as the Stripe SDK's, are abandoned when
+// the deadline passes and finish in the background, so the caller never
+// waits longer than timeout.
+func CallWithTimeout[T any](ctx context.Context, timeout time.Duration, operation string, fn func(context.Context) (T, error)) (T, error) {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn(ctx)
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return r.value, timeoutError(operation, timeout)
+		}
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return zero, timeoutError(operation, timeout)
+		}
+		return zero, ctx.Err()
+	}
+}
+
+func timeoutError(operation string, timeout time.Duration) error {
+	return fmt.Errorf("%w: %s did not complete within %s: %w", ErrProviderTimeout, operation, timeout, context.DeadlineExceeded)
+}
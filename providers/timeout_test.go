@@ -0,0 +1,136 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func slowServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-release:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return server
+}
+
+func TestCallWithTimeoutFiresBeforeClientTimeout(t *testing.T) {
+	server := slowServer(t, 5*time.Second)
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) (int, error)
+	}{
+		{"context aware", func(ctx context.Context) (int, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			if err != nil {
+				return 0, err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return 0, err
+			}
+			defer resp.Body.Close()
+			return resp.StatusCode, nil
+		}},
+		{"ignores context", func(context.Context) (int, error) {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				return 0, err
+			}
+			defer resp.Body.Close()
+			return resp.StatusCode, nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			_, err := CallWithTimeout(context.Background(), 50*time.Millisecond, "charge", tt.call)
+			elapsed := time.Since(start)
+
+			if !errors.Is(err, ErrProviderTimeout) {
+				t.Fatalf("expected ErrProviderTimeout, got %v", err)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected the timeout to wrap context.DeadlineExceeded, got %v", err)
+			}
+			if elapsed >= client.Timeout/2 {
+				t.Fatalf("expected the provider timeout to fire well before the client timeout, took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestCallWithTimeoutLeavesOtherErrorsAlone(t *testing.T) {
+	declined := errors.New("card declined")
+	_, err := CallWithTimeout(context.Background(), time.Second, "charge", func(context.Context) (int, error) {
+		return 0, declined
+	})
+	if !errors.Is(err, declined) || errors.Is(err, ErrProviderTimeout) {
+		t.Fatalf("expected the provider error unchanged, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = CallWithTimeout(ctx, time.Second, "charge", func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrProviderTimeout) {
+		t.Fatalf("expected a cancelled request not to be reported as a provider timeout, got %v", err)
+	}
+}
+
+func TestOperationTimeoutsOverrideDefault(t *testing.T) {
+	timeouts := OperationTimeouts{
+		Default:    10 * time.Second,
+		Operations: map[string]time.Duration{"list_invoices": 3 * time.Second},
+	}
+	if got := timeouts.For("list_invoices"); got != 3*time.Second {
+		t.Fatalf("expected 3s for list_invoices, got %s", got)
+	}
+	if got := timeouts.For("charge"); got != 10*time.Second {
+		t.Fatalf("expected the default for charge, got %s", got)
+	}
+}
+
+func TestProviderTimeoutsTripTheFuse(t *testing.T) {
+	server := slowServer(t, 5*time.Second)
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	executor := CreateProviderExecutor(DefaultProviderExecutorConfig())
+
+	calls := 0
+	for i := 0; i < 5; i++ {
+		err := executor.Execute(context.Background(), "stripe", func() error {
+			calls++
+			_, err := CallWithTimeout(context.Background(), 20*time.Millisecond, "charge", func(ctx context.Context) (*http.Response, error) {
+				req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+				return client.Do(req)
+			})
+			return err
+		})
+		if !errors.Is(err, ErrProviderTimeout) {
+			t.Fatalf("expected ErrProviderTimeout, got %v", err)
+		}
+	}
+	if calls != 5 {
+		t.Fatalf("expected timed-out calls not to be retried, got %d calls", calls)
+	}
+	if state := executor.GetFuseState("stripe"); state != FuseOpen {
+		t.Fatalf("expected repeated timeouts to open the fuse, got %s", state)
+	}
+}
@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	AttrProviderName      = attribute.Key("provider.name")
+	AttrProviderOperation = attribute.Key("provider.operation")
+	AttrProviderOutcome   = attribute.Key("provider.outcome")
+	AttrPaymentCurrency   = attribute.Key("payment.currency")
+)
+
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/malwarebo/conductor/providers")
+}
+
+func startProviderSpan(ctx context.Context, operation, currency string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{AttrProviderOperation.String(operation)}
+	if currency != "" {
+		attrs = append(attrs, AttrPaymentCurrency.String(strings.ToUpper(currency)))
+	}
+	return tracer().Start(ctx, "provider."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func endProviderSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(AttrProviderOutcome.String("error"))
+	} else {
+		span.SetAttributes(AttrProviderOutcome.String("success"))
+	}
+	span.End()
+}
+
+// tagProvider records which underlying provider served the call on the
+// current span and returns the provider so it can be used inline.
+func tagProvider[T any](ctx context.Context, provider T) T {
+	if named, ok := any(provider).(interface{ Name() string }); ok {
+		trace.SpanFromContext(ctx).SetAttributes(AttrProviderName.String(named.Name()))
+	}
+	return provider
+}
@@ -0,0 +1,93 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+type fakeTracedProvider struct {
+	PaymentProvider
+	chargeErr error
+}
+
+func (f *fakeTracedProvider) Name() string { return "stripe" }
+
+func (f *fakeTracedProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeTracedProvider) Charge(_ context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+	if f.chargeErr != nil {
+		return nil, f.chargeErr
+	}
+	return &models.ChargeResponse{Amount: req.Amount, Currency: req.Currency, Status: models.PaymentStatusSuccess}, nil
+}
+
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	return exporter
+}
+
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]string {
+	attrs := make(map[attribute.Key]string, len(span.Attributes))
+	for _, attr := range span.Attributes {
+		attrs[attr.Key] = attr.Value.Emit()
+	}
+	return attrs
+}
+
+func TestChargeProducesProviderSpan(t *testing.T) {
+	exporter := recordSpans(t)
+	config := DefaultMultiProviderConfig()
+	config.EnableSmartRouting = false
+	selector := CreateMultiProviderSelectorWithConfig([]PaymentProvider{&fakeTracedProvider{}}, nil, config)
+
+	if _, err := selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "usd"}); err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "provider.charge" {
+		t.Fatalf("expected a single provider.charge span, got %+v", spans)
+	}
+	attrs := spanAttributes(spans[0])
+	want := map[attribute.Key]string{
+		AttrProviderName:      "stripe",
+		AttrProviderOperation: "charge",
+		AttrPaymentCurrency:   "USD",
+		AttrProviderOutcome:   "success",
+	}
+	for key, value := range want {
+		if attrs[key] != value {
+			t.Fatalf("expected %s=%s, got %q", key, value, attrs[key])
+		}
+	}
+}
+
+func TestFailedChargeMarksSpanAsError(t *testing.T) {
+	exporter := recordSpans(t)
+	config := DefaultMultiProviderConfig()
+	config.EnableSmartRouting = false
+	selector := CreateMultiProviderSelectorWithConfig([]PaymentProvider{&fakeTracedProvider{chargeErr: errors.New("card declined")}}, nil, config)
+
+	if _, err := selector.Charge(context.Background(), &models.ChargeRequest{Amount: 1000, Currency: "USD"}); err == nil {
+		t.Fatal("expected charge to fail")
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected a single span, got %d", len(spans))
+	}
+	if spans[0].Status.Code != codes.Error || spanAttributes(spans[0])[AttrProviderOutcome] != "error" {
+		t.Fatalf("expected error outcome, got status %+v attrs %v", spans[0].Status, spanAttributes(spans[0]))
+	}
+}
@@ -0,0 +1,26 @@
+package providers
+
+import "errors"
+
+var ErrUnknownWebhookProvider = errors.New("unknown webhook provider")
+
+type WebhookValidator interface {
+	ValidateWebhookSignature(payload []byte, signature string) error
+}
+
+// CreateWebhookValidator returns a provider that only verifies webhook
+// signatures, bound to secret instead of the globally configured one. It is
+// used for tenants whose own provider account signs with its own secret.
+func CreateWebhookValidator(providerName, secret string) (WebhookValidator, error) {
+	switch providerName {
+	case "stripe":
+		return &StripeProvider{webhookSecret: secret}, nil
+	case "xendit":
+		return &XenditProvider{webhookSecret: secret}, nil
+	case "razorpay":
+		return &RazorpayProvider{webhookSecret: secret}, nil
+	case "airwallex":
+		return &AirwallexProvider{webhookSecret: secret}, nil
+	}
+	return nil, ErrUnknownWebhookProvider
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/malwarebo/conductor/internal/convert"
 	"github.com/malwarebo/conductor/internal/crypto"
+	"github.com/malwarebo/conductor/internal/httputil"
 	"github.com/malwarebo/conductor/models"
 	xendit "github.com/xendit/xendit-go/v7"
 	"github.com/xendit/xendit-go/v7/customer"
@@ -33,24 +34,31 @@ type XenditProvider struct {
 	webhookSecret string
 	client        *xendit.APIClient
 	httpClient    *http.Client
+	mode          Mode
 }
 
 func CreateXenditProvider(apiKey string) *XenditProvider {
 	client := xendit.NewClient(apiKey)
+	httpClient := httputil.NewHTTPClient(httputil.DefaultTransportConfig())
+	setXenditHTTPClient(client, httpClient)
 	return &XenditProvider{
 		apiKey:     apiKey,
 		client:     client,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: httpClient,
+		mode:       modeFromKey(apiKey, xenditTestKeyPrefixes),
 	}
 }
 
 func CreateXenditProviderWithWebhook(apiKey, webhookSecret string) *XenditProvider {
 	client := xendit.NewClient(apiKey)
+	httpClient := httputil.NewHTTPClient(httputil.DefaultTransportConfig())
+	setXenditHTTPClient(client, httpClient)
 	return &XenditProvider{
 		apiKey:        apiKey,
 		webhookSecret: webhookSecret,
 		client:        client,
-		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		httpClient:    httpClient,
+		mode:          modeFromKey(apiKey, xenditTestKeyPrefixes),
 	}
 }
 
@@ -146,8 +154,10 @@ type xenditTransactionListResponse struct {
 
 func (p *XenditProvider) doRequest(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
 	var reqBody io.Reader
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		var err error
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
@@ -174,8 +184,10 @@ func (p *XenditProvider) doRequest(ctx context.Context, method, path string, bod
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
+	logProviderExchange(ctx, "xendit", method, path, resp.StatusCode, jsonBody, respBody)
+
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("xendit API error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, &APIError{Provider: "xendit", StatusCode: resp.StatusCode, Body: redactBody(respBody)}
 	}
 
 	return respBody, nil
@@ -197,24 +209,45 @@ func (p *XenditProvider) buildListPath(basePath string, params map[string]string
 	return basePath
 }
 
+func (p *XenditProvider) SetHTTPClient(client *http.Client) {
+	p.httpClient = client
+	setXenditHTTPClient(p.client, client)
+}
+
+func setXenditHTTPClient(client *xendit.APIClient, httpClient *http.Client) {
+	if cfg, ok := client.GetConfig().(*xendit.Configuration); ok {
+		cfg.HTTPClient = httpClient
+	}
+}
+
 func (p *XenditProvider) Name() string {
 	return "xendit"
 }
 
+func (p *XenditProvider) Mode() Mode {
+	return p.mode
+}
+
 func (p *XenditProvider) Capabilities() ProviderCapabilities {
 	return ProviderCapabilities{
+		SupportsSubscriptions:   false,
 		SupportsInvoices:        true,
 		SupportsPayouts:         true,
 		SupportsPaymentSessions: true,
 		Supports3DS:             true,
 		SupportsManualCapture:   true,
 		SupportsBalance:         true,
-		SupportedCurrencies:     []string{"IDR", "PHP", "VND", "THB", "MYR", "SGD", "USD", "HKD", "AUD", "GBP", "EUR", "JPY", "MXN"},
+		SupportedCurrencies:     []string{"IDR", "PHP", "VND", "THB", "MYR", "SGD", "HKD", "AUD", "GBP", "EUR", "JPY", "MXN"},
 		SupportedPaymentMethods: []models.PaymentMethodType{models.PMTypeCard, models.PMTypeEWallet, models.PMTypeVirtualAccount, models.PMTypeQRCode, models.PMTypeDirectDebit, models.PMTypeRetail},
+		Mode:                    p.mode,
 	}
 }
 
 func (p *XenditProvider) Charge(ctx context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+	if hasPlatformFees(req) {
+		return nil, ErrPlatformFeesNotSupported
+	}
+
 	currency, err := p.getCurrency(req.Currency)
 	if err != nil {
 		return nil, fmt.Errorf("unsupported currency: %w", err)
@@ -239,10 +272,18 @@ func (p *XenditProvider) Charge(ctx context.Context, req *models.ChargeRequest)
 		paymentReq.SetMetadata(req.Metadata)
 	}
 
-	pr, _, sdkErr := p.client.PaymentRequestApi.CreatePaymentRequest(ctx).PaymentRequestParameters(*paymentReq).Execute()
+	create := p.client.PaymentRequestApi.CreatePaymentRequest(ctx).PaymentRequestParameters(*paymentReq)
+	if req.SubAccountID != "" {
+		// Sent as the for-user-id header, so the charge settles to the
+		// platform's sub-account rather than the master account.
+		create = create.ForUserId(req.SubAccountID)
+	}
+
+	pr, _, sdkErr := create.Execute()
 	if sdkErr != nil {
 		return nil, fmt.Errorf("xendit payment request creation failed: %w", sdkErr)
 	}
+	recordRawJSON(ctx, "xendit", "charge", pr)
 
 	status := p.mapPaymentStatus(string(pr.GetStatus()))
 
@@ -257,6 +298,7 @@ func (p *XenditProvider) Charge(ctx context.Context, req *models.ChargeRequest)
 		ProviderName:     "xendit",
 		ProviderChargeID: pr.GetId(),
 		CaptureMethod:    captureMethod,
+		SubAccountID:     req.SubAccountID,
 		Metadata:         req.Metadata,
 		CreatedAt:        time.Now(),
 	}
@@ -288,6 +330,53 @@ func (p *XenditProvider) mapPaymentStatus(status string) models.PaymentStatus {
 	return models.PaymentStatusPending
 }
 
+func (p *XenditProvider) GetCharge(ctx context.Context, providerChargeID string) (*models.ChargeResponse, error) {
+	pr, _, err := p.client.PaymentRequestApi.GetPaymentRequestByID(ctx, providerChargeID).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("xendit get payment request failed: %w", err)
+	}
+
+	return p.mapPaymentRequestCharge(pr), nil
+}
+
+func (p *XenditProvider) mapPaymentRequestCharge(pr *paymentrequest.PaymentRequest) *models.ChargeResponse {
+	desc := ""
+	if pr.Description.IsSet() && pr.Description.Get() != nil {
+		desc = *pr.Description.Get()
+	}
+
+	captureMethod := models.CaptureMethodAutomatic
+	if pr.GetCaptureMethod() == paymentrequest.PAYMENTREQUESTCAPTUREMETHOD_MANUAL {
+		captureMethod = models.CaptureMethodManual
+	}
+
+	response := &models.ChargeResponse{
+		ID:               pr.GetId(),
+		CustomerID:       pr.GetReferenceId(),
+		Amount:           int64(pr.GetAmount()),
+		Currency:         string(pr.GetCurrency()),
+		Status:           p.mapPaymentStatus(string(pr.GetStatus())),
+		PaymentMethod:    pr.GetPaymentMethod().Id,
+		Description:      desc,
+		ProviderName:     "xendit",
+		ProviderChargeID: pr.GetId(),
+		CaptureMethod:    captureMethod,
+		CreatedAt:        time.Now(),
+	}
+
+	if actions := pr.GetActions(); len(actions) > 0 {
+		response.RequiresAction = true
+		for _, action := range actions {
+			if action.GetAction() == "AUTH" {
+				response.NextActionType = "redirect_to_url"
+				response.NextActionURL = action.GetUrl()
+			}
+		}
+	}
+
+	return response
+}
+
 func (p *XenditProvider) CapturePayment(ctx context.Context, paymentID string, amount int64) error {
 	captureParams := paymentrequest.NewCaptureParameters(float64(amount))
 	_, _, err := p.client.PaymentRequestApi.CapturePaymentRequest(ctx, paymentID).CaptureParameters(*captureParams).Execute()
@@ -481,6 +570,37 @@ func (p *XenditProvider) CreateInvoice(ctx context.Context, req *models.CreateIn
 	return p.mapInvoice(inv), nil
 }
 
+// CreateCheckoutSession uses a Xendit invoice as the hosted checkout: its
+// invoice URL is a payment page offering every channel enabled on the account.
+func (p *XenditProvider) CreateCheckoutSession(ctx context.Context, req *models.CreateCheckoutSessionRequest) (*models.CheckoutSession, error) {
+	inv, err := p.CreateInvoice(ctx, &models.CreateInvoiceRequest{
+		ExternalID:         fmt.Sprintf("checkout_%d", time.Now().UnixNano()),
+		Amount:             req.Amount,
+		Currency:           req.Currency,
+		Description:        req.Description,
+		SuccessRedirectURL: req.SuccessURL,
+		FailureRedirectURL: req.CancelURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CheckoutSession{
+		ProviderName:      "xendit",
+		ProviderSessionID: inv.ProviderID,
+		Amount:            req.Amount,
+		Currency:          req.Currency,
+		CustomerID:        req.CustomerID,
+		Description:       req.Description,
+		Status:            models.CheckoutSessionStatusOpen,
+		URL:               inv.InvoiceURL,
+		SuccessURL:        req.SuccessURL,
+		CancelURL:         req.CancelURL,
+		ExpiresAt:         inv.DueDate,
+		Metadata:          req.Metadata,
+	}, nil
+}
+
 func (p *XenditProvider) GetInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error) {
 	inv, _, err := p.client.InvoiceApi.GetInvoiceById(ctx, invoiceID).Execute()
 	if err != nil {
@@ -640,10 +760,14 @@ func (p *XenditProvider) GetPayoutChannels(ctx context.Context, currency string)
 
 	result := make([]*models.PayoutChannel, len(channels))
 	for i, ch := range channels {
+		limits := ch.GetAmountLimits()
 		result[i] = &models.PayoutChannel{
-			Code:     ch.GetChannelCode(),
-			Category: string(ch.GetChannelCategory()),
-			Currency: ch.GetCurrency(),
+			Code:      ch.GetChannelCode(),
+			Name:      ch.GetChannelName(),
+			Category:  string(ch.GetChannelCategory()),
+			Currency:  ch.GetCurrency(),
+			MinAmount: float64(limits.GetMinimum()),
+			MaxAmount: float64(limits.GetMaximum()),
 		}
 	}
 
@@ -709,20 +833,41 @@ func (p *XenditProvider) GetBalance(ctx context.Context, currency string) (*mode
 	}, nil
 }
 
+var xenditRefundReasons = map[models.RefundReason]string{
+	models.RefundReasonDuplicate:           "DUPLICATE",
+	models.RefundReasonFraudulent:          "FRAUDULENT",
+	models.RefundReasonRequestedByCustomer: "REQUESTED_BY_CUSTOMER",
+}
+
+// xenditRefundReason maps a refund reason onto Xendit's enum. Xendit requires
+// a reason, so empty and unmapped reasons are sent as OTHERS.
+func xenditRefundReason(reason string) string {
+	if r, ok := xenditRefundReasons[models.NormalizeRefundReason(reason)]; ok {
+		return r
+	}
+	return "OTHERS"
+}
+
 func (p *XenditProvider) Refund(ctx context.Context, req *models.RefundRequest) (*models.RefundResponse, error) {
 	refundData := refund.NewCreateRefund()
 	refundData.SetInvoiceId(req.PaymentID)
 	refundData.SetAmount(float64(req.Amount))
-	refundData.SetReason(req.Reason)
+	refundData.SetReason(xenditRefundReason(req.Reason))
 
 	if req.Metadata != nil {
 		refundData.SetMetadata(req.Metadata)
 	}
 
-	ref, _, err := p.client.RefundApi.CreateRefund(ctx).CreateRefund(*refundData).Execute()
+	create := p.client.RefundApi.CreateRefund(ctx).CreateRefund(*refundData)
+	if req.IdempotencyKey != "" {
+		create = create.IdempotencyKey(req.IdempotencyKey)
+	}
+
+	ref, _, err := create.Execute()
 	if err != nil {
 		return nil, err
 	}
+	recordRawJSON(ctx, "xendit", "refund", ref)
 
 	return &models.RefundResponse{
 		ID:               ref.GetId(),
@@ -730,7 +875,8 @@ func (p *XenditProvider) Refund(ctx context.Context, req *models.RefundRequest)
 		Amount:           req.Amount,
 		Currency:         req.Currency,
 		Status:           "succeeded",
-		Reason:           req.Reason,
+		Reason:           string(models.NormalizeRefundReason(req.Reason)),
+		Note:             req.Note,
 		ProviderName:     "xendit",
 		ProviderRefundID: ref.GetId(),
 		Metadata:         req.Metadata,
@@ -1332,6 +1478,11 @@ func (p *XenditProvider) IsAvailable(ctx context.Context) bool {
 	if err != nil && resp == nil {
 		return false
 	}
+	// Any other API error still shows Xendit is reachable, but a rejected
+	// key means every call will fail.
+	if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+		return false
+	}
 
 	return true
 }
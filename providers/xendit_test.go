@@ -0,0 +1,128 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	paymentrequest "github.com/xendit/xendit-go/v7/payment_request"
+)
+
+func TestXenditMapPaymentRequestCharge(t *testing.T) {
+	pr := paymentrequest.PaymentRequest{}
+	pr.SetId("pr_123")
+	pr.SetReferenceId("cus_1")
+	pr.SetAmount(15000)
+	pr.SetCurrency(paymentrequest.PAYMENTREQUESTCURRENCY_IDR)
+	pr.SetStatus(paymentrequest.PAYMENTREQUESTSTATUS_SUCCEEDED)
+	pr.SetCaptureMethod(paymentrequest.PAYMENTREQUESTCAPTUREMETHOD_MANUAL)
+
+	p := &XenditProvider{}
+	charge := p.mapPaymentRequestCharge(&pr)
+
+	if charge.ProviderChargeID != "pr_123" || charge.ProviderName != "xendit" {
+		t.Fatalf("unexpected charge identity: %+v", charge)
+	}
+	if charge.Status != models.PaymentStatusSuccess {
+		t.Fatalf("expected succeeded, got %s", charge.Status)
+	}
+	if charge.Amount != 15000 || charge.Currency != "IDR" || charge.CustomerID != "cus_1" {
+		t.Fatalf("unexpected charge details: %+v", charge)
+	}
+	if charge.CaptureMethod != models.CaptureMethodManual {
+		t.Fatalf("expected manual capture, got %s", charge.CaptureMethod)
+	}
+}
+
+// recordingTransport captures outgoing requests and answers each with a
+// validation error, which is enough to inspect what the SDK sent.
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, r)
+	return &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error_code":"API_VALIDATION_ERROR","message":"rejected in test"}`)),
+		Request:    r,
+	}, nil
+}
+
+func xenditHeader(r *http.Request, name string) string {
+	if values := r.Header[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func TestXenditChargeSetsForUserIDForSubAccount(t *testing.T) {
+	tests := []struct {
+		name         string
+		subAccountID string
+	}{
+		{"sub-account charge", "5f9a1c2b3d4e5f6a7b8c9d0e"},
+		{"master account charge", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &recordingTransport{}
+			p := CreateXenditProvider("xnd_development_key")
+			p.SetHTTPClient(&http.Client{Transport: transport})
+
+			_, _ = p.Charge(context.Background(), &models.ChargeRequest{
+				CustomerID:    "cus_1",
+				Amount:        15000,
+				Currency:      "IDR",
+				PaymentMethod: "pm_1",
+				SubAccountID:  tt.subAccountID,
+			})
+
+			if len(transport.requests) != 1 {
+				t.Fatalf("expected one outgoing request, got %d", len(transport.requests))
+			}
+			// The SDK sets the header under its lowercase name rather than
+			// the canonical form Header.Get looks up.
+			if got := xenditHeader(transport.requests[0], "for-user-id"); got != tt.subAccountID {
+				t.Fatalf("expected for-user-id %q, got %q", tt.subAccountID, got)
+			}
+		})
+	}
+}
+
+func TestXenditRefundSendsIdempotencyKeyHeader(t *testing.T) {
+	transport := &recordingTransport{}
+	p := CreateXenditProvider("xnd_development_key")
+	p.SetHTTPClient(&http.Client{Transport: transport})
+
+	_, _ = p.Refund(context.Background(), &models.RefundRequest{
+		PaymentID:      "inv_1",
+		Amount:         15000,
+		Currency:       "IDR",
+		IdempotencyKey: "refund-key-1",
+	})
+
+	if len(transport.requests) != 1 {
+		t.Fatalf("expected one outgoing request, got %d", len(transport.requests))
+	}
+	if got := xenditHeader(transport.requests[0], "idempotency-key"); got != "refund-key-1" {
+		t.Fatalf("expected idempotency-key refund-key-1, got %q", got)
+	}
+}
+
+func TestSubAccountRejectedByOtherProviders(t *testing.T) {
+	req := &models.ChargeRequest{Amount: 1000, Currency: "USD", PaymentMethod: "pm_1", SubAccountID: "sub_1"}
+
+	if _, err := (&StripeProvider{}).Charge(context.Background(), req); !errors.Is(err, ErrSubAccountNotSupported) {
+		t.Fatalf("expected stripe to reject sub_account_id, got %v", err)
+	}
+	if _, err := CreateRazorpayProvider("key", "secret").Charge(context.Background(), req); !errors.Is(err, ErrSubAccountNotSupported) {
+		t.Fatalf("expected razorpay to reject sub_account_id, got %v", err)
+	}
+}
@@ -4,33 +4,123 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"sync"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrUnknownEncryptionKey = errors.New("ciphertext was encrypted with an unknown key")
+	ErrInvalidEncryptionKey = errors.New("encryption key must be 32 bytes")
+)
+
 type EncryptionManager struct {
-	key []byte
+	mu      sync.RWMutex
+	keyID   string
+	key     []byte
+	retired map[string][]byte
+
+	tenantMu   sync.RWMutex
+	tenantKeys TenantKeyStore
+	tenants    map[string]*tenantKeyring
 }
 
-func CreateEncryptionManager(key []byte) (*EncryptionManager, error) {
+func CreateEncryptionManager(key []byte, retiredKeys ...[]byte) (*EncryptionManager, error) {
 	if len(key) != 32 {
-		return nil, fmt.Errorf("encryption key must be 32 bytes")
+		return nil, ErrInvalidEncryptionKey
+	}
+
+	e := &EncryptionManager{
+		keyID:   EncryptionKeyID(key),
+		key:     key,
+		retired: make(map[string][]byte),
+	}
+	for _, retired := range retiredKeys {
+		if len(retired) != 32 {
+			return nil, ErrInvalidEncryptionKey
+		}
+		if id := EncryptionKeyID(retired); id != e.keyID {
+			e.retired[id] = retired
+		}
 	}
-	return &EncryptionManager{key: key}, nil
+	return e, nil
 }
 
-func (e *EncryptionManager) Encrypt(plaintext string) (string, error) {
-	block, err := aes.NewCipher(e.key)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %v", err)
+// EncryptionKeyID derives the identifier prepended to ciphertext so the
+// matching key can be found after rotation without storing it separately.
+func EncryptionKeyID(key []byte) string {
+	digest := sha256.Sum256(key)
+	return hex.EncodeToString(digest[:4])
+}
+
+func (e *EncryptionManager) KeyID() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.keyID
+}
+
+// RotateKey makes newKey the primary encryption key. The previous primary is
+// kept as a retired, decrypt-only key.
+func (e *EncryptionManager) RotateKey(newKey []byte) error {
+	if len(newKey) != 32 {
+		return ErrInvalidEncryptionKey
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	newID := EncryptionKeyID(newKey)
+	if newID == e.keyID {
+		return nil
+	}
+	e.retired[e.keyID] = e.key
+	delete(e.retired, newID)
+	e.keyID = newID
+	e.key = newKey
+	return nil
+}
+
+func (e *EncryptionManager) RetiredKeyIDs() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ids := make([]string, 0, len(e.retired))
+	for id := range e.retired {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CiphertextKeyID returns the key ID prefix of a ciphertext produced by
+// Encrypt, or an empty string for ciphertext written before key IDs existed.
+func CiphertextKeyID(ciphertext string) string {
+	keyID, _, found := strings.Cut(ciphertext, ":")
+	if !found {
+		return ""
+	}
+	return keyID
+}
+
+func (e *EncryptionManager) Encrypt(plaintext string) (string, error) {
+	e.mu.RLock()
+	keyID, key := e.keyID, e.key
+	e.mu.RUnlock()
+	return encryptWithKey(keyID, key, plaintext)
+}
+
+func encryptWithKey(keyID string, key []byte, plaintext string) (string, error) {
+	gcm, err := createGCM(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %v", err)
+		return "", err
 	}
 
 	nonce := make([]byte, gcm.NonceSize())
@@ -39,23 +129,77 @@ func (e *EncryptionManager) Encrypt(plaintext string) (string, error) {
 	}
 
 	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return keyID + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
 func (e *EncryptionManager) Decrypt(ciphertext string) (string, error) {
-	data, err := base64.StdEncoding.DecodeString(ciphertext)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode base64: %v", err)
+	keyID, encoded, found := strings.Cut(ciphertext, ":")
+	if !found {
+		return e.decryptLegacy(ciphertext)
+	}
+
+	key, ok := e.keyFor(keyID)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownEncryptionKey, keyID)
 	}
+	return decryptWithKey(key, encoded)
+}
+
+func (e *EncryptionManager) keyFor(keyID string) ([]byte, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if keyID == e.keyID {
+		return e.key, true
+	}
+	key, ok := e.retired[keyID]
+	return key, ok
+}
 
-	block, err := aes.NewCipher(e.key)
+// decryptLegacy handles ciphertext without a key ID by trying the primary key
+// first and then every retired key.
+func (e *EncryptionManager) decryptLegacy(ciphertext string) (string, error) {
+	e.mu.RLock()
+	keys := make([][]byte, 0, len(e.retired)+1)
+	keys = append(keys, e.key)
+	for _, key := range e.retired {
+		keys = append(keys, key)
+	}
+	e.mu.RUnlock()
+
+	var lastErr error
+	for _, key := range keys {
+		plaintext, err := decryptWithKey(key, ciphertext)
+		if err == nil {
+			return plaintext, nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+func createGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %v", err)
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
 	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %v", err)
+		return nil, fmt.Errorf("failed to create GCM: %v", err)
+	}
+	return gcm, nil
+}
+
+func decryptWithKey(key []byte, encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode base64: %v", err)
+	}
+
+	gcm, err := createGCM(key)
+	if err != nil {
+		return "", err
 	}
 
 	nonceSize := gcm.NonceSize()
@@ -0,0 +1,145 @@
+package security
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := CreateGenerateEncryptionKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func TestEncryptPrependsKeyID(t *testing.T) {
+	key := testKey(t)
+	e, err := CreateEncryptionManager(key)
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+
+	ciphertext, err := e.Encrypt("secret")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !strings.HasPrefix(ciphertext, EncryptionKeyID(key)+":") {
+		t.Fatalf("expected ciphertext to start with key ID %s, got %q", EncryptionKeyID(key), ciphertext)
+	}
+	if CiphertextKeyID(ciphertext) != e.KeyID() {
+		t.Fatalf("expected ciphertext key ID %s, got %s", e.KeyID(), CiphertextKeyID(ciphertext))
+	}
+}
+
+func TestRotateKeyDecryptsDataWrittenWithOldKey(t *testing.T) {
+	oldKey, newKey := testKey(t), testKey(t)
+	e, err := CreateEncryptionManager(oldKey)
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+
+	oldCiphertext, err := e.Encrypt("written before rotation")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	if err := e.RotateKey(newKey); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if e.KeyID() != EncryptionKeyID(newKey) {
+		t.Fatalf("expected primary key ID %s, got %s", EncryptionKeyID(newKey), e.KeyID())
+	}
+	if ids := e.RetiredKeyIDs(); len(ids) != 1 || ids[0] != EncryptionKeyID(oldKey) {
+		t.Fatalf("expected old key to be retired, got %v", ids)
+	}
+
+	newCiphertext, err := e.Encrypt("written after rotation")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if CiphertextKeyID(newCiphertext) != EncryptionKeyID(newKey) {
+		t.Fatal("expected new data to be encrypted with the new key")
+	}
+
+	for ciphertext, want := range map[string]string{
+		oldCiphertext: "written before rotation",
+		newCiphertext: "written after rotation",
+	} {
+		got, err := e.Decrypt(ciphertext)
+		if err != nil {
+			t.Fatalf("decrypt: %v", err)
+		}
+		if got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestDecryptWithRetiredKeyFromConstructor(t *testing.T) {
+	oldKey := testKey(t)
+	old, err := CreateEncryptionManager(oldKey)
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	ciphertext, err := old.Encrypt("legacy")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	withoutRetired, err := CreateEncryptionManager(testKey(t))
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	if _, err := withoutRetired.Decrypt(ciphertext); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Fatalf("expected ErrUnknownEncryptionKey, got %v", err)
+	}
+
+	withRetired, err := CreateEncryptionManager(testKey(t), oldKey)
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	got, err := withRetired.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if got != "legacy" {
+		t.Fatalf("expected legacy, got %q", got)
+	}
+}
+
+func TestDecryptCiphertextWithoutKeyID(t *testing.T) {
+	key := testKey(t)
+	e, err := CreateEncryptionManager(key)
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	ciphertext, err := e.Encrypt("unprefixed")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	_, encoded, _ := strings.Cut(ciphertext, ":")
+
+	if err := e.RotateKey(testKey(t)); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	got, err := e.Decrypt(encoded)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if got != "unprefixed" {
+		t.Fatalf("expected unprefixed, got %q", got)
+	}
+}
+
+func TestRotateKeyRejectsInvalidKey(t *testing.T) {
+	e, err := CreateEncryptionManager(testKey(t))
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	if err := e.RotateKey([]byte("short")); !errors.Is(err, ErrInvalidEncryptionKey) {
+		t.Fatalf("expected ErrInvalidEncryptionKey, got %v", err)
+	}
+}
@@ -1,22 +1,41 @@
 package security
 
 import (
+	"context"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 )
 
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrTokenRevoked           = errors.New("token has been revoked")
+	ErrInvalidTokenType       = errors.New("invalid token type")
+	ErrRevocationUnavailable  = errors.New("token revocation is not configured")
+	ErrTokenMissingIdentifier = errors.New("token has no identifier")
+)
+
 type JWTManager struct {
-	secretKey string
-	issuer    string
-	audience  string
+	secretKey  string
+	issuer     string
+	audience   string
+	revocation *TokenRevocationList
 }
 
 type Claims struct {
+	ID        string   `json:"jti,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
 	UserID    string   `json:"user_id"`
 	Email     string   `json:"email"`
 	Roles     []string `json:"roles"`
@@ -35,9 +54,110 @@ func CreateJWTManager(secretKey, issuer, audience string) *JWTManager {
 	}
 }
 
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	AccessExpiresAt  time.Time
+	RefreshExpiresAt time.Time
+}
+
+func (j *JWTManager) SetRevocationList(revocation *TokenRevocationList) {
+	j.revocation = revocation
+}
+
 func (j *JWTManager) GenerateToken(userID, email string, roles []string, apiKey string, duration time.Duration) (string, error) {
+	return j.generateToken(TokenTypeAccess, userID, email, roles, apiKey, duration)
+}
+
+func (j *JWTManager) IssueTokenPair(userID, email string, roles []string, apiKey string, accessDuration, refreshDuration time.Duration) (*TokenPair, error) {
+	now := time.Now()
+	accessToken, err := j.generateToken(TokenTypeAccess, userID, email, roles, apiKey, accessDuration)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := j.generateToken(TokenTypeRefresh, userID, email, roles, apiKey, refreshDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		AccessExpiresAt:  now.Add(accessDuration),
+		RefreshExpiresAt: now.Add(refreshDuration),
+	}, nil
+}
+
+// Refresh validates a refresh token and issues a new access token for the
+// same subject. The refresh token itself stays valid until it expires or is
+// revoked.
+func (j *JWTManager) Refresh(ctx context.Context, refreshToken string, accessDuration time.Duration) (string, error) {
+	claims, err := j.validate(ctx, refreshToken, TokenTypeRefresh)
+	if err != nil {
+		return "", err
+	}
+	return j.GenerateToken(claims.UserID, claims.Email, claims.Roles, claims.APIKey, accessDuration)
+}
+
+// ValidateAccessToken validates the signature and expiry of an access token
+// and rejects refresh tokens and tokens on the revocation list.
+func (j *JWTManager) ValidateAccessToken(ctx context.Context, tokenString string) (*Claims, error) {
+	return j.validate(ctx, tokenString, TokenTypeAccess)
+}
+
+func (j *JWTManager) Revoke(ctx context.Context, tokenString string) error {
+	if j.revocation == nil {
+		return ErrRevocationUnavailable
+	}
+
+	claims, err := j.ValidateToken(tokenString)
+	if err != nil {
+		return err
+	}
+	if claims.ID == "" {
+		return ErrTokenMissingIdentifier
+	}
+	return j.revocation.Revoke(ctx, claims.ID, time.Unix(claims.ExpiresAt, 0))
+}
+
+func (j *JWTManager) validate(ctx context.Context, tokenString, tokenType string) (*Claims, error) {
+	claims, err := j.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens issued before token types existed are access tokens.
+	claimsType := claims.TokenType
+	if claimsType == "" {
+		claimsType = TokenTypeAccess
+	}
+	if claimsType != tokenType {
+		return nil, ErrInvalidTokenType
+	}
+
+	if j.revocation != nil && claims.ID != "" {
+		revoked, err := j.revocation.IsRevoked(ctx, claims.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
+}
+
+func (j *JWTManager) generateToken(tokenType, userID, email string, roles []string, apiKey string, duration time.Duration) (string, error) {
+	tokenID, err := generateTokenID()
+	if err != nil {
+		return "", err
+	}
+
 	now := time.Now()
 	claims := &Claims{
+		ID:        tokenID,
+		TokenType: tokenType,
 		UserID:    userID,
 		Email:     email,
 		Roles:     roles,
@@ -94,13 +214,12 @@ func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 	return &claims, nil
 }
 
-func (j *JWTManager) RefreshToken(tokenString string, duration time.Duration) (string, error) {
-	claims, err := j.ValidateToken(tokenString)
-	if err != nil {
-		return "", fmt.Errorf("invalid token for refresh: %v", err)
+func generateTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %v", err)
 	}
-
-	return j.GenerateToken(claims.UserID, claims.Email, claims.Roles, claims.APIKey, duration)
+	return hex.EncodeToString(b), nil
 }
 
 func (j *JWTManager) sign(message string) string {
@@ -0,0 +1,116 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryRevocationStore struct {
+	mu   sync.Mutex
+	keys map[string]time.Time
+}
+
+func newMemoryRevocationStore() *memoryRevocationStore {
+	return &memoryRevocationStore{keys: make(map[string]time.Time)}
+}
+
+func (m *memoryRevocationStore) SetWithTTL(_ context.Context, key string, _ interface{}, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key] = time.Now().Add(ttl)
+	return nil
+}
+
+func (m *memoryRevocationStore) Exists(_ context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expiresAt, ok := m.keys[key]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func testJWTManager() *JWTManager {
+	j := CreateJWTManager("test-secret", "conductor", "conductor-api")
+	j.SetRevocationList(CreateTokenRevocationList(newMemoryRevocationStore()))
+	return j
+}
+
+func TestRefreshIssuesNewAccessToken(t *testing.T) {
+	j := testJWTManager()
+	ctx := context.Background()
+
+	pair, err := j.IssueTokenPair("tenant_1", "Acme", []string{"standard"}, "key_1", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("issue pair: %v", err)
+	}
+
+	accessToken, err := j.Refresh(ctx, pair.RefreshToken, time.Minute)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	claims, err := j.ValidateAccessToken(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("validate refreshed token: %v", err)
+	}
+	if claims.UserID != "tenant_1" || claims.APIKey != "key_1" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	if _, err := j.Refresh(ctx, pair.AccessToken, time.Minute); !errors.Is(err, ErrInvalidTokenType) {
+		t.Fatalf("expected access token to be rejected for refresh, got %v", err)
+	}
+	if _, err := j.ValidateAccessToken(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidTokenType) {
+		t.Fatalf("expected refresh token to be rejected as access token, got %v", err)
+	}
+}
+
+func TestRevokedTokenIsRejected(t *testing.T) {
+	j := testJWTManager()
+	ctx := context.Background()
+
+	pair, err := j.IssueTokenPair("tenant_1", "Acme", nil, "key_1", time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("issue pair: %v", err)
+	}
+
+	if err := j.Revoke(ctx, pair.AccessToken); err != nil {
+		t.Fatalf("revoke access token: %v", err)
+	}
+	if _, err := j.ValidateAccessToken(ctx, pair.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected revoked access token to be rejected, got %v", err)
+	}
+
+	if err := j.Revoke(ctx, pair.RefreshToken); err != nil {
+		t.Fatalf("revoke refresh token: %v", err)
+	}
+	if _, err := j.Refresh(ctx, pair.RefreshToken, time.Minute); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected revoked refresh token to be rejected, got %v", err)
+	}
+}
+
+func TestExpiredRefreshTokenIsRejected(t *testing.T) {
+	j := testJWTManager()
+
+	pair, err := j.IssueTokenPair("tenant_1", "Acme", nil, "key_1", time.Minute, -time.Second)
+	if err != nil {
+		t.Fatalf("issue pair: %v", err)
+	}
+
+	if _, err := j.Refresh(context.Background(), pair.RefreshToken, time.Minute); err == nil {
+		t.Fatal("expected expired refresh token to be rejected")
+	}
+}
+
+func TestRevokeWithoutRevocationList(t *testing.T) {
+	j := CreateJWTManager("test-secret", "conductor", "conductor-api")
+
+	token, err := j.GenerateToken("tenant_1", "Acme", nil, "key_1", time.Minute)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	if err := j.Revoke(context.Background(), token); !errors.Is(err, ErrRevocationUnavailable) {
+		t.Fatalf("expected ErrRevocationUnavailable, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package security
 
 import (
 	"context"
+	"math"
 	"sync"
 	"time"
 
@@ -28,7 +29,24 @@ func CreateRateLimiter() *RateLimiter {
 	return rl
 }
 
+// RateLimitResult describes one limiter decision so callers can tell clients
+// how long to back off. Reset is the time until the bucket is full again and
+// RetryAfter the time until the next token, which is zero when allowed.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
 func (rl *RateLimiter) Allow(key string, config RateLimitConfig) bool {
+	return rl.Take(key, config).Allowed
+}
+
+// Take consumes a token for key if one is available and reports the bucket
+// state either way.
+func (rl *RateLimiter) Take(key string, config RateLimitConfig) RateLimitResult {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
@@ -36,9 +54,44 @@ func (rl *RateLimiter) Allow(key string, config RateLimitConfig) bool {
 	if !exists {
 		limiter = rate.NewLimiter(rate.Limit(config.RequestsPerSecond), config.Burst)
 		rl.limiters[key] = limiter
+	} else {
+		applyRateLimitConfig(limiter, config)
+	}
+
+	now := time.Now()
+	result := RateLimitResult{Allowed: true, Limit: limiter.Burst()}
+	reservation := limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		result.Allowed = false
+	} else if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		result.Allowed = false
+		result.RetryAfter = delay
+	}
+
+	tokens := limiter.TokensAt(now)
+	if tokens > 0 {
+		result.Remaining = int(math.Floor(tokens))
 	}
+	result.Reset = refillDuration(limiter, tokens)
+	return result
+}
 
-	return limiter.Allow()
+func refillDuration(limiter *rate.Limiter, tokens float64) time.Duration {
+	missing := float64(limiter.Burst()) - tokens
+	if missing <= 0 || limiter.Limit() <= 0 {
+		return 0
+	}
+	return time.Duration(missing / float64(limiter.Limit()) * float64(time.Second))
+}
+
+func applyRateLimitConfig(limiter *rate.Limiter, config RateLimitConfig) {
+	if limiter.Limit() != rate.Limit(config.RequestsPerSecond) {
+		limiter.SetLimit(rate.Limit(config.RequestsPerSecond))
+	}
+	if limiter.Burst() != config.Burst {
+		limiter.SetBurst(config.Burst)
+	}
 }
 
 func (rl *RateLimiter) Wait(ctx context.Context, key string, config RateLimitConfig) error {
@@ -101,40 +154,155 @@ func (rl *RateLimiter) startCleanup() {
 	})
 }
 
+// Reset drops every bucket so the next request for a key starts from the
+// current config with a full burst.
+func (rl *RateLimiter) Reset() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limiters = make(map[string]*rate.Limiter)
+}
+
 func (rl *RateLimiter) Close() {
 	if rl.cleanup != nil {
 		rl.cleanup.Stop()
 	}
 }
 
+const DefaultRateLimitTier = "default"
+
+// DefaultRouteLimits throttles the endpoints that call out to paid APIs or
+// fan out to providers.
+func DefaultRouteLimits() map[string]RateLimitConfig {
+	return map[string]RateLimitConfig{
+		"/v1/fraud/analyze": {RequestsPerSecond: 2, Burst: 5, Window: time.Minute},
+		"/v1/charges":       {RequestsPerSecond: 5, Burst: 10, Window: time.Minute},
+		"/v1/charges/batch": {RequestsPerSecond: 1, Burst: 2, Window: time.Minute},
+		"/v1/authorize":     {RequestsPerSecond: 5, Burst: 10, Window: time.Minute},
+	}
+}
+
 type TieredRateLimiter struct {
-	tiers map[string]RateLimitConfig
-	rl    *RateLimiter
+	tiers  map[string]RateLimitConfig
+	routes map[string]RateLimitConfig
+	ip     RateLimitConfig
+	mu     sync.RWMutex
+	rl     *RateLimiter
 }
 
 func CreateTieredRateLimiter(tiers map[string]RateLimitConfig) *TieredRateLimiter {
 	return &TieredRateLimiter{
-		tiers: tiers,
-		rl:    CreateRateLimiter(),
+		tiers:  copyTiers(tiers),
+		routes: make(map[string]RateLimitConfig),
+		rl:     CreateRateLimiter(),
 	}
 }
 
-func (trl *TieredRateLimiter) Allow(key, tier string) bool {
+func copyTiers(tiers map[string]RateLimitConfig) map[string]RateLimitConfig {
+	copied := make(map[string]RateLimitConfig, len(tiers))
+	for name, config := range tiers {
+		copied[name] = config
+	}
+	return copied
+}
+
+// SetTiers hot-reloads the tier definitions. Existing buckets are dropped so
+// a raised burst takes effect immediately rather than after the old bucket
+// refills.
+func (trl *TieredRateLimiter) SetTiers(tiers map[string]RateLimitConfig) {
+	trl.mu.Lock()
+	trl.tiers = copyTiers(tiers)
+	trl.mu.Unlock()
+	trl.rl.Reset()
+}
+
+func (trl *TieredRateLimiter) Tiers() map[string]RateLimitConfig {
+	trl.mu.RLock()
+	defer trl.mu.RUnlock()
+	return copyTiers(trl.tiers)
+}
+
+func (trl *TieredRateLimiter) tierConfig(tier string) RateLimitConfig {
+	trl.mu.RLock()
+	defer trl.mu.RUnlock()
+
 	config, exists := trl.tiers[tier]
 	if !exists {
-		config = trl.tiers["default"]
+		config = trl.tiers[DefaultRateLimitTier]
 	}
+	return config
+}
 
-	return trl.rl.Allow(key, config)
+func (trl *TieredRateLimiter) SetRouteLimits(routes map[string]RateLimitConfig) {
+	trl.mu.Lock()
+	defer trl.mu.Unlock()
+	trl.routes = copyTiers(routes)
 }
 
-func (trl *TieredRateLimiter) Wait(ctx context.Context, key, tier string) error {
-	config, exists := trl.tiers[tier]
+func (trl *TieredRateLimiter) RouteLimits() map[string]RateLimitConfig {
+	trl.mu.RLock()
+	defer trl.mu.RUnlock()
+	return copyTiers(trl.routes)
+}
+
+func (trl *TieredRateLimiter) routeConfig(route string) (RateLimitConfig, bool) {
+	trl.mu.RLock()
+	defer trl.mu.RUnlock()
+
+	config, exists := trl.routes[route]
+	return config, exists
+}
+
+// SetIPLimit configures the per-client-IP limit applied before
+// authentication. A zero config disables it.
+func (trl *TieredRateLimiter) SetIPLimit(config RateLimitConfig) {
+	trl.mu.Lock()
+	defer trl.mu.Unlock()
+	trl.ip = config
+}
+
+func (trl *TieredRateLimiter) AllowIP(ip string) bool {
+	result, _ := trl.TakeIP(ip)
+	return result.Allowed
+}
+
+// TakeIP reports false as its second value when no IP limit is configured.
+func (trl *TieredRateLimiter) TakeIP(ip string) (RateLimitResult, bool) {
+	trl.mu.RLock()
+	config := trl.ip
+	trl.mu.RUnlock()
+
+	if config.RequestsPerSecond <= 0 || config.Burst <= 0 {
+		return RateLimitResult{Allowed: true}, false
+	}
+	return trl.rl.Take("ip_"+ip, config), true
+}
+
+// AllowRoute applies the limit configured for a route template on top of the
+// tier limit. Routes without a configured limit are always allowed.
+func (trl *TieredRateLimiter) AllowRoute(key, route string) bool {
+	result, _ := trl.TakeRoute(key, route)
+	return result.Allowed
+}
+
+// TakeRoute reports false as its second value when the route has no limit.
+func (trl *TieredRateLimiter) TakeRoute(key, route string) (RateLimitResult, bool) {
+	config, exists := trl.routeConfig(route)
 	if !exists {
-		config = trl.tiers["default"]
+		return RateLimitResult{Allowed: true}, false
 	}
+	return trl.rl.Take(key+"|"+route, config), true
+}
+
+func (trl *TieredRateLimiter) Allow(key, tier string) bool {
+	return trl.Take(key, tier).Allowed
+}
 
-	return trl.rl.Wait(ctx, key, config)
+func (trl *TieredRateLimiter) Take(key, tier string) RateLimitResult {
+	return trl.rl.Take(key, trl.tierConfig(tier))
+}
+
+func (trl *TieredRateLimiter) Wait(ctx context.Context, key, tier string) error {
+	return trl.rl.Wait(ctx, key, trl.tierConfig(tier))
 }
 
 func (trl *TieredRateLimiter) GetStats(key, tier string) (int, time.Duration, bool) {
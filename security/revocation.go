@@ -0,0 +1,37 @@
+package security
+
+import (
+	"context"
+	"time"
+)
+
+// RevocationStore is the subset of the Redis cache used to persist revoked
+// token IDs until the tokens would have expired anyway.
+type RevocationStore interface {
+	SetWithTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+type TokenRevocationList struct {
+	store RevocationStore
+}
+
+func CreateTokenRevocationList(store RevocationStore) *TokenRevocationList {
+	return &TokenRevocationList{store: store}
+}
+
+func (l *TokenRevocationList) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return l.store.SetWithTTL(ctx, revocationKey(tokenID), "1", ttl)
+}
+
+func (l *TokenRevocationList) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	return l.store.Exists(ctx, revocationKey(tokenID))
+}
+
+func revocationKey(tokenID string) string {
+	return "jwt:revoked:" + tokenID
+}
@@ -0,0 +1,224 @@
+package security
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+)
+
+// tenantKeyCacheTTL bounds how long a tenant's keys are served from memory,
+// so a rotation on another instance is picked up for new writes within it.
+const tenantKeyCacheTTL = 5 * time.Minute
+
+var ErrTenantKeysNotConfigured = errors.New("tenant encryption keys are not configured")
+
+// TenantKeyStore persists tenants' data keys, wrapped by the master key.
+// AddTenantKey makes the key the tenant's primary and demotes the previous
+// one.
+type TenantKeyStore interface {
+	ListTenantKeys(ctx context.Context, tenantID string) ([]*models.TenantDataKey, error)
+	AddTenantKey(ctx context.Context, key *models.TenantDataKey) error
+}
+
+type tenantKeyring struct {
+	primaryID string
+	keys      map[string][]byte
+	loadedAt  time.Time
+}
+
+// SetTenantKeyStore turns on envelope encryption: EncryptContext and
+// DecryptContext use a data key of the tenant in the context, created on
+// first use and stored wrapped by the master key, so one tenant's data key
+// exposes only that tenant's data.
+func (e *EncryptionManager) SetTenantKeyStore(store TenantKeyStore) {
+	e.tenantMu.Lock()
+	defer e.tenantMu.Unlock()
+	e.tenantKeys = store
+	e.tenants = make(map[string]*tenantKeyring)
+}
+
+// EncryptContext encrypts with the data key of the tenant in ctx, or with the
+// master key when there is no tenant or tenant keys are off.
+func (e *EncryptionManager) EncryptContext(ctx context.Context, plaintext string) (string, error) {
+	tenantID, ok := e.tenantFrom(ctx)
+	if !ok {
+		return e.Encrypt(plaintext)
+	}
+
+	ring, err := e.keyringFor(ctx, tenantID, true)
+	if err != nil {
+		return "", err
+	}
+	return encryptWithKey(ring.primaryID, ring.keys[ring.primaryID], plaintext)
+}
+
+// DecryptContext decrypts ciphertext written by EncryptContext or Encrypt.
+// Ciphertext under a tenant data key only decrypts when that tenant is in
+// ctx; data written under the master key before the tenant had a key stays
+// readable.
+func (e *EncryptionManager) DecryptContext(ctx context.Context, ciphertext string) (string, error) {
+	keyID, encoded, found := strings.Cut(ciphertext, ":")
+	if !found {
+		return e.decryptLegacy(ciphertext)
+	}
+	if key, ok := e.keyFor(keyID); ok {
+		return decryptWithKey(key, encoded)
+	}
+
+	tenantID, ok := e.tenantFrom(ctx)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownEncryptionKey, keyID)
+	}
+	ring, err := e.keyringFor(ctx, tenantID, false)
+	if err != nil {
+		return "", err
+	}
+	key, ok := ring.keys[keyID]
+	if !ok {
+		// The key may have been added by another instance since the
+		// tenant's keys were cached.
+		if ring, err = e.reloadTenantKeyring(ctx, tenantID); err != nil {
+			return "", err
+		}
+		if key, ok = ring.keys[keyID]; !ok {
+			return "", fmt.Errorf("%w: %s", ErrUnknownEncryptionKey, keyID)
+		}
+	}
+	return decryptWithKey(key, encoded)
+}
+
+// KeyIDContext returns the ID of the key EncryptContext would use for ctx.
+func (e *EncryptionManager) KeyIDContext(ctx context.Context) (string, error) {
+	tenantID, ok := e.tenantFrom(ctx)
+	if !ok {
+		return e.KeyID(), nil
+	}
+	ring, err := e.keyringFor(ctx, tenantID, true)
+	if err != nil {
+		return "", err
+	}
+	return ring.primaryID, nil
+}
+
+// RotateTenantKey gives the tenant a new primary data key and returns its ID.
+// Other tenants' keys are untouched, and the replaced key keeps decrypting
+// the tenant's existing data until it is re-encrypted under the new one.
+func (e *EncryptionManager) RotateTenantKey(ctx context.Context, tenantID string) (string, error) {
+	store := e.tenantKeyStore()
+	if store == nil {
+		return "", ErrTenantKeysNotConfigured
+	}
+
+	if _, err := e.addTenantKey(ctx, store, tenantID); err != nil {
+		return "", err
+	}
+	ring, err := e.reloadTenantKeyring(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+	return ring.primaryID, nil
+}
+
+// TenantKeysEnabled reports whether a tenant key store is set.
+func (e *EncryptionManager) TenantKeysEnabled() bool {
+	return e.tenantKeyStore() != nil
+}
+
+func (e *EncryptionManager) tenantFrom(ctx context.Context) (string, bool) {
+	if !e.TenantKeysEnabled() {
+		return "", false
+	}
+	tenantID, ok := ctx.Value(ctxkeys.TenantID).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+func (e *EncryptionManager) tenantKeyStore() TenantKeyStore {
+	e.tenantMu.RLock()
+	defer e.tenantMu.RUnlock()
+	return e.tenantKeys
+}
+
+// keyringFor returns the tenant's cached keys, loading them when missing
+// or stale. With create, a tenant without a primary key is given one.
+func (e *EncryptionManager) keyringFor(ctx context.Context, tenantID string, create bool) (*tenantKeyring, error) {
+	e.tenantMu.RLock()
+	ring, ok := e.tenants[tenantID]
+	e.tenantMu.RUnlock()
+	if ok && time.Since(ring.loadedAt) < tenantKeyCacheTTL && (ring.primaryID != "" || !create) {
+		return ring, nil
+	}
+
+	ring, err := e.reloadTenantKeyring(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if ring.primaryID != "" || !create {
+		return ring, nil
+	}
+
+	if _, err := e.addTenantKey(ctx, e.tenantKeyStore(), tenantID); err != nil {
+		// Another instance may have created the tenant's first key at the
+		// same time; use it if so.
+		ring, reloadErr := e.reloadTenantKeyring(ctx, tenantID)
+		if reloadErr != nil || ring.primaryID == "" {
+			return nil, err
+		}
+		return ring, nil
+	}
+	return e.reloadTenantKeyring(ctx, tenantID)
+}
+
+func (e *EncryptionManager) reloadTenantKeyring(ctx context.Context, tenantID string) (*tenantKeyring, error) {
+	stored, err := e.tenantKeyStore().ListTenantKeys(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tenant keys: %w", err)
+	}
+
+	ring := &tenantKeyring{keys: make(map[string][]byte, len(stored)), loadedAt: time.Now()}
+	for _, dataKey := range stored {
+		unwrapped, err := e.Decrypt(dataKey.WrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap tenant key %s: %w", dataKey.KeyID, err)
+		}
+		key, err := base64.StdEncoding.DecodeString(unwrapped)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("failed to unwrap tenant key %s: %w", dataKey.KeyID, ErrInvalidEncryptionKey)
+		}
+		ring.keys[dataKey.KeyID] = key
+		if dataKey.Primary {
+			ring.primaryID = dataKey.KeyID
+		}
+	}
+
+	e.tenantMu.Lock()
+	e.tenants[tenantID] = ring
+	e.tenantMu.Unlock()
+	return ring, nil
+}
+
+func (e *EncryptionManager) addTenantKey(ctx context.Context, store TenantKeyStore, tenantID string) (*models.TenantDataKey, error) {
+	dataKey, err := CreateGenerateEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := e.Encrypt(base64.StdEncoding.EncodeToString(dataKey))
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &models.TenantDataKey{
+		TenantID:   tenantID,
+		KeyID:      EncryptionKeyID(dataKey),
+		WrappedKey: wrapped,
+	}
+	if err := store.AddTenantKey(ctx, stored); err != nil {
+		return nil, fmt.Errorf("failed to store tenant key: %w", err)
+	}
+	return stored, nil
+}
@@ -0,0 +1,147 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+)
+
+type memoryTenantKeyStore struct {
+	keys []*models.TenantDataKey
+}
+
+func (s *memoryTenantKeyStore) ListTenantKeys(_ context.Context, tenantID string) ([]*models.TenantDataKey, error) {
+	var keys []*models.TenantDataKey
+	for _, key := range s.keys {
+		if key.TenantID == tenantID {
+			copied := *key
+			keys = append(keys, &copied)
+		}
+	}
+	return keys, nil
+}
+
+func (s *memoryTenantKeyStore) AddTenantKey(_ context.Context, key *models.TenantDataKey) error {
+	for _, stored := range s.keys {
+		if stored.TenantID == key.TenantID {
+			stored.Primary = false
+		}
+	}
+	key.Primary = true
+	copied := *key
+	s.keys = append(s.keys, &copied)
+	return nil
+}
+
+func tenantContext(tenantID string) context.Context {
+	return context.WithValue(context.Background(), ctxkeys.TenantID, tenantID)
+}
+
+func tenantKeyManager(t *testing.T) (*EncryptionManager, *memoryTenantKeyStore) {
+	t.Helper()
+	e, err := CreateEncryptionManager(testKey(t))
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	store := &memoryTenantKeyStore{}
+	e.SetTenantKeyStore(store)
+	return e, store
+}
+
+func TestTenantDataCannotBeDecryptedWithAnotherTenantsKey(t *testing.T) {
+	e, _ := tenantKeyManager(t)
+	tenantA, tenantB := tenantContext("ten_a"), tenantContext("ten_b")
+
+	ciphertext, err := e.EncryptContext(tenantA, "jane@example.com")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if CiphertextKeyID(ciphertext) == e.KeyID() {
+		t.Fatal("expected tenant data to be encrypted under the tenant's key, not the master key")
+	}
+	if _, err := e.EncryptContext(tenantB, "john@example.com"); err != nil {
+		t.Fatalf("encrypt for the other tenant: %v", err)
+	}
+
+	if _, err := e.DecryptContext(tenantB, ciphertext); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Fatalf("expected another tenant's key to be unable to decrypt, got %v", err)
+	}
+	if _, err := e.DecryptContext(context.Background(), ciphertext); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Fatalf("expected decryption without a tenant to fail, got %v", err)
+	}
+	if _, err := e.Decrypt(ciphertext); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Fatalf("expected the master key to be unable to decrypt, got %v", err)
+	}
+
+	plaintext, err := e.DecryptContext(tenantA, ciphertext)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if plaintext != "jane@example.com" {
+		t.Fatalf("expected original plaintext, got %q", plaintext)
+	}
+}
+
+func TestRotateTenantKeyLeavesOtherTenantsAlone(t *testing.T) {
+	e, _ := tenantKeyManager(t)
+	tenantA, tenantB := tenantContext("ten_a"), tenantContext("ten_b")
+
+	before, err := e.EncryptContext(tenantA, "written before rotation")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	otherKeyID, err := e.KeyIDContext(tenantB)
+	if err != nil {
+		t.Fatalf("tenant b key: %v", err)
+	}
+
+	newKeyID, err := e.RotateTenantKey(context.Background(), "ten_a")
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if newKeyID == CiphertextKeyID(before) {
+		t.Fatal("expected rotation to produce a new key")
+	}
+	if keyID, _ := e.KeyIDContext(tenantB); keyID != otherKeyID {
+		t.Fatalf("expected tenant b to keep key %s, got %s", otherKeyID, keyID)
+	}
+
+	after, err := e.EncryptContext(tenantA, "written after rotation")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if CiphertextKeyID(after) != newKeyID {
+		t.Fatalf("expected new data under key %s, got %s", newKeyID, CiphertextKeyID(after))
+	}
+	if plaintext, err := e.DecryptContext(tenantA, before); err != nil || plaintext != "written before rotation" {
+		t.Fatalf("expected data under the replaced key to stay readable, got %q, %v", plaintext, err)
+	}
+}
+
+func TestTenantKeysAreReadAfterMasterKeyRotation(t *testing.T) {
+	e, store := tenantKeyManager(t)
+	ctx := tenantContext("ten_a")
+
+	ciphertext, err := e.EncryptContext(ctx, "4242")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	masterKey := e.key
+	if err := e.RotateKey(testKey(t)); err != nil {
+		t.Fatalf("rotate master key: %v", err)
+	}
+
+	// A fresh manager has no cached tenant keys and must unwrap them with
+	// the retired master key.
+	restarted, err := CreateEncryptionManager(e.key, masterKey)
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	restarted.SetTenantKeyStore(store)
+	if plaintext, err := restarted.DecryptContext(ctx, ciphertext); err != nil || plaintext != "4242" {
+		t.Fatalf("expected tenant data to decrypt after master key rotation, got %q, %v", plaintext, err)
+	}
+}
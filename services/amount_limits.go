@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+var ErrAmountOutOfRange = errors.New("amount out of range")
+
+const defaultMaxChargeAmount = 99999999
+
+// DefaultAmountLimits follows each processor's published minimums so that
+// charges it would reject never leave the service. Limits are keyed by
+// provider and then currency; pairs without an entry are only checked for a
+// positive amount.
+func DefaultAmountLimits() map[string]map[string]models.AmountLimit {
+	return map[string]map[string]models.AmountLimit{
+		"stripe": {
+			"USD": {Min: 50, Max: defaultMaxChargeAmount},
+			"EUR": {Min: 50, Max: defaultMaxChargeAmount},
+			"GBP": {Min: 30, Max: defaultMaxChargeAmount},
+			"CAD": {Min: 50, Max: defaultMaxChargeAmount},
+			"AUD": {Min: 50, Max: defaultMaxChargeAmount},
+			"NZD": {Min: 50, Max: defaultMaxChargeAmount},
+			"CHF": {Min: 50, Max: defaultMaxChargeAmount},
+			"SGD": {Min: 50, Max: defaultMaxChargeAmount},
+			"HKD": {Min: 400, Max: defaultMaxChargeAmount},
+			"INR": {Min: 50, Max: defaultMaxChargeAmount},
+			"JPY": {Min: 50, Max: defaultMaxChargeAmount},
+		},
+		"razorpay": {
+			"INR": {Min: 100, Max: defaultMaxChargeAmount},
+		},
+	}
+}
+
+// SetAmountLimits overrides the default limits for the given provider and
+// currency pairs.
+func (s *PaymentService) SetAmountLimits(overrides map[string]map[string]models.AmountLimit) {
+	limits := DefaultAmountLimits()
+	for provider, currencies := range overrides {
+		provider = strings.ToLower(provider)
+		if limits[provider] == nil {
+			limits[provider] = make(map[string]models.AmountLimit, len(currencies))
+		}
+		for currency, limit := range currencies {
+			limits[provider][strings.ToUpper(currency)] = limit
+		}
+	}
+	s.amountLimits = limits
+}
+
+// SetCurrencyExponents overrides the number of decimals used when formatting
+// amounts for the given currencies.
+func (s *PaymentService) SetCurrencyExponents(overrides map[string]int) {
+	exponents := make(map[string]int, len(overrides))
+	for currency, exponent := range overrides {
+		exponents[strings.ToUpper(currency)] = exponent
+	}
+	s.currencyExponents = exponents
+}
+
+// limitProvider names the provider whose limits apply: the one requested,
+// else the one a selector would route the currency to.
+func (s *PaymentService) limitProvider(ctx context.Context, req *models.ChargeRequest) string {
+	if req.Provider != "" {
+		return strings.ToLower(req.Provider)
+	}
+	if resolver, ok := s.provider.(providers.ProviderResolver); ok {
+		if provider, err := resolver.ProviderForCurrency(ctx, req.Currency); err == nil && provider != nil {
+			return provider.Name()
+		}
+	}
+	return s.provider.Name()
+}
+
+func (s *PaymentService) validateAmount(ctx context.Context, req *models.ChargeRequest) error {
+	currency := strings.ToUpper(req.Currency)
+	provider := s.limitProvider(ctx, req)
+	limit, ok := s.amountLimits[provider][currency]
+	if !ok {
+		return nil
+	}
+	if req.Amount < limit.Min {
+		return fmt.Errorf("%w: %s %s is below the %s minimum of %s %s", ErrAmountOutOfRange,
+			s.formatMinorUnits(req.Amount, currency), currency, provider, s.formatMinorUnits(limit.Min, currency), currency)
+	}
+	if limit.Max > 0 && req.Amount > limit.Max {
+		return fmt.Errorf("%w: %s %s exceeds the %s maximum of %s %s", ErrAmountOutOfRange,
+			s.formatMinorUnits(req.Amount, currency), currency, provider, s.formatMinorUnits(limit.Max, currency), currency)
+	}
+	return nil
+}
+
+func (s *PaymentService) currencyExponent(currency string) int {
+	if exponent, ok := s.currencyExponents[currency]; ok {
+		return exponent
+	}
+	return models.CurrencyExponent(currency)
+}
+
+func (s *PaymentService) formatMinorUnits(amount int64, currency string) string {
+	exponent := s.currencyExponent(currency)
+	if exponent <= 0 {
+		return fmt.Sprintf("%d", amount)
+	}
+	scale := int64(1)
+	for i := 0; i < exponent; i++ {
+		scale *= 10
+	}
+	return fmt.Sprintf("%d.%0*d", amount/scale, exponent, amount%scale)
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+func chargeWithAmount(svc *PaymentService, amount int64, currency string) error {
+	_, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID:    "cus_1",
+		Amount:        amount,
+		Currency:      currency,
+		PaymentMethod: "pm_card_visa",
+	})
+	return err
+}
+
+func TestChargeRejectsAmountsOutsideCurrencyLimits(t *testing.T) {
+	svc := CreatePaymentService(&fakePaymentStore{}, &fakeCaptureProvider{name: "stripe"})
+
+	cases := []struct {
+		name     string
+		amount   int64
+		currency string
+		message  string
+	}{
+		{"below USD minimum", 49, "usd", "0.49 USD is below the stripe minimum of 0.50 USD"},
+		{"below GBP minimum", 29, "GBP", "0.29 GBP is below the stripe minimum of 0.30 GBP"},
+		{"below JPY minimum", 10, "JPY", "10 JPY is below the stripe minimum of 50 JPY"},
+		{"above USD maximum", 100000000, "USD", "1000000.00 USD exceeds the stripe maximum of 999999.99 USD"},
+		{"above JPY maximum", 100000000, "JPY", "100000000 JPY exceeds the stripe maximum of 99999999 JPY"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := chargeWithAmount(svc, tc.amount, tc.currency)
+			if !errors.Is(err, ErrAmountOutOfRange) {
+				t.Fatalf("expected ErrAmountOutOfRange, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.message) {
+				t.Fatalf("expected error to mention %q, got %q", tc.message, err.Error())
+			}
+		})
+	}
+}
+
+func TestSetAmountLimitsOverridesDefaults(t *testing.T) {
+	svc := CreatePaymentService(&fakePaymentStore{}, &fakeCaptureProvider{name: "stripe"})
+	svc.SetAmountLimits(map[string]map[string]models.AmountLimit{"Stripe": {"usd": {Min: 100, Max: 10000}, "IDR": {Min: 10000}}})
+
+	if err := chargeWithAmount(svc, 75, "USD"); !errors.Is(err, ErrAmountOutOfRange) {
+		t.Fatalf("expected overridden USD minimum to apply, got %v", err)
+	}
+	if err := chargeWithAmount(svc, 20000, "USD"); !errors.Is(err, ErrAmountOutOfRange) {
+		t.Fatalf("expected overridden USD maximum to apply, got %v", err)
+	}
+	if err := chargeWithAmount(svc, 5000, "IDR"); !errors.Is(err, ErrAmountOutOfRange) {
+		t.Fatalf("expected configured IDR minimum to apply, got %v", err)
+	}
+	if err := chargeWithAmount(svc, 40, "EUR"); !errors.Is(err, ErrAmountOutOfRange) {
+		t.Fatalf("expected EUR default to be kept, got %v", err)
+	}
+}
+
+func TestAmountLimitsAreKeyedByProvider(t *testing.T) {
+	stripe := CreatePaymentService(&fakePaymentStore{}, &fakeCaptureProvider{name: "stripe"})
+	if err := stripe.validateAmount(context.Background(), &models.ChargeRequest{Amount: 75, Currency: "INR"}); err != nil {
+		t.Fatalf("expected 0.75 INR to pass the stripe minimum, got %v", err)
+	}
+
+	razorpay := CreatePaymentService(&fakePaymentStore{}, &fakeCaptureProvider{name: "razorpay"})
+	err := chargeWithAmount(razorpay, 75, "INR")
+	if !errors.Is(err, ErrAmountOutOfRange) || !strings.Contains(err.Error(), "below the razorpay minimum of 1.00 INR") {
+		t.Fatalf("expected the razorpay INR minimum to apply, got %v", err)
+	}
+
+	if err := razorpay.validateAmount(context.Background(), &models.ChargeRequest{Amount: 10, Currency: "USD"}); err != nil {
+		t.Fatalf("expected stripe USD limits not to apply to razorpay, got %v", err)
+	}
+}
+
+func TestAmountLimitErrorsUseCurrencyExponent(t *testing.T) {
+	svc := CreatePaymentService(&fakePaymentStore{}, &fakeCaptureProvider{name: "stripe"})
+	svc.SetAmountLimits(map[string]map[string]models.AmountLimit{"stripe": {"KWD": {Min: 1000}, "XYZ": {Min: 1000}}})
+
+	err := chargeWithAmount(svc, 500, "KWD")
+	if err == nil || !strings.Contains(err.Error(), "0.500 KWD is below the stripe minimum of 1.000 KWD") {
+		t.Fatalf("expected three decimals for KWD, got %v", err)
+	}
+
+	svc.SetCurrencyExponents(map[string]int{"xyz": 0})
+	err = chargeWithAmount(svc, 500, "XYZ")
+	if err == nil || !strings.Contains(err.Error(), "500 XYZ is below the stripe minimum of 1000 XYZ") {
+		t.Fatalf("expected the exponent override to apply, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+)
+
+var (
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrAPIKeyRevoked      = errors.New("api key has been revoked")
+	ErrAPIKeyExpired      = errors.New("api key has expired")
+	ErrAPIKeyScopesNeeded = errors.New("at least one scope is required")
+	ErrAPIKeyScopeDenied  = errors.New("requested scope exceeds the caller's scopes")
+)
+
+// apiKeyTouchInterval bounds how often last_used_at is written for a key, so
+// a busy key does not turn every request into an UPDATE.
+const apiKeyTouchInterval = time.Minute
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	GetBySecretHash(ctx context.Context, secretHash string) (*models.APIKey, error)
+	ListByTenant(ctx context.Context, tenantID string) ([]*models.APIKey, error)
+	Revoke(ctx context.Context, tenantID, id string, revokedAt time.Time) error
+	TouchLastUsed(ctx context.Context, id string, usedAt time.Time) error
+}
+
+type APIKeyService struct {
+	store APIKeyRepository
+}
+
+func CreateAPIKeyService(store APIKeyRepository) *APIKeyService {
+	return &APIKeyService{store: store}
+}
+
+// Issue creates a scoped key for the tenant. The plaintext secret is only
+// returned here; the store keeps a SHA-256 hash of it. When the caller is
+// itself an API key, every requested scope must already be granted to it.
+func (s *APIKeyService) Issue(ctx context.Context, tenantID string, req *models.CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	if len(req.Scopes) == 0 {
+		return nil, "", ErrAPIKeyScopesNeeded
+	}
+	if caller, ok := ctx.Value(ctxkeys.ScopedAPIKey).(*models.APIKey); ok {
+		for _, scope := range req.Scopes {
+			if !caller.HasScope(scope) {
+				return nil, "", ErrAPIKeyScopeDenied
+			}
+		}
+	}
+
+	secret, err := generateAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &models.APIKey{
+		TenantID:   tenantID,
+		Name:       req.Name,
+		Prefix:     secret[:12],
+		SecretHash: hashAPIKeySecret(secret),
+		Scopes:     req.Scopes,
+		ExpiresAt:  req.ExpiresAt,
+	}
+	if err := s.store.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (s *APIKeyService) Authenticate(ctx context.Context, secret string) (*models.APIKey, error) {
+	if !IsAPIKeySecret(secret) {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	key, err := s.store.GetBySecretHash(ctx, hashAPIKeySecret(secret))
+	if err != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	now := time.Now()
+	if key.IsRevoked() {
+		return nil, ErrAPIKeyRevoked
+	}
+	if key.IsExpired(now) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.store.TouchLastUsed(ctx, key.ID, now); err == nil {
+			key.LastUsedAt = &now
+		}
+	}
+
+	return key, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context, tenantID string) ([]*models.APIKey, error) {
+	return s.store.ListByTenant(ctx, tenantID)
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, tenantID, id string) error {
+	if err := s.store.Revoke(ctx, tenantID, id, time.Now()); err != nil {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func IsAPIKeySecret(token string) bool {
+	return strings.HasPrefix(token, models.APIKeySecretPrefix)
+}
+
+func generateAPIKeySecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return models.APIKeySecretPrefix + hex.EncodeToString(b), nil
+}
+
+func hashAPIKeySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
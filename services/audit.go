@@ -3,17 +3,27 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
+	"github.com/malwarebo/conductor/internal/pagination"
 	"github.com/malwarebo/conductor/models"
-	"github.com/malwarebo/conductor/stores"
 )
 
+var ErrAuditTenantRequired = errors.New("tenant is required to read audit logs")
+
+type AuditRepository interface {
+	Create(ctx context.Context, log *models.AuditLog) error
+	List(ctx context.Context, filter models.AuditLogFilter) (pagination.Page[*models.AuditLog], error)
+	ListByResource(ctx context.Context, tenantID, resourceType, resourceID string, limit int) ([]*models.AuditLog, error)
+	CleanupOld(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
 type AuditService struct {
-	store *stores.AuditStore
+	store AuditRepository
 }
 
-func CreateAuditService(store *stores.AuditStore) *AuditService {
+func CreateAuditService(store AuditRepository) *AuditService {
 	return &AuditService{store: store}
 }
 
@@ -37,17 +47,7 @@ func (s *AuditService) LogPaymentAction(ctx context.Context, tenantID, userID, a
 	return s.store.Create(ctx, log)
 }
 
-func (s *AuditService) LogAPIRequest(ctx context.Context, tenantID, userID, method, path, ip, userAgent string, requestBody interface{}, responseCode int, success bool, errMsg string) error {
-	var reqBodyJSON models.JSON
-	if requestBody != nil {
-		if m, ok := requestBody.(map[string]interface{}); ok {
-			reqBodyJSON = models.JSON(m)
-		} else {
-			bytes, _ := json.Marshal(requestBody)
-			_ = json.Unmarshal(bytes, &reqBodyJSON)
-		}
-	}
-
+func (s *AuditService) LogAPIRequest(ctx context.Context, tenantID, userID, method, path, ip, userAgent string, requestBody, responseBody interface{}, responseCode int, success bool, errMsg string) error {
 	log := &models.AuditLog{
 		TenantID:      stringPtr(tenantID),
 		UserID:        userID,
@@ -55,7 +55,8 @@ func (s *AuditService) LogAPIRequest(ctx context.Context, tenantID, userID, meth
 		ResourceType:  "api",
 		RequestMethod: method,
 		RequestPath:   path,
-		RequestBody:   reqBodyJSON,
+		RequestBody:   toAuditJSON(requestBody),
+		ResponseBody:  toAuditJSON(responseBody),
 		ResponseCode:  responseCode,
 		IPAddress:     ip,
 		UserAgent:     userAgent,
@@ -65,6 +66,22 @@ func (s *AuditService) LogAPIRequest(ctx context.Context, tenantID, userID, meth
 	return s.store.Create(ctx, log)
 }
 
+func toAuditJSON(body interface{}) models.JSON {
+	switch b := body.(type) {
+	case nil:
+		return nil
+	case models.JSON:
+		return b
+	case map[string]interface{}:
+		return models.JSON(b)
+	}
+
+	var out models.JSON
+	bytes, _ := json.Marshal(body)
+	_ = json.Unmarshal(bytes, &out)
+	return out
+}
+
 func (s *AuditService) LogWebhookEvent(ctx context.Context, tenantID, provider, eventType, eventID string, success bool, errMsg string) error {
 	log := &models.AuditLog{
 		TenantID:     stringPtr(tenantID),
@@ -81,12 +98,32 @@ func (s *AuditService) LogWebhookEvent(ctx context.Context, tenantID, provider,
 	return s.store.Create(ctx, log)
 }
 
-func (s *AuditService) GetAuditLogs(ctx context.Context, filter models.AuditLogFilter) ([]*models.AuditLog, int64, error) {
-	return s.store.List(ctx, filter)
+func (s *AuditService) GetAuditLogs(ctx context.Context, filter models.AuditLogFilter) (*pagination.Page[*models.AuditLog], error) {
+	if filter.TenantID == "" {
+		return nil, ErrAuditTenantRequired
+	}
+	return s.listAuditLogs(ctx, filter)
+}
+
+// GetAuditLogsAcrossTenants lists logs without requiring a tenant. It backs
+// operator access and must not be reachable with tenant credentials.
+func (s *AuditService) GetAuditLogsAcrossTenants(ctx context.Context, filter models.AuditLogFilter) (*pagination.Page[*models.AuditLog], error) {
+	return s.listAuditLogs(ctx, filter)
 }
 
-func (s *AuditService) GetResourceHistory(ctx context.Context, resourceType, resourceID string, limit int) ([]*models.AuditLog, error) {
-	return s.store.ListByResource(ctx, resourceType, resourceID, limit)
+func (s *AuditService) listAuditLogs(ctx context.Context, filter models.AuditLogFilter) (*pagination.Page[*models.AuditLog], error) {
+	page, err := s.store.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (s *AuditService) GetResourceHistory(ctx context.Context, tenantID, resourceType, resourceID string, limit int) ([]*models.AuditLog, error) {
+	if tenantID == "" {
+		return nil, ErrAuditTenantRequired
+	}
+	return s.store.ListByResource(ctx, tenantID, resourceType, resourceID, limit)
 }
 
 func (s *AuditService) CleanupOldLogs(ctx context.Context, retentionDays int) (int64, error) {
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+const PaymentEventCanceled = "payment.canceled"
+
+const authorizationExpiredReason = "authorization_expired"
+
+// AuthorizationExpiryConfig sets how long an uncaptured authorization is kept
+// before it is voided. Providers hold authorizations for different periods,
+// so Windows overrides Default by provider name.
+type AuthorizationExpiryConfig struct {
+	Default time.Duration
+	Windows map[string]time.Duration
+}
+
+func DefaultAuthorizationExpiryConfig() AuthorizationExpiryConfig {
+	return AuthorizationExpiryConfig{Default: 7 * 24 * time.Hour}
+}
+
+func (s *ReconciliationService) SetAuthorizationExpiry(cfg AuthorizationExpiryConfig, notifier OutboundNotifier) {
+	if cfg.Default <= 0 {
+		cfg.Default = DefaultAuthorizationExpiryConfig().Default
+	}
+	s.authExpiry = cfg
+	s.notifier = notifier
+}
+
+// ExpireStaleAuthorizations cancels payments left in requires_capture past
+// their provider's window. Authorizations the provider still holds are voided
+// first; ones it already released are only reconciled locally.
+func (s *ReconciliationService) ExpireStaleAuthorizations(ctx context.Context, batchSize int) (*models.ReconciliationResult, error) {
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+
+	now := time.Now()
+	cutoffs := make(map[string]time.Time, len(s.authExpiry.Windows))
+	for name, window := range s.authExpiry.Windows {
+		if window > 0 {
+			cutoffs[name] = now.Add(-window)
+		}
+	}
+
+	payments, err := s.store.ClaimExpiredAuthorizations(ctx, cutoffs, now.Add(-s.authExpiry.Default), batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ReconciliationResult{}
+	var errs []error
+	for _, payment := range payments {
+		result.Checked++
+
+		updated, err := s.expireAuthorization(ctx, payment)
+		if err != nil {
+			result.Failed++
+			errs = append(errs, fmt.Errorf("expire authorization %s: %w", payment.ID, err))
+			continue
+		}
+		if updated {
+			result.Updated++
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
+
+func (s *ReconciliationService) expireAuthorization(ctx context.Context, payment *models.Payment) (bool, error) {
+	charge, err := callProvider(ctx, "get_charge", func(ctx context.Context) (*models.ChargeResponse, error) {
+		return s.provider.GetCharge(ctx, payment.ProviderChargeID)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	switch charge.Status {
+	case models.PaymentStatusCanceled:
+	case models.PaymentStatusRequiresCapture, "":
+		voider, ok := s.provider.(providers.VoidProvider)
+		if !ok {
+			return false, errors.New("provider does not support void")
+		}
+		if err := callProviderErr(ctx, "void_payment", func(ctx context.Context) error {
+			return voider.VoidPayment(ctx, payment.ProviderChargeID)
+		}); err != nil {
+			return false, err
+		}
+	default:
+		// Captured or failed outside this service; follow the provider.
+		return s.applyStatus(ctx, payment, charge.Status)
+	}
+
+	updated, err := s.applyStatus(ctx, payment, models.PaymentStatusCanceled)
+	if err != nil || !updated {
+		return updated, err
+	}
+	s.notifyAuthorizationExpired(ctx, payment)
+	return true, nil
+}
+
+func (s *ReconciliationService) notifyAuthorizationExpired(ctx context.Context, payment *models.Payment) {
+	if s.notifier == nil || payment.TenantID == nil {
+		return
+	}
+
+	_ = s.notifier.SendOutboundWebhook(ctx, *payment.TenantID, PaymentEventCanceled, payment, map[string]interface{}{
+		"payment_id":         payment.ID,
+		"customer_id":        payment.CustomerID,
+		"amount":             payment.Amount,
+		"currency":           payment.Currency,
+		"provider":           payment.ProviderName,
+		"provider_charge_id": payment.ProviderChargeID,
+		"status":             models.PaymentStatusCanceled,
+		"reason":             authorizationExpiredReason,
+		"authorized_at":      payment.CreatedAt,
+	})
+}
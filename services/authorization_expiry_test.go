@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+type fakeVoidProvider struct {
+	fakeChargeStatusProvider
+	voided []string
+}
+
+func (f *fakeVoidProvider) VoidPayment(_ context.Context, providerChargeID string) error {
+	f.voided = append(f.voided, providerChargeID)
+	return nil
+}
+
+func TestExpireStaleAuthorizationsVoidsAndCancels(t *testing.T) {
+	tenantID := "tenant_1"
+	store := &fakeReconciliationStore{
+		payments: []*models.Payment{
+			{ID: "pay_stale", TenantID: &tenantID, ProviderName: "stripe", ProviderChargeID: "pi_stale", Status: models.PaymentStatusRequiresCapture, CreatedAt: time.Now().Add(-8 * 24 * time.Hour)},
+			{ID: "pay_fresh", TenantID: &tenantID, ProviderName: "stripe", ProviderChargeID: "pi_fresh", Status: models.PaymentStatusRequiresCapture, CreatedAt: time.Now().Add(-time.Hour)},
+			{ID: "pay_short", TenantID: &tenantID, ProviderName: "razorpay", ProviderChargeID: "pay_rzp", Status: models.PaymentStatusRequiresCapture, CreatedAt: time.Now().Add(-4 * 24 * time.Hour)},
+		},
+		updated: make(map[string]models.PaymentStatus),
+	}
+	provider := &fakeVoidProvider{fakeChargeStatusProvider: fakeChargeStatusProvider{charges: map[string]*models.ChargeResponse{
+		"pi_stale": {ID: "pi_stale", Status: models.PaymentStatusRequiresCapture},
+		"pi_fresh": {ID: "pi_fresh", Status: models.PaymentStatusRequiresCapture},
+		"pay_rzp":  {ID: "pay_rzp", Status: models.PaymentStatusCanceled},
+	}}}
+	notifier := &fakeNotifier{}
+
+	svc := CreateReconciliationService(store, &fakeAuditWriter{}, provider)
+	svc.SetAuthorizationExpiry(AuthorizationExpiryConfig{
+		Default: 7 * 24 * time.Hour,
+		Windows: map[string]time.Duration{"razorpay": 3 * 24 * time.Hour},
+	}, notifier)
+
+	result, err := svc.ExpireStaleAuthorizations(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Checked != 2 || result.Updated != 2 || result.Failed != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if store.updated["pay_stale"] != models.PaymentStatusCanceled || store.updated["pay_short"] != models.PaymentStatusCanceled {
+		t.Fatalf("expected stale authorizations to be canceled, got %v", store.updated)
+	}
+	if _, ok := store.updated["pay_fresh"]; ok {
+		t.Fatal("expected the fresh authorization to be left alone")
+	}
+	if len(provider.voided) != 1 || provider.voided[0] != "pi_stale" {
+		t.Fatalf("expected only the authorization still held by the provider to be voided, got %v", provider.voided)
+	}
+	if len(notifier.events) != 2 || notifier.events[0] != PaymentEventCanceled {
+		t.Fatalf("expected two %s webhooks, got %v", PaymentEventCanceled, notifier.events)
+	}
+}
+
+func TestExpireStaleAuthorizationsFollowsCapturedStatus(t *testing.T) {
+	store := &fakeReconciliationStore{
+		payments: []*models.Payment{
+			{ID: "pay_1", ProviderName: "stripe", ProviderChargeID: "pi_1", Status: models.PaymentStatusRequiresCapture, CreatedAt: time.Now().Add(-30 * 24 * time.Hour)},
+		},
+		updated: make(map[string]models.PaymentStatus),
+	}
+	provider := &fakeVoidProvider{fakeChargeStatusProvider: fakeChargeStatusProvider{charges: map[string]*models.ChargeResponse{
+		"pi_1": {ID: "pi_1", Status: models.PaymentStatusSuccess},
+	}}}
+
+	svc := CreateReconciliationService(store, nil, provider)
+	if _, err := svc.ExpireStaleAuthorizations(context.Background(), 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if store.updated["pay_1"] != models.PaymentStatusSuccess {
+		t.Fatalf("expected captured payment to follow the provider, got %q", store.updated["pay_1"])
+	}
+	if len(provider.voided) != 0 {
+		t.Fatalf("expected no void for a captured payment, got %v", provider.voided)
+	}
+}
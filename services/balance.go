@@ -18,19 +18,31 @@ func CreateBalanceService(provider providers.PaymentProvider) *BalanceService {
 }
 
 func (s *BalanceService) GetBalance(ctx context.Context, currency string) (*models.Balance, error) {
+	if err := requireCapabilityForCurrency(ctx, s.provider, currency, providers.CapabilityBalance); err != nil {
+		return nil, err
+	}
+
 	if balanceProvider, ok := s.provider.(providers.BalanceProvider); ok {
-		return balanceProvider.GetBalance(ctx, currency)
+		return callProvider(ctx, "get_balance", func(ctx context.Context) (*models.Balance, error) {
+			return balanceProvider.GetBalance(ctx, currency)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
 
 func (s *BalanceService) GetAllBalances(ctx context.Context) ([]*models.Balance, error) {
+	if err := requireCapability(s.provider, providers.CapabilityBalance); err != nil {
+		return nil, err
+	}
+
 	currencies := []string{"USD", "EUR", "GBP", "IDR", "SGD", "PHP"}
 	var balances []*models.Balance
 
 	if balanceProvider, ok := s.provider.(providers.BalanceProvider); ok {
 		for _, currency := range currencies {
-			balance, err := balanceProvider.GetBalance(ctx, currency)
+			balance, err := callProvider(ctx, "get_balance", func(ctx context.Context) (*models.Balance, error) {
+				return balanceProvider.GetBalance(ctx, currency)
+			})
 			if err == nil && balance != nil {
 				balances = append(balances, balance)
 			}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/malwarebo/conductor/providers"
+)
+
+var ErrCapabilityUnsupported = errors.New("capability not supported by provider")
+
+// requireCapability fails fast when the selected provider does not advertise
+// the capability, instead of surfacing ErrNotSupported from deep in the call.
+// Only use it for calls that fan out across every provider; calls handled by a
+// single provider behind a selector should go through the resolving variants.
+func requireCapability(provider providers.PaymentProvider, capability providers.Capability) error {
+	if provider.Capabilities().Supports(capability) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s does not support %s", ErrCapabilityUnsupported, provider.Name(), capability)
+}
+
+func requireCapabilityForCurrency(ctx context.Context, provider providers.PaymentProvider, currency string, capability providers.Capability) error {
+	return requireResolvedCapability(provider, capability, func(resolver providers.ProviderResolver) (providers.PaymentProvider, error) {
+		return resolver.ProviderForCurrency(ctx, currency)
+	})
+}
+
+func requireCapabilityForEntity(ctx context.Context, provider providers.PaymentProvider, entityID, entityType string, capability providers.Capability) error {
+	return requireResolvedCapability(provider, capability, func(resolver providers.ProviderResolver) (providers.PaymentProvider, error) {
+		return resolver.ProviderForEntity(ctx, entityID, entityType)
+	})
+}
+
+func requireCapabilityForSubscriptions(ctx context.Context, provider providers.PaymentProvider) error {
+	return requireResolvedCapability(provider, providers.CapabilitySubscriptions, func(resolver providers.ProviderResolver) (providers.PaymentProvider, error) {
+		return resolver.ProviderForSubscriptions(ctx)
+	})
+}
+
+// requireResolvedCapability checks the provider a selector would delegate to.
+// When the selector cannot resolve one the call itself reports that, so the
+// check falls back to the selector's merged capabilities.
+func requireResolvedCapability(provider providers.PaymentProvider, capability providers.Capability, resolve func(providers.ProviderResolver) (providers.PaymentProvider, error)) error {
+	if resolver, ok := provider.(providers.ProviderResolver); ok {
+		if resolved, err := resolve(resolver); err == nil && resolved != nil {
+			provider = resolved
+		}
+	}
+	return requireCapability(provider, capability)
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type fakeXenditProvider struct {
+	providers.PaymentProvider
+	subscriptionCalls int
+}
+
+func (f *fakeXenditProvider) Name() string                     { return "xendit" }
+func (f *fakeXenditProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeXenditProvider) Capabilities() providers.ProviderCapabilities {
+	return (&providers.XenditProvider{}).Capabilities()
+}
+
+func (f *fakeXenditProvider) CreateSubscription(context.Context, *models.CreateSubscriptionRequest) (*models.Subscription, error) {
+	f.subscriptionCalls++
+	return nil, providers.ErrNotSupported
+}
+
+func TestSubscriptionOnXenditFailsCapabilityCheck(t *testing.T) {
+	provider := &fakeXenditProvider{}
+	svc := CreateSubscriptionService(nil, &fakeSubscriptionStore{}, provider)
+
+	_, err := svc.CreateSubscription(context.Background(), &models.CreateSubscriptionRequest{CustomerID: "cus_1", PlanID: "plan_1"})
+	if !errors.Is(err, ErrCapabilityUnsupported) {
+		t.Fatalf("expected ErrCapabilityUnsupported, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "xendit") || !strings.Contains(err.Error(), string(providers.CapabilitySubscriptions)) {
+		t.Fatalf("expected provider and capability in error, got %q", err)
+	}
+	if provider.subscriptionCalls != 0 {
+		t.Fatalf("provider must not be called, got %d calls", provider.subscriptionCalls)
+	}
+}
+
+func TestPayoutAllowedWhenCapabilityAdvertised(t *testing.T) {
+	svc := CreatePayoutService(&fakeXenditProvider{})
+
+	_, err := svc.CreatePayout(context.Background(), &models.CreatePayoutRequest{})
+	if errors.Is(err, ErrCapabilityUnsupported) {
+		t.Fatalf("xendit advertises payouts, got %v", err)
+	}
+}
+
+type fakeResolvingSelector struct {
+	providers.PaymentProvider
+	merged   providers.ProviderCapabilities
+	resolved providers.PaymentProvider
+}
+
+func (f *fakeResolvingSelector) Name() string { return "multi_provider" }
+
+func (f *fakeResolvingSelector) Capabilities() providers.ProviderCapabilities { return f.merged }
+
+func (f *fakeResolvingSelector) ProviderForCurrency(context.Context, string) (providers.PaymentProvider, error) {
+	return f.resolved, nil
+}
+
+func (f *fakeResolvingSelector) ProviderForEntity(context.Context, string, string) (providers.PaymentProvider, error) {
+	return f.resolved, nil
+}
+
+func (f *fakeResolvingSelector) ProviderForSubscriptions(context.Context) (providers.PaymentProvider, error) {
+	return f.resolved, nil
+}
+
+func (f *fakeResolvingSelector) IsAvailable(context.Context) bool { return true }
+
+func TestCapabilityCheckUsesResolvedProvider(t *testing.T) {
+	selector := &fakeResolvingSelector{
+		merged:   providers.MergeCapabilities((&providers.StripeProvider{}).Capabilities(), (&providers.XenditProvider{}).Capabilities()),
+		resolved: &fakeXenditProvider{},
+	}
+
+	svc := CreateSubscriptionService(nil, &fakeSubscriptionStore{}, selector)
+	_, err := svc.CancelSubscription(context.Background(), "sub_1", &models.CancelSubscriptionRequest{})
+	if !errors.Is(err, ErrCapabilityUnsupported) {
+		t.Fatalf("expected ErrCapabilityUnsupported from the resolved provider, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "xendit") {
+		t.Fatalf("expected the resolved provider in the error, got %q", err)
+	}
+}
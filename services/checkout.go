@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+var (
+	ErrCheckoutSessionNotFound = errors.New("checkout session not found")
+	ErrInvalidCheckoutSession  = errors.New("invalid checkout session request")
+)
+
+type CheckoutSessionStore interface {
+	Create(ctx context.Context, session *models.CheckoutSession) error
+	GetByID(ctx context.Context, id string) (*models.CheckoutSession, error)
+	GetByProviderSessionID(ctx context.Context, providerName, providerSessionID string) (*models.CheckoutSession, error)
+	Update(ctx context.Context, session *models.CheckoutSession) error
+}
+
+type CheckoutService struct {
+	store    CheckoutSessionStore
+	provider providers.PaymentProvider
+	now      func() time.Time
+}
+
+func CreateCheckoutService(store CheckoutSessionStore, provider providers.PaymentProvider) *CheckoutService {
+	return &CheckoutService{
+		store:    store,
+		provider: provider,
+		now:      time.Now,
+	}
+}
+
+// CreateCheckoutSession creates a hosted payment page with the provider and
+// records it so the provider's completion webhook can be matched back.
+func (s *CheckoutService) CreateCheckoutSession(ctx context.Context, req *models.CreateCheckoutSessionRequest) (*models.CheckoutSession, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidCheckoutSession)
+	}
+	if req.Currency == "" {
+		return nil, fmt.Errorf("%w: currency is required", ErrInvalidCheckoutSession)
+	}
+	if req.SuccessURL == "" {
+		return nil, fmt.Errorf("%w: success_url is required", ErrInvalidCheckoutSession)
+	}
+
+	checkoutProvider, ok := s.provider.(providers.HostedCheckoutProvider)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s does not support hosted checkout", ErrCapabilityUnsupported, s.provider.Name())
+	}
+	session, err := callProvider(ctx, "create_checkout_session", func(ctx context.Context) (*models.CheckoutSession, error) {
+		return checkoutProvider.CreateCheckoutSession(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if tid, ok := ctx.Value(ctxkeys.TenantID).(string); ok && tid != "" {
+		session.TenantID = &tid
+	}
+	if err := s.store.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *CheckoutService) GetCheckoutSession(ctx context.Context, id string) (*models.CheckoutSession, error) {
+	session, err := s.store.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrCheckoutSessionNotFound
+	}
+	return session, nil
+}
+
+// HandleCheckoutCompleted marks the provider's session paid. Repeated
+// deliveries of the same webhook leave a completed session unchanged.
+func (s *CheckoutService) HandleCheckoutCompleted(ctx context.Context, providerName, providerSessionID, providerPaymentID string) error {
+	session, err := s.store.GetByProviderSessionID(ctx, providerName, providerSessionID)
+	if err != nil {
+		return ErrCheckoutSessionNotFound
+	}
+	if session.Status == models.CheckoutSessionStatusComplete {
+		return nil
+	}
+
+	now := s.now()
+	session.Status = models.CheckoutSessionStatusComplete
+	session.ProviderPaymentID = providerPaymentID
+	session.CompletedAt = &now
+	return s.store.Update(ctx, session)
+}
+
+// HandleCheckoutExpired closes an open session the customer never paid.
+func (s *CheckoutService) HandleCheckoutExpired(ctx context.Context, providerName, providerSessionID string) error {
+	session, err := s.store.GetByProviderSessionID(ctx, providerName, providerSessionID)
+	if err != nil {
+		return ErrCheckoutSessionNotFound
+	}
+	if session.Status != models.CheckoutSessionStatusOpen {
+		return nil
+	}
+
+	session.Status = models.CheckoutSessionStatusExpired
+	return s.store.Update(ctx, session)
+}
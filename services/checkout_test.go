@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type fakeCheckoutStore struct {
+	sessions map[string]*models.CheckoutSession
+}
+
+func (f *fakeCheckoutStore) Create(_ context.Context, session *models.CheckoutSession) error {
+	session.ID = "chk_1"
+	f.sessions[session.ID] = session
+	return nil
+}
+
+func (f *fakeCheckoutStore) GetByID(_ context.Context, id string) (*models.CheckoutSession, error) {
+	session, ok := f.sessions[id]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return session, nil
+}
+
+func (f *fakeCheckoutStore) GetByProviderSessionID(_ context.Context, providerName, providerSessionID string) (*models.CheckoutSession, error) {
+	for _, session := range f.sessions {
+		if session.ProviderName == providerName && session.ProviderSessionID == providerSessionID {
+			return session, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (f *fakeCheckoutStore) Update(_ context.Context, session *models.CheckoutSession) error {
+	f.sessions[session.ID] = session
+	return nil
+}
+
+type fakeHostedCheckoutProvider struct {
+	providers.PaymentProvider
+}
+
+func (f *fakeHostedCheckoutProvider) Name() string { return "stripe" }
+
+func (f *fakeHostedCheckoutProvider) CreateCheckoutSession(_ context.Context, req *models.CreateCheckoutSessionRequest) (*models.CheckoutSession, error) {
+	return &models.CheckoutSession{
+		ProviderName:      "stripe",
+		ProviderSessionID: "cs_test_1",
+		Amount:            req.Amount,
+		Currency:          req.Currency,
+		Status:            models.CheckoutSessionStatusOpen,
+		URL:               "https://checkout.stripe.com/c/pay/cs_test_1",
+		SuccessURL:        req.SuccessURL,
+	}, nil
+}
+
+func stripeCheckoutWebhook(eventType, paymentStatus string) *models.WebhookEvent {
+	return &models.WebhookEvent{
+		Provider:  "stripe",
+		EventType: eventType,
+		Payload: models.JSON{
+			"data": map[string]interface{}{
+				"object": map[string]interface{}{
+					"id":             "cs_test_1",
+					"payment_intent": "pi_123",
+					"payment_status": paymentStatus,
+				},
+			},
+		},
+	}
+}
+
+func TestCheckoutSessionCompletesFromStripeWebhook(t *testing.T) {
+	store := &fakeCheckoutStore{sessions: map[string]*models.CheckoutSession{}}
+	svc := CreateCheckoutService(store, &fakeHostedCheckoutProvider{})
+	webhooks := &WebhookService{checkoutEvents: svc}
+	ctx := context.Background()
+
+	session, err := svc.CreateCheckoutSession(ctx, &models.CreateCheckoutSessionRequest{
+		Amount:     2500,
+		Currency:   "USD",
+		SuccessURL: "https://shop.example/success",
+	})
+	if err != nil {
+		t.Fatalf("create checkout session: %v", err)
+	}
+	if session.URL == "" || store.sessions["chk_1"] == nil {
+		t.Fatalf("expected the session to be stored with its URL, got %+v", session)
+	}
+
+	if err := webhooks.dispatchEvent(ctx, stripeCheckoutWebhook("checkout.session.completed", "unpaid")); err != nil {
+		t.Fatalf("dispatch unpaid completion: %v", err)
+	}
+	if store.sessions["chk_1"].Status != models.CheckoutSessionStatusOpen {
+		t.Fatalf("expected an unpaid session to stay open, got %s", store.sessions["chk_1"].Status)
+	}
+
+	if err := webhooks.dispatchEvent(ctx, stripeCheckoutWebhook("checkout.session.async_payment_succeeded", "paid")); err != nil {
+		t.Fatalf("dispatch paid completion: %v", err)
+	}
+	completed := store.sessions["chk_1"]
+	if completed.Status != models.CheckoutSessionStatusComplete || completed.ProviderPaymentID != "pi_123" || completed.CompletedAt == nil {
+		t.Fatalf("expected the session to complete with its payment, got %+v", completed)
+	}
+
+	if err := webhooks.dispatchEvent(ctx, stripeCheckoutWebhook("checkout.session.expired", "unpaid")); err != nil {
+		t.Fatalf("dispatch expiry: %v", err)
+	}
+	if store.sessions["chk_1"].Status != models.CheckoutSessionStatusComplete {
+		t.Fatalf("expected a completed session to ignore expiry, got %s", store.sessions["chk_1"].Status)
+	}
+}
+
+func TestCreateCheckoutSessionRequiresHostedCheckout(t *testing.T) {
+	svc := CreateCheckoutService(&fakeCheckoutStore{sessions: map[string]*models.CheckoutSession{}}, &fakeChargeProvider{})
+
+	_, err := svc.CreateCheckoutSession(context.Background(), &models.CreateCheckoutSessionRequest{
+		Amount:     2500,
+		Currency:   "USD",
+		SuccessURL: "https://shop.example/success",
+	})
+	if !errors.Is(err, ErrCapabilityUnsupported) {
+		t.Fatalf("expected ErrCapabilityUnsupported, got %v", err)
+	}
+}
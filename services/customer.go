@@ -2,12 +2,19 @@ package services
 
 import (
 	"context"
+	"errors"
 
+	"github.com/malwarebo/conductor/internal/ctxkeys"
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/stores"
 )
 
+var (
+	ErrCustomerTenantRequired = errors.New("tenant is required to list customers")
+	ErrCustomerNotFound       = errors.New("customer not found")
+)
+
 type CustomerService struct {
 	customerStore *stores.CustomerStore
 	provider      providers.PaymentProvider
@@ -21,7 +28,9 @@ func CreateCustomerService(customerStore *stores.CustomerStore, provider provide
 }
 
 func (s *CustomerService) CreateCustomer(ctx context.Context, req *models.CreateCustomerRequest) (*models.Customer, error) {
-	providerID, err := s.provider.CreateCustomer(ctx, req)
+	providerID, err := callProvider(ctx, "create_customer", func(ctx context.Context) (string, error) {
+		return s.provider.CreateCustomer(ctx, req)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -33,6 +42,9 @@ func (s *CustomerService) CreateCustomer(ctx context.Context, req *models.Create
 		Phone:      req.Phone,
 		Metadata:   req.Metadata,
 	}
+	if tid, ok := ctx.Value(ctxkeys.TenantID).(string); ok && tid != "" {
+		customer.TenantID = &tid
+	}
 
 	if s.customerStore != nil {
 		if err := s.customerStore.Create(ctx, customer); err != nil {
@@ -43,14 +55,32 @@ func (s *CustomerService) CreateCustomer(ctx context.Context, req *models.Create
 	return customer, nil
 }
 
+// ListCustomers searches the local customer records, which index every
+// customer created through the API, rather than each provider's listing.
+func (s *CustomerService) ListCustomers(ctx context.Context, filter models.CustomerFilter) ([]*models.Customer, error) {
+	if filter.TenantID == "" {
+		return nil, ErrCustomerTenantRequired
+	}
+	if s.customerStore == nil {
+		return []*models.Customer{}, nil
+	}
+	return s.customerStore.List(ctx, filter)
+}
+
 func (s *CustomerService) GetCustomer(ctx context.Context, customerID string) (*models.Customer, error) {
-	return s.provider.GetCustomer(ctx, customerID)
+	return callProvider(ctx, "get_customer", func(ctx context.Context) (*models.Customer, error) {
+		return s.provider.GetCustomer(ctx, customerID)
+	})
 }
 
 func (s *CustomerService) UpdateCustomer(ctx context.Context, customerID string, req *models.UpdateCustomerRequest) error {
-	return s.provider.UpdateCustomer(ctx, customerID, req)
+	return callProviderErr(ctx, "update_customer", func(ctx context.Context) error {
+		return s.provider.UpdateCustomer(ctx, customerID, req)
+	})
 }
 
 func (s *CustomerService) DeleteCustomer(ctx context.Context, customerID string) error {
-	return s.provider.DeleteCustomer(ctx, customerID)
+	return callProviderErr(ctx, "delete_customer", func(ctx context.Context) error {
+		return s.provider.DeleteCustomer(ctx, customerID)
+	})
 }
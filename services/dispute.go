@@ -4,10 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/malwarebo/conductor/internal/ctxkeys"
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/providers"
-	"github.com/malwarebo/conductor/stores"
 )
 
 var (
@@ -15,29 +16,55 @@ var (
 	ErrInvalidStatus   = errors.New("invalid status")
 )
 
+type DisputeStore interface {
+	Create(ctx context.Context, dispute *models.Dispute) error
+	GetByID(ctx context.Context, id string) (*models.Dispute, error)
+	GetByProviderDisputeID(ctx context.Context, providerDisputeID string) (*models.Dispute, error)
+	Update(ctx context.Context, dispute *models.Dispute) error
+	ListByCustomer(ctx context.Context, customerID string) ([]models.Dispute, error)
+	ListDueForReminder(ctx context.Context, after, before time.Time, limit int) ([]models.Dispute, error)
+	GetStats(ctx context.Context) (*models.DisputeStats, error)
+}
+
+type DisputePaymentStore interface {
+	GetByProviderChargeID(ctx context.Context, providerChargeID string) (*models.Payment, error)
+	Update(ctx context.Context, payment *models.Payment) error
+}
+
 type DisputeService struct {
-	disputeRepo *stores.DisputeRepository
+	disputeRepo DisputeStore
+	paymentRepo DisputePaymentStore
 	provider    providers.PaymentProvider
+	notifier    OutboundNotifier
+	reminders   DisputeReminderConfig
+	now         func() time.Time
 }
 
-func CreateDisputeService(disputeRepo *stores.DisputeRepository, provider providers.PaymentProvider) *DisputeService {
+func CreateDisputeService(disputeRepo DisputeStore, paymentRepo DisputePaymentStore, provider providers.PaymentProvider) *DisputeService {
 	return &DisputeService{
 		disputeRepo: disputeRepo,
+		paymentRepo: paymentRepo,
 		provider:    provider,
+		reminders:   DefaultDisputeReminderConfig(),
+		now:         time.Now,
 	}
 }
 
 func (s *DisputeService) CreateDispute(ctx context.Context, req *models.CreateDisputeRequest) (*models.DisputeResponse, error) {
 	dispute := &models.Dispute{
-		CustomerID:    req.CustomerID,
-		TransactionID: req.TransactionID,
-		Amount:        req.Amount,
-		Currency:      req.Currency,
-		Reason:        req.Reason,
-		Status:        models.DisputeStatusOpen,
-		Evidence:      req.Evidence,
-		DueBy:         req.DueBy,
-		Metadata:      req.Metadata,
+		CustomerID:         req.CustomerID,
+		TransactionID:      req.TransactionID,
+		Amount:             req.Amount,
+		Currency:           req.Currency,
+		Reason:             req.Reason,
+		Status:             models.DisputeStatusOpen,
+		Evidence:           req.Evidence,
+		DueBy:              req.DueBy,
+		Metadata:           req.Metadata,
+		AutoSubmitEvidence: req.AutoSubmitEvidence,
+	}
+	if tenantID, ok := ctx.Value(ctxkeys.TenantID).(string); ok && tenantID != "" {
+		dispute.TenantID = &tenantID
 	}
 
 	if err := s.disputeRepo.Create(ctx, dispute); err != nil {
@@ -49,7 +76,9 @@ func (s *DisputeService) CreateDispute(ctx context.Context, req *models.CreateDi
 
 func (s *DisputeService) GetDispute(ctx context.Context, id string) (*models.DisputeResponse, error) {
 	if s.provider != nil {
-		providerDispute, err := s.provider.GetDispute(ctx, id)
+		providerDispute, err := callProvider(ctx, "get_dispute", func(ctx context.Context) (*models.Dispute, error) {
+			return s.provider.GetDispute(ctx, id)
+		})
 		if err == nil && providerDispute != nil {
 			return &models.DisputeResponse{Dispute: providerDispute}, nil
 		}
@@ -64,7 +93,9 @@ func (s *DisputeService) GetDispute(ctx context.Context, id string) (*models.Dis
 
 func (s *DisputeService) ListDisputes(ctx context.Context, customerID string) ([]models.Dispute, error) {
 	if s.provider != nil {
-		providerDisputes, err := s.provider.ListDisputes(ctx, customerID)
+		providerDisputes, err := callProvider(ctx, "list_disputes", func(ctx context.Context) ([]*models.Dispute, error) {
+			return s.provider.ListDisputes(ctx, customerID)
+		})
 		if err == nil && len(providerDisputes) > 0 {
 			result := make([]models.Dispute, len(providerDisputes))
 			for i, d := range providerDisputes {
@@ -83,7 +114,9 @@ func (s *DisputeService) ListDisputes(ctx context.Context, customerID string) ([
 
 func (s *DisputeService) UpdateDispute(ctx context.Context, id string, req *models.UpdateDisputeRequest) (*models.DisputeResponse, error) {
 	if s.provider != nil {
-		providerDispute, err := s.provider.UpdateDispute(ctx, id, req)
+		providerDispute, err := callProvider(ctx, "update_dispute", func(ctx context.Context) (*models.Dispute, error) {
+			return s.provider.UpdateDispute(ctx, id, req)
+		})
 		if err == nil && providerDispute != nil {
 			return &models.DisputeResponse{Dispute: providerDispute}, nil
 		}
@@ -119,7 +152,9 @@ func (s *DisputeService) AcceptDispute(ctx context.Context, id string) (*models.
 		return nil, fmt.Errorf("provider not configured")
 	}
 
-	dispute, err := s.provider.AcceptDispute(ctx, id)
+	dispute, err := callProvider(ctx, "accept_dispute", func(ctx context.Context) (*models.Dispute, error) {
+		return s.provider.AcceptDispute(ctx, id)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to accept dispute: %w", err)
 	}
@@ -132,7 +167,9 @@ func (s *DisputeService) ContestDispute(ctx context.Context, id string, evidence
 		return nil, fmt.Errorf("provider not configured")
 	}
 
-	dispute, err := s.provider.ContestDispute(ctx, id, evidence)
+	dispute, err := callProvider(ctx, "contest_dispute", func(ctx context.Context) (*models.Dispute, error) {
+		return s.provider.ContestDispute(ctx, id, evidence)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to contest dispute: %w", err)
 	}
@@ -145,7 +182,9 @@ func (s *DisputeService) SubmitEvidence(ctx context.Context, id string, req *mod
 		return nil, fmt.Errorf("provider not configured")
 	}
 
-	evidence, err := s.provider.SubmitDisputeEvidence(ctx, id, req)
+	evidence, err := callProvider(ctx, "submit_dispute_evidence", func(ctx context.Context) (*models.Evidence, error) {
+		return s.provider.SubmitDisputeEvidence(ctx, id, req)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to submit evidence: %w", err)
 	}
@@ -155,7 +194,9 @@ func (s *DisputeService) SubmitEvidence(ctx context.Context, id string, req *mod
 
 func (s *DisputeService) GetStats(ctx context.Context) (*models.DisputeStats, error) {
 	if s.provider != nil {
-		providerStats, err := s.provider.GetDisputeStats(ctx)
+		providerStats, err := callProvider(ctx, "get_dispute_stats", func(ctx context.Context) (*models.DisputeStats, error) {
+			return s.provider.GetDisputeStats(ctx)
+		})
 		if err == nil && providerStats != nil {
 			return providerStats, nil
 		}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+const (
+	DisputeEventEvidenceDueSoon   = "dispute.evidence_due_soon"
+	DisputeEventEvidenceSubmitted = "dispute.evidence_submitted"
+)
+
+// DisputeReminderConfig sets how long before DueBy merchants are reminded.
+// Disputes with AutoSubmitEvidence are contested with their staged evidence
+// at the same point.
+type DisputeReminderConfig struct {
+	LeadTime time.Duration
+}
+
+func DefaultDisputeReminderConfig() DisputeReminderConfig {
+	return DisputeReminderConfig{LeadTime: 72 * time.Hour}
+}
+
+func (s *DisputeService) SetReminders(cfg DisputeReminderConfig, notifier OutboundNotifier) {
+	if cfg.LeadTime > 0 {
+		s.reminders = cfg
+	}
+	s.notifier = notifier
+}
+
+// ProcessEvidenceReminders reminds merchants about open disputes whose
+// evidence deadline is within the lead time and returns how many were
+// processed. Each dispute is reminded once; disputes already past their
+// deadline are skipped.
+func (s *DisputeService) ProcessEvidenceReminders(ctx context.Context, batchSize int) (int, error) {
+	now := s.now()
+	due, err := s.disputeRepo.ListDueForReminder(ctx, now, now.Add(s.reminders.LeadTime), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for i := range due {
+		if err := s.remind(ctx, &due[i]); err != nil {
+			errs = append(errs, fmt.Errorf("dispute reminder %s: %w", due[i].ID, err))
+		}
+	}
+	return len(due), errors.Join(errs...)
+}
+
+// remind records the reminder only once any auto-submission has gone
+// through, so a failed submission is retried on the next run.
+func (s *DisputeService) remind(ctx context.Context, dispute *models.Dispute) error {
+	now := s.now()
+	if !dispute.DueBy.After(now) {
+		return nil
+	}
+
+	if s.shouldAutoSubmit(dispute) {
+		if _, err := callProvider(ctx, "contest_dispute", func(ctx context.Context) (*models.Dispute, error) {
+			return s.provider.ContestDispute(ctx, dispute.ProviderDisputeID, dispute.Evidence)
+		}); err != nil {
+			return err
+		}
+		dispute.EvidenceSubmittedAt = &now
+	}
+
+	dispute.ReminderSentAt = &now
+	if err := s.disputeRepo.Update(ctx, dispute); err != nil {
+		return err
+	}
+
+	s.notify(ctx, dispute, DisputeEventEvidenceDueSoon)
+	if dispute.EvidenceSubmittedAt != nil {
+		s.notify(ctx, dispute, DisputeEventEvidenceSubmitted)
+	}
+	return nil
+}
+
+func (s *DisputeService) shouldAutoSubmit(dispute *models.Dispute) bool {
+	return dispute.AutoSubmitEvidence &&
+		len(dispute.Evidence) > 0 &&
+		dispute.EvidenceSubmittedAt == nil &&
+		dispute.ProviderDisputeID != "" &&
+		s.provider != nil
+}
+
+func (s *DisputeService) notify(ctx context.Context, dispute *models.Dispute, eventType string) {
+	if s.notifier == nil || dispute.TenantID == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"dispute_id":     dispute.ID,
+		"customer_id":    dispute.CustomerID,
+		"transaction_id": dispute.TransactionID,
+		"amount":         dispute.Amount,
+		"currency":       dispute.Currency,
+		"status":         dispute.Status,
+		"due_by":         dispute.DueBy,
+	}
+	if dispute.EvidenceSubmittedAt != nil {
+		data["evidence_submitted_at"] = dispute.EvidenceSubmittedAt
+	}
+
+	_ = s.notifier.SendOutboundWebhook(ctx, *dispute.TenantID, eventType, dispute, data)
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"gorm.io/gorm"
+)
+
+type fakeDisputeStore struct {
+	disputes map[string]*models.Dispute
+	getErr   error
+}
+
+func (f *fakeDisputeStore) Create(_ context.Context, dispute *models.Dispute) error {
+	if dispute.ID == "" {
+		dispute.ID = fmt.Sprintf("dispute_%d", len(f.disputes)+1)
+	}
+	copied := *dispute
+	f.disputes[dispute.ID] = &copied
+	return nil
+}
+
+func (f *fakeDisputeStore) GetByID(_ context.Context, id string) (*models.Dispute, error) {
+	dispute, ok := f.disputes[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *dispute
+	return &copied, nil
+}
+
+func (f *fakeDisputeStore) GetByProviderDisputeID(_ context.Context, providerDisputeID string) (*models.Dispute, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	for _, dispute := range f.disputes {
+		if dispute.ProviderDisputeID == providerDisputeID {
+			copied := *dispute
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeDisputeStore) Update(_ context.Context, dispute *models.Dispute) error {
+	copied := *dispute
+	f.disputes[dispute.ID] = &copied
+	return nil
+}
+
+func (f *fakeDisputeStore) ListByCustomer(context.Context, string) ([]models.Dispute, error) {
+	return nil, nil
+}
+
+func (f *fakeDisputeStore) ListDueForReminder(_ context.Context, after, before time.Time, limit int) ([]models.Dispute, error) {
+	var due []models.Dispute
+	for _, dispute := range f.disputes {
+		if dispute.Status == models.DisputeStatusOpen && dispute.ReminderSentAt == nil && dispute.DueBy.After(after) && !dispute.DueBy.After(before) {
+			due = append(due, *dispute)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].DueBy.Before(due[j].DueBy) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (f *fakeDisputeStore) GetStats(context.Context) (*models.DisputeStats, error) {
+	return &models.DisputeStats{}, nil
+}
+
+type fakeDisputeProvider struct {
+	providers.PaymentProvider
+	contested  map[string]map[string]interface{}
+	contestErr error
+}
+
+func (f *fakeDisputeProvider) ContestDispute(_ context.Context, id string, evidence map[string]interface{}) (*models.Dispute, error) {
+	if f.contestErr != nil {
+		return nil, f.contestErr
+	}
+	f.contested[id] = evidence
+	return &models.Dispute{ID: id, Status: models.DisputeStatusOpen}, nil
+}
+
+func TestDisputeReminderFiresOnceWhenThresholdCrossed(t *testing.T) {
+	tenantID := "tenant-1"
+	store := &fakeDisputeStore{disputes: map[string]*models.Dispute{
+		"dp_1": {
+			ID:       "dp_1",
+			TenantID: &tenantID,
+			Status:   models.DisputeStatusOpen,
+			DueBy:    time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+	}}
+	provider := &fakeDisputeProvider{contested: map[string]map[string]interface{}{}}
+	notifier := &fakeNotifier{}
+	svc := CreateDisputeService(store, nil, provider)
+	svc.SetReminders(DisputeReminderConfig{LeadTime: 48 * time.Hour}, notifier)
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if n, err := svc.ProcessEvidenceReminders(ctx, 10); err != nil || n != 0 {
+		t.Fatalf("expected no reminders before the lead time, got n=%d err=%v", n, err)
+	}
+
+	now = now.Add(25 * time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, err := svc.ProcessEvidenceReminders(ctx, 10); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+	}
+
+	if len(notifier.events) != 1 || notifier.events[0] != DisputeEventEvidenceDueSoon {
+		t.Fatalf("expected exactly one reminder, got %v", notifier.events)
+	}
+	if sent := store.disputes["dp_1"].ReminderSentAt; sent == nil || !sent.Equal(now) {
+		t.Fatalf("expected reminder state to be recorded, got %v", sent)
+	}
+	if len(provider.contested) != 0 {
+		t.Fatalf("evidence must not be submitted without opt-in, got %v", provider.contested)
+	}
+}
+
+func TestDisputeReminderAutoSubmitsStagedEvidence(t *testing.T) {
+	tenantID := "tenant-1"
+	evidence := map[string]interface{}{"shipping_tracking_number": "1Z999"}
+	store := &fakeDisputeStore{disputes: map[string]*models.Dispute{
+		"dp_1": {
+			ID:                 "dp_1",
+			ProviderDisputeID:  "du_1",
+			TenantID:           &tenantID,
+			Status:             models.DisputeStatusOpen,
+			Evidence:           evidence,
+			AutoSubmitEvidence: true,
+			DueBy:              time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}}
+	provider := &fakeDisputeProvider{contested: map[string]map[string]interface{}{}}
+	notifier := &fakeNotifier{}
+	svc := CreateDisputeService(store, nil, provider)
+	svc.SetReminders(DisputeReminderConfig{LeadTime: 48 * time.Hour}, notifier)
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	if _, err := svc.ProcessEvidenceReminders(context.Background(), 10); err != nil {
+		t.Fatalf("process reminders: %v", err)
+	}
+
+	if provider.contested["du_1"]["shipping_tracking_number"] != "1Z999" {
+		t.Fatalf("expected staged evidence to be submitted under the provider dispute ID, got %v", provider.contested)
+	}
+	if submitted := store.disputes["dp_1"].EvidenceSubmittedAt; submitted == nil || !submitted.Equal(now) {
+		t.Fatalf("expected evidence submission to be recorded, got %v", submitted)
+	}
+	want := []string{DisputeEventEvidenceDueSoon, DisputeEventEvidenceSubmitted}
+	if len(notifier.events) != len(want) || notifier.events[0] != want[0] || notifier.events[1] != want[1] {
+		t.Fatalf("expected events %v, got %v", want, notifier.events)
+	}
+}
+
+func TestDisputeReminderRetriesFailedAutoSubmit(t *testing.T) {
+	tenantID := "tenant-1"
+	store := &fakeDisputeStore{disputes: map[string]*models.Dispute{
+		"dp_1": {
+			ID:                 "dp_1",
+			ProviderDisputeID:  "du_1",
+			TenantID:           &tenantID,
+			Status:             models.DisputeStatusOpen,
+			Evidence:           map[string]interface{}{"receipt": "r_1"},
+			AutoSubmitEvidence: true,
+			DueBy:              time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}}
+	provider := &fakeDisputeProvider{
+		contested:  map[string]map[string]interface{}{},
+		contestErr: errors.New("provider unavailable"),
+	}
+	notifier := &fakeNotifier{}
+	svc := CreateDisputeService(store, nil, provider)
+	svc.SetReminders(DisputeReminderConfig{LeadTime: 48 * time.Hour}, notifier)
+	svc.now = func() time.Time { return time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC) }
+
+	if _, err := svc.ProcessEvidenceReminders(context.Background(), 10); err == nil {
+		t.Fatal("expected the failed submission to be reported")
+	}
+	if dispute := store.disputes["dp_1"]; dispute.ReminderSentAt != nil || dispute.EvidenceSubmittedAt != nil {
+		t.Fatalf("expected nothing to be recorded after a failed submission, got %+v", dispute)
+	}
+	if len(notifier.events) != 0 {
+		t.Fatalf("expected no events after a failed submission, got %v", notifier.events)
+	}
+
+	provider.contestErr = nil
+	if _, err := svc.ProcessEvidenceReminders(context.Background(), 10); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if dispute := store.disputes["dp_1"]; dispute.ReminderSentAt == nil || dispute.EvidenceSubmittedAt == nil {
+		t.Fatalf("expected the retry to submit and record the reminder, got %+v", dispute)
+	}
+}
+
+func TestDisputeReminderSkipsPastDueDisputes(t *testing.T) {
+	tenantID := "tenant-1"
+	store := &fakeDisputeStore{disputes: map[string]*models.Dispute{
+		"dp_1": {
+			ID:                 "dp_1",
+			ProviderDisputeID:  "du_1",
+			TenantID:           &tenantID,
+			Status:             models.DisputeStatusOpen,
+			Evidence:           map[string]interface{}{"receipt": "r_1"},
+			AutoSubmitEvidence: true,
+			DueBy:              time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC),
+		},
+	}}
+	provider := &fakeDisputeProvider{contested: map[string]map[string]interface{}{}}
+	notifier := &fakeNotifier{}
+	svc := CreateDisputeService(store, nil, provider)
+	svc.SetReminders(DisputeReminderConfig{LeadTime: 48 * time.Hour}, notifier)
+	svc.now = func() time.Time { return time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC) }
+
+	if n, err := svc.ProcessEvidenceReminders(context.Background(), 10); err != nil || n != 0 {
+		t.Fatalf("expected past-due dispute to be skipped, got n=%d err=%v", n, err)
+	}
+	if len(provider.contested) != 0 || len(notifier.events) != 0 || store.disputes["dp_1"].ReminderSentAt != nil {
+		t.Fatalf("expected no action for a past-due dispute, got contested=%v events=%v", provider.contested, notifier.events)
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+const (
+	DisputeEventCreated = "dispute.created"
+	DisputeEventUpdated = "dispute.updated"
+	DisputeEventClosed  = "dispute.closed"
+)
+
+// DisputeEvent is a provider dispute notification normalized by the webhook
+// service. ProviderPaymentID matches Payment.ProviderChargeID.
+type DisputeEvent struct {
+	Provider          string
+	ProviderDisputeID string
+	ProviderPaymentID string
+	Status            models.DisputeStatus
+	Amount            int64
+	Currency          string
+	Reason            string
+	DueBy             time.Time
+}
+
+// HandleDisputeEvent records a provider dispute status change on the stored
+// dispute and its payment. Disputes not seen before are created from the
+// payment; events for payments this service does not know are ignored.
+func (s *DisputeService) HandleDisputeEvent(ctx context.Context, event *DisputeEvent) error {
+	var payment *models.Payment
+	if s.paymentRepo != nil && event.ProviderPaymentID != "" {
+		found, err := s.paymentRepo.GetByProviderChargeID(ctx, event.ProviderPaymentID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		payment = found
+	}
+
+	dispute, err := s.disputeRepo.GetByProviderDisputeID(ctx, event.ProviderDisputeID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	created := err != nil
+	if created {
+		if payment == nil {
+			return nil
+		}
+		dispute = &models.Dispute{
+			TenantID:          payment.TenantID,
+			CustomerID:        payment.CustomerID,
+			TransactionID:     payment.ID,
+			ProviderName:      event.Provider,
+			ProviderDisputeID: event.ProviderDisputeID,
+			Status:            models.DisputeStatusOpen,
+		}
+	}
+
+	wasOpen := dispute.Status == models.DisputeStatusOpen
+	applyDisputeEvent(dispute, event, s.now())
+
+	if created {
+		err = s.disputeRepo.Create(ctx, dispute)
+	} else {
+		err = s.disputeRepo.Update(ctx, dispute)
+	}
+	if err != nil {
+		return err
+	}
+
+	if payment != nil {
+		if err := s.syncDisputedPayment(ctx, payment, dispute.Status); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case created:
+		s.notify(ctx, dispute, DisputeEventCreated)
+	case wasOpen && dispute.Status != models.DisputeStatusOpen:
+		s.notify(ctx, dispute, DisputeEventClosed)
+	default:
+		s.notify(ctx, dispute, DisputeEventUpdated)
+	}
+	return nil
+}
+
+func applyDisputeEvent(dispute *models.Dispute, event *DisputeEvent, now time.Time) {
+	if event.Status != "" {
+		dispute.Status = event.Status
+	}
+	if event.Amount > 0 {
+		dispute.Amount = event.Amount
+	}
+	if event.Currency != "" {
+		dispute.Currency = event.Currency
+	}
+	if event.Reason != "" {
+		dispute.Reason = event.Reason
+	}
+	if !event.DueBy.IsZero() {
+		dispute.DueBy = event.DueBy
+	}
+
+	if dispute.Status == models.DisputeStatusOpen {
+		dispute.ClosedAt = nil
+	} else if dispute.ClosedAt == nil {
+		dispute.ClosedAt = &now
+	}
+}
+
+// syncDisputedPayment keeps the payment disputed while the dispute is open or
+// lost, and restores it once the dispute is won or withdrawn.
+func (s *DisputeService) syncDisputedPayment(ctx context.Context, payment *models.Payment, status models.DisputeStatus) error {
+	next := payment.Status
+	switch status {
+	case models.DisputeStatusOpen, models.DisputeStatusLost:
+		next = models.PaymentStatusDisputed
+	case models.DisputeStatusWon, models.DisputeStatusCanceled:
+		if payment.Status == models.PaymentStatusDisputed {
+			next = models.PaymentStatusSuccess
+		}
+	}
+
+	if next == payment.Status {
+		return nil
+	}
+	payment.Status = next
+	return s.paymentRepo.Update(ctx, payment)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type fakeDisputePaymentStore struct {
+	payments map[string]*models.Payment
+}
+
+func (f *fakeDisputePaymentStore) GetByProviderChargeID(_ context.Context, providerChargeID string) (*models.Payment, error) {
+	for _, payment := range f.payments {
+		if payment.ProviderChargeID == providerChargeID {
+			copied := *payment
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeDisputePaymentStore) Update(_ context.Context, payment *models.Payment) error {
+	copied := *payment
+	f.payments[payment.ID] = &copied
+	return nil
+}
+
+func stripeDisputeWebhook(eventType, status string) *models.WebhookEvent {
+	return &models.WebhookEvent{
+		Provider:  "stripe",
+		EventType: eventType,
+		Payload: models.JSON{
+			"data": map[string]interface{}{
+				"object": map[string]interface{}{
+					"id":               "dp_123",
+					"payment_intent":   "pi_123",
+					"amount":           float64(5000),
+					"currency":         "usd",
+					"reason":           "fraudulent",
+					"status":           status,
+					"evidence_details": map[string]interface{}{"due_by": float64(1772323200)},
+				},
+			},
+		},
+	}
+}
+
+func TestStripeDisputeWonUpdatesDisputeAndPayment(t *testing.T) {
+	tenantID := "tenant-1"
+	disputes := &fakeDisputeStore{disputes: map[string]*models.Dispute{}}
+	payments := &fakeDisputePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", TenantID: &tenantID, CustomerID: "cus_1", ProviderChargeID: "pi_123", Status: models.PaymentStatusSuccess},
+	}}
+	notifier := &fakeNotifier{}
+	svc := CreateDisputeService(disputes, payments, nil)
+	svc.SetReminders(DefaultDisputeReminderConfig(), notifier)
+	webhooks := &WebhookService{disputeEvents: svc}
+	ctx := context.Background()
+
+	if err := webhooks.dispatchEvent(ctx, stripeDisputeWebhook("charge.dispute.created", "needs_response")); err != nil {
+		t.Fatalf("dispatch charge.dispute.created: %v", err)
+	}
+	if payments.payments["pay_1"].Status != models.PaymentStatusDisputed {
+		t.Fatalf("expected payment to be disputed, got %s", payments.payments["pay_1"].Status)
+	}
+
+	if err := webhooks.dispatchEvent(ctx, stripeDisputeWebhook("charge.dispute.closed", "won")); err != nil {
+		t.Fatalf("dispatch charge.dispute.closed: %v", err)
+	}
+
+	if len(disputes.disputes) != 1 {
+		t.Fatalf("expected a single synced dispute, got %d", len(disputes.disputes))
+	}
+	for _, dispute := range disputes.disputes {
+		if dispute.Status != models.DisputeStatusWon || dispute.ClosedAt == nil {
+			t.Fatalf("expected won and closed dispute, got %+v", dispute)
+		}
+		if dispute.TransactionID != "pay_1" || dispute.Amount != 5000 || dispute.Currency != "USD" || dispute.DueBy.Unix() != 1772323200 {
+			t.Fatalf("dispute not populated from the provider event: %+v", dispute)
+		}
+	}
+	if payments.payments["pay_1"].Status != models.PaymentStatusSuccess {
+		t.Fatalf("expected payment to be restored after a won dispute, got %s", payments.payments["pay_1"].Status)
+	}
+
+	want := []string{DisputeEventCreated, DisputeEventClosed}
+	if len(notifier.events) != len(want) || notifier.events[0] != want[0] || notifier.events[1] != want[1] {
+		t.Fatalf("expected events %v, got %v", want, notifier.events)
+	}
+}
+
+func TestRazorpayDisputeLostKeepsPaymentDisputed(t *testing.T) {
+	tenantID := "tenant-1"
+	disputes := &fakeDisputeStore{disputes: map[string]*models.Dispute{}}
+	payments := &fakeDisputePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", TenantID: &tenantID, CustomerID: "cus_1", ProviderChargeID: "order_123", Status: models.PaymentStatusSuccess},
+	}}
+	svc := CreateDisputeService(disputes, payments, nil)
+	svc.SetReminders(DefaultDisputeReminderConfig(), &fakeNotifier{})
+	webhooks := &WebhookService{disputeEvents: svc}
+
+	err := webhooks.dispatchEvent(context.Background(), &models.WebhookEvent{
+		Provider:  "razorpay",
+		EventType: "payment.dispute.lost",
+		Payload: models.JSON{
+			"event": "payment.dispute.lost",
+			"payload": map[string]interface{}{
+				"payment": map[string]interface{}{"entity": map[string]interface{}{"id": "pay_rzp", "order_id": "order_123"}},
+				"dispute": map[string]interface{}{"entity": map[string]interface{}{
+					"id": "disp_123", "payment_id": "pay_rzp", "amount": float64(10000), "currency": "INR", "status": "lost",
+				}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("dispatch payment.dispute.lost: %v", err)
+	}
+
+	dispute, err := disputes.GetByProviderDisputeID(context.Background(), "disp_123")
+	if err != nil {
+		t.Fatalf("expected dispute to be synced: %v", err)
+	}
+	if dispute.Status != models.DisputeStatusLost || dispute.ProviderName != "razorpay" {
+		t.Fatalf("unexpected dispute %+v", dispute)
+	}
+	if payments.payments["pay_1"].Status != models.PaymentStatusDisputed {
+		t.Fatalf("expected payment to stay disputed, got %s", payments.payments["pay_1"].Status)
+	}
+}
+
+func TestDisputeEventReturnsLookupErrors(t *testing.T) {
+	tenantID := "tenant-1"
+	disputes := &fakeDisputeStore{disputes: map[string]*models.Dispute{}, getErr: errors.New("connection reset")}
+	payments := &fakeDisputePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", TenantID: &tenantID, CustomerID: "cus_1", ProviderChargeID: "pi_123", Status: models.PaymentStatusSuccess},
+	}}
+	notifier := &fakeNotifier{}
+	svc := CreateDisputeService(disputes, payments, nil)
+	svc.SetReminders(DefaultDisputeReminderConfig(), notifier)
+	webhooks := &WebhookService{disputeEvents: svc}
+
+	if err := webhooks.dispatchEvent(context.Background(), stripeDisputeWebhook("charge.dispute.updated", "under_review")); !errors.Is(err, disputes.getErr) {
+		t.Fatalf("expected the lookup error to be returned, got %v", err)
+	}
+	if len(disputes.disputes) != 0 || len(notifier.events) != 0 {
+		t.Fatalf("expected no dispute to be created on a lookup error, got %v", disputes.disputes)
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+const (
+	SubscriptionEventPastDue     = "subscription.past_due"
+	SubscriptionEventRetryFailed = "subscription.payment_retry_failed"
+	SubscriptionEventRecovered   = "subscription.payment_recovered"
+	SubscriptionEventCanceled    = "subscription.canceled"
+)
+
+const dunningCancelReason = "dunning_exhausted"
+
+var ErrNoInvoiceToRetry = errors.New("subscription has no invoice to retry")
+
+type OutboundNotifier interface {
+	SendOutboundWebhook(ctx context.Context, tenantID, eventType string, subject interface{}, data map[string]interface{}) error
+}
+
+// DunningConfig lists the delay before each payment retry. Once every retry
+// has failed the subscription is canceled.
+type DunningConfig struct {
+	RetryIntervals []time.Duration
+}
+
+func DefaultDunningConfig() DunningConfig {
+	return DunningConfig{
+		RetryIntervals: []time.Duration{24 * time.Hour, 72 * time.Hour, 120 * time.Hour},
+	}
+}
+
+func (s *SubscriptionService) SetDunning(cfg DunningConfig, notifier OutboundNotifier) {
+	if len(cfg.RetryIntervals) > 0 {
+		s.dunning = cfg
+	}
+	s.notifier = notifier
+}
+
+// HandleInvoicePaymentFailed starts dunning for a subscription. Failures
+// reported for the invoice already being retried are ignored, since the
+// provider reports every failed retry as well.
+func (s *SubscriptionService) HandleInvoicePaymentFailed(ctx context.Context, subscriptionID, invoiceID string) error {
+	subscription, err := s.getWebhookSubscription(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	if subscription.Status == models.SubscriptionStatusCanceled {
+		return nil
+	}
+	if subscription.Status == models.SubscriptionStatusPastDue && subscription.LatestInvoiceID == invoiceID {
+		return nil
+	}
+
+	next := s.now().Add(s.dunning.RetryIntervals[0])
+	subscription.Status = models.SubscriptionStatusPastDue
+	subscription.LatestInvoiceID = invoiceID
+	subscription.DunningAttempts = 0
+	subscription.NextDunningAt = &next
+	if err := s.subRepo.Update(ctx, subscription); err != nil {
+		return err
+	}
+
+	s.notify(ctx, subscription, SubscriptionEventPastDue)
+	return nil
+}
+
+func (s *SubscriptionService) HandleInvoicePaid(ctx context.Context, subscriptionID, invoiceID string) error {
+	subscription, err := s.getWebhookSubscription(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+
+	if subscription.Status != models.SubscriptionStatusPastDue {
+		return nil
+	}
+	if invoiceID != "" && subscription.LatestInvoiceID != "" && subscription.LatestInvoiceID != invoiceID {
+		return nil
+	}
+
+	return s.markRecovered(ctx, subscription)
+}
+
+// getWebhookSubscription loads the subscription an invoice event refers to.
+// When the event was verified with a tenant's own secret, subscriptions that
+// belong to another tenant are treated as not found.
+func (s *SubscriptionService) getWebhookSubscription(ctx context.Context, subscriptionID string) (*models.Subscription, error) {
+	subscription, err := s.subRepo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	if tenantID, ok := ctx.Value(ctxkeys.TenantID).(string); ok && tenantID != "" {
+		if subscription.TenantID == nil || *subscription.TenantID != tenantID {
+			return nil, ErrSubscriptionNotFound
+		}
+	}
+	return subscription, nil
+}
+
+// ProcessDunning retries payment for past-due subscriptions whose next
+// attempt is due and returns how many were processed.
+func (s *SubscriptionService) ProcessDunning(ctx context.Context, batchSize int) (int, error) {
+	due, err := s.subRepo.ListDueForDunning(ctx, s.now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for _, subscription := range due {
+		if err := s.retryPayment(ctx, subscription); err != nil {
+			errs = append(errs, fmt.Errorf("dunning %s: %w", subscription.ID, err))
+		}
+	}
+	return len(due), errors.Join(errs...)
+}
+
+// retryPayment counts an attempt only when the provider declined the charge
+// or left the invoice unpaid. Outages and unsupported providers push the
+// retry back without using up an attempt.
+func (s *SubscriptionService) retryPayment(ctx context.Context, subscription *models.Subscription) error {
+	invoice, err := s.payInvoice(ctx, subscription)
+	switch {
+	case err == nil && invoice.Status == models.InvoiceStatusPaid:
+		return s.markRecovered(ctx, subscription)
+	case err != nil && !errors.Is(err, providers.ErrPaymentDeclined):
+		next := s.now().Add(s.dunning.RetryIntervals[min(subscription.DunningAttempts, len(s.dunning.RetryIntervals)-1)])
+		subscription.NextDunningAt = &next
+		if updateErr := s.subRepo.Update(ctx, subscription); updateErr != nil {
+			return errors.Join(err, updateErr)
+		}
+		return err
+	}
+
+	subscription.DunningAttempts++
+
+	if subscription.DunningAttempts >= len(s.dunning.RetryIntervals) {
+		return s.cancelAfterDunning(ctx, subscription)
+	}
+
+	next := s.now().Add(s.dunning.RetryIntervals[subscription.DunningAttempts])
+	subscription.NextDunningAt = &next
+	if err := s.subRepo.Update(ctx, subscription); err != nil {
+		return err
+	}
+
+	s.notify(ctx, subscription, SubscriptionEventRetryFailed)
+	return nil
+}
+
+func (s *SubscriptionService) payInvoice(ctx context.Context, subscription *models.Subscription) (*models.Invoice, error) {
+	if subscription.LatestInvoiceID == "" {
+		return nil, ErrNoInvoiceToRetry
+	}
+
+	provider := s.providerFor(ctx, subscription)
+	if provider == nil {
+		return nil, ErrNoAvailableProvider
+	}
+
+	payer, ok := provider.(providers.InvoicePaymentProvider)
+	if !ok {
+		return nil, providers.ErrNotSupported
+	}
+	return callProvider(ctx, "pay_invoice", func(ctx context.Context) (*models.Invoice, error) {
+		return payer.PayInvoice(ctx, subscription.LatestInvoiceID)
+	})
+}
+
+func (s *SubscriptionService) providerFor(ctx context.Context, subscription *models.Subscription) providers.PaymentProvider {
+	return s.providerNamed(ctx, subscription.ProviderName)
+}
+
+// providerNamed returns the configured provider called name, or the first
+// available one when none is, such as when a selector routes for them.
+func (s *SubscriptionService) providerNamed(ctx context.Context, name string) providers.PaymentProvider {
+	s.mu.RLock()
+	for _, provider := range s.providers {
+		if provider.Name() == name {
+			s.mu.RUnlock()
+			return provider
+		}
+	}
+	s.mu.RUnlock()
+
+	return s.getAvailableProvider(ctx)
+}
+
+func (s *SubscriptionService) markRecovered(ctx context.Context, subscription *models.Subscription) error {
+	subscription.Status = models.SubscriptionStatusActive
+	subscription.DunningAttempts = 0
+	subscription.NextDunningAt = nil
+	if err := s.subRepo.Update(ctx, subscription); err != nil {
+		return err
+	}
+
+	s.notify(ctx, subscription, SubscriptionEventRecovered)
+	return nil
+}
+
+func (s *SubscriptionService) cancelAfterDunning(ctx context.Context, subscription *models.Subscription) error {
+	if provider := s.providerFor(ctx, subscription); provider != nil {
+		if _, err := callProvider(ctx, "cancel_subscription", func(ctx context.Context) (*models.Subscription, error) {
+			return provider.CancelSubscription(ctx, subscription.ID, &models.CancelSubscriptionRequest{Reason: dunningCancelReason})
+		}); err != nil {
+			return err
+		}
+	}
+
+	now := s.now()
+	subscription.Status = models.SubscriptionStatusCanceled
+	subscription.CanceledAt = &now
+	subscription.NextDunningAt = nil
+	if err := s.subRepo.Update(ctx, subscription); err != nil {
+		return err
+	}
+
+	s.notify(ctx, subscription, SubscriptionEventCanceled)
+	return nil
+}
+
+func (s *SubscriptionService) notify(ctx context.Context, subscription *models.Subscription, eventType string) {
+	if s.notifier == nil || subscription.TenantID == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"subscription_id":  subscription.ID,
+		"customer_id":      subscription.CustomerID,
+		"status":           subscription.Status,
+		"invoice_id":       subscription.LatestInvoiceID,
+		"dunning_attempts": subscription.DunningAttempts,
+	}
+	if subscription.NextDunningAt != nil {
+		data["next_attempt_at"] = subscription.NextDunningAt
+	}
+
+	_ = s.notifier.SendOutboundWebhook(ctx, *subscription.TenantID, eventType, subscription, data)
+}
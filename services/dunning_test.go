@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type fakeSubscriptionStore struct {
+	subscriptions map[string]*models.Subscription
+}
+
+func (f *fakeSubscriptionStore) Create(_ context.Context, sub *models.Subscription) error {
+	f.subscriptions[sub.ID] = sub
+	return nil
+}
+
+func (f *fakeSubscriptionStore) Update(_ context.Context, sub *models.Subscription) error {
+	copied := *sub
+	f.subscriptions[sub.ID] = &copied
+	return nil
+}
+
+func (f *fakeSubscriptionStore) GetByID(_ context.Context, id string) (*models.Subscription, error) {
+	sub, ok := f.subscriptions[id]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+func (f *fakeSubscriptionStore) ListByCustomer(context.Context, string) ([]*models.Subscription, error) {
+	return nil, nil
+}
+
+func (f *fakeSubscriptionStore) ListDueForDunning(_ context.Context, before time.Time, limit int) ([]*models.Subscription, error) {
+	var due []*models.Subscription
+	for _, sub := range f.subscriptions {
+		if sub.Status == models.SubscriptionStatusPastDue && sub.NextDunningAt != nil && !sub.NextDunningAt.After(before) {
+			copied := *sub
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+type fakeDunningProvider struct {
+	providers.PaymentProvider
+	payErr   error
+	paid     bool
+	payCalls int
+	canceled []string
+}
+
+func (f *fakeDunningProvider) Name() string                     { return "stripe" }
+func (f *fakeDunningProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeDunningProvider) PayInvoice(_ context.Context, invoiceID string) (*models.Invoice, error) {
+	f.payCalls++
+	if f.payErr != nil {
+		return nil, f.payErr
+	}
+	status := models.InvoiceStatusPending
+	if f.paid {
+		status = models.InvoiceStatusPaid
+	}
+	return &models.Invoice{ProviderID: invoiceID, Status: status}, nil
+}
+
+func (f *fakeDunningProvider) CancelSubscription(_ context.Context, id string, _ *models.CancelSubscriptionRequest) (*models.Subscription, error) {
+	f.canceled = append(f.canceled, id)
+	return &models.Subscription{ID: id, Status: models.SubscriptionStatusCanceled}, nil
+}
+
+type fakeNotifier struct {
+	events []string
+}
+
+func (f *fakeNotifier) SendOutboundWebhook(_ context.Context, _ string, eventType string, _ interface{}, _ map[string]interface{}) error {
+	f.events = append(f.events, eventType)
+	return nil
+}
+
+func invoiceFailedEvent(invoiceID string) *models.WebhookEvent {
+	return &models.WebhookEvent{
+		Provider:  "stripe",
+		EventType: "invoice.payment_failed",
+		Payload: models.JSON{
+			"data": map[string]interface{}{
+				"object": map[string]interface{}{"id": invoiceID, "subscription": "sub_1"},
+			},
+		},
+	}
+}
+
+func TestDunningRetriesFailedInvoiceUntilCanceled(t *testing.T) {
+	provider := &fakeDunningProvider{payErr: fmt.Errorf("%w: card_declined", providers.ErrPaymentDeclined)}
+	tenantID := "tenant-1"
+	store := &fakeSubscriptionStore{subscriptions: map[string]*models.Subscription{
+		"sub_1": {ID: "sub_1", TenantID: &tenantID, CustomerID: "cus_1", Status: models.SubscriptionStatusActive, ProviderName: "stripe"},
+	}}
+	notifier := &fakeNotifier{}
+	svc := CreateSubscriptionService(nil, store, provider)
+	svc.SetDunning(DunningConfig{RetryIntervals: []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour}}, notifier)
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	webhooks := &WebhookService{invoiceEvents: svc}
+	ctx := context.Background()
+
+	if err := webhooks.dispatchEvent(ctx, invoiceFailedEvent("in_1")); err != nil {
+		t.Fatalf("dispatch invoice.payment_failed: %v", err)
+	}
+	sub := store.subscriptions["sub_1"]
+	if sub.Status != models.SubscriptionStatusPastDue || sub.LatestInvoiceID != "in_1" || sub.DunningAttempts != 0 {
+		t.Fatalf("expected past_due with no attempts, got %+v", sub)
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		if n, err := svc.ProcessDunning(ctx, 10); err != nil || n != 0 {
+			t.Fatalf("attempt %d ran early: n=%d err=%v", attempt, n, err)
+		}
+		now = store.subscriptions["sub_1"].NextDunningAt.Add(time.Second)
+
+		if n, err := svc.ProcessDunning(ctx, 10); err != nil || n != 1 {
+			t.Fatalf("attempt %d: n=%d err=%v", attempt, n, err)
+		}
+		if got := store.subscriptions["sub_1"].DunningAttempts; got != attempt {
+			t.Fatalf("expected %d attempts, got %d", attempt, got)
+		}
+
+		if attempt < 3 {
+			if err := webhooks.dispatchEvent(ctx, invoiceFailedEvent("in_1")); err != nil {
+				t.Fatalf("redelivered failure: %v", err)
+			}
+			if got := store.subscriptions["sub_1"].DunningAttempts; got != attempt {
+				t.Fatalf("failure for the retried invoice reset dunning: %d attempts", got)
+			}
+		}
+	}
+
+	sub = store.subscriptions["sub_1"]
+	if sub.Status != models.SubscriptionStatusCanceled || sub.CanceledAt == nil || sub.NextDunningAt != nil {
+		t.Fatalf("expected canceled subscription, got %+v", sub)
+	}
+	if provider.payCalls != 3 || len(provider.canceled) != 1 {
+		t.Fatalf("expected 3 payment retries and 1 cancellation, got %d and %d", provider.payCalls, len(provider.canceled))
+	}
+
+	want := []string{
+		SubscriptionEventPastDue,
+		SubscriptionEventRetryFailed,
+		SubscriptionEventRetryFailed,
+		SubscriptionEventCanceled,
+	}
+	if len(notifier.events) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, notifier.events)
+	}
+	for i := range want {
+		if notifier.events[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, notifier.events)
+		}
+	}
+}
+
+func TestDunningRecoversWhenRetrySucceeds(t *testing.T) {
+	provider := &fakeDunningProvider{payErr: fmt.Errorf("%w: card_declined", providers.ErrPaymentDeclined)}
+	tenantID := "tenant-1"
+	store := &fakeSubscriptionStore{subscriptions: map[string]*models.Subscription{
+		"sub_1": {ID: "sub_1", TenantID: &tenantID, CustomerID: "cus_1", Status: models.SubscriptionStatusActive, ProviderName: "stripe"},
+	}}
+	notifier := &fakeNotifier{}
+	svc := CreateSubscriptionService(nil, store, provider)
+	svc.SetDunning(DunningConfig{RetryIntervals: []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour}}, notifier)
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := svc.HandleInvoicePaymentFailed(ctx, "sub_1", "in_1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if _, err := svc.ProcessDunning(ctx, 10); err != nil {
+		t.Fatalf("first retry: %v", err)
+	}
+
+	provider.payErr = nil
+	provider.paid = true
+	now = now.Add(2 * time.Hour)
+	if _, err := svc.ProcessDunning(ctx, 10); err != nil {
+		t.Fatalf("second retry: %v", err)
+	}
+
+	sub := store.subscriptions["sub_1"]
+	if sub.Status != models.SubscriptionStatusActive || sub.DunningAttempts != 0 || sub.NextDunningAt != nil {
+		t.Fatalf("expected recovered subscription, got %+v", sub)
+	}
+	if last := notifier.events[len(notifier.events)-1]; last != SubscriptionEventRecovered {
+		t.Fatalf("expected recovery event, got %v", notifier.events)
+	}
+}
+
+func TestDunningOutageDoesNotUseAnAttempt(t *testing.T) {
+	provider := &fakeDunningProvider{payErr: errors.New("connection reset")}
+	tenantID := "tenant-1"
+	store := &fakeSubscriptionStore{subscriptions: map[string]*models.Subscription{
+		"sub_1": {ID: "sub_1", TenantID: &tenantID, CustomerID: "cus_1", Status: models.SubscriptionStatusActive, ProviderName: "stripe"},
+	}}
+	notifier := &fakeNotifier{}
+	svc := CreateSubscriptionService(nil, store, provider)
+	svc.SetDunning(DunningConfig{RetryIntervals: []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour}}, notifier)
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := svc.HandleInvoicePaymentFailed(ctx, "sub_1", "in_1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if _, err := svc.ProcessDunning(ctx, 10); err == nil {
+		t.Fatal("expected the provider outage to be reported")
+	}
+
+	sub := store.subscriptions["sub_1"]
+	if sub.DunningAttempts != 0 || sub.Status != models.SubscriptionStatusPastDue {
+		t.Fatalf("expected outage not to count as an attempt, got %+v", sub)
+	}
+	if sub.NextDunningAt == nil || !sub.NextDunningAt.After(now) {
+		t.Fatalf("expected retry to be rescheduled, got %v", sub.NextDunningAt)
+	}
+	if len(notifier.events) != 1 {
+		t.Fatalf("expected only the past_due event, got %v", notifier.events)
+	}
+}
+
+func TestDunningIgnoresInvoiceEventsForOtherTenants(t *testing.T) {
+	tenantID := "tenant-1"
+	store := &fakeSubscriptionStore{subscriptions: map[string]*models.Subscription{
+		"sub_1": {ID: "sub_1", TenantID: &tenantID, CustomerID: "cus_1", Status: models.SubscriptionStatusActive, ProviderName: "stripe"},
+	}}
+	svc := CreateSubscriptionService(nil, store, &fakeDunningProvider{})
+	svc.SetDunning(DunningConfig{RetryIntervals: []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour}}, &fakeNotifier{})
+	webhooks := &WebhookService{invoiceEvents: svc}
+
+	event := invoiceFailedEvent("in_1")
+	otherTenant := "tenant-2"
+	event.TenantID = &otherTenant
+	if err := webhooks.dispatchEvent(context.Background(), event); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if sub := store.subscriptions["sub_1"]; sub.Status != models.SubscriptionStatusActive {
+		t.Fatalf("expected another tenant's event to be ignored, got %+v", sub)
+	}
+
+	ctx := context.WithValue(context.Background(), ctxkeys.TenantID, "tenant-1")
+	if err := svc.HandleInvoicePaymentFailed(ctx, "sub_1", "in_1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sub := store.subscriptions["sub_1"]; sub.Status != models.SubscriptionStatusPastDue {
+		t.Fatalf("expected owning tenant's event to start dunning, got %+v", sub)
+	}
+}
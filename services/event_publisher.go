@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/malwarebo/conductor/cache"
+	"github.com/malwarebo/conductor/models"
+)
+
+// DefaultEventTopicPrefix starts the topic inbound webhook events are
+// published to; the provider name is appended, as in
+// "conductor.webhooks.stripe".
+const DefaultEventTopicPrefix = "conductor.webhooks"
+
+// eventPublishTimeout bounds publishing while the provider waits for its
+// webhook to be acknowledged; slower publishes are retried later.
+const eventPublishTimeout = 5 * time.Second
+
+// EventPublisher forwards inbound provider webhook events to a message queue
+// such as Kafka or NATS for a deployment's own pipelines.
+type EventPublisher interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+}
+
+// NoopEventPublisher drops every event. It is the default when no publisher
+// is configured.
+type NoopEventPublisher struct{}
+
+func (NoopEventPublisher) Publish(context.Context, string, []byte) error {
+	return nil
+}
+
+// RedisStreamPublisher appends each event to a Redis stream named after its
+// topic, under the "payload" field.
+type RedisStreamPublisher struct {
+	redis *cache.RedisCache
+}
+
+func CreateRedisStreamPublisher(redisCache *cache.RedisCache) *RedisStreamPublisher {
+	return &RedisStreamPublisher{redis: redisCache}
+}
+
+func (p *RedisStreamPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	return p.redis.AddToStream(ctx, topic, map[string]interface{}{"payload": payload})
+}
+
+// EventPublishRepository records events whose publishing failed so they can
+// be retried.
+type EventPublishRepository interface {
+	MarkPublishFailed(ctx context.Context, id string, errMsg string) error
+	MarkPublished(ctx context.Context, id string) error
+	ListPublishPending(ctx context.Context, limit int) ([]*models.WebhookEvent, error)
+}
+
+// SetEventPublisher forwards every newly received provider webhook event to
+// publisher under topicPrefix + "." + provider. An empty prefix uses
+// DefaultEventTopicPrefix.
+func (s *WebhookService) SetEventPublisher(publisher EventPublisher, topicPrefix string) {
+	if topicPrefix == "" {
+		topicPrefix = DefaultEventTopicPrefix
+	}
+	s.publisher = publisher
+	s.topicPrefix = topicPrefix
+}
+
+// publishEvent forwards a stored event. A failure is recorded on the event
+// for RetryEventPublishes instead of being returned, so the provider's
+// webhook is still acknowledged.
+func (s *WebhookService) publishEvent(ctx context.Context, event *models.WebhookEvent) {
+	if s.publisher == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, eventPublishTimeout)
+	defer cancel()
+	if err := s.sendEvent(ctx, event); err != nil && s.publishes != nil {
+		_ = s.publishes.MarkPublishFailed(ctx, event.ID, err.Error())
+	}
+}
+
+// RetryEventPublishes publishes up to limit events whose earlier publish
+// failed, returning how many went through.
+func (s *WebhookService) RetryEventPublishes(ctx context.Context, limit int) (int, error) {
+	if s.publisher == nil || s.publishes == nil {
+		return 0, nil
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	events, err := s.publishes.ListPublishPending(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var errs []error
+	for _, event := range events {
+		if err := s.sendEvent(ctx, event); err != nil {
+			_ = s.publishes.MarkPublishFailed(ctx, event.ID, err.Error())
+			errs = append(errs, fmt.Errorf("webhook event %s: %w", event.ID, err))
+			continue
+		}
+		if err := s.publishes.MarkPublished(ctx, event.ID); err != nil {
+			errs = append(errs, fmt.Errorf("webhook event %s: %w", event.ID, err))
+			continue
+		}
+		published++
+	}
+	return published, errors.Join(errs...)
+}
+
+func (s *WebhookService) sendEvent(ctx context.Context, event *models.WebhookEvent) error {
+	published := models.PublishedWebhookEvent{
+		ID:         event.ID,
+		Provider:   event.Provider,
+		EventType:  event.EventType,
+		EventID:    event.EventID,
+		Payload:    event.Payload,
+		ReceivedAt: event.CreatedAt,
+	}
+	if event.TenantID != nil {
+		published.TenantID = *event.TenantID
+	}
+
+	payload, err := json.Marshal(published)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+	return s.publisher.Publish(ctx, s.topicPrefix+"."+event.Provider, payload)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+type fakeEventPublisher struct {
+	err    error
+	topics []string
+	events []models.PublishedWebhookEvent
+}
+
+func (f *fakeEventPublisher) Publish(_ context.Context, topic string, payload []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	var event models.PublishedWebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+	f.topics = append(f.topics, topic)
+	f.events = append(f.events, event)
+	return nil
+}
+
+type fakeEventPublishRepo struct {
+	events map[string]*models.WebhookEvent
+}
+
+func (f *fakeEventPublishRepo) MarkPublishFailed(_ context.Context, id string, errMsg string) error {
+	f.events[id].PublishPending = true
+	f.events[id].PublishError = errMsg
+	return nil
+}
+
+func (f *fakeEventPublishRepo) MarkPublished(_ context.Context, id string) error {
+	f.events[id].PublishPending = false
+	f.events[id].PublishError = ""
+	return nil
+}
+
+func (f *fakeEventPublishRepo) ListPublishPending(_ context.Context, limit int) ([]*models.WebhookEvent, error) {
+	var pending []*models.WebhookEvent
+	for _, event := range f.events {
+		if event.PublishPending && len(pending) < limit {
+			pending = append(pending, event)
+		}
+	}
+	return pending, nil
+}
+
+func TestWebhookEventPublishedToProviderTopic(t *testing.T) {
+	tenantID := "ten_1"
+	event := &models.WebhookEvent{
+		ID:        "evt_row_1",
+		TenantID:  &tenantID,
+		Provider:  "stripe",
+		EventType: "payment_intent.succeeded",
+		EventID:   "evt_123",
+		Payload:   models.JSON{"id": "evt_123", "type": "payment_intent.succeeded"},
+		CreatedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+	}
+	publisher := &fakeEventPublisher{}
+	svc := &WebhookService{publishes: &fakeEventPublishRepo{events: map[string]*models.WebhookEvent{event.ID: event}}}
+	svc.SetEventPublisher(publisher, "")
+
+	svc.publishEvent(context.Background(), event)
+
+	if len(publisher.events) != 1 || publisher.topics[0] != "conductor.webhooks.stripe" {
+		t.Fatalf("expected one event on conductor.webhooks.stripe, got %v", publisher.topics)
+	}
+	published := publisher.events[0]
+	if published.ID != "evt_row_1" || published.EventID != "evt_123" || published.EventType != "payment_intent.succeeded" {
+		t.Fatalf("unexpected published event: %+v", published)
+	}
+	if published.TenantID != "ten_1" || published.Payload["type"] != "payment_intent.succeeded" || !published.ReceivedAt.Equal(event.CreatedAt) {
+		t.Fatalf("expected the tenant, payload and receive time to be published, got %+v", published)
+	}
+	if event.PublishPending {
+		t.Fatal("expected a published event not to be queued for retry")
+	}
+}
+
+func TestFailedWebhookEventPublishIsRetried(t *testing.T) {
+	event := &models.WebhookEvent{ID: "evt_row_1", Provider: "stripe", EventType: "payment_intent.succeeded", EventID: "evt_123"}
+	publisher := &fakeEventPublisher{err: errors.New("broker unavailable")}
+	svc := &WebhookService{publishes: &fakeEventPublishRepo{events: map[string]*models.WebhookEvent{event.ID: event}}}
+	svc.SetEventPublisher(publisher, "")
+
+	svc.publishEvent(context.Background(), event)
+
+	if !event.PublishPending || event.PublishError != "broker unavailable" {
+		t.Fatalf("expected the failure to be recorded for retry, got %+v", event)
+	}
+
+	publisher.err = nil
+	published, err := svc.RetryEventPublishes(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if published != 1 || len(publisher.events) != 1 || publisher.events[0].ID != event.ID {
+		t.Fatalf("expected the event to be published on retry, got %d: %+v", published, publisher.events)
+	}
+	if event.PublishPending || event.PublishError != "" {
+		t.Fatalf("expected the retry to clear the pending flag, got %+v", event)
+	}
+}
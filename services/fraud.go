@@ -4,40 +4,131 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/malwarebo/conductor/cache"
+	"github.com/malwarebo/conductor/internal/httputil"
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/stores"
 	"github.com/malwarebo/conductor/utils"
 )
 
 const (
-	openAIAPIURL = "https://api.openai.com/v1/chat/completions"
+	DefaultOpenAIModel          = "gpt-4o"
+	DefaultOpenAIBaseURL        = "https://api.openai.com/v1"
+	DefaultOpenAIRequestTimeout = 30 * time.Second
+	DefaultAzureOpenAIVersion   = "2024-10-21"
+
+	OpenAIAPITypeOpenAI = "openai"
+	OpenAIAPITypeAzure  = "azure"
+
 	systemPrompt = `You are an expert fraud detection analyst for an e-commerce platform.
 Analyze the following transaction data. Your goal is to identify suspicious patterns.
-High-risk indicators include: mismatch between billing and shipping countries, new users making large purchases, unusual IP addresses, or high transaction velocity.
+High-risk indicators include: mismatch between billing and shipping countries, new users making large purchases, unusual IP addresses (including datacenter or hosting IPs, or an IP country that differs from the billing country), or high transaction velocity.
 Based on the data, provide a fraud assessment. Your response MUST be a valid JSON object with three keys:
 1. "is_fraudulent": a boolean (true or false).
 2. "fraud_score": an integer between 0 (low risk) and 100 (high risk).
 3. "reason": a brief, clear explanation for your assessment.`
 )
 
+// ErrFraudResultNotSaved is returned when a decision could not be recorded.
+// Every decision must be stored for stats and audits, so it is not served.
+var ErrFraudResultNotSaved = errors.New("failed to save fraud analysis result")
+
 type FraudService interface {
 	AnalyzeTransaction(ctx context.Context, request *models.FraudAnalysisRequest) (*models.FraudAnalysisResponse, error)
 	GetStatsByDateRange(startDate, endDate time.Time) (*models.FraudStatsResponse, error)
+	SetFingerprintCounter(counter FingerprintCounter)
+}
+
+// FingerprintCounter reports how many customers hold a payment method with a
+// given provider fingerprint. A card saved by many customers is a common sign
+// of card testing or account farming.
+type FingerprintCounter interface {
+	CountByFingerprint(ctx context.Context, fingerprint string) (int64, error)
+}
+
+// OpenAIConfig selects the model and endpoint used for AI assessments.
+// "/chat/completions" is appended to BaseURL's path. With APIType "azure",
+// BaseURL is the deployment URL (".../openai/deployments/<name>"), the key is
+// sent in the api-key header and APIVersion is passed as api-version.
+type OpenAIConfig struct {
+	APIKey         string
+	APIType        string
+	APIVersion     string
+	Model          string
+	BaseURL        string
+	RequestTimeout time.Duration
+	HTTPClient     *http.Client
+}
+
+type openAITimeoutKey struct{}
+
+// WithOpenAITimeout overrides OpenAIConfig.RequestTimeout for calls made
+// with the returned context.
+func WithOpenAITimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, openAITimeoutKey{}, timeout)
+}
+
+func (c OpenAIConfig) requestTimeout(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(openAITimeoutKey{}).(time.Duration); ok && timeout > 0 {
+		return timeout
+	}
+	return c.RequestTimeout
+}
+
+func (c OpenAIConfig) withDefaults() OpenAIConfig {
+	if c.Model == "" {
+		c.Model = DefaultOpenAIModel
+	}
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultOpenAIBaseURL
+	}
+	if c.APIType == "" {
+		c.APIType = OpenAIAPITypeOpenAI
+	}
+	if c.APIType == OpenAIAPITypeAzure && c.APIVersion == "" {
+		c.APIVersion = DefaultAzureOpenAIVersion
+	}
+	if c.RequestTimeout <= 0 {
+		c.RequestTimeout = DefaultOpenAIRequestTimeout
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = httputil.NewHTTPClient(httputil.DefaultTransportConfig())
+	}
+	return c
+}
+
+func (c OpenAIConfig) chatCompletionsURL() (string, error) {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid OpenAI base URL: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/chat/completions"
+	if c.APIVersion != "" {
+		query := u.Query()
+		query.Set("api-version", c.APIVersion)
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), nil
 }
 
 type fraudService struct {
-	repo       stores.FraudRepository
-	openAIKey  string
-	httpClient *http.Client
-	cache      map[string]*models.FraudAnalysisResult
-	redis      *cache.RedisCache
+	repo         stores.FraudRepository
+	openAI       OpenAIConfig
+	rules        *FraudRulesEngine
+	ipAnalyzer   *utils.IPAnalyzer
+	httpClient   *http.Client
+	cache        map[string]*models.FraudAnalysisResult
+	redis        *cache.RedisCache
+	fingerprints FingerprintCounter
 }
 
 const fraudCachePrefix = "fraud:"
@@ -60,22 +151,38 @@ type Choice struct {
 	Message Message `json:"message"`
 }
 
-func CreateFraudService(repo stores.FraudRepository, openAIKey string) FraudService {
-	return CreateFraudServiceWithCache(repo, openAIKey, nil)
+func CreateFraudService(repo stores.FraudRepository, openAI OpenAIConfig) FraudService {
+	return CreateFraudServiceWithCache(repo, openAI, nil)
+}
+
+func CreateFraudServiceWithCache(repo stores.FraudRepository, openAI OpenAIConfig, redisCache *cache.RedisCache) FraudService {
+	return CreateFraudServiceWithRules(repo, openAI, redisCache, nil, nil)
 }
 
-func CreateFraudServiceWithCache(repo stores.FraudRepository, openAIKey string, redisCache *cache.RedisCache) FraudService {
+// CreateFraudServiceWithRules builds the full fraud service. A nil ipAnalyzer
+// falls back to the heuristic analyzer without GeoIP enrichment.
+func CreateFraudServiceWithRules(repo stores.FraudRepository, openAI OpenAIConfig, redisCache *cache.RedisCache, rules *FraudRulesEngine, ipAnalyzer *utils.IPAnalyzer) FraudService {
+	if ipAnalyzer == nil {
+		ipAnalyzer = utils.CreateIPAnalyzer()
+	}
+	openAI = openAI.withDefaults()
 	return &fraudService{
-		repo:      repo,
-		openAIKey: openAIKey,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		cache: make(map[string]*models.FraudAnalysisResult),
-		redis: redisCache,
+		repo:       repo,
+		openAI:     openAI,
+		rules:      rules,
+		ipAnalyzer: ipAnalyzer,
+		httpClient: openAI.HTTPClient,
+		cache:      make(map[string]*models.FraudAnalysisResult),
+		redis:      redisCache,
 	}
 }
 
+// SetFingerprintCounter adds the number of customers sharing the request's
+// card fingerprint to the data sent for AI assessment.
+func (s *fraudService) SetFingerprintCounter(counter FingerprintCounter) {
+	s.fingerprints = counter
+}
+
 func (s *fraudService) AnalyzeTransaction(ctx context.Context, request *models.FraudAnalysisRequest) (*models.FraudAnalysisResponse, error) {
 	cacheKey := fmt.Sprintf("%s_%s_%s_%s", request.TransactionID, request.UserID, request.IPAddress, request.BillingCountry)
 
@@ -84,35 +191,43 @@ func (s *fraudService) AnalyzeTransaction(ctx context.Context, request *models.F
 			"transaction_id": request.TransactionID,
 			"cache_key":      cacheKey,
 		})
+		replay := *cached
+		replay.ID = ""
+		replay.CreatedAt = time.Time{}
+		replay.UpdatedAt = time.Time{}
+		if err := s.repo.SaveAnalysisResult(&replay); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFraudResultNotSaved, err)
+		}
 		return &models.FraudAnalysisResponse{
-			Allow:  cached.Allow,
-			Reason: cached.Reason,
+			Allow:         cached.Allow,
+			Reason:        cached.Reason,
+			RuleTriggered: cached.RuleTriggered,
 		}, nil
 	}
 
-	anonymizedData := map[string]interface{}{
-		"transaction_amount":   request.TransactionAmount,
-		"billing_country":      request.BillingCountry,
-		"shipping_country":     request.ShippingCountry,
-		"transaction_velocity": request.TransactionVelocity,
-		"countries_match":      request.BillingCountry == request.ShippingCountry,
-		"amount_category":      categorizeAmount(request.TransactionAmount),
-		"ip_category":          categorizeIPAddress(request.IPAddress),
-	}
-
-	userMessageData, err := json.Marshal(anonymizedData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal transaction data: %w", err)
-	}
-
-	assessment, err := s.callOpenAI(ctx, string(userMessageData))
-	if err != nil {
-		// If OpenAI fails, use fallback logic
-		log.Printf("OpenAI API failed, using fallback logic: %v", err)
-		assessment = s.fallbackFraudDetection(request)
+	ipInfo := s.ipAnalyzer.AnalyzeIP(request.IPAddress)
+
+	var assessment *models.OpenAIFraudAssessment
+	var source models.FraudDecisionSource
+	var ruleTriggered string
+	if rule := s.rules.Evaluate(request); rule != nil {
+		source = models.FraudDecisionSourceRule
+		ruleTriggered = rule.Name
+		assessment = &models.OpenAIFraudAssessment{
+			IsFraudulent: true,
+			FraudScore:   100,
+			Reason:       fmt.Sprintf("Blocked by fraud rule %s", rule.Name),
+		}
+	} else {
+		var err error
+		source, assessment, err = s.assessWithAI(ctx, request, ipInfo)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	allow := !assessment.IsFraudulent || assessment.FraudScore < 70
+	allow := ruleTriggered == "" && (!assessment.IsFraudulent || assessment.FraudScore < 70)
+	reason := assessment.Reason
 
 	result := &models.FraudAnalysisResult{
 		TransactionID:       request.TransactionID,
@@ -124,26 +239,70 @@ func (s *fraudService) AnalyzeTransaction(ctx context.Context, request *models.F
 		TransactionVelocity: request.TransactionVelocity,
 		IsFraudulent:        assessment.IsFraudulent,
 		FraudScore:          assessment.FraudScore,
-		Reason:              assessment.Reason,
+		Reason:              reason,
 		Allow:               allow,
+		RuleTriggered:       ruleTriggered,
+		DecisionSource:      source,
+		IPRisk:              ipInfo.Risk,
 	}
 
 	if err := s.repo.SaveAnalysisResult(result); err != nil {
-		utils.CreateLogger("conductor").Error(ctx, "Failed to save fraud analysis result", map[string]interface{}{
-			"error": err.Error(),
-		})
+		return nil, fmt.Errorf("%w: %v", ErrFraudResultNotSaved, err)
 	}
 
 	s.setCachedResult(ctx, cacheKey, result)
 
 	response := &models.FraudAnalysisResponse{
-		Allow:  allow,
-		Reason: assessment.Reason,
+		Allow:         allow,
+		Reason:        reason,
+		RuleTriggered: ruleTriggered,
 	}
 
 	return response, nil
 }
 
+// assessWithAI asks the model for an assessment and falls back to the local
+// heuristics when the call fails.
+func (s *fraudService) assessWithAI(ctx context.Context, request *models.FraudAnalysisRequest, ipInfo utils.IPInfo) (models.FraudDecisionSource, *models.OpenAIFraudAssessment, error) {
+	anonymizedData := map[string]interface{}{
+		"transaction_amount":   request.TransactionAmount,
+		"billing_country":      request.BillingCountry,
+		"shipping_country":     request.ShippingCountry,
+		"transaction_velocity": request.TransactionVelocity,
+		"countries_match":      request.BillingCountry == request.ShippingCountry,
+		"amount_category":      categorizeAmount(request.TransactionAmount),
+		"ip_category":          ipInfo.Risk,
+		"ip_is_datacenter":     ipInfo.IsDatacenter,
+	}
+	if ipInfo.Country != "" {
+		anonymizedData["ip_country"] = ipInfo.Country
+		anonymizedData["ip_country_matches_billing"] = strings.EqualFold(ipInfo.Country, request.BillingCountry)
+	}
+	if ipInfo.ASN != 0 {
+		anonymizedData["ip_asn"] = ipInfo.ASN
+	}
+	if request.CardFingerprint != "" && s.fingerprints != nil {
+		if customers, err := s.fingerprints.CountByFingerprint(ctx, request.CardFingerprint); err != nil {
+			log.Printf("Failed to count card fingerprint reuse: %v", err)
+		} else {
+			anonymizedData["card_fingerprint_customers"] = customers
+		}
+	}
+
+	userMessageData, err := json.Marshal(anonymizedData)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal transaction data: %w", err)
+	}
+
+	assessment, err := s.callOpenAI(ctx, string(userMessageData))
+	if err != nil {
+		// If OpenAI fails, use fallback logic
+		log.Printf("OpenAI API failed, using fallback logic: %v", err)
+		return models.FraudDecisionSourceFallback, s.fallbackFraudDetection(request), nil
+	}
+	return models.FraudDecisionSourceAI, assessment, nil
+}
+
 func (s *fraudService) getCachedResult(ctx context.Context, cacheKey string) (*models.FraudAnalysisResult, bool) {
 	if s.redis != nil {
 		raw, err := s.redis.Get(ctx, fraudCachePrefix+cacheKey)
@@ -176,8 +335,16 @@ func (s *fraudService) setCachedResult(ctx context.Context, cacheKey string, res
 }
 
 func (s *fraudService) callOpenAI(ctx context.Context, transactionData string) (*models.OpenAIFraudAssessment, error) {
+	endpoint, err := s.openAI.chatCompletionsURL()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.openAI.requestTimeout(ctx))
+	defer cancel()
+
 	requestBody := OpenAIRequest{
-		Model: "gpt-4o",
+		Model: s.openAI.Model,
 		Messages: []Message{
 			{
 				Role:    "system",
@@ -195,13 +362,17 @@ func (s *fraudService) callOpenAI(ctx context.Context, transactionData string) (
 		return nil, fmt.Errorf("failed to marshal OpenAI request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", openAIAPIURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OpenAI request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.openAIKey)
+	if s.openAI.APIType == OpenAIAPITypeAzure {
+		req.Header.Set("api-key", s.openAI.APIKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+s.openAI.APIKey)
+	}
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
@@ -287,13 +458,6 @@ func categorizeAmount(amount float64) string {
 	return "very_high"
 }
 
-func categorizeIPAddress(ip string) string {
-	if ip == "" {
-		return "unknown"
-	}
-	return "normal"
-}
-
 func joinReasons(reasons []string) string {
 	if len(reasons) == 0 {
 		return ""
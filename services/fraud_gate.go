@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/internal/metrics"
+	"github.com/malwarebo/conductor/models"
+)
+
+// SetFraudFailOpen decides what the charge path does when the fraud service
+// errors: with failOpen the charge proceeds unscreened, otherwise it fails
+// with ErrFraudCheckUnavailable.
+func (s *PaymentService) SetFraudFailOpen(failOpen bool) {
+	s.fraudFailOpen = failOpen
+}
+
+// fraudGateEnabled reports whether the charge must pass a fraud check before
+// it reaches the provider: the request asks for one, or the tenant's
+// fraud_check setting requires one for every charge.
+func (s *PaymentService) fraudGateEnabled(ctx context.Context, req *models.ChargeRequest) bool {
+	if s.fraudService == nil {
+		return false
+	}
+	if req.FraudCheck != nil && *req.FraudCheck {
+		return true
+	}
+	tenant, ok := ctx.Value(ctxkeys.Tenant).(*models.Tenant)
+	if !ok || tenant == nil {
+		return false
+	}
+	enabled, _ := tenant.Settings[models.TenantSettingFraudCheck].(bool)
+	return enabled
+}
+
+// fraudGate analyzes the charge synchronously and blocks it with
+// ErrFraudBlocked when the fraud service disallows it. The fraud service
+// stores each analysis; the gate's own decision is counted and logged.
+func (s *PaymentService) fraudGate(ctx context.Context, req *models.ChargeRequest) error {
+	if !s.fraudGateEnabled(ctx, req) {
+		return nil
+	}
+
+	result, err := s.runFraudCheck(ctx, req)
+	if err != nil {
+		if s.fraudFailOpen {
+			metrics.FraudChecksTotal.Inc(metrics.FraudOutcomeFailOpen)
+			log.Printf("Fraud check failed for customer %s, allowing charge: %v", req.CustomerID, err)
+			return nil
+		}
+		metrics.FraudChecksTotal.Inc(metrics.FraudOutcomeFailClosed)
+		return fmt.Errorf("%w: %v", ErrFraudCheckUnavailable, err)
+	}
+
+	if !result.Allow {
+		metrics.FraudChecksTotal.Inc(metrics.FraudOutcomeBlocked)
+		log.Printf("Fraud check blocked charge for customer %s: %s", req.CustomerID, result.Reason)
+		return fmt.Errorf("%w: %s", ErrFraudBlocked, result.Reason)
+	}
+	metrics.FraudChecksTotal.Inc(metrics.FraudOutcomeAllowed)
+	return nil
+}
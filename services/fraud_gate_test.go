@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/internal/metrics"
+	"github.com/malwarebo/conductor/models"
+)
+
+// scriptedFraudService answers every analysis with the same decision, or
+// with err when set.
+type scriptedFraudService struct {
+	FraudService
+	response *models.FraudAnalysisResponse
+	err      error
+	calls    int
+}
+
+func (f *scriptedFraudService) AnalyzeTransaction(context.Context, *models.FraudAnalysisRequest) (*models.FraudAnalysisResponse, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.response, nil
+}
+
+func fraudCheckTenantContext() context.Context {
+	tenant := &models.Tenant{ID: "ten_1", Settings: models.JSON{models.TenantSettingFraudCheck: true}}
+	ctx := context.WithValue(context.Background(), ctxkeys.Tenant, tenant)
+	return context.WithValue(ctx, ctxkeys.TenantID, tenant.ID)
+}
+
+func fraudGateCharge() *models.ChargeRequest {
+	return &models.ChargeRequest{CustomerID: "cus_1", Amount: 1000, Currency: "USD", PaymentMethod: "pm_card_visa"}
+}
+
+func TestFraudGateBlocksDisallowedCharge(t *testing.T) {
+	provider := &fakeChargeProvider{}
+	store := &fakeChargeStore{}
+	fraud := &scriptedFraudService{response: &models.FraudAnalysisResponse{Allow: false, Reason: "velocity rule"}}
+	svc := CreatePaymentServiceFull(store, nil, nil, provider, fraud)
+	blocked := metrics.FraudChecksTotal.Value(metrics.FraudOutcomeBlocked)
+
+	_, err := svc.CreateCharge(fraudCheckTenantContext(), fraudGateCharge())
+	if !errors.Is(err, ErrFraudBlocked) {
+		t.Fatalf("expected ErrFraudBlocked, got %v", err)
+	}
+	if fraud.calls != 1 {
+		t.Fatalf("expected the tenant setting to run one fraud check, got %d", fraud.calls)
+	}
+	if provider.charges.Load() != 0 || len(store.payments) != 0 {
+		t.Fatal("expected a blocked charge never to reach the provider")
+	}
+	if got := metrics.FraudChecksTotal.Value(metrics.FraudOutcomeBlocked); got != blocked+1 {
+		t.Fatalf("expected the block to be recorded, counter went from %v to %v", blocked, got)
+	}
+}
+
+func TestFraudGateFailsOpenWhenConfigured(t *testing.T) {
+	provider := &fakeChargeProvider{}
+	fraud := &scriptedFraudService{err: errors.New("openai: timeout")}
+	svc := CreatePaymentServiceFull(&fakeChargeStore{}, nil, nil, provider, fraud)
+	svc.SetFraudFailOpen(true)
+	failOpen := metrics.FraudChecksTotal.Value(metrics.FraudOutcomeFailOpen)
+
+	resp, err := svc.CreateCharge(fraudCheckTenantContext(), fraudGateCharge())
+	if err != nil {
+		t.Fatalf("expected the charge to proceed when the fraud check fails open, got %v", err)
+	}
+	if resp.Status != models.PaymentStatusSuccess || provider.charges.Load() != 1 {
+		t.Fatalf("expected the provider to be charged, got status %s after %d charges", resp.Status, provider.charges.Load())
+	}
+	if got := metrics.FraudChecksTotal.Value(metrics.FraudOutcomeFailOpen); got != failOpen+1 {
+		t.Fatalf("expected the fail-open decision to be recorded, counter went from %v to %v", failOpen, got)
+	}
+}
+
+func TestFraudGateFailsClosedByDefault(t *testing.T) {
+	provider := &fakeChargeProvider{}
+	fraud := &scriptedFraudService{err: errors.New("openai: timeout")}
+	svc := CreatePaymentServiceFull(&fakeChargeStore{}, nil, nil, provider, fraud)
+
+	req := fraudGateCharge()
+	req.FraudCheck = boolPtr(true)
+	_, err := svc.CreateCharge(context.Background(), req)
+	if !errors.Is(err, ErrFraudCheckUnavailable) {
+		t.Fatalf("expected ErrFraudCheckUnavailable, got %v", err)
+	}
+	if provider.charges.Load() != 0 {
+		t.Fatal("expected no charge when the fraud check fails closed")
+	}
+}
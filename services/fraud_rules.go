@@ -0,0 +1,79 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+// FraudRulesEngine evaluates deterministic block rules. It runs before the AI
+// assessment; a match blocks without calling the model.
+type FraudRulesEngine struct {
+	rules []models.FraudRule
+}
+
+func CreateFraudRulesEngine(rules []models.FraudRule) *FraudRulesEngine {
+	return &FraudRulesEngine{rules: rules}
+}
+
+// DefaultFraudRules is a starter rule set. It is not applied unless the
+// deployment opts in with fraud.use_default_rules.
+func DefaultFraudRules() []models.FraudRule {
+	return []models.FraudRule{
+		{Name: "cross_border_high_value", CountryMismatch: true, MinAmount: 5000},
+		{Name: "velocity_limit", MinVelocity: 20},
+	}
+}
+
+// Evaluate returns the first rule matching the request, or nil.
+func (e *FraudRulesEngine) Evaluate(request *models.FraudAnalysisRequest) *models.FraudRule {
+	if e == nil {
+		return nil
+	}
+	for i := range e.rules {
+		if ruleMatches(&e.rules[i], request) {
+			return &e.rules[i]
+		}
+	}
+	return nil
+}
+
+func ruleMatches(rule *models.FraudRule, request *models.FraudAnalysisRequest) bool {
+	conditions := 0
+
+	if rule.MinAmount > 0 {
+		conditions++
+		if request.TransactionAmount < rule.MinAmount {
+			return false
+		}
+	}
+	if rule.MinVelocity > 0 {
+		conditions++
+		if request.TransactionVelocity < rule.MinVelocity {
+			return false
+		}
+	}
+	if rule.CountryMismatch {
+		conditions++
+		if strings.EqualFold(request.BillingCountry, request.ShippingCountry) {
+			return false
+		}
+	}
+	if len(rule.BlockedCountries) > 0 {
+		conditions++
+		if !countryIn(rule.BlockedCountries, request.BillingCountry) && !countryIn(rule.BlockedCountries, request.ShippingCountry) {
+			return false
+		}
+	}
+
+	return conditions > 0
+}
+
+func countryIn(countries []string, country string) bool {
+	for _, c := range countries {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
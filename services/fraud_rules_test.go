@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+func TestFraudRuleBlocksWithoutCallingAI(t *testing.T) {
+	aiCalls := 0
+	server := openAIStub(t, models.OpenAIFraudAssessment{IsFraudulent: false, FraudScore: 5, Reason: "looks fine"},
+		func(*http.Request, OpenAIRequest) { aiCalls++ })
+	repo := &fakeFraudRepository{}
+
+	svc := CreateFraudServiceWithRules(repo, OpenAIConfig{BaseURL: server.URL},
+		nil, CreateFraudRulesEngine([]models.FraudRule{{Name: "velocity_limit", MinVelocity: 5}}), nil)
+
+	resp, err := svc.AnalyzeTransaction(context.Background(), &models.FraudAnalysisRequest{
+		TransactionID:       "txn_1",
+		UserID:              "user_1",
+		TransactionAmount:   20,
+		BillingCountry:      "US",
+		ShippingCountry:     "US",
+		TransactionVelocity: 8,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.Allow {
+		t.Fatal("expected velocity rule to block the transaction")
+	}
+	if aiCalls != 0 {
+		t.Fatalf("expected a rule hit to skip the AI call, got %d calls", aiCalls)
+	}
+	if resp.RuleTriggered != "velocity_limit" {
+		t.Fatalf("expected velocity_limit to be recorded, got %q", resp.RuleTriggered)
+	}
+	if len(repo.results) != 1 || repo.results[0].RuleTriggered != "velocity_limit" || repo.results[0].DecisionSource != models.FraudDecisionSourceRule {
+		t.Fatalf("expected stored result to record the rule decision, got %+v", repo.results)
+	}
+}
+
+func TestFraudServiceAppliesNoRulesByDefault(t *testing.T) {
+	server := openAIStub(t, models.OpenAIFraudAssessment{IsFraudulent: false, FraudScore: 5, Reason: "looks fine"}, nil)
+
+	svc := CreateFraudService(&fakeFraudRepository{}, OpenAIConfig{BaseURL: server.URL})
+	resp, err := svc.AnalyzeTransaction(context.Background(), &models.FraudAnalysisRequest{
+		TransactionID:       "txn_2",
+		UserID:              "user_2",
+		TransactionAmount:   9000,
+		BillingCountry:      "US",
+		ShippingCountry:     "GB",
+		TransactionVelocity: 50,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Allow || resp.RuleTriggered != "" {
+		t.Fatalf("expected default rules to stay off unless opted in, got %+v", resp)
+	}
+}
+
+func TestFraudRulesEngineRequiresAllConditions(t *testing.T) {
+	engine := CreateFraudRulesEngine([]models.FraudRule{
+		{Name: "empty"},
+		{Name: "cross_border_high_value", CountryMismatch: true, MinAmount: 1000},
+		{Name: "sanctioned", BlockedCountries: []string{"KP"}},
+	})
+
+	cases := []struct {
+		request models.FraudAnalysisRequest
+		want    string
+	}{
+		{models.FraudAnalysisRequest{TransactionAmount: 5000, BillingCountry: "US", ShippingCountry: "US"}, ""},
+		{models.FraudAnalysisRequest{TransactionAmount: 500, BillingCountry: "US", ShippingCountry: "GB"}, ""},
+		{models.FraudAnalysisRequest{TransactionAmount: 5000, BillingCountry: "US", ShippingCountry: "GB"}, "cross_border_high_value"},
+		{models.FraudAnalysisRequest{TransactionAmount: 10, BillingCountry: "US", ShippingCountry: "kp"}, "sanctioned"},
+	}
+
+	for _, tc := range cases {
+		got := ""
+		if rule := engine.Evaluate(&tc.request); rule != nil {
+			got = rule.Name
+		}
+		if got != tc.want {
+			t.Fatalf("request %+v: expected rule %q, got %q", tc.request, tc.want, got)
+		}
+	}
+}
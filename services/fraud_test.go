@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/utils"
+)
+
+type fakeFraudRepository struct {
+	results []*models.FraudAnalysisResult
+	saveErr error
+}
+
+func (f *fakeFraudRepository) SaveAnalysisResult(result *models.FraudAnalysisResult) error {
+	if f.saveErr != nil {
+		return f.saveErr
+	}
+	f.results = append(f.results, result)
+	return nil
+}
+
+func (f *fakeFraudRepository) GetStatsByDateRange(time.Time, time.Time) (*models.FraudStatsResponse, error) {
+	return &models.FraudStatsResponse{}, nil
+}
+
+func openAIStub(t *testing.T, assessment models.OpenAIFraudAssessment, inspect func(*http.Request, OpenAIRequest)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request body: %v", err)
+		}
+		if inspect != nil {
+			inspect(r, body)
+		}
+
+		content, _ := json.Marshal(assessment)
+		_ = json.NewEncoder(w).Encode(OpenAIResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: string(content)}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFraudServiceSendsConfiguredModelAndEndpoint(t *testing.T) {
+	var gotModel, gotPath, gotAuth string
+	server := openAIStub(t, models.OpenAIFraudAssessment{IsFraudulent: true, FraudScore: 95, Reason: "stolen card"},
+		func(r *http.Request, body OpenAIRequest) {
+			gotModel = body.Model
+			gotPath = r.URL.Path
+			gotAuth = r.Header.Get("Authorization")
+		})
+
+	svc := CreateFraudService(&fakeFraudRepository{}, OpenAIConfig{
+		APIKey:  "sk-test",
+		Model:   "gpt-4o-mini",
+		BaseURL: server.URL + "/openai/v1/",
+	})
+
+	resp, err := svc.AnalyzeTransaction(context.Background(), &models.FraudAnalysisRequest{
+		TransactionID: "txn_1",
+		UserID:        "user_1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotModel != "gpt-4o-mini" {
+		t.Fatalf("expected configured model gpt-4o-mini, got %q", gotModel)
+	}
+	if gotPath != "/openai/v1/chat/completions" {
+		t.Fatalf("expected chat completions under base URL, got %q", gotPath)
+	}
+	if gotAuth != "Bearer sk-test" {
+		t.Fatalf("unexpected authorization header %q", gotAuth)
+	}
+	if resp.Allow || resp.Reason != "stolen card" {
+		t.Fatalf("expected AI assessment to be used, got %+v", resp)
+	}
+}
+
+func TestFraudServiceFallsBackWhenRequestTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := openAIStub(t, models.OpenAIFraudAssessment{}, func(*http.Request, OpenAIRequest) {
+		<-release
+	})
+	defer close(release)
+
+	svc := CreateFraudService(&fakeFraudRepository{}, OpenAIConfig{
+		BaseURL:        server.URL,
+		RequestTimeout: 20 * time.Millisecond,
+	})
+
+	start := time.Now()
+	resp, err := svc.AnalyzeTransaction(context.Background(), &models.FraudAnalysisRequest{
+		TransactionID:       "txn_2",
+		UserID:              "user_2",
+		TransactionAmount:   6000,
+		TransactionVelocity: 10,
+		BillingCountry:      "US",
+		ShippingCountry:     "US",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request timeout was not applied, took %s", elapsed)
+	}
+	if resp.Allow {
+		t.Fatalf("expected fallback rules to block, got %+v", resp)
+	}
+}
+
+func TestFraudServiceUsesAzureAuthAndAPIVersion(t *testing.T) {
+	var gotPath, gotVersion, gotKey, gotAuth string
+	server := openAIStub(t, models.OpenAIFraudAssessment{FraudScore: 5, Reason: "ok"},
+		func(r *http.Request, _ OpenAIRequest) {
+			gotPath = r.URL.Path
+			gotVersion = r.URL.Query().Get("api-version")
+			gotKey = r.Header.Get("api-key")
+			gotAuth = r.Header.Get("Authorization")
+		})
+
+	svc := CreateFraudService(&fakeFraudRepository{}, OpenAIConfig{
+		APIKey:  "azure-key",
+		APIType: OpenAIAPITypeAzure,
+		BaseURL: server.URL + "/openai/deployments/fraud",
+	})
+	if _, err := svc.AnalyzeTransaction(context.Background(), &models.FraudAnalysisRequest{TransactionID: "txn_az", UserID: "user_az"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotPath != "/openai/deployments/fraud/chat/completions" || gotVersion != DefaultAzureOpenAIVersion {
+		t.Fatalf("unexpected Azure request path=%q api-version=%q", gotPath, gotVersion)
+	}
+	if gotKey != "azure-key" || gotAuth != "" {
+		t.Fatalf("expected api-key header only, got api-key=%q authorization=%q", gotKey, gotAuth)
+	}
+}
+
+func TestFraudServiceHonorsPerRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := openAIStub(t, models.OpenAIFraudAssessment{}, func(*http.Request, OpenAIRequest) {
+		<-release
+	})
+	defer close(release)
+
+	svc := CreateFraudService(&fakeFraudRepository{}, OpenAIConfig{BaseURL: server.URL, RequestTimeout: time.Minute})
+
+	start := time.Now()
+	ctx := WithOpenAITimeout(context.Background(), 20*time.Millisecond)
+	if _, err := svc.AnalyzeTransaction(ctx, &models.FraudAnalysisRequest{TransactionID: "txn_t", UserID: "user_t"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("per-request timeout was not applied, took %s", elapsed)
+	}
+}
+
+func TestOpenAIConfigDefaults(t *testing.T) {
+	cfg := OpenAIConfig{}.withDefaults()
+	endpoint, err := cfg.chatCompletionsURL()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Model != DefaultOpenAIModel || endpoint != "https://api.openai.com/v1/chat/completions" {
+		t.Fatalf("unexpected defaults: model=%s endpoint=%s", cfg.Model, endpoint)
+	}
+}
+
+func TestFraudServicePersistsDecisionSource(t *testing.T) {
+	server := openAIStub(t, models.OpenAIFraudAssessment{IsFraudulent: false, FraudScore: 10, Reason: "looks fine"}, nil)
+	repo := &fakeFraudRepository{}
+	svc := CreateFraudServiceWithRules(repo, OpenAIConfig{BaseURL: server.URL}, nil, CreateFraudRulesEngine(DefaultFraudRules()), nil)
+
+	requests := []*models.FraudAnalysisRequest{
+		{TransactionID: "txn_ai", UserID: "user_1", TransactionAmount: 20, IPAddress: "203.0.113.7"},
+		{TransactionID: "txn_rule", UserID: "user_1", TransactionAmount: 20, TransactionVelocity: 50},
+	}
+	for _, request := range requests {
+		if _, err := svc.AnalyzeTransaction(context.Background(), request); err != nil {
+			t.Fatalf("analyze %s: %v", request.TransactionID, err)
+		}
+	}
+
+	server.Close()
+	if _, err := svc.AnalyzeTransaction(context.Background(), &models.FraudAnalysisRequest{
+		TransactionID: "txn_fallback", UserID: "user_1", TransactionAmount: 20,
+	}); err != nil {
+		t.Fatalf("analyze txn_fallback: %v", err)
+	}
+
+	want := []struct {
+		source models.FraudDecisionSource
+		ipRisk string
+	}{
+		{models.FraudDecisionSourceAI, "normal"},
+		{models.FraudDecisionSourceRule, "unknown"},
+		{models.FraudDecisionSourceFallback, "unknown"},
+	}
+	if len(repo.results) != len(want) {
+		t.Fatalf("expected %d stored results, got %d", len(want), len(repo.results))
+	}
+	for i, w := range want {
+		got := repo.results[i]
+		if got.DecisionSource != w.source || got.IPRisk != w.ipRisk {
+			t.Fatalf("result %s: expected source=%s ip_risk=%s, got source=%s ip_risk=%s",
+				got.TransactionID, w.source, w.ipRisk, got.DecisionSource, got.IPRisk)
+		}
+	}
+}
+
+func TestFraudServiceSendsIPEnrichment(t *testing.T) {
+	var prompt map[string]interface{}
+	server := openAIStub(t, models.OpenAIFraudAssessment{FraudScore: 30, Reason: "hosting IP"},
+		func(_ *http.Request, body OpenAIRequest) {
+			if err := json.Unmarshal([]byte(body.Messages[len(body.Messages)-1].Content), &prompt); err != nil {
+				t.Errorf("decode prompt: %v", err)
+			}
+		})
+	analyzer, err := utils.LoadIPAnalyzer(filepath.Join("..", "utils", "testdata", "geoip.mmdb"))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	repo := &fakeFraudRepository{}
+	svc := CreateFraudServiceWithRules(repo, OpenAIConfig{BaseURL: server.URL}, nil, nil, analyzer)
+
+	if _, err := svc.AnalyzeTransaction(context.Background(), &models.FraudAnalysisRequest{
+		TransactionID:  "txn_geo",
+		UserID:         "user_1",
+		IPAddress:      "203.0.113.9",
+		BillingCountry: "US",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if prompt["ip_country"] != "SG" || prompt["ip_is_datacenter"] != true || prompt["ip_asn"] != float64(64500) {
+		t.Fatalf("expected GeoIP enrichment in prompt, got %v", prompt)
+	}
+	if prompt["ip_country_matches_billing"] != false || prompt["ip_category"] != utils.IPRiskDatacenter {
+		t.Fatalf("unexpected IP fields in prompt: %v", prompt)
+	}
+	if _, leaked := prompt["ip_address"]; leaked {
+		t.Fatal("raw IP address must not be sent to OpenAI")
+	}
+	if repo.results[0].IPRisk != utils.IPRiskDatacenter {
+		t.Fatalf("expected stored IP risk %q, got %q", utils.IPRiskDatacenter, repo.results[0].IPRisk)
+	}
+}
+
+func TestFraudServicePersistsCachedDecisions(t *testing.T) {
+	server := openAIStub(t, models.OpenAIFraudAssessment{FraudScore: 10, Reason: "looks fine"}, nil)
+	repo := &fakeFraudRepository{}
+	svc := CreateFraudService(repo, OpenAIConfig{BaseURL: server.URL})
+
+	request := &models.FraudAnalysisRequest{TransactionID: "txn_cached", UserID: "user_1", TransactionAmount: 20}
+	for i := 0; i < 2; i++ {
+		if _, err := svc.AnalyzeTransaction(context.Background(), request); err != nil {
+			t.Fatalf("analyze %d: %v", i, err)
+		}
+	}
+
+	if len(repo.results) != 2 {
+		t.Fatalf("expected the cached decision to be stored too, got %d rows", len(repo.results))
+	}
+	if repo.results[0] == repo.results[1] || repo.results[1].DecisionSource != models.FraudDecisionSourceAI {
+		t.Fatalf("expected a separate row carrying the original decision, got %+v", repo.results[1])
+	}
+}
+
+func TestFraudServiceReportsSaveFailures(t *testing.T) {
+	server := openAIStub(t, models.OpenAIFraudAssessment{FraudScore: 10, Reason: "looks fine"}, nil)
+	repo := &fakeFraudRepository{saveErr: errors.New("connection refused")}
+	svc := CreateFraudService(repo, OpenAIConfig{BaseURL: server.URL})
+
+	_, err := svc.AnalyzeTransaction(context.Background(), &models.FraudAnalysisRequest{TransactionID: "txn_unsaved", UserID: "user_1"})
+	if !errors.Is(err, ErrFraudResultNotSaved) {
+		t.Fatalf("expected ErrFraudResultNotSaved, got %v", err)
+	}
+}
+
+type fakeFingerprintCounter map[string]int64
+
+func (f fakeFingerprintCounter) CountByFingerprint(_ context.Context, fingerprint string) (int64, error) {
+	return f[fingerprint], nil
+}
+
+func TestFraudServiceSendsCardFingerprintReuse(t *testing.T) {
+	var prompt map[string]interface{}
+	server := openAIStub(t, models.OpenAIFraudAssessment{FraudScore: 80, Reason: "card shared across accounts"},
+		func(_ *http.Request, body OpenAIRequest) {
+			if err := json.Unmarshal([]byte(body.Messages[len(body.Messages)-1].Content), &prompt); err != nil {
+				t.Errorf("decode prompt: %v", err)
+			}
+		})
+	svc := CreateFraudService(&fakeFraudRepository{}, OpenAIConfig{BaseURL: server.URL})
+	svc.SetFingerprintCounter(fakeFingerprintCounter{"fp_shared": 7})
+
+	if _, err := svc.AnalyzeTransaction(context.Background(), &models.FraudAnalysisRequest{
+		TransactionID:   "txn_fp",
+		UserID:          "user_1",
+		CardFingerprint: "fp_shared",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if prompt["card_fingerprint_customers"] != float64(7) {
+		t.Fatalf("expected the card reuse count in the prompt, got %v", prompt)
+	}
+	for key, value := range prompt {
+		if value == "fp_shared" {
+			t.Fatalf("raw fingerprint must not be sent to OpenAI, found under %q", key)
+		}
+	}
+}
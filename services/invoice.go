@@ -2,11 +2,18 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/providers"
 )
 
+var (
+	ErrInvalidLineItem       = errors.New("invalid invoice line item")
+	ErrLineItemTotalMismatch = errors.New("line item total does not match invoice amount")
+)
+
 type InvoiceService struct {
 	provider providers.PaymentProvider
 }
@@ -18,29 +25,103 @@ func CreateInvoiceService(provider providers.PaymentProvider) *InvoiceService {
 }
 
 func (s *InvoiceService) CreateInvoice(ctx context.Context, req *models.CreateInvoiceRequest) (*models.Invoice, error) {
-	if invProvider, ok := s.provider.(providers.InvoiceProvider); ok {
+	if err := applyLineItemTotal(req); err != nil {
+		return nil, err
+	}
+	if err := requireCapabilityForCurrency(ctx, s.provider, req.Currency, providers.CapabilityInvoices); err != nil {
+		return nil, err
+	}
+
+	invProvider, ok := s.provider.(providers.InvoiceProvider)
+	if !ok {
+		return nil, providers.ErrNotSupported
+	}
+	var tax *models.TaxCalculation
+	if req.AutomaticTax {
+		calc, err := s.applyInvoiceTax(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		tax = calc
+	}
+	inv, err := callProvider(ctx, "create_invoice", func(ctx context.Context) (*models.Invoice, error) {
 		return invProvider.CreateInvoice(ctx, req)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil, providers.ErrNotSupported
+	if len(inv.LineItems) == 0 && len(req.LineItems) > 0 {
+		inv.LineItems = req.LineItems
+	}
+	if tax != nil {
+		inv.TaxAmount = tax.TaxAmount
+		inv.TaxBreakdown = tax.Breakdown
+	}
+	return inv, nil
+}
+
+// applyLineItemTotal validates the request's line items and fills in the
+// invoice amount from them. A caller that also sends an amount must send the
+// same total, so a stale client-side sum can't silently bill the wrong figure.
+func applyLineItemTotal(req *models.CreateInvoiceRequest) error {
+	if len(req.LineItems) == 0 {
+		return nil
+	}
+	for i, item := range req.LineItems {
+		if item.Name == "" {
+			return fmt.Errorf("%w: line %d needs a name", ErrInvalidLineItem, i+1)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("%w: line %d quantity must be positive", ErrInvalidLineItem, i+1)
+		}
+		if item.UnitAmount <= 0 {
+			return fmt.Errorf("%w: line %d unit amount must be positive", ErrInvalidLineItem, i+1)
+		}
+	}
+
+	total := models.InvoiceLineItems(req.LineItems).Total()
+	if req.Amount != 0 && req.Amount != total {
+		return fmt.Errorf("%w: amount is %d but line items sum to %d", ErrLineItemTotalMismatch, req.Amount, total)
+	}
+	req.Amount = total
+	return nil
 }
 
 func (s *InvoiceService) GetInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error) {
+	if err := requireCapabilityForEntity(ctx, s.provider, invoiceID, "invoice", providers.CapabilityInvoices); err != nil {
+		return nil, err
+	}
+
 	if invProvider, ok := s.provider.(providers.InvoiceProvider); ok {
-		return invProvider.GetInvoice(ctx, invoiceID)
+		return callProvider(ctx, "get_invoice", func(ctx context.Context) (*models.Invoice, error) {
+			return invProvider.GetInvoice(ctx, invoiceID)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
 
 func (s *InvoiceService) ListInvoices(ctx context.Context, req *models.ListInvoicesRequest) ([]*models.Invoice, error) {
+	if err := requireCapability(s.provider, providers.CapabilityInvoices); err != nil {
+		return nil, err
+	}
+
 	if invProvider, ok := s.provider.(providers.InvoiceProvider); ok {
-		return invProvider.ListInvoices(ctx, req)
+		return callProvider(ctx, "list_invoices", func(ctx context.Context) ([]*models.Invoice, error) {
+			return invProvider.ListInvoices(ctx, req)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
 
 func (s *InvoiceService) CancelInvoice(ctx context.Context, invoiceID string) (*models.Invoice, error) {
+	if err := requireCapabilityForEntity(ctx, s.provider, invoiceID, "invoice", providers.CapabilityInvoices); err != nil {
+		return nil, err
+	}
+
 	if invProvider, ok := s.provider.(providers.InvoiceProvider); ok {
-		return invProvider.CancelInvoice(ctx, invoiceID)
+		return callProvider(ctx, "cancel_invoice", func(ctx context.Context) (*models.Invoice, error) {
+			return invProvider.CancelInvoice(ctx, invoiceID)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
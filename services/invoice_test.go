@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type fakeInvoiceProvider struct {
+	providers.PaymentProvider
+	created *models.CreateInvoiceRequest
+}
+
+func (f *fakeInvoiceProvider) Name() string                     { return "razorpay" }
+func (f *fakeInvoiceProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeInvoiceProvider) Capabilities() providers.ProviderCapabilities {
+	return (&providers.RazorpayProvider{}).Capabilities()
+}
+
+func (f *fakeInvoiceProvider) CreateInvoice(_ context.Context, req *models.CreateInvoiceRequest) (*models.Invoice, error) {
+	f.created = req
+	return &models.Invoice{ProviderID: "inv_1", Amount: req.Amount, Currency: req.Currency}, nil
+}
+
+func (f *fakeInvoiceProvider) GetInvoice(context.Context, string) (*models.Invoice, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakeInvoiceProvider) ListInvoices(context.Context, *models.ListInvoicesRequest) ([]*models.Invoice, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakeInvoiceProvider) CancelInvoice(context.Context, string) (*models.Invoice, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func multiLineInvoiceRequest(amount int64) *models.CreateInvoiceRequest {
+	return &models.CreateInvoiceRequest{
+		CustomerID: "cust_1",
+		Currency:   "INR",
+		Amount:     amount,
+		LineItems: []models.InvoiceLineItem{
+			{Name: "Seat licence", Quantity: 3, UnitAmount: 1500},
+			{Name: "Onboarding", Description: "One-off setup", Quantity: 1, UnitAmount: 2500},
+		},
+	}
+}
+
+func TestCreateInvoiceTotalsLineItems(t *testing.T) {
+	provider := &fakeInvoiceProvider{}
+	svc := CreateInvoiceService(provider)
+
+	inv, err := svc.CreateInvoice(context.Background(), multiLineInvoiceRequest(0))
+	if err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+	if provider.created.Amount != 7000 {
+		t.Fatalf("expected provider to be asked for 7000, got %d", provider.created.Amount)
+	}
+	if inv.Amount != 7000 {
+		t.Fatalf("expected invoice amount 7000, got %d", inv.Amount)
+	}
+	if len(inv.LineItems) != 2 || inv.LineItems[1].Name != "Onboarding" {
+		t.Fatalf("expected both line items on the invoice, got %+v", inv.LineItems)
+	}
+}
+
+func TestCreateInvoiceAcceptsMatchingAmount(t *testing.T) {
+	svc := CreateInvoiceService(&fakeInvoiceProvider{})
+
+	if _, err := svc.CreateInvoice(context.Background(), multiLineInvoiceRequest(7000)); err != nil {
+		t.Fatalf("expected matching amount to be accepted, got %v", err)
+	}
+}
+
+func TestCreateInvoiceRejectsMismatchedAmount(t *testing.T) {
+	provider := &fakeInvoiceProvider{}
+	svc := CreateInvoiceService(provider)
+
+	_, err := svc.CreateInvoice(context.Background(), multiLineInvoiceRequest(6000))
+	if !errors.Is(err, ErrLineItemTotalMismatch) {
+		t.Fatalf("expected ErrLineItemTotalMismatch, got %v", err)
+	}
+	if provider.created != nil {
+		t.Fatal("provider must not be called when the total does not match")
+	}
+}
+
+func TestCreateInvoiceRejectsInvalidLineItem(t *testing.T) {
+	req := multiLineInvoiceRequest(0)
+	req.LineItems[0].Quantity = 0
+
+	_, err := CreateInvoiceService(&fakeInvoiceProvider{}).CreateInvoice(context.Background(), req)
+	if !errors.Is(err, ErrInvalidLineItem) {
+		t.Fatalf("expected ErrInvalidLineItem, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+const PaymentEventSucceeded = "payment.succeeded"
+
+// newOutboundEvent builds the envelope sent to a tenant. subject is the
+// internal model the event is about and becomes the typed Object; data is
+// the legacy untyped payload and is passed through unchanged.
+func newOutboundEvent(tenantID, eventType string, subject interface{}, data map[string]interface{}) *models.OutboundEvent {
+	return &models.OutboundEvent{
+		ID:            generateID(),
+		TenantID:      tenantID,
+		EventType:     eventType,
+		SchemaVersion: models.OutboundEventSchemaVersion,
+		Object:        outboundObject(subject),
+		Data:          data,
+		Timestamp:     time.Now(),
+	}
+}
+
+// outboundObject maps an internal model to its outbound schema. Subjects
+// without a schema yield no Object, leaving only Data.
+func outboundObject(subject interface{}) interface{} {
+	switch v := subject.(type) {
+	case *models.Payment:
+		return &models.PaymentEventObject{
+			ID:               v.ID,
+			CustomerID:       v.CustomerID,
+			Amount:           v.Amount,
+			CapturedAmount:   v.CapturedAmount,
+			Currency:         v.Currency,
+			Status:           v.Status,
+			CaptureMethod:    v.CaptureMethod,
+			Description:      v.Description,
+			Provider:         v.ProviderName,
+			ProviderChargeID: v.ProviderChargeID,
+			CreatedAt:        v.CreatedAt,
+			UpdatedAt:        v.UpdatedAt,
+		}
+	case *models.Dispute:
+		return &models.DisputeEventObject{
+			ID:                  v.ID,
+			CustomerID:          v.CustomerID,
+			TransactionID:       v.TransactionID,
+			Amount:              v.Amount,
+			Currency:            v.Currency,
+			Reason:              v.Reason,
+			Status:              v.Status,
+			DueBy:               v.DueBy,
+			EvidenceSubmittedAt: v.EvidenceSubmittedAt,
+			ClosedAt:            v.ClosedAt,
+		}
+	case *models.Subscription:
+		return &models.SubscriptionEventObject{
+			ID:                 v.ID,
+			CustomerID:         v.CustomerID,
+			PlanID:             v.PlanID,
+			Status:             v.Status,
+			CurrentPeriodStart: v.CurrentPeriodStart,
+			CurrentPeriodEnd:   v.CurrentPeriodEnd,
+			LatestInvoiceID:    v.LatestInvoiceID,
+			DunningAttempts:    v.DunningAttempts,
+			NextDunningAt:      v.NextDunningAt,
+			CanceledAt:         v.CanceledAt,
+		}
+	default:
+		return nil
+	}
+}
+
+// notifyPayment tells the payment's tenant about a status change driven by
+// a provider webhook. Delivery failures are not retried here and do not fail
+// the inbound event.
+func (s *WebhookService) notifyPayment(ctx context.Context, payment *models.Payment, eventType string) {
+	if s.tenantStore == nil || payment.TenantID == nil {
+		return
+	}
+
+	_ = s.SendOutboundWebhook(ctx, *payment.TenantID, eventType, payment, map[string]interface{}{
+		"payment_id":         payment.ID,
+		"customer_id":        payment.CustomerID,
+		"amount":             payment.Amount,
+		"captured_amount":    payment.CapturedAmount,
+		"currency":           payment.Currency,
+		"provider":           payment.ProviderName,
+		"provider_charge_id": payment.ProviderChargeID,
+		"status":             payment.Status,
+	})
+}
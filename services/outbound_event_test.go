@@ -0,0 +1,76 @@
+package services
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+func jsonKeys(t *testing.T, raw json.RawMessage) string {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		t.Fatalf("failed to decode %s: %v", raw, err)
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func TestPaymentSucceededEventMatchesSchema(t *testing.T) {
+	created := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	payment := &models.Payment{
+		ID:               "pay_1",
+		CustomerID:       "cus_1",
+		Amount:           1250,
+		CapturedAmount:   1250,
+		Currency:         "USD",
+		Status:           models.PaymentStatusSuccess,
+		CaptureMethod:    models.CaptureMethodAutomatic,
+		ProviderName:     "stripe",
+		ProviderChargeID: "pi_1",
+		PaymentMethod:    "pm_secret",
+		CreatedAt:        created,
+		UpdatedAt:        created,
+	}
+
+	event := newOutboundEvent("ten_1", PaymentEventSucceeded, payment, map[string]interface{}{"payment_id": "pay_1"})
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := jsonKeys(t, body), "data,event_type,id,object,schema_version,signature,tenant_id,timestamp"; got != want {
+		t.Fatalf("expected envelope fields %s, got %s", want, got)
+	}
+	if string(envelope["schema_version"]) != "1" || string(envelope["event_type"]) != `"payment.succeeded"` {
+		t.Fatalf("unexpected envelope: %s", body)
+	}
+	if string(envelope["data"]) != `{"payment_id":"pay_1"}` {
+		t.Fatalf("expected legacy data to be passed through, got %s", envelope["data"])
+	}
+
+	want := "amount,capture_method,captured_amount,created_at,currency,customer_id,id,provider,provider_charge_id,status,updated_at"
+	if got := jsonKeys(t, envelope["object"]); got != want {
+		t.Fatalf("expected payment object fields %s, got %s", want, got)
+	}
+
+	var object models.PaymentEventObject
+	if err := json.Unmarshal(envelope["object"], &object); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if object.ID != "pay_1" || object.Amount != 1250 || object.Status != models.PaymentStatusSuccess || object.Provider != "stripe" || !object.CreatedAt.Equal(created) {
+		t.Fatalf("unexpected payment object: %+v", object)
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/internal/pagination"
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/stores"
+	"gorm.io/gorm"
 )
 
 var (
@@ -18,30 +21,90 @@ var (
 	ErrPaymentNotFound        = errors.New("payment not found")
 	ErrInvalidCaptureAmount   = errors.New("capture amount exceeds authorized amount")
 	ErrPaymentNotCapturable   = errors.New("payment is not in capturable state")
+	ErrInvalidReversalAmount  = errors.New("reversal amount must be positive and less than the uncaptured amount")
 	ErrPaymentAlreadyCaptured = errors.New("payment already captured")
+	ErrPaymentTenantRequired  = errors.New("tenant is required to list payments")
+	ErrMetadataKeyRequired    = errors.New("metadata key is required")
 	ErrIdempotencyConflict    = errors.New("idempotency key conflict")
+	ErrConcurrentModification = stores.ErrConcurrentModification
+	ErrFraudBlocked           = errors.New("payment blocked by fraud screening")
+	ErrFraudCheckUnavailable  = errors.New("fraud check unavailable")
 )
 
+// maxStatementDescriptorLength is the longest descriptor any provider accepts;
+// provider-specific limits are enforced when the charge is sent.
+const maxStatementDescriptorLength = 32
+
+// DefaultIdempotencyTTL is how long an Idempotency-Key replays its first
+// response when no TTL is configured.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+type PaymentStore interface {
+	Create(ctx context.Context, payment *models.Payment) error
+	Update(ctx context.Context, payment *models.Payment) error
+	CompleteIntent(ctx context.Context, intentID string, payment *models.Payment) error
+	GetByID(ctx context.Context, id string) (*models.Payment, error)
+	ListByCustomer(ctx context.Context, customerID string) ([]*models.Payment, error)
+	ListByTenant(ctx context.Context, filter models.PaymentFilter) (pagination.Page[*models.Payment], error)
+	ListByMetadata(ctx context.Context, key, value string, filter models.PaymentFilter) (pagination.Page[*models.Payment], error)
+	CreateRefund(ctx context.Context, refund *models.Refund) error
+	GetRefundByID(ctx context.Context, id string) (*models.Refund, error)
+	ListRefundsByPayment(ctx context.Context, paymentID string) ([]*models.Refund, error)
+}
+
 type PaymentService struct {
-	paymentRepo      *stores.PaymentRepository
-	idempotencyStore *stores.IdempotencyStore
-	auditStore       *stores.AuditStore
-	provider         providers.PaymentProvider
-	executor         *providers.ProviderExecutor
-	fraudService     FraudService
+	paymentRepo       PaymentStore
+	idempotencyStore  IdempotencyRepository
+	auditStore        *stores.AuditStore
+	provider          providers.PaymentProvider
+	executor          *providers.ProviderExecutor
+	fraudService      FraudService
+	amountLimits      map[string]map[string]models.AmountLimit
+	currencyExponents map[string]int
+	providerResponses ProviderResponseStore
+	captureResponses  bool
+	defaultMethods    DefaultPaymentMethodResolver
+	customers         CustomerLookup
+	sessionMethods    SessionPaymentMethodStore
+	idempotencyTTL    time.Duration
+	scheduled         ScheduledPaymentRepository
+	fraudFailOpen     bool
+	notifier          OutboundNotifier
+	now               func() time.Time
+}
+
+// SessionPaymentMethodStore records payment methods saved by payment
+// sessions.
+type SessionPaymentMethodStore interface {
+	GetByProviderID(ctx context.Context, providerName, providerPaymentMethodID string) (*models.PaymentMethod, error)
+	Create(ctx context.Context, pm *models.PaymentMethod) error
+}
+
+// DefaultPaymentMethodResolver looks up a customer's default payment method
+// for charges that do not name one.
+type DefaultPaymentMethodResolver interface {
+	GetDefault(ctx context.Context, customerID string) (*models.PaymentMethod, error)
+}
+
+// CustomerLookup loads the customer a charge names, so its default payment
+// method is only used for a customer of the caller's tenant.
+type CustomerLookup interface {
+	GetByID(ctx context.Context, id string) (*models.Customer, error)
 }
 
-func CreatePaymentService(paymentRepo *stores.PaymentRepository, provider providers.PaymentProvider) *PaymentService {
+func CreatePaymentService(paymentRepo PaymentStore, provider providers.PaymentProvider) *PaymentService {
 	return &PaymentService{
-		paymentRepo: paymentRepo,
-		provider:    provider,
-		executor:    providers.CreateProviderExecutor(providers.DefaultProviderExecutorConfig()),
+		paymentRepo:  paymentRepo,
+		provider:     provider,
+		executor:     providers.CreateProviderExecutor(providers.DefaultProviderExecutorConfig()),
+		amountLimits: DefaultAmountLimits(),
+		now:          time.Now,
 	}
 }
 
 func CreatePaymentServiceFull(
-	paymentRepo *stores.PaymentRepository,
-	idempotencyStore *stores.IdempotencyStore,
+	paymentRepo PaymentStore,
+	idempotencyStore IdempotencyRepository,
 	auditStore *stores.AuditStore,
 	provider providers.PaymentProvider,
 	fraudService FraudService,
@@ -53,11 +116,44 @@ func CreatePaymentServiceFull(
 		provider:         provider,
 		executor:         providers.CreateProviderExecutor(providers.DefaultProviderExecutorConfig()),
 		fraudService:     fraudService,
+		amountLimits:     DefaultAmountLimits(),
+		now:              time.Now,
 	}
 }
 
+func (s *PaymentService) SetDefaultPaymentMethods(resolver DefaultPaymentMethodResolver, customers CustomerLookup) {
+	s.defaultMethods = resolver
+	s.customers = customers
+}
+
+// SetSessionPaymentMethods enables storing the payment methods payment
+// sessions save with setup_future_usage.
+func (s *PaymentService) SetSessionPaymentMethods(store SessionPaymentMethodStore) {
+	s.sessionMethods = store
+}
+
+// SetIdempotencyTTL sets how long an idempotency key replays its response;
+// after that the same key is treated as a new request. Non-positive values
+// restore DefaultIdempotencyTTL.
+func (s *PaymentService) SetIdempotencyTTL(ttl time.Duration) {
+	s.idempotencyTTL = ttl
+}
+
+func (s *PaymentService) idempotencyWindow() time.Duration {
+	if s.idempotencyTTL <= 0 {
+		return DefaultIdempotencyTTL
+	}
+	return s.idempotencyTTL
+}
+
 func (s *PaymentService) CreateCharge(ctx context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
-	if err := s.validateChargeRequest(req); err != nil {
+	if err := s.resolveDefaultPaymentMethod(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := s.resolveCaptureMethod(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := s.validateChargeRequest(ctx, req); err != nil {
 		return nil, err
 	}
 
@@ -73,14 +169,17 @@ func (s *PaymentService) CreateCharge(ctx context.Context, req *models.ChargeReq
 		}
 	}
 
-	if req.FraudCheck != nil && *req.FraudCheck && s.fraudService != nil {
-		fraudResp, err := s.runFraudCheck(ctx, req)
+	if err := s.fraudGate(ctx, req); err != nil {
+		return nil, err
+	}
+
+	var tax *models.TaxCalculation
+	if req.AutomaticTax {
+		calc, err := s.applyChargeTax(ctx, req)
 		if err != nil {
-			return nil, fmt.Errorf("fraud check failed: %w", err)
-		}
-		if !fraudResp.Allow {
-			return nil, fmt.Errorf("payment declined: %s", fraudResp.Reason)
+			return nil, err
 		}
+		tax = calc
 	}
 
 	providerName := s.selectProvider(ctx, req.Currency)
@@ -93,53 +192,102 @@ func (s *PaymentService) CreateCharge(ctx context.Context, req *models.ChargeReq
 		captureMethod = models.CaptureMethodAutomatic
 	}
 
-	var payment *models.Payment
+	tenantID := ctx.Value(ctxkeys.TenantID)
+	var tenantIDPtr *string
+	if tid, ok := tenantID.(string); ok && tid != "" {
+		tenantIDPtr = &tid
+	}
+
+	// Record the attempt before calling the provider so a crash or a failed
+	// write after a successful charge leaves a pending row to reconcile
+	// rather than a charge with no local record.
+	intent := &models.Payment{
+		TenantID:                  tenantIDPtr,
+		Amount:                    req.Amount,
+		Currency:                  req.Currency,
+		Status:                    models.PaymentStatusPending,
+		PaymentMethod:             req.PaymentMethod,
+		CustomerID:                req.CustomerID,
+		Description:               req.Description,
+		StatementDescriptor:       req.StatementDescriptor,
+		StatementDescriptorSuffix: req.StatementDescriptorSuffix,
+		ProviderName:              providerName,
+		CaptureMethod:             captureMethod,
+		ApplicationFeeAmount:      req.ApplicationFeeAmount,
+		OnBehalfOf:                req.OnBehalfOf,
+		SubAccountID:              req.SubAccountID,
+		IdempotencyKey:            req.IdempotencyKey,
+		BillingAddress:            req.BillingAddress,
+		ShippingAddress:           req.ShippingAddress,
+		Metadata:                  req.Metadata,
+		CreatedAt:                 time.Now(),
+	}
+	if tax != nil {
+		intent.TaxAmount = tax.TaxAmount
+		intent.TaxBreakdown = tax.Breakdown
+		intent.TaxCalculationID = tax.ID
+	}
+	if err := s.paymentRepo.Create(ctx, intent); err != nil {
+		s.completeIdempotency(ctx, req.IdempotencyKey, 500, nil)
+		return nil, fmt.Errorf("failed to record payment intent: %w", err)
+	}
+
 	var chargeResp *models.ChargeResponse
 	var providerErr error
 
+	providerCtx, recorder := s.withResponseCapture(ctx)
 	err := s.executor.Execute(ctx, providerName, func() error {
-		chargeResp, providerErr = s.provider.Charge(ctx, req)
+		chargeResp, providerErr = callProvider(providerCtx, "charge", func(ctx context.Context) (*models.ChargeResponse, error) {
+			return s.provider.Charge(ctx, req)
+		})
 		return providerErr
 	})
 
 	if err != nil {
+		intent.Status = models.PaymentStatusFailed
+		var failover *providers.ChargeFailoverError
+		if errors.As(err, &failover) {
+			intent.ProviderAttempts = failover.Attempts
+		}
+		_ = s.paymentRepo.Update(ctx, intent)
 		s.completeIdempotency(ctx, req.IdempotencyKey, 500, nil)
 		return nil, fmt.Errorf("failed to create charge with provider: %w", err)
 	}
 
-	tenantID := ctx.Value(ctxkeys.TenantID)
-	var tenantIDPtr *string
-	if tid, ok := tenantID.(string); ok && tid != "" {
-		tenantIDPtr = &tid
-	}
-
-	payment = &models.Payment{
-		ID:               chargeResp.ID,
-		TenantID:         tenantIDPtr,
-		Amount:           chargeResp.Amount,
-		Currency:         chargeResp.Currency,
-		Status:           chargeResp.Status,
-		PaymentMethod:    req.PaymentMethod,
-		CustomerID:       req.CustomerID,
-		Description:      req.Description,
-		ProviderName:     providerName,
-		ProviderChargeID: chargeResp.ProviderChargeID,
-		CaptureMethod:    captureMethod,
-		CapturedAmount:   chargeResp.CapturedAmount,
-		RequiresAction:   chargeResp.RequiresAction,
-		NextActionType:   chargeResp.NextActionType,
-		NextActionURL:    chargeResp.NextActionURL,
-		ClientSecret:     chargeResp.ClientSecret,
-		IdempotencyKey:   req.IdempotencyKey,
-		Metadata:         req.Metadata,
-		CreatedAt:        time.Now(),
+	payment := *intent
+	payment.ID = chargeResp.ID
+	payment.Amount = chargeResp.Amount
+	payment.Currency = chargeResp.Currency
+	payment.Status = chargeResp.Status
+	payment.ProviderChargeID = chargeResp.ProviderChargeID
+	payment.CapturedAmount = chargeResp.CapturedAmount
+	payment.RequiresAction = chargeResp.RequiresAction
+	payment.NextActionType = chargeResp.NextActionType
+	payment.NextActionURL = chargeResp.NextActionURL
+	payment.ClientSecret = chargeResp.ClientSecret
+	payment.ProviderAttempts = chargeResp.ProviderAttempts
+	payment.RoutingExplanation = chargeResp.RoutingExplanation
+	if chargeResp.ProviderName != "" {
+		// A multi-provider selector is named for itself; later captures,
+		// reversals and refunds need the provider that took the charge.
+		payment.ProviderName = chargeResp.ProviderName
 	}
 
-	if err := s.paymentRepo.Create(ctx, payment); err != nil {
-		return nil, err
+	if err := s.paymentRepo.CompleteIntent(ctx, intent.ID, &payment); err != nil {
+		// Keep the provider's charge ID on the intent so reconciliation can
+		// look the charge up.
+		intent.ProviderName = payment.ProviderName
+		intent.ProviderChargeID = chargeResp.ProviderChargeID
+		_ = s.paymentRepo.Update(ctx, intent)
+		// Settle the key with the charge the provider took, so a retry
+		// replays it instead of waiting out the key or charging again.
+		s.completeIdempotency(ctx, req.IdempotencyKey, 500, s.buildChargeResponse(&payment))
+		return nil, fmt.Errorf("charge %s succeeded but was not recorded, payment intent %s left pending: %w",
+			chargeResp.ProviderChargeID, intent.ID, err)
 	}
+	s.saveProviderResponses(ctx, payment.ID, payment.TenantID, recorder)
 
-	response := s.buildChargeResponse(payment)
+	response := s.buildChargeResponse(&payment)
 	s.completeIdempotency(ctx, req.IdempotencyKey, 200, response)
 
 	return response, nil
@@ -147,21 +295,28 @@ func (s *PaymentService) CreateCharge(ctx context.Context, req *models.ChargeReq
 
 func (s *PaymentService) Authorize(ctx context.Context, req *models.AuthorizeRequest) (*models.ChargeResponse, error) {
 	chargeReq := &models.ChargeRequest{
-		CustomerID:     req.CustomerID,
-		Amount:         req.Amount,
-		Currency:       req.Currency,
-		PaymentMethod:  req.PaymentMethod,
-		Description:    req.Description,
-		CaptureMethod:  models.CaptureMethodManual,
-		Capture:        boolPtr(false),
-		ReturnURL:      req.ReturnURL,
-		IdempotencyKey: req.IdempotencyKey,
-		Metadata:       req.Metadata,
+		CustomerID:          req.CustomerID,
+		Amount:              req.Amount,
+		Currency:            req.Currency,
+		PaymentMethod:       req.PaymentMethod,
+		Description:         req.Description,
+		CaptureMethod:       models.CaptureMethodManual,
+		Capture:             boolPtr(false),
+		RequestMultiCapture: req.RequestMultiCapture,
+		RequestOvercapture:  req.RequestOvercapture,
+		ReturnURL:           req.ReturnURL,
+		IdempotencyKey:      req.IdempotencyKey,
+		Metadata:            req.Metadata,
 	}
 
 	return s.CreateCharge(ctx, chargeReq)
 }
 
+// Capture captures all or part of an authorized payment. Providers that
+// support multi-capture accept further captures until the authorized amount
+// is reached or a capture is marked final; the payment only succeeds then.
+// Providers that support overcapture also accept captures above the
+// authorized amount, up to their MaxOvercapturePercent of it.
 func (s *PaymentService) Capture(ctx context.Context, req *models.CaptureRequest) (*models.CaptureResponse, error) {
 	payment, err := s.paymentRepo.GetByID(ctx, req.PaymentID)
 	if err != nil {
@@ -175,42 +330,76 @@ func (s *PaymentService) Capture(ctx context.Context, req *models.CaptureRequest
 		return nil, ErrPaymentNotCapturable
 	}
 
+	caps := s.capabilitiesFor(payment.ProviderName)
+	multiCapture := caps.SupportsMultiCapture
+	if payment.CapturedAmount > 0 && !multiCapture {
+		return nil, ErrPaymentAlreadyCaptured
+	}
+
+	remaining := payment.Amount - payment.CapturedAmount
 	captureAmount := req.Amount
 	if captureAmount == 0 {
-		captureAmount = payment.Amount
+		captureAmount = remaining
 	}
 
-	if captureAmount > payment.Amount {
+	if captureAmount <= 0 || captureAmount > remaining+maxOvercapture(payment.Amount, caps) {
 		return nil, ErrInvalidCaptureAmount
 	}
 
+	final := !multiCapture || req.Final || captureAmount >= remaining
+
+	previous, err := s.claimPayment(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+
 	var captureErr error
 	err = s.executor.Execute(ctx, payment.ProviderName, func() error {
-		captureErr = s.captureWithProvider(ctx, payment.ProviderChargeID, captureAmount)
+		if multiCapture {
+			captureErr = s.capturePartialWithProvider(ctx, payment.ProviderChargeID, captureAmount, final)
+		} else {
+			captureErr = s.captureWithProvider(ctx, payment.ProviderChargeID, captureAmount)
+		}
 		return captureErr
 	})
 
 	if err != nil {
+		s.releasePayment(ctx, payment, previous)
 		return nil, fmt.Errorf("failed to capture payment: %w", err)
 	}
 
-	payment.CapturedAmount = captureAmount
-	payment.Status = models.PaymentStatusSuccess
+	payment.Status = previous
+	payment.CapturedAmount += captureAmount
+	payment.OvercapturedAmount = max(payment.CapturedAmount-payment.Amount, 0)
+	if final {
+		payment.Status = models.PaymentStatusSuccess
+	}
 
 	if err := s.paymentRepo.Update(ctx, payment); err != nil {
 		return nil, err
 	}
 
 	return &models.CaptureResponse{
-		ID:           payment.ID,
-		PaymentID:    payment.ID,
-		Amount:       captureAmount,
-		Status:       payment.Status,
-		ProviderName: payment.ProviderName,
-		CapturedAt:   time.Now(),
+		ID:                 payment.ID,
+		PaymentID:          payment.ID,
+		Amount:             captureAmount,
+		CapturedAmount:     payment.CapturedAmount,
+		OvercapturedAmount: payment.OvercapturedAmount,
+		Status:             payment.Status,
+		ProviderName:       payment.ProviderName,
+		CapturedAt:         time.Now(),
 	}, nil
 }
 
+// maxOvercapture is how much more than authorized may be captured: nothing
+// unless the provider supports overcapture.
+func maxOvercapture(authorized int64, caps providers.ProviderCapabilities) int64 {
+	if !caps.SupportsOvercapture {
+		return 0
+	}
+	return authorized * int64(caps.MaxOvercapturePercent) / 100
+}
+
 func (s *PaymentService) Void(ctx context.Context, req *models.VoidRequest) (*models.VoidResponse, error) {
 	payment, err := s.paymentRepo.GetByID(ctx, req.PaymentID)
 	if err != nil {
@@ -221,6 +410,11 @@ func (s *PaymentService) Void(ctx context.Context, req *models.VoidRequest) (*mo
 		return nil, fmt.Errorf("cannot void payment with status: %s", payment.Status)
 	}
 
+	previous, err := s.claimPayment(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+
 	var voidErr error
 	err = s.executor.Execute(ctx, payment.ProviderName, func() error {
 		voidErr = s.voidWithProvider(ctx, payment.ProviderChargeID)
@@ -228,6 +422,7 @@ func (s *PaymentService) Void(ctx context.Context, req *models.VoidRequest) (*mo
 	})
 
 	if err != nil {
+		s.releasePayment(ctx, payment, previous)
 		return nil, fmt.Errorf("failed to void payment: %w", err)
 	}
 
@@ -246,6 +441,61 @@ func (s *PaymentService) Void(ctx context.Context, req *models.VoidRequest) (*mo
 	}, nil
 }
 
+// ReverseAuthorization releases part of an authorized payment's hold. The
+// payment's Amount, its authorized amount, drops by the reversed amount, so
+// later captures are limited to what is left. Releasing the whole uncaptured
+// remainder is a void.
+func (s *PaymentService) ReverseAuthorization(ctx context.Context, req *models.ReverseAuthorizationRequest) (*models.ReversalResponse, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, req.PaymentID)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	if payment.Status != models.PaymentStatusRequiresCapture {
+		return nil, ErrPaymentNotCapturable
+	}
+	if req.Amount <= 0 || req.Amount >= payment.Amount-payment.CapturedAmount {
+		return nil, ErrInvalidReversalAmount
+	}
+	if !s.capabilitiesFor(payment.ProviderName).SupportsPartialReversal {
+		return nil, fmt.Errorf("%w: %s does not support partial reversal", ErrCapabilityUnsupported, payment.ProviderName)
+	}
+
+	previous, err := s.claimPayment(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverseErr error
+	err = s.executor.Execute(ctx, payment.ProviderName, func() error {
+		reverseErr = s.reverseWithProvider(ctx, payment.ProviderChargeID, req.Amount)
+		return reverseErr
+	})
+
+	if err != nil {
+		s.releasePayment(ctx, payment, previous)
+		return nil, fmt.Errorf("failed to reverse authorization: %w", err)
+	}
+
+	payment.Status = previous
+	payment.Amount -= req.Amount
+
+	if err := s.paymentRepo.Update(ctx, payment); err != nil {
+		return nil, err
+	}
+
+	return &models.ReversalResponse{
+		ID:               payment.ID,
+		PaymentID:        payment.ID,
+		Amount:           req.Amount,
+		AuthorizedAmount: payment.Amount,
+		CapturedAmount:   payment.CapturedAmount,
+		Status:           payment.Status,
+		ProviderName:     payment.ProviderName,
+		ReversedAt:       time.Now(),
+	}, nil
+}
+
 func (s *PaymentService) Confirm3DS(ctx context.Context, req *models.Confirm3DSRequest) (*models.ChargeResponse, error) {
 	payment, err := s.paymentRepo.GetByID(ctx, req.PaymentID)
 	if err != nil {
@@ -263,6 +513,7 @@ func (s *PaymentService) CreateRefund(ctx context.Context, req *models.RefundReq
 	if err := s.validateRefundRequest(req); err != nil {
 		return nil, err
 	}
+	req.NormalizeReason()
 
 	payment, err := s.paymentRepo.GetByID(ctx, req.PaymentID)
 	if err != nil {
@@ -276,8 +527,11 @@ func (s *PaymentService) CreateRefund(ctx context.Context, req *models.RefundReq
 	var refundResp *models.RefundResponse
 	var refundErr error
 
+	providerCtx, recorder := s.withResponseCapture(ctx)
 	err = s.executor.Execute(ctx, payment.ProviderName, func() error {
-		refundResp, refundErr = s.provider.Refund(ctx, req)
+		refundResp, refundErr = callProvider(providerCtx, "refund", func(ctx context.Context) (*models.RefundResponse, error) {
+			return s.provider.Refund(ctx, req)
+		})
 		return refundErr
 	})
 
@@ -291,6 +545,7 @@ func (s *PaymentService) CreateRefund(ctx context.Context, req *models.RefundReq
 		Amount:           refundResp.Amount,
 		Status:           refundResp.Status,
 		Reason:           req.Reason,
+		Note:             req.Note,
 		ProviderName:     refundResp.ProviderName,
 		ProviderRefundID: refundResp.ProviderRefundID,
 		Metadata:         req.Metadata,
@@ -300,17 +555,44 @@ func (s *PaymentService) CreateRefund(ctx context.Context, req *models.RefundReq
 	if err := s.paymentRepo.CreateRefund(ctx, refund); err != nil {
 		return nil, err
 	}
+	s.saveProviderResponses(ctx, payment.ID, payment.TenantID, recorder)
 
-	if refund.Amount >= payment.Amount {
+	payment.Status = models.PaymentStatusPartiallyRefunded
+	if s.refundedAmount(ctx, payment.ID, refund.Amount) >= capturedAmount(payment) {
 		payment.Status = models.PaymentStatusRefunded
-	} else {
-		payment.Status = models.PaymentStatusPartiallyRefunded
 	}
 	_ = s.paymentRepo.Update(ctx, payment)
 
 	return refundResp, nil
 }
 
+// refundedAmount totals the payment's refunds that have not failed,
+// including the one just recorded. When they cannot be listed it counts
+// only that one, leaving the payment partially refunded.
+func (s *PaymentService) refundedAmount(ctx context.Context, paymentID string, latest int64) int64 {
+	refunds, err := s.paymentRepo.ListRefundsByPayment(ctx, paymentID)
+	if err != nil {
+		return latest
+	}
+	var total int64
+	for _, refund := range refunds {
+		if refund.Status != "failed" && refund.Status != "canceled" {
+			total += refund.Amount
+		}
+	}
+	return max(total, latest)
+}
+
+// capturedAmount is what the customer was charged: the captured amount,
+// including any overcapture, or the full amount for payments captured
+// before captured amounts were recorded.
+func capturedAmount(payment *models.Payment) int64 {
+	if payment.CapturedAmount > 0 {
+		return payment.CapturedAmount
+	}
+	return payment.Amount
+}
+
 func (s *PaymentService) GetPayment(ctx context.Context, id string) (*models.Payment, error) {
 	return s.paymentRepo.GetByID(ctx, id)
 }
@@ -319,6 +601,31 @@ func (s *PaymentService) ListPayments(ctx context.Context, customerID string) ([
 	return s.paymentRepo.ListByCustomer(ctx, customerID)
 }
 
+func (s *PaymentService) ListPaymentsByMetadata(ctx context.Context, key, value string, filter models.PaymentFilter) (*pagination.Page[*models.Payment], error) {
+	if filter.TenantID == "" {
+		return nil, ErrPaymentTenantRequired
+	}
+	if key == "" {
+		return nil, ErrMetadataKeyRequired
+	}
+	page, err := s.paymentRepo.ListByMetadata(ctx, key, value, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (s *PaymentService) ListTenantPayments(ctx context.Context, filter models.PaymentFilter) (*pagination.Page[*models.Payment], error) {
+	if filter.TenantID == "" {
+		return nil, ErrPaymentTenantRequired
+	}
+	page, err := s.paymentRepo.ListByTenant(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
 func (s *PaymentService) GetRefund(ctx context.Context, id string) (*models.Refund, error) {
 	return s.paymentRepo.GetRefundByID(ctx, id)
 }
@@ -329,53 +636,145 @@ func (s *PaymentService) ListRefunds(ctx context.Context, paymentID string) ([]*
 
 func (s *PaymentService) CreatePaymentSession(ctx context.Context, req *models.CreatePaymentSessionRequest) (*models.PaymentSession, error) {
 	if sessionProvider, ok := s.provider.(providers.PaymentSessionProvider); ok {
-		return sessionProvider.CreatePaymentSession(ctx, req)
+		return callProvider(ctx, "create_payment_session", func(ctx context.Context) (*models.PaymentSession, error) {
+			return sessionProvider.CreatePaymentSession(ctx, req)
+		})
 	}
 	return nil, errors.New("provider does not support payment sessions")
 }
 
 func (s *PaymentService) GetPaymentSession(ctx context.Context, id string) (*models.PaymentSession, error) {
 	if sessionProvider, ok := s.provider.(providers.PaymentSessionProvider); ok {
-		return sessionProvider.GetPaymentSession(ctx, id)
+		session, err := callProvider(ctx, "get_payment_session", func(ctx context.Context) (*models.PaymentSession, error) {
+			return sessionProvider.GetPaymentSession(ctx, id)
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.saveSessionPaymentMethod(ctx, session)
+		return session, nil
 	}
 	return nil, errors.New("provider does not support payment sessions")
 }
 
 func (s *PaymentService) UpdatePaymentSession(ctx context.Context, id string, req *models.UpdatePaymentSessionRequest) (*models.PaymentSession, error) {
 	if sessionProvider, ok := s.provider.(providers.PaymentSessionProvider); ok {
-		return sessionProvider.UpdatePaymentSession(ctx, id, req)
+		return callProvider(ctx, "update_payment_session", func(ctx context.Context) (*models.PaymentSession, error) {
+			return sessionProvider.UpdatePaymentSession(ctx, id, req)
+		})
 	}
 	return nil, errors.New("provider does not support payment sessions")
 }
 
 func (s *PaymentService) ConfirmPaymentSession(ctx context.Context, id string, req *models.ConfirmPaymentSessionRequest) (*models.PaymentSession, error) {
 	if sessionProvider, ok := s.provider.(providers.PaymentSessionProvider); ok {
-		return sessionProvider.ConfirmPaymentSession(ctx, id, req)
+		session, err := callProvider(ctx, "confirm_payment_session", func(ctx context.Context) (*models.PaymentSession, error) {
+			return sessionProvider.ConfirmPaymentSession(ctx, id, req)
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.saveSessionPaymentMethod(ctx, session)
+		return session, nil
 	}
 	return nil, errors.New("provider does not support payment sessions")
 }
 
 func (s *PaymentService) CapturePaymentSession(ctx context.Context, id string, amount *int64) (*models.PaymentSession, error) {
 	if sessionProvider, ok := s.provider.(providers.PaymentSessionProvider); ok {
-		return sessionProvider.CapturePaymentSession(ctx, id, amount)
+		return callProvider(ctx, "capture_payment_session", func(ctx context.Context) (*models.PaymentSession, error) {
+			return sessionProvider.CapturePaymentSession(ctx, id, amount)
+		})
 	}
 	return nil, errors.New("provider does not support payment sessions")
 }
 
 func (s *PaymentService) CancelPaymentSession(ctx context.Context, id string) (*models.PaymentSession, error) {
 	if sessionProvider, ok := s.provider.(providers.PaymentSessionProvider); ok {
-		return sessionProvider.CancelPaymentSession(ctx, id)
+		return callProvider(ctx, "cancel_payment_session", func(ctx context.Context) (*models.PaymentSession, error) {
+			return sessionProvider.CancelPaymentSession(ctx, id)
+		})
 	}
 	return nil, errors.New("provider does not support payment sessions")
 }
 
 func (s *PaymentService) ListPaymentSessions(ctx context.Context, req *models.ListPaymentSessionsRequest) ([]*models.PaymentSession, error) {
 	if sessionProvider, ok := s.provider.(providers.PaymentSessionProvider); ok {
-		return sessionProvider.ListPaymentSessions(ctx, req)
+		return callProvider(ctx, "list_payment_sessions", func(ctx context.Context) ([]*models.PaymentSession, error) {
+			return sessionProvider.ListPaymentSessions(ctx, req)
+		})
 	}
 	return nil, errors.New("provider does not support payment sessions")
 }
 
+// saveSessionPaymentMethod stores the payment method a completed session set
+// up for future use, attached to the session's customer, and records it on
+// the session as SavedPaymentMethodID. Sessions are read back after the
+// customer confirms them client-side, so this runs on every read and is a
+// no-op once the method is stored. Failing to save does not fail the read;
+// the next read tries again.
+func (s *PaymentService) saveSessionPaymentMethod(ctx context.Context, session *models.PaymentSession) {
+	if s.sessionMethods == nil || session.SetupFutureUsage == "" || session.PaymentMethodID == "" || session.CustomerID == "" {
+		return
+	}
+	if session.Status != models.PaymentStatusSuccess && session.Status != models.PaymentStatusRequiresCapture {
+		return
+	}
+
+	if existing, err := s.sessionMethods.GetByProviderID(ctx, session.ProviderName, session.PaymentMethodID); err == nil {
+		session.SavedPaymentMethodID = existing.ID
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Failed to look up payment method saved by session %s: %v", session.ProviderID, err)
+		return
+	}
+
+	pm, err := s.attachSessionPaymentMethod(ctx, session)
+	if err != nil {
+		log.Printf("Failed to save payment method for session %s: %v", session.ProviderID, err)
+		return
+	}
+	if err := s.sessionMethods.Create(ctx, pm); err != nil {
+		log.Printf("Failed to store payment method saved by session %s: %v", session.ProviderID, err)
+		return
+	}
+	session.SavedPaymentMethodID = pm.ID
+}
+
+// attachSessionPaymentMethod loads the session's payment method from the
+// provider and attaches it to the session's customer if the provider has not
+// already done so.
+func (s *PaymentService) attachSessionPaymentMethod(ctx context.Context, session *models.PaymentSession) (*models.PaymentMethod, error) {
+	pmProvider, ok := s.provider.(providers.PaymentMethodProvider)
+	if !ok {
+		return nil, providers.ErrNotSupported
+	}
+
+	pm, err := callProvider(ctx, "get_payment_method", func(ctx context.Context) (*models.PaymentMethod, error) {
+		return pmProvider.GetPaymentMethod(ctx, session.PaymentMethodID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pm.CustomerID != session.CustomerID {
+		if err := callProviderErr(ctx, "attach_payment_method", func(ctx context.Context) error {
+			return pmProvider.AttachPaymentMethod(ctx, session.PaymentMethodID, session.CustomerID)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	pm.CustomerID = session.CustomerID
+	pm.ProviderName = session.ProviderName
+	pm.ProviderPaymentMethodID = session.PaymentMethodID
+	pm.Reusable = true
+	pm.PaymentSessionID = session.ProviderID
+	if pm.Status == "" {
+		pm.Status = models.PaymentMethodStatusActive
+	}
+	return pm, nil
+}
+
 func (s *PaymentService) checkIdempotency(ctx context.Context, key, path string, req interface{}) (*models.IdempotencyResult, error) {
 	if s.idempotencyStore == nil {
 		return &models.IdempotencyResult{IsNew: true}, nil
@@ -387,7 +786,7 @@ func (s *PaymentService) checkIdempotency(ctx context.Context, key, path string,
 		tenantID = tid.(string)
 	}
 
-	return s.idempotencyStore.GetOrCreate(ctx, key, tenantID, path, reqBody, 24*time.Hour)
+	return s.idempotencyStore.GetOrCreate(ctx, key, tenantID, path, reqBody, s.idempotencyWindow())
 }
 
 func (s *PaymentService) completeIdempotency(ctx context.Context, key string, code int, response interface{}) {
@@ -397,27 +796,148 @@ func (s *PaymentService) completeIdempotency(ctx context.Context, key string, co
 	_ = s.idempotencyStore.Complete(ctx, key, code, response)
 }
 
-func (s *PaymentService) validateChargeRequest(req *models.ChargeRequest) error {
-	if req.Amount <= 0 {
-		return errors.New("amount must be positive")
+// resolveDefaultPaymentMethod fills in the customer's default payment method
+// when the charge does not name one. With a tenant in ctx, a customer of
+// another tenant is reported as not found rather than charged.
+func (s *PaymentService) resolveDefaultPaymentMethod(ctx context.Context, req *models.ChargeRequest) error {
+	if req.PaymentMethod != "" || req.CustomerID == "" || s.defaultMethods == nil {
+		return nil
 	}
-	if req.Currency == "" {
-		return errors.New("currency is required")
+	if err := s.checkCustomerTenant(ctx, req.CustomerID); err != nil {
+		return err
 	}
+	pm, err := s.defaultMethods.GetDefault(ctx, req.CustomerID)
+	if err != nil {
+		return err
+	}
+	req.PaymentMethod = pm.ProviderPaymentMethodID
 	if req.PaymentMethod == "" {
-		return errors.New("payment method is required")
+		req.PaymentMethod = pm.ID
+	}
+	if req.PaymentMethodType == "" {
+		req.PaymentMethodType = pm.Type
 	}
 	return nil
 }
 
+func (s *PaymentService) checkCustomerTenant(ctx context.Context, customerID string) error {
+	tenantID, _ := ctx.Value(ctxkeys.TenantID).(string)
+	if tenantID == "" {
+		return nil
+	}
+	if s.customers == nil {
+		return fmt.Errorf("%w: %s", ErrCustomerNotFound, customerID)
+	}
+	customer, err := s.customers.GetByID(ctx, customerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", ErrCustomerNotFound, customerID)
+		}
+		return err
+	}
+	if customer.TenantID == nil || *customer.TenantID != tenantID {
+		return fmt.Errorf("%w: %s", ErrCustomerNotFound, customerID)
+	}
+	return nil
+}
+
+// validateChargeRequest checks every field before returning, so the
+// *ValidationError lists all of the request's problems.
+func (s *PaymentService) validateChargeRequest(ctx context.Context, req *models.ChargeRequest) error {
+	var verr ValidationError
+	if req.Amount <= 0 {
+		verr.Add("amount", FieldCodeMustBePositive, "amount must be positive")
+	}
+	if req.Currency == "" {
+		verr.Add("currency", FieldCodeRequired, "currency is required")
+	}
+	if req.Amount > 0 && req.Currency != "" {
+		if err := s.validateAmount(ctx, req); err != nil {
+			verr.AddErr("amount", FieldCodeOutOfRange, err)
+		}
+	}
+	if req.VPA != "" {
+		// A VPA is a UPI collect request, which only UPI providers take.
+		if req.PaymentMethodType == "" {
+			req.PaymentMethodType = models.PMTypeUPI
+		} else if req.PaymentMethodType != models.PMTypeUPI {
+			verr.Add("payment_method_type", FieldCodeInvalid, "vpa can only be used with payment_method_type upi")
+		}
+	}
+	if req.PaymentMethod == "" && req.VPA == "" {
+		verr.Add("payment_method", FieldCodeRequired, "payment method is required")
+	}
+	if len(req.StatementDescriptor) > maxStatementDescriptorLength {
+		verr.AddErr("statement_descriptor", FieldCodeTooLong,
+			fmt.Errorf("%w: must be at most %d characters", providers.ErrInvalidStatementDescriptor, maxStatementDescriptorLength))
+	}
+	if len(req.StatementDescriptorSuffix) > maxStatementDescriptorLength {
+		verr.AddErr("statement_descriptor_suffix", FieldCodeTooLong,
+			fmt.Errorf("%w: suffix must be at most %d characters", providers.ErrInvalidStatementDescriptor, maxStatementDescriptorLength))
+	}
+	if req.AllowRedirects != "" && !req.AllowRedirects.Valid() {
+		verr.Add("allow_redirects", FieldCodeInvalid, "allow_redirects must be always or never")
+	}
+	if req.AllowRedirects != "" && len(req.PaymentMethodTypes) > 0 {
+		verr.Add("allow_redirects", FieldCodeNotAllowed, "allow_redirects cannot be combined with payment_method_types")
+	}
+	if req.ApplicationFeeAmount < 0 {
+		verr.Add("application_fee_amount", FieldCodeMustNotBeNegative, "application fee amount cannot be negative")
+	}
+	if req.ApplicationFeeAmount > req.Amount {
+		verr.Add("application_fee_amount", FieldCodeOutOfRange, "application fee amount cannot exceed charge amount")
+	}
+	if req.ApplicationFeeAmount > 0 && req.OnBehalfOf == "" {
+		verr.Add("on_behalf_of", FieldCodeRequired, "on_behalf_of is required when application fee amount is set")
+	}
+	if req.BillingAddress != nil {
+		validateAddress(&verr, "billing_address", req.BillingAddress)
+	}
+	if req.ShippingAddress != nil {
+		if req.ShippingAddress.Name == "" {
+			verr.Add("shipping_address.name", FieldCodeRequired, "shipping address name is required")
+		}
+		validateAddress(&verr, "shipping_address.address", &req.ShippingAddress.Address)
+	}
+	// Selectors pick a provider that supports the method when they route.
+	if _, ok := s.provider.(providers.ProviderResolver); !ok {
+		if err := providers.CheckPaymentMethod(s.provider, req.PaymentMethodType); err != nil {
+			verr.AddErr("payment_method_type", FieldCodeUnsupported, err)
+		}
+	}
+	return verr.Err()
+}
+
+func validateAddress(verr *ValidationError, field string, address *models.Address) {
+	if len(address.Country) != 2 {
+		verr.Add(field+".country", FieldCodeInvalid, "country must be a two-letter ISO 3166-1 code")
+	}
+}
+
 func (s *PaymentService) validateRefundRequest(req *models.RefundRequest) error {
+	var verr ValidationError
 	if req.PaymentID == "" {
-		return errors.New("payment ID is required")
+		verr.Add("payment_id", FieldCodeRequired, "payment ID is required")
 	}
 	if req.Amount <= 0 {
-		return errors.New("amount must be positive")
+		verr.Add("amount", FieldCodeMustBePositive, "amount must be positive")
 	}
-	return nil
+	return verr.Err()
+}
+
+// resolveCaptureMethod fills in the tenant's default capture method when the
+// charge names neither a capture method nor capture, and rejects manual
+// capture on a provider that cannot hold an authorization.
+func (s *PaymentService) resolveCaptureMethod(ctx context.Context, req *models.ChargeRequest) error {
+	if req.CaptureMethod == "" && req.Capture == nil {
+		if tenant, ok := ctx.Value(ctxkeys.Tenant).(*models.Tenant); ok && tenant != nil {
+			req.CaptureMethod = tenant.DefaultCaptureMethod
+		}
+	}
+	if req.CaptureMethod != models.CaptureMethodManual {
+		return nil
+	}
+	return requireCapabilityForCurrency(ctx, s.provider, req.Currency, providers.CapabilityManualCapture)
 }
 
 func (s *PaymentService) selectProvider(ctx context.Context, currency string) string {
@@ -429,37 +949,99 @@ func (s *PaymentService) selectProvider(ctx context.Context, currency string) st
 
 func (s *PaymentService) captureWithProvider(ctx context.Context, providerChargeID string, amount int64) error {
 	if capturer, ok := s.provider.(providers.CaptureProvider); ok {
-		return capturer.CapturePayment(ctx, providerChargeID, amount)
+		return callProviderErr(ctx, "capture_payment", func(ctx context.Context) error {
+			return capturer.CapturePayment(ctx, providerChargeID, amount)
+		})
 	}
 	return errors.New("provider does not support capture")
 }
 
+func (s *PaymentService) capturePartialWithProvider(ctx context.Context, providerChargeID string, amount int64, final bool) error {
+	if capturer, ok := s.provider.(providers.MultiCaptureProvider); ok {
+		return callProviderErr(ctx, "capture_partial", func(ctx context.Context) error {
+			return capturer.CapturePartial(ctx, providerChargeID, amount, final)
+		})
+	}
+	return errors.New("provider does not support multi-capture")
+}
+
+func (s *PaymentService) capabilitiesFor(providerName string) providers.ProviderCapabilities {
+	if lookup, ok := s.provider.(providers.CapabilityLookup); ok {
+		caps, _ := lookup.CapabilitiesFor(providerName)
+		return caps
+	}
+	return s.provider.Capabilities()
+}
+
+// claimPayment moves the payment to processing before a provider call and
+// returns the status it had. Update's version check makes a concurrent
+// capture or void fail here with ErrConcurrentModification instead of also
+// reaching the provider.
+func (s *PaymentService) claimPayment(ctx context.Context, payment *models.Payment) (models.PaymentStatus, error) {
+	previous := payment.Status
+	payment.Status = models.PaymentStatusProcessing
+	if err := s.paymentRepo.Update(ctx, payment); err != nil {
+		payment.Status = previous
+		return previous, err
+	}
+	return previous, nil
+}
+
+// releasePayment puts a claimed payment back after the provider call failed.
+// If this write is lost, reconciliation picks the payment up from processing.
+func (s *PaymentService) releasePayment(ctx context.Context, payment *models.Payment, previous models.PaymentStatus) {
+	payment.Status = previous
+	_ = s.paymentRepo.Update(ctx, payment)
+}
+
 func (s *PaymentService) voidWithProvider(ctx context.Context, providerChargeID string) error {
 	if voider, ok := s.provider.(providers.VoidProvider); ok {
-		return voider.VoidPayment(ctx, providerChargeID)
+		return callProviderErr(ctx, "void_payment", func(ctx context.Context) error {
+			return voider.VoidPayment(ctx, providerChargeID)
+		})
 	}
 	return errors.New("provider does not support void")
 }
 
+func (s *PaymentService) reverseWithProvider(ctx context.Context, providerChargeID string, amount int64) error {
+	if reverser, ok := s.provider.(providers.AuthorizationReversalProvider); ok {
+		return callProviderErr(ctx, "reverse_authorization", func(ctx context.Context) error {
+			return reverser.ReverseAuthorization(ctx, providerChargeID, amount)
+		})
+	}
+	return errors.New("provider does not support authorization reversal")
+}
+
 func (s *PaymentService) buildChargeResponse(payment *models.Payment) *models.ChargeResponse {
 	return &models.ChargeResponse{
-		ID:               payment.ID,
-		CustomerID:       payment.CustomerID,
-		Amount:           payment.Amount,
-		Currency:         payment.Currency,
-		Status:           payment.Status,
-		PaymentMethod:    payment.PaymentMethod,
-		Description:      payment.Description,
-		ProviderName:     payment.ProviderName,
-		ProviderChargeID: payment.ProviderChargeID,
-		CaptureMethod:    payment.CaptureMethod,
-		CapturedAmount:   payment.CapturedAmount,
-		RequiresAction:   payment.RequiresAction,
-		NextActionType:   payment.NextActionType,
-		NextActionURL:    payment.NextActionURL,
-		ClientSecret:     payment.ClientSecret,
-		Metadata:         payment.Metadata,
-		CreatedAt:        payment.CreatedAt,
+		ID:                        payment.ID,
+		CustomerID:                payment.CustomerID,
+		Amount:                    payment.Amount,
+		Currency:                  payment.Currency,
+		Status:                    payment.Status,
+		PaymentMethod:             payment.PaymentMethod,
+		Description:               payment.Description,
+		StatementDescriptor:       payment.StatementDescriptor,
+		StatementDescriptorSuffix: payment.StatementDescriptorSuffix,
+		ProviderName:              payment.ProviderName,
+		ProviderChargeID:          payment.ProviderChargeID,
+		CaptureMethod:             payment.CaptureMethod,
+		CapturedAmount:            payment.CapturedAmount,
+		ApplicationFeeAmount:      payment.ApplicationFeeAmount,
+		OnBehalfOf:                payment.OnBehalfOf,
+		SubAccountID:              payment.SubAccountID,
+		TaxAmount:                 payment.TaxAmount,
+		TaxBreakdown:              payment.TaxBreakdown,
+		BillingAddress:            payment.BillingAddress,
+		ShippingAddress:           payment.ShippingAddress,
+		RequiresAction:            payment.RequiresAction,
+		NextActionType:            payment.NextActionType,
+		NextActionURL:             payment.NextActionURL,
+		ClientSecret:              payment.ClientSecret,
+		Metadata:                  payment.Metadata,
+		ProviderAttempts:          payment.ProviderAttempts,
+		RoutingExplanation:        payment.RoutingExplanation,
+		CreatedAt:                 payment.CreatedAt,
 	}
 }
 
@@ -475,12 +1057,23 @@ func (s *PaymentService) runFraudCheck(ctx context.Context, req *models.ChargeRe
 		}
 	}
 
+	// Structured addresses win over the older billing_country and
+	// shipping_country metadata keys.
+	billingCountry := extractMetadataString(req.Metadata, "billing_country", "US")
+	if req.BillingAddress != nil && req.BillingAddress.Country != "" {
+		billingCountry = req.BillingAddress.Country
+	}
+	shippingCountry := extractMetadataString(req.Metadata, "shipping_country", "US")
+	if req.ShippingAddress != nil && req.ShippingAddress.Address.Country != "" {
+		shippingCountry = req.ShippingAddress.Address.Country
+	}
+
 	fraudReq := &models.FraudAnalysisRequest{
 		TransactionID:       req.IdempotencyKey,
 		UserID:              req.CustomerID,
-		TransactionAmount:   float64(req.Amount) / 100,
-		BillingCountry:      extractMetadataString(req.Metadata, "billing_country", "US"),
-		ShippingCountry:     extractMetadataString(req.Metadata, "shipping_country", "US"),
+		TransactionAmount:   req.Money().Major(),
+		BillingCountry:      billingCountry,
+		ShippingCountry:     shippingCountry,
 		IPAddress:           ipAddress,
 		TransactionVelocity: 1,
 	}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+type recordingFraudService struct {
+	FraudService
+	requests []*models.FraudAnalysisRequest
+}
+
+func (f *recordingFraudService) AnalyzeTransaction(_ context.Context, req *models.FraudAnalysisRequest) (*models.FraudAnalysisResponse, error) {
+	f.requests = append(f.requests, req)
+	return &models.FraudAnalysisResponse{Allow: true}, nil
+}
+
+func TestChargeAddressesReachFraudCheckAndPayment(t *testing.T) {
+	store := &fakeChargeStore{}
+	fraud := &recordingFraudService{}
+	svc := CreatePaymentServiceFull(store, nil, nil, &fakeChargeProvider{}, fraud)
+
+	billing := &models.Address{Line1: "1 Main St", PostalCode: "94105", Country: "US"}
+	shipping := &models.ShippingAddress{Name: "Ada Lovelace", Address: models.Address{City: "London", Country: "GB"}}
+	resp, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID:      "cus_1",
+		Amount:          1000,
+		Currency:        "USD",
+		PaymentMethod:   "pm_card_visa",
+		FraudCheck:      boolPtr(true),
+		BillingAddress:  billing,
+		ShippingAddress: shipping,
+		// Structured addresses take precedence over the metadata keys.
+		Metadata: models.JSON{"billing_country": "CA"},
+	})
+	if err != nil {
+		t.Fatalf("create charge: %v", err)
+	}
+
+	if len(fraud.requests) != 1 {
+		t.Fatalf("expected one fraud check, got %d", len(fraud.requests))
+	}
+	if got := fraud.requests[0]; got.BillingCountry != "US" || got.ShippingCountry != "GB" {
+		t.Fatalf("expected billing US and shipping GB in the fraud input, got %s and %s", got.BillingCountry, got.ShippingCountry)
+	}
+
+	stored := store.payments[0]
+	if stored.BillingAddress == nil || stored.BillingAddress.PostalCode != "94105" {
+		t.Fatalf("expected the billing address stored on the payment, got %+v", stored.BillingAddress)
+	}
+	if stored.ShippingAddress == nil || stored.ShippingAddress.Name != "Ada Lovelace" {
+		t.Fatalf("expected the shipping address stored on the payment, got %+v", stored.ShippingAddress)
+	}
+	if resp.ShippingAddress == nil || resp.ShippingAddress.Address.Country != "GB" {
+		t.Fatalf("expected the shipping address in the response, got %+v", resp.ShippingAddress)
+	}
+}
+
+func TestChargeRejectsShippingAddressWithoutName(t *testing.T) {
+	svc := CreatePaymentService(&fakeChargeStore{}, &fakeChargeProvider{})
+
+	_, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID:      "cus_1",
+		Amount:          1000,
+		Currency:        "USD",
+		PaymentMethod:   "pm_card_visa",
+		ShippingAddress: &models.ShippingAddress{Address: models.Address{Country: "United Kingdom"}},
+	})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, f := range verr.Fields {
+		fields[f.Field] = true
+	}
+	if !fields["shipping_address.name"] || !fields["shipping_address.address.country"] {
+		t.Fatalf("expected name and country errors, got %+v", verr.Fields)
+	}
+}
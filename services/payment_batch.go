@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+const (
+	MaxBatchChargeSize     = 100
+	batchChargeConcurrency = 8
+)
+
+var (
+	ErrBatchEmpty                   = errors.New("batch must contain at least one charge")
+	ErrBatchTooLarge                = fmt.Errorf("batch cannot contain more than %d charges", MaxBatchChargeSize)
+	ErrDuplicateBatchIdempotencyKey = errors.New("idempotency key is repeated within the batch")
+)
+
+// CreateChargeBatch runs each charge through CreateCharge, so every item gets
+// the same validation, fraud checks and idempotency handling as a single
+// charge. A failing item is reported in its result and never aborts the rest.
+func (s *PaymentService) CreateChargeBatch(ctx context.Context, reqs []models.ChargeRequest) (*models.BatchChargeResponse, error) {
+	if len(reqs) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	if len(reqs) > MaxBatchChargeSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]models.BatchChargeResult, len(reqs))
+	seenKeys := make(map[string]bool, len(reqs))
+	sem := make(chan struct{}, batchChargeConcurrency)
+	var wg sync.WaitGroup
+
+	for i := range reqs {
+		req := &reqs[i]
+		results[i] = models.BatchChargeResult{Index: i, IdempotencyKey: req.IdempotencyKey}
+
+		if req.IdempotencyKey != "" {
+			if seenKeys[req.IdempotencyKey] {
+				results[i].Error = ErrDuplicateBatchIdempotencyKey.Error()
+				continue
+			}
+			seenKeys[req.IdempotencyKey] = true
+		}
+
+		wg.Add(1)
+		go func(result *models.BatchChargeResult) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			resp, err := s.CreateCharge(ctx, req)
+			if err != nil {
+				result.Error = err.Error()
+				var verr *ValidationError
+				if errors.As(err, &verr) {
+					result.Errors = verr.Fields
+				}
+				return
+			}
+			result.Success = true
+			result.Charge = resp
+		}(&results[i])
+	}
+	wg.Wait()
+
+	response := &models.BatchChargeResponse{Results: results}
+	for _, result := range results {
+		if result.Success {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type fakeChargeStore struct {
+	PaymentStore
+	mu          sync.Mutex
+	payments    []*models.Payment
+	completeErr error
+}
+
+func (f *fakeChargeStore) Create(_ context.Context, payment *models.Payment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if payment.ID == "" {
+		payment.ID = fmt.Sprintf("intent_%d", len(f.payments)+1)
+	}
+	copied := *payment
+	f.payments = append(f.payments, &copied)
+	return nil
+}
+
+func (f *fakeChargeStore) Update(_ context.Context, payment *models.Payment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, p := range f.payments {
+		if p.ID == payment.ID {
+			copied := *payment
+			f.payments[i] = &copied
+		}
+	}
+	return nil
+}
+
+func (f *fakeChargeStore) CompleteIntent(_ context.Context, intentID string, payment *models.Payment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.completeErr != nil {
+		return f.completeErr
+	}
+	for i, p := range f.payments {
+		if p.ID == intentID {
+			f.payments[i] = payment
+			return nil
+		}
+	}
+	return fmt.Errorf("payment intent %s not found", intentID)
+}
+
+type fakeChargeProvider struct {
+	providers.PaymentProvider
+	charges atomic.Int64
+}
+
+func (f *fakeChargeProvider) Name() string { return "stripe" }
+
+func (f *fakeChargeProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeChargeProvider) Charge(_ context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+	n := f.charges.Add(1)
+	return &models.ChargeResponse{
+		ID:               fmt.Sprintf("pay_%d", n),
+		Amount:           req.Amount,
+		Currency:         req.Currency,
+		Status:           models.PaymentStatusSuccess,
+		ProviderChargeID: fmt.Sprintf("ch_%d", n),
+	}, nil
+}
+
+func TestChargeBatchReportsPerItemResults(t *testing.T) {
+	store := &fakeChargeStore{}
+	provider := &fakeChargeProvider{}
+	svc := CreatePaymentService(store, provider)
+
+	resp, err := svc.CreateChargeBatch(context.Background(), []models.ChargeRequest{
+		{Amount: 1000, Currency: "USD", PaymentMethod: "pm_card_visa", IdempotencyKey: "order-1"},
+		{Amount: 0, Currency: "USD", PaymentMethod: "pm_card_visa", IdempotencyKey: "order-2"},
+		{Amount: 2500, Currency: "USD", PaymentMethod: "pm_card_visa", IdempotencyKey: "order-3"},
+		{Amount: 1000, Currency: "", PaymentMethod: "pm_card_visa", IdempotencyKey: "order-4"},
+		{Amount: 1000, Currency: "USD", PaymentMethod: "pm_card_visa", IdempotencyKey: "order-1"},
+	})
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+
+	if resp.Succeeded != 2 || resp.Failed != 3 || len(resp.Results) != 5 {
+		t.Fatalf("expected 2 succeeded and 3 failed, got %+v", resp)
+	}
+	for i, result := range resp.Results {
+		if result.Index != i {
+			t.Fatalf("result %d has index %d", i, result.Index)
+		}
+	}
+
+	if r := resp.Results[0]; !r.Success || r.Charge == nil || r.Charge.Amount != 1000 || r.IdempotencyKey != "order-1" {
+		t.Fatalf("expected first item to succeed, got %+v", r)
+	}
+	if r := resp.Results[2]; !r.Success || r.Charge == nil || r.Charge.Amount != 2500 {
+		t.Fatalf("expected third item to succeed, got %+v", r)
+	}
+	if r := resp.Results[1]; r.Success || r.Error != "amount must be positive" {
+		t.Fatalf("expected amount validation failure, got %+v", r)
+	}
+	if r := resp.Results[3]; r.Success || r.Error != "currency is required" {
+		t.Fatalf("expected currency validation failure, got %+v", r)
+	}
+	if r := resp.Results[4]; r.Success || r.Error != ErrDuplicateBatchIdempotencyKey.Error() {
+		t.Fatalf("expected duplicate idempotency key failure, got %+v", r)
+	}
+
+	if got := provider.charges.Load(); got != 2 {
+		t.Fatalf("expected only valid items to reach the provider, got %d charges", got)
+	}
+	if len(store.payments) != 2 {
+		t.Fatalf("expected 2 stored payments, got %d", len(store.payments))
+	}
+}
+
+func TestChargeBatchRejectsEmptyAndOversizedBatches(t *testing.T) {
+	svc := CreatePaymentService(&fakeChargeStore{}, &fakeChargeProvider{})
+
+	if _, err := svc.CreateChargeBatch(context.Background(), nil); !errors.Is(err, ErrBatchEmpty) {
+		t.Fatalf("expected ErrBatchEmpty, got %v", err)
+	}
+	oversized := make([]models.ChargeRequest, MaxBatchChargeSize+1)
+	if _, err := svc.CreateChargeBatch(context.Background(), oversized); !errors.Is(err, ErrBatchTooLarge) {
+		t.Fatalf("expected ErrBatchTooLarge, got %v", err)
+	}
+}
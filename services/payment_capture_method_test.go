@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type captureMethodProvider struct {
+	fakeChargeProvider
+	manualCapture bool
+	requests      []models.ChargeRequest
+}
+
+func (f *captureMethodProvider) Capabilities() providers.ProviderCapabilities {
+	return providers.ProviderCapabilities{SupportsManualCapture: f.manualCapture}
+}
+
+// Charge authorizes rather than captures when the request asks for manual
+// capture, as the real providers do.
+func (f *captureMethodProvider) Charge(ctx context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+	f.requests = append(f.requests, *req)
+	resp, err := f.fakeChargeProvider.Charge(ctx, req)
+	if err == nil && req.CaptureMethod == models.CaptureMethodManual {
+		resp.Status = models.PaymentStatusRequiresCapture
+	}
+	return resp, err
+}
+
+func tenantContext(defaultCapture models.CaptureMethod) context.Context {
+	tenant := &models.Tenant{ID: "ten_1", DefaultCaptureMethod: defaultCapture}
+	ctx := context.WithValue(context.Background(), ctxkeys.TenantID, tenant.ID)
+	return context.WithValue(ctx, ctxkeys.Tenant, tenant)
+}
+
+func TestManualDefaultTenantChargeAuthorizes(t *testing.T) {
+	store := &fakeChargeStore{}
+	provider := &captureMethodProvider{manualCapture: true}
+	svc := CreatePaymentService(store, provider)
+
+	resp, err := svc.CreateCharge(tenantContext(models.CaptureMethodManual), &models.ChargeRequest{
+		Amount:        5000,
+		Currency:      "USD",
+		PaymentMethod: "pm_card_visa",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Status != models.PaymentStatusRequiresCapture {
+		t.Fatalf("expected the charge to be authorized only, got %s", resp.Status)
+	}
+	if got := provider.requests[0].CaptureMethod; got != models.CaptureMethodManual {
+		t.Fatalf("expected the provider to be asked for manual capture, got %q", got)
+	}
+	if got := store.payments[0].CaptureMethod; got != models.CaptureMethodManual {
+		t.Fatalf("expected the payment to record manual capture, got %q", got)
+	}
+}
+
+func TestChargeCaptureMethodOverridesTenantDefault(t *testing.T) {
+	tests := []struct {
+		name   string
+		tenant models.CaptureMethod
+		req    models.ChargeRequest
+		want   models.CaptureMethod
+	}{
+		{"no tenant default", "", models.ChargeRequest{}, models.CaptureMethodAutomatic},
+		{"explicit automatic", models.CaptureMethodManual, models.ChargeRequest{CaptureMethod: models.CaptureMethodAutomatic}, models.CaptureMethodAutomatic},
+		{"explicit capture flag", models.CaptureMethodManual, models.ChargeRequest{Capture: boolPtr(true)}, models.CaptureMethodAutomatic},
+		{"tenant default", models.CaptureMethodManual, models.ChargeRequest{}, models.CaptureMethodManual},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeChargeStore{}
+			svc := CreatePaymentService(store, &captureMethodProvider{manualCapture: true})
+
+			req := tt.req
+			req.Amount, req.Currency, req.PaymentMethod = 5000, "USD", "pm_card_visa"
+			if _, err := svc.CreateCharge(tenantContext(tt.tenant), &req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := store.payments[0].CaptureMethod; got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestManualDefaultRequiresManualCaptureSupport(t *testing.T) {
+	provider := &captureMethodProvider{}
+	svc := CreatePaymentService(&fakeChargeStore{}, provider)
+
+	_, err := svc.CreateCharge(tenantContext(models.CaptureMethodManual), &models.ChargeRequest{
+		Amount:        5000,
+		Currency:      "USD",
+		PaymentMethod: "pm_card_visa",
+	})
+	if !errors.Is(err, ErrCapabilityUnsupported) {
+		t.Fatalf("expected ErrCapabilityUnsupported, got %v", err)
+	}
+	if len(provider.requests) != 0 {
+		t.Fatal("expected the provider not to be charged")
+	}
+}
@@ -0,0 +1,434 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type fakePaymentStore struct {
+	PaymentStore
+	mu       sync.Mutex
+	payments map[string]*models.Payment
+	// reads, when set, is marked done by each GetByID and waited on before
+	// returning so concurrent callers all read the same version.
+	reads   *sync.WaitGroup
+	refunds []*models.Refund
+}
+
+func (f *fakePaymentStore) GetByID(_ context.Context, id string) (*models.Payment, error) {
+	f.mu.Lock()
+	payment, ok := f.payments[id]
+	var copied models.Payment
+	if ok {
+		copied = *payment
+	}
+	f.mu.Unlock()
+
+	if f.reads != nil {
+		f.reads.Done()
+		f.reads.Wait()
+	}
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return &copied, nil
+}
+
+func (f *fakePaymentStore) Create(_ context.Context, payment *models.Payment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if payment.ID == "" {
+		payment.ID = fmt.Sprintf("intent_%d", len(f.payments)+1)
+	}
+	copied := *payment
+	f.payments[payment.ID] = &copied
+	return nil
+}
+
+func (f *fakePaymentStore) CompleteIntent(_ context.Context, intentID string, payment *models.Payment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.payments[intentID]; !ok {
+		return errors.New("record not found")
+	}
+	delete(f.payments, intentID)
+	copied := *payment
+	f.payments[payment.ID] = &copied
+	return nil
+}
+
+func (f *fakePaymentStore) CreateRefund(_ context.Context, refund *models.Refund) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refunds = append(f.refunds, refund)
+	return nil
+}
+
+func (f *fakePaymentStore) ListRefundsByPayment(_ context.Context, paymentID string) ([]*models.Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var refunds []*models.Refund
+	for _, refund := range f.refunds {
+		if refund.PaymentID == paymentID {
+			refunds = append(refunds, refund)
+		}
+	}
+	return refunds, nil
+}
+
+func (f *fakePaymentStore) Update(_ context.Context, payment *models.Payment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if stored, ok := f.payments[payment.ID]; ok && stored.Version != payment.Version {
+		return ErrConcurrentModification
+	}
+	payment.Version++
+	copied := *payment
+	f.payments[payment.ID] = &copied
+	return nil
+}
+
+type captureCall struct {
+	amount int64
+	final  bool
+}
+
+type fakeCaptureProvider struct {
+	providers.PaymentProvider
+	name               string
+	multiCapture       bool
+	partialReversal    bool
+	overcapturePercent int
+	captures           []captureCall
+	reversals          []int64
+}
+
+func (f *fakeCaptureProvider) Name() string { return f.name }
+
+func (f *fakeCaptureProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeCaptureProvider) Charge(_ context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+	return &models.ChargeResponse{
+		ID:               "pay_1",
+		Amount:           req.Amount,
+		Currency:         req.Currency,
+		Status:           models.PaymentStatusRequiresCapture,
+		ProviderName:     f.name,
+		ProviderChargeID: "pay_1",
+	}, nil
+}
+
+func (f *fakeCaptureProvider) Capabilities() providers.ProviderCapabilities {
+	return providers.ProviderCapabilities{
+		SupportsManualCapture:   true,
+		SupportsMultiCapture:    f.multiCapture,
+		SupportsPartialReversal: f.partialReversal,
+		SupportsOvercapture:     f.overcapturePercent > 0,
+		MaxOvercapturePercent:   f.overcapturePercent,
+	}
+}
+
+func (f *fakeCaptureProvider) CapturePayment(_ context.Context, _ string, amount int64) error {
+	f.captures = append(f.captures, captureCall{amount: amount, final: true})
+	return nil
+}
+
+func (f *fakeCaptureProvider) CapturePartial(_ context.Context, _ string, amount int64, final bool) error {
+	f.captures = append(f.captures, captureCall{amount: amount, final: final})
+	return nil
+}
+
+func (f *fakeCaptureProvider) Refund(_ context.Context, req *models.RefundRequest) (*models.RefundResponse, error) {
+	return &models.RefundResponse{PaymentID: req.PaymentID, Amount: req.Amount, Status: "succeeded", ProviderName: f.name}, nil
+}
+
+func (f *fakeCaptureProvider) ReverseAuthorization(_ context.Context, _ string, amount int64) error {
+	f.reversals = append(f.reversals, amount)
+	return nil
+}
+
+func TestMultiCaptureSucceedsOnceAuthorizedAmountIsCaptured(t *testing.T) {
+	provider := &fakeCaptureProvider{name: "stripe", multiCapture: true}
+	store := &fakePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", Amount: 1000, Status: models.PaymentStatusRequiresCapture, ProviderName: "stripe", ProviderChargeID: "pi_1"},
+	}}
+	svc := CreatePaymentService(store, provider)
+	ctx := context.Background()
+
+	resp, err := svc.Capture(ctx, &models.CaptureRequest{PaymentID: "pay_1", Amount: 400})
+	if err != nil {
+		t.Fatalf("first capture: %v", err)
+	}
+	if resp.Status != models.PaymentStatusRequiresCapture || resp.CapturedAmount != 400 {
+		t.Fatalf("expected payment to stay capturable after a partial capture, got %+v", resp)
+	}
+
+	if _, err := svc.Capture(ctx, &models.CaptureRequest{PaymentID: "pay_1", Amount: 700}); !errors.Is(err, ErrInvalidCaptureAmount) {
+		t.Fatalf("expected over-capture to be rejected, got %v", err)
+	}
+
+	resp, err = svc.Capture(ctx, &models.CaptureRequest{PaymentID: "pay_1", Amount: 600})
+	if err != nil {
+		t.Fatalf("second capture: %v", err)
+	}
+	if resp.Status != models.PaymentStatusSuccess || store.payments["pay_1"].CapturedAmount != 1000 {
+		t.Fatalf("expected fully captured payment, got %+v", store.payments["pay_1"])
+	}
+
+	want := []captureCall{{400, false}, {600, true}}
+	if len(provider.captures) != len(want) || provider.captures[0] != want[0] || provider.captures[1] != want[1] {
+		t.Fatalf("expected provider captures %v, got %v", want, provider.captures)
+	}
+
+	if _, err := svc.Capture(ctx, &models.CaptureRequest{PaymentID: "pay_1"}); !errors.Is(err, ErrPaymentAlreadyCaptured) {
+		t.Fatalf("expected captured payment to be rejected, got %v", err)
+	}
+}
+
+func TestMultiCaptureThroughProviderSelector(t *testing.T) {
+	provider := &fakeCaptureProvider{name: "stripe", multiCapture: true}
+	selector := providers.CreateMultiProviderSelectorWithConfig([]providers.PaymentProvider{provider}, nil, providers.MultiProviderConfig{})
+	store := &fakePaymentStore{payments: map[string]*models.Payment{}}
+	svc := CreatePaymentService(store, selector)
+	ctx := context.Background()
+
+	if _, err := svc.CreateCharge(ctx, &models.ChargeRequest{Amount: 1000, Currency: "USD", PaymentMethod: "pm_card", CaptureMethod: models.CaptureMethodManual}); err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if stored := store.payments["pay_1"]; stored == nil || stored.ProviderName != "stripe" {
+		t.Fatalf("expected the payment to record the provider that took the charge, got %+v", stored)
+	}
+
+	resp, err := svc.Capture(ctx, &models.CaptureRequest{PaymentID: "pay_1", Amount: 400})
+	if err != nil {
+		t.Fatalf("first capture: %v", err)
+	}
+	if resp.Status != models.PaymentStatusRequiresCapture || resp.CapturedAmount != 400 {
+		t.Fatalf("expected payment to stay capturable after a partial capture, got %+v", resp)
+	}
+	if len(provider.captures) != 1 || provider.captures[0] != (captureCall{400, false}) {
+		t.Fatalf("expected a non-final provider capture of 400, got %v", provider.captures)
+	}
+}
+
+func TestRefundsOfCapturedAmountFullyRefundPayment(t *testing.T) {
+	provider := &fakeCaptureProvider{name: "stripe", multiCapture: true}
+	store := &fakePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", Amount: 1000, Status: models.PaymentStatusRequiresCapture, ProviderName: "stripe", ProviderChargeID: "pi_1"},
+	}}
+	svc := CreatePaymentService(store, provider)
+	ctx := context.Background()
+
+	if _, err := svc.Capture(ctx, &models.CaptureRequest{PaymentID: "pay_1", Amount: 600, Final: true}); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+
+	if _, err := svc.CreateRefund(ctx, &models.RefundRequest{PaymentID: "pay_1", Amount: 300}); err != nil {
+		t.Fatalf("first refund: %v", err)
+	}
+	if status := store.payments["pay_1"].Status; status != models.PaymentStatusPartiallyRefunded {
+		t.Fatalf("expected half the captured amount refunded to be partial, got %s", status)
+	}
+
+	if _, err := svc.CreateRefund(ctx, &models.RefundRequest{PaymentID: "pay_1", Amount: 300}); err != nil {
+		t.Fatalf("second refund: %v", err)
+	}
+	if status := store.payments["pay_1"].Status; status != models.PaymentStatusRefunded {
+		t.Fatalf("expected refunds totalling the 600 captured to fully refund the payment, got %s", status)
+	}
+}
+
+func TestPartialCaptureFinalizesWithoutMultiCapture(t *testing.T) {
+	provider := &fakeCaptureProvider{name: "razorpay"}
+	store := &fakePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", Amount: 1000, Status: models.PaymentStatusRequiresCapture, ProviderName: "razorpay", ProviderChargeID: "pi_1"},
+	}}
+	svc := CreatePaymentService(store, provider)
+
+	resp, err := svc.Capture(context.Background(), &models.CaptureRequest{PaymentID: "pay_1", Amount: 400})
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if resp.Status != models.PaymentStatusSuccess || store.payments["pay_1"].CapturedAmount != 400 {
+		t.Fatalf("expected single capture to finalize the payment, got %+v", store.payments["pay_1"])
+	}
+}
+
+func TestOvercaptureWithinProviderLimit(t *testing.T) {
+	provider := &fakeCaptureProvider{name: "stripe", overcapturePercent: 20}
+	store := &fakePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", Amount: 1000, Status: models.PaymentStatusRequiresCapture, ProviderName: "stripe", ProviderChargeID: "pi_1"},
+	}}
+	svc := CreatePaymentService(store, provider)
+
+	resp, err := svc.Capture(context.Background(), &models.CaptureRequest{PaymentID: "pay_1", Amount: 1150})
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if resp.Status != models.PaymentStatusSuccess || resp.CapturedAmount != 1150 || resp.OvercapturedAmount != 150 {
+		t.Fatalf("expected a 15%% overcapture to succeed, got %+v", resp)
+	}
+	if stored := store.payments["pay_1"]; stored.CapturedAmount != 1150 || stored.OvercapturedAmount != 150 {
+		t.Fatalf("expected the overcaptured amount to be recorded, got %+v", stored)
+	}
+	if len(provider.captures) != 1 || provider.captures[0].amount != 1150 {
+		t.Fatalf("expected one provider capture of 1150, got %v", provider.captures)
+	}
+}
+
+func TestOvercaptureRejectedBeyondLimitOrWithoutSupport(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider *fakeCaptureProvider
+		amount   int64
+	}{
+		{"beyond the provider's limit", &fakeCaptureProvider{name: "stripe", overcapturePercent: 20}, 1250},
+		{"provider without overcapture", &fakeCaptureProvider{name: "razorpay"}, 1001},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakePaymentStore{payments: map[string]*models.Payment{
+				"pay_1": {ID: "pay_1", Amount: 1000, Status: models.PaymentStatusRequiresCapture, ProviderName: tt.provider.name, ProviderChargeID: "pi_1"},
+			}}
+			svc := CreatePaymentService(store, tt.provider)
+
+			_, err := svc.Capture(context.Background(), &models.CaptureRequest{PaymentID: "pay_1", Amount: tt.amount})
+			if !errors.Is(err, ErrInvalidCaptureAmount) {
+				t.Fatalf("expected ErrInvalidCaptureAmount, got %v", err)
+			}
+			if len(tt.provider.captures) != 0 {
+				t.Fatalf("expected no provider call, got %v", tt.provider.captures)
+			}
+			if stored := store.payments["pay_1"]; stored.Status != models.PaymentStatusRequiresCapture || stored.CapturedAmount != 0 {
+				t.Fatalf("expected the payment to stay capturable, got %+v", stored)
+			}
+		})
+	}
+}
+
+func TestPartialReversalReducesCapturableAmount(t *testing.T) {
+	provider := &fakeCaptureProvider{name: "stripe", multiCapture: true, partialReversal: true}
+	store := &fakePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", Amount: 1000, Status: models.PaymentStatusRequiresCapture, ProviderName: "stripe", ProviderChargeID: "pi_1"},
+	}}
+	svc := CreatePaymentService(store, provider)
+	ctx := context.Background()
+
+	if _, err := svc.Capture(ctx, &models.CaptureRequest{PaymentID: "pay_1", Amount: 200}); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+
+	if _, err := svc.ReverseAuthorization(ctx, &models.ReverseAuthorizationRequest{PaymentID: "pay_1", Amount: 800}); !errors.Is(err, ErrInvalidReversalAmount) {
+		t.Fatalf("expected reversing the whole remainder to be rejected, got %v", err)
+	}
+
+	resp, err := svc.ReverseAuthorization(ctx, &models.ReverseAuthorizationRequest{PaymentID: "pay_1", Amount: 300})
+	if err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+	if resp.AuthorizedAmount != 700 || resp.CapturedAmount != 200 || resp.Status != models.PaymentStatusRequiresCapture {
+		t.Fatalf("unexpected reversal: %+v", resp)
+	}
+	if stored := store.payments["pay_1"]; stored.Amount != 700 || stored.Status != models.PaymentStatusRequiresCapture {
+		t.Fatalf("expected the stored authorization to drop to 700, got %+v", stored)
+	}
+	if len(provider.reversals) != 1 || provider.reversals[0] != 300 {
+		t.Fatalf("expected one provider reversal of 300, got %v", provider.reversals)
+	}
+
+	if _, err := svc.Capture(ctx, &models.CaptureRequest{PaymentID: "pay_1", Amount: 600}); !errors.Is(err, ErrInvalidCaptureAmount) {
+		t.Fatalf("expected capturing the released amount to be rejected, got %v", err)
+	}
+	resp2, err := svc.Capture(ctx, &models.CaptureRequest{PaymentID: "pay_1"})
+	if err != nil {
+		t.Fatalf("capture remainder: %v", err)
+	}
+	if resp2.Amount != 500 || resp2.Status != models.PaymentStatusSuccess {
+		t.Fatalf("expected the remaining 500 to be captured, got %+v", resp2)
+	}
+}
+
+func TestPartialReversalRequiresProviderSupport(t *testing.T) {
+	provider := &fakeCaptureProvider{name: "razorpay"}
+	store := &fakePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", Amount: 1000, Status: models.PaymentStatusRequiresCapture, ProviderName: "razorpay", ProviderChargeID: "pi_1"},
+	}}
+	svc := CreatePaymentService(store, provider)
+
+	_, err := svc.ReverseAuthorization(context.Background(), &models.ReverseAuthorizationRequest{PaymentID: "pay_1", Amount: 100})
+	if !errors.Is(err, ErrCapabilityUnsupported) {
+		t.Fatalf("expected ErrCapabilityUnsupported, got %v", err)
+	}
+	if len(provider.reversals) != 0 {
+		t.Fatalf("expected no provider call, got %v", provider.reversals)
+	}
+}
+
+type fakeCaptureVoidProvider struct {
+	providers.PaymentProvider
+	captures atomic.Int64
+	voids    atomic.Int64
+}
+
+func (f *fakeCaptureVoidProvider) Name() string { return "stripe" }
+
+func (f *fakeCaptureVoidProvider) Capabilities() providers.ProviderCapabilities {
+	return providers.ProviderCapabilities{SupportsManualCapture: true}
+}
+
+func (f *fakeCaptureVoidProvider) CapturePayment(context.Context, string, int64) error {
+	f.captures.Add(1)
+	return nil
+}
+
+func (f *fakeCaptureVoidProvider) VoidPayment(context.Context, string) error {
+	f.voids.Add(1)
+	return nil
+}
+
+func TestConcurrentCaptureAndVoidActOnce(t *testing.T) {
+	provider := &fakeCaptureVoidProvider{}
+	reads := &sync.WaitGroup{}
+	reads.Add(2)
+	store := &fakePaymentStore{reads: reads, payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", Amount: 1000, Status: models.PaymentStatusRequiresCapture, ProviderName: "stripe", ProviderChargeID: "pi_1"},
+	}}
+	svc := CreatePaymentService(store, provider)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, errs[0] = svc.Capture(ctx, &models.CaptureRequest{PaymentID: "pay_1"})
+	}()
+	go func() {
+		defer wg.Done()
+		_, errs[1] = svc.Void(ctx, &models.VoidRequest{PaymentID: "pay_1"})
+	}()
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else if !errors.Is(err, ErrConcurrentModification) {
+			t.Fatalf("expected the losing call to fail with ErrConcurrentModification, got %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("expected exactly one of capture and void to succeed, got errors %v", errs)
+	}
+	if calls := provider.captures.Load() + provider.voids.Load(); calls != 1 {
+		t.Fatalf("expected the provider to be called once, got %d", calls)
+	}
+}
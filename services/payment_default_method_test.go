@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"gorm.io/gorm"
+)
+
+type fakeDefaultPaymentMethods map[string]*models.PaymentMethod
+
+func (f fakeDefaultPaymentMethods) GetDefault(_ context.Context, customerID string) (*models.PaymentMethod, error) {
+	pm, ok := f[customerID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoDefaultPaymentMethod, customerID)
+	}
+	return pm, nil
+}
+
+type fakeCustomers map[string]*models.Customer
+
+func (f fakeCustomers) GetByID(_ context.Context, id string) (*models.Customer, error) {
+	customer, ok := f[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return customer, nil
+}
+
+func TestCreateChargeUsesCustomerDefaultPaymentMethod(t *testing.T) {
+	store := &fakeChargeStore{}
+	svc := CreatePaymentService(store, &fakeChargeProvider{})
+	svc.SetDefaultPaymentMethods(fakeDefaultPaymentMethods{
+		"cus_1": {ID: "b6c1", CustomerID: "cus_1", ProviderPaymentMethodID: "pm_default", IsDefault: true},
+	}, nil)
+
+	if _, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID: "cus_1",
+		Amount:     1000,
+		Currency:   "USD",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(store.payments) != 1 || store.payments[0].PaymentMethod != "pm_default" {
+		t.Fatalf("expected the charge to use the default payment method, got %+v", store.payments)
+	}
+}
+
+func TestCreateChargeWithoutDefaultPaymentMethodFails(t *testing.T) {
+	store := &fakeChargeStore{}
+	provider := &fakeChargeProvider{}
+	svc := CreatePaymentService(store, provider)
+	svc.SetDefaultPaymentMethods(fakeDefaultPaymentMethods{}, nil)
+
+	_, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID: "cus_none",
+		Amount:     1000,
+		Currency:   "USD",
+	})
+	if !errors.Is(err, ErrNoDefaultPaymentMethod) {
+		t.Fatalf("expected ErrNoDefaultPaymentMethod, got %v", err)
+	}
+	if provider.charges.Load() != 0 || len(store.payments) != 0 {
+		t.Fatal("expected no charge to be attempted without a payment method")
+	}
+}
+
+func TestCreateChargeRejectsAnotherTenantsCustomer(t *testing.T) {
+	store := &fakeChargeStore{}
+	provider := &fakeChargeProvider{}
+	svc := CreatePaymentService(store, provider)
+	tenantA, tenantB := "ten_a", "ten_b"
+	svc.SetDefaultPaymentMethods(fakeDefaultPaymentMethods{
+		"cus_a": {ID: "b6c1", CustomerID: "cus_a", ProviderPaymentMethodID: "pm_a", IsDefault: true},
+		"cus_b": {ID: "b6c2", CustomerID: "cus_b", ProviderPaymentMethodID: "pm_b", IsDefault: true},
+	}, fakeCustomers{
+		"cus_a": {ID: "cus_a", TenantID: &tenantA},
+		"cus_b": {ID: "cus_b", TenantID: &tenantB},
+	})
+	ctx := context.WithValue(context.Background(), ctxkeys.TenantID, tenantA)
+
+	_, err := svc.CreateCharge(ctx, &models.ChargeRequest{CustomerID: "cus_b", Amount: 1000, Currency: "USD"})
+	if !errors.Is(err, ErrCustomerNotFound) {
+		t.Fatalf("expected another tenant's customer to be not found, got %v", err)
+	}
+	if provider.charges.Load() != 0 || len(store.payments) != 0 {
+		t.Fatal("expected no charge against another tenant's saved payment method")
+	}
+
+	if _, err := svc.CreateCharge(ctx, &models.ChargeRequest{CustomerID: "cus_a", Amount: 1000, Currency: "USD"}); err != nil {
+		t.Fatalf("expected the tenant's own customer to be charged, got %v", err)
+	}
+	if len(store.payments) != 1 || store.payments[0].PaymentMethod != "pm_a" {
+		t.Fatalf("expected the charge to use the tenant's own default method, got %+v", store.payments)
+	}
+}
+
+func TestCreateChargeKeepsExplicitPaymentMethod(t *testing.T) {
+	store := &fakeChargeStore{}
+	svc := CreatePaymentService(store, &fakeChargeProvider{})
+	svc.SetDefaultPaymentMethods(fakeDefaultPaymentMethods{
+		"cus_1": {ID: "b6c1", CustomerID: "cus_1", ProviderPaymentMethodID: "pm_default", IsDefault: true},
+	}, nil)
+
+	if _, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID:    "cus_1",
+		Amount:        1000,
+		Currency:      "USD",
+		PaymentMethod: "pm_explicit",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if store.payments[0].PaymentMethod != "pm_explicit" {
+		t.Fatalf("expected the explicit payment method to win, got %q", store.payments[0].PaymentMethod)
+	}
+}
+
+func TestCreateChargeRejectsDefaultMethodTypeProviderCannotTake(t *testing.T) {
+	store := &fakeChargeStore{}
+	svc := CreatePaymentService(store, providers.CreateStripeProvider("sk_test_123"))
+	svc.SetDefaultPaymentMethods(fakeDefaultPaymentMethods{
+		"cus_1": {ID: "b6c1", CustomerID: "cus_1", ProviderPaymentMethodID: "vpa_default", Type: models.PMTypeUPI, IsDefault: true},
+	}, nil)
+
+	_, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID: "cus_1",
+		Amount:     1000,
+		Currency:   "INR",
+	})
+	if !errors.Is(err, providers.ErrPaymentMethodUnsupported) {
+		t.Fatalf("expected ErrPaymentMethodUnsupported for a upi default on stripe, got %v", err)
+	}
+	if len(store.payments) != 0 {
+		t.Fatal("expected no payment to be stored")
+	}
+}
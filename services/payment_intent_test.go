@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type fakeDecliningProvider struct {
+	providers.PaymentProvider
+}
+
+func (f *fakeDecliningProvider) Name() string                     { return "stripe" }
+func (f *fakeDecliningProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeDecliningProvider) Charge(context.Context, *models.ChargeRequest) (*models.ChargeResponse, error) {
+	return nil, errors.New("card declined")
+}
+
+func TestCreateChargeReplacesIntentWithProviderPayment(t *testing.T) {
+	store := &fakeChargeStore{}
+	svc := CreatePaymentService(store, &fakeChargeProvider{})
+
+	resp, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID:    "cus_1",
+		Amount:        1000,
+		Currency:      "USD",
+		PaymentMethod: "pm_card_visa",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.payments) != 1 {
+		t.Fatalf("expected the intent to be replaced by one payment, got %d rows", len(store.payments))
+	}
+	if got := store.payments[0]; got.ID != resp.ID || got.Status != models.PaymentStatusSuccess {
+		t.Fatalf("expected the stored payment to match the charge, got %+v", got)
+	}
+}
+
+func TestCreateChargeLeavesPendingIntentWhenRecordFails(t *testing.T) {
+	store := &fakeChargeStore{completeErr: errors.New("connection reset")}
+	provider := &fakeChargeProvider{}
+	svc := CreatePaymentService(store, provider)
+
+	_, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID:     "cus_1",
+		Amount:         1000,
+		Currency:       "USD",
+		PaymentMethod:  "pm_card_visa",
+		IdempotencyKey: "order-1",
+	})
+	if err == nil {
+		t.Fatal("expected an error when the payment cannot be recorded")
+	}
+	if provider.charges.Load() != 1 {
+		t.Fatalf("expected the provider to be charged once, got %d", provider.charges.Load())
+	}
+	if len(store.payments) != 1 {
+		t.Fatalf("expected a recoverable intent row, got %d rows", len(store.payments))
+	}
+	intent := store.payments[0]
+	if intent.Status != models.PaymentStatusPending || intent.IdempotencyKey != "order-1" || intent.Amount != 1000 {
+		t.Fatalf("expected a pending intent carrying the request, got %+v", intent)
+	}
+	if intent.ProviderChargeID != "ch_1" {
+		t.Fatalf("expected the intent to keep the provider charge ID for reconciliation, got %q", intent.ProviderChargeID)
+	}
+}
+
+func TestUnrecordedChargeSettlesIdempotencyKey(t *testing.T) {
+	store := &fakeChargeStore{completeErr: errors.New("connection reset")}
+	provider := &fakeChargeProvider{}
+	keys := &fakeIdempotencyRepo{keys: map[string]*models.IdempotencyResult{}}
+	svc := CreatePaymentServiceFull(store, keys, nil, provider, nil)
+	req := models.ChargeRequest{
+		CustomerID:     "cus_1",
+		Amount:         1000,
+		Currency:       "USD",
+		PaymentMethod:  "pm_card_visa",
+		IdempotencyKey: "order-1",
+	}
+
+	first := req
+	if _, err := svc.CreateCharge(context.Background(), &first); err == nil {
+		t.Fatal("expected an error when the payment cannot be recorded")
+	}
+	if key := keys.keys["order-1"]; key.ResponseCode != 500 {
+		t.Fatalf("expected the idempotency key to be completed, got response code %d", key.ResponseCode)
+	}
+
+	retry := req
+	resp, err := svc.CreateCharge(context.Background(), &retry)
+	if err != nil {
+		t.Fatalf("expected the retry to replay the recorded result, got %v", err)
+	}
+	if resp.ProviderChargeID != "ch_1" || provider.charges.Load() != 1 {
+		t.Fatalf("expected the retry to replay charge ch_1 without charging again, got %q after %d charges", resp.ProviderChargeID, provider.charges.Load())
+	}
+}
+
+func TestCreateChargeMarksIntentFailedWhenProviderFails(t *testing.T) {
+	store := &fakeChargeStore{}
+	svc := CreatePaymentService(store, &fakeDecliningProvider{})
+
+	if _, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID:    "cus_1",
+		Amount:        1000,
+		Currency:      "USD",
+		PaymentMethod: "pm_card_visa",
+	}); err == nil {
+		t.Fatal("expected the declined charge to fail")
+	}
+	if len(store.payments) != 1 || store.payments[0].Status != models.PaymentStatusFailed {
+		t.Fatalf("expected the intent to be marked failed, got %+v", store.payments)
+	}
+}
@@ -2,10 +2,19 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/stores"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidMicrodepositAmounts = errors.New("exactly two positive micro-deposit amounts are required")
+	ErrPaymentMethodNotFound      = errors.New("payment method not found")
+	ErrNoDefaultPaymentMethod     = errors.New("customer has no default payment method")
 )
 
 type PaymentMethodService struct {
@@ -22,7 +31,9 @@ func CreatePaymentMethodService(paymentMethodStore *stores.PaymentMethodStore, p
 
 func (s *PaymentMethodService) CreatePaymentMethod(ctx context.Context, req *models.CreatePaymentMethodRequest) (*models.PaymentMethod, error) {
 	if pmProvider, ok := s.provider.(providers.PaymentMethodProvider); ok {
-		pm, err := pmProvider.CreatePaymentMethod(ctx, req)
+		pm, err := callProvider(ctx, "create_payment_method", func(ctx context.Context) (*models.PaymentMethod, error) {
+			return pmProvider.CreatePaymentMethod(ctx, req)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -40,35 +51,106 @@ func (s *PaymentMethodService) CreatePaymentMethod(ctx context.Context, req *mod
 
 func (s *PaymentMethodService) GetPaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethod, error) {
 	if pmProvider, ok := s.provider.(providers.PaymentMethodProvider); ok {
-		return pmProvider.GetPaymentMethod(ctx, paymentMethodID)
+		return callProvider(ctx, "get_payment_method", func(ctx context.Context) (*models.PaymentMethod, error) {
+			return pmProvider.GetPaymentMethod(ctx, paymentMethodID)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
 
 func (s *PaymentMethodService) ListPaymentMethods(ctx context.Context, customerID string, pmType *models.PaymentMethodType) ([]*models.PaymentMethod, error) {
 	if pmProvider, ok := s.provider.(providers.PaymentMethodProvider); ok {
-		return pmProvider.ListPaymentMethods(ctx, customerID, pmType)
+		return callProvider(ctx, "list_payment_methods", func(ctx context.Context) ([]*models.PaymentMethod, error) {
+			return pmProvider.ListPaymentMethods(ctx, customerID, pmType)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
 
+// GetDefault returns the payment method the customer marked as default.
+func (s *PaymentMethodService) GetDefault(ctx context.Context, customerID string) (*models.PaymentMethod, error) {
+	if s.paymentMethodStore == nil {
+		return nil, ErrNoDefaultPaymentMethod
+	}
+	pm, err := s.paymentMethodStore.GetDefault(ctx, customerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNoDefaultPaymentMethod, customerID)
+		}
+		return nil, err
+	}
+	return pm, nil
+}
+
+// SetDefault makes the stored payment method its customer's default,
+// clearing the flag on the customer's other methods.
+func (s *PaymentMethodService) SetDefault(ctx context.Context, paymentMethodID string) (*models.PaymentMethod, error) {
+	if s.paymentMethodStore == nil {
+		return nil, ErrPaymentMethodNotFound
+	}
+	pm, err := s.paymentMethodStore.GetByID(ctx, paymentMethodID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentMethodNotFound
+		}
+		return nil, err
+	}
+	if err := s.paymentMethodStore.SetDefault(ctx, pm.CustomerID, pm.ID); err != nil {
+		return nil, err
+	}
+	pm.IsDefault = true
+	return pm, nil
+}
+
 func (s *PaymentMethodService) AttachPaymentMethod(ctx context.Context, paymentMethodID, customerID string) error {
 	if pmProvider, ok := s.provider.(providers.PaymentMethodProvider); ok {
-		return pmProvider.AttachPaymentMethod(ctx, paymentMethodID, customerID)
+		return callProviderErr(ctx, "attach_payment_method", func(ctx context.Context) error {
+			return pmProvider.AttachPaymentMethod(ctx, paymentMethodID, customerID)
+		})
 	}
 	return providers.ErrNotSupported
 }
 
 func (s *PaymentMethodService) DetachPaymentMethod(ctx context.Context, paymentMethodID string) error {
 	if pmProvider, ok := s.provider.(providers.PaymentMethodProvider); ok {
-		return pmProvider.DetachPaymentMethod(ctx, paymentMethodID)
+		return callProviderErr(ctx, "detach_payment_method", func(ctx context.Context) error {
+			return pmProvider.DetachPaymentMethod(ctx, paymentMethodID)
+		})
 	}
 	return providers.ErrNotSupported
 }
 
 func (s *PaymentMethodService) ExpirePaymentMethod(ctx context.Context, paymentMethodID string) (*models.PaymentMethod, error) {
 	if pmProvider, ok := s.provider.(providers.PaymentMethodProvider); ok {
-		return pmProvider.ExpirePaymentMethod(ctx, paymentMethodID)
+		return callProvider(ctx, "expire_payment_method", func(ctx context.Context) (*models.PaymentMethod, error) {
+			return pmProvider.ExpirePaymentMethod(ctx, paymentMethodID)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
+
+func (s *PaymentMethodService) VerifyPaymentMethod(ctx context.Context, paymentMethodID string, amounts []int64) (*models.PaymentMethod, error) {
+	if len(amounts) != 2 || amounts[0] <= 0 || amounts[1] <= 0 {
+		return nil, ErrInvalidMicrodepositAmounts
+	}
+
+	verifier, ok := s.provider.(providers.PaymentMethodVerifier)
+	if !ok {
+		return nil, providers.ErrNotSupported
+	}
+
+	pm, err := callProvider(ctx, "verify_payment_method", func(ctx context.Context) (*models.PaymentMethod, error) {
+		return verifier.VerifyPaymentMethod(ctx, paymentMethodID, amounts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.paymentMethodStore != nil {
+		if err := s.paymentMethodStore.UpdateStatusByProviderID(ctx, pm.ProviderName, pm.ProviderPaymentMethodID, pm.Status); err != nil {
+			return nil, err
+		}
+	}
+
+	return pm, nil
+}
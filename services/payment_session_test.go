@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"gorm.io/gorm"
+)
+
+// fakeSessionProvider confirms sessions that save their payment method
+// off-session, holding the method unattached until AttachPaymentMethod.
+type fakeSessionProvider struct {
+	providers.PaymentProvider
+	session  *models.PaymentSession
+	pm       *models.PaymentMethod
+	attached []string
+}
+
+func (f *fakeSessionProvider) Name() string                     { return "stripe" }
+func (f *fakeSessionProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeSessionProvider) CreatePaymentSession(context.Context, *models.CreatePaymentSessionRequest) (*models.PaymentSession, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakeSessionProvider) GetPaymentSession(context.Context, string) (*models.PaymentSession, error) {
+	copied := *f.session
+	return &copied, nil
+}
+
+func (f *fakeSessionProvider) UpdatePaymentSession(context.Context, string, *models.UpdatePaymentSessionRequest) (*models.PaymentSession, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakeSessionProvider) ConfirmPaymentSession(_ context.Context, _ string, req *models.ConfirmPaymentSessionRequest) (*models.PaymentSession, error) {
+	f.session.Status = models.PaymentStatusSuccess
+	f.session.PaymentMethodID = req.PaymentMethodID
+	copied := *f.session
+	return &copied, nil
+}
+
+func (f *fakeSessionProvider) CapturePaymentSession(context.Context, string, *int64) (*models.PaymentSession, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakeSessionProvider) CancelPaymentSession(context.Context, string) (*models.PaymentSession, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakeSessionProvider) ListPaymentSessions(context.Context, *models.ListPaymentSessionsRequest) ([]*models.PaymentSession, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakeSessionProvider) CreatePaymentMethod(context.Context, *models.CreatePaymentMethodRequest) (*models.PaymentMethod, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakeSessionProvider) GetPaymentMethod(context.Context, string) (*models.PaymentMethod, error) {
+	copied := *f.pm
+	return &copied, nil
+}
+
+func (f *fakeSessionProvider) ListPaymentMethods(context.Context, string, *models.PaymentMethodType) ([]*models.PaymentMethod, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakeSessionProvider) AttachPaymentMethod(_ context.Context, paymentMethodID, customerID string) error {
+	f.attached = append(f.attached, paymentMethodID+":"+customerID)
+	f.pm.CustomerID = customerID
+	return nil
+}
+
+func (f *fakeSessionProvider) DetachPaymentMethod(context.Context, string) error {
+	return providers.ErrNotSupported
+}
+
+func (f *fakeSessionProvider) ExpirePaymentMethod(context.Context, string) (*models.PaymentMethod, error) {
+	return nil, providers.ErrNotSupported
+}
+
+type fakeSessionMethodStore struct {
+	methods []*models.PaymentMethod
+}
+
+func (f *fakeSessionMethodStore) GetByProviderID(_ context.Context, providerName, providerPaymentMethodID string) (*models.PaymentMethod, error) {
+	for _, pm := range f.methods {
+		if pm.ProviderName == providerName && pm.ProviderPaymentMethodID == providerPaymentMethodID {
+			return pm, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeSessionMethodStore) Create(_ context.Context, pm *models.PaymentMethod) error {
+	pm.ID = "pm_local_1"
+	f.methods = append(f.methods, pm)
+	return nil
+}
+
+func TestOffSessionPaymentSessionSavesAttachedPaymentMethod(t *testing.T) {
+	provider := &fakeSessionProvider{
+		session: &models.PaymentSession{
+			ProviderID:       "pi_1",
+			ProviderName:     "stripe",
+			Amount:           2000,
+			Currency:         "usd",
+			Status:           models.PaymentStatusRequiresAction,
+			CustomerID:       "cus_1",
+			SetupFutureUsage: "off_session",
+		},
+		pm: &models.PaymentMethod{
+			ProviderName:            "stripe",
+			ProviderPaymentMethodID: "pm_card_1",
+			Type:                    models.PMTypeCard,
+			Last4:                   "4242",
+		},
+	}
+	store := &fakeSessionMethodStore{}
+	svc := CreatePaymentService(&fakeChargeStore{}, provider)
+	svc.SetSessionPaymentMethods(store)
+
+	session, err := svc.ConfirmPaymentSession(context.Background(), "pi_1", &models.ConfirmPaymentSessionRequest{PaymentMethodID: "pm_card_1"})
+	if err != nil {
+		t.Fatalf("confirm payment session: %v", err)
+	}
+
+	if len(provider.attached) != 1 || provider.attached[0] != "pm_card_1:cus_1" {
+		t.Fatalf("expected pm_card_1 to be attached to cus_1, got %v", provider.attached)
+	}
+	if len(store.methods) != 1 {
+		t.Fatalf("expected the payment method to be stored, got %d", len(store.methods))
+	}
+	saved := store.methods[0]
+	if saved.CustomerID != "cus_1" || saved.PaymentSessionID != "pi_1" || !saved.Reusable || saved.Last4 != "4242" {
+		t.Fatalf("unexpected stored payment method: %+v", saved)
+	}
+	if session.SavedPaymentMethodID != "pm_local_1" {
+		t.Fatalf("expected the session to link the saved method, got %q", session.SavedPaymentMethodID)
+	}
+
+	again, err := svc.GetPaymentSession(context.Background(), "pi_1")
+	if err != nil {
+		t.Fatalf("get payment session: %v", err)
+	}
+	if len(store.methods) != 1 || len(provider.attached) != 1 {
+		t.Fatal("expected reading the session again not to save the method twice")
+	}
+	if again.SavedPaymentMethodID != "pm_local_1" {
+		t.Fatalf("expected the reread session to link the saved method, got %q", again.SavedPaymentMethodID)
+	}
+}
+
+func TestPaymentSessionWithoutSetupFutureUsageSavesNothing(t *testing.T) {
+	provider := &fakeSessionProvider{
+		session: &models.PaymentSession{ProviderID: "pi_2", ProviderName: "stripe", CustomerID: "cus_1"},
+		pm:      &models.PaymentMethod{ProviderName: "stripe", ProviderPaymentMethodID: "pm_card_2"},
+	}
+	store := &fakeSessionMethodStore{}
+	svc := CreatePaymentService(&fakeChargeStore{}, provider)
+	svc.SetSessionPaymentMethods(store)
+
+	if _, err := svc.ConfirmPaymentSession(context.Background(), "pi_2", &models.ConfirmPaymentSessionRequest{PaymentMethodID: "pm_card_2"}); err != nil {
+		t.Fatalf("confirm payment session: %v", err)
+	}
+	if len(store.methods) != 0 || len(provider.attached) != 0 {
+		t.Fatal("expected a one-off session not to save its payment method")
+	}
+}
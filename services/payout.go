@@ -2,52 +2,251 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/providers"
 )
 
+var (
+	ErrInvalidFXQuoteRequest  = errors.New("fx quote needs two currencies and a positive amount")
+	ErrInsufficientBalance    = errors.New("insufficient available balance for payout")
+	ErrInvalidPayoutChannel   = errors.New("invalid payout destination")
+	ErrPayoutAmountOutOfRange = errors.New("payout amount is outside the channel's limits")
+)
+
 type PayoutService struct {
-	provider providers.PaymentProvider
+	provider  providers.PaymentProvider
+	reserves  map[string]int64
+	schedules PayoutScheduleRepository
+	now       func() time.Time
 }
 
 func CreatePayoutService(provider providers.PaymentProvider) *PayoutService {
 	return &PayoutService{
 		provider: provider,
+		now:      time.Now,
+	}
+}
+
+// SetBalanceReserves sets, per currency in minor units, how much available
+// balance must remain after a payout. Currencies not listed keep no reserve.
+func (s *PayoutService) SetBalanceReserves(reserves map[string]int64) {
+	s.reserves = make(map[string]int64, len(reserves))
+	for currency, amount := range reserves {
+		s.reserves[strings.ToUpper(currency)] = amount
 	}
 }
 
 func (s *PayoutService) CreatePayout(ctx context.Context, req *models.CreatePayoutRequest) (*models.Payout, error) {
-	if payoutProvider, ok := s.provider.(providers.PayoutProvider); ok {
+	if err := requireCapabilityForCurrency(ctx, s.provider, req.Currency, providers.CapabilityPayouts); err != nil {
+		return nil, err
+	}
+
+	payoutProvider, ok := s.provider.(providers.PayoutProvider)
+	if !ok {
+		return nil, providers.ErrNotSupported
+	}
+
+	if err := s.validateDestination(ctx, payoutProvider, req); err != nil {
+		return nil, err
+	}
+
+	var quote *models.FXQuote
+	if req.SourceCurrency != "" && !strings.EqualFold(req.SourceCurrency, req.Currency) {
+		var err error
+		quote, err = s.GetFXQuote(ctx, req.SourceCurrency, req.Currency, req.Amount)
+		if err != nil {
+			return nil, err
+		}
+		req.FXQuoteID = quote.ID
+	}
+
+	debitCurrency, debitAmount := req.Currency, req.Amount
+	if quote != nil {
+		debitCurrency, debitAmount = quote.SourceCurrency, quote.SourceAmount
+	}
+	if err := s.checkBalance(ctx, debitCurrency, debitAmount); err != nil {
+		return nil, err
+	}
+
+	payout, err := callProvider(ctx, "create_payout", func(ctx context.Context) (*models.Payout, error) {
 		return payoutProvider.CreatePayout(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if quote != nil {
+		payout.SourceCurrency = quote.SourceCurrency
+		payout.SourceAmount = quote.SourceAmount
+		payout.FXRate = quote.Rate
+		payout.FXQuoteID = quote.ID
+	}
+	return payout, nil
+}
+
+// validateDestination checks the destination channel and amount against the
+// channels the provider offers for the payout currency, so a payout the
+// provider would refuse fails here with a clear reason. Providers that do not
+// list channels are not checked.
+func (s *PayoutService) validateDestination(ctx context.Context, payoutProvider providers.PayoutProvider, req *models.CreatePayoutRequest) error {
+	if req.DestinationAccount == "" {
+		return fmt.Errorf("%w: destination_account is required", ErrInvalidPayoutChannel)
+	}
+
+	channels, err := callProvider(ctx, "get_payout_channels", func(ctx context.Context) ([]*models.PayoutChannel, error) {
+		return payoutProvider.GetPayoutChannels(ctx, req.Currency)
+	})
+	if errors.Is(err, providers.ErrNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load payout channels: %w", err)
+	}
+
+	var available []*models.PayoutChannel
+	for _, channel := range channels {
+		if channel.Currency == "" || strings.EqualFold(channel.Currency, req.Currency) {
+			available = append(available, channel)
+		}
+	}
+	currency := strings.ToUpper(req.Currency)
+	if len(available) == 0 {
+		return fmt.Errorf("%w: no payout channels are available for %s", ErrInvalidPayoutChannel, currency)
+	}
+
+	if req.DestinationChannel == "" {
+		// Without a channel the payout can go out on any of them, so only
+		// an amount below every channel's minimum is certain to fail.
+		lowest := available[0].MinAmount
+		for _, channel := range available[1:] {
+			lowest = min(lowest, channel.MinAmount)
+		}
+		if float64(req.Amount) < lowest {
+			return fmt.Errorf("%w: %d %s is below the provider minimum of %g", ErrPayoutAmountOutOfRange, req.Amount, currency, lowest)
+		}
+		return nil
+	}
+
+	codes := make([]string, len(available))
+	for i, channel := range available {
+		codes[i] = channel.Code
+		if !strings.EqualFold(channel.Code, req.DestinationChannel) {
+			continue
+		}
+		if channel.MinAmount > 0 && float64(req.Amount) < channel.MinAmount {
+			return fmt.Errorf("%w: %d %s is below the %s minimum of %g", ErrPayoutAmountOutOfRange, req.Amount, currency, channel.Code, channel.MinAmount)
+		}
+		if channel.MaxAmount > 0 && float64(req.Amount) > channel.MaxAmount {
+			return fmt.Errorf("%w: %d %s is above the %s maximum of %g", ErrPayoutAmountOutOfRange, req.Amount, currency, channel.Code, channel.MaxAmount)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: channel %s is not available for %s payouts; use one of %s",
+		ErrInvalidPayoutChannel, req.DestinationChannel, currency, strings.Join(codes, ", "))
+}
+
+// checkBalance rejects a payout that would leave less than the currency's
+// reserve available. Providers that do not report balances are not checked.
+func (s *PayoutService) checkBalance(ctx context.Context, currency string, amount int64) error {
+	if requireCapabilityForCurrency(ctx, s.provider, currency, providers.CapabilityBalance) != nil {
+		return nil
+	}
+	balance, err := s.fetchBalance(ctx, currency)
+	if errors.Is(err, providers.ErrNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check balance before payout: %w", err)
+	}
+
+	reserve := s.reserve(currency)
+	if balance.Available < amount+reserve {
+		return fmt.Errorf("%w: %d %s available, payout needs %d plus a reserve of %d",
+			ErrInsufficientBalance, balance.Available, strings.ToUpper(currency), amount, reserve)
+	}
+	return nil
+}
+
+func (s *PayoutService) fetchBalance(ctx context.Context, currency string) (*models.Balance, error) {
+	balanceProvider, ok := s.provider.(providers.BalanceProvider)
+	if !ok {
+		return nil, providers.ErrNotSupported
+	}
+	return callProvider(ctx, "get_balance", func(ctx context.Context) (*models.Balance, error) {
+		return balanceProvider.GetBalance(ctx, currency)
+	})
+}
+
+func (s *PayoutService) reserve(currency string) int64 {
+	return s.reserves[strings.ToUpper(currency)]
+}
+
+// GetFXQuote prices delivering amount of to, funded from a balance in from.
+func (s *PayoutService) GetFXQuote(ctx context.Context, from, to string, amount int64) (*models.FXQuote, error) {
+	if from == "" || to == "" || amount <= 0 {
+		return nil, ErrInvalidFXQuoteRequest
+	}
+
+	if fxProvider, ok := s.provider.(providers.FXProvider); ok {
+		return callProvider(ctx, "get_fx_quote", func(ctx context.Context) (*models.FXQuote, error) {
+			return fxProvider.GetFXQuote(ctx, strings.ToUpper(from), strings.ToUpper(to), amount)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
 
 func (s *PayoutService) GetPayout(ctx context.Context, payoutID string) (*models.Payout, error) {
+	if err := requireCapabilityForEntity(ctx, s.provider, payoutID, "payout", providers.CapabilityPayouts); err != nil {
+		return nil, err
+	}
+
 	if payoutProvider, ok := s.provider.(providers.PayoutProvider); ok {
-		return payoutProvider.GetPayout(ctx, payoutID)
+		return callProvider(ctx, "get_payout", func(ctx context.Context) (*models.Payout, error) {
+			return payoutProvider.GetPayout(ctx, payoutID)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
 
 func (s *PayoutService) ListPayouts(ctx context.Context, req *models.ListPayoutsRequest) ([]*models.Payout, error) {
+	if err := requireCapability(s.provider, providers.CapabilityPayouts); err != nil {
+		return nil, err
+	}
+
 	if payoutProvider, ok := s.provider.(providers.PayoutProvider); ok {
-		return payoutProvider.ListPayouts(ctx, req)
+		return callProvider(ctx, "list_payouts", func(ctx context.Context) ([]*models.Payout, error) {
+			return payoutProvider.ListPayouts(ctx, req)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
 
 func (s *PayoutService) CancelPayout(ctx context.Context, payoutID string) (*models.Payout, error) {
+	if err := requireCapabilityForEntity(ctx, s.provider, payoutID, "payout", providers.CapabilityPayouts); err != nil {
+		return nil, err
+	}
+
 	if payoutProvider, ok := s.provider.(providers.PayoutProvider); ok {
-		return payoutProvider.CancelPayout(ctx, payoutID)
+		return callProvider(ctx, "cancel_payout", func(ctx context.Context) (*models.Payout, error) {
+			return payoutProvider.CancelPayout(ctx, payoutID)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
 
 func (s *PayoutService) GetPayoutChannels(ctx context.Context, currency string) ([]*models.PayoutChannel, error) {
+	if err := requireCapabilityForCurrency(ctx, s.provider, currency, providers.CapabilityPayouts); err != nil {
+		return nil, err
+	}
+
 	if payoutProvider, ok := s.provider.(providers.PayoutProvider); ok {
-		return payoutProvider.GetPayoutChannels(ctx, currency)
+		return callProvider(ctx, "get_payout_channels", func(ctx context.Context) ([]*models.PayoutChannel, error) {
+			return payoutProvider.GetPayoutChannels(ctx, currency)
+		})
 	}
 	return nil, providers.ErrNotSupported
 }
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"gorm.io/gorm"
+)
+
+// payoutScheduleRunLimit caps how many runs ListPayoutScheduleRuns returns.
+const payoutScheduleRunLimit = 100
+
+var (
+	ErrPayoutScheduleNotFound       = errors.New("payout schedule not found")
+	ErrInvalidPayoutSchedule        = errors.New("invalid payout schedule")
+	ErrPayoutScheduleTenantRequired = errors.New("tenant is required to list payout schedules")
+)
+
+type PayoutScheduleRepository interface {
+	Create(ctx context.Context, schedule *models.PayoutSchedule) error
+	GetByID(ctx context.Context, id string) (*models.PayoutSchedule, error)
+	ListByTenant(ctx context.Context, tenantID string) ([]*models.PayoutSchedule, error)
+	Update(ctx context.Context, schedule *models.PayoutSchedule) error
+	Delete(ctx context.Context, id string) error
+	ClaimDue(ctx context.Context, now time.Time, limit int) ([]*models.PayoutSchedule, error)
+	CreateRun(ctx context.Context, run *models.PayoutScheduleRun) error
+	ListRuns(ctx context.Context, scheduleID string, limit int) ([]*models.PayoutScheduleRun, error)
+	SettledBalance(ctx context.Context, tenantID, currency string) (int64, error)
+}
+
+// SetPayoutSchedules enables payout schedules and the job that runs them.
+func (s *PayoutService) SetPayoutSchedules(repo PayoutScheduleRepository) {
+	s.schedules = repo
+}
+
+func (s *PayoutService) CreatePayoutSchedule(ctx context.Context, req *models.CreatePayoutScheduleRequest) (*models.PayoutSchedule, error) {
+	if s.schedules == nil {
+		return nil, errors.New("payout schedules are not enabled")
+	}
+
+	schedule := &models.PayoutSchedule{
+		Currency:           strings.ToUpper(req.Currency),
+		MinAmount:          req.MinAmount,
+		Frequency:          req.Frequency,
+		DestinationType:    req.DestinationType,
+		DestinationAccount: req.DestinationAccount,
+		DestinationName:    req.DestinationName,
+		DestinationBank:    req.DestinationBank,
+		DestinationChannel: req.DestinationChannel,
+		Description:        req.Description,
+		Active:             true,
+	}
+	if req.StartAt != nil {
+		schedule.NextRunAt = *req.StartAt
+	} else {
+		schedule.NextRunAt = req.Frequency.Next(s.now())
+	}
+	if err := validatePayoutSchedule(schedule); err != nil {
+		return nil, err
+	}
+	if err := requireCapabilityForCurrency(ctx, s.provider, schedule.Currency, providers.CapabilityPayouts); err != nil {
+		return nil, err
+	}
+
+	if tid, ok := ctx.Value(ctxkeys.TenantID).(string); ok && tid != "" {
+		schedule.TenantID = &tid
+	}
+	if err := s.schedules.Create(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *PayoutService) GetPayoutSchedule(ctx context.Context, id string) (*models.PayoutSchedule, error) {
+	if s.schedules == nil {
+		return nil, ErrPayoutScheduleNotFound
+	}
+	schedule, err := s.schedules.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPayoutScheduleNotFound
+		}
+		return nil, err
+	}
+	if !payoutScheduleVisible(ctx, schedule) {
+		return nil, ErrPayoutScheduleNotFound
+	}
+	return schedule, nil
+}
+
+func (s *PayoutService) ListPayoutSchedules(ctx context.Context) ([]*models.PayoutSchedule, error) {
+	tenantID, _ := ctx.Value(ctxkeys.TenantID).(string)
+	if tenantID == "" {
+		return nil, ErrPayoutScheduleTenantRequired
+	}
+	if s.schedules == nil {
+		return []*models.PayoutSchedule{}, nil
+	}
+	return s.schedules.ListByTenant(ctx, tenantID)
+}
+
+func (s *PayoutService) UpdatePayoutSchedule(ctx context.Context, id string, req *models.UpdatePayoutScheduleRequest) (*models.PayoutSchedule, error) {
+	schedule, err := s.GetPayoutSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.MinAmount != nil {
+		schedule.MinAmount = *req.MinAmount
+	}
+	if req.Frequency != nil {
+		schedule.Frequency = *req.Frequency
+	}
+	if req.DestinationType != nil {
+		schedule.DestinationType = *req.DestinationType
+	}
+	if req.DestinationAccount != nil {
+		schedule.DestinationAccount = *req.DestinationAccount
+	}
+	if req.DestinationName != nil {
+		schedule.DestinationName = *req.DestinationName
+	}
+	if req.DestinationBank != nil {
+		schedule.DestinationBank = *req.DestinationBank
+	}
+	if req.DestinationChannel != nil {
+		schedule.DestinationChannel = *req.DestinationChannel
+	}
+	if req.Description != nil {
+		schedule.Description = *req.Description
+	}
+	if req.Active != nil {
+		schedule.Active = *req.Active
+	}
+	if req.NextRunAt != nil {
+		schedule.NextRunAt = *req.NextRunAt
+	}
+	if err := validatePayoutSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.schedules.Update(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *PayoutService) DeletePayoutSchedule(ctx context.Context, id string) error {
+	if _, err := s.GetPayoutSchedule(ctx, id); err != nil {
+		return err
+	}
+	return s.schedules.Delete(ctx, id)
+}
+
+func (s *PayoutService) ListPayoutScheduleRuns(ctx context.Context, id string) ([]*models.PayoutScheduleRun, error) {
+	if _, err := s.GetPayoutSchedule(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.schedules.ListRuns(ctx, id, payoutScheduleRunLimit)
+}
+
+// ExecuteDuePayoutSchedules runs every active schedule whose next run has
+// passed and records the outcome of each. It returns how many were run.
+func (s *PayoutService) ExecuteDuePayoutSchedules(ctx context.Context, batchSize int) (int, error) {
+	if s.schedules == nil {
+		return 0, nil
+	}
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+
+	now := s.now()
+	due, err := s.schedules.ClaimDue(ctx, now, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for _, schedule := range due {
+		if err := s.runPayoutSchedule(ctx, schedule, now); err != nil {
+			errs = append(errs, fmt.Errorf("payout schedule %s: %w", schedule.ID, err))
+		}
+	}
+	return len(due), errors.Join(errs...)
+}
+
+// runPayoutSchedule pays out the available balance above the currency's
+// reserve, or skips the run when that is below the schedule's minimum. The
+// provider balance is shared by every tenant, so a tenant's schedule pays
+// out no more than that tenant has settled and not yet been paid. A failed
+// balance read or payout is recorded on the run rather than returned; only
+// failing to save the run is an error.
+func (s *PayoutService) runPayoutSchedule(ctx context.Context, schedule *models.PayoutSchedule, ranAt time.Time) error {
+	if schedule.TenantID != nil {
+		ctx = context.WithValue(ctx, ctxkeys.TenantID, *schedule.TenantID)
+	}
+
+	run := &models.PayoutScheduleRun{
+		ScheduleID: schedule.ID,
+		TenantID:   schedule.TenantID,
+		Currency:   schedule.Currency,
+		RanAt:      ranAt,
+	}
+
+	balance, err := s.scheduledPayoutBalance(ctx, schedule.Currency)
+	if err != nil {
+		run.Status = models.PayoutScheduleRunFailed
+		run.Reason = fmt.Sprintf("failed to read available balance: %v", err)
+		return s.schedules.CreateRun(ctx, run)
+	}
+	run.AvailableBalance = balance.Available
+
+	amount := balance.Available - s.reserve(schedule.Currency)
+	if schedule.TenantID != nil {
+		settled, err := s.schedules.SettledBalance(ctx, *schedule.TenantID, schedule.Currency)
+		if err != nil {
+			run.Status = models.PayoutScheduleRunFailed
+			run.Reason = fmt.Sprintf("failed to read the tenant's settled balance: %v", err)
+			return s.schedules.CreateRun(ctx, run)
+		}
+		run.AvailableBalance = min(run.AvailableBalance, settled)
+		amount = min(amount, settled)
+	}
+	if amount <= 0 || amount < schedule.MinAmount {
+		run.Status = models.PayoutScheduleRunSkipped
+		run.Reason = fmt.Sprintf("%d %s available above the reserve, below the minimum of %d",
+			max(amount, 0), schedule.Currency, schedule.MinAmount)
+		return s.schedules.CreateRun(ctx, run)
+	}
+
+	run.Amount = amount
+	payout, err := s.CreatePayout(ctx, &models.CreatePayoutRequest{
+		ReferenceID:        fmt.Sprintf("payout_schedule_%s_%d", schedule.ID, ranAt.Unix()),
+		Amount:             amount,
+		Currency:           schedule.Currency,
+		Description:        schedule.Description,
+		DestinationType:    schedule.DestinationType,
+		DestinationAccount: schedule.DestinationAccount,
+		DestinationName:    schedule.DestinationName,
+		DestinationBank:    schedule.DestinationBank,
+		DestinationChannel: schedule.DestinationChannel,
+	})
+	if err != nil {
+		run.Status = models.PayoutScheduleRunFailed
+		run.Reason = err.Error()
+		return s.schedules.CreateRun(ctx, run)
+	}
+
+	run.Status = models.PayoutScheduleRunPaidOut
+	run.PayoutID = payout.ID
+	if run.PayoutID == "" {
+		run.PayoutID = payout.ProviderID
+	}
+	return s.schedules.CreateRun(ctx, run)
+}
+
+func (s *PayoutService) scheduledPayoutBalance(ctx context.Context, currency string) (*models.Balance, error) {
+	if err := requireCapabilityForCurrency(ctx, s.provider, currency, providers.CapabilityBalance); err != nil {
+		return nil, err
+	}
+	return s.fetchBalance(ctx, currency)
+}
+
+func validatePayoutSchedule(schedule *models.PayoutSchedule) error {
+	switch {
+	case schedule.Currency == "":
+		return fmt.Errorf("%w: currency is required", ErrInvalidPayoutSchedule)
+	case schedule.MinAmount < 0:
+		return fmt.Errorf("%w: min_amount cannot be negative", ErrInvalidPayoutSchedule)
+	case !schedule.Frequency.Valid():
+		return fmt.Errorf("%w: frequency must be daily, weekly or monthly", ErrInvalidPayoutSchedule)
+	case schedule.DestinationAccount == "":
+		return fmt.Errorf("%w: destination_account is required", ErrInvalidPayoutSchedule)
+	case schedule.DestinationType == "":
+		return fmt.Errorf("%w: destination_type is required", ErrInvalidPayoutSchedule)
+	}
+	return nil
+}
+
+// payoutScheduleVisible hides other tenants' schedules from a tenant-scoped
+// caller.
+func payoutScheduleVisible(ctx context.Context, schedule *models.PayoutSchedule) bool {
+	tenantID, _ := ctx.Value(ctxkeys.TenantID).(string)
+	if tenantID == "" {
+		return true
+	}
+	return schedule.TenantID != nil && *schedule.TenantID == tenantID
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type fakePayoutScheduleRepo struct {
+	schedules []*models.PayoutSchedule
+	runs      []*models.PayoutScheduleRun
+	// settled is each tenant's settled funds before scheduled payouts.
+	settled map[string]int64
+}
+
+func (f *fakePayoutScheduleRepo) Create(_ context.Context, schedule *models.PayoutSchedule) error {
+	schedule.ID = fmt.Sprintf("ps_%d", len(f.schedules)+1)
+	f.schedules = append(f.schedules, schedule)
+	return nil
+}
+
+func (f *fakePayoutScheduleRepo) GetByID(_ context.Context, id string) (*models.PayoutSchedule, error) {
+	for _, schedule := range f.schedules {
+		if schedule.ID == id {
+			return schedule, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakePayoutScheduleRepo) ListByTenant(_ context.Context, tenantID string) ([]*models.PayoutSchedule, error) {
+	var out []*models.PayoutSchedule
+	for _, schedule := range f.schedules {
+		if schedule.TenantID != nil && *schedule.TenantID == tenantID {
+			out = append(out, schedule)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakePayoutScheduleRepo) Update(context.Context, *models.PayoutSchedule) error {
+	return nil
+}
+
+func (f *fakePayoutScheduleRepo) Delete(_ context.Context, id string) error {
+	for i, schedule := range f.schedules {
+		if schedule.ID == id {
+			f.schedules = append(f.schedules[:i], f.schedules[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakePayoutScheduleRepo) ClaimDue(_ context.Context, now time.Time, limit int) ([]*models.PayoutSchedule, error) {
+	var due []*models.PayoutSchedule
+	for _, schedule := range f.schedules {
+		if len(due) == limit {
+			break
+		}
+		if schedule.Active && !schedule.NextRunAt.After(now) {
+			for !schedule.NextRunAt.After(now) {
+				schedule.NextRunAt = schedule.Frequency.Next(schedule.NextRunAt)
+			}
+			schedule.LastRunAt = &now
+			due = append(due, schedule)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakePayoutScheduleRepo) CreateRun(_ context.Context, run *models.PayoutScheduleRun) error {
+	f.runs = append(f.runs, run)
+	return nil
+}
+
+func (f *fakePayoutScheduleRepo) ListRuns(_ context.Context, scheduleID string, _ int) ([]*models.PayoutScheduleRun, error) {
+	var out []*models.PayoutScheduleRun
+	for _, run := range f.runs {
+		if run.ScheduleID == scheduleID {
+			out = append(out, run)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakePayoutScheduleRepo) SettledBalance(_ context.Context, tenantID, currency string) (int64, error) {
+	balance := f.settled[tenantID]
+	for _, run := range f.runs {
+		if run.TenantID != nil && *run.TenantID == tenantID && run.Currency == currency && run.Status == models.PayoutScheduleRunPaidOut {
+			balance -= run.Amount
+		}
+	}
+	return balance, nil
+}
+
+func TestDailyPayoutScheduleSweepsBalanceAboveMinimum(t *testing.T) {
+	provider := &fakePayoutProvider{available: 50000}
+	repo := &fakePayoutScheduleRepo{}
+	svc := CreatePayoutService(provider)
+	svc.SetPayoutSchedules(repo)
+	clock := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return clock }
+
+	_, err := svc.CreatePayoutSchedule(context.Background(), &models.CreatePayoutScheduleRequest{
+		Currency:           "usd",
+		MinAmount:          10000,
+		Frequency:          models.PayoutScheduleDaily,
+		DestinationType:    models.DestinationBankAccount,
+		DestinationAccount: "ba_1",
+	})
+	if err != nil {
+		t.Fatalf("create payout schedule: %v", err)
+	}
+	svc.SetBalanceReserves(map[string]int64{"USD": 5000})
+
+	if ran, err := svc.ExecuteDuePayoutSchedules(context.Background(), 10); err != nil || ran != 0 {
+		t.Fatalf("expected nothing due before the first run, ran %d: %v", ran, err)
+	}
+
+	clock = clock.Add(24 * time.Hour)
+	ran, err := svc.ExecuteDuePayoutSchedules(context.Background(), 10)
+	if err != nil || ran != 1 {
+		t.Fatalf("expected one schedule to run, ran %d: %v", ran, err)
+	}
+
+	if len(provider.payouts) != 1 {
+		t.Fatalf("expected one payout, got %d", len(provider.payouts))
+	}
+	payout := provider.payouts[0]
+	if payout.Amount != 45000 || payout.Currency != "USD" || payout.DestinationAccount != "ba_1" {
+		t.Fatalf("expected the balance above the reserve to be paid to ba_1, got %+v", payout)
+	}
+
+	run := repo.runs[0]
+	if run.Status != models.PayoutScheduleRunPaidOut || run.Amount != 45000 || run.AvailableBalance != 50000 || run.PayoutID != "po_1" {
+		t.Fatalf("unexpected run record: %+v", run)
+	}
+	if next := repo.schedules[0].NextRunAt; !next.Equal(clock.Add(24 * time.Hour)) {
+		t.Fatalf("expected the next run a day later, got %s", next)
+	}
+}
+
+func TestDailyPayoutScheduleSkipsBalanceBelowMinimum(t *testing.T) {
+	provider := &fakePayoutProvider{available: 8000}
+	repo := &fakePayoutScheduleRepo{}
+	svc := CreatePayoutService(provider)
+	svc.SetPayoutSchedules(repo)
+	clock := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return clock }
+
+	_, err := svc.CreatePayoutSchedule(context.Background(), &models.CreatePayoutScheduleRequest{
+		Currency:           "usd",
+		MinAmount:          10000,
+		Frequency:          models.PayoutScheduleDaily,
+		DestinationType:    models.DestinationBankAccount,
+		DestinationAccount: "ba_1",
+	})
+	if err != nil {
+		t.Fatalf("create payout schedule: %v", err)
+	}
+
+	clock = clock.Add(24 * time.Hour)
+	if _, err := svc.ExecuteDuePayoutSchedules(context.Background(), 10); err != nil {
+		t.Fatalf("execute payout schedules: %v", err)
+	}
+
+	if len(provider.payouts) != 0 {
+		t.Fatal("expected no payout below the minimum")
+	}
+	if len(repo.runs) != 1 {
+		t.Fatalf("expected the skipped run to be recorded, got %d runs", len(repo.runs))
+	}
+	if run := repo.runs[0]; run.Status != models.PayoutScheduleRunSkipped || run.AvailableBalance != 8000 || run.Amount != 0 {
+		t.Fatalf("unexpected run record: %+v", run)
+	}
+}
+
+func TestTenantPayoutScheduleIsCappedBySettledFunds(t *testing.T) {
+	provider := &fakePayoutProvider{available: 50000}
+	repo := &fakePayoutScheduleRepo{settled: map[string]int64{"ten_a": 12000}}
+	svc := CreatePayoutService(provider)
+	svc.SetPayoutSchedules(repo)
+	clock := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return clock }
+
+	ctx := context.WithValue(context.Background(), ctxkeys.TenantID, "ten_a")
+	_, err := svc.CreatePayoutSchedule(ctx, &models.CreatePayoutScheduleRequest{
+		Currency:           "USD",
+		MinAmount:          1000,
+		Frequency:          models.PayoutScheduleDaily,
+		DestinationType:    models.DestinationBankAccount,
+		DestinationAccount: "ba_1",
+	})
+	if err != nil {
+		t.Fatalf("create payout schedule: %v", err)
+	}
+
+	clock = clock.Add(24 * time.Hour)
+	if _, err := svc.ExecuteDuePayoutSchedules(context.Background(), 10); err != nil {
+		t.Fatalf("execute payout schedules: %v", err)
+	}
+	if len(provider.payouts) != 1 || provider.payouts[0].Amount != 12000 {
+		t.Fatalf("expected the tenant's 12000 settled to be paid out, not the platform balance, got %+v", provider.payouts)
+	}
+
+	clock = clock.Add(24 * time.Hour)
+	if _, err := svc.ExecuteDuePayoutSchedules(context.Background(), 10); err != nil {
+		t.Fatalf("execute payout schedules: %v", err)
+	}
+	if len(provider.payouts) != 1 {
+		t.Fatalf("expected no second payout of funds already paid out, got %+v", provider.payouts)
+	}
+	if run := repo.runs[1]; run.Status != models.PayoutScheduleRunSkipped || run.AvailableBalance != 0 {
+		t.Fatalf("expected the second run to be skipped with nothing left, got %+v", run)
+	}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type rewriteHostTransport struct {
+	target *url.URL
+}
+
+func (t rewriteHostTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func newAirwallexFXServer(t *testing.T, transfer *map[string]interface{}) *providers.AirwallexProvider {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/authentication/login":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"token":"tok","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+		case "/api/v1/fx/quotes/create":
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["sell_currency"] != "USD" || body["buy_currency"] != "EUR" || body["buy_amount"] != 100.0 {
+				t.Errorf("unexpected quote request %v", body)
+			}
+			_, _ = w.Write([]byte(`{"quote_id":"quote_1","sell_currency":"USD","buy_currency":"EUR","sell_amount":125,"buy_amount":100,"client_rate":1.25,"valid_to_at":"2026-10-16T10:15:00Z"}`))
+		case "/api/v1/balances/current":
+			currency := r.URL.Query().Get("currency")
+			_, _ = w.Write([]byte(`{"available_amount":1000000,"pending_amount":0,"currency":"` + currency + `"}`))
+		case "/api/v1/transfers/create":
+			_ = json.NewDecoder(r.Body).Decode(transfer)
+			_, _ = w.Write([]byte(`{"id":"tfr_1","amount":100,"currency":"EUR","status":"PENDING","beneficiary_id":"ben_1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	p := providers.CreateAirwallexProvider("client", "key", true)
+	p.SetHTTPClient(&http.Client{Transport: rewriteHostTransport{target: target}})
+	return p
+}
+
+func TestCrossCurrencyPayoutStoresQuotedRate(t *testing.T) {
+	var transfer map[string]interface{}
+	svc := CreatePayoutService(newAirwallexFXServer(t, &transfer))
+
+	payout, err := svc.CreatePayout(context.Background(), &models.CreatePayoutRequest{
+		Amount:             10000,
+		Currency:           "EUR",
+		SourceCurrency:     "USD",
+		DestinationAccount: "ben_1",
+	})
+	if err != nil {
+		t.Fatalf("create payout: %v", err)
+	}
+
+	if payout.FXRate != 0.8 || payout.FXQuoteID != "quote_1" {
+		t.Fatalf("expected rate 0.8 from quote_1 on the payout, got %v from %q", payout.FXRate, payout.FXQuoteID)
+	}
+	if payout.SourceCurrency != "USD" || payout.SourceAmount != 12500 {
+		t.Fatalf("expected 12500 USD debited, got %d %s", payout.SourceAmount, payout.SourceCurrency)
+	}
+	if transfer["quote_id"] != "quote_1" || transfer["source_currency"] != "USD" {
+		t.Fatalf("expected the transfer to lock the quote, got %v", transfer)
+	}
+}
+
+func TestSameCurrencyPayoutSkipsQuote(t *testing.T) {
+	var transfer map[string]interface{}
+	svc := CreatePayoutService(newAirwallexFXServer(t, &transfer))
+
+	payout, err := svc.CreatePayout(context.Background(), &models.CreatePayoutRequest{
+		Amount:             10000,
+		Currency:           "EUR",
+		SourceCurrency:     "eur",
+		DestinationAccount: "ben_1",
+	})
+	if err != nil {
+		t.Fatalf("create payout: %v", err)
+	}
+	if payout.FXRate != 0 || payout.FXQuoteID != "" {
+		t.Fatalf("expected no FX on a same-currency payout, got %+v", payout)
+	}
+	if _, ok := transfer["quote_id"]; ok {
+		t.Fatalf("expected no quote on the transfer, got %v", transfer)
+	}
+}
+
+func TestFXQuoteRejectsMissingAmount(t *testing.T) {
+	svc := CreatePayoutService(&fakeXenditProvider{})
+
+	if _, err := svc.GetFXQuote(context.Background(), "USD", "EUR", 0); !errors.Is(err, ErrInvalidFXQuoteRequest) {
+		t.Fatalf("expected ErrInvalidFXQuoteRequest, got %v", err)
+	}
+	if _, err := svc.GetFXQuote(context.Background(), "USD", "EUR", 100); !errors.Is(err, providers.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported from a provider without FX, got %v", err)
+	}
+}
+
+// fakePayoutProvider pays out in USD from an available balance it reports.
+// It lists channels only when some are set.
+type fakePayoutProvider struct {
+	providers.PaymentProvider
+	available int64
+	channels  []*models.PayoutChannel
+	payouts   []*models.CreatePayoutRequest
+}
+
+func (f *fakePayoutProvider) Name() string                     { return "stripe" }
+func (f *fakePayoutProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakePayoutProvider) Capabilities() providers.ProviderCapabilities {
+	return providers.ProviderCapabilities{
+		SupportsPayouts:     true,
+		SupportsBalance:     true,
+		SupportedCurrencies: []string{"USD"},
+	}
+}
+
+func (f *fakePayoutProvider) GetBalance(_ context.Context, currency string) (*models.Balance, error) {
+	return &models.Balance{Available: f.available, Currency: currency, ProviderName: "stripe"}, nil
+}
+
+func (f *fakePayoutProvider) CreatePayout(_ context.Context, req *models.CreatePayoutRequest) (*models.Payout, error) {
+	f.payouts = append(f.payouts, req)
+	return &models.Payout{ProviderID: "po_1", ProviderName: "stripe", Amount: req.Amount, Currency: req.Currency, Status: models.PayoutStatusPending}, nil
+}
+
+func (f *fakePayoutProvider) GetPayout(context.Context, string) (*models.Payout, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakePayoutProvider) ListPayouts(context.Context, *models.ListPayoutsRequest) ([]*models.Payout, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakePayoutProvider) CancelPayout(context.Context, string) (*models.Payout, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakePayoutProvider) GetPayoutChannels(context.Context, string) ([]*models.PayoutChannel, error) {
+	if f.channels == nil {
+		return nil, providers.ErrNotSupported
+	}
+	return f.channels, nil
+}
+
+func TestPayoutExceedingAvailableBalanceIsRejected(t *testing.T) {
+	provider := &fakePayoutProvider{available: 50000}
+	svc := CreatePayoutService(provider)
+	svc.SetBalanceReserves(map[string]int64{"usd": 10000})
+
+	_, err := svc.CreatePayout(context.Background(), &models.CreatePayoutRequest{
+		Amount:             45000,
+		Currency:           "USD",
+		DestinationAccount: "ba_1",
+	})
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("expected ErrInsufficientBalance when the reserve would be touched, got %v", err)
+	}
+	if len(provider.payouts) != 0 {
+		t.Fatal("expected no payout to reach the provider")
+	}
+}
+
+func TestPayoutWithinAvailableBalanceSucceeds(t *testing.T) {
+	provider := &fakePayoutProvider{available: 50000}
+	svc := CreatePayoutService(provider)
+	svc.SetBalanceReserves(map[string]int64{"USD": 10000})
+
+	payout, err := svc.CreatePayout(context.Background(), &models.CreatePayoutRequest{
+		Amount:             40000,
+		Currency:           "USD",
+		DestinationAccount: "ba_1",
+	})
+	if err != nil {
+		t.Fatalf("create payout: %v", err)
+	}
+	if payout.Amount != 40000 || len(provider.payouts) != 1 {
+		t.Fatalf("expected the payout to be issued, got %+v", payout)
+	}
+}
+
+func newChannelPayoutProvider() *fakePayoutProvider {
+	return &fakePayoutProvider{
+		available: 1000000,
+		channels: []*models.PayoutChannel{
+			{Code: "bank_account", Currency: "USD", MinAmount: 100},
+			{Code: "card", Currency: "USD", MinAmount: 500, MaxAmount: 300000},
+			{Code: "NEFT", Currency: "INR"},
+		},
+	}
+}
+
+func TestPayoutWithChannelForAnotherCurrencyIsRejected(t *testing.T) {
+	provider := newChannelPayoutProvider()
+	svc := CreatePayoutService(provider)
+
+	_, err := svc.CreatePayout(context.Background(), &models.CreatePayoutRequest{
+		Amount:             10000,
+		Currency:           "USD",
+		DestinationAccount: "ba_1",
+		DestinationChannel: "NEFT",
+	})
+	if !errors.Is(err, ErrInvalidPayoutChannel) {
+		t.Fatalf("expected ErrInvalidPayoutChannel for NEFT on a USD payout, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "bank_account, card") {
+		t.Fatalf("expected the error to list the USD channels, got %q", err)
+	}
+	if len(provider.payouts) != 0 {
+		t.Fatal("expected no payout to reach the provider")
+	}
+}
+
+func TestPayoutBelowChannelMinimumIsRejected(t *testing.T) {
+	provider := newChannelPayoutProvider()
+	svc := CreatePayoutService(provider)
+
+	_, err := svc.CreatePayout(context.Background(), &models.CreatePayoutRequest{
+		Amount:             300,
+		Currency:           "USD",
+		DestinationAccount: "4242",
+		DestinationChannel: "card",
+	})
+	if !errors.Is(err, ErrPayoutAmountOutOfRange) {
+		t.Fatalf("expected ErrPayoutAmountOutOfRange below the card minimum, got %v", err)
+	}
+	if len(provider.payouts) != 0 {
+		t.Fatal("expected no payout to reach the provider")
+	}
+
+	if _, err := svc.CreatePayout(context.Background(), &models.CreatePayoutRequest{
+		Amount:             300,
+		Currency:           "USD",
+		DestinationAccount: "ba_1",
+		DestinationChannel: "bank_account",
+	}); err != nil {
+		t.Fatalf("expected the same amount to clear the bank_account minimum, got %v", err)
+	}
+}
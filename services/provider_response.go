@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/internal/redact"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"github.com/malwarebo/conductor/utils"
+)
+
+type ProviderResponseStore interface {
+	Create(ctx context.Context, response *models.ProviderResponse) error
+	ListByPayment(ctx context.Context, paymentID string) ([]*models.ProviderResponse, error)
+}
+
+// providerResponseRedactor strips client secrets and credentials that some
+// providers echo back before a raw body is stored.
+var providerResponseRedactor = redact.CreateRedactor(redact.DefaultPaths)
+
+// SetProviderResponseCapture stores raw provider responses for charges and
+// refunds in store. With captureAll off, only tenants whose settings enable
+// capture_provider_responses are captured.
+func (s *PaymentService) SetProviderResponseCapture(store ProviderResponseStore, captureAll bool) {
+	s.providerResponses = store
+	s.captureResponses = captureAll
+}
+
+func (s *PaymentService) captureEnabled(ctx context.Context) bool {
+	if s.providerResponses == nil {
+		return false
+	}
+	if s.captureResponses {
+		return true
+	}
+	tenant, ok := ctx.Value(ctxkeys.Tenant).(*models.Tenant)
+	if !ok || tenant == nil {
+		return false
+	}
+	enabled, _ := tenant.Settings[models.TenantSettingCaptureProviderResponses].(bool)
+	return enabled
+}
+
+// withResponseCapture returns the context to pass to the provider and the
+// recorder it fills, or a nil recorder when capture is off.
+func (s *PaymentService) withResponseCapture(ctx context.Context) (context.Context, *providers.RawResponseRecorder) {
+	if !s.captureEnabled(ctx) {
+		return ctx, nil
+	}
+	recorder := &providers.RawResponseRecorder{}
+	return providers.WithRawResponseRecorder(ctx, recorder), recorder
+}
+
+// saveProviderResponses persists what the recorder captured. Capture is a
+// debugging aid, so a failed write is logged rather than failing the payment.
+func (s *PaymentService) saveProviderResponses(ctx context.Context, paymentID string, tenantID *string, recorder *providers.RawResponseRecorder) {
+	if recorder == nil {
+		return
+	}
+
+	for _, raw := range recorder.Responses() {
+		response := &models.ProviderResponse{
+			PaymentID:  paymentID,
+			TenantID:   tenantID,
+			Provider:   raw.Provider,
+			Operation:  raw.Operation,
+			RawBody:    providerResponseRedactor.RedactBody(raw.Body),
+			ReceivedAt: raw.ReceivedAt,
+		}
+		if err := s.providerResponses.Create(ctx, response); err != nil {
+			utils.CreateLogger("conductor").Error(ctx, "Failed to store provider response", map[string]interface{}{
+				"payment_id": paymentID,
+				"provider":   raw.Provider,
+				"operation":  raw.Operation,
+				"error":      err.Error(),
+			})
+		}
+	}
+}
+
+// ListProviderResponses returns the raw responses captured for a payment,
+// oldest first. Payments outside the caller's tenant are reported as not
+// found.
+func (s *PaymentService) ListProviderResponses(ctx context.Context, paymentID string) (*models.ProviderResponseList, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	if tenantID, ok := ctx.Value(ctxkeys.TenantID).(string); ok && tenantID != "" {
+		if payment.TenantID == nil || *payment.TenantID != tenantID {
+			return nil, ErrPaymentNotFound
+		}
+	}
+
+	list := &models.ProviderResponseList{PaymentID: payment.ID, Responses: []*models.ProviderResponse{}}
+	if s.providerResponses == nil {
+		return list, nil
+	}
+
+	responses, err := s.providerResponses.ListByPayment(ctx, payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	list.Responses = append(list.Responses, responses...)
+	return list, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type fakeProviderResponseStore struct {
+	responses []*models.ProviderResponse
+}
+
+func (f *fakeProviderResponseStore) Create(_ context.Context, response *models.ProviderResponse) error {
+	f.responses = append(f.responses, response)
+	return nil
+}
+
+func (f *fakeProviderResponseStore) ListByPayment(_ context.Context, paymentID string) ([]*models.ProviderResponse, error) {
+	var matched []*models.ProviderResponse
+	for _, response := range f.responses {
+		if response.PaymentID == paymentID {
+			matched = append(matched, response)
+		}
+	}
+	return matched, nil
+}
+
+type rawRecordingProvider struct {
+	fakeChargeProvider
+}
+
+func (f *rawRecordingProvider) Charge(ctx context.Context, req *models.ChargeRequest) (*models.ChargeResponse, error) {
+	providers.RecordRawResponse(ctx, "stripe", "charge", []byte(`{"id":"pi_raw","status":"succeeded","client_secret":"pi_raw_secret_123"}`))
+	return f.fakeChargeProvider.Charge(ctx, req)
+}
+
+func TestProviderResponsesCapturedOnlyWhenEnabled(t *testing.T) {
+	tests := []struct {
+		name       string
+		captureAll bool
+		settings   map[string]interface{}
+		want       int
+	}{
+		{"capture off", false, nil, 0},
+		{"tenant opted out", false, map[string]interface{}{models.TenantSettingCaptureProviderResponses: false}, 0},
+		{"global flag", true, nil, 1},
+		{"tenant setting", false, map[string]interface{}{models.TenantSettingCaptureProviderResponses: true}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := &fakeProviderResponseStore{}
+			svc := CreatePaymentService(&fakeChargeStore{}, &rawRecordingProvider{})
+			svc.SetProviderResponseCapture(responses, tt.captureAll)
+
+			tenant := &models.Tenant{ID: "tenant_1", Settings: tt.settings}
+			ctx := context.WithValue(context.Background(), ctxkeys.TenantID, tenant.ID)
+			ctx = context.WithValue(ctx, ctxkeys.Tenant, tenant)
+
+			charge, err := svc.CreateCharge(ctx, &models.ChargeRequest{Amount: 1000, Currency: "USD", PaymentMethod: "pm_card_visa"})
+			if err != nil {
+				t.Fatalf("charge: %v", err)
+			}
+
+			if len(responses.responses) != tt.want {
+				t.Fatalf("expected %d captured responses, got %d", tt.want, len(responses.responses))
+			}
+			if tt.want == 0 {
+				return
+			}
+
+			captured := responses.responses[0]
+			if captured.PaymentID != charge.ID || captured.Provider != "stripe" || captured.Operation != "charge" {
+				t.Fatalf("unexpected captured response %+v", captured)
+			}
+			if captured.TenantID == nil || *captured.TenantID != tenant.ID {
+				t.Fatalf("expected response to be scoped to %s, got %v", tenant.ID, captured.TenantID)
+			}
+			if strings.Contains(captured.RawBody, "pi_raw_secret_123") || !strings.Contains(captured.RawBody, `"status":"succeeded"`) {
+				t.Fatalf("expected client secret to be redacted and the rest kept, got %s", captured.RawBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+
+	"github.com/malwarebo/conductor/providers"
+)
+
+var providerTimeouts = providers.OperationTimeouts{Default: providers.DefaultOperationTimeout}
+
+// SetProviderTimeouts sets how long services wait on each provider
+// operation. It is meant to be called once at startup; a zero Default keeps
+// providers.DefaultOperationTimeout.
+func SetProviderTimeouts(timeouts providers.OperationTimeouts) {
+	if timeouts.Default == 0 {
+		timeouts.Default = providers.DefaultOperationTimeout
+	}
+	providerTimeouts = timeouts
+}
+
+// callProvider runs one provider operation under its configured timeout,
+// returning an error wrapping providers.ErrProviderTimeout when it expires.
+func callProvider[T any](ctx context.Context, operation string, fn func(context.Context) (T, error)) (T, error) {
+	return providers.CallWithTimeout(ctx, providerTimeouts.For(operation), operation, fn)
+}
+
+func callProviderErr(ctx context.Context, operation string, fn func(context.Context) error) error {
+	_, err := callProvider(ctx, operation, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+var pendingPaymentStatuses = []models.PaymentStatus{
+	models.PaymentStatusPending,
+	models.PaymentStatusProcessing,
+	models.PaymentStatusRequiresAction,
+}
+
+type ReconciliationStore interface {
+	WithTransaction(ctx context.Context, fn func(context.Context) error) error
+	ClaimStalePending(ctx context.Context, statuses []models.PaymentStatus, updatedBefore time.Time, limit int) ([]*models.Payment, error)
+	UpdateStatusFrom(ctx context.Context, id string, from, status models.PaymentStatus) (bool, error)
+	ClaimExpiredAuthorizations(ctx context.Context, cutoffs map[string]time.Time, defaultCutoff time.Time, limit int) ([]*models.Payment, error)
+}
+
+type AuditLogWriter interface {
+	Create(ctx context.Context, log *models.AuditLog) error
+}
+
+type ReconciliationService struct {
+	store      ReconciliationStore
+	auditStore AuditLogWriter
+	provider   providers.PaymentProvider
+	authExpiry AuthorizationExpiryConfig
+	notifier   OutboundNotifier
+}
+
+func CreateReconciliationService(store ReconciliationStore, auditStore AuditLogWriter, provider providers.PaymentProvider) *ReconciliationService {
+	return &ReconciliationService{
+		store:      store,
+		auditStore: auditStore,
+		provider:   provider,
+		authExpiry: DefaultAuthorizationExpiryConfig(),
+	}
+}
+
+func (s *ReconciliationService) ReconcilePending(ctx context.Context, olderThan time.Duration, batchSize int) (*models.ReconciliationResult, error) {
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+
+	payments, err := s.store.ClaimStalePending(ctx, pendingPaymentStatuses, time.Now().Add(-olderThan), batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ReconciliationResult{}
+	for _, payment := range payments {
+		if payment.ProviderChargeID == "" {
+			// An intent the provider never confirmed has nothing to look
+			// up.
+			continue
+		}
+		result.Checked++
+
+		charge, err := callProvider(ctx, "get_charge", func(ctx context.Context) (*models.ChargeResponse, error) {
+			return s.provider.GetCharge(ctx, payment.ProviderChargeID)
+		})
+		if err != nil {
+			result.Failed++
+			continue
+		}
+
+		if charge.Status == "" || charge.Status == payment.Status {
+			continue
+		}
+
+		updated, err := s.applyStatus(ctx, payment, charge.Status)
+		if err != nil {
+			return result, fmt.Errorf("failed to update payment %s: %w", payment.ID, err)
+		}
+		if updated {
+			result.Updated++
+		}
+	}
+
+	return result, nil
+}
+
+// applyStatus moves the payment and writes its timeline entry together. A
+// payment whose status changed since it was claimed, for example by a
+// webhook, is left alone.
+func (s *ReconciliationService) applyStatus(ctx context.Context, payment *models.Payment, status models.PaymentStatus) (bool, error) {
+	var updated bool
+	err := s.store.WithTransaction(ctx, func(txCtx context.Context) error {
+		ok, err := s.store.UpdateStatusFrom(txCtx, payment.ID, payment.Status, status)
+		if err != nil || !ok {
+			return err
+		}
+		updated = true
+		return s.recordReconciliation(txCtx, payment, status)
+	})
+	return updated, err
+}
+
+func (s *ReconciliationService) recordReconciliation(ctx context.Context, payment *models.Payment, status models.PaymentStatus) error {
+	if s.auditStore == nil {
+		return nil
+	}
+
+	return s.auditStore.Create(ctx, &models.AuditLog{
+		TenantID:     payment.TenantID,
+		Action:       string(models.AuditActionReconcile),
+		ResourceType: string(models.AuditResourcePayment),
+		ResourceID:   payment.ID,
+		Success:      true,
+		Metadata: map[string]interface{}{
+			"provider":           payment.ProviderName,
+			"provider_charge_id": payment.ProviderChargeID,
+			"previous_status":    payment.Status,
+			"status":             status,
+		},
+	})
+}
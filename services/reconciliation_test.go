@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+type fakeReconciliationStore struct {
+	payments     []*models.Payment
+	updated      map[string]models.PaymentStatus
+	transactions int
+}
+
+func (f *fakeReconciliationStore) WithTransaction(ctx context.Context, fn func(context.Context) error) error {
+	f.transactions++
+	return fn(ctx)
+}
+
+func (f *fakeReconciliationStore) ClaimStalePending(_ context.Context, statuses []models.PaymentStatus, updatedBefore time.Time, limit int) ([]*models.Payment, error) {
+	var matched []*models.Payment
+	for _, p := range f.payments {
+		if len(matched) >= limit {
+			break
+		}
+		if p.UpdatedAt.After(updatedBefore) {
+			continue
+		}
+		for _, status := range statuses {
+			if p.Status == status {
+				matched = append(matched, p)
+				break
+			}
+		}
+	}
+	return matched, nil
+}
+
+func (f *fakeReconciliationStore) UpdateStatusFrom(_ context.Context, id string, from, status models.PaymentStatus) (bool, error) {
+	for _, p := range f.payments {
+		if p.ID == id && p.Status != from {
+			return false, nil
+		}
+	}
+	f.updated[id] = status
+	return true, nil
+}
+
+func (f *fakeReconciliationStore) ClaimExpiredAuthorizations(_ context.Context, cutoffs map[string]time.Time, defaultCutoff time.Time, limit int) ([]*models.Payment, error) {
+	var matched []*models.Payment
+	for _, p := range f.payments {
+		if len(matched) >= limit {
+			break
+		}
+		cutoff, ok := cutoffs[p.ProviderName]
+		if !ok {
+			cutoff = defaultCutoff
+		}
+		if p.Status == models.PaymentStatusRequiresCapture && !p.CreatedAt.After(cutoff) {
+			matched = append(matched, p)
+		}
+	}
+	return matched, nil
+}
+
+type fakeAuditWriter struct {
+	logs []*models.AuditLog
+}
+
+func (f *fakeAuditWriter) Create(_ context.Context, log *models.AuditLog) error {
+	f.logs = append(f.logs, log)
+	return nil
+}
+
+type fakeChargeStatusProvider struct {
+	providers.PaymentProvider
+	charges map[string]*models.ChargeResponse
+}
+
+func (f *fakeChargeStatusProvider) GetCharge(_ context.Context, providerChargeID string) (*models.ChargeResponse, error) {
+	charge, ok := f.charges[providerChargeID]
+	if !ok {
+		return nil, errors.New("charge not found")
+	}
+	return charge, nil
+}
+
+func TestReconcilePendingUpdatesProcessingPaymentToSucceeded(t *testing.T) {
+	store := &fakeReconciliationStore{
+		payments: []*models.Payment{
+			{ID: "pay_1", ProviderName: "stripe", ProviderChargeID: "pi_1", Status: models.PaymentStatusProcessing, UpdatedAt: time.Now().Add(-time.Hour)},
+			{ID: "pay_2", ProviderName: "stripe", ProviderChargeID: "pi_2", Status: models.PaymentStatusProcessing, UpdatedAt: time.Now()},
+		},
+		updated: make(map[string]models.PaymentStatus),
+	}
+	audit := &fakeAuditWriter{}
+	provider := &fakeChargeStatusProvider{
+		charges: map[string]*models.ChargeResponse{
+			"pi_1": {ID: "pi_1", Status: models.PaymentStatusSuccess},
+			"pi_2": {ID: "pi_2", Status: models.PaymentStatusSuccess},
+		},
+	}
+
+	svc := CreateReconciliationService(store, audit, provider)
+	result, err := svc.ReconcilePending(context.Background(), 10*time.Minute, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Checked != 1 || result.Updated != 1 || result.Failed != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if store.updated["pay_1"] != models.PaymentStatusSuccess {
+		t.Fatalf("expected pay_1 to be reconciled to succeeded, got %q", store.updated["pay_1"])
+	}
+	if _, ok := store.updated["pay_2"]; ok {
+		t.Fatal("expected recent payment pay_2 to be left alone")
+	}
+	if store.transactions != 1 {
+		t.Fatalf("expected one transaction for the status update, got %d", store.transactions)
+	}
+	if len(audit.logs) != 1 || audit.logs[0].ResourceID != "pay_1" || audit.logs[0].Action != string(models.AuditActionReconcile) {
+		t.Fatalf("expected a reconcile audit entry for pay_1, got %+v", audit.logs)
+	}
+}
+
+func TestReconcilePendingCountsProviderFailures(t *testing.T) {
+	store := &fakeReconciliationStore{
+		payments: []*models.Payment{
+			{ID: "pay_1", ProviderChargeID: "pi_missing", Status: models.PaymentStatusRequiresAction, UpdatedAt: time.Now().Add(-time.Hour)},
+		},
+		updated: make(map[string]models.PaymentStatus),
+	}
+	provider := &fakeChargeStatusProvider{charges: map[string]*models.ChargeResponse{}}
+
+	svc := CreateReconciliationService(store, nil, provider)
+	result, err := svc.ReconcilePending(context.Background(), 10*time.Minute, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Checked != 1 || result.Failed != 1 || result.Updated != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(store.updated) != 0 {
+		t.Fatalf("expected no status updates, got %v", store.updated)
+	}
+}
+
+func TestReconcilePendingSkipsIntentsWithoutProviderCharge(t *testing.T) {
+	store := &fakeReconciliationStore{
+		payments: []*models.Payment{
+			{ID: "intent_1", Status: models.PaymentStatusPending, UpdatedAt: time.Now().Add(-time.Hour)},
+		},
+		updated: make(map[string]models.PaymentStatus),
+	}
+	provider := &providerCallRecorder{store: store}
+
+	svc := CreateReconciliationService(store, nil, provider)
+	result, err := svc.ReconcilePending(context.Background(), 10*time.Minute, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.transactionsAtCall) != 0 {
+		t.Fatal("expected no provider lookup without a provider charge ID")
+	}
+	if result.Checked != 0 || result.Failed != 0 {
+		t.Fatalf("expected the intent to be skipped, got %+v", result)
+	}
+}
+
+type inTransactionAuditWriter struct {
+	store *fakeReconciliationStore
+	logs  []*models.AuditLog
+}
+
+func (f *inTransactionAuditWriter) Create(_ context.Context, log *models.AuditLog) error {
+	if f.store.transactions == 0 {
+		return errors.New("audit entry written outside the status transaction")
+	}
+	f.logs = append(f.logs, log)
+	return nil
+}
+
+type providerCallRecorder struct {
+	fakeChargeStatusProvider
+	store              *fakeReconciliationStore
+	transactionsAtCall []int
+}
+
+func (f *providerCallRecorder) GetCharge(ctx context.Context, providerChargeID string) (*models.ChargeResponse, error) {
+	f.transactionsAtCall = append(f.transactionsAtCall, f.store.transactions)
+	return f.fakeChargeStatusProvider.GetCharge(ctx, providerChargeID)
+}
+
+func TestReconcilePendingCallsProviderOutsideTransaction(t *testing.T) {
+	store := &fakeReconciliationStore{
+		payments: []*models.Payment{
+			{ID: "pay_1", ProviderChargeID: "pi_1", Status: models.PaymentStatusProcessing, UpdatedAt: time.Now().Add(-time.Hour)},
+		},
+		updated: make(map[string]models.PaymentStatus),
+	}
+	provider := &providerCallRecorder{
+		fakeChargeStatusProvider: fakeChargeStatusProvider{charges: map[string]*models.ChargeResponse{
+			"pi_1": {ID: "pi_1", Status: models.PaymentStatusFailed},
+		}},
+		store: store,
+	}
+	audit := &inTransactionAuditWriter{store: store}
+
+	svc := CreateReconciliationService(store, audit, provider)
+	if _, err := svc.ReconcilePending(context.Background(), 10*time.Minute, 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.transactionsAtCall) != 1 || provider.transactionsAtCall[0] != 0 {
+		t.Fatalf("expected the provider to be called before any transaction, got %v", provider.transactionsAtCall)
+	}
+	if len(audit.logs) != 1 {
+		t.Fatalf("expected a timeline entry inside the update transaction, got %d", len(audit.logs))
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+// scheduledPaymentStaleAfter is how long a scheduled payment may sit in
+// processing before another run picks it up again. The charge reuses the
+// same idempotency key, so a retry cannot charge twice.
+const scheduledPaymentStaleAfter = 15 * time.Minute
+
+var (
+	ErrScheduledPaymentNotFound      = errors.New("scheduled payment not found")
+	ErrScheduledPaymentNotCancelable = errors.New("scheduled payment has already run or been canceled")
+	ErrInvalidScheduledPayment       = errors.New("invalid scheduled payment request")
+)
+
+type ScheduledPaymentRepository interface {
+	Create(ctx context.Context, scheduled *models.ScheduledPayment) error
+	GetByID(ctx context.Context, id string) (*models.ScheduledPayment, error)
+	ListByTenant(ctx context.Context, tenantID string, filter models.ScheduledPaymentFilter) ([]*models.ScheduledPayment, error)
+	Update(ctx context.Context, scheduled *models.ScheduledPayment) error
+	ClaimDue(ctx context.Context, now, staleBefore time.Time, limit int) ([]*models.ScheduledPayment, error)
+	Cancel(ctx context.Context, id string, at time.Time) (bool, error)
+}
+
+// SetScheduledPayments enables SchedulePayment and the job that runs due
+// scheduled payments.
+func (s *PaymentService) SetScheduledPayments(repo ScheduledPaymentRepository) {
+	s.scheduled = repo
+}
+
+// SchedulePayment stores req to be charged at runAt. The request is
+// validated now so mistakes surface to the caller rather than at run time,
+// but a customer's default payment method is only resolved when it runs.
+// The tenant's default capture method is resolved now, since the tenant is
+// not loaded when the job runs.
+func (s *PaymentService) SchedulePayment(ctx context.Context, req *models.ChargeRequest, runAt time.Time) (*models.ScheduledPayment, error) {
+	if s.scheduled == nil {
+		return nil, errors.New("scheduled payments are not enabled")
+	}
+	if runAt.IsZero() || !runAt.After(s.now()) {
+		return nil, fmt.Errorf("%w: run_at must be in the future", ErrInvalidScheduledPayment)
+	}
+
+	stored := *req
+	if err := s.resolveCaptureMethod(ctx, &stored); err != nil {
+		return nil, err
+	}
+	check := stored
+	if err := s.resolveDefaultPaymentMethod(ctx, &check); err != nil {
+		return nil, err
+	}
+	if err := s.validateChargeRequest(ctx, &check); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidScheduledPayment, err)
+	}
+
+	scheduled := &models.ScheduledPayment{
+		Request: stored,
+		RunAt:   runAt,
+		Status:  models.ScheduledPaymentStatusScheduled,
+	}
+	if tid, ok := ctx.Value(ctxkeys.TenantID).(string); ok && tid != "" {
+		scheduled.TenantID = &tid
+	}
+	if err := s.scheduled.Create(ctx, scheduled); err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+func (s *PaymentService) GetScheduledPayment(ctx context.Context, id string) (*models.ScheduledPayment, error) {
+	if s.scheduled == nil {
+		return nil, ErrScheduledPaymentNotFound
+	}
+	scheduled, err := s.scheduled.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScheduledPaymentNotFound
+		}
+		return nil, err
+	}
+	if !scheduledPaymentVisible(ctx, scheduled) {
+		return nil, ErrScheduledPaymentNotFound
+	}
+	return scheduled, nil
+}
+
+func (s *PaymentService) ListScheduledPayments(ctx context.Context, filter models.ScheduledPaymentFilter) ([]*models.ScheduledPayment, error) {
+	tenantID, _ := ctx.Value(ctxkeys.TenantID).(string)
+	if tenantID == "" {
+		return nil, ErrPaymentTenantRequired
+	}
+	if s.scheduled == nil {
+		return []*models.ScheduledPayment{}, nil
+	}
+	return s.scheduled.ListByTenant(ctx, tenantID, filter)
+}
+
+// CancelScheduledPayment stops a scheduled payment that has not started to
+// run. Once the job has claimed it the charge goes ahead.
+func (s *PaymentService) CancelScheduledPayment(ctx context.Context, id string) (*models.ScheduledPayment, error) {
+	scheduled, err := s.GetScheduledPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if scheduled.Status != models.ScheduledPaymentStatusScheduled {
+		return nil, ErrScheduledPaymentNotCancelable
+	}
+
+	now := s.now()
+	canceled, err := s.scheduled.Cancel(ctx, id, now)
+	if err != nil {
+		return nil, err
+	}
+	if !canceled {
+		return nil, ErrScheduledPaymentNotCancelable
+	}
+	scheduled.Status = models.ScheduledPaymentStatusCanceled
+	scheduled.CanceledAt = &now
+	return scheduled, nil
+}
+
+// ExecuteDueScheduledPayments charges scheduled payments whose run time has
+// passed through CreateCharge, so each one gets the same validation, fraud
+// checks and idempotency handling as a charge made through the API. It
+// returns how many were run.
+func (s *PaymentService) ExecuteDueScheduledPayments(ctx context.Context, batchSize int) (int, error) {
+	if s.scheduled == nil {
+		return 0, nil
+	}
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+
+	now := s.now()
+	due, err := s.scheduled.ClaimDue(ctx, now, now.Add(-scheduledPaymentStaleAfter), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for _, scheduled := range due {
+		if err := s.executeScheduledPayment(ctx, scheduled); err != nil {
+			errs = append(errs, fmt.Errorf("scheduled payment %s: %w", scheduled.ID, err))
+		}
+	}
+	return len(due), errors.Join(errs...)
+}
+
+// executeScheduledPayment records a declined or rejected charge on the
+// scheduled payment rather than returning it; only failing to save the
+// outcome is an error.
+func (s *PaymentService) executeScheduledPayment(ctx context.Context, scheduled *models.ScheduledPayment) error {
+	if scheduled.TenantID != nil {
+		ctx = context.WithValue(ctx, ctxkeys.TenantID, *scheduled.TenantID)
+	}
+
+	req := scheduled.Request
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = "scheduled_payment_" + scheduled.ID
+	}
+
+	resp, err := s.CreateCharge(ctx, &req)
+
+	executedAt := s.now()
+	scheduled.ExecutedAt = &executedAt
+	switch {
+	case err != nil:
+		scheduled.Status = models.ScheduledPaymentStatusFailed
+		scheduled.ErrorMessage = err.Error()
+	case resp.Status == models.PaymentStatusFailed:
+		scheduled.Status = models.ScheduledPaymentStatusFailed
+		scheduled.PaymentID = resp.ID
+		scheduled.ErrorMessage = "charge failed"
+	default:
+		scheduled.Status = models.ScheduledPaymentStatusSucceeded
+		scheduled.PaymentID = resp.ID
+		scheduled.ErrorMessage = ""
+	}
+	return s.scheduled.Update(ctx, scheduled)
+}
+
+// scheduledPaymentVisible hides other tenants' scheduled payments from a
+// tenant-scoped caller.
+func scheduledPaymentVisible(ctx context.Context, scheduled *models.ScheduledPayment) bool {
+	tenantID, _ := ctx.Value(ctxkeys.TenantID).(string)
+	if tenantID == "" {
+		return true
+	}
+	return scheduled.TenantID != nil && *scheduled.TenantID == tenantID
+}
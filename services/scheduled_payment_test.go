@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type fakeScheduledPaymentRepo struct {
+	scheduled []*models.ScheduledPayment
+}
+
+func (f *fakeScheduledPaymentRepo) Create(_ context.Context, scheduled *models.ScheduledPayment) error {
+	scheduled.ID = fmt.Sprintf("sp_%d", len(f.scheduled)+1)
+	f.scheduled = append(f.scheduled, scheduled)
+	return nil
+}
+
+func (f *fakeScheduledPaymentRepo) GetByID(_ context.Context, id string) (*models.ScheduledPayment, error) {
+	for _, sp := range f.scheduled {
+		if sp.ID == id {
+			return sp, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeScheduledPaymentRepo) ListByTenant(_ context.Context, tenantID string, _ models.ScheduledPaymentFilter) ([]*models.ScheduledPayment, error) {
+	var out []*models.ScheduledPayment
+	for _, sp := range f.scheduled {
+		if sp.TenantID != nil && *sp.TenantID == tenantID {
+			out = append(out, sp)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeScheduledPaymentRepo) Update(_ context.Context, scheduled *models.ScheduledPayment) error {
+	return nil
+}
+
+func (f *fakeScheduledPaymentRepo) ClaimDue(_ context.Context, now, _ time.Time, limit int) ([]*models.ScheduledPayment, error) {
+	var due []*models.ScheduledPayment
+	for _, sp := range f.scheduled {
+		if len(due) == limit {
+			break
+		}
+		if sp.Status == models.ScheduledPaymentStatusScheduled && !sp.RunAt.After(now) {
+			sp.Status = models.ScheduledPaymentStatusProcessing
+			due = append(due, sp)
+		}
+	}
+	return due, nil
+}
+
+func (f *fakeScheduledPaymentRepo) Cancel(_ context.Context, id string, at time.Time) (bool, error) {
+	for _, sp := range f.scheduled {
+		if sp.ID == id && sp.Status == models.ScheduledPaymentStatusScheduled {
+			sp.Status = models.ScheduledPaymentStatusCanceled
+			sp.CanceledAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestScheduledPaymentRunsOnceDue(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	store := &fakeChargeStore{}
+	provider := &fakeChargeProvider{}
+	svc := CreatePaymentService(store, provider)
+	svc.SetScheduledPayments(&fakeScheduledPaymentRepo{})
+	now := start
+	svc.now = func() time.Time { return now }
+	ctx := context.WithValue(context.Background(), ctxkeys.TenantID, "ten_1")
+
+	scheduled, err := svc.SchedulePayment(ctx, &models.ChargeRequest{
+		Amount:        5000,
+		Currency:      "USD",
+		PaymentMethod: "pm_card_visa",
+	}, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scheduled.Status != models.ScheduledPaymentStatusScheduled || scheduled.TenantID == nil || *scheduled.TenantID != "ten_1" {
+		t.Fatalf("expected a scheduled payment for ten_1, got %+v", scheduled)
+	}
+
+	ran, err := svc.ExecuteDueScheduledPayments(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ran != 0 || provider.charges.Load() != 0 {
+		t.Fatalf("expected nothing to run before run_at, ran %d with %d charges", ran, provider.charges.Load())
+	}
+
+	now = start.Add(25 * time.Hour)
+	ran, err = svc.ExecuteDueScheduledPayments(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ran != 1 || provider.charges.Load() != 1 {
+		t.Fatalf("expected the due payment to be charged once, ran %d with %d charges", ran, provider.charges.Load())
+	}
+	if scheduled.Status != models.ScheduledPaymentStatusSucceeded || scheduled.PaymentID != "pay_1" || scheduled.ExecutedAt == nil {
+		t.Fatalf("expected the scheduled payment to record its charge, got %+v", scheduled)
+	}
+
+	payment := store.payments[0]
+	if payment.IdempotencyKey != "scheduled_payment_"+scheduled.ID {
+		t.Fatalf("expected the charge to carry the scheduled payment's idempotency key, got %q", payment.IdempotencyKey)
+	}
+	if payment.TenantID == nil || *payment.TenantID != "ten_1" {
+		t.Fatalf("expected the charge to belong to ten_1, got %v", payment.TenantID)
+	}
+
+	if ran, _ := svc.ExecuteDueScheduledPayments(context.Background(), 10); ran != 0 || provider.charges.Load() != 1 {
+		t.Fatalf("expected an executed payment not to run again, ran %d with %d charges", ran, provider.charges.Load())
+	}
+}
+
+func TestScheduledPaymentKeepsCallerIdempotencyKey(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	store := &fakeChargeStore{}
+	svc := CreatePaymentService(store, &fakeChargeProvider{})
+	svc.SetScheduledPayments(&fakeScheduledPaymentRepo{})
+	now := start
+	svc.now = func() time.Time { return now }
+
+	if _, err := svc.SchedulePayment(context.Background(), &models.ChargeRequest{
+		Amount:         5000,
+		Currency:       "USD",
+		PaymentMethod:  "pm_card_visa",
+		IdempotencyKey: "invoice-42",
+	}, start.Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now = start.Add(2 * time.Hour)
+	if _, err := svc.ExecuteDueScheduledPayments(context.Background(), 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := store.payments[0].IdempotencyKey; got != "invoice-42" {
+		t.Fatalf("expected the caller's idempotency key, got %q", got)
+	}
+}
+
+func TestCanceledScheduledPaymentNeverRuns(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	provider := &fakeChargeProvider{}
+	svc := CreatePaymentService(&fakeChargeStore{}, provider)
+	svc.SetScheduledPayments(&fakeScheduledPaymentRepo{})
+	now := start
+	svc.now = func() time.Time { return now }
+	ctx := context.WithValue(context.Background(), ctxkeys.TenantID, "ten_1")
+
+	scheduled, err := svc.SchedulePayment(ctx, &models.ChargeRequest{
+		Amount:        5000,
+		Currency:      "USD",
+		PaymentMethod: "pm_card_visa",
+	}, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	otherTenant := context.WithValue(context.Background(), ctxkeys.TenantID, "ten_2")
+	if _, err := svc.CancelScheduledPayment(otherTenant, scheduled.ID); !errors.Is(err, ErrScheduledPaymentNotFound) {
+		t.Fatalf("expected ErrScheduledPaymentNotFound for another tenant, got %v", err)
+	}
+
+	canceled, err := svc.CancelScheduledPayment(ctx, scheduled.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if canceled.Status != models.ScheduledPaymentStatusCanceled || canceled.CanceledAt == nil {
+		t.Fatalf("expected the payment to be canceled, got %+v", canceled)
+	}
+
+	now = start.Add(2 * time.Hour)
+	if ran, err := svc.ExecuteDueScheduledPayments(context.Background(), 10); err != nil || ran != 0 {
+		t.Fatalf("expected nothing to run, ran %d: %v", ran, err)
+	}
+	if n := provider.charges.Load(); n != 0 {
+		t.Fatalf("expected a canceled payment not to be charged, got %d charges", n)
+	}
+
+	if _, err := svc.CancelScheduledPayment(ctx, scheduled.ID); !errors.Is(err, ErrScheduledPaymentNotCancelable) {
+		t.Fatalf("expected ErrScheduledPaymentNotCancelable, got %v", err)
+	}
+}
+
+func TestSchedulePaymentValidates(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	svc := CreatePaymentService(&fakeChargeStore{}, &fakeChargeProvider{})
+	svc.SetScheduledPayments(&fakeScheduledPaymentRepo{})
+	svc.now = func() time.Time { return start }
+	ctx := context.Background()
+	valid := models.ChargeRequest{Amount: 5000, Currency: "USD", PaymentMethod: "pm_card_visa"}
+
+	if _, err := svc.SchedulePayment(ctx, &valid, start.Add(-time.Minute)); !errors.Is(err, ErrInvalidScheduledPayment) {
+		t.Fatalf("expected ErrInvalidScheduledPayment for a past run_at, got %v", err)
+	}
+	invalid := valid
+	invalid.Amount = 0
+	if _, err := svc.SchedulePayment(ctx, &invalid, start.Add(time.Hour)); !errors.Is(err, ErrInvalidScheduledPayment) {
+		t.Fatalf("expected ErrInvalidScheduledPayment for a zero amount, got %v", err)
+	}
+}
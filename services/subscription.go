@@ -2,34 +2,91 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
+	"github.com/malwarebo/conductor/internal/ctxkeys"
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/providers"
 	"github.com/malwarebo/conductor/stores"
+	"gorm.io/gorm"
 )
 
 var (
-	ErrPlanNotFound = errors.New("plan not found")
+	ErrPlanNotFound         = errors.New("plan not found")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrPlanNotMetered       = errors.New("subscription plan is not metered")
+	ErrInvalidUsageQuantity = errors.New("usage quantity must be positive")
 )
 
+// PlanStore is the canonical record of plans. Plans are written through to
+// the provider they are created on, but reads never go to a provider, so
+// every provider's plans are listed the same way.
+type PlanStore interface {
+	Create(ctx context.Context, plan *models.Plan) error
+	Update(ctx context.Context, plan *models.Plan) error
+	GetByID(ctx context.Context, id string) (*models.Plan, error)
+	List(ctx context.Context) ([]*models.Plan, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type SubscriptionStore interface {
+	Create(ctx context.Context, subscription *models.Subscription) error
+	Update(ctx context.Context, subscription *models.Subscription) error
+	GetByID(ctx context.Context, id string) (*models.Subscription, error)
+	ListByCustomer(ctx context.Context, customerID string) ([]*models.Subscription, error)
+	ListDueForDunning(ctx context.Context, before time.Time, limit int) ([]*models.Subscription, error)
+}
+
+// IdempotencyRepository records the outcome of a keyed request so a retry
+// with the same key replays it instead of repeating the provider call.
+type IdempotencyRepository interface {
+	GetOrCreate(ctx context.Context, key, tenantID, requestPath string, requestBody []byte, ttl time.Duration) (*models.IdempotencyResult, error)
+	Complete(ctx context.Context, key string, responseCode int, responseBody interface{}) error
+	Unlock(ctx context.Context, key string) error
+}
+
 type SubscriptionService struct {
-	providers []providers.PaymentProvider
-	planRepo  *stores.PlanRepository
-	subRepo   *stores.SubscriptionRepository
-	mu        sync.RWMutex
+	providers      []providers.PaymentProvider
+	planRepo       PlanStore
+	subRepo        SubscriptionStore
+	notifier       OutboundNotifier
+	dunning        DunningConfig
+	idempotency    IdempotencyRepository
+	idempotencyTTL time.Duration
+	audit          *AuditService
+	now            func() time.Time
+	mu             sync.RWMutex
 }
 
-func CreateSubscriptionService(planRepo *stores.PlanRepository, subRepo *stores.SubscriptionRepository, providers ...providers.PaymentProvider) *SubscriptionService {
+func CreateSubscriptionService(planRepo PlanStore, subRepo SubscriptionStore, providers ...providers.PaymentProvider) *SubscriptionService {
 	return &SubscriptionService{
 		providers: providers,
 		planRepo:  planRepo,
 		subRepo:   subRepo,
+		dunning:   DefaultDunningConfig(),
+		now:       time.Now,
 	}
 }
 
+// SetIdempotency makes UpdateSubscription and CancelSubscription replay the
+// first result for a repeated idempotency key. Non-positive TTLs use
+// DefaultIdempotencyTTL.
+func (s *SubscriptionService) SetIdempotency(store IdempotencyRepository, ttl time.Duration) {
+	s.idempotency = store
+	s.idempotencyTTL = ttl
+}
+
+// SetAuditService records subscription updates and cancellations, with the
+// subscription before and after the change, in the audit log.
+func (s *SubscriptionService) SetAuditService(audit *AuditService) {
+	s.audit = audit
+}
+
 func (s *SubscriptionService) AddProvider(provider providers.PaymentProvider) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -53,11 +110,19 @@ func (s *SubscriptionService) CreatePlan(ctx context.Context, plan *models.Plan)
 	if provider == nil {
 		return nil, ErrNoAvailableProvider
 	}
+	if err := requireCapabilityForSubscriptions(ctx, provider); err != nil {
+		return nil, err
+	}
 
-	providerPlan, err := provider.CreatePlan(ctx, plan)
+	providerPlan, err := callProvider(ctx, "create_plan", func(ctx context.Context) (*models.Plan, error) {
+		return provider.CreatePlan(ctx, plan)
+	})
 	if err != nil {
 		return nil, err
 	}
+	if providerPlan.ProviderName == "" {
+		providerPlan.ProviderName = provider.Name()
+	}
 
 	if err := s.planRepo.Create(ctx, providerPlan); err != nil {
 		return nil, err
@@ -66,17 +131,37 @@ func (s *SubscriptionService) CreatePlan(ctx context.Context, plan *models.Plan)
 	return providerPlan, nil
 }
 
+// UpdatePlan changes the plan on the provider that owns it, then records
+// the result over the stored plan.
 func (s *SubscriptionService) UpdatePlan(ctx context.Context, planID string, plan *models.Plan) (*models.Plan, error) {
-	provider := s.getAvailableProvider(ctx)
+	existing, err := s.GetPlan(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	provider := s.providerNamed(ctx, existing.ProviderName)
 	if provider == nil {
 		return nil, ErrNoAvailableProvider
 	}
+	if err := requireCapabilityForEntity(ctx, provider, planID, "plan", providers.CapabilitySubscriptions); err != nil {
+		return nil, err
+	}
 
-	updatedPlan, err := provider.UpdatePlan(ctx, planID, plan)
+	updatedPlan, err := callProvider(ctx, "update_plan", func(ctx context.Context) (*models.Plan, error) {
+		return provider.UpdatePlan(ctx, planID, plan)
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	// Providers return only what they track, so fields they leave out keep
+	// their stored values.
+	updatedPlan.ID = existing.ID
+	updatedPlan.ProviderName = existing.ProviderName
+	updatedPlan.CreatedAt = existing.CreatedAt
+	if updatedPlan.MeterEventName == "" {
+		updatedPlan.MeterEventName = existing.MeterEventName
+	}
+
 	if err := s.planRepo.Update(ctx, updatedPlan); err != nil {
 		return nil, err
 	}
@@ -85,22 +170,37 @@ func (s *SubscriptionService) UpdatePlan(ctx context.Context, planID string, pla
 }
 
 func (s *SubscriptionService) DeletePlan(ctx context.Context, planID string) error {
-	provider := s.getAvailableProvider(ctx)
+	existing, err := s.GetPlan(ctx, planID)
+	if err != nil {
+		return err
+	}
+	provider := s.providerNamed(ctx, existing.ProviderName)
 	if provider == nil {
 		return ErrNoAvailableProvider
 	}
+	if err := requireCapabilityForEntity(ctx, provider, planID, "plan", providers.CapabilitySubscriptions); err != nil {
+		return err
+	}
 
-	if err := provider.DeletePlan(ctx, planID); err != nil {
+	if err := callProviderErr(ctx, "delete_plan", func(ctx context.Context) error {
+		return provider.DeletePlan(ctx, planID)
+	}); err != nil {
 		return err
 	}
 
 	return s.planRepo.Delete(ctx, planID)
 }
 
+// GetPlan reads the plan from the local store, whichever provider owns it.
 func (s *SubscriptionService) GetPlan(ctx context.Context, planID string) (*models.Plan, error) {
-	return s.planRepo.GetByID(ctx, planID)
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPlanNotFound
+	}
+	return plan, err
 }
 
+// ListPlans lists active plans from the local store across all providers.
 func (s *SubscriptionService) ListPlans(ctx context.Context) ([]*models.Plan, error) {
 	return s.planRepo.List(ctx)
 }
@@ -110,6 +210,9 @@ func (s *SubscriptionService) CreateSubscription(ctx context.Context, req *model
 	if provider == nil {
 		return nil, ErrNoAvailableProvider
 	}
+	if err := requireCapabilityForSubscriptions(ctx, provider); err != nil {
+		return nil, err
+	}
 
 	plan, err := s.planRepo.GetByID(ctx, req.PlanID)
 	if err != nil {
@@ -121,10 +224,15 @@ func (s *SubscriptionService) CreateSubscription(ctx context.Context, req *model
 		req.TrialDays = &trialDays
 	}
 
-	subscription, err := provider.CreateSubscription(ctx, req)
+	subscription, err := callProvider(ctx, "create_subscription", func(ctx context.Context) (*models.Subscription, error) {
+		return provider.CreateSubscription(ctx, req)
+	})
 	if err != nil {
 		return nil, err
 	}
+	if tenantID, ok := ctx.Value(ctxkeys.TenantID).(string); ok && tenantID != "" {
+		subscription.TenantID = &tenantID
+	}
 
 	if err := s.subRepo.Create(ctx, subscription); err != nil {
 		return nil, err
@@ -134,10 +242,19 @@ func (s *SubscriptionService) CreateSubscription(ctx context.Context, req *model
 }
 
 func (s *SubscriptionService) UpdateSubscription(ctx context.Context, subscriptionID string, req *models.UpdateSubscriptionRequest) (*models.Subscription, error) {
+	return s.changeSubscription(ctx, models.AuditActionUpdate, subscriptionID, req.IdempotencyKey, req, func() (*models.Subscription, error) {
+		return s.updateSubscription(ctx, subscriptionID, req)
+	})
+}
+
+func (s *SubscriptionService) updateSubscription(ctx context.Context, subscriptionID string, req *models.UpdateSubscriptionRequest) (*models.Subscription, error) {
 	provider := s.getAvailableProvider(ctx)
 	if provider == nil {
 		return nil, ErrNoAvailableProvider
 	}
+	if err := requireCapabilityForEntity(ctx, provider, subscriptionID, "subscription", providers.CapabilitySubscriptions); err != nil {
+		return nil, err
+	}
 
 	if req.PlanID != nil {
 		if _, err := s.planRepo.GetByID(ctx, *req.PlanID); err != nil {
@@ -145,7 +262,9 @@ func (s *SubscriptionService) UpdateSubscription(ctx context.Context, subscripti
 		}
 	}
 
-	subscription, err := provider.UpdateSubscription(ctx, subscriptionID, req)
+	subscription, err := callProvider(ctx, "update_subscription", func(ctx context.Context) (*models.Subscription, error) {
+		return provider.UpdateSubscription(ctx, subscriptionID, req)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -158,18 +277,33 @@ func (s *SubscriptionService) UpdateSubscription(ctx context.Context, subscripti
 }
 
 func (s *SubscriptionService) CancelSubscription(ctx context.Context, subscriptionID string, req *models.CancelSubscriptionRequest) (*models.Subscription, error) {
+	return s.changeSubscription(ctx, models.AuditActionCancel, subscriptionID, req.IdempotencyKey, req, func() (*models.Subscription, error) {
+		return s.cancelSubscription(ctx, subscriptionID, req)
+	})
+}
+
+func (s *SubscriptionService) cancelSubscription(ctx context.Context, subscriptionID string, req *models.CancelSubscriptionRequest) (*models.Subscription, error) {
 	provider := s.getAvailableProvider(ctx)
 	if provider == nil {
 		return nil, ErrNoAvailableProvider
 	}
+	if err := requireCapabilityForEntity(ctx, provider, subscriptionID, "subscription", providers.CapabilitySubscriptions); err != nil {
+		return nil, err
+	}
 
-	subscription, err := provider.CancelSubscription(ctx, subscriptionID, req)
+	canceled, err := callProvider(ctx, "cancel_subscription", func(ctx context.Context) (*models.Subscription, error) {
+		return provider.CancelSubscription(ctx, subscriptionID, req)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	now := time.Now()
-	subscription.CanceledAt = &now
+	subscription, err := s.subRepo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		subscription = canceled
+	}
+	applyCancellation(subscription, canceled, req.CancelAtPeriodEnd, s.now())
+
 	if err := s.subRepo.Update(ctx, subscription); err != nil {
 		return nil, err
 	}
@@ -177,6 +311,126 @@ func (s *SubscriptionService) CancelSubscription(ctx context.Context, subscripti
 	return subscription, nil
 }
 
+// changeSubscription runs change once per idempotency key and audits the
+// result. A replayed request returns the stored subscription without calling
+// the provider or writing another audit entry. Failed changes release the key
+// so the caller can retry with it.
+func (s *SubscriptionService) changeSubscription(ctx context.Context, action models.AuditAction, subscriptionID, idempotencyKey string, req interface{}, change func() (*models.Subscription, error)) (*models.Subscription, error) {
+	useKey := idempotencyKey != "" && s.idempotency != nil
+	if useKey {
+		replayed, err := s.checkIdempotency(ctx, idempotencyKey, "/v1/subscriptions/"+subscriptionID, req)
+		if err != nil || replayed != nil {
+			return replayed, err
+		}
+	}
+
+	before, _ := s.subRepo.GetByID(ctx, subscriptionID)
+
+	subscription, err := change()
+	if useKey {
+		if err != nil {
+			_ = s.idempotency.Unlock(ctx, idempotencyKey)
+		} else {
+			_ = s.idempotency.Complete(ctx, idempotencyKey, http.StatusOK, subscription)
+		}
+	}
+	s.auditChange(ctx, action, subscriptionID, before, subscription, err)
+	return subscription, err
+}
+
+// checkIdempotency returns the stored subscription when key has already
+// completed, or nil when the request should run.
+func (s *SubscriptionService) checkIdempotency(ctx context.Context, key, path string, req interface{}) (*models.Subscription, error) {
+	tenantID, _ := ctx.Value(ctxkeys.TenantID).(string)
+	ttl := s.idempotencyTTL
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	body, _ := json.Marshal(req)
+	result, err := s.idempotency.GetOrCreate(ctx, key, tenantID, path, body, ttl)
+	if err != nil {
+		if errors.Is(err, stores.ErrIdempotencyMismatch) || errors.Is(err, stores.ErrIdempotencyInProgress) {
+			return nil, fmt.Errorf("%w: %w", ErrIdempotencyConflict, err)
+		}
+		return nil, err
+	}
+	if result.IsNew || result.ResponseCode == 0 {
+		return nil, nil
+	}
+
+	var subscription models.Subscription
+	if err := json.Unmarshal(result.ResponseBody, &subscription); err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (s *SubscriptionService) auditChange(ctx context.Context, action models.AuditAction, subscriptionID string, before, after *models.Subscription, changeErr error) {
+	if s.audit == nil {
+		return
+	}
+
+	log := &models.AuditLog{
+		Action:       string(action),
+		ResourceType: string(models.AuditResourceSubscription),
+		ResourceID:   subscriptionID,
+		Success:      changeErr == nil,
+		Metadata: map[string]interface{}{
+			"before": toAuditJSON(before),
+			"after":  toAuditJSON(after),
+		},
+	}
+	if tenantID, ok := ctx.Value(ctxkeys.TenantID).(string); ok && tenantID != "" {
+		log.TenantID = &tenantID
+	} else if before != nil {
+		log.TenantID = before.TenantID
+	}
+	if userID, ok := ctx.Value(ctxkeys.UserID).(string); ok {
+		log.UserID = userID
+	}
+	if changeErr != nil {
+		log.ErrorMessage = changeErr.Error()
+	}
+	_ = s.audit.LogAction(ctx, log)
+}
+
+// applyCancellation records a provider's cancel response on the stored
+// subscription in one shape regardless of provider: a period-end cancel stays
+// active with CancelAt set, an immediate cancel is canceled as of now.
+func applyCancellation(subscription, canceled *models.Subscription, atPeriodEnd bool, now time.Time) {
+	if periodEnd := canceled.CurrentPeriodEnd; periodEnd.Unix() > 0 {
+		subscription.CurrentPeriodEnd = periodEnd
+	}
+
+	if !atPeriodEnd {
+		subscription.Status = models.SubscriptionStatusCanceled
+		subscription.CanceledAt = &now
+		subscription.CancelAt = nil
+		return
+	}
+
+	// Providers report the scheduled end inconsistently: Stripe as cancel_at,
+	// Airwallex as ends_at and Razorpay only through the period end.
+	cancelAt := canceled.CancelAt
+	if cancelAt == nil {
+		cancelAt = canceled.CanceledAt
+	}
+	if cancelAt == nil && subscription.CurrentPeriodEnd.Unix() > 0 {
+		periodEnd := subscription.CurrentPeriodEnd
+		cancelAt = &periodEnd
+	}
+
+	switch canceled.Status {
+	case models.SubscriptionStatusTrialing, models.SubscriptionStatusPastDue:
+		subscription.Status = canceled.Status
+	default:
+		subscription.Status = models.SubscriptionStatusActive
+	}
+	subscription.CancelAt = cancelAt
+	subscription.CanceledAt = nil
+}
+
 func (s *SubscriptionService) GetSubscription(ctx context.Context, subscriptionID string) (*models.Subscription, error) {
 	return s.subRepo.GetByID(ctx, subscriptionID)
 }
@@ -184,3 +438,63 @@ func (s *SubscriptionService) GetSubscription(ctx context.Context, subscriptionI
 func (s *SubscriptionService) ListSubscriptions(ctx context.Context, customerID string) ([]*models.Subscription, error) {
 	return s.subRepo.ListByCustomer(ctx, customerID)
 }
+
+func (s *SubscriptionService) RecordUsage(ctx context.Context, subscriptionID string, req *models.RecordUsageRequest) (*models.UsageRecord, error) {
+	if req.Quantity <= 0 {
+		return nil, ErrInvalidUsageQuantity
+	}
+
+	subscription, err := s.subRepo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	plan := subscription.Plan
+	if plan == nil {
+		plan, err = s.planRepo.GetByID(ctx, subscription.PlanID)
+		if err != nil {
+			return nil, ErrPlanNotFound
+		}
+	}
+	if plan.PricingType != models.PricingTypeMetered {
+		return nil, ErrPlanNotMetered
+	}
+
+	itemID := req.SubscriptionItemID
+	if itemID == "" {
+		itemID = subscription.SubscriptionItemID
+	}
+
+	provider := s.providerFor(ctx, subscription)
+	if provider == nil {
+		return nil, ErrNoAvailableProvider
+	}
+
+	meteredProvider, ok := provider.(providers.MeteredBillingProvider)
+	if !ok {
+		return nil, providers.ErrNotSupported
+	}
+
+	timestamp := time.Now()
+	if req.Timestamp != nil {
+		timestamp = *req.Timestamp
+	}
+
+	record, err := callProvider(ctx, "record_usage", func(ctx context.Context) (*models.UsageRecord, error) {
+		return meteredProvider.RecordUsage(ctx, &models.UsageEvent{
+			SubscriptionID:     subscription.ID,
+			SubscriptionItemID: itemID,
+			CustomerID:         subscription.CustomerID,
+			ProviderName:       subscription.ProviderName,
+			EventName:          plan.MeterEventName,
+			Quantity:           req.Quantity,
+			Timestamp:          timestamp,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	record.SubscriptionID = subscriptionID
+
+	return record, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"gorm.io/gorm"
+)
+
+type fakePlanStore struct {
+	plans []*models.Plan
+}
+
+func (f *fakePlanStore) Create(_ context.Context, plan *models.Plan) error {
+	copied := *plan
+	f.plans = append(f.plans, &copied)
+	return nil
+}
+
+func (f *fakePlanStore) Update(_ context.Context, plan *models.Plan) error {
+	for i, stored := range f.plans {
+		if stored.ID == plan.ID {
+			copied := *plan
+			f.plans[i] = &copied
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (f *fakePlanStore) GetByID(_ context.Context, id string) (*models.Plan, error) {
+	for _, plan := range f.plans {
+		if plan.ID == id {
+			copied := *plan
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakePlanStore) List(context.Context) ([]*models.Plan, error) {
+	return f.plans, nil
+}
+
+func (f *fakePlanStore) Delete(_ context.Context, id string) error {
+	for i, plan := range f.plans {
+		if plan.ID == id {
+			f.plans = append(f.plans[:i], f.plans[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// fakePlanProvider creates and updates plans but, like Airwallex, cannot
+// read them back.
+type fakePlanProvider struct {
+	providers.PaymentProvider
+	name    string
+	down    bool
+	created int
+	updated []string
+}
+
+func (f *fakePlanProvider) Name() string                     { return f.name }
+func (f *fakePlanProvider) IsAvailable(context.Context) bool { return !f.down }
+
+func (f *fakePlanProvider) Capabilities() providers.ProviderCapabilities {
+	return providers.ProviderCapabilities{SupportsSubscriptions: true}
+}
+
+func (f *fakePlanProvider) CreatePlan(_ context.Context, plan *models.Plan) (*models.Plan, error) {
+	f.created++
+	created := *plan
+	created.ID = fmt.Sprintf("%s_plan_%d", f.name, f.created)
+	return &created, nil
+}
+
+func (f *fakePlanProvider) UpdatePlan(_ context.Context, planID string, plan *models.Plan) (*models.Plan, error) {
+	f.updated = append(f.updated, planID)
+	updated := *plan
+	updated.ID = planID
+	return &updated, nil
+}
+
+func (f *fakePlanProvider) GetPlan(context.Context, string) (*models.Plan, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func (f *fakePlanProvider) ListPlans(context.Context) ([]*models.Plan, error) {
+	return nil, providers.ErrNotSupported
+}
+
+func TestPlansFromEveryProviderAreListedFromTheStore(t *testing.T) {
+	stripe := &fakePlanProvider{name: "stripe"}
+	airwallex := &fakePlanProvider{name: "airwallex"}
+	store := &fakePlanStore{}
+	svc := CreateSubscriptionService(store, &fakeSubscriptionStore{}, stripe, airwallex)
+	ctx := context.Background()
+
+	stripePlan, err := svc.CreatePlan(ctx, &models.Plan{Name: "Pro", Amount: 20, Currency: "USD", BillingPeriod: "month"})
+	if err != nil {
+		t.Fatalf("create stripe plan: %v", err)
+	}
+	stripe.down = true
+	airwallexPlan, err := svc.CreatePlan(ctx, &models.Plan{Name: "Team", Amount: 150, Currency: "HKD", BillingPeriod: "month"})
+	if err != nil {
+		t.Fatalf("create airwallex plan: %v", err)
+	}
+	if stripePlan.ProviderName != "stripe" || airwallexPlan.ProviderName != "airwallex" {
+		t.Fatalf("expected each plan to record its provider, got %q and %q", stripePlan.ProviderName, airwallexPlan.ProviderName)
+	}
+
+	plans, err := svc.ListPlans(ctx)
+	if err != nil {
+		t.Fatalf("list plans: %v", err)
+	}
+	if len(plans) != 2 || plans[0].ID != stripePlan.ID || plans[1].ID != airwallexPlan.ID {
+		t.Fatalf("expected both providers' plans listed, got %+v", plans)
+	}
+	for _, id := range []string{stripePlan.ID, airwallexPlan.ID} {
+		plan, err := svc.GetPlan(ctx, id)
+		if err != nil {
+			t.Fatalf("get plan %s: %v", id, err)
+		}
+		if plan.ID != id {
+			t.Fatalf("expected plan %s, got %s", id, plan.ID)
+		}
+	}
+	if _, err := svc.GetPlan(ctx, "plan_missing"); !errors.Is(err, ErrPlanNotFound) {
+		t.Fatalf("expected ErrPlanNotFound for an unknown plan, got %v", err)
+	}
+}
+
+func TestUpdatePlanWritesThroughToOwningProvider(t *testing.T) {
+	stripe := &fakePlanProvider{name: "stripe"}
+	airwallex := &fakePlanProvider{name: "airwallex"}
+	store := &fakePlanStore{plans: []*models.Plan{
+		{ID: "airwallex_plan_1", Name: "Team", Amount: 150, Currency: "HKD", ProviderName: "airwallex", MeterEventName: "seats"},
+	}}
+	svc := CreateSubscriptionService(store, &fakeSubscriptionStore{}, stripe, airwallex)
+
+	updated, err := svc.UpdatePlan(context.Background(), "airwallex_plan_1", &models.Plan{Name: "Team Plus", Amount: 180, Currency: "HKD"})
+	if err != nil {
+		t.Fatalf("update plan: %v", err)
+	}
+	if len(airwallex.updated) != 1 || len(stripe.updated) != 0 {
+		t.Fatalf("expected the update sent to airwallex only, airwallex=%v stripe=%v", airwallex.updated, stripe.updated)
+	}
+
+	stored, _ := store.GetByID(context.Background(), "airwallex_plan_1")
+	if stored.Name != "Team Plus" || stored.ProviderName != "airwallex" || stored.MeterEventName != "seats" {
+		t.Fatalf("expected the update recorded over the stored plan, got %+v", stored)
+	}
+	if updated.ProviderName != "airwallex" {
+		t.Fatalf("expected the response to keep the provider, got %q", updated.ProviderName)
+	}
+}
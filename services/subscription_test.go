@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+	"github.com/malwarebo/conductor/stores"
+)
+
+type fakeCancelProvider struct {
+	providers.PaymentProvider
+	name   string
+	cancel func(req *models.CancelSubscriptionRequest) *models.Subscription
+}
+
+func (f *fakeCancelProvider) Name() string                     { return f.name }
+func (f *fakeCancelProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeCancelProvider) Capabilities() providers.ProviderCapabilities {
+	return providers.ProviderCapabilities{SupportsSubscriptions: true}
+}
+
+func (f *fakeCancelProvider) CancelSubscription(_ context.Context, id string, req *models.CancelSubscriptionRequest) (*models.Subscription, error) {
+	sub := f.cancel(req)
+	sub.ID = id
+	sub.ProviderName = f.name
+	return sub, nil
+}
+
+func TestCancelSubscriptionNormalizesAcrossProviders(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	periodEnd := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	providerCanceledAt := now.Add(-time.Second)
+
+	// Each fake mirrors what the real provider maps from its cancel response.
+	fakes := []*fakeCancelProvider{
+		{name: "stripe", cancel: func(req *models.CancelSubscriptionRequest) *models.Subscription {
+			if req.CancelAtPeriodEnd {
+				return &models.Subscription{Status: models.SubscriptionStatusActive, CurrentPeriodEnd: time.Unix(0, 0), CancelAt: &periodEnd}
+			}
+			return &models.Subscription{Status: models.SubscriptionStatusCanceled, CurrentPeriodEnd: providerCanceledAt, CanceledAt: &providerCanceledAt}
+		}},
+		{name: "razorpay", cancel: func(req *models.CancelSubscriptionRequest) *models.Subscription {
+			if req.CancelAtPeriodEnd {
+				return &models.Subscription{Status: models.SubscriptionStatusActive, CurrentPeriodEnd: periodEnd}
+			}
+			return &models.Subscription{Status: models.SubscriptionStatusCanceled, CurrentPeriodEnd: periodEnd, CanceledAt: &providerCanceledAt}
+		}},
+		{name: "airwallex", cancel: func(req *models.CancelSubscriptionRequest) *models.Subscription {
+			if req.CancelAtPeriodEnd {
+				return &models.Subscription{Status: models.SubscriptionStatus("pending"), CurrentPeriodEnd: periodEnd, CanceledAt: &periodEnd}
+			}
+			return &models.Subscription{Status: models.SubscriptionStatusCanceled, CurrentPeriodEnd: periodEnd}
+		}},
+	}
+
+	for _, provider := range fakes {
+		for _, atPeriodEnd := range []bool{true, false} {
+			name := provider.name + "/immediate"
+			if atPeriodEnd {
+				name = provider.name + "/period_end"
+			}
+			t.Run(name, func(t *testing.T) {
+				tenantID := "tenant_1"
+				store := &fakeSubscriptionStore{subscriptions: map[string]*models.Subscription{
+					"sub_1": {ID: "sub_1", TenantID: &tenantID, PlanID: "plan_1", Status: models.SubscriptionStatusActive, CurrentPeriodEnd: periodEnd, ProviderName: provider.name},
+				}}
+				svc := CreateSubscriptionService(nil, store, provider)
+				svc.now = func() time.Time { return now }
+
+				got, err := svc.CancelSubscription(context.Background(), "sub_1", &models.CancelSubscriptionRequest{CancelAtPeriodEnd: atPeriodEnd})
+				if err != nil {
+					t.Fatalf("cancel: %v", err)
+				}
+
+				if atPeriodEnd {
+					if got.Status != models.SubscriptionStatusActive {
+						t.Fatalf("expected active, got %s", got.Status)
+					}
+					if got.CancelAt == nil || !got.CancelAt.Equal(periodEnd) {
+						t.Fatalf("expected cancel_at %s, got %v", periodEnd, got.CancelAt)
+					}
+					if got.CanceledAt != nil {
+						t.Fatalf("expected no canceled_at, got %s", got.CanceledAt)
+					}
+				} else {
+					if got.Status != models.SubscriptionStatusCanceled {
+						t.Fatalf("expected canceled, got %s", got.Status)
+					}
+					if got.CanceledAt == nil || !got.CanceledAt.Equal(now) {
+						t.Fatalf("expected canceled_at %s, got %v", now, got.CanceledAt)
+					}
+					if got.CancelAt != nil {
+						t.Fatalf("expected no cancel_at, got %s", got.CancelAt)
+					}
+				}
+
+				if got.TenantID == nil || got.PlanID != "plan_1" {
+					t.Fatalf("expected stored fields to be kept, got %+v", got)
+				}
+				stored := store.subscriptions["sub_1"]
+				if stored.Status != got.Status {
+					t.Fatalf("expected stored status %s, got %s", got.Status, stored.Status)
+				}
+			})
+		}
+	}
+}
+
+type fakeMeteredProvider struct {
+	providers.PaymentProvider
+	name  string
+	usage []*models.UsageEvent
+}
+
+func (f *fakeMeteredProvider) Name() string                     { return f.name }
+func (f *fakeMeteredProvider) IsAvailable(context.Context) bool { return true }
+
+func (f *fakeMeteredProvider) RecordUsage(_ context.Context, usage *models.UsageEvent) (*models.UsageRecord, error) {
+	f.usage = append(f.usage, usage)
+	return &models.UsageRecord{ID: "usage_1", Quantity: usage.Quantity, ProviderName: f.name}, nil
+}
+
+func TestRecordUsageRoutesToSubscriptionProvider(t *testing.T) {
+	first := &fakeMeteredProvider{name: "first"}
+	owner := &fakeMeteredProvider{name: "owner"}
+	store := &fakeSubscriptionStore{subscriptions: map[string]*models.Subscription{
+		"sub_1": {
+			ID:           "sub_1",
+			CustomerID:   "cus_1",
+			PlanID:       "plan_1",
+			Plan:         &models.Plan{ID: "plan_1", PricingType: models.PricingTypeMetered, MeterEventName: "conductor_api_calls"},
+			ProviderName: "owner",
+		},
+	}}
+	svc := CreateSubscriptionService(nil, store, first, owner)
+
+	record, err := svc.RecordUsage(context.Background(), "sub_1", &models.RecordUsageRequest{Quantity: 3})
+	if err != nil {
+		t.Fatalf("record usage: %v", err)
+	}
+
+	if len(first.usage) != 0 {
+		t.Fatalf("expected no usage on the first available provider, got %d", len(first.usage))
+	}
+	if len(owner.usage) != 1 {
+		t.Fatalf("expected usage on the subscription's provider, got %d", len(owner.usage))
+	}
+	usage := owner.usage[0]
+	if usage.EventName != "conductor_api_calls" || usage.CustomerID != "cus_1" || usage.Quantity != 3 {
+		t.Fatalf("unexpected usage event: %+v", usage)
+	}
+	if record.SubscriptionID != "sub_1" {
+		t.Fatalf("expected subscription id on record, got %q", record.SubscriptionID)
+	}
+}
+
+type fakeIdempotencyRepo struct {
+	keys map[string]*models.IdempotencyResult
+}
+
+func (f *fakeIdempotencyRepo) GetOrCreate(_ context.Context, key, _, _ string, requestBody []byte, _ time.Duration) (*models.IdempotencyResult, error) {
+	if existing, ok := f.keys[key]; ok {
+		if existing.Key.RequestHash != string(requestBody) {
+			return nil, stores.ErrIdempotencyMismatch
+		}
+		return &models.IdempotencyResult{Key: existing.Key, ResponseCode: existing.ResponseCode, ResponseBody: existing.ResponseBody}, nil
+	}
+	result := &models.IdempotencyResult{IsNew: true, Key: &models.IdempotencyKey{Key: key, RequestHash: string(requestBody)}}
+	f.keys[key] = result
+	return result, nil
+}
+
+func (f *fakeIdempotencyRepo) Complete(_ context.Context, key string, responseCode int, responseBody interface{}) error {
+	body, err := json.Marshal(responseBody)
+	if err != nil {
+		return err
+	}
+	f.keys[key].ResponseCode = responseCode
+	f.keys[key].ResponseBody = body
+	return nil
+}
+
+func (f *fakeIdempotencyRepo) Unlock(_ context.Context, key string) error {
+	return nil
+}
+
+type fakeAuditRepo struct {
+	AuditRepository
+	logs []*models.AuditLog
+}
+
+func (f *fakeAuditRepo) Create(_ context.Context, log *models.AuditLog) error {
+	f.logs = append(f.logs, log)
+	return nil
+}
+
+func TestRetriedCancelWithSameKeyCallsProviderOnce(t *testing.T) {
+	tenantID := "tenant_1"
+	store := &fakeSubscriptionStore{subscriptions: map[string]*models.Subscription{
+		"sub_1": {ID: "sub_1", TenantID: &tenantID, PlanID: "plan_1", Status: models.SubscriptionStatusActive, ProviderName: "stripe"},
+	}}
+	calls := 0
+	provider := &fakeCancelProvider{name: "stripe", cancel: func(*models.CancelSubscriptionRequest) *models.Subscription {
+		calls++
+		return &models.Subscription{Status: models.SubscriptionStatusCanceled}
+	}}
+	audit := &fakeAuditRepo{}
+	svc := CreateSubscriptionService(nil, store, provider)
+	svc.SetIdempotency(&fakeIdempotencyRepo{keys: map[string]*models.IdempotencyResult{}}, 0)
+	svc.SetAuditService(CreateAuditService(audit))
+	ctx := context.WithValue(context.Background(), ctxkeys.TenantID, tenantID)
+
+	req := &models.CancelSubscriptionRequest{IdempotencyKey: "cancel-sub_1"}
+	first, err := svc.CancelSubscription(ctx, "sub_1", req)
+	if err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	second, err := svc.CancelSubscription(ctx, "sub_1", req)
+	if err != nil {
+		t.Fatalf("retried cancel: %v", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected the provider to be called once, got %d", calls)
+	}
+	if second.ID != first.ID || second.Status != models.SubscriptionStatusCanceled {
+		t.Fatalf("expected the retry to replay the canceled subscription, got %+v", second)
+	}
+
+	if len(audit.logs) != 1 {
+		t.Fatalf("expected one audit entry, got %d", len(audit.logs))
+	}
+	entry := audit.logs[0]
+	if entry.Action != string(models.AuditActionCancel) || entry.ResourceType != string(models.AuditResourceSubscription) || entry.ResourceID != "sub_1" || !entry.Success {
+		t.Fatalf("unexpected audit entry %+v", entry)
+	}
+	if entry.TenantID == nil || *entry.TenantID != tenantID {
+		t.Fatalf("expected the audit entry to belong to %s, got %v", tenantID, entry.TenantID)
+	}
+	before, _ := entry.Metadata["before"].(models.JSON)
+	after, _ := entry.Metadata["after"].(models.JSON)
+	if before["status"] != string(models.SubscriptionStatusActive) || after["status"] != string(models.SubscriptionStatusCanceled) {
+		t.Fatalf("expected before/after state in the audit entry, got %+v", entry.Metadata)
+	}
+
+	reused := &models.CancelSubscriptionRequest{IdempotencyKey: "cancel-sub_1", CancelAtPeriodEnd: true}
+	if _, err := svc.CancelSubscription(ctx, "sub_1", reused); !errors.Is(err, ErrIdempotencyConflict) {
+		t.Fatalf("expected ErrIdempotencyConflict for a different request, got %v", err)
+	}
+}
+
+type fakeUpdateProvider struct {
+	fakeCancelProvider
+	err error
+}
+
+func (f *fakeUpdateProvider) UpdateSubscription(_ context.Context, id string, req *models.UpdateSubscriptionRequest) (*models.Subscription, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &models.Subscription{ID: id, Quantity: *req.Quantity, Status: models.SubscriptionStatusActive}, nil
+}
+
+func TestUpdateSubscriptionAuditsFailures(t *testing.T) {
+	store := &fakeSubscriptionStore{subscriptions: map[string]*models.Subscription{
+		"sub_1": {ID: "sub_1", Quantity: 1, Status: models.SubscriptionStatusActive},
+	}}
+	provider := &fakeUpdateProvider{fakeCancelProvider: fakeCancelProvider{name: "stripe"}}
+	audit := &fakeAuditRepo{}
+	svc := CreateSubscriptionService(nil, store, provider)
+	svc.SetAuditService(CreateAuditService(audit))
+
+	quantity := 3
+	if _, err := svc.UpdateSubscription(context.Background(), "sub_1", &models.UpdateSubscriptionRequest{Quantity: &quantity}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	provider.err = errors.New("provider unavailable")
+	if _, err := svc.UpdateSubscription(context.Background(), "sub_1", &models.UpdateSubscriptionRequest{Quantity: &quantity}); err == nil {
+		t.Fatal("expected the provider error")
+	}
+
+	if len(audit.logs) != 2 {
+		t.Fatalf("expected an audit entry per update, got %d", len(audit.logs))
+	}
+	if ok := audit.logs[0]; !ok.Success || ok.Action != string(models.AuditActionUpdate) || ok.Metadata["after"].(models.JSON)["quantity"] != float64(3) {
+		t.Fatalf("unexpected audit entry for the successful update %+v", ok)
+	}
+	if failed := audit.logs[1]; failed.Success || failed.ErrorMessage == "" {
+		t.Fatalf("expected the failed update to be audited as a failure, got %+v", failed)
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+// taxLineItemName is the invoice line that bills automatically calculated
+// tax.
+const taxLineItemName = "Tax"
+
+// calculateTax prices tax with the provider, returning
+// providers.ErrNotSupported when it cannot calculate tax.
+func calculateTax(ctx context.Context, provider providers.PaymentProvider, req *models.CalculateTaxRequest) (*models.TaxCalculation, error) {
+	taxProvider, ok := provider.(providers.TaxProvider)
+	if !ok {
+		return nil, providers.ErrNotSupported
+	}
+	calc, err := callProvider(ctx, "calculate_tax", func(ctx context.Context) (*models.TaxCalculation, error) {
+		return taxProvider.CalculateTax(ctx, req)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate tax: %w", err)
+	}
+	return calc, nil
+}
+
+// applyChargeTax calculates tax on the charge amount and adds it, so the
+// provider is asked for the taxed total.
+func (s *PaymentService) applyChargeTax(ctx context.Context, req *models.ChargeRequest) (*models.TaxCalculation, error) {
+	calc, err := calculateTax(ctx, s.provider, &models.CalculateTaxRequest{
+		Currency:   req.Currency,
+		CustomerID: req.CustomerID,
+		Address:    req.TaxAddress,
+		LineItems:  []models.TaxLineItem{{Reference: "charge", Amount: req.Amount}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req.Amount += calc.TaxAmount
+	return calc, nil
+}
+
+// applyInvoiceTax calculates tax per invoice line, or on the amount when the
+// invoice has no lines, and bills it as an extra line so the provider's
+// total includes it.
+func (s *InvoiceService) applyInvoiceTax(ctx context.Context, req *models.CreateInvoiceRequest) (*models.TaxCalculation, error) {
+	taxReq := &models.CalculateTaxRequest{
+		Currency:   req.Currency,
+		CustomerID: req.CustomerID,
+		Address:    req.TaxAddress,
+	}
+	for i, item := range req.LineItems {
+		taxReq.LineItems = append(taxReq.LineItems, models.TaxLineItem{
+			Reference: fmt.Sprintf("line_%d", i+1),
+			Amount:    item.Total(),
+		})
+	}
+	if len(taxReq.LineItems) == 0 {
+		taxReq.LineItems = []models.TaxLineItem{{Reference: "invoice", Amount: req.Amount}}
+	}
+
+	calc, err := calculateTax(ctx, s.provider, taxReq)
+	if err != nil {
+		return nil, err
+	}
+	if calc.TaxAmount > 0 && len(req.LineItems) > 0 {
+		req.LineItems = append(req.LineItems, models.InvoiceLineItem{
+			Name:       taxLineItemName,
+			Quantity:   1,
+			UnitAmount: calc.TaxAmount,
+		})
+	}
+	req.Amount += calc.TaxAmount
+	return calc, nil
+}
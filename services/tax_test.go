@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+// fakeTaxCalculator charges a flat 10% on every line.
+type fakeTaxCalculator struct {
+	requests []*models.CalculateTaxRequest
+}
+
+func (f *fakeTaxCalculator) CalculateTax(_ context.Context, req *models.CalculateTaxRequest) (*models.TaxCalculation, error) {
+	f.requests = append(f.requests, req)
+	calc := &models.TaxCalculation{ID: "taxcalc_1", Currency: req.Currency}
+	for _, item := range req.LineItems {
+		calc.Subtotal += item.Amount
+	}
+	calc.TaxAmount = calc.Subtotal / 10
+	calc.Total = calc.Subtotal + calc.TaxAmount
+	calc.Breakdown = models.TaxBreakdown{{Amount: calc.TaxAmount, TaxableAmount: calc.Subtotal, Country: "US", State: "NY", TaxType: "sales_tax", Rate: "10.0"}}
+	return calc, nil
+}
+
+type fakeTaxChargeProvider struct {
+	fakeChargeProvider
+	fakeTaxCalculator
+}
+
+type fakeTaxInvoiceProvider struct {
+	fakeInvoiceProvider
+	fakeTaxCalculator
+}
+
+var nyAddress = &models.TaxAddress{Line1: "1 Main St", City: "New York", State: "NY", PostalCode: "10001", Country: "US"}
+
+func TestChargeWithAutomaticTaxStoresAndChargesTax(t *testing.T) {
+	store := &fakeChargeStore{}
+	provider := &fakeTaxChargeProvider{}
+	svc := CreatePaymentService(store, provider)
+
+	resp, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID:    "cus_1",
+		Amount:        1000,
+		Currency:      "USD",
+		PaymentMethod: "pm_card_visa",
+		AutomaticTax:  true,
+		TaxAddress:    nyAddress,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.requests) != 1 || provider.requests[0].LineItems[0].Amount != 1000 {
+		t.Fatalf("expected tax calculated on the 1000 charge amount, got %+v", provider.requests)
+	}
+	if resp.Amount != 1100 || resp.TaxAmount != 100 {
+		t.Fatalf("expected 100 tax charged on top for a 1100 total, got amount=%d tax=%d", resp.Amount, resp.TaxAmount)
+	}
+	stored := store.payments[0]
+	if stored.TaxAmount != 100 || stored.TaxCalculationID != "taxcalc_1" || len(stored.TaxBreakdown) != 1 {
+		t.Fatalf("expected the tax stored on the payment, got %+v", stored)
+	}
+}
+
+func TestChargeWithAutomaticTaxRequiresTaxProvider(t *testing.T) {
+	store := &fakeChargeStore{}
+	provider := &fakeChargeProvider{}
+	svc := CreatePaymentService(store, provider)
+
+	_, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		CustomerID:    "cus_1",
+		Amount:        1000,
+		Currency:      "USD",
+		PaymentMethod: "pm_card_visa",
+		AutomaticTax:  true,
+	})
+	if !errors.Is(err, providers.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if provider.charges.Load() != 0 || len(store.payments) != 0 {
+		t.Fatal("expected no charge when tax cannot be calculated")
+	}
+}
+
+func TestInvoiceWithAutomaticTaxBillsTaxLine(t *testing.T) {
+	provider := &fakeTaxInvoiceProvider{}
+	svc := CreateInvoiceService(provider)
+
+	req := multiLineInvoiceRequest(0)
+	req.AutomaticTax = true
+	req.TaxAddress = nyAddress
+	inv, err := svc.CreateInvoice(context.Background(), req)
+	if err != nil {
+		t.Fatalf("create invoice: %v", err)
+	}
+
+	if got := provider.requests[0].LineItems; len(got) != 2 || got[0].Amount != 4500 || got[1].Amount != 2500 {
+		t.Fatalf("expected tax calculated per line, got %+v", got)
+	}
+	if provider.created.Amount != 7700 || inv.Amount != 7700 {
+		t.Fatalf("expected the 700 tax added to the 7000 total, got provider=%d invoice=%d", provider.created.Amount, inv.Amount)
+	}
+	if last := inv.LineItems[len(inv.LineItems)-1]; last.Name != taxLineItemName || last.UnitAmount != 700 {
+		t.Fatalf("expected a tax line of 700, got %+v", last)
+	}
+	if inv.TaxAmount != 700 || len(inv.TaxBreakdown) != 1 || inv.TaxBreakdown[0].State != "NY" {
+		t.Fatalf("expected the tax recorded on the invoice, got amount=%d breakdown=%+v", inv.TaxAmount, inv.TaxBreakdown)
+	}
+}
@@ -6,16 +6,26 @@ import (
 
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/stores"
+	"gorm.io/gorm"
 )
 
 var (
 	ErrTenantNotFound = errors.New("tenant not found")
 	ErrTenantInactive = errors.New("tenant is inactive")
 	ErrInvalidAPIKey  = errors.New("invalid api key")
+
+	ErrInvalidDefaultCaptureMethod = errors.New("default_capture_method must be automatic or manual")
+	ErrTenantKeysNotEnabled        = errors.New("tenant encryption keys are not enabled")
 )
 
+// TenantKeyRotator replaces a tenant's data encryption key.
+type TenantKeyRotator interface {
+	RotateTenantKey(ctx context.Context, tenantID string) (string, error)
+}
+
 type TenantService struct {
 	store *stores.TenantStore
+	keys  TenantKeyRotator
 }
 
 func CreateTenantService(store *stores.TenantStore) *TenantService {
@@ -23,12 +33,23 @@ func CreateTenantService(store *stores.TenantStore) *TenantService {
 }
 
 func (s *TenantService) Create(ctx context.Context, req *models.CreateTenantRequest) (*models.Tenant, error) {
+	if req.DefaultCaptureMethod != "" && !req.DefaultCaptureMethod.Valid() {
+		return nil, ErrInvalidDefaultCaptureMethod
+	}
+
+	tier := req.RateLimitTier
+	if tier == "" {
+		tier = "default"
+	}
+
 	tenant := &models.Tenant{
-		Name:       req.Name,
-		WebhookURL: req.WebhookURL,
-		IsActive:   true,
-		Settings:   req.Settings,
-		Metadata:   req.Metadata,
+		Name:                 req.Name,
+		WebhookURL:           req.WebhookURL,
+		IsActive:             true,
+		RateLimitTier:        tier,
+		DefaultCaptureMethod: req.DefaultCaptureMethod,
+		Settings:             req.Settings,
+		Metadata:             req.Metadata,
 	}
 
 	if err := s.store.Create(ctx, tenant); err != nil {
@@ -39,6 +60,10 @@ func (s *TenantService) Create(ctx context.Context, req *models.CreateTenantRequ
 }
 
 func (s *TenantService) Update(ctx context.Context, id string, req *models.UpdateTenantRequest) (*models.Tenant, error) {
+	if req.DefaultCaptureMethod != "" && !req.DefaultCaptureMethod.Valid() {
+		return nil, ErrInvalidDefaultCaptureMethod
+	}
+
 	tenant, err := s.store.GetByID(ctx, id)
 	if err != nil {
 		return nil, ErrTenantNotFound
@@ -53,9 +78,25 @@ func (s *TenantService) Update(ctx context.Context, id string, req *models.Updat
 	if req.WebhookSecret != "" {
 		tenant.WebhookSecret = req.WebhookSecret
 	}
+	for provider, secret := range req.ProviderWebhookSecrets {
+		if tenant.ProviderWebhookSecrets == nil {
+			tenant.ProviderWebhookSecrets = make(map[string]string)
+		}
+		if secret == "" {
+			delete(tenant.ProviderWebhookSecrets, provider)
+			continue
+		}
+		tenant.ProviderWebhookSecrets[provider] = secret
+	}
 	if req.IsActive != nil {
 		tenant.IsActive = *req.IsActive
 	}
+	if req.RateLimitTier != "" {
+		tenant.RateLimitTier = req.RateLimitTier
+	}
+	if req.DefaultCaptureMethod != "" {
+		tenant.DefaultCaptureMethod = req.DefaultCaptureMethod
+	}
 	if req.Settings != nil {
 		tenant.Settings = req.Settings
 	}
@@ -94,7 +135,21 @@ func (s *TenantService) List(ctx context.Context, activeOnly bool, limit, offset
 }
 
 func (s *TenantService) Delete(ctx context.Context, id string) error {
-	return s.store.Delete(ctx, id)
+	return tenantNotFound(s.store.Delete(ctx, id))
+}
+
+func (s *TenantService) Restore(ctx context.Context, id string) (*models.Tenant, error) {
+	if err := tenantNotFound(s.store.Restore(ctx, id)); err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
+
+func tenantNotFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTenantNotFound
+	}
+	return err
 }
 
 func (s *TenantService) Deactivate(ctx context.Context, id string) error {
@@ -105,6 +160,24 @@ func (s *TenantService) RegenerateAPISecret(ctx context.Context, id string) (str
 	return s.store.RegenerateAPISecret(ctx, id)
 }
 
+// SetKeyRotator enables RotateEncryptionKey.
+func (s *TenantService) SetKeyRotator(keys TenantKeyRotator) {
+	s.keys = keys
+}
+
+// RotateEncryptionKey gives the tenant a new data encryption key and returns
+// its ID. The tenant's existing PII stays readable under the replaced key and
+// moves to the new one on the next re-encryption pass.
+func (s *TenantService) RotateEncryptionKey(ctx context.Context, id string) (string, error) {
+	if s.keys == nil {
+		return "", ErrTenantKeysNotEnabled
+	}
+	if _, err := s.store.GetByID(ctx, id); err != nil {
+		return "", ErrTenantNotFound
+	}
+	return s.keys.RotateTenantKey(ctx, id)
+}
+
 func (s *TenantService) ValidateCredentials(ctx context.Context, apiKey, apiSecret string) (*models.Tenant, error) {
 	tenant, err := s.store.ValidateCredentials(ctx, apiKey, apiSecret)
 	if err != nil {
@@ -116,6 +189,20 @@ func (s *TenantService) ValidateCredentials(ctx context.Context, apiKey, apiSecr
 	return tenant, nil
 }
 
+// ProviderWebhookSecret returns the signing secret the tenant configured for
+// its own provider account, or "" when inbound webhooks should be verified
+// with the global secret.
+func (s *TenantService) ProviderWebhookSecret(ctx context.Context, tenantID, provider string) (string, error) {
+	tenant, err := s.store.GetByID(ctx, tenantID)
+	if err != nil {
+		return "", ErrTenantNotFound
+	}
+	if !tenant.IsActive {
+		return "", ErrTenantInactive
+	}
+	return tenant.ProviderWebhookSecrets[provider], nil
+}
+
 func (s *TenantService) GetSettings(ctx context.Context, id string) (*models.TenantSettings, error) {
 	tenant, err := s.store.GetByID(ctx, id)
 	if err != nil {
@@ -130,6 +217,10 @@ func (s *TenantService) GetSettings(ctx context.Context, id string) (*models.Ten
 		WebhookRetryCount:    5,
 	}
 
+	if tenant.DefaultCaptureMethod != "" {
+		settings.DefaultCaptureMethod = string(tenant.DefaultCaptureMethod)
+	}
+
 	if tenant.Settings != nil {
 		if dp, ok := tenant.Settings["default_provider"].(string); ok {
 			settings.DefaultProvider = dp
@@ -143,12 +234,18 @@ func (s *TenantService) GetSettings(ctx context.Context, id string) (*models.Ten
 		if e3ds, ok := tenant.Settings["enable_3ds"].(bool); ok {
 			settings.Enable3DS = e3ds
 		}
-		if dcm, ok := tenant.Settings["default_capture_method"].(string); ok {
+		if dcm, ok := tenant.Settings["default_capture_method"].(string); ok && tenant.DefaultCaptureMethod == "" {
 			settings.DefaultCaptureMethod = dcm
 		}
 		if wrc, ok := tenant.Settings["webhook_retry_count"].(float64); ok {
 			settings.WebhookRetryCount = int(wrc)
 		}
+		if capture, ok := tenant.Settings[models.TenantSettingCaptureProviderResponses].(bool); ok {
+			settings.CaptureProviderResponses = capture
+		}
+		if fraudCheck, ok := tenant.Settings[models.TenantSettingFraudCheck].(bool); ok {
+			settings.FraudCheck = fraudCheck
+		}
 	}
 
 	return settings, nil
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+const (
+	PaymentEventFailed     = "payment.failed"
+	PaymentEventAuthorized = "payment.authorized"
+)
+
+var ErrThreeDSCallbackMismatch = errors.New("3DS callback does not match the payment")
+
+// threeDSReturnIDParams are the return URL parameters providers use to name
+// the payment the challenge was for: Stripe's payment_intent and Airwallex's
+// payment_intent_id.
+var threeDSReturnIDParams = []string{"payment_intent", "payment_intent_id"}
+
+// SetNotifier sends tenants a webhook when a payment is finalized outside a
+// provider webhook, such as by a 3DS callback.
+func (s *PaymentService) SetNotifier(notifier OutboundNotifier) {
+	s.notifier = notifier
+}
+
+// Complete3DSCallback finalizes a payment after the customer returns from a
+// 3DS challenge. The return parameters must name the payment's provider
+// charge, but the outcome comes from the provider, since the customer can
+// edit the return URL. A payment that already left requires_action, for
+// instance because the provider's webhook arrived first, is returned as is.
+func (s *PaymentService) Complete3DSCallback(ctx context.Context, req *models.ThreeDSCallbackRequest) (*models.ChargeResponse, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, req.PaymentID)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	for _, key := range threeDSReturnIDParams {
+		if id, ok := req.Params[key]; ok && id != payment.ProviderChargeID {
+			return nil, ErrThreeDSCallbackMismatch
+		}
+	}
+
+	if payment.Status != models.PaymentStatusRequiresAction {
+		return s.buildChargeResponse(payment), nil
+	}
+
+	charge, err := callProvider(ctx, "get_charge", func(ctx context.Context) (*models.ChargeResponse, error) {
+		return s.provider.GetCharge(ctx, payment.ProviderChargeID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var eventType string
+	switch charge.Status {
+	case models.PaymentStatusSuccess:
+		eventType = PaymentEventSucceeded
+		payment.CapturedAmount = charge.CapturedAmount
+		if payment.CapturedAmount == 0 {
+			payment.CapturedAmount = payment.Amount
+		}
+	case models.PaymentStatusRequiresCapture:
+		eventType = PaymentEventAuthorized
+	case models.PaymentStatusFailed, models.PaymentStatusCanceled:
+		eventType = PaymentEventFailed
+	default:
+		// Still requires action or processing; the provider's webhook
+		// finalizes it later.
+		return s.buildChargeResponse(payment), nil
+	}
+
+	payment.Status = charge.Status
+	payment.RequiresAction = false
+	payment.NextActionType = ""
+	payment.NextActionURL = ""
+	if err := s.paymentRepo.Update(ctx, payment); err != nil {
+		return nil, err
+	}
+	s.notifyPaymentFinalized(ctx, payment, eventType)
+
+	return s.buildChargeResponse(payment), nil
+}
+
+func (s *PaymentService) notifyPaymentFinalized(ctx context.Context, payment *models.Payment, eventType string) {
+	if s.notifier == nil || payment.TenantID == nil {
+		return
+	}
+
+	_ = s.notifier.SendOutboundWebhook(ctx, *payment.TenantID, eventType, payment, map[string]interface{}{
+		"payment_id":         payment.ID,
+		"customer_id":        payment.CustomerID,
+		"amount":             payment.Amount,
+		"captured_amount":    payment.CapturedAmount,
+		"currency":           payment.Currency,
+		"provider":           payment.ProviderName,
+		"provider_charge_id": payment.ProviderChargeID,
+		"status":             payment.Status,
+	})
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+// fakeThreeDSProvider reports the charge as the provider sees it after the
+// customer's challenge.
+type fakeThreeDSProvider struct {
+	providers.PaymentProvider
+	charge  *models.ChargeResponse
+	fetched []string
+}
+
+func (f *fakeThreeDSProvider) GetCharge(_ context.Context, providerChargeID string) (*models.ChargeResponse, error) {
+	f.fetched = append(f.fetched, providerChargeID)
+	return f.charge, nil
+}
+
+func TestThreeDSCallbackFinalizesSucceededPayment(t *testing.T) {
+	tenantID := "ten_1"
+	store := &fakePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {
+			ID:               "pay_1",
+			TenantID:         &tenantID,
+			CustomerID:       "cus_1",
+			Amount:           5000,
+			Currency:         "EUR",
+			Status:           models.PaymentStatusRequiresAction,
+			ProviderName:     "stripe",
+			ProviderChargeID: "pi_1",
+			RequiresAction:   true,
+			NextActionType:   "redirect_to_url",
+			NextActionURL:    "https://hooks.stripe.com/3d_secure_2/pi_1",
+		},
+	}}
+	provider := &fakeThreeDSProvider{charge: &models.ChargeResponse{
+		ProviderChargeID: "pi_1",
+		Amount:           5000,
+		CapturedAmount:   5000,
+		Status:           models.PaymentStatusSuccess,
+	}}
+	notifier := &fakeNotifier{}
+	svc := CreatePaymentService(store, provider)
+	svc.SetNotifier(notifier)
+
+	resp, err := svc.Complete3DSCallback(context.Background(), &models.ThreeDSCallbackRequest{
+		PaymentID: "pay_1",
+		Params:    map[string]string{"payment_intent": "pi_1", "redirect_status": "succeeded"},
+	})
+	if err != nil {
+		t.Fatalf("complete 3DS callback: %v", err)
+	}
+
+	if len(provider.fetched) != 1 || provider.fetched[0] != "pi_1" {
+		t.Fatalf("expected the charge to be fetched from the provider, got %v", provider.fetched)
+	}
+	if resp.Status != models.PaymentStatusSuccess || resp.RequiresAction {
+		t.Fatalf("expected a succeeded payment with no action pending, got %s (requires_action=%v)", resp.Status, resp.RequiresAction)
+	}
+	stored := store.payments["pay_1"]
+	if stored.Status != models.PaymentStatusSuccess || stored.CapturedAmount != 5000 || stored.NextActionURL != "" {
+		t.Fatalf("unexpected stored payment: %+v", stored)
+	}
+	if len(notifier.events) != 1 || notifier.events[0] != PaymentEventSucceeded {
+		t.Fatalf("expected one payment.succeeded webhook, got %v", notifier.events)
+	}
+
+	if _, err := svc.Complete3DSCallback(context.Background(), &models.ThreeDSCallbackRequest{PaymentID: "pay_1"}); err != nil {
+		t.Fatalf("repeat 3DS callback: %v", err)
+	}
+	if len(provider.fetched) != 1 || len(notifier.events) != 1 {
+		t.Fatal("expected a repeated callback to leave the finalized payment alone")
+	}
+}
+
+func TestThreeDSCallbackRejectsParamsForAnotherPayment(t *testing.T) {
+	store := &fakePaymentStore{payments: map[string]*models.Payment{
+		"pay_1": {ID: "pay_1", Amount: 5000, Status: models.PaymentStatusRequiresAction, ProviderName: "stripe", ProviderChargeID: "pi_1", RequiresAction: true},
+	}}
+	provider := &fakeThreeDSProvider{charge: &models.ChargeResponse{Status: models.PaymentStatusSuccess}}
+	notifier := &fakeNotifier{}
+	svc := CreatePaymentService(store, provider)
+	svc.SetNotifier(notifier)
+
+	_, err := svc.Complete3DSCallback(context.Background(), &models.ThreeDSCallbackRequest{
+		PaymentID: "pay_1",
+		Params:    map[string]string{"payment_intent": "pi_other", "redirect_status": "succeeded"},
+	})
+	if !errors.Is(err, ErrThreeDSCallbackMismatch) {
+		t.Fatalf("expected ErrThreeDSCallbackMismatch, got %v", err)
+	}
+	if len(provider.fetched) != 0 || len(notifier.events) != 0 || store.payments["pay_1"].Status != models.PaymentStatusRequiresAction {
+		t.Fatal("expected a mismatched callback to change nothing")
+	}
+}
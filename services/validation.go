@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/malwarebo/conductor/models"
+)
+
+// ErrValidation matches every *ValidationError, whatever fields failed.
+var ErrValidation = errors.New("validation failed")
+
+// Codes reported for a failing field. Like API error codes they never change
+// meaning once released.
+const (
+	FieldCodeRequired          = "required"
+	FieldCodeMustBePositive    = "must_be_positive"
+	FieldCodeMustNotBeNegative = "must_not_be_negative"
+	FieldCodeOutOfRange        = "out_of_range"
+	FieldCodeTooLong           = "too_long"
+	FieldCodeInvalid           = "invalid"
+	FieldCodeNotAllowed        = "not_allowed"
+	FieldCodeUnsupported       = "unsupported"
+)
+
+// ValidationError collects every field a request failed on, so a client can
+// show all of them at once rather than fixing one per round trip.
+type ValidationError struct {
+	Fields []models.FieldError
+	causes []error
+}
+
+func (e *ValidationError) Add(field, code, message string) {
+	e.Fields = append(e.Fields, models.FieldError{Field: field, Code: code, Message: message})
+}
+
+// AddErr records a failure whose cause callers may still match with
+// errors.Is, such as ErrAmountOutOfRange.
+func (e *ValidationError) AddErr(field, code string, err error) {
+	e.Add(field, code, err.Error())
+	e.causes = append(e.causes, err)
+}
+
+// Err returns e when any field failed and nil otherwise.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.causes
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/providers"
+)
+
+func TestChargeValidationReportsEveryFailure(t *testing.T) {
+	svc := CreatePaymentService(&fakeChargeStore{}, &fakeChargeProvider{})
+
+	_, err := svc.CreateCharge(context.Background(), &models.ChargeRequest{
+		Amount:               -5,
+		StatementDescriptor:  "THIS DESCRIPTOR IS FAR TOO LONG FOR ANY PROVIDER",
+		AllowRedirects:       "sometimes",
+		ApplicationFeeAmount: 100,
+	})
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	want := []models.FieldError{
+		{Field: "amount", Code: FieldCodeMustBePositive},
+		{Field: "currency", Code: FieldCodeRequired},
+		{Field: "payment_method", Code: FieldCodeRequired},
+		{Field: "statement_descriptor", Code: FieldCodeTooLong},
+		{Field: "allow_redirects", Code: FieldCodeInvalid},
+		{Field: "application_fee_amount", Code: FieldCodeOutOfRange},
+		{Field: "on_behalf_of", Code: FieldCodeRequired},
+	}
+	if len(verr.Fields) != len(want) {
+		t.Fatalf("expected %d field errors, got %+v", len(want), verr.Fields)
+	}
+	for i, w := range want {
+		if got := verr.Fields[i]; got.Field != w.Field || got.Code != w.Code {
+			t.Fatalf("field error %d: expected %s/%s, got %s/%s", i, w.Field, w.Code, got.Field, got.Code)
+		}
+	}
+
+	if !errors.Is(err, ErrValidation) {
+		t.Fatal("expected the error to match ErrValidation")
+	}
+	if !errors.Is(err, providers.ErrInvalidStatementDescriptor) {
+		t.Fatal("expected the descriptor failure to stay matchable")
+	}
+}
+
+func TestChargeValidationPassesValidRequest(t *testing.T) {
+	svc := CreatePaymentService(&fakeChargeStore{}, &fakeChargeProvider{})
+
+	err := svc.validateChargeRequest(context.Background(), &models.ChargeRequest{
+		Amount:        1000,
+		Currency:      "USD",
+		PaymentMethod: "pm_card_visa",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
@@ -8,22 +8,64 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/internal/metrics"
 	"github.com/malwarebo/conductor/models"
 	"github.com/malwarebo/conductor/stores"
+	"gorm.io/gorm"
 )
 
 const defaultWebhookMaxAttempts = 5
 
+var (
+	ErrWebhookEventNotFound        = errors.New("webhook event not found")
+	ErrWebhookEventNotDeadLettered = errors.New("webhook event is not dead-lettered")
+	ErrWebhookEventInProgress      = errors.New("webhook event is being processed")
+)
+
+type InvoiceEventHandler interface {
+	HandleInvoicePaymentFailed(ctx context.Context, subscriptionID, invoiceID string) error
+	HandleInvoicePaid(ctx context.Context, subscriptionID, invoiceID string) error
+}
+
+type DisputeEventHandler interface {
+	HandleDisputeEvent(ctx context.Context, event *DisputeEvent) error
+}
+
+type CheckoutEventHandler interface {
+	HandleCheckoutCompleted(ctx context.Context, providerName, providerSessionID, providerPaymentID string) error
+	HandleCheckoutExpired(ctx context.Context, providerName, providerSessionID string) error
+}
+
+// TenantLookup resolves the tenant an outbound event is delivered to.
+type TenantLookup interface {
+	GetByID(ctx context.Context, id string) (*models.Tenant, error)
+}
+
 type WebhookService struct {
-	webhookStore *stores.WebhookStore
-	paymentStore *stores.PaymentRepository
-	tenantStore  *stores.TenantStore
-	auditStore   *stores.AuditStore
-	httpClient   *http.Client
+	webhookStore   *stores.WebhookStore
+	paymentStore   *stores.PaymentRepository
+	tenantStore    TenantLookup
+	auditStore     *stores.AuditStore
+	deliveries     WebhookDeliveryRepository
+	endpoints      WebhookEndpointRepository
+	invoiceEvents  InvoiceEventHandler
+	disputeEvents  DisputeEventHandler
+	checkoutEvents CheckoutEventHandler
+	publisher      EventPublisher
+	topicPrefix    string
+	publishes      EventPublishRepository
+	httpClient     *http.Client
+	// handledEventTypes holds, per provider, the inbound event types that
+	// are stored and processed; see RegisterHandledEventTypes.
+	handledEventTypes map[string]map[string]bool
 }
 
 func CreateWebhookService(
@@ -32,23 +74,47 @@ func CreateWebhookService(
 	tenantStore *stores.TenantStore,
 	auditStore *stores.AuditStore,
 ) *WebhookService {
-	return &WebhookService{
+	s := &WebhookService{
 		webhookStore: webhookStore,
 		paymentStore: paymentStore,
-		tenantStore:  tenantStore,
 		auditStore:   auditStore,
+		publisher:    NoopEventPublisher{},
+		topicPrefix:  DefaultEventTopicPrefix,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
+	if tenantStore != nil {
+		s.tenantStore = tenantStore
+	}
+	if webhookStore != nil {
+		s.publishes = webhookStore
+	}
+	for provider, eventTypes := range defaultHandledEventTypes {
+		s.RegisterHandledEventTypes(provider, eventTypes...)
+	}
+	return s
+}
+
+func (s *WebhookService) SetInvoiceEventHandler(handler InvoiceEventHandler) {
+	s.invoiceEvents = handler
+}
+
+func (s *WebhookService) SetDisputeEventHandler(handler DisputeEventHandler) {
+	s.disputeEvents = handler
+}
+
+func (s *WebhookService) SetCheckoutEventHandler(handler CheckoutEventHandler) {
+	s.checkoutEvents = handler
 }
 
+// ProcessInboundWebhook stores a verified provider event for the workers and
+// forwards it to the event publisher. Event types the provider sends but
+// nothing handles are acknowledged without being stored.
 func (s *WebhookService) ProcessInboundWebhook(ctx context.Context, provider, eventID, eventType string, payload []byte) error {
-	if eventID != "" {
-		existing, _ := s.webhookStore.GetByEventID(ctx, provider, eventID)
-		if existing != nil {
-			return nil
-		}
+	if !s.HandlesEventType(provider, eventType) {
+		metrics.WebhookEventsTotal.Inc(provider, metrics.WebhookOutcomeIgnored)
+		return nil
 	}
 
 	var payloadJSON models.JSON
@@ -62,29 +128,117 @@ func (s *WebhookService) ProcessInboundWebhook(ctx context.Context, provider, ev
 		Status:      models.WebhookEventStatusPending,
 		MaxAttempts: defaultWebhookMaxAttempts,
 	}
+	if tenantID, ok := ctx.Value(ctxkeys.TenantID).(string); ok && tenantID != "" {
+		event.TenantID = &tenantID
+	}
 
-	if err := s.webhookStore.Create(ctx, event); err != nil {
+	created, err := s.webhookStore.CreateIfNotExists(ctx, event)
+	if err != nil {
+		metrics.WebhookEventsTotal.Inc(provider, metrics.WebhookOutcomeFailed)
 		return fmt.Errorf("failed to create webhook event: %w", err)
 	}
+	if !created {
+		// Another delivery of the same event already owns the row.
+		metrics.WebhookEventsTotal.Inc(provider, metrics.WebhookOutcomeDuplicate)
+		return nil
+	}
 
+	metrics.WebhookEventsTotal.Inc(provider, metrics.WebhookOutcomeAccepted)
+	s.publishEvent(ctx, event)
 	return nil
 }
 
+// ProcessEvent claims a single stored event and dispatches it. An event that
+// is already being processed or has completed is skipped without error.
+func (s *WebhookService) ProcessEvent(ctx context.Context, id string) error {
+	event, err := s.webhookStore.MarkProcessing(ctx, id)
+	if errors.Is(err, stores.ErrWebhookEventNotClaimable) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to claim webhook event: %w", err)
+	}
+	return s.ProcessClaimedEvent(ctx, event)
+}
+
 func (s *WebhookService) ProcessClaimedEvent(ctx context.Context, event *models.WebhookEvent) error {
 	if err := s.dispatchEvent(ctx, event); err != nil {
 		shouldRetry := event.Attempts < event.MaxAttempts
 		_ = s.webhookStore.MarkFailed(ctx, event.ID, err.Error(), shouldRetry)
+		metrics.WebhookEventsTotal.Inc(event.Provider, metrics.WebhookOutcomeFailed)
+		if !shouldRetry {
+			metrics.WebhookEventsTotal.Inc(event.Provider, metrics.WebhookOutcomeDeadLettered)
+		}
 		return err
 	}
+	metrics.WebhookEventsTotal.Inc(event.Provider, metrics.WebhookOutcomeProcessed)
 	return s.webhookStore.MarkCompleted(ctx, event.ID)
 }
 
+func (s *WebhookService) ListDeadLetterEvents(ctx context.Context, filter models.DeadLetterFilter) ([]*models.WebhookEvent, error) {
+	return s.webhookStore.ListDeadLetterEvents(ctx, filter.Provider, filter.Limit, filter.Offset)
+}
+
+// ReprocessEvent gives a dead-lettered event one more attempt and returns it
+// in its resulting state. A failed attempt puts the event back in the
+// dead-letter state with the new failure reason; that failure is reported on
+// the returned event rather than as an error.
+func (s *WebhookService) ReprocessEvent(ctx context.Context, id string) (*models.WebhookEvent, error) {
+	event, err := s.webhookStore.ReprocessEvent(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, ErrWebhookEventNotFound
+		case errors.Is(err, stores.ErrWebhookEventNotClaimable):
+			return nil, ErrWebhookEventNotDeadLettered
+		}
+		return nil, fmt.Errorf("failed to claim webhook event: %w", err)
+	}
+
+	// The failure reason is stored on the event by ProcessClaimedEvent.
+	_ = s.ProcessClaimedEvent(ctx, event)
+	return s.webhookStore.GetByID(ctx, event.ID)
+}
+
+// Reprocess replays a stored inbound event, identified by its provider and
+// the provider's event id, through the business handlers again. Unlike
+// ReprocessEvent it accepts events in any finished state, including
+// completed ones: the handlers are idempotent, so replaying an event that
+// already succeeded converges on the same result. The outcome is reported on
+// the returned event rather than as an error.
+func (s *WebhookService) Reprocess(ctx context.Context, provider, eventID string) (*models.WebhookEvent, error) {
+	stored, err := s.webhookStore.GetByEventID(ctx, provider, eventID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookEventNotFound
+		}
+		return nil, err
+	}
+
+	if _, err := s.webhookStore.ResetToPending(ctx, stored.ID); err != nil {
+		if errors.Is(err, stores.ErrWebhookEventNotClaimable) {
+			return nil, ErrWebhookEventInProgress
+		}
+		return nil, fmt.Errorf("failed to reset webhook event: %w", err)
+	}
+
+	// The failure reason is stored on the event by ProcessClaimedEvent.
+	_ = s.ProcessEvent(ctx, stored.ID)
+	return s.webhookStore.GetByID(ctx, stored.ID)
+}
+
 func (s *WebhookService) dispatchEvent(ctx context.Context, event *models.WebhookEvent) error {
+	if event.TenantID != nil && *event.TenantID != "" {
+		ctx = context.WithValue(ctx, ctxkeys.TenantID, *event.TenantID)
+	}
+
 	switch event.Provider {
 	case "stripe":
 		return s.processStripeEvent(ctx, event)
 	case "xendit":
 		return s.processXenditEvent(ctx, event)
+	case "razorpay":
+		return s.processRazorpayEvent(ctx, event)
 	default:
 		return fmt.Errorf("unknown provider: %s", event.Provider)
 	}
@@ -118,6 +272,8 @@ func (s *WebhookService) processStripeEvent(ctx context.Context, event *models.W
 		return s.handleChargeRefunded(ctx, object)
 	case "charge.dispute.created":
 		return s.handleDisputeCreated(ctx, object)
+	case "charge.dispute.updated", "charge.dispute.closed":
+		return s.handleStripeDispute(ctx, object)
 	case "invoice.paid":
 		return s.handleStripeInvoicePaid(ctx, object)
 	case "invoice.payment_failed":
@@ -136,6 +292,10 @@ func (s *WebhookService) processStripeEvent(ctx context.Context, event *models.W
 		return s.handleStripePayoutFailed(ctx, object)
 	case "payout.canceled":
 		return s.handleStripePayoutCanceled(ctx, object)
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded":
+		return s.handleStripeCheckoutCompleted(ctx, object)
+	case "checkout.session.expired":
+		return s.handleStripeCheckoutExpired(ctx, object)
 	}
 
 	return nil
@@ -189,7 +349,11 @@ func (s *WebhookService) handlePaymentSucceeded(ctx context.Context, object map[
 	}
 	payment.RequiresAction = false
 
-	return s.paymentStore.Update(ctx, payment)
+	if err := s.paymentStore.Update(ctx, payment); err != nil {
+		return err
+	}
+	s.notifyPayment(ctx, payment, PaymentEventSucceeded)
+	return nil
 }
 
 func (s *WebhookService) handlePaymentFailed(ctx context.Context, object map[string]interface{}) error {
@@ -287,6 +451,10 @@ func (s *WebhookService) handleChargeRefunded(ctx context.Context, object map[st
 }
 
 func (s *WebhookService) handleDisputeCreated(ctx context.Context, object map[string]interface{}) error {
+	if s.disputeEvents != nil {
+		return s.handleStripeDispute(ctx, object)
+	}
+
 	paymentIntentID, ok := object["payment_intent"].(string)
 	if !ok {
 		return nil
@@ -301,6 +469,162 @@ func (s *WebhookService) handleDisputeCreated(ctx context.Context, object map[st
 	return s.paymentStore.Update(ctx, payment)
 }
 
+func (s *WebhookService) processRazorpayEvent(ctx context.Context, event *models.WebhookEvent) error {
+	payload, ok := event.Payload["payload"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid payload structure")
+	}
+
+	switch event.EventType {
+	case "payment.dispute.created", "payment.dispute.under_review", "payment.dispute.action_required",
+		"payment.dispute.won", "payment.dispute.lost", "payment.dispute.closed":
+		return s.handleRazorpayDispute(ctx, payload)
+	case "payment.authorized", "payment.captured", "payment.failed":
+		return s.handleRazorpayPayment(ctx, event.EventType, payload)
+	}
+
+	return nil
+}
+
+// handleRazorpayPayment moves a payment through the states a UPI collect
+// (or checkout) payment reports once the customer acts on it.
+func (s *WebhookService) handleRazorpayPayment(ctx context.Context, eventType string, payload map[string]interface{}) error {
+	entity := razorpayEntity(payload, "payment")
+	// Razorpay payments are stored under their order ID.
+	orderID := stringField(entity, "order_id")
+	if orderID == "" {
+		return fmt.Errorf("missing order id")
+	}
+
+	payment, err := s.paymentStore.GetByProviderChargeID(ctx, orderID)
+	if err != nil {
+		return nil
+	}
+
+	switch eventType {
+	case "payment.authorized":
+		if payment.CaptureMethod == models.CaptureMethodManual {
+			payment.Status = models.PaymentStatusRequiresCapture
+		} else {
+			payment.Status = models.PaymentStatusProcessing
+		}
+	case "payment.captured":
+		payment.Status = models.PaymentStatusSuccess
+		if amount, ok := entity["amount"].(float64); ok {
+			payment.CapturedAmount = int64(amount)
+		}
+	case "payment.failed":
+		payment.Status = models.PaymentStatusFailed
+	}
+	payment.RequiresAction = false
+
+	if err := s.paymentStore.Update(ctx, payment); err != nil {
+		return err
+	}
+	if payment.Status == models.PaymentStatusSuccess {
+		s.notifyPayment(ctx, payment, PaymentEventSucceeded)
+	}
+	return nil
+}
+
+func (s *WebhookService) handleStripeDispute(ctx context.Context, object map[string]interface{}) error {
+	if s.disputeEvents == nil {
+		return nil
+	}
+	disputeID, ok := object["id"].(string)
+	if !ok {
+		return fmt.Errorf("missing dispute id")
+	}
+
+	event := &DisputeEvent{
+		Provider:          "stripe",
+		ProviderDisputeID: disputeID,
+		Status:            stripeDisputeStatus(object["status"]),
+		Reason:            stringField(object, "reason"),
+		Currency:          strings.ToUpper(stringField(object, "currency")),
+	}
+	event.ProviderPaymentID, _ = object["payment_intent"].(string)
+	if amount, ok := object["amount"].(float64); ok {
+		event.Amount = int64(amount)
+	}
+	if details, ok := object["evidence_details"].(map[string]interface{}); ok {
+		if dueBy, ok := details["due_by"].(float64); ok && dueBy > 0 {
+			event.DueBy = time.Unix(int64(dueBy), 0).UTC()
+		}
+	}
+
+	return s.disputeEvents.HandleDisputeEvent(ctx, event)
+}
+
+func (s *WebhookService) handleRazorpayDispute(ctx context.Context, payload map[string]interface{}) error {
+	if s.disputeEvents == nil {
+		return nil
+	}
+	dispute := razorpayEntity(payload, "dispute")
+	disputeID, ok := dispute["id"].(string)
+	if !ok {
+		return fmt.Errorf("missing dispute id")
+	}
+
+	event := &DisputeEvent{
+		Provider:          "razorpay",
+		ProviderDisputeID: disputeID,
+		Status:            razorpayDisputeStatus(dispute["status"]),
+		Reason:            stringField(dispute, "reason_code"),
+		Currency:          strings.ToUpper(stringField(dispute, "currency")),
+	}
+	// Razorpay payments are stored under their order ID.
+	event.ProviderPaymentID = stringField(razorpayEntity(payload, "payment"), "order_id")
+	if event.ProviderPaymentID == "" {
+		event.ProviderPaymentID = stringField(dispute, "payment_id")
+	}
+	if amount, ok := dispute["amount"].(float64); ok {
+		event.Amount = int64(amount)
+	}
+	if respondBy, ok := dispute["respond_by"].(float64); ok && respondBy > 0 {
+		event.DueBy = time.Unix(int64(respondBy), 0).UTC()
+	}
+
+	return s.disputeEvents.HandleDisputeEvent(ctx, event)
+}
+
+func stripeDisputeStatus(status interface{}) models.DisputeStatus {
+	switch status {
+	case "won":
+		return models.DisputeStatusWon
+	case "lost":
+		return models.DisputeStatusLost
+	case "warning_closed":
+		return models.DisputeStatusCanceled
+	default:
+		return models.DisputeStatusOpen
+	}
+}
+
+func razorpayDisputeStatus(status interface{}) models.DisputeStatus {
+	switch status {
+	case "won":
+		return models.DisputeStatusWon
+	case "lost":
+		return models.DisputeStatusLost
+	case "closed":
+		return models.DisputeStatusCanceled
+	default:
+		return models.DisputeStatusOpen
+	}
+}
+
+func razorpayEntity(payload map[string]interface{}, name string) map[string]interface{} {
+	wrapper, _ := payload[name].(map[string]interface{})
+	entity, _ := wrapper["entity"].(map[string]interface{})
+	return entity
+}
+
+func stringField(object map[string]interface{}, key string) string {
+	value, _ := object[key].(string)
+	return value
+}
+
 func (s *WebhookService) handleXenditPaymentSucceeded(ctx context.Context, payload map[string]interface{}) error {
 	paymentID, ok := payload["id"].(string)
 	if !ok {
@@ -317,7 +641,11 @@ func (s *WebhookService) handleXenditPaymentSucceeded(ctx context.Context, paylo
 		payment.CapturedAmount = int64(amount)
 	}
 
-	return s.paymentStore.Update(ctx, payment)
+	if err := s.paymentStore.Update(ctx, payment); err != nil {
+		return err
+	}
+	s.notifyPayment(ctx, payment, PaymentEventSucceeded)
+	return nil
 }
 
 func (s *WebhookService) handleXenditPaymentFailed(ctx context.Context, payload map[string]interface{}) error {
@@ -367,17 +695,25 @@ func (s *WebhookService) handleXenditRefundSucceeded(ctx context.Context, payloa
 
 func (s *WebhookService) handleStripeInvoicePaid(ctx context.Context, object map[string]interface{}) error {
 	subscriptionID, ok := object["subscription"].(string)
-	if !ok || subscriptionID == "" {
+	if !ok || subscriptionID == "" || s.invoiceEvents == nil {
 		return nil
 	}
+	invoiceID, _ := object["id"].(string)
+	if err := s.invoiceEvents.HandleInvoicePaid(ctx, subscriptionID, invoiceID); err != nil && !errors.Is(err, ErrSubscriptionNotFound) {
+		return err
+	}
 	return nil
 }
 
 func (s *WebhookService) handleStripeInvoiceFailed(ctx context.Context, object map[string]interface{}) error {
 	subscriptionID, ok := object["subscription"].(string)
-	if !ok || subscriptionID == "" {
+	if !ok || subscriptionID == "" || s.invoiceEvents == nil {
 		return nil
 	}
+	invoiceID, _ := object["id"].(string)
+	if err := s.invoiceEvents.HandleInvoicePaymentFailed(ctx, subscriptionID, invoiceID); err != nil && !errors.Is(err, ErrSubscriptionNotFound) {
+		return err
+	}
 	return nil
 }
 
@@ -409,12 +745,45 @@ func (s *WebhookService) handleStripePayoutCanceled(ctx context.Context, object
 	return nil
 }
 
+// handleStripeCheckoutCompleted completes the checkout session once Stripe
+// reports it paid; delayed methods complete on async_payment_succeeded.
+func (s *WebhookService) handleStripeCheckoutCompleted(ctx context.Context, object map[string]interface{}) error {
+	if s.checkoutEvents == nil || stringField(object, "payment_status") != "paid" {
+		return nil
+	}
+	err := s.checkoutEvents.HandleCheckoutCompleted(ctx, "stripe", stringField(object, "id"), stringField(object, "payment_intent"))
+	return ignoreUnknownCheckout(err)
+}
+
+func (s *WebhookService) handleStripeCheckoutExpired(ctx context.Context, object map[string]interface{}) error {
+	if s.checkoutEvents == nil {
+		return nil
+	}
+	return ignoreUnknownCheckout(s.checkoutEvents.HandleCheckoutExpired(ctx, "stripe", stringField(object, "id")))
+}
+
+// handleXenditInvoicePaid completes the checkout session backed by the
+// invoice. Invoices created outside checkout have no session and are skipped.
 func (s *WebhookService) handleXenditInvoicePaid(ctx context.Context, payload map[string]interface{}) error {
-	return nil
+	if s.checkoutEvents == nil {
+		return nil
+	}
+	err := s.checkoutEvents.HandleCheckoutCompleted(ctx, "xendit", stringField(payload, "id"), stringField(payload, "payment_id"))
+	return ignoreUnknownCheckout(err)
 }
 
 func (s *WebhookService) handleXenditInvoiceExpired(ctx context.Context, payload map[string]interface{}) error {
-	return nil
+	if s.checkoutEvents == nil {
+		return nil
+	}
+	return ignoreUnknownCheckout(s.checkoutEvents.HandleCheckoutExpired(ctx, "xendit", stringField(payload, "id")))
+}
+
+func ignoreUnknownCheckout(err error) error {
+	if errors.Is(err, ErrCheckoutSessionNotFound) {
+		return nil
+	}
+	return err
 }
 
 func (s *WebhookService) handleXenditPayoutCompleted(ctx context.Context, payload map[string]interface{}) error {
@@ -477,54 +846,112 @@ func (s *WebhookService) handleXenditQRPaymentSucceeded(ctx context.Context, pay
 	return s.paymentStore.Update(ctx, payment)
 }
 
-func (s *WebhookService) SendOutboundWebhook(ctx context.Context, tenantID, eventType string, data map[string]interface{}) error {
+// SendOutboundWebhook delivers eventType to the tenant's webhook URL and to
+// every enabled endpoint subscribed to it. subject is the model the event
+// concerns and is serialized as the event's typed Object; data is sent
+// alongside it as the legacy payload. Every target receives the same event
+// ID, and a failure at one target does not stop delivery to the others.
+func (s *WebhookService) SendOutboundWebhook(ctx context.Context, tenantID, eventType string, subject interface{}, data map[string]interface{}) error {
 	tenant, err := s.tenantStore.GetByID(ctx, tenantID)
 	if err != nil {
 		return fmt.Errorf("failed to get tenant: %w", err)
 	}
 
-	if tenant.WebhookURL == "" {
+	var targets []webhookTarget
+	if tenant.WebhookURL != "" {
+		targets = append(targets, tenantTarget(tenant))
+	}
+	endpoints, err := s.subscribedEndpoints(ctx, tenantID, eventType)
+	if err != nil {
+		return fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	for _, endpoint := range endpoints {
+		targets = append(targets, endpointTarget(endpoint))
+	}
+	if len(targets) == 0 {
 		return nil
 	}
 
-	payload := &models.OutboundWebhook{
-		ID:        generateID(),
-		TenantID:  tenantID,
-		EventType: eventType,
-		Data:      data,
-		Timestamp: time.Now(),
+	event := newOutboundEvent(tenantID, eventType, subject, data)
+	var errs []error
+	for _, target := range targets {
+		if _, err := s.deliver(ctx, target, event, 1); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
+}
+
+// webhookTarget is where deliver sends an event: the tenant's own webhook
+// URL, or one of its endpoints when endpointID is set.
+type webhookTarget struct {
+	tenantID   string
+	endpointID *string
+	url        string
+	secret     string
+}
+
+func tenantTarget(tenant *models.Tenant) webhookTarget {
+	return webhookTarget{tenantID: tenant.ID, url: tenant.WebhookURL, secret: tenant.WebhookSecret}
+}
+
+func endpointTarget(endpoint *models.WebhookEndpoint) webhookTarget {
+	id := endpoint.ID
+	return webhookTarget{tenantID: endpoint.TenantID, endpointID: &id, url: endpoint.URL, secret: endpoint.Secret}
+}
 
-	payloadBytes, err := json.Marshal(payload)
+// deliver signs event with the target's secret, POSTs it to the target's URL
+// and records the attempt in the delivery log when one is set.
+func (s *WebhookService) deliver(ctx context.Context, target webhookTarget, event *models.OutboundEvent, attempt int) (*models.WebhookDelivery, error) {
+	event.Signature = ""
+	unsigned, err := json.Marshal(event)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	signature := s.signPayload(payloadBytes, tenant.WebhookSecret)
-	payload.Signature = signature
+	signature := s.signPayload(unsigned, target.secret)
+	event.Signature = signature
 
-	payloadBytes, _ = json.Marshal(payload)
+	payloadBytes, _ := json.Marshal(event)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", tenant.WebhookURL, bytes.NewReader(payloadBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", target.url, bytes.NewReader(payloadBytes))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Webhook-Signature", signature)
-	req.Header.Set("X-Webhook-ID", payload.ID)
+	req.Header.Set("X-Webhook-ID", event.ID)
+
+	var payload models.JSON
+	_ = json.Unmarshal(unsigned, &payload)
+	delivery := &models.WebhookDelivery{
+		TenantID:   target.tenantID,
+		EndpointID: target.endpointID,
+		EventID:    event.ID,
+		EventType:  event.EventType,
+		URL:        target.url,
+		Payload:    payload,
+		Attempt:    attempt,
+	}
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send webhook: %w", err)
+		err = fmt.Errorf("failed to send webhook: %w", err)
+		s.recordDelivery(ctx, delivery, err)
+		return delivery, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, deliveryResponseSnippetBytes))
+	delivery.StatusCode = resp.StatusCode
+	delivery.ResponseBody = string(snippet)
+
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("webhook delivery failed with status: %d", resp.StatusCode)
+		err = fmt.Errorf("webhook delivery failed with status: %d", resp.StatusCode)
 	}
-
-	return nil
+	s.recordDelivery(ctx, delivery, err)
+	return delivery, err
 }
 
 func (s *WebhookService) signPayload(payload []byte, secret string) string {
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+// deliveryResponseSnippetBytes caps how much of a tenant's response body is
+// kept with each delivery attempt.
+const deliveryResponseSnippetBytes = 1024
+
+var (
+	ErrWebhookDeliveryNotFound       = errors.New("webhook delivery not found")
+	ErrWebhookDeliveryTenantRequired = errors.New("tenant is required to read webhook deliveries")
+	ErrWebhookURLNotConfigured       = errors.New("tenant has no webhook URL configured")
+)
+
+type WebhookDeliveryRepository interface {
+	Create(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetByID(ctx context.Context, tenantID, id string) (*models.WebhookDelivery, error)
+	List(ctx context.Context, filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error)
+	CountByEvent(ctx context.Context, tenantID, eventID string) (int64, error)
+}
+
+// SetDeliveryLog records every outbound delivery attempt in repo so tenants
+// can inspect and replay them.
+func (s *WebhookService) SetDeliveryLog(repo WebhookDeliveryRepository) {
+	s.deliveries = repo
+}
+
+// recordDelivery stores the outcome of one attempt. Logging is best effort:
+// a failure to record never changes the result of the delivery itself.
+func (s *WebhookService) recordDelivery(ctx context.Context, delivery *models.WebhookDelivery, err error) {
+	delivery.Status = models.WebhookDeliveryStatusSucceeded
+	if err != nil {
+		delivery.Status = models.WebhookDeliveryStatusFailed
+		delivery.Error = err.Error()
+	}
+	if s.deliveries == nil {
+		return
+	}
+	_ = s.deliveries.Create(ctx, delivery)
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	if filter.TenantID == "" {
+		return nil, ErrWebhookDeliveryTenantRequired
+	}
+	if s.deliveries == nil {
+		return []*models.WebhookDelivery{}, nil
+	}
+	return s.deliveries.List(ctx, filter)
+}
+
+// Redeliver sends the event recorded by delivery id again, to the current URL
+// of the endpoint it went to or of the tenant. The event keeps its ID so
+// receivers can deduplicate, is re-signed with the current secret and is
+// logged as the next attempt for that event.
+func (s *WebhookService) Redeliver(ctx context.Context, tenantID, id string) (*models.WebhookDelivery, error) {
+	if tenantID == "" {
+		return nil, ErrWebhookDeliveryTenantRequired
+	}
+	if s.deliveries == nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+
+	original, err := s.deliveries.GetByID(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	target, err := s.redeliveryTarget(ctx, original)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(original.Payload)
+	if err != nil {
+		return nil, err
+	}
+	var event models.OutboundEvent
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode stored event: %w", err)
+	}
+
+	attempts, err := s.deliveries.CountByEvent(ctx, tenantID, original.EventID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.deliver(ctx, target, &event, int(attempts)+1)
+	if delivery == nil {
+		return nil, err
+	}
+	// The attempt itself is the result; a failed redelivery is reported
+	// through the delivery's status rather than as an error.
+	return delivery, nil
+}
+
+// redeliveryTarget resolves where a logged delivery is sent again. Endpoints
+// are looked up afresh so a changed URL is honored; a disabled endpoint
+// still receives an explicit redelivery.
+func (s *WebhookService) redeliveryTarget(ctx context.Context, original *models.WebhookDelivery) (webhookTarget, error) {
+	if original.EndpointID != nil {
+		endpoint, err := s.GetEndpoint(ctx, original.TenantID, *original.EndpointID)
+		if err != nil {
+			return webhookTarget{}, err
+		}
+		return endpointTarget(endpoint), nil
+	}
+
+	tenant, err := s.tenantStore.GetByID(ctx, original.TenantID)
+	if err != nil {
+		return webhookTarget{}, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	if tenant.WebhookURL == "" {
+		return webhookTarget{}, ErrWebhookURLNotConfigured
+	}
+	return tenantTarget(tenant), nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type fakeTenantLookup map[string]*models.Tenant
+
+func (f fakeTenantLookup) GetByID(_ context.Context, id string) (*models.Tenant, error) {
+	tenant, ok := f[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return tenant, nil
+}
+
+type fakeDeliveryRepo struct {
+	deliveries []*models.WebhookDelivery
+}
+
+func (f *fakeDeliveryRepo) Create(_ context.Context, delivery *models.WebhookDelivery) error {
+	delivery.ID = fmt.Sprintf("whd_%d", len(f.deliveries)+1)
+	f.deliveries = append(f.deliveries, delivery)
+	return nil
+}
+
+func (f *fakeDeliveryRepo) GetByID(_ context.Context, tenantID, id string) (*models.WebhookDelivery, error) {
+	for _, d := range f.deliveries {
+		if d.ID == id && d.TenantID == tenantID {
+			return d, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeDeliveryRepo) List(_ context.Context, filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	var out []*models.WebhookDelivery
+	for i := len(f.deliveries) - 1; i >= 0; i-- {
+		d := f.deliveries[i]
+		if d.TenantID != filter.TenantID {
+			continue
+		}
+		if filter.EventType != "" && d.EventType != filter.EventType {
+			continue
+		}
+		if filter.Status != "" && d.Status != filter.Status {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (f *fakeDeliveryRepo) CountByEvent(_ context.Context, tenantID, eventID string) (int64, error) {
+	var n int64
+	for _, d := range f.deliveries {
+		if d.TenantID == tenantID && d.EventID == eventID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+type recordedWebhook struct {
+	id        string
+	signature string
+	body      []byte
+}
+
+func TestWebhookDeliveriesAreLoggedAndRedelivered(t *testing.T) {
+	var mu sync.Mutex
+	var received []recordedWebhook
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, recordedWebhook{r.Header.Get("X-Webhook-ID"), r.Header.Get("X-Webhook-Signature"), body})
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("receiver down"))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	repo := &fakeDeliveryRepo{}
+	svc := CreateWebhookService(nil, nil, nil, nil)
+	svc.tenantStore = fakeTenantLookup{
+		"ten_1": {ID: "ten_1", WebhookURL: server.URL, WebhookSecret: "whsec"},
+		"ten_2": {ID: "ten_2", WebhookURL: server.URL, WebhookSecret: "other"},
+	}
+	svc.SetDeliveryLog(repo)
+	ctx := context.Background()
+
+	err := svc.SendOutboundWebhook(ctx, "ten_1", PaymentEventSucceeded, nil, map[string]interface{}{"payment_id": "pay_1"})
+	if err == nil {
+		t.Fatal("expected the failed delivery to be reported")
+	}
+	if err := svc.SendOutboundWebhook(ctx, "ten_1", "dispute.created", nil, map[string]interface{}{"dispute_id": "dp_1"}); err == nil {
+		t.Fatal("expected the failed delivery to be reported")
+	}
+	if err := svc.SendOutboundWebhook(ctx, "ten_2", PaymentEventSucceeded, nil, nil); err == nil {
+		t.Fatal("expected the failed delivery to be reported")
+	}
+
+	failed, err := svc.ListDeliveries(ctx, models.WebhookDeliveryFilter{
+		TenantID:  "ten_1",
+		EventType: PaymentEventSucceeded,
+		Status:    models.WebhookDeliveryStatusFailed,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(failed) != 1 {
+		t.Fatalf("expected 1 failed payment delivery for ten_1, got %d", len(failed))
+	}
+	first := failed[0]
+	if first.StatusCode != http.StatusServiceUnavailable || first.ResponseBody != "receiver down" || first.Attempt != 1 || first.URL != server.URL {
+		t.Fatalf("unexpected logged delivery: %+v", first)
+	}
+
+	if _, err := svc.Redeliver(ctx, "ten_2", first.ID); !errors.Is(err, ErrWebhookDeliveryNotFound) {
+		t.Fatalf("expected another tenant's delivery to be hidden, got %v", err)
+	}
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	redelivered, err := svc.Redeliver(ctx, "ten_1", first.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if redelivered.Status != models.WebhookDeliveryStatusSucceeded || redelivered.StatusCode != http.StatusOK || redelivered.Attempt != 2 {
+		t.Fatalf("unexpected redelivery: %+v", redelivered)
+	}
+	if redelivered.EventID != first.EventID {
+		t.Fatalf("expected redelivery to keep event %s, got %s", first.EventID, redelivered.EventID)
+	}
+
+	mu.Lock()
+	last := received[len(received)-1]
+	mu.Unlock()
+	if last.id != first.EventID {
+		t.Fatalf("expected X-Webhook-ID %s, got %s", first.EventID, last.id)
+	}
+	var event models.OutboundEvent
+	if err := json.Unmarshal(last.body, &event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.EventType != PaymentEventSucceeded || event.Data["payment_id"] != "pay_1" {
+		t.Fatalf("unexpected redelivered event: %s", last.body)
+	}
+	event.Signature = ""
+	unsigned, _ := json.Marshal(event)
+	if want := svc.signPayload(unsigned, "whsec"); last.signature != want {
+		t.Fatalf("expected redelivery signed with the tenant secret")
+	}
+
+	all, _ := svc.ListDeliveries(ctx, models.WebhookDeliveryFilter{TenantID: "ten_1"})
+	if len(all) != 3 || all[0].ID != redelivered.ID {
+		t.Fatalf("expected 3 deliveries newest first, got %d", len(all))
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+const webhookEndpointSecretPrefix = "whsec_"
+
+var (
+	ErrWebhookEndpointNotFound       = errors.New("webhook endpoint not found")
+	ErrWebhookEndpointTenantRequired = errors.New("tenant is required to manage webhook endpoints")
+	ErrInvalidWebhookEndpointURL     = errors.New("webhook endpoint url must be an absolute http or https URL")
+	ErrWebhookEndpointEventsNeeded   = errors.New("at least one event type is required")
+)
+
+type WebhookEndpointRepository interface {
+	Create(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	GetByID(ctx context.Context, tenantID, id string) (*models.WebhookEndpoint, error)
+	ListByTenant(ctx context.Context, tenantID string) ([]*models.WebhookEndpoint, error)
+	Update(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	Delete(ctx context.Context, tenantID, id string) error
+}
+
+// SetEndpointStore enables per-tenant webhook endpoints. Without it events
+// only go to the tenant's own webhook URL.
+func (s *WebhookService) SetEndpointStore(repo WebhookEndpointRepository) {
+	s.endpoints = repo
+}
+
+// CreateEndpoint registers an endpoint for the tenant. The signing secret is
+// generated unless the request supplies one, and is only returned here.
+func (s *WebhookService) CreateEndpoint(ctx context.Context, tenantID string, req *models.CreateWebhookEndpointRequest) (*models.WebhookEndpoint, string, error) {
+	if tenantID == "" {
+		return nil, "", ErrWebhookEndpointTenantRequired
+	}
+	if s.endpoints == nil {
+		return nil, "", errors.New("webhook endpoints are not enabled")
+	}
+	if err := validateWebhookEndpoint(req.URL, req.EventTypes); err != nil {
+		return nil, "", err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		generated, err := generateWebhookEndpointSecret()
+		if err != nil {
+			return nil, "", err
+		}
+		secret = generated
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		TenantID:    tenantID,
+		URL:         req.URL,
+		Description: req.Description,
+		Secret:      secret,
+		EventTypes:  req.EventTypes,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+	}
+	if err := s.endpoints.Create(ctx, endpoint); err != nil {
+		return nil, "", err
+	}
+	return endpoint, secret, nil
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context, tenantID string) ([]*models.WebhookEndpoint, error) {
+	if tenantID == "" {
+		return nil, ErrWebhookEndpointTenantRequired
+	}
+	if s.endpoints == nil {
+		return []*models.WebhookEndpoint{}, nil
+	}
+	return s.endpoints.ListByTenant(ctx, tenantID)
+}
+
+func (s *WebhookService) GetEndpoint(ctx context.Context, tenantID, id string) (*models.WebhookEndpoint, error) {
+	if tenantID == "" {
+		return nil, ErrWebhookEndpointTenantRequired
+	}
+	if s.endpoints == nil {
+		return nil, ErrWebhookEndpointNotFound
+	}
+	endpoint, err := s.endpoints.GetByID(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookEndpointNotFound
+		}
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *WebhookService) UpdateEndpoint(ctx context.Context, tenantID, id string, req *models.UpdateWebhookEndpointRequest) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.GetEndpoint(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != "" {
+		endpoint.URL = req.URL
+	}
+	if req.Description != "" {
+		endpoint.Description = req.Description
+	}
+	if req.EventTypes != nil {
+		endpoint.EventTypes = req.EventTypes
+	}
+	if req.Enabled != nil {
+		endpoint.Enabled = *req.Enabled
+	}
+	if err := validateWebhookEndpoint(endpoint.URL, endpoint.EventTypes); err != nil {
+		return nil, err
+	}
+
+	if err := s.endpoints.Update(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, tenantID, id string) error {
+	if tenantID == "" {
+		return ErrWebhookEndpointTenantRequired
+	}
+	if s.endpoints == nil {
+		return ErrWebhookEndpointNotFound
+	}
+	if err := s.endpoints.Delete(ctx, tenantID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrWebhookEndpointNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// subscribedEndpoints returns the tenant's enabled endpoints that take
+// eventType.
+func (s *WebhookService) subscribedEndpoints(ctx context.Context, tenantID, eventType string) ([]*models.WebhookEndpoint, error) {
+	if s.endpoints == nil {
+		return nil, nil
+	}
+	endpoints, err := s.endpoints.ListByTenant(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	var subscribed []*models.WebhookEndpoint
+	for _, endpoint := range endpoints {
+		if endpoint.Enabled && endpoint.Subscribes(eventType) {
+			subscribed = append(subscribed, endpoint)
+		}
+	}
+	return subscribed, nil
+}
+
+func validateWebhookEndpoint(rawURL string, eventTypes []string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidWebhookEndpointURL
+	}
+	if len(eventTypes) == 0 {
+		return ErrWebhookEndpointEventsNeeded
+	}
+	return nil
+}
+
+func generateWebhookEndpointSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return webhookEndpointSecretPrefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type fakeEndpointRepo struct {
+	endpoints []*models.WebhookEndpoint
+}
+
+func (f *fakeEndpointRepo) Create(_ context.Context, endpoint *models.WebhookEndpoint) error {
+	endpoint.ID = fmt.Sprintf("we_%d", len(f.endpoints)+1)
+	f.endpoints = append(f.endpoints, endpoint)
+	return nil
+}
+
+func (f *fakeEndpointRepo) GetByID(_ context.Context, tenantID, id string) (*models.WebhookEndpoint, error) {
+	for _, e := range f.endpoints {
+		if e.ID == id && e.TenantID == tenantID {
+			return e, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeEndpointRepo) ListByTenant(_ context.Context, tenantID string) ([]*models.WebhookEndpoint, error) {
+	var out []*models.WebhookEndpoint
+	for _, e := range f.endpoints {
+		if e.TenantID == tenantID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEndpointRepo) Update(_ context.Context, endpoint *models.WebhookEndpoint) error {
+	return nil
+}
+
+func (f *fakeEndpointRepo) Delete(_ context.Context, tenantID, id string) error {
+	for i, e := range f.endpoints {
+		if e.ID == id && e.TenantID == tenantID {
+			f.endpoints = append(f.endpoints[:i], f.endpoints[i+1:]...)
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func TestOutboundEventsFanOutToSubscribedEndpoints(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string][]recordedWebhook{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		received[r.URL.Path] = append(received[r.URL.Path], recordedWebhook{r.Header.Get("X-Webhook-ID"), r.Header.Get("X-Webhook-Signature"), body})
+	}))
+	defer server.Close()
+
+	deliveries := &fakeDeliveryRepo{}
+	svc := CreateWebhookService(nil, nil, nil, nil)
+	svc.tenantStore = fakeTenantLookup{"ten_1": {ID: "ten_1"}}
+	svc.SetDeliveryLog(deliveries)
+	svc.SetEndpointStore(&fakeEndpointRepo{})
+	ctx := context.Background()
+
+	payments, paymentsSecret, err := svc.CreateEndpoint(ctx, "ten_1", &models.CreateWebhookEndpointRequest{
+		URL:        server.URL + "/payments",
+		EventTypes: []string{PaymentEventSucceeded},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if paymentsSecret == "" || !payments.Enabled {
+		t.Fatalf("expected an enabled endpoint with a generated secret, got %+v", payments)
+	}
+	if _, _, err := svc.CreateEndpoint(ctx, "ten_1", &models.CreateWebhookEndpointRequest{
+		URL:        server.URL + "/disputes",
+		Secret:     "whsec_disputes",
+		EventTypes: []string{"dispute.created"},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := svc.SendOutboundWebhook(ctx, "ten_1", PaymentEventSucceeded, nil, map[string]interface{}{"payment_id": "pay_1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received["/payments"]) != 1 {
+		t.Fatalf("expected the payments endpoint to receive the event once, got %d", len(received["/payments"]))
+	}
+	if len(received["/disputes"]) != 0 {
+		t.Fatalf("expected the disputes endpoint not to receive a payment event, got %d", len(received["/disputes"]))
+	}
+
+	got := received["/payments"][0]
+	var event models.OutboundEvent
+	if err := json.Unmarshal(got.body, &event); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	event.Signature = ""
+	unsigned, _ := json.Marshal(event)
+	if got.signature != svc.signPayload(unsigned, paymentsSecret) {
+		t.Fatalf("expected the event signed with the endpoint's secret")
+	}
+
+	if len(deliveries.deliveries) != 1 {
+		t.Fatalf("expected 1 logged delivery, got %d", len(deliveries.deliveries))
+	}
+	if logged := deliveries.deliveries[0]; logged.EndpointID == nil || *logged.EndpointID != payments.ID {
+		t.Fatalf("expected the delivery to record endpoint %s, got %+v", payments.ID, logged.EndpointID)
+	}
+}
+
+func TestDisabledWebhookEndpointsReceiveNothing(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	svc := CreateWebhookService(nil, nil, nil, nil)
+	svc.tenantStore = fakeTenantLookup{"ten_1": {ID: "ten_1"}}
+	svc.SetEndpointStore(&fakeEndpointRepo{})
+	ctx := context.Background()
+
+	disabled := false
+	endpoint, _, err := svc.CreateEndpoint(ctx, "ten_1", &models.CreateWebhookEndpointRequest{
+		URL:        server.URL,
+		EventTypes: []string{models.WebhookEventAll},
+		Enabled:    &disabled,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.SendOutboundWebhook(ctx, "ten_1", PaymentEventSucceeded, nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("expected a disabled endpoint to receive nothing, got %d calls", n)
+	}
+
+	enabled := true
+	if _, err := svc.UpdateEndpoint(ctx, "ten_1", endpoint.ID, &models.UpdateWebhookEndpointRequest{Enabled: &enabled}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.SendOutboundWebhook(ctx, "ten_1", PaymentEventSucceeded, nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected a wildcard endpoint to receive the event once enabled, got %d calls", n)
+	}
+}
+
+func TestCreateWebhookEndpointValidates(t *testing.T) {
+	svc := CreateWebhookService(nil, nil, nil, nil)
+	svc.SetEndpointStore(&fakeEndpointRepo{})
+	ctx := context.Background()
+
+	if _, _, err := svc.CreateEndpoint(ctx, "ten_1", &models.CreateWebhookEndpointRequest{URL: "example.com/hook", EventTypes: []string{"*"}}); !errors.Is(err, ErrInvalidWebhookEndpointURL) {
+		t.Fatalf("expected ErrInvalidWebhookEndpointURL, got %v", err)
+	}
+	if _, _, err := svc.CreateEndpoint(ctx, "ten_1", &models.CreateWebhookEndpointRequest{URL: "https://example.com/hook"}); !errors.Is(err, ErrWebhookEndpointEventsNeeded) {
+		t.Fatalf("expected ErrWebhookEndpointEventsNeeded, got %v", err)
+	}
+	if _, err := svc.GetEndpoint(ctx, "ten_2", "we_1"); !errors.Is(err, ErrWebhookEndpointNotFound) {
+		t.Fatalf("expected ErrWebhookEndpointNotFound, got %v", err)
+	}
+}
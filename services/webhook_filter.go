@@ -0,0 +1,84 @@
+package services
+
+// defaultHandledEventTypes lists, per provider, the inbound event types the
+// dispatch switches act on. Events of any other type are acknowledged without
+// being stored; a type gains a handler by being added both here and to its
+// provider's switch.
+var defaultHandledEventTypes = map[string][]string{
+	"stripe": {
+		"payment_intent.succeeded",
+		"payment_intent.payment_failed",
+		"payment_intent.requires_action",
+		"payment_intent.canceled",
+		"payment_intent.amount_capturable_updated",
+		"charge.refunded",
+		"charge.dispute.created",
+		"charge.dispute.updated",
+		"charge.dispute.closed",
+		"invoice.paid",
+		"invoice.payment_failed",
+		"invoice.finalized",
+		"customer.subscription.created",
+		"customer.subscription.updated",
+		"customer.subscription.deleted",
+		"payout.paid",
+		"payout.failed",
+		"payout.canceled",
+		"checkout.session.completed",
+		"checkout.session.async_payment_succeeded",
+		"checkout.session.expired",
+	},
+	"xendit": {
+		"payment.succeeded",
+		"capture.succeeded",
+		"payment.failed",
+		"payment.pending",
+		"refund.succeeded",
+		"invoices.paid",
+		"invoice.paid",
+		"invoices.expired",
+		"invoice.expired",
+		"disbursement.completed",
+		"payout.completed",
+		"disbursement.failed",
+		"payout.failed",
+		"ewallet.payment.succeeded",
+		"virtual_account.paid",
+		"qr_code.payment.completed",
+	},
+	"razorpay": {
+		"payment.dispute.created",
+		"payment.dispute.under_review",
+		"payment.dispute.action_required",
+		"payment.dispute.won",
+		"payment.dispute.lost",
+		"payment.dispute.closed",
+		"payment.authorized",
+		"payment.captured",
+		"payment.failed",
+	},
+}
+
+// RegisterHandledEventTypes adds event types to the set accepted from
+// provider. Registered types are stored and forwarded to the event publisher
+// like handled ones, so a type with no handler of its own is still available
+// to downstream consumers.
+func (s *WebhookService) RegisterHandledEventTypes(provider string, eventTypes ...string) {
+	if s.handledEventTypes == nil {
+		s.handledEventTypes = make(map[string]map[string]bool)
+	}
+	types := s.handledEventTypes[provider]
+	if types == nil {
+		types = make(map[string]bool, len(eventTypes))
+		s.handledEventTypes[provider] = types
+	}
+	for _, eventType := range eventTypes {
+		types[eventType] = true
+	}
+}
+
+// HandlesEventType reports whether inbound events of eventType from provider
+// are stored and processed.
+func (s *WebhookService) HandlesEventType(provider, eventType string) bool {
+	return s.handledEventTypes[provider][eventType]
+}
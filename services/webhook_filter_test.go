@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/malwarebo/conductor/internal/metrics"
+)
+
+func TestUnhandledWebhookEventIsAcknowledgedWithoutStoring(t *testing.T) {
+	// No webhook store: storing the event would panic.
+	svc := CreateWebhookService(nil, nil, nil, nil)
+	ignored := metrics.WebhookEventsTotal.Value("stripe", metrics.WebhookOutcomeIgnored)
+	accepted := metrics.WebhookEventsTotal.Value("stripe", metrics.WebhookOutcomeAccepted)
+
+	payload := []byte(`{"id":"evt_1","type":"customer.created","data":{"object":{"id":"cus_1"}}}`)
+	if err := svc.ProcessInboundWebhook(context.Background(), "stripe", "evt_1", "customer.created", payload); err != nil {
+		t.Fatalf("expected an unhandled event type to be acknowledged, got %v", err)
+	}
+
+	if got := metrics.WebhookEventsTotal.Value("stripe", metrics.WebhookOutcomeIgnored); got != ignored+1 {
+		t.Fatalf("expected the event counted as ignored, counter went from %v to %v", ignored, got)
+	}
+	if got := metrics.WebhookEventsTotal.Value("stripe", metrics.WebhookOutcomeAccepted); got != accepted {
+		t.Fatalf("expected the event not to be accepted, counter went from %v to %v", accepted, got)
+	}
+}
+
+func TestRegisteredWebhookEventTypesAreHandled(t *testing.T) {
+	svc := CreateWebhookService(nil, nil, nil, nil)
+
+	if !svc.HandlesEventType("stripe", "payment_intent.succeeded") || !svc.HandlesEventType("razorpay", "payment.captured") {
+		t.Fatal("expected the built-in handler types to be handled")
+	}
+	if svc.HandlesEventType("stripe", "customer.created") || svc.HandlesEventType("airwallex", "payment_intent.succeeded") {
+		t.Fatal("expected types without a handler to be ignored")
+	}
+
+	svc.RegisterHandledEventTypes("stripe", "customer.created")
+	if !svc.HandlesEventType("stripe", "customer.created") {
+		t.Fatal("expected a registered type to be handled")
+	}
+	if svc.HandlesEventType("xendit", "customer.created") {
+		t.Fatal("expected registration to apply to its provider only")
+	}
+}
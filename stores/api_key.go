@@ -0,0 +1,62 @@
+package stores
+
+import (
+	"context"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type APIKeyStore struct {
+	BaseStore
+}
+
+func CreateAPIKeyStore(db *gorm.DB) *APIKeyStore {
+	return &APIKeyStore{BaseStore: BaseStore{db: db}}
+}
+
+func (s *APIKeyStore) Create(ctx context.Context, key *models.APIKey) error {
+	return s.GetDB(ctx).Create(key).Error
+}
+
+func (s *APIKeyStore) GetBySecretHash(ctx context.Context, secretHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.GetDB(ctx).First(&key, "secret_hash = ?", secretHash).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *APIKeyStore) GetByID(ctx context.Context, tenantID, id string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.GetDB(ctx).First(&key, "id = ? AND tenant_id = ?", id, tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *APIKeyStore) ListByTenant(ctx context.Context, tenantID string) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	if err := s.GetDB(ctx).Where("tenant_id = ?", tenantID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *APIKeyStore) Revoke(ctx context.Context, tenantID, id string, revokedAt time.Time) error {
+	result := s.GetDB(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND tenant_id = ? AND revoked_at IS NULL", id, tenantID).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *APIKeyStore) TouchLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	return s.GetDB(ctx).Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/malwarebo/conductor/internal/pagination"
 	"github.com/malwarebo/conductor/models"
 	"gorm.io/gorm"
 )
@@ -28,9 +29,9 @@ func (s *AuditStore) GetByID(ctx context.Context, id string) (*models.AuditLog,
 	return &log, nil
 }
 
-func (s *AuditStore) List(ctx context.Context, filter models.AuditLogFilter) ([]*models.AuditLog, int64, error) {
+// List returns one page of audit logs ordered newest first.
+func (s *AuditStore) List(ctx context.Context, filter models.AuditLogFilter) (pagination.Page[*models.AuditLog], error) {
 	var logs []*models.AuditLog
-	var total int64
 
 	query := s.GetDB(ctx).Model(&models.AuditLog{})
 
@@ -56,29 +57,22 @@ func (s *AuditStore) List(ctx context.Context, filter models.AuditLogFilter) ([]
 		query = query.Where("created_at <= ?", filter.EndDate)
 	}
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
 	}
 
-	if filter.Limit > 0 {
-		query = query.Limit(filter.Limit)
+	if err := pagination.Keyset(query, filter.Cursor, limit).Find(&logs).Error; err != nil {
+		return pagination.Page[*models.AuditLog]{}, err
 	}
-	if filter.Offset > 0 {
-		query = query.Offset(filter.Offset)
-	}
-
-	if err := query.Order("created_at DESC").Find(&logs).Error; err != nil {
-		return nil, 0, err
-	}
-
-	return logs, total, nil
+	return pagination.NewPage(logs, limit, models.AuditLogKey), nil
 }
 
-func (s *AuditStore) ListByResource(ctx context.Context, resourceType, resourceID string, limit int) ([]*models.AuditLog, error) {
+func (s *AuditStore) ListByResource(ctx context.Context, tenantID, resourceType, resourceID string, limit int) ([]*models.AuditLog, error) {
 	var logs []*models.AuditLog
 	query := s.GetDB(ctx).
-		Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).
-		Order("created_at DESC")
+		Where("tenant_id = ? AND resource_type = ? AND resource_id = ?", tenantID, resourceType, resourceID).
+		Order("created_at DESC, id DESC")
 
 	if limit > 0 {
 		query = query.Limit(limit)
@@ -0,0 +1,90 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/pagination"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/stores"
+)
+
+func seedAuditLogs(t *testing.T, store *stores.AuditStore, tenantID string, n int, base time.Time) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		log := &models.AuditLog{
+			TenantID:     &tenantID,
+			UserID:       "user_1",
+			Action:       string(models.AuditActionCharge),
+			ResourceType: string(models.AuditResourcePayment),
+			ResourceID:   fmt.Sprintf("pay_%d", i),
+			Success:      true,
+			// Groups of five share a timestamp so the id tie-breaker is exercised.
+			CreatedAt: base.Add(time.Duration(i/5) * time.Second),
+		}
+		if err := store.Create(context.Background(), log); err != nil {
+			t.Fatalf("seed audit log %d: %v", i, err)
+		}
+	}
+}
+
+func TestAuditListCursorPagination(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.AuditLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	store := stores.CreateAuditStore(db)
+	ctx := context.Background()
+
+	const tenantA, tenantB = "11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222"
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	seedAuditLogs(t, store, tenantA, 23, base)
+	seedAuditLogs(t, store, tenantB, 5, base)
+
+	seen := make(map[string]bool)
+	var ordered []*models.AuditLog
+	filter := models.AuditLogFilter{TenantID: tenantA, Limit: 4}
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("pagination did not terminate")
+		}
+
+		page, err := store.List(ctx, filter)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		for _, log := range page.Data {
+			if log.TenantID == nil || *log.TenantID != tenantA {
+				t.Fatalf("page leaked another tenant's audit log: %+v", log)
+			}
+			if seen[log.ID] {
+				t.Fatalf("audit log %s returned on more than one page", log.ID)
+			}
+			seen[log.ID] = true
+			ordered = append(ordered, log)
+		}
+
+		if !page.HasMore {
+			break
+		}
+		decoded, err := pagination.Decode(page.NextCursor)
+		if err != nil {
+			t.Fatalf("decode cursor: %v", err)
+		}
+		filter.Cursor = decoded
+	}
+
+	if len(ordered) != 23 {
+		t.Fatalf("expected all 23 audit logs across pages, got %d", len(ordered))
+	}
+	for i := 1; i < len(ordered); i++ {
+		prev, cur := ordered[i-1], ordered[i]
+		if cur.CreatedAt.After(prev.CreatedAt) || (cur.CreatedAt.Equal(prev.CreatedAt) && cur.ID > prev.ID) {
+			t.Fatalf("ordering broken at index %d: %s/%s after %s/%s", i, cur.CreatedAt, cur.ID, prev.CreatedAt, prev.ID)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type CheckoutSessionRepository struct {
+	BaseStore
+}
+
+func CreateCheckoutSessionRepository(db *gorm.DB) *CheckoutSessionRepository {
+	return &CheckoutSessionRepository{BaseStore: BaseStore{db: db}}
+}
+
+func (r *CheckoutSessionRepository) Create(ctx context.Context, session *models.CheckoutSession) error {
+	return r.GetDB(ctx).Create(session).Error
+}
+
+func (r *CheckoutSessionRepository) GetByID(ctx context.Context, id string) (*models.CheckoutSession, error) {
+	var session models.CheckoutSession
+	if err := r.GetDB(ctx).First(&session, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *CheckoutSessionRepository) GetByProviderSessionID(ctx context.Context, providerName, providerSessionID string) (*models.CheckoutSession, error) {
+	var session models.CheckoutSession
+	if err := r.GetDB(ctx).First(&session, "provider_name = ? AND provider_session_id = ?", providerName, providerSessionID).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *CheckoutSessionRepository) Update(ctx context.Context, session *models.CheckoutSession) error {
+	return r.GetDB(ctx).Save(session).Error
+}
@@ -2,6 +2,7 @@ package stores
 
 import (
 	"context"
+	"errors"
 
 	"github.com/malwarebo/conductor/models"
 	"gorm.io/gorm"
@@ -9,18 +10,27 @@ import (
 
 type CustomerStore struct {
 	BaseStore
+	fields *FieldCipher
 }
 
 func CreateCustomerStore(db *gorm.DB) *CustomerStore {
 	return &CustomerStore{BaseStore: BaseStore{db: db}}
 }
 
+func CreateCustomerStoreWithEncryption(db *gorm.DB, fields *FieldCipher) *CustomerStore {
+	return &CustomerStore{BaseStore: BaseStore{db: db}, fields: fields}
+}
+
 func (s *CustomerStore) Create(ctx context.Context, customer *models.Customer) error {
-	return s.GetDB(ctx).Create(customer).Error
+	return s.write(ctx, customer, func() error {
+		return s.GetDB(ctx).Create(customer).Error
+	})
 }
 
 func (s *CustomerStore) Update(ctx context.Context, customer *models.Customer) error {
-	return s.GetDB(ctx).Save(customer).Error
+	return s.write(ctx, customer, func() error {
+		return s.GetDB(ctx).Save(customer).Error
+	})
 }
 
 func (s *CustomerStore) GetByID(ctx context.Context, id string) (*models.Customer, error) {
@@ -28,6 +38,9 @@ func (s *CustomerStore) GetByID(ctx context.Context, id string) (*models.Custome
 	if err := s.GetDB(ctx).First(&customer, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
+	if err := s.decrypt(ctx, &customer); err != nil {
+		return nil, err
+	}
 	return &customer, nil
 }
 
@@ -36,12 +49,31 @@ func (s *CustomerStore) GetByExternalID(ctx context.Context, externalID string)
 	if err := s.GetDB(ctx).First(&customer, "external_id = ?", externalID).Error; err != nil {
 		return nil, err
 	}
+	if err := s.decrypt(ctx, &customer); err != nil {
+		return nil, err
+	}
 	return &customer, nil
 }
 
+// GetByEmail looks customers up by blind index, falling back to plaintext rows
+// written before encryption that ReEncrypt has not backfilled yet.
 func (s *CustomerStore) GetByEmail(ctx context.Context, email string) (*models.Customer, error) {
 	var customer models.Customer
-	if err := s.GetDB(ctx).First(&customer, "email = ?", email).Error; err != nil {
+	if s.fields == nil {
+		if err := s.GetDB(ctx).Where("email = ?", email).First(&customer).Error; err != nil {
+			return nil, err
+		}
+		return &customer, nil
+	}
+
+	err := s.GetDB(ctx).Where("email_hash = ?", s.fields.BlindIndex(email)).First(&customer).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = s.GetDB(ctx).Where("(email_hash IS NULL OR email_hash = '') AND email = ?", email).First(&customer).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decrypt(ctx, &customer); err != nil {
 		return nil, err
 	}
 	return &customer, nil
@@ -51,17 +83,114 @@ func (s *CustomerStore) Delete(ctx context.Context, id string) error {
 	return s.GetDB(ctx).Delete(&models.Customer{}, "id = ?", id).Error
 }
 
-func (s *CustomerStore) List(ctx context.Context, limit, offset int) ([]*models.Customer, error) {
+// List returns the tenant's customers newest first. With encryption enabled
+// the email filter matches on the blind index, so it stays an exact,
+// case-insensitive match rather than a substring search.
+func (s *CustomerStore) List(ctx context.Context, filter models.CustomerFilter) ([]*models.Customer, error) {
 	var customers []*models.Customer
-	query := s.GetDB(ctx)
-	if limit > 0 {
-		query = query.Limit(limit)
+	query := s.GetDB(ctx).Where("tenant_id = ?", filter.TenantID)
+	if filter.ExternalID != "" {
+		query = query.Where("external_id = ?", filter.ExternalID)
+	}
+	if filter.Email != "" {
+		if s.fields == nil {
+			query = query.Where("LOWER(email) = LOWER(?)", filter.Email)
+		} else {
+			query = query.Where("(email_hash = ? OR ((email_hash IS NULL OR email_hash = '') AND LOWER(email) = LOWER(?)))",
+				s.fields.BlindIndex(filter.Email), filter.Email)
+		}
 	}
-	if offset > 0 {
-		query = query.Offset(offset)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
 	}
-	if err := query.Find(&customers).Error; err != nil {
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+	if err := query.Order("created_at DESC").Find(&customers).Error; err != nil {
 		return nil, err
 	}
+	for _, customer := range customers {
+		if err := s.decrypt(ctx, customer); err != nil {
+			return nil, err
+		}
+	}
 	return customers, nil
 }
+
+// ReEncrypt rewrites customers whose PII is still plaintext, was encrypted
+// with a retired key or is missing its email blind index, returning the number
+// of rows updated.
+func (s *CustomerStore) ReEncrypt(ctx context.Context, batchSize int) (int, error) {
+	if s.fields == nil {
+		return 0, nil
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	updated := 0
+	lastID := ""
+	for {
+		var customers []*models.Customer
+		if err := s.GetDB(ctx).Where("id > ?", lastID).Order("id").Limit(batchSize).Find(&customers).Error; err != nil {
+			return updated, err
+		}
+		if len(customers) == 0 {
+			return updated, nil
+		}
+
+		for _, customer := range customers {
+			lastID = customer.ID
+			rowCtx := withRowTenant(ctx, customer.TenantID)
+			stale, err := s.fields.needsReEncrypt(rowCtx, customer.Email, customer.Phone)
+			if err != nil {
+				return updated, err
+			}
+			if err := s.decrypt(rowCtx, customer); err != nil {
+				return updated, err
+			}
+			if !stale && customer.EmailHash == s.fields.BlindIndex(customer.Email) {
+				continue
+			}
+			if err := s.encrypt(rowCtx, customer); err != nil {
+				return updated, err
+			}
+			if err := s.GetDB(ctx).Model(&models.Customer{}).Where("id = ?", customer.ID).UpdateColumns(map[string]interface{}{
+				"email":      customer.Email,
+				"email_hash": customer.EmailHash,
+				"phone":      customer.Phone,
+			}).Error; err != nil {
+				return updated, err
+			}
+			updated++
+		}
+	}
+}
+
+func (s *CustomerStore) write(ctx context.Context, customer *models.Customer, fn func() error) error {
+	if s.fields == nil {
+		return fn()
+	}
+
+	email, phone := customer.Email, customer.Phone
+	defer func() {
+		customer.Email, customer.Phone = email, phone
+	}()
+
+	if err := s.encrypt(withRowTenant(ctx, customer.TenantID), customer); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func (s *CustomerStore) encrypt(ctx context.Context, customer *models.Customer) error {
+	customer.EmailHash = s.fields.BlindIndex(customer.Email)
+	return s.fields.encryptFields(ctx, &customer.Email, &customer.Phone)
+}
+
+func (s *CustomerStore) decrypt(ctx context.Context, customer *models.Customer) error {
+	if s.fields == nil {
+		return nil
+	}
+	return s.fields.decryptFields(withRowTenant(ctx, customer.TenantID), &customer.Email, &customer.Phone)
+}
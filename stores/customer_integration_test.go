@@ -0,0 +1,88 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/stores"
+)
+
+func TestCustomerListScopedToTenant(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Customer{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+	store := stores.CreateCustomerStore(db)
+
+	tenantA, tenantB := "tenant_a", "tenant_b"
+	for _, c := range []*models.Customer{
+		{TenantID: &tenantA, ExternalID: "cus_a1", Email: "a1@example.com"},
+		{TenantID: &tenantA, ExternalID: "cus_a2", Email: "a2@example.com"},
+		{TenantID: &tenantB, ExternalID: "cus_b1", Email: "b1@example.com"},
+	} {
+		if err := store.Create(ctx, c); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	customers, err := store.List(ctx, models.CustomerFilter{TenantID: tenantA})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(customers) != 2 {
+		t.Fatalf("expected 2 customers for tenant_a, got %d", len(customers))
+	}
+	for _, c := range customers {
+		if c.TenantID == nil || *c.TenantID != tenantA {
+			t.Fatalf("expected only tenant_a customers, got %+v", c)
+		}
+	}
+
+	page, err := store.List(ctx, models.CustomerFilter{TenantID: tenantA, Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("list page: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != customers[1].ID {
+		t.Fatalf("expected second page to hold %s, got %+v", customers[1].ID, page)
+	}
+
+	byExternal, err := store.List(ctx, models.CustomerFilter{TenantID: tenantB, ExternalID: "cus_a1"})
+	if err != nil {
+		t.Fatalf("list by external id: %v", err)
+	}
+	if len(byExternal) != 0 {
+		t.Fatalf("expected another tenant's customer to be hidden, got %+v", byExternal)
+	}
+}
+
+func TestCustomerListSearchesEncryptedEmail(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Customer{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+	fields := stores.CreateFieldCipher(newEncryptionManager(t, newEncryptionKey(t)), []byte("index-key"))
+	store := stores.CreateCustomerStoreWithEncryption(db, fields)
+
+	tenantID := "tenant_a"
+	for _, c := range []*models.Customer{
+		{TenantID: &tenantID, ExternalID: "cus_1", Email: "jane@example.com"},
+		{TenantID: &tenantID, ExternalID: "cus_2", Email: "john@example.com"},
+	} {
+		if err := store.Create(ctx, c); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	customers, err := store.List(ctx, models.CustomerFilter{TenantID: tenantID, Email: "Jane@Example.com"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(customers) != 1 || customers[0].ExternalID != "cus_1" || customers[0].Email != "jane@example.com" {
+		t.Fatalf("expected the decrypted jane@example.com customer, got %+v", customers)
+	}
+}
@@ -2,6 +2,7 @@ package stores
 
 import (
 	"context"
+	"time"
 
 	"github.com/malwarebo/conductor/models"
 	"gorm.io/gorm"
@@ -27,6 +28,14 @@ func (r *DisputeRepository) GetByID(ctx context.Context, id string) (*models.Dis
 	return &dispute, nil
 }
 
+func (r *DisputeRepository) GetByProviderDisputeID(ctx context.Context, providerDisputeID string) (*models.Dispute, error) {
+	var dispute models.Dispute
+	if err := r.GetDB(ctx).First(&dispute, "provider_dispute_id = ?", providerDisputeID).Error; err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
 func (r *DisputeRepository) Update(ctx context.Context, dispute *models.Dispute) error {
 	return r.GetDB(ctx).Save(dispute).Error
 }
@@ -45,6 +54,21 @@ func (r *DisputeRepository) ListByCustomer(ctx context.Context, customerID strin
 	return disputes, err
 }
 
+// ListDueForReminder returns open disputes due in (after, before] that have
+// not been reminded yet, earliest deadline first.
+func (r *DisputeRepository) ListDueForReminder(ctx context.Context, after, before time.Time, limit int) ([]models.Dispute, error) {
+	var disputes []models.Dispute
+	err := r.GetDB(ctx).
+		Where("status = ? AND reminder_sent_at IS NULL AND due_by > ? AND due_by <= ?", models.DisputeStatusOpen, after, before).
+		Order("due_by ASC").
+		Limit(limit).
+		Find(&disputes).Error
+	if err != nil {
+		return nil, err
+	}
+	return disputes, nil
+}
+
 func (r *DisputeRepository) GetStats(ctx context.Context) (*models.DisputeStats, error) {
 	var stats models.DisputeStats
 	err := r.GetDB(ctx).Model(&models.Dispute{}).
@@ -0,0 +1,268 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/security"
+	"github.com/malwarebo/conductor/stores"
+)
+
+func newEncryptionKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := security.CreateGenerateEncryptionKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func newEncryptionManager(t *testing.T, key []byte) *security.EncryptionManager {
+	t.Helper()
+	manager, err := security.CreateEncryptionManager(key)
+	if err != nil {
+		t.Fatalf("encryption manager: %v", err)
+	}
+	return manager
+}
+
+func TestCustomerPIIEncryptedAtRest(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Customer{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+	manager := newEncryptionManager(t, newEncryptionKey(t))
+	fields := stores.CreateFieldCipher(manager, []byte("index-key"))
+	store := stores.CreateCustomerStoreWithEncryption(db, fields)
+
+	customer := &models.Customer{ExternalID: "cus_ext_1", Email: "jane@example.com", Phone: "+6591234567"}
+	if err := store.Create(ctx, customer); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if customer.Email != "jane@example.com" {
+		t.Fatalf("expected caller's struct to keep plaintext, got %q", customer.Email)
+	}
+
+	var raw models.Customer
+	if err := db.First(&raw, "id = ?", customer.ID).Error; err != nil {
+		t.Fatalf("read raw: %v", err)
+	}
+	prefix := "enc:" + manager.KeyID() + ":"
+	if !strings.HasPrefix(raw.Email, prefix) || !strings.HasPrefix(raw.Phone, prefix) {
+		t.Fatalf("expected ciphertext in the database, got email=%q phone=%q", raw.Email, raw.Phone)
+	}
+
+	byEmail, err := store.GetByEmail(ctx, "jane@example.com")
+	if err != nil {
+		t.Fatalf("get by email: %v", err)
+	}
+	if byEmail.ID != customer.ID || byEmail.Email != "jane@example.com" || byEmail.Phone != "+6591234567" {
+		t.Fatalf("expected decrypted customer, got %+v", byEmail)
+	}
+}
+
+func TestPaymentMethodReEncryptAfterKeyRotation(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.PaymentMethod{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+
+	oldKey := newEncryptionKey(t)
+	oldStore := stores.CreatePaymentMethodStoreWithEncryption(db, stores.CreateFieldCipher(newEncryptionManager(t, oldKey), nil))
+	pm := &models.PaymentMethod{
+		CustomerID:              "cus_1",
+		ProviderName:            "stripe",
+		ProviderPaymentMethodID: "pm_1",
+		Type:                    models.PMTypeCard,
+		Last4:                   "4242",
+		Brand:                   "visa",
+	}
+	if err := oldStore.Create(ctx, pm); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	manager, err := security.CreateEncryptionManager(newEncryptionKey(t), oldKey)
+	if err != nil {
+		t.Fatalf("encryption manager: %v", err)
+	}
+	store := stores.CreatePaymentMethodStoreWithEncryption(db, stores.CreateFieldCipher(manager, nil))
+
+	got, err := store.GetByID(ctx, pm.ID)
+	if err != nil {
+		t.Fatalf("get with retired key: %v", err)
+	}
+	if got.Last4 != "4242" || got.Brand != "visa" {
+		t.Fatalf("expected decrypted card details, got last4=%q brand=%q", got.Last4, got.Brand)
+	}
+
+	updated, err := store.ReEncrypt(ctx, 10)
+	if err != nil {
+		t.Fatalf("re-encrypt: %v", err)
+	}
+	if updated != 1 {
+		t.Fatalf("expected 1 row re-encrypted, got %d", updated)
+	}
+
+	var raw models.PaymentMethod
+	if err := db.First(&raw, "id = ?", pm.ID).Error; err != nil {
+		t.Fatalf("read raw: %v", err)
+	}
+	prefix := "enc:" + manager.KeyID() + ":"
+	if !strings.HasPrefix(raw.Last4, prefix) || !strings.HasPrefix(raw.Brand, prefix) {
+		t.Fatalf("expected fields re-encrypted with the primary key, got last4=%q brand=%q", raw.Last4, raw.Brand)
+	}
+}
+
+func TestCustomerReEncryptBackfillsEmailHash(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Customer{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ctx := context.Background()
+
+	legacy := &models.Customer{ExternalID: "cus_legacy", Email: "legacy@example.com"}
+	if err := stores.CreateCustomerStore(db).Create(ctx, legacy); err != nil {
+		t.Fatalf("create legacy: %v", err)
+	}
+
+	store := stores.CreateCustomerStoreWithEncryption(db, stores.CreateFieldCipher(newEncryptionManager(t, newEncryptionKey(t)), []byte("index-key")))
+	if found, err := store.GetByEmail(ctx, "legacy@example.com"); err != nil || found.ID != legacy.ID {
+		t.Fatalf("expected plaintext fallback lookup before backfill, got %+v, %v", found, err)
+	}
+
+	updated, err := store.ReEncrypt(ctx, 10)
+	if err != nil {
+		t.Fatalf("re-encrypt: %v", err)
+	}
+	if updated != 1 {
+		t.Fatalf("expected 1 customer re-encrypted, got %d", updated)
+	}
+
+	var raw models.Customer
+	if err := db.First(&raw, "id = ?", legacy.ID).Error; err != nil {
+		t.Fatalf("read raw: %v", err)
+	}
+	if raw.EmailHash == "" || !strings.HasPrefix(raw.Email, "enc:") {
+		t.Fatalf("expected encrypted email with a blind index, got email=%q hash=%q", raw.Email, raw.EmailHash)
+	}
+	if found, err := store.GetByEmail(ctx, "legacy@example.com"); err != nil || found.ID != legacy.ID {
+		t.Fatalf("expected blind index lookup after backfill, got %+v, %v", found, err)
+	}
+}
+
+func TestCustomerPIIEncryptedUnderTenantKeys(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Customer{}, &models.TenantDataKey{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	manager := newEncryptionManager(t, newEncryptionKey(t))
+	manager.SetTenantKeyStore(stores.CreateTenantKeyStore(db))
+	store := stores.CreateCustomerStoreWithEncryption(db, stores.CreateFieldCipher(manager, []byte("index-key")))
+
+	tenantA, tenantB := "ten_a", "ten_b"
+	ctxA := context.WithValue(context.Background(), ctxkeys.TenantID, tenantA)
+	ctxB := context.WithValue(context.Background(), ctxkeys.TenantID, tenantB)
+	customerA := &models.Customer{TenantID: &tenantA, ExternalID: "cus_a", Email: "a@example.com"}
+	customerB := &models.Customer{TenantID: &tenantB, ExternalID: "cus_b", Email: "b@example.com"}
+	if err := store.Create(ctxA, customerA); err != nil {
+		t.Fatalf("create a: %v", err)
+	}
+	if err := store.Create(ctxB, customerB); err != nil {
+		t.Fatalf("create b: %v", err)
+	}
+
+	keyA, _ := manager.KeyIDContext(ctxA)
+	keyB, _ := manager.KeyIDContext(ctxB)
+	var rawA models.Customer
+	if err := db.First(&rawA, "id = ?", customerA.ID).Error; err != nil {
+		t.Fatalf("read raw: %v", err)
+	}
+	if keyA == keyB || keyA == manager.KeyID() || !strings.HasPrefix(rawA.Email, "enc:"+keyA+":") {
+		t.Fatalf("expected tenant a's email under its own key %s, got %q", keyA, rawA.Email)
+	}
+
+	if _, err := manager.DecryptContext(ctxB, strings.TrimPrefix(rawA.Email, "enc:")); !errors.Is(err, security.ErrUnknownEncryptionKey) {
+		t.Fatalf("expected tenant b's key to be unable to read tenant a's email, got %v", err)
+	}
+	if got, err := store.GetByID(ctxB, customerA.ID); err != nil || got.Email != "a@example.com" {
+		t.Fatalf("expected the customer read with its own tenant's key, got %+v, %v", got, err)
+	}
+
+	rotated, err := manager.RotateTenantKey(context.Background(), tenantA)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	updated, err := store.ReEncrypt(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("re-encrypt: %v", err)
+	}
+	if updated != 1 {
+		t.Fatalf("expected only tenant a's customer re-encrypted, got %d", updated)
+	}
+	if err := db.First(&rawA, "id = ?", customerA.ID).Error; err != nil {
+		t.Fatalf("read raw: %v", err)
+	}
+	if !strings.HasPrefix(rawA.Email, "enc:"+rotated+":") {
+		t.Fatalf("expected tenant a's email under the rotated key %s, got %q", rotated, rawA.Email)
+	}
+}
+
+func TestPaymentMethodEncryptedUnderCustomerTenantKey(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Customer{}, &models.PaymentMethod{}, &models.TenantDataKey{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	manager := newEncryptionManager(t, newEncryptionKey(t))
+	manager.SetTenantKeyStore(stores.CreateTenantKeyStore(db))
+	fields := stores.CreateFieldCipher(manager, []byte("index-key"))
+	customers := stores.CreateCustomerStoreWithEncryption(db, fields)
+	store := stores.CreatePaymentMethodStoreWithEncryption(db, fields)
+
+	tenantA := "ten_a"
+	ctxA := context.WithValue(context.Background(), ctxkeys.TenantID, tenantA)
+	customer := &models.Customer{TenantID: &tenantA, ExternalID: "cus_a", Email: "a@example.com"}
+	if err := customers.Create(ctxA, customer); err != nil {
+		t.Fatalf("create customer: %v", err)
+	}
+
+	// Written by a job with no tenant and read by an operator scoped to
+	// another tenant: both use the customer's tenant key.
+	pm := &models.PaymentMethod{
+		CustomerID:              customer.ID,
+		ProviderName:            "stripe",
+		ProviderPaymentMethodID: "pm_1",
+		Type:                    models.PMTypeCard,
+		Last4:                   "4242",
+		Brand:                   "visa",
+	}
+	if err := store.Create(context.Background(), pm); err != nil {
+		t.Fatalf("create payment method: %v", err)
+	}
+
+	keyA, _ := manager.KeyIDContext(ctxA)
+	var raw models.PaymentMethod
+	if err := db.First(&raw, "id = ?", pm.ID).Error; err != nil {
+		t.Fatalf("read raw: %v", err)
+	}
+	if !strings.HasPrefix(raw.Last4, "enc:"+keyA+":") {
+		t.Fatalf("expected card details under tenant a's key %s, got %q", keyA, raw.Last4)
+	}
+
+	ctxB := context.WithValue(context.Background(), ctxkeys.TenantID, "ten_b")
+	got, err := store.GetByID(ctxB, pm.ID)
+	if err != nil || got.Last4 != "4242" {
+		t.Fatalf("expected the payment method read with its customer's tenant key, got %+v, %v", got, err)
+	}
+	listed, err := store.ListByCustomer(ctxB, customer.ID)
+	if err != nil || len(listed) != 1 || listed[0].Brand != "visa" {
+		t.Fatalf("expected the listed payment method decrypted, got %+v, %v", listed, err)
+	}
+}
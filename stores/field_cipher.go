@@ -0,0 +1,135 @@
+package stores
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/malwarebo/conductor/internal/ctxkeys"
+	"github.com/malwarebo/conductor/security"
+)
+
+const (
+	encryptedFieldPrefix = "enc:"
+	// legacyFieldVersion marks values written before ciphertext carried its
+	// key ID. They are still read, by trying each known key, and ReEncrypt
+	// moves them to the key ID format.
+	legacyFieldVersion = "v1:"
+)
+
+// FieldCipher encrypts individual columns before they are written. Values are
+// stored as "enc:<key id>:<ciphertext>" so rows written with a retired key
+// can still be read and later re-encrypted with the current one. With tenant
+// keys enabled on the manager, the key is the data key of the tenant in the
+// context.
+type FieldCipher struct {
+	manager  *security.EncryptionManager
+	indexKey []byte
+}
+
+func CreateFieldCipher(manager *security.EncryptionManager, indexKey []byte) *FieldCipher {
+	return &FieldCipher{
+		manager:  manager,
+		indexKey: indexKey,
+	}
+}
+
+func (c *FieldCipher) Encrypt(ctx context.Context, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	ciphertext, err := c.manager.EncryptContext(ctx, value)
+	if err != nil {
+		return "", err
+	}
+	return encryptedFieldPrefix + ciphertext, nil
+}
+
+// Decrypt returns values without the encrypted prefix unchanged so rows
+// written before encryption was enabled stay readable.
+func (c *FieldCipher) Decrypt(ctx context.Context, value string) (string, error) {
+	ciphertext, encrypted := strings.CutPrefix(value, encryptedFieldPrefix)
+	if !encrypted {
+		return value, nil
+	}
+	if legacy, ok := strings.CutPrefix(ciphertext, legacyFieldVersion); ok {
+		return c.manager.DecryptContext(ctx, legacy)
+	}
+	return c.manager.DecryptContext(ctx, ciphertext)
+}
+
+// NeedsReEncrypt reports whether value is plaintext or was encrypted with a
+// key other than the one Encrypt would use for ctx.
+func (c *FieldCipher) NeedsReEncrypt(ctx context.Context, value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	ciphertext, encrypted := strings.CutPrefix(value, encryptedFieldPrefix)
+	if !encrypted {
+		return true, nil
+	}
+	keyID, err := c.manager.KeyIDContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	return security.CiphertextKeyID(ciphertext) != keyID, nil
+}
+
+// BlindIndex returns a deterministic keyed hash used to look up encrypted
+// values by equality.
+func (c *FieldCipher) BlindIndex(value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *FieldCipher) encryptFields(ctx context.Context, fields ...*string) error {
+	for _, field := range fields {
+		encrypted, err := c.Encrypt(ctx, *field)
+		if err != nil {
+			return err
+		}
+		*field = encrypted
+	}
+	return nil
+}
+
+func (c *FieldCipher) decryptFields(ctx context.Context, fields ...*string) error {
+	for _, field := range fields {
+		decrypted, err := c.Decrypt(ctx, *field)
+		if err != nil {
+			return err
+		}
+		*field = decrypted
+	}
+	return nil
+}
+
+func (c *FieldCipher) needsReEncrypt(ctx context.Context, fields ...string) (bool, error) {
+	for _, field := range fields {
+		stale, err := c.NeedsReEncrypt(ctx, field)
+		if err != nil || stale {
+			return stale, err
+		}
+	}
+	return false, nil
+}
+
+// withRowTenant scopes ctx to the tenant that owns a row so the row is
+// encrypted and decrypted with that tenant's key, whoever is asking: an
+// operator request or a background job may carry another tenant or none.
+// The context's tenant is only used for rows without one.
+func withRowTenant(ctx context.Context, tenantID *string) context.Context {
+	if tenantID == nil || *tenantID == "" {
+		return ctx
+	}
+	if current, ok := ctx.Value(ctxkeys.TenantID).(string); ok && current == *tenantID {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxkeys.TenantID, *tenantID)
+}
@@ -0,0 +1,134 @@
+package stores
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/malwarebo/conductor/security"
+)
+
+func testEncryptionKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := security.CreateGenerateEncryptionKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func testEncryptionManager(t *testing.T, key []byte) *security.EncryptionManager {
+	t.Helper()
+	manager, err := security.CreateEncryptionManager(key)
+	if err != nil {
+		t.Fatalf("create encryption manager: %v", err)
+	}
+	return manager
+}
+
+func needsReEncrypt(t *testing.T, cipher *FieldCipher, value string) bool {
+	t.Helper()
+	stale, err := cipher.NeedsReEncrypt(context.Background(), value)
+	if err != nil {
+		t.Fatalf("check re-encryption: %v", err)
+	}
+	return stale
+}
+
+func TestFieldCipherRoundTripWithKeyIDPrefix(t *testing.T) {
+	manager := testEncryptionManager(t, testEncryptionKey(t))
+	cipher := CreateFieldCipher(manager, []byte("index-key"))
+
+	encrypted, err := cipher.Encrypt(context.Background(), "jane@example.com")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !strings.HasPrefix(encrypted, "enc:"+manager.KeyID()+":") || strings.Contains(encrypted, "jane@example.com") {
+		t.Fatalf("expected key-versioned ciphertext, got %q", encrypted)
+	}
+
+	decrypted, err := cipher.Decrypt(context.Background(), encrypted)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if decrypted != "jane@example.com" {
+		t.Fatalf("expected original plaintext, got %q", decrypted)
+	}
+	if needsReEncrypt(t, cipher, encrypted) {
+		t.Fatal("expected value written with the primary key not to need re-encryption")
+	}
+}
+
+func TestFieldCipherDecryptsWithRetiredKey(t *testing.T) {
+	manager := testEncryptionManager(t, testEncryptionKey(t))
+	cipher := CreateFieldCipher(manager, nil)
+
+	encrypted, err := cipher.Encrypt(context.Background(), "4242")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	if err := manager.RotateKey(testEncryptionKey(t)); err != nil {
+		t.Fatalf("rotate key: %v", err)
+	}
+
+	decrypted, err := cipher.Decrypt(context.Background(), encrypted)
+	if err != nil {
+		t.Fatalf("decrypt with retired key: %v", err)
+	}
+	if decrypted != "4242" {
+		t.Fatalf("expected 4242, got %q", decrypted)
+	}
+	if !needsReEncrypt(t, cipher, encrypted) {
+		t.Fatal("expected value written with a retired key to need re-encryption")
+	}
+}
+
+func TestFieldCipherPassesThroughLegacyPlaintext(t *testing.T) {
+	cipher := CreateFieldCipher(testEncryptionManager(t, testEncryptionKey(t)), nil)
+
+	decrypted, err := cipher.Decrypt(context.Background(), "visa")
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if decrypted != "visa" {
+		t.Fatalf("expected plaintext passthrough, got %q", decrypted)
+	}
+	if !needsReEncrypt(t, cipher, "visa") || needsReEncrypt(t, cipher, "") {
+		t.Fatal("expected only non-empty plaintext to need re-encryption")
+	}
+}
+
+func TestFieldCipherBlindIndexIsStable(t *testing.T) {
+	cipher := CreateFieldCipher(testEncryptionManager(t, testEncryptionKey(t)), []byte("index-key"))
+
+	if cipher.BlindIndex("Jane@Example.com ") != cipher.BlindIndex("jane@example.com") {
+		t.Fatal("expected blind index to normalise case and whitespace")
+	}
+	if cipher.BlindIndex("jane@example.com") == cipher.BlindIndex("john@example.com") {
+		t.Fatal("expected distinct values to have distinct blind indexes")
+	}
+}
+
+func TestFieldCipherReadsLegacyVersionedValues(t *testing.T) {
+	manager := testEncryptionManager(t, testEncryptionKey(t))
+	cipher := CreateFieldCipher(manager, nil)
+
+	ciphertext, err := manager.Encrypt("4242")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	_, body, _ := strings.Cut(ciphertext, ":")
+	legacy := "enc:v1:" + body
+
+	decrypted, err := cipher.Decrypt(context.Background(), legacy)
+	if err != nil {
+		t.Fatalf("decrypt legacy value: %v", err)
+	}
+	if decrypted != "4242" {
+		t.Fatalf("expected original plaintext, got %q", decrypted)
+	}
+	if !needsReEncrypt(t, cipher, legacy) {
+		t.Fatal("expected legacy value to need re-encryption")
+	}
+}
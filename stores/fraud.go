@@ -31,6 +31,7 @@ func (r *fraudRepository) GetStatsByDateRange(startDate, endDate time.Time) (*mo
 		TotalTransactions           int64
 		TotalFraudulentTransactions int64
 		AverageFraudScore           float64
+		TotalBlockedTransactions    int64
 	}
 
 	err := r.db.Model(&models.FraudAnalysisResult{}).
@@ -55,6 +56,31 @@ func (r *fraudRepository) GetStatsByDateRange(startDate, endDate time.Time) (*mo
 		return nil, err
 	}
 
+	err = r.db.Model(&models.FraudAnalysisResult{}).
+		Where("created_at >= ? AND created_at <= ? AND allow = ?", startDate, endDate, false).
+		Count(&stats.TotalBlockedTransactions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceCounts []struct {
+		DecisionSource models.FraudDecisionSource
+		Count          int
+	}
+	err = r.db.Model(&models.FraudAnalysisResult{}).
+		Where("created_at >= ? AND created_at <= ?", startDate, endDate).
+		Select("decision_source, COUNT(*) AS count").
+		Group("decision_source").
+		Scan(&sourceCounts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	decisionSources := make(map[models.FraudDecisionSource]int, len(sourceCounts))
+	for _, sc := range sourceCounts {
+		decisionSources[sc.DecisionSource] = sc.Count
+	}
+
 	var fraudulentPercentage float64
 	if stats.TotalTransactions > 0 {
 		fraudulentPercentage = (float64(stats.TotalFraudulentTransactions) / float64(stats.TotalTransactions)) * 100
@@ -65,5 +91,7 @@ func (r *fraudRepository) GetStatsByDateRange(startDate, endDate time.Time) (*mo
 		TotalFraudulentTransactions:     int(stats.TotalFraudulentTransactions),
 		AverageFraudScore:               stats.AverageFraudScore,
 		FraudulentTransactionPercentage: fraudulentPercentage,
+		TotalBlockedTransactions:        int(stats.TotalBlockedTransactions),
+		DecisionSources:                 decisionSources,
 	}, nil
 }
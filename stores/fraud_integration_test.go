@@ -0,0 +1,67 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/stores"
+)
+
+func TestFraudStatsAggregateStoredResults(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.FraudAnalysisResult{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := stores.CreateFraudRepository(db)
+
+	seed := []models.FraudAnalysisResult{
+		{TransactionID: "txn_1", FraudScore: 10, Allow: true, DecisionSource: models.FraudDecisionSourceAI},
+		{TransactionID: "txn_2", FraudScore: 90, IsFraudulent: true, DecisionSource: models.FraudDecisionSourceAI},
+		{TransactionID: "txn_3", FraudScore: 20, RuleTriggered: "velocity_limit", DecisionSource: models.FraudDecisionSourceRule},
+		{TransactionID: "txn_4", FraudScore: 40, Allow: true, DecisionSource: models.FraudDecisionSourceFallback},
+	}
+	for i := range seed {
+		result := seed[i]
+		result.UserID = "user_1"
+		result.BillingCountry = "US"
+		result.ShippingCountry = "US"
+		result.IPRisk = "normal"
+		if err := repo.SaveAnalysisResult(&result); err != nil {
+			t.Fatalf("save %s: %v", result.TransactionID, err)
+		}
+	}
+
+	now := time.Now()
+	stats, err := repo.GetStatsByDateRange(now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+
+	if stats.TotalTransactions != 4 || stats.TotalFraudulentTransactions != 1 || stats.TotalBlockedTransactions != 2 {
+		t.Fatalf("unexpected totals: %+v", stats)
+	}
+	if stats.AverageFraudScore != 40 {
+		t.Fatalf("expected average score 40, got %v", stats.AverageFraudScore)
+	}
+	want := map[models.FraudDecisionSource]int{
+		models.FraudDecisionSourceAI:       2,
+		models.FraudDecisionSourceRule:     1,
+		models.FraudDecisionSourceFallback: 1,
+	}
+	for source, count := range want {
+		if stats.DecisionSources[source] != count {
+			t.Fatalf("expected %d %s decisions, got %v", count, source, stats.DecisionSources)
+		}
+	}
+
+	stats, err = repo.GetStatsByDateRange(now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("stats outside range: %v", err)
+	}
+	if stats.TotalTransactions != 0 {
+		t.Fatalf("expected no transactions outside the range, got %d", stats.TotalTransactions)
+	}
+}
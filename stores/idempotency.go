@@ -28,6 +28,10 @@ func (s *IdempotencyStore) GetOrCreate(ctx context.Context, key, tenantID, reque
 		Where("key = ? AND (tenant_id = ? OR (tenant_id IS NULL AND ? = ''))", key, tenantID, tenantID).
 		First(&existing).Error
 
+	if err == nil && !existing.ExpiresAt.After(now) {
+		return s.restart(ctx, &existing, requestPath, requestHash, now, ttl)
+	}
+
 	if err == nil {
 		if existing.RequestHash != requestHash {
 			return nil, ErrIdempotencyMismatch
@@ -111,9 +115,57 @@ func (s *IdempotencyStore) Unlock(ctx context.Context, key string) error {
 		Update("locked_at", nil).Error
 }
 
-func (s *IdempotencyStore) CleanupExpired(ctx context.Context) (int64, error) {
+// restart reuses an expired key's row for a fresh request. Once its TTL has
+// passed a key no longer replays or conflicts, whatever the old request was.
+// The row is only reset while it is still expired, so when concurrent
+// requests find the same expired key exactly one of them reclaims it and the
+// others get ErrIdempotencyInProgress.
+func (s *IdempotencyStore) restart(ctx context.Context, existing *models.IdempotencyKey, requestPath, requestHash string, now time.Time, ttl time.Duration) (*models.IdempotencyResult, error) {
+	result := s.GetDB(ctx).
+		Model(&models.IdempotencyKey{}).
+		Where("id = ? AND expires_at <= ?", existing.ID, now).
+		Updates(map[string]interface{}{
+			"request_path":  requestPath,
+			"request_hash":  requestHash,
+			"response_code": nil,
+			"response_body": nil,
+			"completed_at":  nil,
+			"locked_at":     now,
+			"expires_at":    now.Add(ttl),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrIdempotencyInProgress
+	}
+
+	existing.RequestPath = requestPath
+	existing.RequestHash = requestHash
+	existing.ResponseCode = nil
+	existing.ResponseBody = nil
+	existing.CompletedAt = nil
+	existing.LockedAt = &now
+	existing.ExpiresAt = now.Add(ttl)
+
+	return &models.IdempotencyResult{
+		IsNew: true,
+		Key:   existing,
+	}, nil
+}
+
+// DeleteExpired removes up to batchSize keys whose TTL has passed and
+// reports how many were deleted. Batching keeps each delete short so the
+// cleanup job never holds long locks on a busy table.
+func (s *IdempotencyStore) DeleteExpired(ctx context.Context, batchSize int) (int64, error) {
+	expired := s.GetDB(ctx).
+		Model(&models.IdempotencyKey{}).
+		Select("id").
+		Where("expires_at <= ?", time.Now()).
+		Limit(batchSize)
+
 	result := s.GetDB(ctx).
-		Where("expires_at < ?", time.Now()).
+		Where("id IN (?)", expired).
 		Delete(&models.IdempotencyKey{})
 	return result.RowsAffected, result.Error
 }
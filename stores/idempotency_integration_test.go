@@ -0,0 +1,162 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/stores"
+)
+
+func TestIdempotencyKeyHonorsConfiguredTTL(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.IdempotencyKey{}); err != nil {
+		t.Fatalf("migrate idempotency keys: %v", err)
+	}
+	store := stores.CreateIdempotencyStore(db)
+	ctx := context.Background()
+
+	if _, err := store.GetOrCreate(ctx, "idem_ttl", "", "/v1/charges", []byte(`{"amount":100}`), 2*time.Hour); err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+
+	var row models.IdempotencyKey
+	if err := db.Where("key = ?", "idem_ttl").First(&row).Error; err != nil {
+		t.Fatalf("expected key to be stored: %v", err)
+	}
+	if remaining := time.Until(row.ExpiresAt); remaining < 119*time.Minute || remaining > 2*time.Hour {
+		t.Fatalf("expected the key to expire in about 2h, got %s", remaining)
+	}
+}
+
+func TestExpiredIdempotencyKeyIsTreatedAsNew(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.IdempotencyKey{}); err != nil {
+		t.Fatalf("migrate idempotency keys: %v", err)
+	}
+	store := stores.CreateIdempotencyStore(db)
+	ctx := context.Background()
+
+	if _, err := store.GetOrCreate(ctx, "idem_short", "", "/v1/charges", []byte(`{"amount":100}`), time.Millisecond); err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	if err := store.Complete(ctx, "idem_short", 200, map[string]interface{}{"id": "pay_1"}); err != nil {
+		t.Fatalf("complete key: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	result, err := store.GetOrCreate(ctx, "idem_short", "", "/v1/charges", []byte(`{"amount":250}`), time.Hour)
+	if err != nil {
+		t.Fatalf("reuse expired key: %v", err)
+	}
+	if !result.IsNew || result.ResponseCode != 0 {
+		t.Fatalf("expected an expired key to start a new request, got %+v", result)
+	}
+	var row models.IdempotencyKey
+	if err := db.Where("key = ?", "idem_short").First(&row).Error; err != nil || row.CompletedAt != nil || time.Until(row.ExpiresAt) < 59*time.Minute {
+		t.Fatalf("expected the key to be reset with a fresh TTL, got %+v, %v", row, err)
+	}
+
+	if _, err := store.GetOrCreate(ctx, "idem_short", "", "/v1/charges", []byte(`{"amount":999}`), time.Hour); !errors.Is(err, stores.ErrIdempotencyMismatch) {
+		t.Fatalf("expected a live key to reject a different body, got %v", err)
+	}
+}
+
+func TestConcurrentReclaimOfExpiredKeyStartsOneRequest(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.IdempotencyKey{}); err != nil {
+		t.Fatalf("migrate idempotency keys: %v", err)
+	}
+	store := stores.CreateIdempotencyStore(db)
+	ctx := context.Background()
+
+	body := []byte(`{"amount":100}`)
+	if _, err := store.GetOrCreate(ctx, "idem_race", "", "/v1/charges", body, time.Millisecond); err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	if err := store.Complete(ctx, "idem_race", 200, map[string]interface{}{"id": "pay_1"}); err != nil {
+		t.Fatalf("complete key: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	const callers = 8
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		mu    sync.Mutex
+		fresh int
+	)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			result, err := store.GetOrCreate(ctx, "idem_race", "", "/v1/charges", body, time.Hour)
+			if err != nil {
+				if !errors.Is(err, stores.ErrIdempotencyInProgress) {
+					t.Errorf("expected a losing reclaim to be in progress, got %v", err)
+				}
+				return
+			}
+			if result.IsNew {
+				mu.Lock()
+				fresh++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if fresh != 1 {
+		t.Fatalf("expected exactly one caller to reclaim the expired key, got %d", fresh)
+	}
+}
+
+func TestDeleteExpiredRemovesOnlyExpiredKeys(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.IdempotencyKey{}); err != nil {
+		t.Fatalf("migrate idempotency keys: %v", err)
+	}
+	store := stores.CreateIdempotencyStore(db)
+	ctx := context.Background()
+
+	for _, key := range []string{"idem_old_1", "idem_old_2", "idem_old_3"} {
+		if _, err := store.GetOrCreate(ctx, key, "", "/v1/charges", []byte(key), time.Millisecond); err != nil {
+			t.Fatalf("create %s: %v", key, err)
+		}
+	}
+	if _, err := store.GetOrCreate(ctx, "idem_live", "", "/v1/charges", []byte("live"), time.Hour); err != nil {
+		t.Fatalf("create live key: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	deleted, err := store.DeleteExpired(ctx, 2)
+	if err != nil {
+		t.Fatalf("first batch: %v", err)
+	}
+	if deleted != 2 {
+		t.Fatalf("expected the batch size to cap deletes at 2, got %d", deleted)
+	}
+
+	deleted, err = store.DeleteExpired(ctx, 2)
+	if err != nil {
+		t.Fatalf("second batch: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected the last expired key to be deleted, got %d", deleted)
+	}
+
+	var remaining []string
+	if err := db.Model(&models.IdempotencyKey{}).Pluck("key", &remaining).Error; err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	if len(remaining) != 1 || remaining[0] != "idem_live" {
+		t.Fatalf("expected only the unexpired key to survive cleanup, got %v", remaining)
+	}
+}
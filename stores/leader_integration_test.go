@@ -0,0 +1,58 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/worker"
+)
+
+func TestPostgresLockerElectsSingleLeader(t *testing.T) {
+	db := newTestDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+
+	var runsA, runsB atomic.Int32
+	a := worker.NewScheduler(worker.NewPostgresLocker(sqlDB))
+	a.Register("webhook-retries", 10*time.Millisecond, func(context.Context) error {
+		runsA.Add(1)
+		return nil
+	})
+	b := worker.NewScheduler(worker.NewPostgresLocker(sqlDB))
+	b.Register("webhook-retries", 10*time.Millisecond, func(context.Context) error {
+		runsB.Add(1)
+		return nil
+	})
+
+	a.Start(context.Background())
+	b.Start(context.Background())
+
+	deadline := time.After(10 * time.Second)
+	for runsA.Load()+runsB.Load() < 10 {
+		select {
+		case <-deadline:
+			a.Stop()
+			b.Stop()
+			t.Fatalf("timed out: a=%d b=%d", runsA.Load(), runsB.Load())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	a.Stop()
+	b.Stop()
+
+	if runsA.Load() > 0 && runsB.Load() > 0 {
+		t.Fatalf("expected a single leader, but both ran: a=%d b=%d", runsA.Load(), runsB.Load())
+	}
+
+	lease, ok, err := worker.NewPostgresLocker(sqlDB).TryLock(context.Background(), "webhook-retries")
+	if err != nil || !ok {
+		t.Fatalf("expected lock to be released on stop: ok=%v err=%v", ok, err)
+	}
+	_ = lease.Release(context.Background())
+}
@@ -2,11 +2,20 @@ package stores
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"time"
 
+	"github.com/malwarebo/conductor/internal/pagination"
 	"github.com/malwarebo/conductor/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrConcurrentModification is returned by Update when the payment changed
+// since it was read, so the caller's state transition lost the race.
+var ErrConcurrentModification = errors.New("payment was modified concurrently")
+
 type PaymentRepository struct {
 	BaseStore
 }
@@ -19,8 +28,34 @@ func (r *PaymentRepository) Create(ctx context.Context, payment *models.Payment)
 	return r.GetDB(ctx).Create(payment).Error
 }
 
+// Update writes the payment only if its version still matches the stored row,
+// bumping the version on success. A stale payment leaves the row untouched
+// and returns ErrConcurrentModification.
 func (r *PaymentRepository) Update(ctx context.Context, payment *models.Payment) error {
-	return r.GetDB(ctx).Save(payment).Error
+	version := payment.Version
+	payment.Version++
+	result := r.GetDB(ctx).Model(payment).Where("version = ?", version).Select("*").Updates(payment)
+	if result.Error != nil {
+		payment.Version = version
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		payment.Version = version
+		return ErrConcurrentModification
+	}
+	return nil
+}
+
+// CompleteIntent replaces the pending row written before a provider call with
+// the payment the provider returned. Both happen in one transaction so the
+// payment takes the provider's ID and exactly one row remains.
+func (r *PaymentRepository) CompleteIntent(ctx context.Context, intentID string, payment *models.Payment) error {
+	return r.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Payment{}, "id = ?", intentID).Error; err != nil {
+			return err
+		}
+		return tx.Create(payment).Error
+	})
 }
 
 func (r *PaymentRepository) GetByID(ctx context.Context, id string) (*models.Payment, error) {
@@ -75,19 +110,36 @@ func (r *PaymentRepository) GetByIdempotencyKey(ctx context.Context, idempotency
 	return &payment, nil
 }
 
-func (r *PaymentRepository) ListByTenant(ctx context.Context, tenantID string, limit, offset int) ([]*models.Payment, error) {
-	var payments []*models.Payment
-	query := r.GetDB(ctx).Where("tenant_id = ?", tenantID).Order("created_at DESC")
-	if limit > 0 {
-		query = query.Limit(limit)
+func (r *PaymentRepository) ListByTenant(ctx context.Context, filter models.PaymentFilter) (pagination.Page[*models.Payment], error) {
+	query := r.GetDB(ctx).Where("tenant_id = ?", filter.TenantID)
+	return listPaymentPage(query, filter)
+}
+
+// ListByMetadata finds a tenant's payments whose metadata contains key=value,
+// using jsonb containment so the GIN index on metadata applies.
+func (r *PaymentRepository) ListByMetadata(ctx context.Context, key, value string, filter models.PaymentFilter) (pagination.Page[*models.Payment], error) {
+	containment, err := json.Marshal(map[string]string{key: value})
+	if err != nil {
+		return pagination.Page[*models.Payment]{}, err
 	}
-	if offset > 0 {
-		query = query.Offset(offset)
+
+	query := r.GetDB(ctx).
+		Where("tenant_id = ?", filter.TenantID).
+		Where("metadata @> CAST(? AS jsonb)", string(containment))
+	return listPaymentPage(query, filter)
+}
+
+func listPaymentPage(query *gorm.DB, filter models.PaymentFilter) (pagination.Page[*models.Payment], error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
 	}
-	if err := query.Find(&payments).Error; err != nil {
-		return nil, err
+
+	var payments []*models.Payment
+	if err := pagination.Keyset(query, filter.Cursor, limit).Find(&payments).Error; err != nil {
+		return pagination.Page[*models.Payment]{}, err
 	}
-	return payments, nil
+	return pagination.NewPage(payments, limit, models.PaymentKey), nil
 }
 
 func (r *PaymentRepository) UpdateStatus(ctx context.Context, id string, status models.PaymentStatus) error {
@@ -100,3 +152,93 @@ func (r *PaymentRepository) UpdateCapture(ctx context.Context, id string, captur
 		"status":          status,
 	}).Error
 }
+
+// ClaimStalePending locks a batch of stale payments, bumps their updated_at so
+// concurrent reconcilers skip them, and commits before returning so callers
+// can talk to providers without holding row locks. Payments without a
+// provider charge ID cannot be looked up and are not claimed.
+func (r *PaymentRepository) ClaimStalePending(ctx context.Context, statuses []models.PaymentStatus, updatedBefore time.Time, limit int) ([]*models.Payment, error) {
+	var claimed []*models.Payment
+	err := r.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		var payments []*models.Payment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND updated_at <= ?", statuses, updatedBefore).
+			Where("provider_charge_id <> ''").
+			Order("updated_at ASC").
+			Limit(limit).
+			Find(&payments).Error
+		if err != nil {
+			return err
+		}
+		if len(payments) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(payments))
+		for i, p := range payments {
+			ids[i] = p.ID
+		}
+		if err := tx.Model(&models.Payment{}).Where("id IN ?", ids).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+		claimed = payments
+		return nil
+	})
+	return claimed, err
+}
+
+// ClaimExpiredAuthorizations locks uncaptured authorizations created before
+// their provider's cutoff, falling back to defaultCutoff for providers
+// without one. updated_at is bumped so an authorization that cannot be voided
+// rotates behind the rest of the backlog.
+func (r *PaymentRepository) ClaimExpiredAuthorizations(ctx context.Context, cutoffs map[string]time.Time, defaultCutoff time.Time, limit int) ([]*models.Payment, error) {
+	var claimed []*models.Payment
+	err := r.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		names := make([]string, 0, len(cutoffs))
+		for name := range cutoffs {
+			names = append(names, name)
+		}
+
+		expired := tx.Where("created_at <= ?", defaultCutoff)
+		if len(names) > 0 {
+			expired = tx.Where("provider_name NOT IN ? AND created_at <= ?", names, defaultCutoff)
+		}
+		for name, cutoff := range cutoffs {
+			expired = expired.Or("provider_name = ? AND created_at <= ?", name, cutoff)
+		}
+
+		var payments []*models.Payment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", models.PaymentStatusRequiresCapture).
+			Where(expired).
+			Order("updated_at ASC").
+			Limit(limit).
+			Find(&payments).Error
+		if err != nil {
+			return err
+		}
+		if len(payments) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(payments))
+		for i, p := range payments {
+			ids[i] = p.ID
+		}
+		if err := tx.Model(&models.Payment{}).Where("id IN ?", ids).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+		claimed = payments
+		return nil
+	})
+	return claimed, err
+}
+
+// UpdateStatusFrom moves a payment to status only if it is still in from,
+// reporting whether the row changed.
+func (r *PaymentRepository) UpdateStatusFrom(ctx context.Context, id string, from, status models.PaymentStatus) (bool, error) {
+	result := r.GetDB(ctx).Model(&models.Payment{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", status)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,179 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/internal/pagination"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/stores"
+)
+
+func TestPaymentListByMetadataScopesToTenant(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Payment{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := stores.CreatePaymentRepository(db)
+	ctx := context.Background()
+
+	const tenantA, tenantB = "11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222"
+	seed := []struct {
+		tenant   string
+		metadata models.JSON
+	}{
+		{tenantA, models.JSON{"order_id": "123", "channel": "web"}},
+		{tenantA, models.JSON{"order_id": "123"}},
+		{tenantA, models.JSON{"order_id": "456"}},
+		{tenantA, nil},
+		{tenantB, models.JSON{"order_id": "123"}},
+	}
+	for i, s := range seed {
+		tenantID := s.tenant
+		payment := &models.Payment{
+			TenantID:      &tenantID,
+			CustomerID:    "cus_1",
+			Amount:        1000,
+			Currency:      "USD",
+			Status:        models.PaymentStatusSuccess,
+			PaymentMethod: "card",
+			ProviderName:  "stripe",
+			Metadata:      s.metadata,
+		}
+		if err := repo.Create(ctx, payment); err != nil {
+			t.Fatalf("seed payment %d: %v", i, err)
+		}
+	}
+
+	page, err := repo.ListByMetadata(ctx, "order_id", "123", models.PaymentFilter{TenantID: tenantA})
+	if err != nil {
+		t.Fatalf("list by metadata: %v", err)
+	}
+	if len(page.Data) != 2 {
+		t.Fatalf("expected 2 payments for order 123, got %d", len(page.Data))
+	}
+	for _, p := range page.Data {
+		if p.TenantID == nil || *p.TenantID != tenantA {
+			t.Fatalf("payment %s leaked from another tenant", p.ID)
+		}
+		if p.Metadata["order_id"] != "123" {
+			t.Fatalf("payment %s has unexpected metadata %v", p.ID, p.Metadata)
+		}
+	}
+
+	page, err = repo.ListByMetadata(ctx, "order_id", "999", models.PaymentFilter{TenantID: tenantA})
+	if err != nil {
+		t.Fatalf("list by missing value: %v", err)
+	}
+	if len(page.Data) != 0 {
+		t.Fatalf("expected no payments for order 999, got %d", len(page.Data))
+	}
+}
+
+func TestPaymentListByTenantPagesAcrossTiedTimestamps(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Payment{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := stores.CreatePaymentRepository(db)
+	ctx := context.Background()
+
+	const tenantID = "11111111-1111-1111-1111-111111111111"
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 11; i++ {
+		tenant := tenantID
+		payment := &models.Payment{
+			TenantID:      &tenant,
+			CustomerID:    "cus_1",
+			Amount:        1000,
+			Currency:      "USD",
+			Status:        models.PaymentStatusSuccess,
+			PaymentMethod: "card",
+			ProviderName:  "stripe",
+			// Groups of four share a timestamp so pages end inside a tie.
+			CreatedAt: base.Add(time.Duration(i/4) * time.Second),
+		}
+		if err := repo.Create(ctx, payment); err != nil {
+			t.Fatalf("seed payment %d: %v", i, err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	filter := models.PaymentFilter{TenantID: tenantID, Limit: 3}
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("pagination did not terminate")
+		}
+		page, err := repo.ListByTenant(ctx, filter)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		for _, p := range page.Data {
+			if seen[p.ID] {
+				t.Fatalf("payment %s returned on more than one page", p.ID)
+			}
+			seen[p.ID] = true
+		}
+		if !page.HasMore {
+			break
+		}
+		if filter.Cursor, err = pagination.Decode(page.NextCursor); err != nil {
+			t.Fatalf("decode cursor: %v", err)
+		}
+	}
+
+	if len(seen) != 11 {
+		t.Fatalf("expected all 11 payments across pages, got %d", len(seen))
+	}
+}
+
+func TestPaymentUpdateRejectsStaleVersion(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Payment{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := stores.CreatePaymentRepository(db)
+	ctx := context.Background()
+
+	payment := &models.Payment{
+		CustomerID:    "cus_1",
+		Amount:        1000,
+		Currency:      "USD",
+		Status:        models.PaymentStatusRequiresCapture,
+		PaymentMethod: "card",
+		ProviderName:  "stripe",
+	}
+	if err := repo.Create(ctx, payment); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	first, err := repo.GetByID(ctx, payment.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	second, err := repo.GetByID(ctx, payment.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	first.Status = models.PaymentStatusSuccess
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	second.Status = models.PaymentStatusCanceled
+	if err := repo.Update(ctx, second); !errors.Is(err, stores.ErrConcurrentModification) {
+		t.Fatalf("expected ErrConcurrentModification for a stale update, got %v", err)
+	}
+
+	stored, err := repo.GetByID(ctx, payment.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if stored.Status != models.PaymentStatusSuccess || stored.Version != 1 {
+		t.Fatalf("expected the first update to win, got status %s version %d", stored.Status, stored.Version)
+	}
+}
@@ -9,18 +9,27 @@ import (
 
 type PaymentMethodStore struct {
 	BaseStore
+	fields *FieldCipher
 }
 
 func CreatePaymentMethodStore(db *gorm.DB) *PaymentMethodStore {
 	return &PaymentMethodStore{BaseStore: BaseStore{db: db}}
 }
 
+func CreatePaymentMethodStoreWithEncryption(db *gorm.DB, fields *FieldCipher) *PaymentMethodStore {
+	return &PaymentMethodStore{BaseStore: BaseStore{db: db}, fields: fields}
+}
+
 func (s *PaymentMethodStore) Create(ctx context.Context, pm *models.PaymentMethod) error {
-	return s.GetDB(ctx).Create(pm).Error
+	return s.write(ctx, pm, func() error {
+		return s.GetDB(ctx).Create(pm).Error
+	})
 }
 
 func (s *PaymentMethodStore) Update(ctx context.Context, pm *models.PaymentMethod) error {
-	return s.GetDB(ctx).Save(pm).Error
+	return s.write(ctx, pm, func() error {
+		return s.GetDB(ctx).Save(pm).Error
+	})
 }
 
 func (s *PaymentMethodStore) GetByID(ctx context.Context, id string) (*models.PaymentMethod, error) {
@@ -28,6 +37,23 @@ func (s *PaymentMethodStore) GetByID(ctx context.Context, id string) (*models.Pa
 	if err := s.GetDB(ctx).First(&pm, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
+	if err := s.decrypt(ctx, &pm); err != nil {
+		return nil, err
+	}
+	return &pm, nil
+}
+
+func (s *PaymentMethodStore) GetByProviderID(ctx context.Context, providerName, providerPaymentMethodID string) (*models.PaymentMethod, error) {
+	var pm models.PaymentMethod
+	err := s.GetDB(ctx).
+		Where("provider_name = ? AND provider_payment_method_id = ?", providerName, providerPaymentMethodID).
+		First(&pm).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decrypt(ctx, &pm); err != nil {
+		return nil, err
+	}
 	return &pm, nil
 }
 
@@ -36,6 +62,18 @@ func (s *PaymentMethodStore) ListByCustomer(ctx context.Context, customerID stri
 	if err := s.GetDB(ctx).Where("customer_id = ?", customerID).Find(&pms).Error; err != nil {
 		return nil, err
 	}
+	if s.fields == nil {
+		return pms, nil
+	}
+	tenants, err := s.customerTenants(ctx, pms)
+	if err != nil {
+		return nil, err
+	}
+	for _, pm := range pms {
+		if err := s.fields.decryptFields(withRowTenant(ctx, tenants[pm.CustomerID]), &pm.Last4, &pm.Brand); err != nil {
+			return nil, err
+		}
+	}
 	return pms, nil
 }
 
@@ -43,6 +81,12 @@ func (s *PaymentMethodStore) Delete(ctx context.Context, id string) error {
 	return s.GetDB(ctx).Delete(&models.PaymentMethod{}, "id = ?", id).Error
 }
 
+func (s *PaymentMethodStore) UpdateStatusByProviderID(ctx context.Context, providerName, providerPaymentMethodID, status string) error {
+	return s.GetDB(ctx).Model(&models.PaymentMethod{}).
+		Where("provider_name = ? AND provider_payment_method_id = ?", providerName, providerPaymentMethodID).
+		Update("status", status).Error
+}
+
 func (s *PaymentMethodStore) SetDefault(ctx context.Context, customerID, id string) error {
 	return s.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Model(&models.PaymentMethod{}).
@@ -61,5 +105,137 @@ func (s *PaymentMethodStore) GetDefault(ctx context.Context, customerID string)
 	if err := s.GetDB(ctx).Where("customer_id = ? AND is_default = ?", customerID, true).First(&pm).Error; err != nil {
 		return nil, err
 	}
+	if err := s.decrypt(ctx, &pm); err != nil {
+		return nil, err
+	}
 	return &pm, nil
 }
+
+// CountByFingerprint returns how many distinct customers hold a payment method
+// with the given provider fingerprint.
+func (s *PaymentMethodStore) CountByFingerprint(ctx context.Context, fingerprint string) (int64, error) {
+	if fingerprint == "" {
+		return 0, nil
+	}
+	var count int64
+	err := s.GetDB(ctx).Model(&models.PaymentMethod{}).
+		Where("fingerprint = ?", fingerprint).
+		Distinct("customer_id").
+		Count(&count).Error
+	return count, err
+}
+
+// ReEncrypt rewrites payment methods whose card details are still plaintext
+// or were encrypted with a retired key, returning the number of rows updated.
+func (s *PaymentMethodStore) ReEncrypt(ctx context.Context, batchSize int) (int, error) {
+	if s.fields == nil {
+		return 0, nil
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	updated := 0
+	lastID := ""
+	for {
+		var pms []*models.PaymentMethod
+		if err := s.GetDB(ctx).Where("id > ?", lastID).Order("id").Limit(batchSize).Find(&pms).Error; err != nil {
+			return updated, err
+		}
+		if len(pms) == 0 {
+			return updated, nil
+		}
+		tenants, err := s.customerTenants(ctx, pms)
+		if err != nil {
+			return updated, err
+		}
+
+		for _, pm := range pms {
+			lastID = pm.ID
+			rowCtx := withRowTenant(ctx, tenants[pm.CustomerID])
+			stale, err := s.fields.needsReEncrypt(rowCtx, pm.Last4, pm.Brand)
+			if err != nil {
+				return updated, err
+			}
+			if !stale {
+				continue
+			}
+			if err := s.fields.decryptFields(rowCtx, &pm.Last4, &pm.Brand); err != nil {
+				return updated, err
+			}
+			if err := s.fields.encryptFields(rowCtx, &pm.Last4, &pm.Brand); err != nil {
+				return updated, err
+			}
+			if err := s.GetDB(ctx).Model(&models.PaymentMethod{}).Where("id = ?", pm.ID).UpdateColumns(map[string]interface{}{
+				"last4": pm.Last4,
+				"brand": pm.Brand,
+			}).Error; err != nil {
+				return updated, err
+			}
+			updated++
+		}
+	}
+}
+
+// customerTenants maps the customers owning pms to their tenants. Payment
+// methods carry no tenant of their own, and their card details are encrypted
+// under the key of their customer's tenant.
+func (s *PaymentMethodStore) customerTenants(ctx context.Context, pms []*models.PaymentMethod) (map[string]*string, error) {
+	if !s.fields.manager.TenantKeysEnabled() {
+		return nil, nil
+	}
+	customerIDs := make([]string, 0, len(pms))
+	for _, pm := range pms {
+		customerIDs = append(customerIDs, pm.CustomerID)
+	}
+
+	var customers []*models.Customer
+	if err := s.GetDB(ctx).Select("id", "tenant_id").Where("id IN ?", customerIDs).Find(&customers).Error; err != nil {
+		return nil, err
+	}
+	tenants := make(map[string]*string, len(customers))
+	for _, customer := range customers {
+		tenants[customer.ID] = customer.TenantID
+	}
+	return tenants, nil
+}
+
+func (s *PaymentMethodStore) write(ctx context.Context, pm *models.PaymentMethod, fn func() error) error {
+	if s.fields == nil {
+		return fn()
+	}
+
+	last4, brand := pm.Last4, pm.Brand
+	defer func() {
+		pm.Last4, pm.Brand = last4, brand
+	}()
+
+	rowCtx, err := s.rowContext(ctx, pm)
+	if err != nil {
+		return err
+	}
+	if err := s.fields.encryptFields(rowCtx, &pm.Last4, &pm.Brand); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func (s *PaymentMethodStore) decrypt(ctx context.Context, pm *models.PaymentMethod) error {
+	if s.fields == nil {
+		return nil
+	}
+	rowCtx, err := s.rowContext(ctx, pm)
+	if err != nil {
+		return err
+	}
+	return s.fields.decryptFields(rowCtx, &pm.Last4, &pm.Brand)
+}
+
+// rowContext scopes ctx to the tenant of the customer owning pm.
+func (s *PaymentMethodStore) rowContext(ctx context.Context, pm *models.PaymentMethod) (context.Context, error) {
+	tenants, err := s.customerTenants(ctx, []*models.PaymentMethod{pm})
+	if err != nil {
+		return nil, err
+	}
+	return withRowTenant(ctx, tenants[pm.CustomerID]), nil
+}
@@ -0,0 +1,59 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/stores"
+)
+
+func TestPaymentMethodCountByFingerprintAcrossCustomers(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.PaymentMethod{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	store := stores.CreatePaymentMethodStore(db)
+	ctx := context.Background()
+
+	seed := func(customerID, providerID, fingerprint string) *models.PaymentMethod {
+		t.Helper()
+		pm := &models.PaymentMethod{
+			CustomerID:              customerID,
+			ProviderName:            "stripe",
+			ProviderPaymentMethodID: providerID,
+			Type:                    models.PMTypeCard,
+			Fingerprint:             fingerprint,
+		}
+		if err := store.Create(ctx, pm); err != nil {
+			t.Fatalf("seed payment method: %v", err)
+		}
+		return pm
+	}
+	first := seed("cus_1", "pm_1", "fp_shared")
+	seed("cus_1", "pm_2", "fp_shared")
+	seed("cus_2", "pm_3", "fp_shared")
+	seed("cus_3", "pm_4", "fp_other")
+
+	stored, err := store.GetByID(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if stored.Fingerprint != "fp_shared" {
+		t.Fatalf("expected the fingerprint to be stored, got %q", stored.Fingerprint)
+	}
+
+	count, err := store.CountByFingerprint(ctx, "fp_shared")
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected the shared card to be counted once per customer, got %d", count)
+	}
+
+	if count, err := store.CountByFingerprint(ctx, "fp_unknown"); err != nil || count != 0 {
+		t.Fatalf("expected no customers for an unknown fingerprint, got %d: %v", count, err)
+	}
+}
@@ -0,0 +1,124 @@
+package stores
+
+import (
+	"context"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PayoutScheduleStore struct {
+	BaseStore
+}
+
+func CreatePayoutScheduleStore(db *gorm.DB) *PayoutScheduleStore {
+	return &PayoutScheduleStore{BaseStore: BaseStore{db: db}}
+}
+
+func (s *PayoutScheduleStore) Create(ctx context.Context, schedule *models.PayoutSchedule) error {
+	return s.GetDB(ctx).Create(schedule).Error
+}
+
+func (s *PayoutScheduleStore) GetByID(ctx context.Context, id string) (*models.PayoutSchedule, error) {
+	var schedule models.PayoutSchedule
+	if err := s.GetDB(ctx).First(&schedule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (s *PayoutScheduleStore) ListByTenant(ctx context.Context, tenantID string) ([]*models.PayoutSchedule, error) {
+	var schedules []*models.PayoutSchedule
+	if err := s.GetDB(ctx).Where("tenant_id = ?", tenantID).Order("created_at ASC").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (s *PayoutScheduleStore) Update(ctx context.Context, schedule *models.PayoutSchedule) error {
+	return s.GetDB(ctx).Save(schedule).Error
+}
+
+func (s *PayoutScheduleStore) Delete(ctx context.Context, id string) error {
+	return s.GetDB(ctx).Delete(&models.PayoutSchedule{}, "id = ?", id).Error
+}
+
+// ClaimDue locks active schedules whose next run has passed and moves each
+// one's next_run_at to the first run after now before committing, so a run
+// is claimed by one worker and a schedule that fell behind runs once rather
+// than once per missed period.
+func (s *PayoutScheduleStore) ClaimDue(ctx context.Context, now time.Time, limit int) ([]*models.PayoutSchedule, error) {
+	var claimed []*models.PayoutSchedule
+	err := s.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		var schedules []*models.PayoutSchedule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("active AND next_run_at <= ?", now).
+			Order("next_run_at ASC").
+			Limit(limit).
+			Find(&schedules).Error
+		if err != nil {
+			return err
+		}
+
+		for _, schedule := range schedules {
+			next := schedule.NextRunAt
+			for !next.After(now) {
+				next = schedule.Frequency.Next(next)
+			}
+			if err := tx.Model(&models.PayoutSchedule{}).Where("id = ?", schedule.ID).Updates(map[string]interface{}{
+				"next_run_at": next,
+				"last_run_at": now,
+				"updated_at":  time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+			schedule.NextRunAt = next
+			schedule.LastRunAt = &now
+		}
+		claimed = schedules
+		return nil
+	})
+	return claimed, err
+}
+
+func (s *PayoutScheduleStore) CreateRun(ctx context.Context, run *models.PayoutScheduleRun) error {
+	return s.GetDB(ctx).Create(run).Error
+}
+
+// ListRuns returns a schedule's runs, most recent first.
+func (s *PayoutScheduleStore) ListRuns(ctx context.Context, scheduleID string, limit int) ([]*models.PayoutScheduleRun, error) {
+	var runs []*models.PayoutScheduleRun
+	if err := s.GetDB(ctx).Where("schedule_id = ?", scheduleID).Order("ran_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// SettledBalance returns what a tenant has settled in currency and not yet
+// been paid: the captured amount of its payments less their refunds and the
+// amounts its schedules have paid out.
+func (s *PayoutScheduleStore) SettledBalance(ctx context.Context, tenantID, currency string) (int64, error) {
+	var balance int64
+	err := s.GetDB(ctx).Raw(`
+		SELECT
+			(SELECT COALESCE(SUM(captured_amount), 0) FROM payments
+				WHERE tenant_id = @tenant AND currency = @currency AND status IN @settled)
+			- (SELECT COALESCE(SUM(r.amount), 0) FROM refunds r JOIN payments p ON p.id = r.payment_id
+				WHERE p.tenant_id = @tenant AND p.currency = @currency AND r.status NOT IN ('failed', 'canceled'))
+			- (SELECT COALESCE(SUM(amount), 0) FROM payout_schedule_runs
+				WHERE tenant_id = @tenant AND currency = @currency AND status = @paidOut)`,
+		map[string]interface{}{
+			"tenant":   tenantID,
+			"currency": currency,
+			"settled": []models.PaymentStatus{
+				models.PaymentStatusSuccess,
+				models.PaymentStatusPartiallyRefunded,
+				models.PaymentStatusRefunded,
+				models.PaymentStatusDisputed,
+			},
+			"paidOut": models.PayoutScheduleRunPaidOut,
+		}).Scan(&balance).Error
+	return balance, err
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/malwarebo/conductor/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProviderMappingStore struct {
@@ -19,6 +20,18 @@ func (s *ProviderMappingStore) Create(ctx context.Context, mapping *models.Provi
 	return s.GetDB(ctx).Create(mapping).Error
 }
 
+// CreateIfNotExists inserts the mapping unless one already exists for the
+// entity, reporting whether a row was written.
+func (s *ProviderMappingStore) CreateIfNotExists(ctx context.Context, mapping *models.ProviderMapping) (bool, error) {
+	result := s.GetDB(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "entity_id"}, {Name: "entity_type"}},
+			DoNothing: true,
+		}).
+		Create(mapping)
+	return result.RowsAffected > 0, result.Error
+}
+
 func (s *ProviderMappingStore) Update(ctx context.Context, mapping *models.ProviderMapping) error {
 	return s.GetDB(ctx).Save(mapping).Error
 }
@@ -31,6 +44,18 @@ func (s *ProviderMappingStore) GetByEntity(ctx context.Context, entityID, entity
 	return &mapping, nil
 }
 
+// GetByProviderEntity resolves a mapping from the provider's own ID, which is
+// all an inbound webhook carries.
+func (s *ProviderMappingStore) GetByProviderEntity(ctx context.Context, providerName, providerEntityID, entityType string) (*models.ProviderMapping, error) {
+	var mapping models.ProviderMapping
+	if err := s.GetDB(ctx).
+		Where("provider_name = ? AND provider_entity_id = ? AND entity_type = ?", providerName, providerEntityID, entityType).
+		First(&mapping).Error; err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
 func (s *ProviderMappingStore) Delete(ctx context.Context, entityID, entityType string) error {
 	return s.GetDB(ctx).Where("entity_id = ? AND entity_type = ?", entityID, entityType).Delete(&models.ProviderMapping{}).Error
 }
@@ -0,0 +1,76 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/stores"
+)
+
+func TestProviderMappingCreateIfNotExistsIsIdempotent(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.ProviderMapping{}); err != nil {
+		t.Fatalf("migrate provider mappings: %v", err)
+	}
+	store := stores.CreateProviderMappingStore(db)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		created, err := store.CreateIfNotExists(ctx, &models.ProviderMapping{
+			EntityID:         "pay_123",
+			EntityType:       "payment",
+			ProviderName:     "stripe",
+			ProviderEntityID: "pi_123",
+		})
+		if err != nil {
+			t.Fatalf("save %d: %v", i+1, err)
+		}
+		if created != (i == 0) {
+			t.Fatalf("save %d: expected created=%v, got %v", i+1, i == 0, created)
+		}
+	}
+
+	var count int64
+	if err := db.Model(&models.ProviderMapping{}).
+		Where("entity_id = ? AND entity_type = ?", "pay_123", "payment").
+		Count(&count).Error; err != nil {
+		t.Fatalf("count mappings: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected exactly one mapping, got %d", count)
+	}
+}
+
+func TestProviderMappingGetByProviderEntityResolvesSubscription(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.ProviderMapping{}); err != nil {
+		t.Fatalf("migrate provider mappings: %v", err)
+	}
+	store := stores.CreateProviderMappingStore(db)
+	ctx := context.Background()
+
+	for _, mapping := range []*models.ProviderMapping{
+		{EntityID: "sub_local", EntityType: "subscription", ProviderName: "stripe", ProviderEntityID: "sub_123"},
+		{EntityID: "cus_local", EntityType: "customer", ProviderName: "stripe", ProviderEntityID: "sub_123"},
+		{EntityID: "sub_other", EntityType: "subscription", ProviderName: "razorpay", ProviderEntityID: "sub_123"},
+	} {
+		if err := store.Create(ctx, mapping); err != nil {
+			t.Fatalf("create mapping %s: %v", mapping.EntityID, err)
+		}
+	}
+
+	mapping, err := store.GetByProviderEntity(ctx, "stripe", "sub_123", "subscription")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if mapping.EntityID != "sub_local" {
+		t.Fatalf("expected sub_local, got %s", mapping.EntityID)
+	}
+
+	if _, err := store.GetByProviderEntity(ctx, "stripe", "sub_missing", "subscription"); err == nil {
+		t.Fatal("expected error for unknown provider entity")
+	}
+}
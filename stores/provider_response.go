@@ -0,0 +1,31 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type ProviderResponseStore struct {
+	BaseStore
+}
+
+func CreateProviderResponseStore(db *gorm.DB) *ProviderResponseStore {
+	return &ProviderResponseStore{BaseStore: BaseStore{db: db}}
+}
+
+func (s *ProviderResponseStore) Create(ctx context.Context, response *models.ProviderResponse) error {
+	return s.GetDB(ctx).Create(response).Error
+}
+
+func (s *ProviderResponseStore) ListByPayment(ctx context.Context, paymentID string) ([]*models.ProviderResponse, error) {
+	var responses []*models.ProviderResponse
+	if err := s.GetDB(ctx).
+		Where("payment_id = ?", paymentID).
+		Order("received_at ASC").
+		Find(&responses).Error; err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
@@ -0,0 +1,98 @@
+package stores
+
+import (
+	"context"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ScheduledPaymentStore struct {
+	BaseStore
+}
+
+func CreateScheduledPaymentStore(db *gorm.DB) *ScheduledPaymentStore {
+	return &ScheduledPaymentStore{BaseStore: BaseStore{db: db}}
+}
+
+func (s *ScheduledPaymentStore) Create(ctx context.Context, scheduled *models.ScheduledPayment) error {
+	return s.GetDB(ctx).Create(scheduled).Error
+}
+
+func (s *ScheduledPaymentStore) GetByID(ctx context.Context, id string) (*models.ScheduledPayment, error) {
+	var scheduled models.ScheduledPayment
+	if err := s.GetDB(ctx).First(&scheduled, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &scheduled, nil
+}
+
+// ListByTenant returns a tenant's scheduled payments, soonest first.
+func (s *ScheduledPaymentStore) ListByTenant(ctx context.Context, tenantID string, filter models.ScheduledPaymentFilter) ([]*models.ScheduledPayment, error) {
+	query := s.GetDB(ctx).Where("tenant_id = ?", tenantID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var scheduled []*models.ScheduledPayment
+	if err := query.Order("run_at ASC").Limit(filter.Limit).Offset(filter.Offset).Find(&scheduled).Error; err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+func (s *ScheduledPaymentStore) Update(ctx context.Context, scheduled *models.ScheduledPayment) error {
+	return s.GetDB(ctx).Save(scheduled).Error
+}
+
+// ClaimDue locks scheduled payments whose run_at has passed, plus ones left
+// processing since staleBefore by a worker that died mid-run, and moves them
+// to processing before committing so callers can charge without holding row
+// locks.
+func (s *ScheduledPaymentStore) ClaimDue(ctx context.Context, now, staleBefore time.Time, limit int) ([]*models.ScheduledPayment, error) {
+	var claimed []*models.ScheduledPayment
+	err := s.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		var scheduled []*models.ScheduledPayment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", models.ScheduledPaymentStatusScheduled, now).
+			Or("status = ? AND updated_at <= ?", models.ScheduledPaymentStatusProcessing, staleBefore).
+			Order("run_at ASC").
+			Limit(limit).
+			Find(&scheduled).Error
+		if err != nil {
+			return err
+		}
+		if len(scheduled) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(scheduled))
+		for i, sp := range scheduled {
+			ids[i] = sp.ID
+			sp.Status = models.ScheduledPaymentStatusProcessing
+		}
+		if err := tx.Model(&models.ScheduledPayment{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":     models.ScheduledPaymentStatusProcessing,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		claimed = scheduled
+		return nil
+	})
+	return claimed, err
+}
+
+// Cancel moves a scheduled payment to canceled only if it has not been
+// claimed yet, reporting whether the row changed.
+func (s *ScheduledPaymentStore) Cancel(ctx context.Context, id string, at time.Time) (bool, error) {
+	result := s.GetDB(ctx).Model(&models.ScheduledPayment{}).
+		Where("id = ? AND status = ?", id, models.ScheduledPaymentStatusScheduled).
+		Updates(map[string]interface{}{
+			"status":      models.ScheduledPaymentStatusCanceled,
+			"canceled_at": at,
+		})
+	return result.RowsAffected > 0, result.Error
+}
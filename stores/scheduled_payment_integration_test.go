@@ -0,0 +1,72 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/stores"
+)
+
+func TestScheduledPaymentClaimDueSkipsFutureAndCanceled(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.ScheduledPayment{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	store := stores.CreateScheduledPaymentStore(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	seed := func(runAt time.Time) *models.ScheduledPayment {
+		t.Helper()
+		scheduled := &models.ScheduledPayment{
+			Request: models.ChargeRequest{Amount: 1000, Currency: "USD", PaymentMethod: "pm_card_visa"},
+			RunAt:   runAt,
+			Status:  models.ScheduledPaymentStatusScheduled,
+		}
+		if err := store.Create(ctx, scheduled); err != nil {
+			t.Fatalf("seed scheduled payment: %v", err)
+		}
+		return scheduled
+	}
+	due := seed(now.Add(-time.Minute))
+	future := seed(now.Add(time.Hour))
+	canceled := seed(now.Add(-time.Minute))
+
+	if ok, err := store.Cancel(ctx, canceled.ID, now); err != nil || !ok {
+		t.Fatalf("expected cancel to succeed, got %v, %v", ok, err)
+	}
+
+	claimed, err := store.ClaimDue(ctx, now, now.Add(-15*time.Minute), 10)
+	if err != nil {
+		t.Fatalf("claim due: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != due.ID {
+		t.Fatalf("expected only %s to be claimed, got %+v", due.ID, claimed)
+	}
+	if claimed[0].Request.Amount != 1000 || claimed[0].Request.PaymentMethod != "pm_card_visa" {
+		t.Fatalf("expected the charge request to round-trip, got %+v", claimed[0].Request)
+	}
+
+	stored, err := store.GetByID(ctx, due.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if stored.Status != models.ScheduledPaymentStatusProcessing {
+		t.Fatalf("expected the claimed payment to be processing, got %s", stored.Status)
+	}
+	if ok, err := store.Cancel(ctx, due.ID, now); err != nil || ok {
+		t.Fatalf("expected a claimed payment not to be cancelable, got %v, %v", ok, err)
+	}
+
+	if again, err := store.ClaimDue(ctx, now, now.Add(-15*time.Minute), 10); err != nil || len(again) != 0 {
+		t.Fatalf("expected nothing left to claim, got %d: %v", len(again), err)
+	}
+
+	if stored, err := store.GetByID(ctx, future.ID); err != nil || stored.Status != models.ScheduledPaymentStatusScheduled {
+		t.Fatalf("expected the future payment to stay scheduled, got %+v: %v", stored, err)
+	}
+}
@@ -2,6 +2,7 @@ package stores
 
 import (
 	"context"
+	"time"
 
 	"github.com/malwarebo/conductor/models"
 	"gorm.io/gorm"
@@ -47,6 +48,19 @@ func (r *SubscriptionRepository) ListActive(ctx context.Context) ([]*models.Subs
 	return subscriptions, nil
 }
 
+func (r *SubscriptionRepository) ListDueForDunning(ctx context.Context, before time.Time, limit int) ([]*models.Subscription, error) {
+	var subscriptions []*models.Subscription
+	err := r.GetDB(ctx).
+		Where("status = ? AND next_dunning_at <= ?", models.SubscriptionStatusPastDue, before).
+		Order("next_dunning_at ASC").
+		Limit(limit).
+		Find(&subscriptions).Error
+	if err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
 func (r *SubscriptionRepository) Delete(ctx context.Context, id string) error {
 	return r.GetDB(ctx).Delete(&models.Subscription{}, "id = ?", id).Error
 }
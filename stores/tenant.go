@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"time"
 
 	"github.com/malwarebo/conductor/models"
 	"gorm.io/gorm"
@@ -17,6 +18,11 @@ func CreateTenantStore(db *gorm.DB) *TenantStore {
 	return &TenantStore{BaseStore: BaseStore{db: db}}
 }
 
+// live scopes a query to tenants that have not been soft-deleted.
+func (s *TenantStore) live(ctx context.Context) *gorm.DB {
+	return s.GetDB(ctx).Where("deleted_at IS NULL")
+}
+
 func (s *TenantStore) Create(ctx context.Context, tenant *models.Tenant) error {
 	if tenant.APIKey == "" {
 		tenant.APIKey = s.generateAPIKey()
@@ -33,7 +39,7 @@ func (s *TenantStore) Update(ctx context.Context, tenant *models.Tenant) error {
 
 func (s *TenantStore) GetByID(ctx context.Context, id string) (*models.Tenant, error) {
 	var tenant models.Tenant
-	if err := s.GetDB(ctx).First(&tenant, "id = ?", id).Error; err != nil {
+	if err := s.live(ctx).First(&tenant, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &tenant, nil
@@ -41,7 +47,7 @@ func (s *TenantStore) GetByID(ctx context.Context, id string) (*models.Tenant, e
 
 func (s *TenantStore) GetByAPIKey(ctx context.Context, apiKey string) (*models.Tenant, error) {
 	var tenant models.Tenant
-	if err := s.GetDB(ctx).Where("api_key = ? AND is_active = true", apiKey).First(&tenant).Error; err != nil {
+	if err := s.live(ctx).Where("api_key = ? AND is_active = true", apiKey).First(&tenant).Error; err != nil {
 		return nil, err
 	}
 	return &tenant, nil
@@ -51,7 +57,7 @@ func (s *TenantStore) List(ctx context.Context, activeOnly bool, limit, offset i
 	var tenants []*models.Tenant
 	var total int64
 
-	query := s.GetDB(ctx).Model(&models.Tenant{})
+	query := s.live(ctx).Model(&models.Tenant{})
 	if activeOnly {
 		query = query.Where("is_active = true")
 	}
@@ -74,17 +80,41 @@ func (s *TenantStore) List(ctx context.Context, activeOnly bool, limit, offset i
 	return tenants, total, nil
 }
 
+// Delete soft-deletes the tenant. The row and everything referencing it are
+// kept so payments and audit logs stay intact; Restore brings it back.
 func (s *TenantStore) Delete(ctx context.Context, id string) error {
-	return s.GetDB(ctx).Delete(&models.Tenant{}, "id = ?", id).Error
+	result := s.live(ctx).Model(&models.Tenant{}).Where("id = ?", id).Update("deleted_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Restore clears a soft delete. It returns gorm.ErrRecordNotFound when the
+// tenant does not exist or is not deleted.
+func (s *TenantStore) Restore(ctx context.Context, id string) error {
+	result := s.GetDB(ctx).Model(&models.Tenant{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 func (s *TenantStore) Deactivate(ctx context.Context, id string) error {
-	return s.GetDB(ctx).Model(&models.Tenant{}).Where("id = ?", id).Update("is_active", false).Error
+	return s.live(ctx).Model(&models.Tenant{}).Where("id = ?", id).Update("is_active", false).Error
 }
 
 func (s *TenantStore) RegenerateAPISecret(ctx context.Context, id string) (string, error) {
 	newSecret := s.generateAPISecret()
-	err := s.GetDB(ctx).Model(&models.Tenant{}).Where("id = ?", id).Update("api_secret", newSecret).Error
+	err := s.live(ctx).Model(&models.Tenant{}).Where("id = ?", id).Update("api_secret", newSecret).Error
 	if err != nil {
 		return "", err
 	}
@@ -93,7 +123,7 @@ func (s *TenantStore) RegenerateAPISecret(ctx context.Context, id string) (strin
 
 func (s *TenantStore) ValidateCredentials(ctx context.Context, apiKey, apiSecret string) (*models.Tenant, error) {
 	var tenant models.Tenant
-	if err := s.GetDB(ctx).Where("api_key = ? AND api_secret = ? AND is_active = true", apiKey, apiSecret).First(&tenant).Error; err != nil {
+	if err := s.live(ctx).Where("api_key = ? AND api_secret = ? AND is_active = true", apiKey, apiSecret).First(&tenant).Error; err != nil {
 		return nil, err
 	}
 	return &tenant, nil
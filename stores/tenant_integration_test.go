@@ -0,0 +1,94 @@
+//go:build integration
+
+package stores_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/malwarebo/conductor/middleware"
+	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
+	"github.com/malwarebo/conductor/stores"
+	"gorm.io/gorm"
+)
+
+func newTenantStore(t *testing.T) *stores.TenantStore {
+	t.Helper()
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Tenant{}); err != nil {
+		t.Fatalf("migrate tenants: %v", err)
+	}
+	return stores.CreateTenantStore(db)
+}
+
+func TestTenantDeleteThenRestore(t *testing.T) {
+	store := newTenantStore(t)
+	ctx := context.Background()
+
+	tenant := &models.Tenant{Name: "Acme", IsActive: true}
+	if err := store.Create(ctx, tenant); err != nil {
+		t.Fatalf("create tenant: %v", err)
+	}
+
+	if err := store.Delete(ctx, tenant.ID); err != nil {
+		t.Fatalf("delete tenant: %v", err)
+	}
+	if _, err := store.GetByID(ctx, tenant.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected a deleted tenant to be hidden, got %v", err)
+	}
+	if tenants, total, err := store.List(ctx, false, 10, 0); err != nil || total != 0 || len(tenants) != 0 {
+		t.Fatalf("expected no tenants listed after delete, got %d (%v)", total, err)
+	}
+	if err := store.Delete(ctx, tenant.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected deleting twice to report not found, got %v", err)
+	}
+
+	if err := store.Restore(ctx, tenant.ID); err != nil {
+		t.Fatalf("restore tenant: %v", err)
+	}
+	restored, err := store.GetByID(ctx, tenant.ID)
+	if err != nil {
+		t.Fatalf("expected the restored tenant to be visible: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.APIKey != tenant.APIKey {
+		t.Fatalf("unexpected restored tenant: %+v", restored)
+	}
+	if err := store.Restore(ctx, tenant.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected restoring a live tenant to report not found, got %v", err)
+	}
+}
+
+func TestDeletedTenantCannotAuthenticate(t *testing.T) {
+	store := newTenantStore(t)
+	ctx := context.Background()
+
+	tenant := &models.Tenant{Name: "Acme", IsActive: true}
+	if err := store.Create(ctx, tenant); err != nil {
+		t.Fatalf("create tenant: %v", err)
+	}
+	if err := store.Delete(ctx, tenant.ID); err != nil {
+		t.Fatalf("delete tenant: %v", err)
+	}
+
+	if _, err := store.ValidateCredentials(ctx, tenant.APIKey, tenant.APISecret); err == nil {
+		t.Fatal("expected credentials of a deleted tenant to be rejected")
+	}
+
+	tm := middleware.CreateTenantMiddleware(services.CreateTenantService(store), nil)
+	handler := tm.TenantContextMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not run for a deleted tenant")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/payments", nil)
+	req.Header.Set("X-API-Key", tenant.APIKey)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
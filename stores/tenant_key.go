@@ -0,0 +1,39 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type TenantKeyStore struct {
+	BaseStore
+}
+
+func CreateTenantKeyStore(db *gorm.DB) *TenantKeyStore {
+	return &TenantKeyStore{BaseStore: BaseStore{db: db}}
+}
+
+func (s *TenantKeyStore) ListTenantKeys(ctx context.Context, tenantID string) ([]*models.TenantDataKey, error) {
+	var keys []*models.TenantDataKey
+	if err := s.GetDB(ctx).Where("tenant_id = ?", tenantID).Order("created_at ASC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// AddTenantKey stores key as the tenant's primary key and demotes the one it
+// replaces. The partial unique index on is_primary makes a concurrent add for
+// the same tenant fail rather than leave two primaries.
+func (s *TenantKeyStore) AddTenantKey(ctx context.Context, key *models.TenantDataKey) error {
+	return s.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := s.GetDB(ctx).Model(&models.TenantDataKey{}).
+			Where("tenant_id = ? AND is_primary", key.TenantID).
+			Update("is_primary", false).Error; err != nil {
+			return err
+		}
+		key.Primary = true
+		return s.GetDB(ctx).Create(key).Error
+	})
+}
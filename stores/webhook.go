@@ -2,6 +2,7 @@ package stores
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/malwarebo/conductor/models"
@@ -9,6 +10,10 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// ErrWebhookEventNotClaimable is returned when an event is already being
+// processed or has finished, so another worker must not pick it up.
+var ErrWebhookEventNotClaimable = errors.New("webhook event is not claimable")
+
 type WebhookStore struct {
 	BaseStore
 }
@@ -21,6 +26,26 @@ func (s *WebhookStore) Create(ctx context.Context, event *models.WebhookEvent) e
 	return s.GetDB(ctx).Create(event).Error
 }
 
+// CreateIfNotExists inserts the event unless the provider already delivered
+// one with the same event ID, reporting whether a row was written. Events
+// without an ID are always inserted.
+func (s *WebhookStore) CreateIfNotExists(ctx context.Context, event *models.WebhookEvent) (bool, error) {
+	if event.EventID == "" {
+		if err := s.Create(ctx, event); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	result := s.GetDB(ctx).
+		Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "provider"}, {Name: "event_id"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "event_id <> ''"}}},
+			DoNothing:   true,
+		}).
+		Create(event)
+	return result.RowsAffected > 0, result.Error
+}
+
 func (s *WebhookStore) Update(ctx context.Context, event *models.WebhookEvent) error {
 	return s.GetDB(ctx).Save(event).Error
 }
@@ -111,15 +136,44 @@ func (s *WebhookStore) ClaimPendingEvents(ctx context.Context, limit int, staleA
 	return claimed, err
 }
 
-func (s *WebhookStore) MarkProcessing(ctx context.Context, id string) error {
-	now := time.Now()
-	return s.GetDB(ctx).Model(&models.WebhookEvent{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"status":          models.WebhookEventStatusProcessing,
-			"last_attempt_at": now,
-			"attempts":        gorm.Expr("attempts + 1"),
-		}).Error
+// MarkProcessing locks the event row and moves it to processing. Events that
+// another worker already holds or has finished return
+// ErrWebhookEventNotClaimable.
+func (s *WebhookStore) MarkProcessing(ctx context.Context, id string) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+
+	err := s.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&event, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		switch event.Status {
+		case models.WebhookEventStatusPending, models.WebhookEventStatusRetrying:
+		default:
+			return ErrWebhookEventNotClaimable
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.WebhookEvent{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"status":          models.WebhookEventStatusProcessing,
+				"last_attempt_at": now,
+				"attempts":        gorm.Expr("attempts + 1"),
+			}).Error; err != nil {
+			return err
+		}
+
+		event.Status = models.WebhookEventStatusProcessing
+		event.LastAttemptAt = &now
+		event.Attempts++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
 }
 
 func (s *WebhookStore) MarkCompleted(ctx context.Context, id string) error {
@@ -132,6 +186,8 @@ func (s *WebhookStore) MarkCompleted(ctx context.Context, id string) error {
 		}).Error
 }
 
+// MarkFailed records a failed attempt. Without a retry the event moves to the
+// dead-letter state, keeping errMsg as the final failure reason.
 func (s *WebhookStore) MarkFailed(ctx context.Context, id string, errMsg string, scheduleRetry bool) error {
 	updates := map[string]interface{}{
 		"error_message": errMsg,
@@ -141,12 +197,149 @@ func (s *WebhookStore) MarkFailed(ctx context.Context, id string, errMsg string,
 		updates["status"] = models.WebhookEventStatusRetrying
 		updates["next_attempt_at"] = s.calculateNextAttempt(ctx, id)
 	} else {
-		updates["status"] = models.WebhookEventStatusFailed
+		updates["status"] = models.WebhookEventStatusDeadLetter
+		updates["dead_lettered_at"] = time.Now()
 	}
 
 	return s.GetDB(ctx).Model(&models.WebhookEvent{}).Where("id = ?", id).Updates(updates).Error
 }
 
+// MarkPublishFailed flags an event whose forwarding to the event publisher
+// failed so ListPublishPending returns it for another try.
+func (s *WebhookStore) MarkPublishFailed(ctx context.Context, id string, errMsg string) error {
+	return s.GetDB(ctx).Model(&models.WebhookEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"publish_pending": true,
+			"publish_error":   errMsg,
+		}).Error
+}
+
+func (s *WebhookStore) MarkPublished(ctx context.Context, id string) error {
+	return s.GetDB(ctx).Model(&models.WebhookEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"publish_pending": false,
+			"publish_error":   "",
+		}).Error
+}
+
+// ListPublishPending returns events waiting to be forwarded again, oldest
+// first.
+func (s *WebhookStore) ListPublishPending(ctx context.Context, limit int) ([]*models.WebhookEvent, error) {
+	var events []*models.WebhookEvent
+	if err := s.GetDB(ctx).
+		Where("publish_pending = ?", true).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ListDeadLetterEvents returns dead-lettered events, most recently
+// dead-lettered first. An empty provider lists every provider.
+func (s *WebhookStore) ListDeadLetterEvents(ctx context.Context, provider string, limit, offset int) ([]*models.WebhookEvent, error) {
+	var events []*models.WebhookEvent
+	query := s.GetDB(ctx).Where("status = ?", models.WebhookEventStatusDeadLetter)
+
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	if err := query.Order("dead_lettered_at DESC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ReprocessEvent claims a dead-lettered event for one more attempt, moving it
+// to processing. Events in any other state return
+// ErrWebhookEventNotClaimable.
+func (s *WebhookStore) ReprocessEvent(ctx context.Context, id string) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+
+	err := s.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&event, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if event.Status != models.WebhookEventStatusDeadLetter {
+			return ErrWebhookEventNotClaimable
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.WebhookEvent{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"status":          models.WebhookEventStatusProcessing,
+				"last_attempt_at": now,
+				"next_attempt_at": nil,
+				"attempts":        gorm.Expr("attempts + 1"),
+			}).Error; err != nil {
+			return err
+		}
+
+		event.Status = models.WebhookEventStatusProcessing
+		event.LastAttemptAt = &now
+		event.NextAttemptAt = nil
+		event.Attempts++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// ResetToPending puts a stored event back to pending so it can be
+// processed again, whatever state it finished in. The replay starts with a
+// fresh retry budget. An event that a worker holds right now returns
+// ErrWebhookEventNotClaimable.
+func (s *WebhookStore) ResetToPending(ctx context.Context, id string) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+
+	err := s.GetDB(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&event, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if event.Status == models.WebhookEventStatusProcessing {
+			return ErrWebhookEventNotClaimable
+		}
+
+		if err := tx.Model(&models.WebhookEvent{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"status":           models.WebhookEventStatusPending,
+				"attempts":         0,
+				"error_message":    "",
+				"next_attempt_at":  nil,
+				"dead_lettered_at": nil,
+			}).Error; err != nil {
+			return err
+		}
+
+		event.Status = models.WebhookEventStatusPending
+		event.Attempts = 0
+		event.ErrorMessage = ""
+		event.NextAttemptAt = nil
+		event.DeadLetteredAt = nil
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
 func (s *WebhookStore) calculateNextAttempt(ctx context.Context, id string) time.Time {
 	var event models.WebhookEvent
 	s.GetDB(ctx).Select("attempts").First(&event, "id = ?", id)
@@ -0,0 +1,66 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type WebhookDeliveryRepository struct {
+	BaseStore
+}
+
+func CreateWebhookDeliveryRepository(db *gorm.DB) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{BaseStore: BaseStore{db: db}}
+}
+
+func (r *WebhookDeliveryRepository) Create(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return r.GetDB(ctx).Create(delivery).Error
+}
+
+func (r *WebhookDeliveryRepository) GetByID(ctx context.Context, tenantID, id string) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := r.GetDB(ctx).First(&delivery, "id = ? AND tenant_id = ?", id, tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// List returns a tenant's deliveries, newest first.
+func (r *WebhookDeliveryRepository) List(ctx context.Context, filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	query := r.GetDB(ctx).Where("tenant_id = ?", filter.TenantID)
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.StartDate != nil {
+		query = query.Where("created_at >= ?", *filter.StartDate)
+	}
+	if filter.EndDate != nil {
+		query = query.Where("created_at <= ?", *filter.EndDate)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var deliveries []*models.WebhookDelivery
+	if err := query.Order("created_at DESC").Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// CountByEvent returns how many delivery attempts have been made for an event.
+func (r *WebhookDeliveryRepository) CountByEvent(ctx context.Context, tenantID, eventID string) (int64, error) {
+	var count int64
+	err := r.GetDB(ctx).Model(&models.WebhookDelivery{}).
+		Where("tenant_id = ? AND event_id = ?", tenantID, eventID).
+		Count(&count).Error
+	return count, err
+}
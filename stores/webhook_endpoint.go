@@ -0,0 +1,52 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/malwarebo/conductor/models"
+	"gorm.io/gorm"
+)
+
+type WebhookEndpointStore struct {
+	BaseStore
+}
+
+func CreateWebhookEndpointStore(db *gorm.DB) *WebhookEndpointStore {
+	return &WebhookEndpointStore{BaseStore: BaseStore{db: db}}
+}
+
+func (s *WebhookEndpointStore) Create(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	return s.GetDB(ctx).Create(endpoint).Error
+}
+
+func (s *WebhookEndpointStore) GetByID(ctx context.Context, tenantID, id string) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	if err := s.GetDB(ctx).First(&endpoint, "id = ? AND tenant_id = ?", id, tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// ListByTenant returns a tenant's endpoints, oldest first.
+func (s *WebhookEndpointStore) ListByTenant(ctx context.Context, tenantID string) ([]*models.WebhookEndpoint, error) {
+	var endpoints []*models.WebhookEndpoint
+	if err := s.GetDB(ctx).Where("tenant_id = ?", tenantID).Order("created_at ASC").Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (s *WebhookEndpointStore) Update(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	return s.GetDB(ctx).Save(endpoint).Error
+}
+
+func (s *WebhookEndpointStore) Delete(ctx context.Context, tenantID, id string) error {
+	result := s.GetDB(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).Delete(&models.WebhookEndpoint{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
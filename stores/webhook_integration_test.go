@@ -4,6 +4,7 @@ package stores_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/malwarebo/conductor/internal/worker"
 	"github.com/malwarebo/conductor/models"
+	"github.com/malwarebo/conductor/services"
 	"github.com/malwarebo/conductor/stores"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
@@ -256,3 +258,268 @@ func TestWorkerPoolDrainsOutbox(t *testing.T) {
 		t.Fatalf("expected %d completed events, got %d", total, completed)
 	}
 }
+
+type countingInvoiceHandler struct {
+	mu   sync.Mutex
+	paid int
+}
+
+func (h *countingInvoiceHandler) HandleInvoicePaid(ctx context.Context, subscriptionID, invoiceID string) error {
+	h.mu.Lock()
+	h.paid++
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *countingInvoiceHandler) HandleInvoicePaymentFailed(ctx context.Context, subscriptionID, invoiceID string) error {
+	return nil
+}
+
+func TestConcurrentDuplicateDeliveryProcessedOnce(t *testing.T) {
+	db := newTestDB(t)
+	store := stores.CreateWebhookStore(db)
+	handler := &countingInvoiceHandler{}
+	svc := services.CreateWebhookService(store, nil, nil, nil)
+	svc.SetInvoiceEventHandler(handler)
+	ctx := context.Background()
+
+	payload := []byte(`{"id":"evt_dup","type":"invoice.paid","data":{"object":{"id":"in_1","subscription":"sub_1"}}}`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := svc.ProcessInboundWebhook(ctx, "stripe", "evt_dup", "invoice.paid", payload); err != nil {
+				t.Errorf("deliver: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var events []models.WebhookEvent
+	if err := db.Where("provider = ? AND event_id = ?", "stripe", "evt_dup").Find(&events).Error; err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected one stored event, got %d", len(events))
+	}
+
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := svc.ProcessEvent(ctx, events[0].ID); err != nil {
+				t.Errorf("process: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if handler.paid != 1 {
+		t.Fatalf("expected invoice handler to run once, ran %d times", handler.paid)
+	}
+
+	stored, err := store.GetByID(ctx, events[0].ID)
+	if err != nil {
+		t.Fatalf("get event: %v", err)
+	}
+	if stored.Status != models.WebhookEventStatusCompleted || stored.Attempts != 1 {
+		t.Fatalf("expected completed after one attempt, got status=%s attempts=%d", stored.Status, stored.Attempts)
+	}
+}
+
+func TestMarkProcessingRejectsCompletedEvent(t *testing.T) {
+	db := newTestDB(t)
+	store := stores.CreateWebhookStore(db)
+	ctx := context.Background()
+	seedPending(t, store, 1)
+
+	var ev models.WebhookEvent
+	if err := db.First(&ev).Error; err != nil {
+		t.Fatalf("load event: %v", err)
+	}
+	if _, err := store.MarkProcessing(ctx, ev.ID); err != nil {
+		t.Fatalf("first mark: %v", err)
+	}
+	if err := store.MarkCompleted(ctx, ev.ID); err != nil {
+		t.Fatalf("mark completed: %v", err)
+	}
+	if _, err := store.MarkProcessing(ctx, ev.ID); !errors.Is(err, stores.ErrWebhookEventNotClaimable) {
+		t.Fatalf("expected ErrWebhookEventNotClaimable, got %v", err)
+	}
+}
+
+type flakyInvoiceHandler struct {
+	mu   sync.Mutex
+	fail bool
+	paid int
+}
+
+func (h *flakyInvoiceHandler) HandleInvoicePaid(ctx context.Context, subscriptionID, invoiceID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.fail {
+		return errors.New("subscription store unavailable")
+	}
+	h.paid++
+	return nil
+}
+
+func (h *flakyInvoiceHandler) HandleInvoicePaymentFailed(ctx context.Context, subscriptionID, invoiceID string) error {
+	return nil
+}
+
+func TestExhaustedEventDeadLetteredAndReprocessed(t *testing.T) {
+	db := newTestDB(t)
+	store := stores.CreateWebhookStore(db)
+	handler := &flakyInvoiceHandler{fail: true}
+	svc := services.CreateWebhookService(store, nil, nil, nil)
+	svc.SetInvoiceEventHandler(handler)
+	ctx := context.Background()
+
+	ev := &models.WebhookEvent{
+		Provider:    "stripe",
+		EventType:   "invoice.paid",
+		EventID:     "evt_dead",
+		Payload:     models.JSON{"type": "invoice.paid", "data": map[string]interface{}{"object": map[string]interface{}{"id": "in_1", "subscription": "sub_1"}}},
+		Status:      models.WebhookEventStatusPending,
+		MaxAttempts: 2,
+	}
+	if err := store.Create(ctx, ev); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for i := 0; i < ev.MaxAttempts; i++ {
+		if err := svc.ProcessEvent(ctx, ev.ID); err == nil {
+			t.Fatalf("attempt %d: expected the handler failure", i+1)
+		}
+	}
+
+	dead, err := store.GetByID(ctx, ev.ID)
+	if err != nil {
+		t.Fatalf("get event: %v", err)
+	}
+	if dead.Status != models.WebhookEventStatusDeadLetter || dead.DeadLetteredAt == nil {
+		t.Fatalf("expected the event dead-lettered after %d attempts, got status=%s", ev.MaxAttempts, dead.Status)
+	}
+	if dead.ErrorMessage == "" {
+		t.Fatalf("expected the final failure reason recorded")
+	}
+	if claimed, _ := store.ClaimPendingEvents(ctx, 10, time.Minute); len(claimed) != 0 {
+		t.Fatalf("dead-lettered event must not be claimed, got %d", len(claimed))
+	}
+
+	listed, err := svc.ListDeadLetterEvents(ctx, models.DeadLetterFilter{Provider: "stripe", Limit: 10})
+	if err != nil {
+		t.Fatalf("list dead letter: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != ev.ID {
+		t.Fatalf("expected the event in the dead-letter list, got %d events", len(listed))
+	}
+
+	handler.mu.Lock()
+	handler.fail = false
+	handler.mu.Unlock()
+
+	reprocessed, err := svc.ReprocessEvent(ctx, ev.ID)
+	if err != nil {
+		t.Fatalf("reprocess: %v", err)
+	}
+	if reprocessed.Status != models.WebhookEventStatusCompleted || handler.paid != 1 {
+		t.Fatalf("expected the reprocessed event completed, got status=%s paid=%d", reprocessed.Status, handler.paid)
+	}
+
+	if _, err := svc.ReprocessEvent(ctx, ev.ID); !errors.Is(err, services.ErrWebhookEventNotDeadLettered) {
+		t.Fatalf("expected ErrWebhookEventNotDeadLettered, got %v", err)
+	}
+}
+
+func TestReprocessCompletedPaymentSucceededEvent(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Payment{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	store := stores.CreateWebhookStore(db)
+	payments := stores.CreatePaymentRepository(db)
+	svc := services.CreateWebhookService(store, payments, nil, nil)
+	ctx := context.Background()
+
+	payment := &models.Payment{
+		CustomerID:       "cus_1",
+		Amount:           2000,
+		Currency:         "USD",
+		Status:           models.PaymentStatusPending,
+		PaymentMethod:    "card",
+		ProviderName:     "stripe",
+		ProviderChargeID: "pi_replay",
+	}
+	if err := payments.Create(ctx, payment); err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+
+	payload := []byte(`{"id":"evt_replay","type":"payment_intent.succeeded","data":{"object":{"id":"pi_replay","amount_received":2000}}}`)
+	if err := svc.ProcessInboundWebhook(ctx, "stripe", "evt_replay", "payment_intent.succeeded", payload); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	stored, err := store.GetByEventID(ctx, "stripe", "evt_replay")
+	if err != nil {
+		t.Fatalf("get event: %v", err)
+	}
+	if err := svc.ProcessEvent(ctx, stored.ID); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		replayed, err := svc.Reprocess(ctx, "stripe", "evt_replay")
+		if err != nil {
+			t.Fatalf("reprocess %d: %v", i+1, err)
+		}
+		if replayed.ID != stored.ID || replayed.Status != models.WebhookEventStatusCompleted || replayed.Attempts != 1 {
+			t.Fatalf("reprocess %d: expected the event completed after one fresh attempt, got status=%s attempts=%d",
+				i+1, replayed.Status, replayed.Attempts)
+		}
+	}
+
+	got, err := payments.GetByProviderChargeID(ctx, "pi_replay")
+	if err != nil {
+		t.Fatalf("get payment: %v", err)
+	}
+	if got.Status != models.PaymentStatusSuccess || got.CapturedAmount != 2000 {
+		t.Fatalf("expected the payment to stay succeeded with 2000 captured, got status=%s captured=%d", got.Status, got.CapturedAmount)
+	}
+	var count int64
+	if err := db.Model(&models.WebhookEvent{}).Where("event_id = ?", "evt_replay").Count(&count).Error; err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected reprocessing to reuse the stored event, got %d rows", count)
+	}
+
+	if _, err := svc.Reprocess(ctx, "stripe", "evt_missing"); !errors.Is(err, services.ErrWebhookEventNotFound) {
+		t.Fatalf("expected ErrWebhookEventNotFound, got %v", err)
+	}
+}
+
+func TestUnhandledEventTypeAcknowledgedButNotStored(t *testing.T) {
+	db := newTestDB(t)
+	store := stores.CreateWebhookStore(db)
+	svc := services.CreateWebhookService(store, nil, nil, nil)
+	ctx := context.Background()
+
+	payload := []byte(`{"id":"evt_noise","type":"customer.created","data":{"object":{"id":"cus_1"}}}`)
+	if err := svc.ProcessInboundWebhook(ctx, "stripe", "evt_noise", "customer.created", payload); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if _, err := store.GetByEventID(ctx, "stripe", "evt_noise"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the unhandled event not to be stored, got %v", err)
+	}
+
+	svc.RegisterHandledEventTypes("stripe", "customer.created")
+	if err := svc.ProcessInboundWebhook(ctx, "stripe", "evt_noise", "customer.created", payload); err != nil {
+		t.Fatalf("deliver after registering: %v", err)
+	}
+	if _, err := store.GetByEventID(ctx, "stripe", "evt_noise"); err != nil {
+		t.Fatalf("expected the registered event type to be stored: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+const (
+	IPRiskUnknown    = "unknown"
+	IPRiskNormal     = "normal"
+	IPRiskDatacenter = "datacenter"
+)
+
+var ErrInvalidGeoIPDatabase = errors.New("invalid GeoIP database")
+
+type IPInfo struct {
+	Risk           string `json:"risk"`
+	Country        string `json:"country,omitempty"`
+	ASN            uint32 `json:"asn,omitempty"`
+	ASOrganization string `json:"as_organization,omitempty"`
+	IsDatacenter   bool   `json:"is_datacenter"`
+}
+
+// geoRecord is the union of the fields conductor reads from MaxMind
+// databases: country from GeoIP2/GeoLite2 Country or City, the AS fields from
+// GeoLite2 ASN and is_hosting_provider from GeoIP2 Anonymous IP.
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	ASN               uint32 `maxminddb:"autonomous_system_number"`
+	ASOrganization    string `maxminddb:"autonomous_system_organization"`
+	IsHostingProvider bool   `maxminddb:"is_hosting_provider"`
+}
+
+// IPAnalyzer classifies client IPs for fraud scoring. Without a GeoIP
+// database it only distinguishes missing or malformed addresses.
+type IPAnalyzer struct {
+	readers []*maxminddb.Reader
+}
+
+func CreateIPAnalyzer() *IPAnalyzer {
+	return &IPAnalyzer{}
+}
+
+// LoadIPAnalyzer opens one or more MaxMind .mmdb databases, for example
+// GeoLite2-Country plus GeoLite2-ASN. Each address is looked up in every
+// database and the fields found are merged. Call Close when done.
+func LoadIPAnalyzer(paths ...string) (*IPAnalyzer, error) {
+	analyzer := &IPAnalyzer{}
+	for _, path := range paths {
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			_ = analyzer.Close()
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidGeoIPDatabase, path, err)
+		}
+		analyzer.readers = append(analyzer.readers, reader)
+	}
+	return analyzer, nil
+}
+
+func (a *IPAnalyzer) Close() error {
+	if a == nil {
+		return nil
+	}
+	var errs []error
+	for _, reader := range a.readers {
+		errs = append(errs, reader.Close())
+	}
+	a.readers = nil
+	return errors.Join(errs...)
+}
+
+func (a *IPAnalyzer) AnalyzeIP(ip string) IPInfo {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return IPInfo{Risk: IPRiskUnknown}
+	}
+	addr = addr.Unmap()
+
+	info, ok := a.lookup(addr)
+	if !ok {
+		return IPInfo{Risk: IPRiskNormal}
+	}
+	info.Risk = IPRiskNormal
+	if info.IsDatacenter {
+		info.Risk = IPRiskDatacenter
+	}
+	return info
+}
+
+func (a *IPAnalyzer) lookup(addr netip.Addr) (IPInfo, bool) {
+	if a == nil {
+		return IPInfo{}, false
+	}
+
+	var info IPInfo
+	found := false
+	for _, reader := range a.readers {
+		result := reader.Lookup(addr)
+		if !result.Found() {
+			continue
+		}
+		var record geoRecord
+		if err := result.Decode(&record); err != nil {
+			continue
+		}
+		found = true
+		if record.Country.ISOCode != "" {
+			info.Country = strings.ToUpper(record.Country.ISOCode)
+		}
+		if record.ASN != 0 {
+			info.ASN = record.ASN
+			info.ASOrganization = record.ASOrganization
+		}
+		info.IsDatacenter = info.IsDatacenter || record.IsHostingProvider
+	}
+	return info, found
+}
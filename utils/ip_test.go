@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestIPAnalyzerEnrichesFromGeoIPDatabase(t *testing.T) {
+	analyzer, err := LoadIPAnalyzer(filepath.Join("testdata", "geoip.mmdb"))
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	defer analyzer.Close()
+
+	cases := []struct {
+		ip   string
+		want IPInfo
+	}{
+		{"81.2.69.160", IPInfo{Risk: IPRiskNormal, Country: "GB", ASN: 20712, ASOrganization: "Andrews & Arnold Ltd"}},
+		{"203.0.113.9", IPInfo{Risk: IPRiskDatacenter, Country: "SG", ASN: 64500, ASOrganization: "Example Cloud Hosting", IsDatacenter: true}},
+		{"::ffff:203.0.113.9", IPInfo{Risk: IPRiskDatacenter, Country: "SG", ASN: 64500, ASOrganization: "Example Cloud Hosting", IsDatacenter: true}},
+		{"2001:db8::1", IPInfo{Risk: IPRiskNormal, Country: "DE", ASN: 64501, ASOrganization: "Example Transit"}},
+		{"81.2.70.1", IPInfo{Risk: IPRiskNormal}},
+		{"", IPInfo{Risk: IPRiskUnknown}},
+	}
+	for _, tc := range cases {
+		if got := analyzer.AnalyzeIP(tc.ip); got != tc.want {
+			t.Fatalf("AnalyzeIP(%q) = %+v, want %+v", tc.ip, got, tc.want)
+		}
+	}
+}
+
+func TestIPAnalyzerFallsBackWithoutDatabase(t *testing.T) {
+	if _, err := LoadIPAnalyzer(filepath.Join("testdata", "missing.mmdb")); err == nil {
+		t.Fatal("expected an error for a missing database")
+	}
+
+	var nilAnalyzer *IPAnalyzer
+	for _, analyzer := range []*IPAnalyzer{CreateIPAnalyzer(), nilAnalyzer} {
+		if got := analyzer.AnalyzeIP("81.2.69.160"); got != (IPInfo{Risk: IPRiskNormal}) {
+			t.Fatalf("expected heuristic result without enrichment, got %+v", got)
+		}
+		if got := analyzer.AnalyzeIP("not-an-ip"); got.Risk != IPRiskUnknown {
+			t.Fatalf("expected unknown risk for malformed IP, got %+v", got)
+		}
+	}
+}
+
+func TestIPAnalyzerRejectsMalformedDatabase(t *testing.T) {
+	_, err := LoadIPAnalyzer(filepath.Join("testdata", "legacy-geoip.csv"))
+	if !errors.Is(err, ErrInvalidGeoIPDatabase) {
+		t.Fatalf("expected ErrInvalidGeoIPDatabase, got %v", err)
+	}
+}
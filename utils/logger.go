@@ -10,10 +10,6 @@ import (
 	"github.com/malwarebo/conductor/internal/ctxkeys"
 )
 
-type contextKey string
-
-const correlationIDContextKey contextKey = "correlation_id"
-
 type LogLevel int
 
 const (
@@ -115,7 +111,7 @@ func (l *Logger) levelString(level LogLevel) string {
 }
 
 func CreateGetCorrelationID(ctx context.Context) string {
-	if id, ok := ctx.Value(correlationIDContextKey).(string); ok {
+	if id, ok := ctx.Value(ctxkeys.CorrelationID).(string); ok {
 		return id
 	}
 	return ""
@@ -129,7 +125,7 @@ func CreateGetUserID(ctx context.Context) string {
 }
 
 func CreateWithCorrelationID(ctx context.Context, id string) context.Context {
-	return context.WithValue(ctx, correlationIDContextKey, id)
+	return context.WithValue(ctx, ctxkeys.CorrelationID, id)
 }
 
 func CreateDebug(ctx context.Context, message string, fields ...map[string]interface{}) {